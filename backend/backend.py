@@ -27,6 +27,9 @@ DB_PATH = os.getenv("SANTAMON_DB_PATH", "signals.db")
 API_KEY = os.getenv("SANTAMON_API_KEY")
 MIN_API_KEY_LENGTH = 16
 ALLOWED_STATUSES = {"open", "acknowledged", "resolved"}
+# Agents call resolved signals closed
+AGENT_STATUSES = {"open": "open", "acknowledged": "acknowledged", "resolved": "closed"}
+TRIAGE_PAGE_SIZE = 500
 
 # Enforce API key presence and minimum length at startup
 if not API_KEY:
@@ -104,6 +107,48 @@ async def lifespan(app: FastAPI):
         CREATE INDEX IF NOT EXISTS idx_heartbeat_agent
         ON heartbeats(agent_id, received_at DESC)
     """)
+    # Create fleet first-seen table for fleet-level baselines
+    conn.execute(
+        """
+        CREATE TABLE IF NOT EXISTS fleet_first_seen (
+            rule_id TEXT NOT NULL,
+            pattern TEXT NOT NULL,
+            agent_id TEXT NOT NULL,
+            first_seen TEXT NOT NULL,
+            PRIMARY KEY (rule_id, pattern, agent_id)
+        )
+        """
+    )
+    # Create triage table recording signal status changes; agents poll it to
+    # stop re-alerting on signals analysts closed
+    conn.execute(
+        """
+        CREATE TABLE IF NOT EXISTS signal_triage (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            signal_id TEXT NOT NULL,
+            host_id TEXT NOT NULL,
+            rule_id TEXT NOT NULL,
+            status TEXT NOT NULL,
+            changed_by TEXT,
+            note TEXT,
+            ts TEXT NOT NULL
+        )
+        """
+    )
+    conn.execute("""
+        CREATE INDEX IF NOT EXISTS idx_triage_host
+        ON signal_triage(host_id, id)
+    """)
+    # Create baseline seed table holding the fleet's golden baseline
+    conn.execute(
+        """
+        CREATE TABLE IF NOT EXISTS baseline_seed (
+            id INTEGER PRIMARY KEY CHECK (id = 1),
+            seed TEXT NOT NULL,
+            uploaded_at TEXT NOT NULL
+        )
+        """
+    )
     conn.commit()
     conn.close()
     print(f"Database initialized: {DB_PATH}")
@@ -207,8 +252,61 @@ async def heartbeat(
         conn.close()
 
 
+class FleetFirstSeenQuery(BaseModel):
+    agent_id: str = Field(..., max_length=255)
+    rule_id: str = Field(..., max_length=64)
+    pattern: str = Field(..., max_length=4096)
+
+
+@app.post("/fleet/first-seen")
+async def fleet_first_seen(
+    query: FleetFirstSeenQuery,
+    x_api_key: str = Header(None, alias="X-API-Key")
+):
+    """
+    Record that an agent saw a baseline pattern for the first time and
+    return how many agents have seen it, so common patterns stop alerting
+
+    Authentication via X-API-Key header
+    """
+    if not x_api_key or not secrets.compare_digest(x_api_key, API_KEY):
+        raise HTTPException(status_code=401, detail="Invalid API key")
+
+    conn = sqlite3.connect(DB_PATH, timeout=5.0)
+    try:
+        conn.execute(
+            """
+            INSERT OR IGNORE INTO fleet_first_seen VALUES
+            (?, ?, ?, ?)
+            """,
+            (
+                query.rule_id,
+                query.pattern,
+                query.agent_id,
+                datetime.utcnow().strftime("%Y-%m-%dT%H:%M:%SZ"),
+            ),
+        )
+        conn.commit()
+
+        hosts, first_seen = conn.execute(
+            """
+            SELECT COUNT(*), MIN(first_seen) FROM fleet_first_seen
+            WHERE rule_id = ? AND pattern = ?
+            """,
+            (query.rule_id, query.pattern),
+        ).fetchone()
+
+        return {"hosts": hosts, "first_seen": first_seen}
+    except Exception as e:
+        raise HTTPException(status_code=500, detail="Internal server error")
+    finally:
+        conn.close()
+
+
 class StatusUpdate(BaseModel):
     status: str
+    by: Optional[str] = Field(default=None, max_length=128)
+    note: Optional[str] = Field(default=None, max_length=1024)
 
     @field_validator('status')
     @classmethod
@@ -223,14 +321,35 @@ async def update_signal_status(signal_id: str, update: StatusUpdate):
     """Update status of a signal (open, acknowledged, resolved)."""
     conn = sqlite3.connect(DB_PATH, timeout=5.0)
     try:
-        cursor = conn.execute(
+        row = conn.execute(
+            "SELECT host_id, rule_id, status FROM signals WHERE signal_id = ?",
+            (signal_id,),
+        ).fetchone()
+        if row is None:
+            raise HTTPException(status_code=404, detail="Signal not found")
+        host_id, rule_id, previous = row
+        conn.execute(
             "UPDATE signals SET status = ? WHERE signal_id = ?",
             (update.status, signal_id),
         )
+        if update.status != previous:
+            # Recorded for the agent that produced the signal (GET /signals/triage)
+            conn.execute(
+                """
+                INSERT INTO signal_triage (signal_id, host_id, rule_id, status, changed_by, note, ts)
+                VALUES (?, ?, ?, ?, ?, ?, ?)
+                """,
+                (
+                    signal_id,
+                    host_id,
+                    rule_id,
+                    update.status,
+                    update.by,
+                    update.note,
+                    datetime.utcnow().isoformat() + "Z",
+                ),
+            )
         conn.commit()
-        updated_rows = cursor.rowcount
-        if updated_rows == 0:
-            raise HTTPException(status_code=404, detail="Signal not found")
         return {"signal_id": signal_id, "status": update.status}
     except HTTPException:
         raise
@@ -240,6 +359,54 @@ async def update_signal_status(signal_id: str, update: StatusUpdate):
         conn.close()
 
 
+@app.get("/signals/triage")
+async def signal_triage(
+    agent_id: str = Query(..., max_length=256),
+    cursor: int = Query(0, ge=0, description="Last update ID returned"),
+    x_api_key: str = Header(None, alias="X-API-Key")
+):
+    """
+    Return the status changes of an agent's signals after cursor, oldest first
+
+    Agents with shipper.triage enabled poll this to stop emitting dedup
+    summaries and rollups for closed signals. Authentication via X-API-Key
+    header.
+    """
+    if not x_api_key or not secrets.compare_digest(x_api_key, API_KEY):
+        raise HTTPException(status_code=401, detail="Invalid API key")
+
+    conn = sqlite3.connect(DB_PATH, timeout=5.0)
+    try:
+        rows = conn.execute(
+            """
+            SELECT id, signal_id, rule_id, status, changed_by, note, ts
+            FROM signal_triage
+            WHERE host_id = ? AND id > ?
+            ORDER BY id
+            LIMIT ?
+            """,
+            (agent_id, cursor, TRIAGE_PAGE_SIZE + 1),
+        ).fetchall()
+    finally:
+        conn.close()
+
+    more = len(rows) > TRIAGE_PAGE_SIZE
+    rows = rows[:TRIAGE_PAGE_SIZE]
+    updates = [
+        {
+            "signal_id": signal_id,
+            "rule_id": rule_id,
+            "status": AGENT_STATUSES.get(status, status),
+            "ts": ts,
+            "by": changed_by or "",
+            "note": note or "",
+        }
+        for _, signal_id, rule_id, status, changed_by, note, ts in rows
+    ]
+    next_cursor = rows[-1][0] if rows else cursor
+    return {"updates": updates, "cursor": str(next_cursor), "more": more}
+
+
 @app.post("/ingest")
 async def ingest(
     signal: Signal,
@@ -474,6 +641,63 @@ async def list_agents(
     return {"count": len(heartbeats), "heartbeats": heartbeats}
 
 
+class BaselineSeed(BaseModel):
+    version: int
+    agent_id: Optional[str] = Field(None, max_length=255)
+    exported_at: str = Field(..., max_length=64)
+    patterns: List[dict]
+
+
+@app.put("/fleet/baseline-seed")
+async def put_baseline_seed(
+    seed: BaselineSeed,
+    x_api_key: str = Header(None, alias="X-API-Key")
+):
+    """
+    Store the baseline seed exported from a golden host, replacing any
+    previous seed
+
+    Authentication via X-API-Key header
+    """
+    if not x_api_key or not secrets.compare_digest(x_api_key, API_KEY):
+        raise HTTPException(status_code=401, detail="Invalid API key")
+
+    conn = sqlite3.connect(DB_PATH, timeout=5.0)
+    try:
+        conn.execute(
+            "INSERT OR REPLACE INTO baseline_seed VALUES (1, ?, ?)",
+            (json.dumps(seed.model_dump()), datetime.utcnow().isoformat()),
+        )
+        conn.commit()
+        return {"status": "ok", "patterns": len(seed.patterns)}
+    except Exception as e:
+        raise HTTPException(status_code=500, detail="Internal server error")
+    finally:
+        conn.close()
+
+
+@app.get("/fleet/baseline-seed")
+async def get_baseline_seed(
+    x_api_key: str = Header(None, alias="X-API-Key")
+):
+    """
+    Return the stored baseline seed for agents with seeding enabled
+
+    Authentication via X-API-Key header
+    """
+    if not x_api_key or not secrets.compare_digest(x_api_key, API_KEY):
+        raise HTTPException(status_code=401, detail="Invalid API key")
+
+    conn = sqlite3.connect(DB_PATH, timeout=5.0)
+    try:
+        row = conn.execute("SELECT seed FROM baseline_seed WHERE id = 1").fetchone()
+    finally:
+        conn.close()
+    if row is None:
+        raise HTTPException(status_code=404, detail="No baseline seed uploaded")
+    return json.loads(row[0])
+
+
 @app.get("/")
 async def root():
     """Root endpoint with API information"""
@@ -484,8 +708,12 @@ async def root():
             "POST /ingest": "Receive signals from agents",
             "GET /signals": "List and filter signals",
             "PATCH /signals/{id}/status": "Update signal status",
+            "GET /signals/triage": "Status changes of an agent's signals",
             "POST /agents/heartbeat": "Receive agent heartbeat",
             "GET /agents": "List agents with latest heartbeats",
+            "POST /fleet/first-seen": "Record and count fleet-wide baseline sightings",
+            "PUT /fleet/baseline-seed": "Upload the fleet baseline seed",
+            "GET /fleet/baseline-seed": "Fetch the fleet baseline seed",
             "GET /stats": "Get statistics",
             "GET /health": "Health check",
             "GET /ui": "Web UI (if static/ directory exists)"
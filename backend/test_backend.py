@@ -44,3 +44,100 @@ def test_ingest_duplicate_flag(tmp_path):
         second_response = client.post("/ingest", json=payload, headers=headers)
         assert second_response.status_code == 200
         assert second_response.json()["duplicate"] is True
+
+
+def test_fleet_first_seen_counts_agents(tmp_path):
+    backend_module = _create_test_client(tmp_path)
+
+    headers = {"X-API-Key": "test-api-key"}
+    query = {"rule_id": "BL-001", "pattern": "/usr/local/bin/tool"}
+
+    with TestClient(backend_module.app) as client:
+        response = client.post("/fleet/first-seen", json={**query, "agent_id": "host-1"}, headers=headers)
+        assert response.status_code == 200
+        assert response.json()["hosts"] == 1
+        first_seen = response.json()["first_seen"]
+
+        # Repeat reports from the same agent are not counted twice
+        client.post("/fleet/first-seen", json={**query, "agent_id": "host-1"}, headers=headers)
+        response = client.post("/fleet/first-seen", json={**query, "agent_id": "host-2"}, headers=headers)
+        assert response.json() == {"hosts": 2, "first_seen": first_seen}
+
+        response = client.post("/fleet/first-seen", json={**query, "agent_id": "host-3"})
+        assert response.status_code == 401
+
+
+def test_baseline_seed_round_trip(tmp_path):
+    backend_module = _create_test_client(tmp_path)
+
+    headers = {"X-API-Key": "test-api-key"}
+    seed = {
+        "version": 1,
+        "agent_id": "golden-host",
+        "exported_at": "2025-01-15T10:30:00Z",
+        "patterns": [
+            {
+                "rule_id": "BL-001",
+                "pattern": "/usr/local/bin/tool",
+                "first_seen": "2025-01-01T00:00:00Z",
+                "last_seen": "2025-01-15T00:00:00Z",
+                "count": 4,
+            }
+        ],
+    }
+
+    with TestClient(backend_module.app) as client:
+        response = client.get("/fleet/baseline-seed", headers=headers)
+        assert response.status_code == 404
+
+        response = client.put("/fleet/baseline-seed", json=seed)
+        assert response.status_code == 401
+
+        response = client.put("/fleet/baseline-seed", json=seed, headers=headers)
+        assert response.status_code == 200
+        assert response.json()["patterns"] == 1
+
+        response = client.get("/fleet/baseline-seed", headers=headers)
+        assert response.status_code == 200
+        assert response.json() == seed
+
+
+def test_signal_triage_feed(tmp_path):
+    backend_module = _create_test_client(tmp_path)
+
+    headers = {"X-API-Key": "test-api-key"}
+    signal = {
+        "signal_id": "signal-456",
+        "ts": "2024-01-01T00:00:00Z",
+        "host_id": "host-1",
+        "rule_id": "rule-1",
+        "severity": "high",
+        "title": "Test signal",
+    }
+
+    with TestClient(backend_module.app) as client:
+        client.post("/ingest", json=signal, headers=headers)
+        client.patch("/signals/signal-456/status", json={"status": "acknowledged", "by": "alice"})
+        client.patch("/signals/signal-456/status", json={"status": "acknowledged"})
+        client.patch("/signals/signal-456/status", json={"status": "resolved", "note": "benign"})
+
+        response = client.get("/signals/triage", params={"agent_id": "host-1"})
+        assert response.status_code == 401
+
+        response = client.get("/signals/triage", params={"agent_id": "host-1"}, headers=headers)
+        assert response.status_code == 200
+        page = response.json()
+        # Repeated statuses are not recorded, and resolved is reported as closed
+        assert [(u["status"], u["by"], u["note"]) for u in page["updates"]] == [
+            ("acknowledged", "alice", ""),
+            ("closed", "", "benign"),
+        ]
+        assert page["more"] is False
+
+        response = client.get(
+            "/signals/triage", params={"agent_id": "host-1", "cursor": page["cursor"]}, headers=headers
+        )
+        assert response.json() == {"updates": [], "cursor": page["cursor"], "more": False}
+
+        response = client.get("/signals/triage", params={"agent_id": "host-2"}, headers=headers)
+        assert response.json()["updates"] == []
@@ -3,27 +3,62 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/admin"
+	"github.com/0x4d31/santamon/internal/aggregate"
+	"github.com/0x4d31/santamon/internal/allowlist"
 	"github.com/0x4d31/santamon/internal/baseline"
+	"github.com/0x4d31/santamon/internal/bench"
 	"github.com/0x4d31/santamon/internal/config"
 	"github.com/0x4d31/santamon/internal/correlation"
+	"github.com/0x4d31/santamon/internal/dedup"
 	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/health"
+	"github.com/0x4d31/santamon/internal/identity"
+	"github.com/0x4d31/santamon/internal/ingest"
+	"github.com/0x4d31/santamon/internal/intel"
+	"github.com/0x4d31/santamon/internal/inventory"
 	"github.com/0x4d31/santamon/internal/lineage"
 	"github.com/0x4d31/santamon/internal/logutil"
+	"github.com/0x4d31/santamon/internal/prefilter"
+	"github.com/0x4d31/santamon/internal/recorder"
+	"github.com/0x4d31/santamon/internal/reputation"
+	"github.com/0x4d31/santamon/internal/response"
+	"github.com/0x4d31/santamon/internal/rulepack"
 	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/rulesync"
+	"github.com/0x4d31/santamon/internal/ruletest"
+	"github.com/0x4d31/santamon/internal/santalog"
+	"github.com/0x4d31/santamon/internal/santarule"
+	"github.com/0x4d31/santamon/internal/search"
+	"github.com/0x4d31/santamon/internal/session"
+	"github.com/0x4d31/santamon/internal/shadow"
+	"github.com/0x4d31/santamon/internal/shedding"
 	"github.com/0x4d31/santamon/internal/shipper"
 	"github.com/0x4d31/santamon/internal/signals"
+	"github.com/0x4d31/santamon/internal/simulate"
 	"github.com/0x4d31/santamon/internal/spool"
 	"github.com/0x4d31/santamon/internal/state"
+	"github.com/0x4d31/santamon/internal/tracing"
+	"github.com/0x4d31/santamon/internal/tune"
+	"github.com/0x4d31/santamon/internal/yara"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -51,6 +86,28 @@ func main() {
 		dbCommand()
 	case "rules":
 		rulesCommand()
+	case "tune":
+		tuneCommand()
+	case "shipper":
+		shipperCommand()
+	case "tail":
+		tailCommand()
+	case "baseline":
+		baselineCommand()
+	case "replay":
+		replayCommand()
+	case "search":
+		searchCommand()
+	case "bench":
+		benchCommand()
+	case "pack":
+		packCommand()
+	case "validate":
+		validateCommand()
+	case "schema":
+		schemaCommand()
+	case "gen-events":
+		genEventsCommand()
 	case "version":
 		fmt.Printf("santamon version %s\n", version)
 		fmt.Printf("commit: %s\n", commit)
@@ -70,15 +127,118 @@ func printUsage() {
 Usage:
   santamon run [options]            Run the agent
   santamon status [--config PATH]   Show agent status
-  santamon db <stats|compact> [--config PATH]
-                                    Database operations
+  santamon db <stats|first-seen|windows|queue|triage|compact> [options]
+                                    Inspect or compact the state database (agent stopped)
   santamon rules validate           Validate rules configuration
+  santamon validate [options]       Check config, CEL expressions, rule field paths and lint rules (exit 1 on problems)
+  santamon rules test [options]     Run rules against fixture events (--events DIR, --tests FILE)
+  santamon rules stats [options]    Show how often each of the running agent's rules has fired, across restarts
+  santamon rules shadow [options]   Compare the running agent's shadow rules (rules.shadow_path) with the active rules
+  santamon tune [options]           Suggest rule exceptions from local signal history
+  santamon shipper queue [options]  Inspect or manage the running agent's shipping queue
+  santamon tail [options]           Stream the running agent's signals as they are emitted
+  santamon baseline export [options]
+                                    Write the learned baseline patterns as a seed for other hosts
+  santamon baseline import [options] FILE
+                                    Merge a baseline seed into the state database (agent stopped)
+  santamon replay [options] [PATH...]
+                                    Run archived spool files through the pipeline
+  santamon search [options] EXPR [PATH...]
+                                    Print archived events matching a CEL expression
+  santamon bench [options] [PATH...]
+                                    Profile rule evaluation cost over archived spool files
+  santamon pack <add|update|remove|list|verify> [options] [NAME] [SOURCE]
+                                    Install and update rule packs in the rules directory
+  santamon schema [options]         Print the JSON Schema of the signals this build and config produce
+  santamon gen-events [options]     Write synthetic Santa spool files for demos, load tests and rule development
   santamon version                  Show version
   santamon help                     Show this help
 
 Run Options:
   --config PATH                     Configuration file path (default: /etc/santamon/config.yaml)
   --verbose                         Verbose mode (show additional details and timestamps)
+  --output FORMAT                   console (default) or ndjson: one JSON signal per line on stdout
+
+Validate Options:
+  --config PATH                     Configuration file path (default: /etc/santamon/config.yaml)
+  --rules PATH                      Rules file or directory; without --config only the rules are checked
+  --skip-shipper                    Skip shipper checks (e.g. when SANTAMON_API_KEY is not set)
+  --no-lint                         Skip static analysis of rule expressions
+
+Tune Options:
+  --since DURATION                  History window to analyze (default: state.history.retention)
+  --rule ID                         Only analyze one rule
+  --min-signals N                   Skip rules with fewer signals (default: 10)
+  --min-share F                     Share of signals a pattern must cover (default: 0.8)
+
+Rules Stats Options:
+  --json                            Print the fire history as JSON
+
+Rules Shadow Options:
+  --all                             Also list rules both rule sets agree on
+  --json                            Print the report as JSON, including shadow-only sample signals
+
+Shipper Queue Options:
+  --list                            List queued signals (default)
+  --limit N                         Maximum signals to list (default: 50, 0 = all)
+  --flush                           Ship queued signals now, ignoring the circuit breaker
+  --drop ID                         Remove a queued signal (e.g. one the backend always rejects)
+
+Tail Options:
+  --json                            Print one JSON signal per line, as with run --output ndjson
+  --severity LEVEL                  Only signals at least this severe (low, medium, high, critical)
+  --rule IDS                        Comma-separated rule IDs
+  --kind KINDS                      Comma-separated signal kinds: rule, correlation, baseline, absence,
+                                    health, dedup, aggregate, follow_up
+
+DB Options:
+  --config PATH                     Configuration file path (default: /etc/santamon/config.yaml)
+  --json                            Print JSON instead of a table (stats is always JSON)
+  --rule ID                         first-seen, windows, triage: only this rule's entries
+  --match TEXT                      first-seen: only patterns containing TEXT
+  --limit N                         first-seen, queue, triage: maximum entries to list (default: 50, 0 = all)
+  --events                          windows: include the stored events (implies --json)
+
+Baseline Options:
+  --config PATH                     Configuration file path (default: /etc/santamon/config.yaml)
+  --out FILE                        Export: write the seed to FILE instead of stdout
+  --rule ID                         Export: only this baseline rule's patterns
+
+Search Options:
+  --since TIME                      Only events at or after TIME: a duration back from now (24h),
+                                    an RFC 3339 timestamp or a date (2006-01-02)
+  --until TIME                      Only events before TIME (same formats)
+  --limit N                         Stop after N matching events (default: 100, 0 = all)
+  --json                            Print one JSON event per line instead of a table
+  PATH                              Spool files or directories (default: santa.archive_dir)
+
+Bench Options:
+  --rules PATH                      Rules file or directory (default: rules.path from config)
+  --passes N                        Times to evaluate the corpus (default: 1)
+  --top N                           Slowest rules to list (default: 20, 0 = all)
+  --max-events N                    Maximum events to load (default: 100000, 0 = all)
+  --json                            Print the report as JSON
+  PATH                              Spool files or directories (default: santa.archive_dir)
+
+Pack Options:
+  --rules DIR                       Rules directory (default: rules.path from config)
+  --ref REF                         add, update: git tag, branch or commit, or version for {ref} in an HTTP URL
+  --path DIR                        add: subdirectory of the source holding the rules
+  --sha256 HEX                      add, update: expected SHA-256 of the HTTP download
+  SOURCE                            git+URL or URL.git for git; an http(s) .tar.gz or .yaml URL otherwise
+
+Schema Options:
+  --config PATH                     Configuration file path; enables identity, archive and dedup fields it configures
+  --rules PATH                      Rules file or directory; without --config only the rules are used
+
+Gen-events Options:
+  --out DIR                         Spool directory to write to, e.g. /var/db/santa/spool/new (required)
+  --kind KINDS                      Comma-separated event kinds, or all (default: all)
+  --count N                         Number of events (default: 100)
+  --deny-rate F                     Share of executions and file accesses denied (default: 0.1)
+  --per-file N                      Events per spool file (default: 100)
+  --interval DURATION               Time between event timestamps (default: 100ms)
+  --seed N                          Random seed for reproducible output (default: random)
 
 Environment Variables:
   SANTAMON_API_KEY                  API key for backend authentication`)
@@ -191,8 +351,23 @@ func runCommand() {
 	fs := flag.NewFlagSet("run", flag.ExitOnError)
 	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
 	verbose := fs.Bool("verbose", false, "Verbose mode (show additional details and timestamps)")
+	output := fs.String("output", "console", "Signal output: console or ndjson (one JSON signal per line on stdout)")
 	_ = fs.Parse(os.Args[2:])
 
+	// In ndjson mode stdout carries only signals; the banner is dropped and
+	// logs stay on stderr
+	console := io.Writer(os.Stdout)
+	var ndjson *signals.NDJSONWriter
+	switch *output {
+	case "console":
+	case "ndjson":
+		console = io.Discard
+		ndjson = signals.NewNDJSONWriter(os.Stdout)
+	default:
+		logutil.Error("Invalid --output %q: must be console or ndjson", *output)
+		os.Exit(1)
+	}
+
 	// Set verbosity level and timestamps
 	if *verbose {
 		logutil.SetVerbosity(logutil.VerboseLevel)
@@ -206,55 +381,208 @@ func runCommand() {
 		os.Exit(1)
 	}
 
+	// Configure logging
+	logOpts := logutil.Options{
+		Format:     cfg.Agent.LogFormat,
+		Level:      effectiveLogLevel(cfg, *verbose),
+		Components: cfg.Agent.LogLevels,
+	}
+	if cfg.Agent.LogFile != "" {
+		rot := cfg.Agent.LogRotation
+		logFile, err := logutil.OpenRotatingFile(cfg.Agent.LogFile, int64(rot.MaxSizeMB)*1024*1024, rot.MaxBackups, rot.MaxAge)
+		if err != nil {
+			logutil.Error("Failed to open log file: %v", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+		logOpts.Output = logFile
+		logOpts.NoColor = true
+	}
+	if err := logutil.Configure(logOpts); err != nil {
+		logutil.Error("Failed to configure logging: %v", err)
+		os.Exit(1)
+	}
+
 	// Startup banner (no timestamps even in verbose mode)
-	fmt.Println()
-	fmt.Println("                   _                           ")
-	fmt.Println("  ___ _____ ____ _| |_ _____ ____   ___  ____  ")
-	fmt.Println(" /___|____ |  _ (_   _|____ |    \\ / _ \\|  _ \\ ")
-	fmt.Println("|___ / ___ | | | || |_/ ___ | | | | |_| | | | |")
-	fmt.Println("(___/\\_____|_| |_| \\__)_____|_|_|_|\\___/|_| |_|")
-	fmt.Println("                                               ")
-	fmt.Printf("  %s - Lightweight macOS Detection Agent\n", version)
-	fmt.Printf("  commit: %s, built: %s\n\n", commit, date)
-	fmt.Printf("\033[92m✓\033[0m Loaded configuration from %s\n", *configPath)
-	fmt.Printf("\033[92m✓\033[0m Agent ID: %s\n", cfg.Agent.ID)
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, "                   _                           ")
+	fmt.Fprintln(console, "  ___ _____ ____ _| |_ _____ ____   ___  ____  ")
+	fmt.Fprintln(console, " /___|____ |  _ (_   _|____ |    \\ / _ \\|  _ \\ ")
+	fmt.Fprintln(console, "|___ / ___ | | | || |_/ ___ | | | | |_| | | | |")
+	fmt.Fprintln(console, "(___/\\_____|_| |_| \\__)_____|_|_|_|\\___/|_| |_|")
+	fmt.Fprintln(console, "                                               ")
+	fmt.Fprintf(console, "  %s - Lightweight macOS Detection Agent\n", version)
+	fmt.Fprintf(console, "  commit: %s, built: %s\n\n", commit, date)
+	fmt.Fprintf(console, "\033[92m✓\033[0m Loaded configuration from %s\n", *configPath)
+	fmt.Fprintf(console, "\033[92m✓\033[0m Agent ID: %s\n", cfg.Agent.ID)
 
 	// Open state database
-	db, err := state.Open(cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
+	db, err := state.OpenStore(cfg.State.Backend, cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
 	if err != nil {
 		logutil.Error("Failed to open database: %v", err)
 		os.Exit(1)
 	}
 	defer func() { _ = db.Close() }()
 
+	// Compact before other goroutines use the database, when correlation
+	// state outgrew its threshold during the last run
+	if compacted, err := db.CompactIfRequested(); err != nil {
+		logutil.Error("Failed to compact database: %v", err)
+		os.Exit(1)
+	} else if compacted {
+		logutil.Info("Compacted state database")
+	}
+
 	// Store agent metadata
 	if err := db.SetMeta("agent_id", cfg.Agent.ID); err != nil {
-		log.Printf("Warning: Failed to store agent_id metadata: %v", err)
+		logutil.Warn("Failed to store agent_id metadata: %v", err)
 	}
 	if err := db.SetMeta("version", version); err != nil {
-		log.Printf("Warning: Failed to store version metadata: %v", err)
+		logutil.Warn("Failed to store version metadata: %v", err)
 	}
 
-	// Load detection rules (supports both file and directory)
-	rulesConfig, err := rules.Load(cfg.Rules.Path)
+	budget := ruleBudget(cfg)
+	host := ruleHost(cfg)
+	overrides := ruleOverrides(cfg)
+
+	// Load the suppressions file, when configured. It is merged into the
+	// rules whenever they are compiled.
+	suppressions, err := loadSuppressions(cfg)
 	if err != nil {
-		logutil.Error("Failed to load rules: %v", err)
+		logutil.Error("Failed to load suppressions: %v", err)
 		os.Exit(1)
 	}
-	fmt.Printf("\033[92m✓\033[0m Detection rules: %d simple, %d correlation, %d baseline\n",
-		len(rulesConfig.Rules), len(rulesConfig.Correlations), len(rulesConfig.Baselines))
+	if suppressions != nil {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Suppressions: %d entries from %s\n", suppressions.Len(), cfg.Rules.Suppressions)
+	}
 
-	// Create rules engine
-	engine, err := rules.NewEngine()
+	// Load threat intel feeds, when configured. Rules can still run if a feed
+	// fails; it is retried on every reload.
+	intelStore := loadIntel(cfg)
+	if intelStore != nil {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Intel: %d indicators from %d feeds\n", intelStore.Len(), len(cfg.Intel.Feeds))
+	}
+
+	// Create remote rules fetcher, when configured. Bundles are only cached
+	// and delivered once they verify and compile.
+	var fetcher *rulesync.Fetcher
+	if cfg.Rules.Remote.URL != "" {
+		fetcher, err = rulesync.NewFetcher(cfg.Rules.Remote, func(bundle []byte) error {
+			rc, err := rules.Parse(bundle)
+			if err != nil {
+				return err
+			}
+			_, err = compileRules(rc, budget, nil, nil, nil)
+			return err
+		})
+		if err != nil {
+			logutil.Error("Failed to create remote rules fetcher: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load detection rules: the last verified remote bundle, if any, else the
+	// local rules path (supports both file and directory)
+	var rulesConfig *rules.RulesConfig
+	var engine *rules.Engine
+	rulesSource := cfg.Rules.Path
+	if fetcher != nil {
+		if rulesConfig, engine = loadCachedRules(fetcher, budget, host, overrides, suppressions); engine != nil {
+			rulesSource = cfg.Rules.Remote.URL + " (cached)"
+		}
+	}
+	if engine == nil {
+		if rulesConfig, err = rules.Load(cfg.Rules.Path); err != nil {
+			logutil.Error("Failed to load rules: %v", err)
+			os.Exit(1)
+		}
+		if engine, err = compileRules(rulesConfig, budget, host, overrides, suppressions); err != nil {
+			logutil.Error("Failed to load rules engine: %v", err)
+			os.Exit(1)
+		}
+	}
+	engine.SetIntel(intelStore)
+	fmt.Fprintf(console, "\033[92m✓\033[0m Detection rules: %d simple, %d correlation, %d baseline from %s (version %s)\n",
+		len(rulesConfig.Rules), len(rulesConfig.Correlations), len(rulesConfig.Baselines), rulesSource, engine.Version())
+	if err := db.SetMeta("rules_version", engine.Version()); err != nil {
+		logutil.Warn("Failed to store rules_version metadata: %v", err)
+	}
+	warnContextGaps(engine, rulesConfig)
+
+	// Load the shadow rules, when configured. They see the same events as the
+	// active rules, but their matches are only compared, never shipped.
+	shadowEngine, err := loadShadowRules(cfg, budget, host, overrides, suppressions)
 	if err != nil {
-		logutil.Error("Failed to create rules engine: %v", err)
+		logutil.Error("Failed to load shadow rules: %v", err)
 		os.Exit(1)
 	}
+	var shadowRunner *shadow.Runner
+	if shadowEngine != nil {
+		shadowEngine.SetIntel(intelStore)
+		shadowRunner = shadow.New(cfg.Agent.ID, shadowEngine, engine.Version())
+		fmt.Fprintf(console, "\033[92m✓\033[0m Shadow rules: %s (version %s), compared against version %s\n",
+			cfg.Rules.ShadowPath, shadowEngine.Version(), engine.Version())
+	}
 
-	if err := engine.LoadRules(rulesConfig); err != nil {
-		logutil.Error("Failed to compile rules: %v", err)
+	// Load the global allowlist, when configured
+	allow, err := loadAllowlist(cfg)
+	if err != nil {
+		logutil.Error("Failed to load allowlist: %v", err)
 		os.Exit(1)
 	}
+	if allow != nil {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Allowlist: %d entries from %s (scope: %s)\n",
+			allow.Len(), cfg.Rules.Allowlist.Path, cfg.Rules.Allowlist.Scope)
+	}
+
+	// Create event sampling recorder, when enabled
+	var rec *recorder.Recorder
+	if cfg.Recorder.Enabled {
+		recOpts := recorder.Options{
+			Dir:          cfg.Recorder.Dir,
+			SampleRate:   cfg.Recorder.SampleRate,
+			RedactFields: cfg.Recorder.RedactFields,
+			MaxBytes:     cfg.Recorder.MaxBytes,
+		}
+		if cfg.Recorder.Filter != "" {
+			filter, err := engine.CompileFilter(cfg.Recorder.Filter)
+			if err != nil {
+				logutil.Error("Failed to compile recorder filter: %v", err)
+				os.Exit(1)
+			}
+			recOpts.Filter = func(msg *santapb.SantaMessage) bool {
+				ok, err := filter.Match(msg)
+				return err == nil && ok
+			}
+		}
+		rec, err = recorder.New(recOpts)
+		if err != nil {
+			logutil.Error("Failed to create event recorder: %v", err)
+			os.Exit(1)
+		}
+		defer func() { _ = rec.Close() }()
+		fmt.Fprintf(console, "\033[92m✓\033[0m Recording %.2f%% of events to %s\n", cfg.Recorder.SampleRate*100, cfg.Recorder.Dir)
+	}
+
+	// Create the event prefilter, when configured
+	var eventFilter *prefilter.Prefilter
+	if cfg.Prefilter.Filter != "" || len(cfg.Prefilter.Sample) > 0 {
+		var match func(*santapb.SantaMessage) (bool, error)
+		if cfg.Prefilter.Filter != "" {
+			filter, err := engine.CompileFilter(cfg.Prefilter.Filter)
+			if err != nil {
+				logutil.Error("Failed to compile prefilter: %v", err)
+				os.Exit(1)
+			}
+			match = filter.Match
+		}
+		eventFilter, err = prefilter.New(cfg.Prefilter, match)
+		if err != nil {
+			logutil.Error("Failed to create prefilter: %v", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(console, "\033[92m✓\033[0m Prefilter enabled (%d sampled kinds)\n", len(cfg.Prefilter.Sample))
+	}
 
 	// Create correlation window manager
 	windowMgr := correlation.NewWindowManager(
@@ -262,32 +590,165 @@ func runCommand() {
 		cfg.State.Windows.MaxEvents,
 		cfg.State.Windows.GCInterval,
 	)
+	windowMgr.SetCaps(cfg.State.Windows.MaxRuleEvents, cfg.State.Windows.MaxTotalEvents)
+	windowMgr.CompactAbove(int64(cfg.State.Windows.CompactThresholdMB) * 1024 * 1024)
+	if cfg.State.Windows.CrossHost {
+		windowMgr.CrossHost()
+	}
+	forgetCorrelations(windowMgr, engine)
+	restoreLearning(db, engine)
 
 	// Create baseline processor
 	baselineProc := baseline.NewProcessor(db)
 
-	// Create lineage store only if any enabled rule requests process trees
+	// Create lineage store only if enabled rules request process trees or
+	// group correlations by lineage
 	var lineageStore *lineage.Store
-	for _, r := range rulesConfig.Rules {
-		if r.Enabled && r.IncludeProcessTree {
-			lineageStore = lineage.NewStore(lineage.Config{})
-			break
+	if rulesConfig.NeedsLineage() {
+		lineageStore = newLineageStore(cfg, db)
+	}
+	windowMgr.SetLineage(lineageStore)
+	engine.SetAncestry(lineageStore)
+	if shadowEngine != nil {
+		shadowEngine.SetAncestry(lineageStore)
+	}
+
+	// Create directory identity provider, when configured
+	idProvider, err := newIdentityProvider(cfg)
+	if err != nil {
+		logutil.Error("Failed to create identity provider: %v", err)
+		os.Exit(1)
+	}
+
+	// Create hash reputation client, when configured
+	repClient := newReputation(cfg)
+	if repClient != nil {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Reputation: %s (%d lookups/min)\n", cfg.Reputation.Provider, cfg.Reputation.RateLimit)
+	}
+
+	// Create YARA scanner for the executables of severe signals, when enabled
+	yaraScanner, err := newYARA(cfg)
+	if err != nil {
+		logutil.Error("Failed to create YARA scanner: %v", err)
+		os.Exit(1)
+	}
+	if yaraScanner != nil {
+		fmt.Fprintf(console, "\033[92m✓\033[0m YARA: %s (%s and above, %d at a time)\n", cfg.YARA.Rules, cfg.YARA.MinSeverity, cfg.YARA.MaxConcurrent)
+	}
+
+	// Suggest Santa rules for signals about unsigned or newly seen binaries
+	santaRules, err := santarule.NewSuggester(cfg.SantaRules)
+	if err != nil {
+		logutil.Error("Failed to open Santa rules file: %v", err)
+		os.Exit(1)
+	}
+	if santaRules != nil && cfg.SantaRules.File != "" {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Santa rule suggestions: %s\n", cfg.SantaRules.File)
+	}
+
+	// Run the response actions rules declare, from the configured catalog
+	responder, err := response.New(cfg.Response)
+	if err != nil {
+		logutil.Error("Failed to create responder: %v", err)
+		os.Exit(1)
+	}
+	if responder != nil {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Response actions: %d (audit log %s)\n", len(cfg.Response.Actions), cfg.Response.AuditLog)
+		if sync := cfg.Response.SyncServer; sync.Enabled {
+			mode := ""
+			if sync.DryRun {
+				mode = " (dry run)"
+			}
+			fmt.Fprintf(console, "\033[92m✓\033[0m Sync server block rules: %s%s\n", sync.URL, mode)
 		}
 	}
+	setResponseRules(responder, rulesConfig)
+
+	// Collect the machine inventory signals and heartbeats are attributed with
+	inv := inventory.NewCollector()
+	if machine := inv.Collect(context.Background()); machine.OSVersion != "" {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Inventory: macOS %s, %s, Santa %s (%s)\n",
+			machine.OSVersion, machine.Model, machine.SantaVersion, machine.SantaMode)
+	}
+
+	// Track the console user, starting from the one logged in now
+	sessions := session.NewTracker()
+	sessions.Seed(session.ConsoleOwner())
 
 	// Create signal generator
 	sigGen := signals.NewGenerator(cfg.Agent.ID, lineageStore)
+	sigGen.SetIdentityProvider(idProvider)
+	sigGen.SetIntel(intelStore)
+	sigGen.SetInventory(inv)
+	sigGen.SetSessions(sessions)
+	sigGen.SetRulesVersion(engine.Version())
 
-	// Create spool watcher
-	watcher, err := spool.NewWatcherWithOptions(cfg.Santa.SpoolDir, cfg.Santa.StabilityWait, spool.WatcherOptions{ArchiveDir: cfg.Santa.ArchiveDir})
-	if err != nil {
-		logutil.Error("Failed to create watcher: %v", err)
-		os.Exit(1)
+	// Create a spool watcher for each source
+	var sources []spool.Source
+	for _, src := range cfg.Santa.SpoolDir {
+		watcherOpts := spool.WatcherOptions{
+			ArchiveDir:       cfg.Santa.ArchiveDir,
+			ClaimDir:         cfg.Santa.SourceClaimDir(src),
+			ArchiveCompress:  cfg.Santa.Archive.Compress,
+			ArchivePartition: cfg.Santa.Archive.Partition,
+			ArchiveMaxAge:    cfg.Santa.Archive.MaxAge,
+			ArchiveMaxBytes:  cfg.Santa.Archive.MaxSizeMB * 1024 * 1024,
+		}
+		w, err := spool.NewWatcherWithOptions(src.Path, src.StabilityWait, watcherOpts)
+		if err != nil {
+			logutil.Error("Failed to create watcher for %s: %v", src.Path, err)
+			os.Exit(1)
+		}
+		sources = append(sources, spool.Source{Label: src.Label, Watcher: w})
+		if src.Label != "" {
+			fmt.Fprintf(console, "\033[92m✓\033[0m Spool %s: %s (%s)\n", src.Label, src.Path, src.Mode)
+		}
 	}
+	watcher := spool.NewGroup(sources...)
 	defer func() { _ = watcher.Close() }()
 
+	// Convert Santa's text event log into the santa_log spool, when enabled
+	var eventLog *santalog.Tailer
+	if cfg.Santa.EventLog.Enabled {
+		eventLog = santalog.NewTailer(santalog.Options{
+			Source:        cfg.Santa.EventLog.Source,
+			Path:          cfg.Santa.EventLog.Path,
+			Predicate:     cfg.Santa.EventLog.Predicate,
+			SpoolDir:      cfg.Santa.EventLog.SpoolDir,
+			BatchInterval: cfg.Santa.EventLog.BatchInterval,
+			BatchSize:     cfg.Santa.EventLog.BatchSize,
+			Offsets:       db,
+		})
+		if cfg.Santa.EventLog.Source == "unified" {
+			fmt.Fprintf(console, "\033[92m✓\033[0m Santa event log: unified log\n")
+		} else {
+			fmt.Fprintf(console, "\033[92m✓\033[0m Santa event log: %s\n", cfg.Santa.EventLog.Path)
+		}
+	}
+
+	// Create tracer, when enabled (a nil tracer records nothing)
+	var tracer *tracing.Tracer
+	if cfg.Tracing.Enabled {
+		tracer = tracing.New(cfg.Tracing,
+			tracing.String("host.name", cfg.Agent.ID),
+			tracing.String("service.version", version))
+		fmt.Fprintf(console, "\033[92m✓\033[0m Tracing %.0f%% of spool files to %s\n", cfg.Tracing.SampleRate*100, cfg.Tracing.Endpoint)
+	}
+
 	// Create shipper
 	ship := shipper.NewShipper(&cfg.Shipper, db, cfg.Agent.ID, version)
+	ship.SetRulesVersion(engine.Version())
+	ship.SetTracer(tracer)
+	ship.SetInventory(inv)
+	sinks, err := newSinks(cfg.Shipper.Sinks)
+	if err != nil {
+		logutil.Error("Failed to configure sinks: %v", err)
+		os.Exit(1)
+	}
+	ship.SetSinks(sinks)
+	for _, sink := range sinks {
+		fmt.Fprintf(console, "\033[92m✓\033[0m Sink %s enabled\n", sink.Name())
+	}
 
 	// Setup context with signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -305,25 +766,242 @@ func runCommand() {
 		return ship.Start(gctx)
 	})
 
+	// Start priority shipping lane in errgroup
+	g.Go(func() error {
+		return ship.StartPriorityLane(gctx)
+	})
+
 	// Start heartbeat in errgroup
 	g.Go(func() error {
 		return ship.StartHeartbeat(gctx)
 	})
 
+	// Pull signal acknowledgements and closures from the backend
+	g.Go(func() error {
+		return ship.StartTriageSync(gctx)
+	})
+
+	// Deliver signal copies to the extra sinks
+	if len(sinks) > 0 {
+		g.Go(func() error {
+			return ship.StartSinks(gctx)
+		})
+	}
+
+	// Run response actions in the background
+	if responder != nil {
+		g.Go(func() error {
+			return responder.Run(gctx)
+		})
+	}
+
+	// Refresh the machine inventory, e.g. after an OS update or Santa mode change
+	// Goroutines get copies of the settings they use: reloads replace cfg
+	inventoryInterval := cfg.Agent.InventoryInterval
+	g.Go(func() error {
+		return inv.Run(gctx, inventoryInterval)
+	})
+
+	// Reload threat intel feeds in the background
+	if intelStore != nil {
+		g.Go(func() error {
+			return intelStore.Run(gctx)
+		})
+	}
+
 	// Start watcher in errgroup
 	g.Go(func() error {
 		return watcher.Start(gctx)
 	})
 
-	// Channel to signal rule reload
+	// Tail Santa's event log into its spool
+	if eventLog != nil {
+		g.Go(func() error {
+			return eventLog.Start(gctx)
+		})
+	}
+
+	// Accept pushed event batches into the ingest spool, when enabled
+	if cfg.Santa.Ingest.Enabled {
+		ingestServer := ingest.NewServer(ingest.Options{
+			Listen:   cfg.Santa.Ingest.Listen,
+			Token:    cfg.Santa.Ingest.Token,
+			SpoolDir: cfg.Santa.Ingest.SpoolDir,
+			MaxBytes: cfg.Santa.Ingest.MaxBatchMB << 20,
+		})
+		fmt.Fprintf(console, "\033[92m✓\033[0m Ingest server: %s\n", cfg.Santa.Ingest.Listen)
+		g.Go(func() error {
+			return ingestServer.Start(gctx)
+		})
+	}
+
+	// Export spans in the background
+	if tracer != nil {
+		g.Go(func() error {
+			return tracer.Start(gctx)
+		})
+	}
+
+	// Shed low-value detection work when spool files back up, when enabled
+	var shedPolicy *shedding.Policy
+	if cfg.LoadShedding.Enabled {
+		shedPolicy = shedding.NewPolicy(cfg.LoadShedding)
+	}
+
+	// Watch spool size and free disk space, when enabled
+	var pressure *spool.PressureMonitor
+	if cfg.Santa.Pressure.Enabled {
+		pressure = spool.NewPressureMonitor(cfg.Santa.Pressure.MaxSpoolMB<<20, uint64(cfg.Santa.Pressure.MinFreeMB)<<20)
+	}
+
+	// Start health endpoint and liveness file, when enabled
+	if cfg.Health.Enabled {
+		checker := health.NewChecker(health.Options{
+			AgentID:      cfg.Agent.ID,
+			Version:      version,
+			Listen:       cfg.Health.Listen,
+			LivenessFile: cfg.Health.LivenessFile,
+			Interval:     cfg.Health.Interval,
+		})
+		checker.Register("spool_watcher", watcher.Health)
+		if eventLog != nil {
+			checker.Register("santa_event_log", eventLog.Health)
+		}
+		checker.Register("state_db", db.Ping)
+		checker.Register("shipper", ship.Health)
+		if fetcher != nil {
+			checker.Register("remote_rules", fetcher.Health)
+		}
+		if intelStore != nil {
+			checker.Register("intel", intelStore.Health)
+		}
+		if shedPolicy != nil {
+			checker.Register("coverage", shedPolicy.Health)
+		}
+		if pressure != nil {
+			checker.Register("spool_pressure", pressure.Health)
+		}
+		g.Go(func() error {
+			return checker.Start(gctx)
+		})
+	}
+
+	// Serve operator commands (santamon shipper queue, santamon tail) on the
+	// admin socket
+	tail := admin.NewStream()
+	adminServer := admin.NewServer(cfg.Agent.AdminSocket, db, ship)
+	adminServer.SetStream(tail)
+	adminServer.SetFireHistory(db)
+	if shadowRunner != nil {
+		adminServer.SetShadow(shadowRunner)
+	}
+	g.Go(func() error {
+		if err := adminServer.Start(gctx); err != nil && !errors.Is(err, context.Canceled) {
+			// The agent keeps running without operator commands
+			logutil.Warn("Admin socket unavailable: %v", err)
+		}
+		return nil
+	})
+
+	// Look up and scan the executables of signals off the detection loop;
+	// flagged ones ship as follow-up signals
+	var followUps *signals.FollowUps
+	if repClient != nil || yaraScanner != nil {
+		followUps = signals.NewFollowUps(followUpQueueSize, func(sig *state.Signal) {
+			enqueueFollowUp(ship, tail, ndjson, sig)
+		})
+		followUps.SetReputation(repClient)
+		followUps.SetYARA(yaraScanner, cfg.YARA.MinSeverity)
+		// One worker per scan slot, so scans are never refused as busy
+		workers := 1
+		if yaraScanner != nil {
+			workers = cfg.YARA.MaxConcurrent
+		}
+		g.Go(func() error {
+			return followUps.Run(gctx, workers)
+		})
+	}
+
+	// Check locally first-seen baseline patterns with the collector off the
+	// detection loop
+	var fleetChecker *shipper.FleetChecker
+	if fleet := cfg.State.FirstSeen.Fleet; fleet.Enabled {
+		fleetChecker = shipper.NewFleetChecker(ship, fleet.Timeout, fleetQueueSize)
+		g.Go(func() error {
+			return fleetChecker.Run(gctx, fleetWorkers)
+		})
+	}
+
+	// Prune local signal history used by santamon tune
+	historyRetention := cfg.State.History.Retention
+	g.Go(func() error {
+		return pruneHistory(gctx, db, historyRetention)
+	})
+
+	// Expired process lineage is swept in the background; the sweeper is
+	// replaced along with the store
+	stopSweep := sweepLineage(gctx, lineageStore)
+
+	// Pre-seed baseline patterns from the collector, once per state database
+	if seed := cfg.State.FirstSeen.Seed; seed.Enabled {
+		g.Go(func() error {
+			return pullBaselineSeed(gctx, ship, db, seed.RetryInterval)
+		})
+	}
+
+	// Collapse repeated signals, shipping a summary as each cooldown ends
+	var deduper *dedup.Deduper
+	if cfg.State.Dedup.Cooldown > 0 {
+		deduper = dedup.New(db, cfg.State.Dedup.Cooldown)
+		g.Go(func() error {
+			return flushLoop(gctx, deduper.Cooldown(), "deduplicated", deduper.Flush, func(sig *state.Signal) {
+				enqueueDedupSummary(ship, tail, sig)
+			})
+		})
+	}
+
+	// Roll up matches of aggregate rules, shipping one signal per interval
+	aggregator := aggregate.New(db, cfg.State.Aggregate.Interval)
+	g.Go(func() error {
+		return flushLoop(gctx, aggregator.Interval(), "aggregated", aggregator.Flush, func(sig *state.Signal) {
+			enqueueRollup(ship, tail, sig)
+		})
+	})
+
+	// Poll for signed rules bundles; new ones arrive on remoteRules
+	var remoteRules <-chan []byte
+	if fetcher != nil {
+		remoteRules = fetcher.Updates()
+		g.Go(func() error {
+			return fetcher.Run(gctx)
+		})
+	}
+
+	// Channel to signal config and rule reload
 	reloadCh := make(chan struct{}, 1)
 
+	// Optionally reload when the config file changes, in addition to SIGHUP
+	if cfg.Agent.ReloadOn == "change" {
+		g.Go(func() error {
+			return watchConfigFile(gctx, *configPath, reloadCh)
+		})
+	}
+
+	// Optionally reload when the rules change; the reload validates and
+	// compiles them before swapping, as with SIGHUP. The watcher is replaced
+	// when a reload changes rules.path.
+	watchedRules := cfg.Rules.Path
+	stopRulesWatch := func() {}
+	if cfg.Rules.ReloadOn == "change" {
+		stopRulesWatch = watchRules(gctx, watchedRules, cfg.Rules.ReloadDebounce, reloadCh)
+	}
+
 	// Handle signals (SIGINT/SIGTERM for shutdown, SIGHUP for reload)
 	go func() {
 		for sig := range sigChan {
 			switch sig {
 			case syscall.SIGHUP:
-				// Trigger rule reload
+				// Trigger config and rule reload
 				select {
 				case reloadCh <- struct{}{}:
 				default:
@@ -338,385 +1016,3515 @@ func runCommand() {
 		}
 	}()
 
-	fmt.Println()
-	fmt.Println("\033[90mℹ\033[0m Watching for security events...")
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, "\033[90mℹ\033[0m Watching for security events...")
 
 	// Main event processing loop
 	decoder := spool.NewDecoder()
 	eventCount := 0
 	signalCount := 0
+	// Baseline signals fleet first-seen workers ship after their spool file
+	// was processed; they count them, so the counter is shared
+	var fleetSignalCount atomic.Int64
+	dedupCount := 0
+	aggregatedCount := 0
 
-	eventsCh := watcher.Events()
-
-	for {
-		select {
-		case <-gctx.Done():
-			// Context cancelled, wait for all goroutines to finish
-			if err := g.Wait(); err != nil && err != context.Canceled {
-				logutil.Error("Service error: %v", err)
+	// Claim, hash and decode upcoming spool files on santa.workers
+	// goroutines; detection consumes them one at a time in arrival order,
+	// so correlation and baseline state see events as if read sequentially
+	archiveDir := cfg.Santa.ArchiveDir
+	prepareFile := func(filePath string) *spoolFile {
+		// Claim the file so agents sharing the spool never double-process it
+		claimedPath, err := watcher.Claim(filePath)
+		if err != nil {
+			if errors.Is(err, spool.ErrAlreadyClaimed) {
+				logutil.Debug("Skipping spool file claimed by another agent: %s", filePath)
+			} else {
+				logutil.Warn("Failed to claim spool file %s: %v", filePath, err)
 			}
-			logutil.Verbose("Processed %d events, generated %d signals", eventCount, signalCount)
-			logutil.Success("Shutdown complete")
-			return
-
-		case <-reloadCh:
-			// Reload rules (SIGHUP received)
-			logutil.Info("Reloading detection rules...")
+			return nil
+		}
+		filePath = claimedPath
 
-			newRulesConfig, err := rules.Load(cfg.Rules.Path)
-			if err != nil {
-				logutil.Error("Failed to reload rules: %v", err)
-				continue
+		// Skip if we've already processed this file (journaled) and clean it up
+		je, _ := db.GetJournalEntry(filePath)
+		if je != nil && je.Checkpoint == nil {
+			if info, err := os.Stat(filePath); err == nil {
+				// If file hasn't changed since last processed, archive/delete it
+				if !info.ModTime().After(je.ProcessedTS) {
+					if err := watcher.ArchiveFile(filePath); err != nil {
+						logutil.Warn("Failed to archive already-processed spool file %s: %v", filePath, err)
+					} else if archiveDir != "" {
+						logutil.Debug("Archived already-processed spool file %s to %s", filePath, archiveDir)
+					} else {
+						logutil.Debug("Deleted already-processed spool file: %s", filePath)
+					}
+					return nil
+				}
 			}
+		}
 
-			newEngine, err := rules.NewEngine()
-			if err != nil {
-				logutil.Error("Failed to create new rules engine: %v", err)
-				continue
+		// Resume a file interrupted by a crash or shutdown after the last
+		// event it processed, unless its contents changed since
+		file := &spoolFile{path: filePath, source: watcher.Label(filePath)}
+		file.hash, err = spool.FileHash(filePath)
+		if err != nil {
+			logutil.Warn("Failed to hash spool file %s, processing it without checkpoints: %v", filePath, err)
+		}
+		if je != nil && je.Checkpoint != nil && file.hash != "" {
+			if je.Checkpoint.Hash == file.hash {
+				file.resume = *je.Checkpoint
+				logutil.Info("Resuming %s after %d processed events", filepath.Base(filePath), file.resume.Next)
+			} else {
+				logutil.Warn("Spool file %s changed since it was checkpointed, processing it from the start", filePath)
 			}
+		}
 
-			if err := newEngine.LoadRules(newRulesConfig); err != nil {
-				logutil.Error("Failed to compile reloaded rules: %v", err)
-				continue
+		// Trace the file as one unit of work: decode, rule evaluation,
+		// correlation and baseline processing, and the signals it produced
+		file.ctx, file.span = tracer.StartSpan(ctx, "spool.file", tracing.String("spool.file", filepath.Base(filePath)))
+		if file.source != "" {
+			file.span.SetAttr(tracing.String("spool.source", file.source))
+		}
+		if file.span.Sampled() {
+			// Time since Santa last wrote the file, including the stability wait
+			if info, err := os.Stat(filePath); err == nil {
+				file.span.SetAttr(tracing.Int64("spool.file_age_ms", time.Since(info.ModTime()).Milliseconds()))
 			}
+		}
 
-			// Atomically replace the old engine with the new one
-			// (safe because this is single-threaded event loop)
-			engine = newEngine
-			rulesConfig = newRulesConfig
+		// Decode events from file
+		_, decodeSpan := tracer.StartSpan(file.ctx, "spool.decode")
+		file.messages, file.decodeErr = decoder.DecodeEvents(filePath)
+		decodeSpan.SetAttr(tracing.Int("spool.events", len(file.messages)))
+		decodeSpan.RecordError(file.decodeErr)
+		decodeSpan.End()
+		return file
+	}
+	prefetch := spool.Prefetch(gctx, watcher.Events(), cfg.Santa.Workers, prepareFile)
+	eventsCh := prefetch.Results()
 
-			// Recreate lineage store if process tree requirements changed
-			needsLineage := false
-			for _, r := range rulesConfig.Rules {
-				if r.Enabled && r.IncludeProcessTree {
-					needsLineage = true
-					break
-				}
-			}
-			if needsLineage && lineageStore == nil {
-				lineageStore = lineage.NewStore(lineage.Config{})
-			} else if !needsLineage {
-				lineageStore = nil
+	// Files waiting for stability, dispatched or being prepared
+	backlog := func() int {
+		return watcher.Backlog() + prefetch.Pending()
+	}
+
+	// Rule fires are counted per spool file and added to the persistent fire
+	// history in one batch when the file is done
+	ruleFires := make(map[string]*state.RuleFires)
+	recordFire := func(ruleID string, ts time.Time) {
+		f, ok := ruleFires[ruleID]
+		if !ok {
+			ruleFires[ruleID] = &state.RuleFires{Count: 1, First: ts, Last: ts}
+			return
+		}
+		f.Count++
+		if ts.Before(f.First) {
+			f.First = ts
+		}
+		if ts.After(f.Last) {
+			f.Last = ts
+		}
+	}
+
+	// emitRuleMatch turns a simple rule match into an enriched signal and enqueues it
+	emitRuleMatch := func(fileCtx context.Context, match *rules.Match, seq uint64, spoolContext map[string]any) {
+		// On traced files the match carries its evaluation time; the signal's
+		// span is a child of the evaluation that produced it
+		evalCtx := fileCtx
+		if !match.EvalStart.IsZero() {
+			evalCtx = tracer.Record(fileCtx, "rule.evaluate", match.EvalStart, match.EvalDuration,
+				tracing.String("rule.id", match.RuleID))
+		}
+		_, span := tracer.StartSpan(evalCtx, "signal.generate")
+		defer span.End()
+		signal := sigGen.FromRuleMatch(match)
+		signal.EventSeq = seq
+		recordFire(match.RuleID, signal.TS)
+
+		// Check if this is the first time we've seen this artifact
+		firstSeen := false
+		if hash := events.TargetSHA256(match.Message); hash != "" {
+			isFirst, err := db.IsFirstSeen("sha256", hash)
+			if err != nil {
+				logutil.Warn("Failed to check first seen: %v", err)
+			} else if isFirst {
+				firstSeen = true
+				sigGen.EnrichSignal(signal, map[string]any{
+					"first_seen": true,
+				})
 			}
+		}
+		santaRules.Suggest(signal, match.Message, firstSeen)
 
-			// Update signal generator with new lineage store
-			sigGen = signals.NewGenerator(cfg.Agent.ID, lineageStore)
+		sigGen.EnrichSignal(signal, spoolContext)
+		traceSignal(span, signal)
 
-			logutil.Success("Reloaded %d simple, %d correlation, %d baseline rules",
-				len(rulesConfig.Rules), len(rulesConfig.Correlations), len(rulesConfig.Baselines))
+		// Aggregate rules only contribute to the next rollup
+		if match.Rule != nil && match.Rule.Aggregate {
+			if err := aggregator.Add(signal, aggregate.Target(match.Message), time.Now()); err != nil {
+				logutil.Warn("Failed to aggregate signal: %v", err)
+			}
+			aggregatedCount++
+			return
+		}
 
-		case filePath, ok := <-eventsCh:
-			if !ok {
-				// Watcher closed, wait for all goroutines to finish
-				cancel() // Trigger shutdown
-				if err := g.Wait(); err != nil && err != context.Canceled {
-					logutil.Error("Service error: %v", err)
-				}
-				logutil.Warn("Watcher events channel closed")
-				logutil.Verbose("Processed %d events, generated %d signals", eventCount, signalCount)
-				logutil.Success("Shutdown complete")
-				return
+		// Repeats of a recent signal for the same target are only counted
+		if deduper != nil {
+			emit, summary, err := deduper.Check(dedup.Key(match.RuleID, match.Message), signal, time.Now())
+			if err != nil {
+				logutil.Warn("Failed to deduplicate signal: %v", err)
 			}
-			spoolArchivePath := ""
-			if cfg.Santa.ArchiveDir != "" {
-				spoolArchivePath = filepath.Join(cfg.Santa.ArchiveDir, filepath.Base(filePath))
+			if summary != nil {
+				enqueueDedupSummary(ship, tail, summary)
 			}
-			spoolContext := map[string]any{}
-			if spoolArchivePath != "" {
-				spoolContext["spool_archive_path"] = spoolArchivePath
+			if !emit {
+				dedupCount++
+				return
 			}
+		}
 
-			// Skip if we've already processed this file (journaled) and clean it up
-			if je, _ := db.GetJournalEntry(filePath); je != nil {
-				if info, err := os.Stat(filePath); err == nil {
-					// If file hasn't changed since last processed, archive/delete it
-					if !info.ModTime().After(je.ProcessedTS) {
-						if err := watcher.ArchiveFile(filePath); err != nil {
-							log.Printf("Warning: Failed to archive already-processed spool file %s: %v", filePath, err)
-						} else if os.Getenv("SANTAMON_DEBUG") == "1" {
-							if spoolArchivePath != "" {
-								log.Printf("Archived already-processed spool file %s to %s", filePath, spoolArchivePath)
-							} else {
-								log.Printf("Deleted already-processed spool file: %s", filePath)
-							}
-						}
-						continue
-					}
-				}
+		responder.Trigger(signal)
+		if err := ship.EnqueueSignal(signal); err != nil {
+			span.RecordError(err)
+			logutil.Error("Failed to enqueue signal: %v", err)
+		} else {
+			signalCount++
+			// Format context for display
+			ctx := formatSignalContext(signal.Context)
+			logutil.Signal("rule", signal.RuleID, signal.Severity, signal.Title, ctx)
+			writeNDJSON(ndjson, signal)
+			tail.Publish("rule", signal)
+			submitFollowUp(followUps, signal, match.Message)
+		}
+	}
+
+	// enqueueBaseline ships a baseline signal and reports whether it was
+	// queued. Fleet first-seen checks call it from their workers.
+	enqueueBaseline := func(signal *state.Signal, bmatch *baseline.BaselineMatch) bool {
+		responder.Trigger(signal)
+		if err := ship.EnqueueSignal(signal); err != nil {
+			logutil.Error("Failed to enqueue baseline signal: %v", err)
+			return false
+		}
+		logutil.Signal("baseline", signal.RuleID, signal.Severity, signal.Title, formatBaselinePattern(bmatch.Pattern))
+		writeNDJSON(ndjson, signal)
+		tail.Publish("baseline", signal)
+		submitFollowUp(followUps, signal, bmatch.Message)
+		return true
+	}
+
+	// reportDisabledRules emits a health signal for each rule the engine
+	// disabled for exceeding its budget since the last call, and reports the
+	// disabled rules in heartbeats
+	reportedDisabled := make(map[string]bool)
+	reportDisabledRules := func() {
+		reasons := engine.DisabledReasons()
+		if len(reasons) == len(reportedDisabled) {
+			return
+		}
+		for _, id := range slices.Sorted(maps.Keys(reasons)) {
+			if reportedDisabled[id] {
+				continue
 			}
-			if os.Getenv("SANTAMON_DEBUG") == "1" {
-				log.Printf("Processing file: %s", filePath)
+			reportedDisabled[id] = true
+			signal := sigGen.FromHealth("rule_disabled", "medium", "Rule disabled: "+id, time.Now(), map[string]any{
+				"disabled_rule":   id,
+				"disabled_reason": reasons[id],
+			})
+			if err := ship.EnqueueSignal(signal); err != nil {
+				logutil.Error("Failed to enqueue rule disabled signal: %v", err)
+				continue
 			}
+			signalCount++
+			logutil.Signal("health", signal.RuleID, signal.Severity, signal.Title, formatSignalContext(signal.Context))
+			writeNDJSON(ndjson, signal)
+			tail.Publish("health", signal)
+		}
+		ship.SetDisabledRules(reasons)
+	}
 
-			fileHasSignals := false
+	// Reloaded rules are on probation for the rollback window: if they hit
+	// the error threshold, the previous engine is restored
+	var prevEngine *rules.Engine
+	var prevRulesConfig *rules.RulesConfig
+	var probationUntil time.Time
 
-			// Decode events from file
-			messages, err := decoder.DecodeEvents(filePath)
-			if err != nil {
-				log.Printf("Failed to decode file: %v", err)
+	// installRules makes newEngine the running engine
+	// (safe because this is single-threaded event loop)
+	installRules := func(newEngine *rules.Engine, newRulesConfig *rules.RulesConfig) {
+		engine = newEngine
+		engine.SetIntel(intelStore)
+		rulesConfig = newRulesConfig
+
+		// Disabled rules are enabled again by compiling them anew
+		clear(reportedDisabled)
+		ship.SetDisabledRules(nil)
+
+		// Recreate lineage store if process tree requirements changed
+		needsLineage := rulesConfig.NeedsLineage()
+		if needsLineage && lineageStore == nil {
+			lineageStore = newLineageStore(cfg, db)
+			stopSweep = sweepLineage(gctx, lineageStore)
+		} else if !needsLineage {
+			stopSweep()
+			lineageStore = nil
+		}
+		windowMgr.SetLineage(lineageStore)
+		engine.SetAncestry(lineageStore)
+		if shadowEngine != nil {
+			shadowEngine.SetAncestry(lineageStore)
+		}
+		forgetCorrelations(windowMgr, engine)
+		restoreLearning(db, engine)
+
+		// Update signal generator with new lineage store
+		sigGen = signals.NewGenerator(cfg.Agent.ID, lineageStore)
+		sigGen.SetIdentityProvider(idProvider)
+		sigGen.SetIntel(intelStore)
+		sigGen.SetInventory(inv)
+		sigGen.SetSessions(sessions)
+		sigGen.SetRulesVersion(engine.Version())
+
+		ship.SetRulesVersion(engine.Version())
+		if err := db.SetMeta("rules_version", engine.Version()); err != nil {
+			logutil.Warn("Failed to store rules_version metadata: %v", err)
+		}
+		warnContextGaps(engine, rulesConfig)
+		setResponseRules(responder, rulesConfig)
+
+		// Start a new shadow comparison against the new active rules
+		if shadowRunner != nil {
+			shadowRunner.Reset(shadowEngine, engine.Version())
+		}
+	}
+
+	// swapRules compiles newRulesConfig and replaces the running engine.
+	// The old engine stays in place if compilation fails.
+	swapRules := func(newRulesConfig *rules.RulesConfig) {
+		newEngine, err := compileRules(newRulesConfig, budget, host, overrides, suppressions)
+		if err != nil {
+			logutil.Error("Failed to compile reloaded rules, keeping version %s: %v", engine.Version(), err)
+			return
+		}
+		if newEngine.Version() == engine.Version() && newEngine.SuppressionsVersion() == engine.SuppressionsVersion() {
+			logutil.Info("Detection rules unchanged (version %s)", engine.Version())
+			return
+		}
+
+		// Keep baseline learning periods anchored to agent start
+		newEngine.InheritStartTime(engine)
+
+		prevEngine, prevRulesConfig = engine, rulesConfig
+		probationUntil = time.Now().Add(cfg.Rules.Rollback.Window)
+		installRules(newEngine, newRulesConfig)
+
+		logutil.Success("Reloaded %d simple, %d correlation, %d baseline rules (version %s)",
+			len(rulesConfig.Rules), len(rulesConfig.Correlations), len(rulesConfig.Baselines), engine.Version())
+	}
+
+	// checkRulesProbation rolls back reloaded rules that cause an error storm
+	// and marks them last-known-good once the probation window passes cleanly
+	checkRulesProbation := func() {
+		if prevEngine == nil {
+			return
+		}
+		if errs := engine.EvalErrors(); errs >= int64(cfg.Rules.Rollback.ErrorThreshold) {
+			bad := engine.Version()
+			logutil.Error("Rules version %s hit %d evaluation errors, rolling back to %s", bad, errs, prevEngine.Version())
+			if err := db.SetMeta("rules_rejected_version", bad); err != nil {
+				logutil.Warn("Failed to store rules_rejected_version metadata: %v", err)
+			}
+			if fetcher != nil {
+				if err := fetcher.Rollback(); err != nil {
+					logutil.Warn("Failed to restore previous cached rules bundle: %v", err)
+				}
+			}
+			installRules(prevEngine, prevRulesConfig)
+			prevEngine, prevRulesConfig = nil, nil
+			return
+		}
+		if time.Now().After(probationUntil) {
+			if err := db.SetMeta("rules_last_good_version", engine.Version()); err != nil {
+				logutil.Warn("Failed to store rules_last_good_version metadata: %v", err)
+			}
+			prevEngine, prevRulesConfig = nil, nil
+		}
+	}
+
+	// Absence correlations report triggers whose expected event never came
+	absenceTicker := time.NewTicker(absenceCheckInterval)
+	defer absenceTicker.Stop()
+
+	// Baseline rules whose learning period ended report what they learned
+	var learningTick <-chan time.Time
+	if cfg.State.FirstSeen.Learning.Summary {
+		learningTicker := time.NewTicker(learningSummaryInterval)
+		defer learningTicker.Stop()
+		learningTick = learningTicker.C
+	}
+
+	// State past its retention is pruned every compact interval. Correlation
+	// windows belong to this loop and are pruned on it; the rest of the state
+	// DB is pruned in the background so large sweeps don't stall events.
+	var janitorTick <-chan time.Time
+	pruned := make(map[string]int64)
+	prunedCh := make(chan map[string]int, 1)
+	if cfg.State.Retention != (config.RetentionConfig{}) && cfg.State.CompactInterval > 0 {
+		janitorTicker := time.NewTicker(cfg.State.CompactInterval)
+		defer janitorTicker.Stop()
+		janitorTick = janitorTicker.C
+		retention, interval := cfg.State.Retention, cfg.State.CompactInterval
+		g.Go(func() error {
+			return pruneState(gctx, db, retention, interval, prunedCh)
+		})
+	}
+	addPruned := func(removed map[string]int) {
+		for class, n := range removed {
+			pruned[class] += int64(n)
+		}
+		if len(removed) > 0 {
+			logutil.Info("Pruned state past retention: %v", removed)
+			ship.SetPruned(maps.Clone(pruned))
+		}
+	}
+
+	// Spool size and free space are checked every pressure interval
+	var pressureTick <-chan time.Time
+	if pressure != nil {
+		pressureTicker := time.NewTicker(cfg.Santa.Pressure.Interval)
+		defer pressureTicker.Stop()
+		pressureTick = pressureTicker.C
+	}
+
+	// Process lineage is snapshotted so a restart doesn't lose process trees
+	lineageTicker := time.NewTicker(cfg.State.Lineage.SnapshotInterval)
+	defer lineageTicker.Stop()
+
+	for {
+		select {
+		case <-gctx.Done():
+			// Context cancelled, wait for all goroutines to finish
+			if err := g.Wait(); err != nil && err != context.Canceled {
+				logutil.Error("Service error: %v", err)
+			}
+			saveLineage(db, lineageStore)
+			logutil.Verbose("Processed %d events, generated %d signals", eventCount, int64(signalCount)+fleetSignalCount.Load())
+			logutil.Verbose("Reclaimed %d expired correlation entries", windowMgr.Reclaimed().Total())
+			logutil.Success("Shutdown complete")
+			return
+
+		case <-reloadCh:
+			// Apply hot-reloadable config settings, then reload rules
+			cfg = reloadConfig(*configPath, cfg, logOpts, *verbose, ship)
+			if cfg.Rules.ReloadOn == "change" && cfg.Rules.Path != watchedRules {
+				stopRulesWatch()
+				watchedRules = cfg.Rules.Path
+				stopRulesWatch = watchRules(gctx, watchedRules, cfg.Rules.ReloadDebounce, reloadCh)
+			}
+
+			// The allowlist is reloaded with the rules; keep the old one on error
+			if newAllow, err := loadAllowlist(cfg); err != nil {
+				logutil.Error("Failed to reload allowlist: %v", err)
+			} else {
+				allow = newAllow
+			}
+
+			// Suppressions are reloaded with the rules; keep the old ones on error
+			if newSuppressions, err := loadSuppressions(cfg); err != nil {
+				logutil.Error("Failed to reload suppressions: %v", err)
+			} else {
+				suppressions = newSuppressions
+			}
+
+			// Shadow rules are reloaded with the rules; keep the old ones on error
+			if shadowRunner != nil {
+				if newShadow, err := loadShadowRules(cfg, budget, host, overrides, suppressions); err != nil {
+					logutil.Error("Failed to reload shadow rules, keeping version %s: %v", shadowEngine.Version(), err)
+				} else if newShadow.Version() != shadowEngine.Version() || newShadow.SuppressionsVersion() != shadowEngine.SuppressionsVersion() {
+					shadowEngine = newShadow
+					shadowEngine.SetIntel(intelStore)
+					shadowEngine.SetAncestry(lineageStore)
+					shadowRunner.Reset(shadowEngine, engine.Version())
+					logutil.Success("Reloaded shadow rules (version %s)", shadowEngine.Version())
+				}
+			}
+
+			// Remote bundles replace the local rules; check for a new one now,
+			// and apply changed suppressions to the current bundle meanwhile
+			if fetcher != nil {
+				logutil.Info("Checking for a new remote rules bundle...")
+				fetcher.Trigger()
+				swapRules(rulesConfig)
+				continue
+			}
+
+			logutil.Info("Reloading detection rules...")
+
+			newRulesConfig, err := rules.Load(cfg.Rules.Path)
+			if err != nil {
+				logutil.Error("Failed to reload rules: %v", err)
+				continue
+			}
+			swapRules(newRulesConfig)
+
+		case <-lineageTicker.C:
+			saveLineage(db, lineageStore)
+
+		case <-absenceTicker.C:
+			// Expected events may still wait in the spool; only expire
+			// triggers once the backlog is processed
+			if backlog() > 0 {
+				continue
+			}
+			windowMatches, err := windowMgr.Expire(time.Now(), engine.GetCorrelations())
+			if err != nil {
+				logutil.Error("Absence correlation processing error: %v", err)
+				continue
+			}
+			for _, wmatch := range windowMatches {
+				signal := sigGen.FromWindowMatch(wmatch, events.ExtractField(wmatch.Events[0], "boot_session_uuid"))
+				recordFire(signal.RuleID, signal.TS)
+				responder.Trigger(signal)
+				if err := ship.EnqueueSignal(signal); err != nil {
+					logutil.Error("Failed to enqueue absence signal: %v", err)
+					continue
+				}
+				signalCount++
+				ctx := fmt.Sprintf("absence=%d events %s", wmatch.Count, formatSignalContext(signal.Context))
+				logutil.Signal("absence", signal.RuleID, signal.Severity, signal.Title, ctx)
+				writeNDJSON(ndjson, signal)
+				tail.Publish("absence", signal)
+			}
+
+		case <-learningTick:
+			for _, signal := range shipLearningSummaries(db, engine, sigGen, ship, time.Now()) {
+				signalCount++
+				ctx := fmt.Sprintf("learned=%v sightings=%v", signal.Context["learned_total"], signal.Context["learned_sightings"])
+				logutil.Signal("baseline", signal.RuleID, signal.Severity, signal.Title, ctx)
+				writeNDJSON(ndjson, signal)
+				tail.Publish("baseline", signal)
+			}
+
+		case <-janitorTick:
+			addPruned(pruneRetention([]retentionClass{
+				{"window_events", cfg.State.Retention.WindowEvents, windowMgr.PruneBefore},
+			}, time.Now()))
+
+		case removed := <-prunedCh:
+			addPruned(removed)
+
+		case <-pressureTick:
+			usage, err := watcher.Usage()
+			if err != nil {
+				logutil.Warn("Failed to measure spool usage: %v", err)
+				continue
+			}
+			reason, changed := pressure.Update(usage)
+			if !changed {
+				continue
+			}
+			severity, title := "medium", "Spool under pressure"
+			if reason != "" {
+				logutil.Warn("Spool under pressure: %s", reason)
+			} else {
+				severity, title = "info", "Spool pressure resolved"
+				logutil.Success("Spool pressure resolved")
+			}
+
+			// Shed load or favor recent files while under pressure
+			switch cfg.Santa.Pressure.Action {
+			case "sample":
+				if reason != "" {
+					shedPolicy.SetFloor(shedding.LevelSample, "spool under pressure: "+reason)
+				} else {
+					shedPolicy.SetFloor(shedding.LevelNone, "")
+				}
+			case "newest":
+				watcher.SetNewestFirst(reason != "")
+			}
+
+			signal := sigGen.FromHealth("spool_pressure", severity, title, time.Now(), map[string]any{
+				"spool_files":      usage.Files,
+				"spool_bytes":      usage.Bytes,
+				"spool_free_bytes": usage.FreeBytes,
+				"pressure_reason":  reason,
+				"pressure_action":  cfg.Santa.Pressure.Action,
+			})
+			if err := ship.EnqueueSignal(signal); err != nil {
+				logutil.Error("Failed to enqueue spool pressure signal: %v", err)
+				continue
+			}
+			signalCount++
+			logutil.Signal("health", signal.RuleID, signal.Severity, signal.Title, formatSignalContext(signal.Context))
+			writeNDJSON(ndjson, signal)
+			tail.Publish("health", signal)
+
+		case bundle := <-remoteRules:
+			newRulesConfig, err := rules.Parse(bundle)
+			if err != nil {
+				logutil.Error("Failed to parse remote rules bundle: %v", err)
+				continue
+			}
+			// Don't reinstall a bundle that was already rolled back
+			if rejected, _ := db.GetMeta("rules_rejected_version"); rejected == newRulesConfig.Version() {
+				logutil.Warn("Skipping remote rules version %s: previously rolled back", rejected)
+				continue
+			}
+			swapRules(newRulesConfig)
+
+		case file, ok := <-eventsCh:
+			if !ok {
+				// Watcher closed, wait for all goroutines to finish
+				cancel() // Trigger shutdown
+				if err := g.Wait(); err != nil && err != context.Canceled {
+					logutil.Error("Service error: %v", err)
+				}
+				logutil.Warn("Watcher events channel closed")
+				logutil.Verbose("Processed %d events, generated %d signals", eventCount, int64(signalCount)+fleetSignalCount.Load())
+				logutil.Verbose("Reclaimed %d expired correlation entries", windowMgr.Reclaimed().Total())
+				logutil.Success("Shutdown complete")
+				return
+			}
+
+			checkRulesProbation()
+			if file == nil {
+				continue
+			}
+			filePath, resume := file.path, file.resume
+			fileCtx, fileSpan := file.ctx, file.span
+			traced := fileSpan.Sampled()
+			messages, err := file.messages, file.decodeErr
+
+			spoolArchivePath := watcher.ArchivePath(filePath)
+			spoolContext := map[string]any{}
+			if spoolArchivePath != "" {
+				spoolContext["spool_archive_path"] = spoolArchivePath
+			}
+			if file.source != "" {
+				spoolContext["spool_source"] = file.source
+			}
+			logutil.Debug("Processing file: %s", filePath)
+
+			fileHasSignals := resume.Signals
+			fileSignals := signalCount
+			fleetDeferred := 0
+			fileDeduplicated := dedupCount
+			fileAggregated := aggregatedCount
+
+			// Decide how much work to shed from the current backlog.
+			// Priority rules always see every event.
+			shedLevel := shedding.LevelNone
+			if shedPolicy != nil {
+				level, changed := shedPolicy.Update(backlog())
+				if changed {
+					if err := shedPolicy.Health(); err != nil {
+						logutil.Warn("Load shedding %s: %v; rules skipped: %s", level, err, strings.Join(engine.SheddableRules(), ", "))
+						ship.SetCoverageDegraded(err.Error())
+					} else {
+						logutil.Success("Load shedding stopped: full detection coverage restored")
+						ship.SetCoverageDegraded("")
+					}
+				}
+				shedLevel = level
+			}
+			evaluateBulk := engine.EvaluateBulk
+			correlations := engine.GetCorrelations()
+			baselines := engine.GetBaselines()
+			if shedLevel >= shedding.LevelSkipLow {
+				evaluateBulk = engine.EvaluateBulkEssential
+				correlations = engine.GetEssentialCorrelations()
+				baselines = engine.GetEssentialBaselines()
+			}
+			sampledOut := 0
+			allowlisted := 0
+			allowAll := cfg.Rules.Allowlist.Scope == "all"
+
+			// Files that cannot be decoded are set aside and never retried
+			if err != nil {
+				fileSpan.RecordError(err)
+				fileSpan.End()
+				logutil.Error("Failed to decode file: %v", err)
+				if err := watcher.ArchiveFile(filePath); err != nil {
+					logutil.Warn("Failed to archive unreadable spool file %s: %v", filePath, err)
+				}
+				// Update journal even on error to avoid reprocessing
+				if err := db.UpdateJournal(filePath, 0); err != nil {
+					logutil.Warn("Failed to update journal: %v", err)
+				}
+				continue
+			}
+
+			// Number the file's events; a file processed again gets new
+			// numbers, a resumed one keeps them
+			firstSeq := resume.FirstSeq
+			if firstSeq == 0 {
+				firstSeq, err = db.ReserveEventSeq(len(messages))
+				if err != nil {
+					logutil.Warn("Failed to reserve event sequence numbers: %v", err)
+					firstSeq = 0
+				}
+			}
+			eventSeq := func(i int) uint64 {
+				if firstSeq == 0 {
+					return 0
+				}
+				return firstSeq + uint64(i)
+			}
+
+			// Checkpoint the events processed so far, so a restart neither
+			// emits their signals again nor skips the rest of the file
+			checkpoint := func(priority, next int) {
+				if file.hash == "" {
+					return
+				}
+				cp := state.Checkpoint{Hash: file.hash, Priority: priority, Next: next, FirstSeq: firstSeq, Signals: fileHasSignals}
+				if err := db.SaveCheckpoint(filePath, cp); err != nil {
+					logutil.Warn("Failed to checkpoint spool file %s: %v", filePath, err)
+				}
+			}
+
+			// Traced files get a span for each rule evaluation, correlation
+			// persist and baseline processing that produced a match, and the
+			// file's total cost of each as attributes
+			var ruleStats map[string]*rules.RuleStats
+			var correlationTime, baselineTime time.Duration
+			if traced {
+				ruleStats = make(map[string]*rules.RuleStats)
+				engine.CollectStats(ruleStats)
+			}
+
+			// Record the file's executions in the process lineage store before
+			// any rule runs, so ancestry functions see parents executed earlier
+			// in the same file. Events processed before a restart and events
+			// the prefilter drops are recorded too.
+			if lineageStore != nil {
+				for _, msg := range messages {
+					if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
+						lineageStore.UpsertFromExecution(msg, ev.Execution)
+					}
+				}
+			}
+
+			// Follow the console user through the file's session events;
+			// signals look it up by event time
+			for _, msg := range messages {
+				sessions.Observe(msg)
+			}
+
+			// Allowlisted executions skip simple rules; events the prefilter
+			// drops skip detection entirely
+			allowed := make([]bool, len(messages))
+			filtered := make([]bool, len(messages))
+			for i, msg := range messages {
+				_, allowed[i] = allow.Match(msg)
+				filtered[i] = eventFilter != nil && !eventFilter.Keep(msg)
+			}
+
+			// Under sampling, only every Nth event reaches non-priority rules
+			sampled := make([]bool, len(messages))
+			skipBulk := make([]bool, len(messages))
+			for i := range messages {
+				sampled[i] = !filtered[i] && shedLevel == shedding.LevelSample && !shedPolicy.Keep()
+				skipBulk[i] = sampled[i] || allowed[i] || filtered[i] || i < resume.Next
+			}
+
+			// Events are processed in chunks, so the activations and event maps
+			// that rules, correlations, baselines and signals share are only
+			// held for one chunk at a time. Each event is checkpointed once it
+			// changed any state: signals, dedup and aggregates, windows or
+			// baselines.
+			writes := db.Writes()
+			for lo := 0; lo < len(messages); lo += eventChunkSize {
+				hi := min(lo+eventChunkSize, len(messages))
+				evs := rules.NewEvents(messages[lo:hi])
+
+				// Fast lane: evaluate priority rules across the chunk first so
+				// their signals ship before bulk evaluation of the remaining
+				// rules. Simple rules are evaluated on the worker pool; their
+				// signals are still emitted in event order.
+				fastLane := engine.HasPriorityRules()
+				var priorityMatches map[*santapb.SantaMessage][]*rules.Match
+				if fastLane && shadowRunner != nil {
+					priorityMatches = make(map[*santapb.SantaMessage][]*rules.Match)
+				}
+				if fastLane {
+					// Events evaluated before a restart are skipped
+					skipPriority := make([]bool, hi-lo)
+					for j := range skipPriority {
+						i := lo + j
+						skipPriority[j] = allowed[i] || filtered[i] || i < resume.Priority
+					}
+					results := rules.EvaluateEach(evs, skipPriority, cfg.Rules.Workers, engine.EvaluatePriority)
+					for j, msg := range messages[lo:hi] {
+						if skipPriority[j] {
+							continue
+						}
+						i := lo + j
+						matches, err := results[j].Matches, results[j].Err
+						if err != nil {
+							logutil.Error("Priority rule evaluation error: %v", err)
+							continue
+						}
+						for _, match := range matches {
+							emitRuleMatch(fileCtx, match, eventSeq(i), spoolContext)
+							fileHasSignals = true
+						}
+						if priorityMatches != nil && len(matches) > 0 {
+							priorityMatches[msg] = matches
+						}
+						// Events before the chunk are done
+						if len(matches) > 0 {
+							checkpoint(i+1, max(resume.Next, lo))
+						}
+					}
+				}
+
+				bulkResults := rules.EvaluateEach(evs, skipBulk[lo:hi], cfg.Rules.Workers, evaluateBulk)
+				for i := lo; i < hi; i++ {
+					msg := messages[i]
+					// Priority rules have run for the whole chunk
+					if i > 0 && db.Writes() != writes {
+						checkpoint(hi, i)
+						writes = db.Writes()
+					}
+
+					// Events processed before a restart were only recorded in the
+					// lineage store
+					if i < resume.Next {
+						continue
+					}
+					eventCount++
+
+					// Sample event into the rule development corpus, when enabled
+					if rec != nil {
+						if _, err := rec.Record(msg); err != nil {
+							logutil.Warn("Failed to record event: %v", err)
+						}
+					}
+
+					if filtered[i] {
+						continue
+					}
+					if sampled[i] {
+						sampledOut++
+						continue
+					}
+
+					// Allowlisted executions skip simple rules, and with scope "all"
+					// correlations and baselines too
+					if allowed[i] {
+						allowlisted++
+						if allowAll {
+							continue
+						}
+					} else {
+						// Remaining simple rules, evaluated above
+						matches, err := bulkResults[i-lo].Matches, bulkResults[i-lo].Err
+						if err != nil {
+							logutil.Error("Rule evaluation error: %v", err)
+							continue
+						}
+
+						// Process simple rule matches
+						for _, match := range matches {
+							emitRuleMatch(fileCtx, match, eventSeq(i), spoolContext)
+							fileHasSignals = true
+						}
+
+						// Compare with the shadow rules; skipped while shedding,
+						// when the active rules only see part of the load
+						if shadowRunner != nil && shedLevel == shedding.LevelNone {
+							active := slices.Concat(priorityMatches[msg], matches)
+							if err := shadowRunner.Compare(msg, active); err != nil {
+								logutil.Warn("Shadow rule evaluation error: %v", err)
+							}
+						}
+					}
+
+					// Evaluate correlation rules
+					if len(correlations) > 0 {
+						start := time.Now()
+						windowMatches, err := windowMgr.ProcessEvent(evs[i-lo], correlations)
+						correlationCtx := fileCtx
+						if traced {
+							elapsed := time.Since(start)
+							correlationTime += elapsed
+							if len(windowMatches) > 0 {
+								correlationCtx = tracer.Record(fileCtx, "correlation.persist", start, elapsed,
+									tracing.Int("correlation.matches", len(windowMatches)))
+							}
+						}
+						if err != nil {
+							logutil.Error("Correlation processing error: %v", err)
+							continue
+						}
+						for _, wmatch := range windowMatches {
+							_, span := tracer.StartSpan(correlationCtx, "signal.generate")
+							signal := sigGen.FromWindowMatch(wmatch, msg.GetBootSessionUuid())
+							signal.EventSeq = eventSeq(i)
+							recordFire(signal.RuleID, signal.TS)
+							sigGen.EnrichSignal(signal, spoolContext)
+							traceSignal(span, signal)
+							fileHasSignals = true
+							responder.Trigger(signal)
+							err := ship.EnqueueSignal(signal)
+							span.RecordError(err)
+							span.End()
+							if err != nil {
+								logutil.Error("Failed to enqueue correlation signal: %v", err)
+							} else {
+								signalCount++
+								// Format context for correlation signals
+								ctx := fmt.Sprintf("correlation=%d events %s", wmatch.Count, formatSignalContext(signal.Context))
+								logutil.Signal("correlation", signal.RuleID, signal.Severity, signal.Title, ctx)
+								writeNDJSON(ndjson, signal)
+								tail.Publish("correlation", signal)
+							}
+						}
+					}
+
+					// Evaluate baseline rules
+					if len(baselines) > 0 {
+						start := time.Now()
+						baselineMatches, err := baselineProc.ProcessEvent(evs[i-lo], baselines, engine)
+						baselineCtx := fileCtx
+						if traced {
+							elapsed := time.Since(start)
+							baselineTime += elapsed
+							if len(baselineMatches) > 0 {
+								baselineCtx = tracer.Record(fileCtx, "baseline.process", start, elapsed,
+									tracing.Int("baseline.matches", len(baselineMatches)))
+							}
+						}
+						if err != nil {
+							logutil.Error("Baseline processing error: %v", err)
+							continue
+						}
+						for _, bmatch := range baselineMatches {
+							// The pattern is recorded; during the learning period
+							// only log the match unless learning.ship is set
+							if bmatch.InLearning && !cfg.State.FirstSeen.Learning.Ship {
+								// Show learning mode signals with INFO severity
+								ctx := formatBaselinePattern(bmatch.Pattern)
+								logutil.Signal("baseline", bmatch.RuleID, "info", bmatch.Title+" (learning)", ctx)
+								continue
+							}
+
+							_, span := tracer.StartSpan(baselineCtx, "signal.generate")
+							signal := sigGen.FromBaselineMatch(bmatch)
+							signal.EventSeq = eventSeq(i)
+							if bmatch.InLearning {
+								signal.Severity = "info"
+							}
+							recordFire(signal.RuleID, signal.TS)
+							// A baseline match is new to this host by definition
+							santaRules.Suggest(signal, bmatch.Message, true)
+							sigGen.EnrichSignal(signal, spoolContext)
+							traceSignal(span, signal)
+							span.End()
+							fileHasSignals = true
+
+							// Ask the collector whether the pattern is already common
+							// across the fleet, off the detection loop; alert anyway if
+							// it cannot answer in time
+							if fleetChecker != nil && !bmatch.InLearning {
+								minHosts := cfg.State.FirstSeen.Fleet.MinHosts
+								queued := fleetChecker.Check(bmatch.RuleID, bmatch.Pattern, func(sighting *shipper.FleetSighting, err error) {
+									switch {
+									case err != nil:
+										logutil.Warn("Fleet first-seen lookup for %s failed, alerting: %v", bmatch.RuleID, err)
+									case sighting.Hosts >= minHosts:
+										logutil.Debug("Baseline %s pattern already seen on %d hosts since %s, not alerting: %s",
+											bmatch.RuleID, sighting.Hosts, sighting.FirstSeen.Format(time.RFC3339), bmatch.Pattern)
+										return
+									default:
+										signal.Context["fleet_hosts"] = sighting.Hosts
+										signal.Context["fleet_first_seen"] = sighting.FirstSeen.UTC().Format(time.RFC3339)
+									}
+									if enqueueBaseline(signal, bmatch) {
+										fleetSignalCount.Add(1)
+									}
+								})
+								if queued {
+									// Counted by the callback once the collector answered
+									fleetDeferred++
+									continue
+								}
+								logutil.Warn("Fleet first-seen lookups are backed up, alerting on %s without one", bmatch.RuleID)
+							}
+							if enqueueBaseline(signal, bmatch) {
+								signalCount++
+							}
+						}
+					}
+				}
+			}
+
+			// Log what the prefilter kept from detection
+			prefiltered := 0
+			if eventFilter != nil {
+				var dropped string
+				if prefiltered, dropped = eventFilter.Dropped(); prefiltered > 0 {
+					logutil.Debug("Prefilter %s: dropped %d of %d events (%s)",
+						filepath.Base(filePath), prefiltered, len(messages), dropped)
+				}
+			}
+
+			// Log exactly what this file lost to load shedding
+			if shedLevel != shedding.LevelNone {
+				if skipped := engine.SheddableRules(); len(skipped) > 0 {
+					logutil.Warn("Load shedding (%s) %s: skipped %d info/low rules for %d events: %s",
+						shedLevel, filepath.Base(filePath), len(skipped), len(messages)-sampledOut, strings.Join(skipped, ", "))
+				}
+				if sampledOut > 0 {
+					logutil.Warn("Load shedding (%s) %s: %d of %d events not evaluated by non-priority rules",
+						shedLevel, filepath.Base(filePath), sampledOut, len(messages))
+				}
+			}
+
+			if traced {
+				engine.CollectStats(nil)
+				var ruleTime time.Duration
+				evaluations := 0
+				for _, st := range ruleStats {
+					ruleTime += st.Duration
+					evaluations += st.Evaluations
+				}
+				fileSpan.SetAttr(
+					tracing.Int("rule.evaluations", evaluations),
+					tracing.Int64("rule.evaluate_us", ruleTime.Microseconds()),
+					tracing.Int64("correlation.persist_us", correlationTime.Microseconds()),
+					tracing.Int64("baseline.process_us", baselineTime.Microseconds()),
+					tracing.Int("spool.events", len(messages)),
+					tracing.Int("spool.allowlisted", allowlisted),
+					tracing.Int("spool.prefiltered", prefiltered),
+					tracing.Int("spool.signals", signalCount-fileSignals),
+					tracing.Int("spool.fleet_deferred", fleetDeferred),
+					tracing.Int("spool.deduplicated", dedupCount-fileDeduplicated),
+					tracing.Int("spool.aggregated", aggregatedCount-fileAggregated))
+			}
+			fileSpan.End()
+			ship.SetSuppressions(engine.Suppressions())
+			reportDisabledRules()
+
+			if err := db.AddRuleFires(ruleFires); err != nil {
+				logutil.Warn("Failed to update rule fire history: %v", err)
+			}
+			clear(ruleFires)
+
+			// Update journal after successful processing
+			if err := db.UpdateJournal(filePath, 0); err != nil {
+				logutil.Warn("Failed to update journal: %v", err)
+			}
+
+			// Delete processed files with no signals, archive files that produced alerts
+			if fileHasSignals {
 				if err := watcher.ArchiveFile(filePath); err != nil {
-					log.Printf("Warning: Failed to archive unreadable spool file %s: %v", filePath, err)
+					logutil.Warn("Failed to archive spool file %s: %v", filePath, err)
+				} else if spoolArchivePath != "" {
+					logutil.Debug("Archived spool file %s to %s", filePath, spoolArchivePath)
+				}
+			} else {
+				if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+					logutil.Warn("Failed to delete spool file %s: %v", filePath, err)
+				} else {
+					logutil.Debug("Deleted spool file %s (no signals)", filePath)
+				}
+			}
+
+			logutil.Debug("Processed %d events from %s (%d allowlisted, %d deduplicated, %d aggregated signals)",
+				len(messages), filePath, allowlisted, dedupCount-fileDeduplicated, aggregatedCount-fileAggregated)
+		}
+	}
+}
+
+// spoolFile is a spool file claimed and decoded ahead of detection
+type spoolFile struct {
+	path      string // Claimed path
+	source    string // Label of the spool directory the file came from
+	hash      string // Contents digest; empty when checkpoints are unavailable
+	resume    state.Checkpoint
+	messages  []*santapb.SantaMessage
+	decodeErr error
+	ctx       context.Context // Carries the file's trace span
+	span      *tracing.Span
+}
+
+// newIdentityProvider creates the configured directory identity provider, or nil
+func newIdentityProvider(cfg *config.Config) (identity.Provider, error) {
+	switch cfg.Identity.Provider {
+	case "file":
+		return identity.NewFileProvider(cfg.Identity.File), nil
+	case "command":
+		return identity.NewCommandProvider(cfg.Identity.Command, cfg.Identity.Timeout, cfg.Identity.CacheTTL)
+	}
+	return nil, nil
+}
+
+// setResponseRules gives the responder the actions of the loaded rules and
+// logs references to actions missing from the response.actions catalog
+func setResponseRules(responder *response.Responder, rc *rules.RulesConfig) {
+	actions := rc.Actions()
+	if responder == nil {
+		if len(actions) > 0 {
+			logutil.Warn("%d rules declare response actions, but response is disabled", len(actions))
+		}
+		return
+	}
+	for _, err := range responder.SetRules(actions) {
+		logutil.Warn("Response: %v", err)
+	}
+}
+
+// warnContextGaps logs the rules that request a process tree or extra_context
+// fields their events can never provide
+func warnContextGaps(engine *rules.Engine, rc *rules.RulesConfig) {
+	for _, issue := range engine.CheckContext(rc) {
+		logutil.Warn("Rule %v", issue)
+	}
+}
+
+// newReputation creates the hash reputation client, or returns nil when no
+// provider is configured
+func newReputation(cfg *config.Config) reputation.Provider {
+	if cfg.Reputation.Provider == "" {
+		return nil
+	}
+	return reputation.New(cfg.Reputation)
+}
+
+// newYARA creates the YARA scanner, or returns nil when yara is disabled
+func newYARA(cfg *config.Config) (yara.Provider, error) {
+	if !cfg.YARA.Enabled {
+		return nil, nil
+	}
+	return yara.New(cfg.YARA)
+}
+
+// loadIntel loads the configured threat intel feeds, or returns nil when none
+// are configured. Feeds that fail to load are logged and retried by Run.
+func loadIntel(cfg *config.Config) *intel.Store {
+	if len(cfg.Intel.Feeds) == 0 {
+		return nil
+	}
+	store := intel.NewStore(cfg.Intel)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := store.Load(ctx); err != nil {
+		logutil.Warn("Failed to load intel feeds: %v", err)
+	}
+	return store
+}
+
+// traceSignal tags the generating span with the signal and stores the span on
+// the signal, so shipping it later continues the same trace
+func traceSignal(span *tracing.Span, signal *state.Signal) {
+	span.SetAttr(
+		tracing.String("signal.id", signal.ID),
+		tracing.String("rule.id", signal.RuleID),
+		tracing.String("signal.severity", signal.Severity))
+	signal.TraceID, signal.SpanID = span.IDs()
+}
+
+// effectiveLogLevel returns the configured log level, raised to verbose by --verbose
+// unless debug is already set
+func effectiveLogLevel(cfg *config.Config, verbose bool) string {
+	if verbose && cfg.Agent.LogLevel != "debug" {
+		return "verbose"
+	}
+	return cfg.Agent.LogLevel
+}
+
+// reloadConfig re-reads the config file and applies its hot-reloadable
+// settings. Invalid configs are rejected as a whole and the current one kept.
+func reloadConfig(path string, current *config.Config, logOpts logutil.Options, verbose bool, ship *shipper.Shipper) *config.Config {
+	next, err := config.Load(path)
+	if err != nil {
+		logutil.Error("Config reload failed, keeping current settings: %v", err)
+		return current
+	}
+
+	merged, applied, restart := current.Reload(next)
+	if len(restart) > 0 {
+		logutil.Warn("Config changes need a restart to take effect: %s", strings.Join(restart, ", "))
+	}
+	if len(applied) == 0 {
+		return current
+	}
+
+	logOpts.Level = effectiveLogLevel(merged, verbose)
+	logOpts.Components = merged.Agent.LogLevels
+	if err := logutil.Configure(logOpts); err != nil {
+		logutil.Error("Config reload failed, keeping current settings: %v", err)
+		return current
+	}
+	ship.UpdateConfig(&merged.Shipper)
+
+	logutil.Success("Reloaded config: %s", strings.Join(applied, ", "))
+	return merged
+}
+
+// configPollInterval is how often the config file is checked when agent.reload_on is "change"
+const configPollInterval = 5 * time.Second
+
+// absenceCheckInterval is how often absence correlation windows are expired
+const absenceCheckInterval = 5 * time.Second
+
+// watchConfigFile requests a reload whenever the config file's size or
+// modification time changes
+func watchConfigFile(ctx context.Context, path string, reloadCh chan<- struct{}) error {
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	lastMod, lastSize := stat()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		mod, size := stat()
+		// A missing file (mid-rewrite) is not a change; wait for it to reappear
+		if size < 0 || (mod.Equal(lastMod) && size == lastSize) {
+			continue
+		}
+		lastMod, lastSize = mod, size
+
+		logutil.Info("Config file changed, reloading")
+		select {
+		case reloadCh <- struct{}{}:
+		default:
+			// Reload already pending
+		}
+	}
+}
+
+// watchRules requests a reload whenever the rules at path change, until ctx
+// is done or the returned function is called
+func watchRules(ctx context.Context, path string, debounce time.Duration, reloadCh chan<- struct{}) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		err := rules.Watch(ctx, path, debounce, func() {
+			logutil.Info("Rules changed, reloading")
+			select {
+			case reloadCh <- struct{}{}:
+			default:
+				// Reload already pending
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			// The agent keeps running; rules still reload on SIGHUP
+			logutil.Warn("Rules watcher stopped: %v", err)
+		}
+	}()
+	return cancel
+}
+
+// writeNDJSON emits sig on stdout when running with --output ndjson
+func writeNDJSON(w *signals.NDJSONWriter, sig *state.Signal) {
+	if w == nil {
+		return
+	}
+	if err := w.Write(sig); err != nil {
+		logutil.Warn("Failed to write signal to stdout: %v", err)
+	}
+}
+
+// loadCachedRules loads the last verified remote bundle. If it no longer
+// compiles (e.g. after an agent upgrade), the bundle before it is restored.
+// Returns nils when no cached bundle is usable.
+func loadCachedRules(fetcher *rulesync.Fetcher, budget rules.Budget, host *rules.Host, overrides *rules.Overrides, suppressions *rules.Suppressions) (*rules.RulesConfig, *rules.Engine) {
+	for attempt := 0; ; attempt++ {
+		bundle, err := fetcher.Cached()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		var rc *rules.RulesConfig
+		if err == nil {
+			rc, err = rules.Parse(bundle)
+		}
+		var engine *rules.Engine
+		if err == nil {
+			engine, err = compileRules(rc, budget, host, overrides, suppressions)
+		}
+		if err == nil {
+			return rc, engine
+		}
+
+		logutil.Warn("Ignoring cached remote rules: %v", err)
+		if attempt > 0 || fetcher.Rollback() != nil {
+			return nil, nil
+		}
+	}
+}
+
+// loadAllowlist loads the global allowlist, or returns nil when none is configured
+func loadAllowlist(cfg *config.Config) (*allowlist.Allowlist, error) {
+	if cfg.Rules.Allowlist.Path == "" {
+		return nil, nil
+	}
+	return allowlist.Load(cfg.Rules.Allowlist.Path)
+}
+
+// ruleBudget returns the configured rule evaluation budget
+func ruleBudget(cfg *config.Config) rules.Budget {
+	return rules.Budget{
+		CostLimit:      cfg.Rules.Budget.CostLimit,
+		LatencyLimit:   cfg.Rules.Budget.LatencyLimit,
+		Timeout:        cfg.Rules.Budget.Timeout,
+		DisableAfter:   cfg.Rules.Budget.DisableAfter,
+		ErrorRate:      cfg.Rules.Budget.ErrorRate,
+		MinEvaluations: cfg.Rules.Budget.MinEvaluations,
+	}
+}
+
+// ruleHost returns the host that rules are scoped to
+func ruleHost(cfg *config.Config) *rules.Host {
+	return &rules.Host{Tags: cfg.Agent.Tags}
+}
+
+// ruleOverrides returns the configured pack and rule overrides, or nil when
+// there are none
+func ruleOverrides(cfg *config.Config) *rules.Overrides {
+	if len(cfg.Rules.Packs) == 0 && len(cfg.Rules.Overrides) == 0 {
+		return nil
+	}
+	convert := func(in map[string]config.RuleOverrideConfig) map[string]rules.Override {
+		out := make(map[string]rules.Override, len(in))
+		for name, o := range in {
+			out[name] = rules.Override{Enabled: o.Enabled, Severity: o.Severity}
+		}
+		return out
+	}
+	return &rules.Overrides{Packs: convert(cfg.Rules.Packs), Rules: convert(cfg.Rules.Overrides)}
+}
+
+// loadSuppressions loads the suppressions file, or returns nil when none is configured
+func loadSuppressions(cfg *config.Config) (*rules.Suppressions, error) {
+	if cfg.Rules.Suppressions == "" {
+		return nil, nil
+	}
+	return rules.LoadSuppressions(cfg.Rules.Suppressions)
+}
+
+// loadShadowRules compiles the shadow rules, when configured, with the same
+// budget, host, overrides and suppressions as the active rules
+func loadShadowRules(cfg *config.Config, budget rules.Budget, host *rules.Host, overrides *rules.Overrides, suppressions *rules.Suppressions) (*rules.Engine, error) {
+	if cfg.Rules.ShadowPath == "" {
+		return nil, nil
+	}
+	rc, err := rules.Load(cfg.Rules.ShadowPath)
+	if err != nil {
+		return nil, err
+	}
+	return compileRules(rc, budget, host, overrides, suppressions)
+}
+
+// compileRules applies overrides to rc and creates a rules engine loaded with
+// it and suppressions. The rules are scoped to host, or all loaded when host
+// is nil.
+func compileRules(rc *rules.RulesConfig, budget rules.Budget, host *rules.Host, overrides *rules.Overrides, suppressions *rules.Suppressions) (*rules.Engine, error) {
+	rc.ApplyOverrides(overrides)
+	engine, err := rules.NewEngine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rules engine: %w", err)
+	}
+	engine.SetBudget(budget)
+	engine.SetHost(host)
+	engine.SetSuppressions(suppressions)
+	if err := engine.LoadRules(rc); err != nil {
+		return nil, fmt.Errorf("failed to compile rules: %w", err)
+	}
+	return engine, nil
+}
+
+// newSinks compiles the transform pipelines of the configured sinks
+func newSinks(cfgs []config.SinkConfig) ([]*shipper.Sink, error) {
+	sinks := make([]*shipper.Sink, 0, len(cfgs))
+	for _, sc := range cfgs {
+		sink, err := shipper.NewSink(sc)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// flushLoop hands the signals flush returns to emit, flushing every interval
+// or every minute, whichever is shorter
+func flushLoop(ctx context.Context, interval time.Duration, what string, flush func(time.Time) ([]*state.Signal, error), emit func(*state.Signal)) error {
+	ticker := time.NewTicker(min(interval, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		sigs, err := flush(time.Now())
+		if err != nil {
+			logutil.Warn("Failed to flush %s signals: %v", what, err)
+			continue
+		}
+		for _, sig := range sigs {
+			emit(sig)
+		}
+	}
+}
+
+// enqueueRollup ships an aggregate rollup
+func enqueueRollup(ship *shipper.Shipper, tail *admin.Stream, rollup *state.Signal) {
+	if err := ship.EnqueueSignal(rollup); err != nil {
+		logutil.Error("Failed to enqueue aggregate rollup: %v", err)
+		return
+	}
+	logutil.Info("%s: %q matched %v times (%v distinct targets) since %v", rollup.RuleID, rollup.Title,
+		rollup.Context["aggregate_count"], rollup.Context["aggregate_distinct_targets"], rollup.Context["aggregate_first_seen"])
+	tail.Publish("aggregate", rollup)
+}
+
+// Fleet first-seen lookups in flight and waiting; matches beyond them alert
+// without a lookup
+const (
+	fleetWorkers   = 4
+	fleetQueueSize = 256
+)
+
+// eventChunkSize bounds the events of a spool file whose activations and
+// event maps are held at once
+const eventChunkSize = 1024
+
+// followUpQueueSize bounds the signals waiting for slow enrichments; signals
+// beyond it ship without them
+const followUpQueueSize = 256
+
+// submitFollowUp queues a shipped signal for the enrichments that run off
+// the detection loop
+func submitFollowUp(followUps *signals.FollowUps, sig *state.Signal, msg *santapb.SantaMessage) {
+	if !followUps.Submit(sig, msg) {
+		logutil.Debug("Follow-up queue full, signal %s not enriched", sig.ID)
+	}
+}
+
+// enqueueFollowUp ships a follow-up signal enriching one shipped earlier
+func enqueueFollowUp(ship *shipper.Shipper, tail *admin.Stream, ndjson *signals.NDJSONWriter, sig *state.Signal) {
+	if err := ship.EnqueueSignal(sig); err != nil {
+		logutil.Error("Failed to enqueue follow-up signal: %v", err)
+		return
+	}
+	logutil.Signal("follow_up", sig.RuleID, sig.Severity, sig.Title, formatSignalContext(sig.Context))
+	writeNDJSON(ndjson, sig)
+	tail.Publish("follow_up", sig)
+}
+
+// enqueueDedupSummary ships the summary of a signal's repeats
+func enqueueDedupSummary(ship *shipper.Shipper, tail *admin.Stream, summary *state.Signal) {
+	if err := ship.EnqueueSignal(summary); err != nil {
+		logutil.Error("Failed to enqueue dedup summary: %v", err)
+		return
+	}
+	logutil.Info("%s: %q repeated %v times since %v", summary.RuleID, summary.Title,
+		summary.Context["dedup_count"], summary.Context["dedup_first_seen"])
+	tail.Publish("dedup", summary)
+}
+
+// forgetCorrelations drops the stored windows of correlation rules the
+// engine no longer has, so removed rules don't keep state forever
+func forgetCorrelations(windowMgr *correlation.WindowManager, engine *rules.Engine) {
+	if dropped, err := windowMgr.Forget(engine.GetCorrelations()); err != nil {
+		logutil.Warn("Failed to drop state of removed correlation rules: %v", err)
+	} else if dropped > 0 {
+		logutil.Info("Dropped %d correlation groups of removed rules", dropped)
+	}
+}
+
+// restoreLearning starts each baseline rule's learning period when the rule
+// was first loaded, so agent restarts don't reopen it
+func restoreLearning(db state.Store, engine *rules.Engine) {
+	now := time.Now()
+	for _, b := range engine.GetBaselines() {
+		if b.Rule.LearningPeriod == 0 {
+			continue
+		}
+		start, err := db.LearningStart(b.Rule.ID, b.Rule.ContentHash(), now)
+		if err != nil {
+			logutil.Warn("Failed to restore learning period of %s: %v", b.Rule.ID, err)
+			continue
+		}
+		engine.SetLearningStart(b.Rule.ID, start)
+		if start.Before(now) {
+			logutil.Debug("Baseline %s learning since %s", b.Rule.ID, start.Format(time.RFC3339))
+		}
+	}
+}
+
+// newLineageStore creates the process lineage store and restores the
+// snapshot persisted before the last shutdown
+func newLineageStore(cfg *config.Config, db state.Store) *lineage.Store {
+	store := lineage.NewStore(lineage.Config{
+		MaxEntries: cfg.State.Lineage.MaxEntries,
+		TTL:        cfg.State.Lineage.TTL,
+	})
+	snapshot, err := db.LoadLineage()
+	if err != nil {
+		logutil.Warn("Failed to load process lineage: %v", err)
+		return store
+	}
+	restored, err := store.Restore(snapshot, time.Now())
+	if err != nil {
+		logutil.Warn("Failed to restore process lineage: %v", err)
+	} else if restored > 0 {
+		logutil.Verbose("Restored %d processes of lineage", restored)
+	}
+	return store
+}
+
+// lineageSweepInterval is how often expired process lineage is removed
+const lineageSweepInterval = 10 * time.Second
+
+// sweepLineage removes expired nodes from store in the background until ctx
+// is cancelled or the returned func is called
+func sweepLineage(ctx context.Context, store *lineage.Store) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if store != nil {
+		go store.RunSweeper(ctx, lineageSweepInterval)
+	}
+	return cancel
+}
+
+// saveLineage persists a snapshot of the lineage store, when there is one
+func saveLineage(db state.Store, store *lineage.Store) {
+	if store == nil {
+		return
+	}
+	snapshot, err := store.Snapshot()
+	if err == nil {
+		err = db.SaveLineage(snapshot)
+	}
+	if err != nil {
+		logutil.Warn("Failed to save process lineage: %v", err)
+	}
+}
+
+// learningSummaryInterval is how often baseline rules are checked for an
+// ended learning period
+const learningSummaryInterval = time.Minute
+
+// metaLearningSummary prefixes the meta keys recording, per baseline rule,
+// the content hash whose learning summary was shipped
+const metaLearningSummary = "learning_summary:"
+
+// shipLearningSummaries enqueues a learning summary for each baseline rule
+// whose learning period has ended, once per rule content, and returns the
+// enqueued signals
+func shipLearningSummaries(db state.Store, engine *rules.Engine, sigGen *signals.Generator, ship *shipper.Shipper, now time.Time) []*state.Signal {
+	var shipped []*state.Signal
+	for _, b := range engine.GetBaselines() {
+		end := engine.LearningEnd(b.Rule)
+		if end.IsZero() || now.Before(end) {
+			continue
+		}
+		key, hash := metaLearningSummary+b.Rule.ID, b.Rule.ContentHash()
+		if sent, err := db.GetMeta(key); err != nil || sent == hash {
+			continue
+		}
+
+		summary, err := baseline.Summarize(db, b.Rule, end.Add(-b.Rule.LearningPeriod), end)
+		if err != nil {
+			logutil.Warn("Failed to summarize baseline learning: %v", err)
+			continue
+		}
+		signal := sigGen.FromLearningSummary(summary)
+		if err := ship.EnqueueSignal(signal); err != nil {
+			logutil.Error("Failed to enqueue learning summary: %v", err)
+			continue
+		}
+		if err := db.SetMeta(key, hash); err != nil {
+			logutil.Warn("Failed to record learning summary of %s: %v", b.Rule.ID, err)
+		}
+		shipped = append(shipped, signal)
+	}
+	return shipped
+}
+
+// metaBaselineSeeded records when a baseline seed was imported
+const metaBaselineSeeded = "baseline_seeded"
+
+// pullBaselineSeed imports the baseline seed published on the collector,
+// retrying every interval until one is imported. It does nothing once a
+// seed was imported into the database.
+func pullBaselineSeed(ctx context.Context, ship *shipper.Shipper, db state.Store, interval time.Duration) error {
+	if seeded, err := db.GetMeta(metaBaselineSeeded); err != nil || seeded != "" {
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		data, err := ship.FetchBaselineSeed(ctx)
+		switch {
+		case err != nil:
+			logutil.Warn("Failed to fetch baseline seed, retrying in %v: %v", interval, err)
+		case data == nil:
+			logutil.Debug("No baseline seed published, retrying in %v", interval)
+		default:
+			seed, err := baseline.ParseSeed(data)
+			if err != nil {
+				logutil.Warn("Ignoring baseline seed: %v", err)
+				break
+			}
+			added, err := baseline.Import(db, seed)
+			if err != nil {
+				logutil.Warn("Failed to import baseline seed: %v", err)
+				break
+			}
+			if err := db.SetMeta(metaBaselineSeeded, time.Now().UTC().Format(time.RFC3339)); err != nil {
+				logutil.Warn("Failed to store baseline_seeded metadata: %v", err)
+			}
+			logutil.Info("Seeded %d baseline patterns exported from %s", added, seed.AgentID)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// retentionClass is a class of state pruned past its retention
+type retentionClass struct {
+	name  string
+	ttl   time.Duration
+	prune func(before time.Time) (int, error)
+}
+
+// pruneRetention removes the state of each class older than its retention
+// and returns how many entries were removed, by class
+func pruneRetention(classes []retentionClass, now time.Time) map[string]int {
+	removed := make(map[string]int)
+	for _, class := range classes {
+		if class.ttl <= 0 {
+			continue
+		}
+		n, err := class.prune(now.Add(-class.ttl))
+		if err != nil {
+			logutil.Warn("Failed to prune %s past retention: %v", class.name, err)
+		}
+		if n > 0 {
+			removed[class.name] = n
+		}
+		if class.name == "queued_signals" && n > 0 {
+			logutil.Warn("Dropped %d queued signals older than %s without shipping them", n, class.ttl)
+		}
+	}
+	return removed
+}
+
+// pruneState prunes first-seen entries, journal markers and queued signals
+// past retention every interval until ctx is cancelled, and reports what was
+// removed to the event loop, which keeps the totals
+func pruneState(ctx context.Context, db state.Store, retention config.RetentionConfig, interval time.Duration, report chan<- map[string]int) error {
+	classes := []retentionClass{
+		{"first_seen", retention.FirstSeen, db.PruneFirstSeen},
+		{"journal", retention.Journal, db.PruneJournal},
+		{"queued_signals", retention.QueuedSignals, db.PruneQueue},
+		{"triage", retention.Triage, db.PruneTriage},
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		removed := pruneRetention(classes, time.Now())
+		if len(removed) == 0 {
+			continue
+		}
+		select {
+		case report <- removed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// pruneHistory drops signal history older than retention once an hour
+func pruneHistory(ctx context.Context, db state.Store, retention time.Duration) error {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := db.PruneHistory(time.Now().Add(-retention)); err != nil {
+			logutil.Warn("Failed to prune signal history: %v", err)
+		} else if removed > 0 {
+			logutil.Debug("Pruned %d signal history entries", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func statusCommand() {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	_ = fs.Parse(os.Args[2:])
+
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := state.OpenStore(cfg.State.Backend, cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	stats, err := db.Stats()
+	if err != nil {
+		log.Fatalf("Failed to read stats: %v", err)
+	}
+
+	fmt.Printf("santamon %s\n", version)
+	fmt.Printf("Agent ID:   %s\n", cfg.Agent.ID)
+	fmt.Printf("State DB:   %s\n", cfg.State.DBPath)
+	fmt.Printf("Signals queued: %v\n", stats["signals"])
+	fmt.Printf("Signals shipped: %v\n", stats["shipped"])
+
+	encoded, _ := json.MarshalIndent(stats, "", "  ")
+	fmt.Printf("\nFull stats:\n%s\n", string(encoded))
+}
+
+func newDBFlagSet(errorHandling flag.ErrorHandling) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("db", errorHandling)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	return fs, configPath
+}
+
+func dbCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: santamon db <stats|first-seen|windows|queue|triage|compact> [--config PATH] [--json] [--rule ID] [--match TEXT] [--limit N] [--events]")
+		os.Exit(1)
+	}
+
+	subCmd := os.Args[2]
+
+	fs, configPath := newDBFlagSet(flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	ruleID := fs.String("rule", "", "Only this rule's entries (first-seen, windows, triage)")
+	match := fs.String("match", "", "Only patterns containing this text (first-seen)")
+	limit := fs.Int("limit", 50, "Maximum entries to list, 0 = all (first-seen, queue, triage)")
+	withEvents := fs.Bool("events", false, "Include the stored events (windows)")
+	_ = fs.Parse(os.Args[3:])
+
+	// Load config to get DB path (skip shipper validation for read-only ops)
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := state.OpenStore(cfg.State.Backend, cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
+	if err != nil {
+		log.Fatalf("Failed to open database (is the agent running?): %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	switch subCmd {
+	case "first-seen":
+		dbFirstSeen(db, *ruleID, *match, *limit, *asJSON)
+
+	case "windows":
+		dbWindows(db, *ruleID, *withEvents, *asJSON || *withEvents)
+
+	case "queue":
+		dbQueue(db, *limit, *asJSON)
+
+	case "triage":
+		dbTriage(db, *ruleID, *limit, *asJSON)
+
+	case "stats":
+		stats, err := db.Stats()
+		if err != nil {
+			log.Fatalf("Failed to get stats: %v", err)
+		}
+
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(data))
+
+	case "compact":
+		fmt.Println("Compacting database...")
+		if err := db.Compact(); err != nil {
+			log.Fatalf("Failed to compact: %v", err)
+		}
+		fmt.Println("Done")
+
+	default:
+		fmt.Printf("Unknown db command: %s\n", subCmd)
+		os.Exit(1)
+	}
+}
+
+// dbFirstSeenEntry is a tracked baseline pattern listed by `santamon db first-seen`
+type dbFirstSeenEntry struct {
+	RuleID    string    `json:"rule_id"`
+	Pattern   string    `json:"pattern"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+// dbFirstSeen lists the tracked first-seen patterns, most recently seen first
+func dbFirstSeen(db state.Store, ruleID, match string, limit int, asJSON bool) {
+	entries := make([]dbFirstSeenEntry, 0)
+	err := db.ForEachFirstSeen(func(kind, id string, entry state.FirstSeenEntry) error {
+		if (ruleID == "" || kind == ruleID) && strings.Contains(id, match) {
+			entries = append(entries, dbFirstSeenEntry{kind, id, entry.First, entry.Last, entry.Count})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read first-seen patterns: %v", err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastSeen.After(entries[j].LastSeen)
+	})
+	total := len(entries)
+	if limit > 0 && total > limit {
+		entries = entries[:limit]
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal first-seen patterns: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if total == 0 {
+		fmt.Println("No first-seen patterns")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tCOUNT\tFIRST SEEN\tLAST SEEN\tPATTERN")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", e.RuleID, e.Count,
+			e.FirstSeen.Local().Format(time.DateTime), e.LastSeen.Local().Format(time.DateTime), e.Pattern)
+	}
+	_ = tw.Flush()
+	if len(entries) < total {
+		fmt.Printf("\nShowing %d of %d patterns (use --limit 0 for all)\n", len(entries), total)
+	}
+}
+
+// dbWindowGroup is a correlation group listed by `santamon db windows`
+type dbWindowGroup struct {
+	RuleID    string           `json:"rule_id"`
+	GroupKey  string           `json:"group_key"`
+	Events    int              `json:"events"`
+	LastFired *time.Time       `json:"last_fired,omitempty"`
+	Stored    []map[string]any `json:"stored_events,omitempty"`
+}
+
+// dbWindows lists the correlation groups holding window events
+func dbWindows(db state.Store, ruleID string, withEvents, asJSON bool) {
+	groups := make([]*dbWindowGroup, 0)
+	err := db.WindowUsage(func(rule, groupKey string, events int) {
+		if ruleID == "" || rule == ruleID {
+			groups = append(groups, &dbWindowGroup{RuleID: rule, GroupKey: groupKey, Events: events})
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to read correlation windows: %v", err)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].RuleID != groups[j].RuleID {
+			return groups[i].RuleID < groups[j].RuleID
+		}
+		return groups[i].GroupKey < groups[j].GroupKey
+	})
+	for _, g := range groups {
+		if fired, err := db.WindowFired(g.RuleID, g.GroupKey); err == nil && !fired.IsZero() {
+			g.LastFired = &fired
+		}
+		if withEvents {
+			if g.Stored, err = db.GetWindowEvents(g.RuleID, g.GroupKey); err != nil {
+				log.Fatalf("Failed to read window events: %v", err)
+			}
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal correlation windows: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(groups) == 0 {
+		fmt.Println("No correlation window events")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tEVENTS\tLAST FIRED\tGROUP")
+	for _, g := range groups {
+		fired := "-"
+		if g.LastFired != nil {
+			fired = g.LastFired.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", g.RuleID, g.Events, fired, g.GroupKey)
+	}
+	_ = tw.Flush()
+}
+
+// dbQueue lists the signals waiting to be shipped
+func dbQueue(db state.Store, limit int, asJSON bool) {
+	queued, err := db.ListQueue(limit)
+	if err != nil {
+		log.Fatalf("Failed to list queue: %v", err)
+	}
+	if queued == nil {
+		queued = make([]*state.QueuedSignal, 0)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(queued, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal queue: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(queued) == 0 {
+		fmt.Println("Shipping queue is empty")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUED\tSIGNAL ID\tRULE\tSEVERITY\tLANE")
+	for _, sig := range queued {
+		lane := "bulk"
+		if sig.Priority {
+			lane = "priority"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			sig.QueuedAt.Local().Format(time.DateTime), sig.ID, sig.RuleID, sig.Severity, lane)
+	}
+	_ = tw.Flush()
+	if limit > 0 && len(queued) == limit {
+		fmt.Printf("\nShowing the first %d signals (use --limit 0 for all)\n", limit)
+	}
+}
+
+// dbTriage lists the signals acknowledged or closed on the backend, most
+// recently updated first
+func dbTriage(db state.Store, ruleID string, limit int, asJSON bool) {
+	records := make([]*state.TriageRecord, 0)
+	err := db.ForEachTriage(func(rec *state.TriageRecord) error {
+		if ruleID == "" || rec.RuleID == ruleID {
+			records = append(records, rec)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to list triage records: %v", err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Updated.After(records[j].Updated) })
+	total := len(records)
+	if limit > 0 && total > limit {
+		records = records[:limit]
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal triage records: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No signals have been triaged")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UPDATED\tSIGNAL ID\tRULE\tSTATUS\tBY\tNOTE")
+	for _, rec := range records {
+		var by, note string
+		if n := len(rec.Transitions); n > 0 {
+			by, note = rec.Transitions[n-1].By, rec.Transitions[n-1].Note
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			rec.Updated.Local().Format(time.DateTime), rec.SignalID, rec.RuleID, rec.Status, by, note)
+	}
+	_ = tw.Flush()
+	if len(records) < total {
+		fmt.Printf("\nShowing %d of %d signals (use --limit 0 for all)\n", len(records), total)
+	}
+}
+
+func rulesCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: santamon rules <validate|test|shadow|stats> [--config PATH]")
+		os.Exit(1)
+	}
+
+	subCmd := os.Args[2]
+	switch subCmd {
+	case "shadow":
+		rulesShadowCommand()
+		return
+	case "stats":
+		rulesStatsCommand()
+		return
+	}
+
+	// Parse config flag
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	rulesPath := fs.String("rules", "", "Rules file or directory (default: rules.path from config)")
+	eventsPath := fs.String("events", "", "Fixture file or directory of Santa events to report matches for (test)")
+	testsPath := fs.String("tests", "", "YAML file of expected-match assertions (test)")
+	_ = fs.Parse(os.Args[3:])
+
+	// rules test can run in CI without an agent config
+	if *rulesPath == "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		*rulesPath = cfg.Rules.Path
+	}
+
+	switch subCmd {
+	case "validate":
+		rulesConfig, err := rules.Load(*rulesPath)
+		if err != nil {
+			log.Fatalf("Validation failed: %v", err)
+		}
+
+		// Try to compile rules
+		engine, err := rules.NewEngine()
+		if err != nil {
+			log.Fatalf("Failed to create engine: %v", err)
+		}
+
+		if err := engine.LoadRules(rulesConfig); err != nil {
+			log.Fatalf("Failed to compile rules: %v", err)
+		}
+
+		fmt.Printf("✓ Rules validated successfully\n")
+		fmt.Printf("  %d rules\n", len(rulesConfig.Rules))
+		fmt.Printf("  %d correlations\n", len(rulesConfig.Correlations))
+		fmt.Printf("  %d baselines\n", len(rulesConfig.Baselines))
+		if packs := rulesConfig.Packs(); len(packs) > 0 {
+			fmt.Printf("  packs: %s\n", strings.Join(packs, ", "))
+		}
+
+	case "test":
+		if *eventsPath == "" && *testsPath == "" {
+			log.Fatalf("rules test requires --events or --tests")
+		}
+		rulesConfig, err := rules.Load(*rulesPath)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+		engine, err := compileRules(rulesConfig, rules.DefaultBudget, nil, nil, nil)
+		if err != nil {
+			log.Fatalf("Failed to compile rules: %v", err)
+		}
+		decode := spool.NewDecoder().DecodeEvents
+
+		if *eventsPath != "" {
+			if err := reportFixtures(engine, decode, *eventsPath); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		if *testsPath != "" {
+			if !runRuleTests(engine, decode, *testsPath) {
+				os.Exit(1)
+			}
+		}
+
+	default:
+		fmt.Printf("Unknown rules command: %s\n", subCmd)
+		os.Exit(1)
+	}
+}
+
+// rulesStatsCommand prints the running agent's fire history of each rule, including
+// loaded rules that have never fired
+func rulesStatsCommand() {
+	fs := flag.NewFlagSet("rules stats", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	asJSON := fs.Bool("json", false, "Print the fire history as JSON")
+	_ = fs.Parse(os.Args[3:])
+
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// The agent holds the state DB lock, so ask it over the admin socket
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	history, err := admin.NewClient(cfg.Agent.AdminSocket).RuleStats(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read rule fire history: %v", err)
+	}
+
+	// Rules that were loaded but never fired are listed too; without the
+	// rules, only the history is shown
+	loaded := make(map[string]bool)
+	if rulesConfig, err := rules.Load(cfg.Rules.Path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load rules, showing fire history only: %v\n", err)
+	} else {
+		for _, r := range rulesConfig.Rules {
+			loaded[r.ID] = r.Enabled
+		}
+		for _, c := range rulesConfig.Correlations {
+			loaded[c.ID] = c.Enabled
+		}
+		for _, b := range rulesConfig.Baselines {
+			loaded[b.ID] = b.Enabled
+		}
+	}
+	fired := make(map[string]bool, len(history))
+	for _, f := range history {
+		fired[f.RuleID] = true
+	}
+	for id, enabled := range loaded {
+		if enabled && !fired[id] {
+			history = append(history, &state.RuleFires{RuleID: id})
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].Count != history[j].Count {
+			return history[i].Count > history[j].Count
+		}
+		return history[i].RuleID < history[j].RuleID
+	})
+
+	if *asJSON {
+		data, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal rule fire history: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(history) == 0 {
+		fmt.Println("No rule fire history")
+		return
+	}
+
+	unloaded := false
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tFIRES\tFIRST FIRED\tLAST FIRED")
+	for _, f := range history {
+		id := f.RuleID
+		if len(loaded) > 0 && !loaded[id] {
+			id += " *"
+			unloaded = true
+		}
+		first, last := "-", "-"
+		if f.Count > 0 {
+			first = f.First.Local().Format(time.DateTime)
+			last = f.Last.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", id, f.Count, first, last)
+	}
+	_ = tw.Flush()
+	if unloaded {
+		fmt.Println("\n* not enabled in the current rules")
+	}
+}
+
+// rulesShadowCommand prints the running agent's comparison of the shadow
+// rules with the active rules
+func rulesShadowCommand() {
+	fs := flag.NewFlagSet("rules shadow", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	all := fs.Bool("all", false, "List rules the shadow and active rules agree on too")
+	asJSON := fs.Bool("json", false, "Print the report as JSON, including shadow-only sample signals")
+	_ = fs.Parse(os.Args[3:])
+
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report, err := admin.NewClient(cfg.Agent.AdminSocket).Shadow(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get shadow report: %v", err)
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal shadow report: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Shadow rules %s vs active rules %s\n", report.ShadowVersion, report.ActiveVersion)
+	fmt.Printf("%d events compared since %s\n\n", report.Events, report.Since.Local().Format(time.DateTime))
+
+	changed := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tBOTH\tACTIVE ONLY\tSHADOW ONLY")
+	for _, d := range report.Rules {
+		if d.Changed() {
+			changed++
+		} else if !*all {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", d.RuleID, d.Both, d.ActiveOnly, d.ShadowOnly)
+	}
+	_ = tw.Flush()
+	if changed == 0 {
+		fmt.Println("\nNo differences: the shadow rules alerted exactly like the active rules")
+		return
+	}
+
+	for _, d := range report.Rules {
+		if len(d.Samples) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s would also alert on (latest %d):\n", d.RuleID, len(d.Samples))
+		for _, sig := range d.Samples {
+			fmt.Printf("  %s  %s\n", sig.TS.Local().Format(time.DateTime), formatSignalContext(sig.Context))
+		}
+	}
+}
+
+// schemaCommand prints the JSON Schema of the signal payload, including the
+// context fields enabled by the config and rules
+func schemaCommand() {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	rulesPath := fs.String("rules", "", "Rules file or directory (default: rules.path from config)")
+	_ = fs.Parse(os.Args[2:])
+
+	// With only --rules, config-dependent enrichments are left out
+	useConfig := true
+	if *rulesPath != "" {
+		useConfig = false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				useConfig = true
+			}
+		})
+	}
+
+	opts := signals.SchemaOptions{Version: version}
+	if useConfig {
+		cfg, err := config.LoadForReadOnly(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		if *rulesPath == "" {
+			*rulesPath = cfg.Rules.Path
+		}
+		opts.Identity = cfg.Identity.Provider != ""
+		opts.SpoolArchive = cfg.Santa.ArchiveDir != ""
+		opts.Dedup = cfg.State.Dedup.Cooldown > 0
+		opts.FleetFirstSeen = cfg.State.FirstSeen.Fleet.Enabled
+		opts.Learning = cfg.State.FirstSeen.Learning.Summary
+		opts.Intel = len(cfg.Intel.Feeds) > 0
+		opts.Reputation = cfg.Reputation.Provider != ""
+		opts.YARA = cfg.YARA.Enabled
+		opts.SantaRules = cfg.SantaRules.Enabled
+		opts.Response = cfg.Response.Enabled
+	}
+
+	rulesConfig, err := rules.Load(*rulesPath)
+	if err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
+	}
+	opts.Rules = rulesConfig
+
+	data, err := json.MarshalIndent(signals.Schema(opts), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal schema: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+// genEventsCommand writes synthetic Santa spool files
+func genEventsCommand() {
+	fs := flag.NewFlagSet("gen-events", flag.ExitOnError)
+	out := fs.String("out", "", "Spool directory to write to (required)")
+	kind := fs.String("kind", "all", "Comma-separated event kinds, or all ("+strings.Join(simulate.Kinds(), ", ")+")")
+	count := fs.Int("count", 100, "Number of events")
+	denyRate := fs.Float64("deny-rate", 0.1, "Share of executions and file accesses denied")
+	perFile := fs.Int("per-file", 100, "Events per spool file")
+	interval := fs.Duration("interval", 100*time.Millisecond, "Time between event timestamps")
+	seed := fs.Uint64("seed", 0, "Random seed for reproducible output (0 = random)")
+	_ = fs.Parse(os.Args[2:])
+
+	if *out == "" {
+		log.Fatalf("gen-events requires --out")
+	}
+	var kinds []string
+	if *kind != "all" {
+		for k := range strings.SplitSeq(*kind, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+
+	gen, err := simulate.New(simulate.Options{
+		Kinds:    kinds,
+		Count:    *count,
+		DenyRate: *denyRate,
+		Interval: *interval,
+		Seed:     *seed,
+	})
+	if err != nil {
+		log.Fatalf("Invalid options: %v", err)
+	}
+	paths, err := simulate.WriteSpool(*out, gen.Generate(), *perFile)
+	if err != nil {
+		log.Fatalf("Failed to write spool files: %v", err)
+	}
+	fmt.Printf("Wrote %d events to %d spool files in %s (seed %d)\n", *count, len(paths), *out, *seed)
+}
+
+// validateCommand checks the config and rules and exits non-zero on any
+// problem, for use as a CI gate
+func validateCommand() {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	rulesPath := fs.String("rules", "", "Rules file or directory (default: rules.path from config)")
+	skipShipper := fs.Bool("skip-shipper", false, "Skip shipper checks (e.g. when SANTAMON_API_KEY is not set)")
+	noLint := fs.Bool("no-lint", false, "Skip static analysis of rule expressions")
+	_ = fs.Parse(os.Args[2:])
+
+	// With only --rules, check the rules on their own
+	checkConfig := true
+	if *rulesPath != "" {
+		checkConfig = false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				checkConfig = true
+			}
+		})
+	}
+
+	var problems []error
+	var suppressions *rules.Suppressions
+	if checkConfig {
+		cfg, err := config.LoadWithOptions(*configPath, *skipShipper)
+		if err != nil {
+			fmt.Printf("✗ Config %s: %v\n", *configPath, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Config %s\n", *configPath)
+		if *rulesPath == "" {
+			*rulesPath = cfg.Rules.Path
+		}
+		if suppressions, err = loadSuppressions(cfg); err != nil {
+			fmt.Printf("✗ Suppressions %s: %v\n", cfg.Rules.Suppressions, err)
+			os.Exit(1)
+		}
+		if _, err := newSinks(cfg.Shipper.Sinks); err != nil {
+			fmt.Printf("✗ Config %s: %v\n", *configPath, err)
+			os.Exit(1)
+		}
+	}
+
+	rulesConfig, err := rules.Load(*rulesPath)
+	if err != nil {
+		fmt.Printf("✗ Rules %s: %v\n", *rulesPath, err)
+		os.Exit(1)
+	}
+	engine, err := rules.NewEngine()
+	if err != nil {
+		log.Fatalf("Failed to create engine: %v", err)
+	}
+	engine.SetSuppressions(suppressions)
+	problems = append(problems, engine.CheckExpressions(rulesConfig)...)
+	problems = append(problems, rules.CheckFields(rulesConfig)...)
+	for _, id := range suppressions.UnknownRules(rulesConfig) {
+		problems = append(problems, fmt.Errorf("suppressions for unknown rule %s", id))
+	}
+	if !*noLint {
+		problems = append(problems, engine.Lint(rulesConfig)...)
+		problems = append(problems, engine.CheckContext(rulesConfig)...)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("✗ Rules %s: %d problem(s)\n", *rulesPath, len(problems))
+		for _, p := range problems {
+			fmt.Printf("  %v\n", p)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Rules %s\n", *rulesPath)
+	fmt.Printf("  %d rules, %d correlations, %d baselines\n",
+		len(rulesConfig.Rules), len(rulesConfig.Correlations), len(rulesConfig.Baselines))
+}
+
+// reportFixtures prints which simple rules fire for each fixture file
+func reportFixtures(engine *rules.Engine, decode ruletest.DecodeFunc, path string) error {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to read fixtures: %w", err)
+	} else if info.IsDir() {
+		if files, err = ruletest.Files(path); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		fixture, err := ruletest.Evaluate(engine, decode, file)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s (%d events)\n", file, fixture.Events)
+		if fixture.EvalErrors > 0 {
+			fmt.Printf("  ⚠ %d rule evaluation errors\n", fixture.EvalErrors)
+		}
+		ids := fixture.RuleIDs()
+		if len(ids) == 0 {
+			fmt.Println("  no rules fired")
+		}
+		for _, id := range ids {
+			fmt.Printf("  %-30s %d events\n", id, fixture.Fired[id])
+		}
+	}
+	return nil
+}
+
+// runRuleTests runs a test suite and reports whether every case passed
+func runRuleTests(engine *rules.Engine, decode ruletest.DecodeFunc, path string) bool {
+	suite, err := ruletest.LoadSuite(path)
+	if err != nil {
+		log.Fatalf("Failed to load tests: %v", err)
+	}
+
+	failed := 0
+	for _, result := range suite.Run(engine, decode) {
+		if result.Passed() {
+			fmt.Printf("✓ %s\n", result.Case.Name)
+			continue
+		}
+		failed++
+		fmt.Printf("✗ %s\n", result.Case.Name)
+		if result.Err != nil {
+			fmt.Printf("    %v\n", result.Err)
+			continue
+		}
+		for _, failure := range result.Failures {
+			fmt.Printf("    %s\n", failure)
+		}
+		if ids := result.Fixture.RuleIDs(); len(ids) > 0 {
+			fmt.Printf("    fired: %s\n", strings.Join(ids, ", "))
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d failed\n", len(suite.Tests)-failed, failed)
+	return failed == 0
+}
+
+// replayCommand runs historical spool files through the detection pipeline
+// (simple, correlation and baseline rules) to back-test rules. It uses a
+// throwaway state DB so the agent's windows, baselines and queue are never
+// touched, and measures correlation windows and baseline learning periods in
+// event time.
+func replayCommand() {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	rulesPath := fs.String("rules", "", "Rules file or directory (default: rules.path from config)")
+	dryRun := fs.Bool("dry-run", false, "Print signals as JSON lines instead of shipping them")
+	_ = fs.Parse(os.Args[2:])
+
+	// Shipper settings are only needed when signals are shipped
+	load := config.Load
+	if *dryRun {
+		load = config.LoadForReadOnly
+	}
+	cfg, err := load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *rulesPath == "" {
+		*rulesPath = cfg.Rules.Path
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		if cfg.Santa.ArchiveDir == "" {
+			log.Fatalf("No files to replay: pass paths or set santa.archive_dir")
+		}
+		paths = []string{cfg.Santa.ArchiveDir}
+	}
+	files, err := replayFiles(paths)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(files) == 0 {
+		log.Fatalf("No spool files found in %s", strings.Join(paths, ", "))
+	}
+
+	rulesConfig, err := rules.Load(*rulesPath)
+	if err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
+	}
+	suppressions, err := loadSuppressions(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load suppressions: %v", err)
+	}
+	engine, err := compileRules(rulesConfig, ruleBudget(cfg), ruleHost(cfg), ruleOverrides(cfg), suppressions)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "santamon-replay")
+	if err != nil {
+		log.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	db, err := state.Open(filepath.Join(tmpDir, "state.db"), cfg.State.FirstSeen.MaxEntries, false)
+	if err != nil {
+		log.Fatalf("Failed to open replay database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	windowMgr := correlation.NewWindowManager(db, cfg.State.Windows.MaxEvents, cfg.State.Windows.GCInterval)
+	windowMgr.SetCaps(cfg.State.Windows.MaxRuleEvents, cfg.State.Windows.MaxTotalEvents)
+	windowMgr.UseEventTime()
+	if cfg.State.Windows.CrossHost {
+		windowMgr.CrossHost()
+	}
+	baselineProc := baseline.NewProcessor(db)
+	baselineProc.UseEventTime()
+
+	var lineageStore *lineage.Store
+	if rulesConfig.NeedsLineage() {
+		lineageStore = newLineageStore(cfg, db)
+	}
+	windowMgr.SetLineage(lineageStore)
+	engine.SetAncestry(lineageStore)
+	idProvider, err := newIdentityProvider(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create identity provider: %v", err)
+	}
+	intelStore := loadIntel(cfg)
+	engine.SetIntel(intelStore)
+	sigGen := signals.NewGenerator(cfg.Agent.ID, lineageStore)
+	sigGen.SetIdentityProvider(idProvider)
+	sigGen.SetIntel(intelStore)
+	yaraScanner, err := newYARA(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create YARA scanner: %v", err)
+	}
+	// Replay waits for lookups and scans and adds their results to the
+	// signal itself
+	followUps := signals.NewFollowUps(0, nil)
+	followUps.SetReputation(newReputation(cfg))
+	followUps.SetYARA(yaraScanner, cfg.YARA.MinSeverity)
+	sessions := session.NewTracker()
+	sigGen.SetSessions(sessions)
+	sigGen.SetRulesVersion(engine.Version())
+	allow, err := loadAllowlist(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load allowlist: %v", err)
+	}
+	allowAll := cfg.Rules.Allowlist.Scope == "all"
+
+	var ndjson *signals.NDJSONWriter
+	var ship *shipper.Shipper
+	if *dryRun {
+		ndjson = signals.NewNDJSONWriter(os.Stdout)
+	} else {
+		ship = shipper.NewShipper(&cfg.Shipper, db, cfg.Agent.ID, version)
+		ship.SetRulesVersion(engine.Version())
+	}
+
+	decoder := spool.NewDecoder()
+	eventCount, signalCount, learningCount, allowlistedCount, dedupCount, aggregatedCount := 0, 0, 0, 0, 0, 0
+	aggregator := aggregate.New(db, cfg.State.Aggregate.Interval)
+	var deduper *dedup.Deduper
+	if cfg.State.Dedup.Cooldown > 0 {
+		deduper = dedup.New(db, cfg.State.Dedup.Cooldown)
+	}
+	emit := func(signal *state.Signal, file string) {
+		sigGen.EnrichSignal(signal, map[string]any{"replayed_from": file})
+		signalCount++
+		if ship == nil {
+			writeNDJSON(ndjson, signal)
+			return
+		}
+		if err := ship.EnqueueSignal(signal); err != nil {
+			log.Printf("Failed to enqueue signal: %v", err)
+		}
+	}
+
+	// Files are streamed, so large archives replay in constant memory
+	learningStarted := false
+	for _, file := range files {
+		for msg, err := range decoder.DecodeEventsStream(context.Background(), file) {
+			if err != nil {
+				log.Printf("Skipping rest of %s: %v", file, err)
+				break
+			}
+			// Baseline learning starts at the first replayed event
+			if !learningStarted {
+				if start := events.EventTime(msg); !start.IsZero() {
+					engine.SetStartTime(start)
+					learningStarted = true
+				}
+			}
+			eventCount++
+			evt := rules.NewEvent(msg)
+			sessions.Observe(msg)
+			if lineageStore != nil {
+				if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
+					lineageStore.UpsertFromExecution(msg, ev.Execution)
+				}
+			}
+
+			_, allowed := allow.Match(msg)
+			if allowed {
+				allowlistedCount++
+				if allowAll {
+					continue
+				}
+			} else {
+				matches, err := engine.EvaluateEvent(evt)
+				if err != nil {
+					log.Printf("Rule evaluation error: %v", err)
+					continue
+				}
+				for _, match := range matches {
+					signal := sigGen.FromRuleMatch(match)
+					followUps.Enrich(context.Background(), signal, match.Message)
+					if hash := events.TargetSHA256(match.Message); hash != "" {
+						if isFirst, err := db.IsFirstSeen("sha256", hash); err == nil && isFirst {
+							sigGen.EnrichSignal(signal, map[string]any{"first_seen": true})
+						}
+					}
+					if match.Rule != nil && match.Rule.Aggregate {
+						sigGen.EnrichSignal(signal, map[string]any{"replayed_from": file})
+						if err := aggregator.Add(signal, aggregate.Target(match.Message), signal.TS); err != nil {
+							log.Printf("Failed to aggregate signal: %v", err)
+						}
+						aggregatedCount++
+						continue
+					}
+					if deduper != nil {
+						sigGen.EnrichSignal(signal, map[string]any{"replayed_from": file})
+						emitted, summary, err := deduper.Check(dedup.Key(match.RuleID, match.Message), signal, signal.TS)
+						if err != nil {
+							log.Printf("Failed to deduplicate signal: %v", err)
+						}
+						if summary != nil {
+							emit(summary, file)
+						}
+						if !emitted {
+							dedupCount++
+							continue
+						}
+					}
+					emit(signal, file)
+				}
+			}
+
+			if correlations := engine.GetCorrelations(); len(correlations) > 0 {
+				windowMatches, err := windowMgr.ProcessEvent(evt, correlations)
+				if err != nil {
+					log.Printf("Correlation processing error: %v", err)
+					continue
+				}
+				// Absence windows end in event time too
+				if ts := events.EventTime(msg); !ts.IsZero() {
+					expired, err := windowMgr.Expire(ts, correlations)
+					if err != nil {
+						log.Printf("Absence correlation processing error: %v", err)
+					}
+					windowMatches = append(windowMatches, expired...)
+				}
+				for _, wmatch := range windowMatches {
+					emit(sigGen.FromWindowMatch(wmatch, msg.GetBootSessionUuid()), file)
+				}
+			}
+
+			if baselines := engine.GetBaselines(); len(baselines) > 0 {
+				baselineMatches, err := baselineProc.ProcessEvent(evt, baselines, engine)
+				if err != nil {
+					log.Printf("Baseline processing error: %v", err)
+					continue
+				}
+				for _, bmatch := range baselineMatches {
+					if bmatch.InLearning {
+						learningCount++
+						continue
+					}
+					signal := sigGen.FromBaselineMatch(bmatch)
+					followUps.Enrich(context.Background(), signal, bmatch.Message)
+					emit(signal, file)
+				}
+			}
+		}
+	}
+
+	// Emit the remaining rollups: replay has no later events to end them
+	rollups, err := aggregator.Flush(time.Now().Add(cfg.State.Aggregate.Interval))
+	if err != nil {
+		log.Printf("Failed to flush aggregated signals: %v", err)
+	}
+	for _, rollup := range rollups {
+		sample, _ := rollup.Context["sample"].(map[string]any)
+		from, _ := sample["replayed_from"].(string)
+		emit(rollup, from)
+	}
+
+	// Close the remaining dedup windows: replay has no later events to end them
+	if deduper != nil {
+		summaries, err := deduper.Flush(time.Now().Add(cfg.State.Dedup.Cooldown))
+		if err != nil {
+			log.Printf("Failed to flush deduplicated signals: %v", err)
+		}
+		for _, summary := range summaries {
+			from, _ := summary.Context["replayed_from"].(string)
+			emit(summary, from)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Replayed %d events from %d files with rules version %s: %d signals",
+		eventCount, len(files), engine.Version(), signalCount)
+	if learningCount > 0 {
+		fmt.Fprintf(os.Stderr, " (%d baseline matches suppressed during learning)", learningCount)
+	}
+	fmt.Fprintln(os.Stderr)
+	if allowlistedCount > 0 {
+		fmt.Fprintf(os.Stderr, "%d allowlisted executions skipped rule evaluation\n", allowlistedCount)
+	}
+	if dedupCount > 0 {
+		fmt.Fprintf(os.Stderr, "%d repeated signals collapsed into dedup summaries\n", dedupCount)
+	}
+	if aggregatedCount > 0 {
+		fmt.Fprintf(os.Stderr, "%d aggregate rule matches rolled up into %d signals\n", aggregatedCount, len(rollups))
+	}
+	if errs := engine.EvalErrors(); errs > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ %d rule evaluation errors\n", errs)
+	}
+	if disabled := engine.DisabledRules(); len(disabled) > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ Rules disabled for exceeding their evaluation budget: %s\n", strings.Join(disabled, ", "))
+	}
+
+	if ship != nil && signalCount > 0 {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		shipped, err := ship.Flush(ctx)
+		remaining, _ := db.ListQueue(0)
+		fmt.Fprintf(os.Stderr, "Shipped %d signals (%d not shipped)\n", shipped, len(remaining))
+		if err != nil {
+			log.Fatalf("Shipping failed: %v", err)
+		}
+	}
+}
+
+// searchResult is one matching event printed by santamon search --json
+type searchResult struct {
+	TS    time.Time      `json:"ts"`
+	Kind  string         `json:"kind"`
+	File  string         `json:"file"`
+	Event map[string]any `json:"event"` // Protojson shape, as matched by rule expressions
+}
+
+func searchCommand() {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	since := fs.String("since", "", "Only events at or after this time: duration back from now (24h), RFC 3339 timestamp or date")
+	until := fs.String("until", "", "Only events before this time (same formats as --since)")
+	limit := fs.Int("limit", 100, "Stop after this many matching events (0 = all)")
+	asJSON := fs.Bool("json", false, "Print one JSON event per line instead of a table")
+	_ = fs.Parse(os.Args[2:])
+
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: santamon search [--since TIME] [--until TIME] [--limit N] [--json] [--config PATH] EXPR [PATH...]")
+		os.Exit(1)
+	}
+	if *limit < 0 {
+		log.Fatalf("--limit cannot be negative")
+	}
+	now := time.Now()
+	opts := search.Options{Limit: *limit}
+	var err error
+	if opts.Since, err = search.ParseTime(*since, now); err != nil {
+		log.Fatalf("Invalid --since: %v", err)
+	}
+	if opts.Until, err = search.ParseTime(*until, now); err != nil {
+		log.Fatalf("Invalid --until: %v", err)
+	}
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && !opts.Until.After(opts.Since) {
+		log.Fatalf("--until must be after --since")
+	}
+
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	paths := fs.Args()[1:]
+	if len(paths) == 0 {
+		paths = []string{cfg.Santa.ArchiveDir}
+	}
+	files, err := search.Files(paths, opts.Since)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Expressions use the rule environment, with the configured rules' lists
+	engine, err := rules.NewEngine()
+	if err != nil {
+		log.Fatalf("Failed to create rules engine: %v", err)
+	}
+	engine.SetBudget(ruleBudget(cfg))
+	if rulesConfig, err := rules.Load(cfg.Rules.Path); err == nil {
+		engine, err = compileRules(rulesConfig, ruleBudget(cfg), nil, nil, nil)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	filter, err := engine.CompileFilter(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Invalid expression: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var enc *json.Encoder
+	var tw *tabwriter.Writer
+	if *asJSON {
+		enc = json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+	} else {
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tKIND\tACTOR\tTARGET")
+	}
+	decoder := spool.NewDecoder()
+	stats, err := search.Run(ctx, files, decoder.DecodeEventsStream, filter, opts, func(m search.Match) error {
+		ts := events.EventTime(m.Message)
+		if enc != nil {
+			event, err := events.ToMap(m.Message)
+			if err != nil {
+				return fmt.Errorf("failed to convert event: %w", err)
+			}
+			return enc.Encode(searchResult{TS: ts, Kind: events.Kind(m.Message), File: m.File, Event: event})
+		}
+		when := "-"
+		if !ts.IsZero() {
+			when = ts.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", when, events.Kind(m.Message),
+			shortenPath(events.ActorPath(m.Message)), shortenPath(events.TargetPath(m.Message)))
+		return nil
+	})
+	if tw != nil {
+		_ = tw.Flush()
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Search failed: %v", err)
+	}
+
+	// The summary goes to stderr so --json output stays clean
+	fmt.Fprintf(os.Stderr, "\n%d of %d events in range matched, from %d files\n", stats.Matches, stats.Events, stats.Files)
+	if stats.Truncated {
+		fmt.Fprintf(os.Stderr, "Stopped at --limit %d (use --limit 0 for all)\n", *limit)
+	}
+	if stats.EvalErrors > 0 {
+		fmt.Fprintf(os.Stderr, "The expression failed on %d events (e.g. fields missing from their kind)\n", stats.EvalErrors)
+	}
+	if stats.FileErrors > 0 {
+		fmt.Fprintf(os.Stderr, "%d files could not be fully decoded\n", stats.FileErrors)
+	}
+}
+
+func benchCommand() {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	rulesPath := fs.String("rules", "", "Rules file or directory (default: rules.path from config)")
+	passes := fs.Int("passes", 1, "Times to evaluate the corpus")
+	top := fs.Int("top", 20, "Slowest rules to list (0 = all)")
+	maxEvents := fs.Int("max-events", 100000, "Maximum events to load from the corpus (0 = all)")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(os.Args[2:])
+
+	if *passes < 1 {
+		log.Fatalf("--passes must be at least 1")
+	}
+	if *top < 0 || *maxEvents < 0 {
+		log.Fatalf("--top and --max-events cannot be negative")
+	}
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *rulesPath == "" {
+		*rulesPath = cfg.Rules.Path
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		if cfg.Santa.ArchiveDir == "" {
+			log.Fatalf("No corpus to benchmark: pass paths or set santa.archive_dir")
+		}
+		paths = []string{cfg.Santa.ArchiveDir}
+	}
+	files, err := replayFiles(paths)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(files) == 0 {
+		log.Fatalf("No spool files found in %s", strings.Join(paths, ", "))
+	}
+
+	rulesConfig, err := rules.Load(*rulesPath)
+	if err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
+	}
+	suppressions, err := loadSuppressions(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load suppressions: %v", err)
+	}
+	engine, err := compileRules(rulesConfig, ruleBudget(cfg), ruleHost(cfg), ruleOverrides(cfg), suppressions)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Correlation and baseline rules record state, so they get a scratch DB
+	tmpDir, err := os.MkdirTemp("", "santamon-bench")
+	if err != nil {
+		log.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	db, err := state.Open(filepath.Join(tmpDir, "state.db"), cfg.State.FirstSeen.MaxEntries, false)
+	if err != nil {
+		log.Fatalf("Failed to open bench database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	windowMgr := correlation.NewWindowManager(db, cfg.State.Windows.MaxEvents, cfg.State.Windows.GCInterval)
+	windowMgr.SetCaps(cfg.State.Windows.MaxRuleEvents, cfg.State.Windows.MaxTotalEvents)
+	windowMgr.UseEventTime()
+	baselineProc := baseline.NewProcessor(db)
+	baselineProc.UseEventTime()
+
+	var lineageStore *lineage.Store
+	if rulesConfig.NeedsLineage() {
+		lineageStore = newLineageStore(cfg, db)
+	}
+	windowMgr.SetLineage(lineageStore)
+	engine.SetAncestry(lineageStore)
+	engine.SetIntel(loadIntel(cfg))
+
+	// The corpus is loaded up front so decoding is not timed
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	decoder := spool.NewDecoder()
+	var corpus []*rules.Event
+	truncated := false
+load:
+	for _, file := range files {
+		for msg, err := range decoder.DecodeEventsStream(ctx, file) {
+			if err != nil {
+				if ctx.Err() != nil {
+					return
 				}
-				// Update journal even on error to avoid reprocessing
-				if err := db.UpdateJournal(filePath, 0); err != nil {
-					log.Printf("Warning: Failed to update journal: %v", err)
+				log.Printf("Skipping rest of %s: %v", file, err)
+				break
+			}
+			if *maxEvents > 0 && len(corpus) >= *maxEvents {
+				truncated = true
+				break load
+			}
+			if len(corpus) == 0 {
+				if start := events.EventTime(msg); !start.IsZero() {
+					engine.SetStartTime(start)
 				}
-				continue
 			}
-
-			// Process each event
-			for _, msg := range messages {
-				eventCount++
-
-				// Update process lineage store for execution events, when enabled
-				if lineageStore != nil {
-					if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
-						lineageStore.UpsertFromExecution(msg, ev.Execution)
-					}
+			if lineageStore != nil {
+				if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
+					lineageStore.UpsertFromExecution(msg, ev.Execution)
 				}
+			}
+			corpus = append(corpus, rules.NewEvent(msg))
+		}
+	}
+	if len(corpus) == 0 {
+		log.Fatalf("No events found in %s", strings.Join(paths, ", "))
+	}
 
-				// Evaluate simple rules
-				matches, err := engine.Evaluate(msg)
-				if err != nil {
-					log.Printf("Rule evaluation error: %v", err)
-					continue
-				}
+	runner := &bench.Runner{Engine: engine, Windows: windowMgr, Baselines: baselineProc}
+	report := runner.Run(corpus, *passes)
+	if *top > 0 && len(report.Rules) > *top {
+		report.Rules = report.Rules[:*top]
+	}
 
-				// Process simple rule matches
-				for _, match := range matches {
-					signal := sigGen.FromRuleMatch(match)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "RULE\tTYPE\tAVG\tTOTAL\tEVALS\tMATCHES")
+		for _, res := range report.Rules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\n", res.RuleID, res.Type,
+				res.Average(), res.Total.Round(time.Microsecond), res.Evaluations, res.Matches)
+		}
+		_ = tw.Flush()
+	}
 
-					// Check if this is the first time we've seen this artifact
-					if hash := events.TargetSHA256(match.Message); hash != "" {
-						isFirst, err := db.IsFirstSeen("sha256", hash)
-						if err != nil {
-							log.Printf("Warning: Failed to check first seen: %v", err)
-						} else if isFirst {
-							sigGen.EnrichSignal(signal, map[string]any{
-								"first_seen": true,
-							})
-						}
-					}
+	evaluated := report.Events * report.Passes
+	rate := float64(evaluated) / max(report.Duration.Seconds(), 1e-9)
+	fmt.Fprintf(os.Stderr, "\nEvaluated %d events from %d files %d times in %s (%.0f events/s) with rules version %s\n",
+		report.Events, len(files), report.Passes, report.Duration.Round(time.Millisecond), rate, engine.Version())
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Stopped loading at --max-events %d (use --max-events 0 for all)\n", *maxEvents)
+	}
+	if errs := engine.EvalErrors(); errs > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ %d rule evaluation errors\n", errs)
+	}
+	if disabled := engine.DisabledRules(); len(disabled) > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ Rules disabled for exceeding their evaluation budget: %s\n", strings.Join(disabled, ", "))
+	}
+}
 
-					sigGen.EnrichSignal(signal, spoolContext)
-					fileHasSignals = true
+func packCommand() {
+	if len(os.Args) < 3 || !slices.Contains([]string{"add", "update", "remove", "list", "verify"}, os.Args[2]) {
+		fmt.Println("Usage: santamon pack <add|update|remove|list|verify> [options] [NAME] [SOURCE]")
+		os.Exit(1)
+	}
+	subCmd := os.Args[2]
 
-					if err := ship.EnqueueSignal(signal); err != nil {
-						logutil.Error("Failed to enqueue signal: %v", err)
-					} else {
-						signalCount++
-						// Format context for display
-						ctx := formatSignalContext(signal.Context)
-						logutil.Signal("rule", signal.RuleID, signal.Severity, signal.Title, ctx)
-					}
-				}
+	fs := flag.NewFlagSet("pack "+subCmd, flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	rulesDir := fs.String("rules", "", "Rules directory (default: rules.path from config)")
+	ref := fs.String("ref", "", "add, update: git tag, branch or commit, or the version substituted for {ref} in an HTTP URL")
+	path := fs.String("path", "", "add: subdirectory of the repository or archive holding the rules")
+	sum := fs.String("sha256", "", "add, update: expected SHA-256 of the HTTP download (git: of the installed rules)")
+	_ = fs.Parse(os.Args[3:])
 
-				// Evaluate correlation rules
-				correlations := engine.GetCorrelations()
-				if len(correlations) > 0 {
-					windowMatches, err := windowMgr.Process(msg, correlations)
-					if err != nil {
-						log.Printf("Correlation processing error: %v", err)
-						continue
-					}
-					for _, wmatch := range windowMatches {
-						signal := sigGen.FromWindowMatch(wmatch, msg.GetBootSessionUuid())
-						sigGen.EnrichSignal(signal, spoolContext)
-						fileHasSignals = true
-						if err := ship.EnqueueSignal(signal); err != nil {
-							logutil.Error("Failed to enqueue correlation signal: %v", err)
-						} else {
-							signalCount++
-							// Format context for correlation signals
-							ctx := fmt.Sprintf("correlation=%d events %s", wmatch.Count, formatSignalContext(signal.Context))
-							logutil.Signal("correlation", signal.RuleID, signal.Severity, signal.Title, ctx)
-						}
-					}
-				}
+	dir := *rulesDir
+	if dir == "" {
+		cfg, err := config.LoadForReadOnly(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		dir = cfg.Rules.Path
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		log.Fatalf("Rule packs are installed into a rules directory; %s is not one", dir)
+	}
 
-				// Evaluate baseline rules
-				baselines := engine.GetBaselines()
-				if len(baselines) > 0 {
-					baselineMatches, err := baselineProc.Process(msg, baselines, engine)
-					if err != nil {
-						logutil.Error("Baseline processing error: %v", err)
-						continue
-					}
-					for _, bmatch := range baselineMatches {
-						// Skip alerts during learning period if configured
-						if bmatch.InLearning {
-							// Show learning mode signals with INFO severity
-							ctx := formatBaselinePattern(bmatch.Pattern)
-							logutil.Signal("baseline", bmatch.RuleID, "info", bmatch.Title+" (learning)", ctx)
-							continue
-						}
+	m := rulepack.NewManager(dir)
+	m.Validate = func(packDir string) error {
+		rc, err := rules.LoadRulesDir(packDir)
+		if err != nil {
+			return err
+		}
+		_, err = compileRules(rc, rules.DefaultBudget, nil, nil, nil)
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-						signal := sigGen.FromBaselineMatch(bmatch)
-						sigGen.EnrichSignal(signal, spoolContext)
-						fileHasSignals = true
-						if err := ship.EnqueueSignal(signal); err != nil {
-							logutil.Error("Failed to enqueue baseline signal: %v", err)
-						} else {
-							signalCount++
-							ctx := formatBaselinePattern(bmatch.Pattern)
-							logutil.Signal("baseline", signal.RuleID, signal.Severity, signal.Title, ctx)
-						}
-					}
-				}
-			}
+	switch subCmd {
+	case "add":
+		if fs.NArg() != 2 {
+			log.Fatalf("Usage: santamon pack add [--ref REF] [--path DIR] [--sha256 HEX] NAME SOURCE")
+		}
+		name := fs.Arg(0)
+		entry, err := m.Add(ctx, name, rulepack.Source{URL: fs.Arg(1), Ref: *ref, Path: *path}, *sum)
+		if err != nil {
+			log.Fatalf("Failed to add pack: %v", err)
+		}
+		fmt.Printf("✓ Installed pack %s %s into %s\n", name, packVersion(entry), filepath.Join(dir, name))
+		checkPackRules(dir)
 
-			// Update journal after successful processing
-			if err := db.UpdateJournal(filePath, 0); err != nil {
-				log.Printf("Warning: Failed to update journal: %v", err)
+	case "update":
+		lock, err := m.Lock()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		names := fs.Args()
+		if len(names) == 0 {
+			names = slices.Sorted(maps.Keys(lock.Packs))
+		}
+		if (*ref != "" || *sum != "") && len(names) != 1 {
+			log.Fatalf("--ref and --sha256 need a single pack name")
+		}
+		failed, changed := false, false
+		for _, name := range names {
+			entry, updated, err := m.Update(ctx, name, *ref, *sum)
+			switch {
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "✗ %s: %v\n", name, err)
+				failed = true
+			case updated:
+				fmt.Printf("✓ Updated pack %s to %s\n", name, packVersion(entry))
+				changed = true
+			default:
+				fmt.Printf("  Pack %s is up to date (%s)\n", name, packVersion(entry))
 			}
+		}
+		if changed {
+			checkPackRules(dir)
+		}
+		if failed {
+			os.Exit(1)
+		}
 
-			// Delete processed files with no signals, archive files that produced alerts
-			if fileHasSignals {
-				if err := watcher.ArchiveFile(filePath); err != nil {
-					log.Printf("Warning: Failed to archive spool file %s: %v", filePath, err)
-				} else if os.Getenv("SANTAMON_DEBUG") == "1" && spoolArchivePath != "" {
-					log.Printf("Archived spool file %s to %s", filePath, spoolArchivePath)
-				}
-			} else {
-				if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-					log.Printf("Warning: Failed to delete spool file %s: %v", filePath, err)
-				} else if os.Getenv("SANTAMON_DEBUG") == "1" {
-					log.Printf("Deleted spool file %s (no signals)", filePath)
-				}
+	case "remove":
+		if fs.NArg() != 1 {
+			log.Fatalf("Usage: santamon pack remove NAME")
+		}
+		if err := m.Remove(fs.Arg(0)); err != nil {
+			log.Fatalf("Failed to remove pack: %v", err)
+		}
+		fmt.Printf("✓ Removed pack %s\n", fs.Arg(0))
+		checkPackRules(dir)
+
+	case "list", "verify":
+		lock, err := m.Lock()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if len(lock.Packs) == 0 {
+			fmt.Printf("No packs installed in %s\n", dir)
+			return
+		}
+		failed := false
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PACK\tVERSION\tSOURCE\tINSTALLED\tSTATUS")
+		for _, name := range slices.Sorted(maps.Keys(lock.Packs)) {
+			entry := lock.Packs[name]
+			status := "ok"
+			if err := m.Verify(name, entry); errors.Is(err, os.ErrNotExist) {
+				status, failed = "missing", true
+			} else if err != nil {
+				status, failed = "modified", true
 			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, packVersion(entry), entry.Source,
+				entry.Installed.Local().Format(time.DateTime), status)
+		}
+		_ = tw.Flush()
+		if failed && subCmd == "verify" {
+			fmt.Fprintln(os.Stderr, "\nSome packs differ from the lockfile; run santamon pack update to reinstall them")
+			os.Exit(1)
+		}
+	}
+}
 
-			if os.Getenv("SANTAMON_DEBUG") == "1" {
-				log.Printf("Processed %d events from %s", len(messages), filePath)
+// packVersion describes the installed version of a pack
+func packVersion(entry *rulepack.Entry) string {
+	version := entry.Ref
+	if version == "" {
+		version = "latest"
+	}
+	if len(entry.Commit) >= 12 {
+		version += " (" + entry.Commit[:12] + ")"
+	}
+	return version
+}
+
+// checkPackRules reports whether the rules directory still loads after a
+// pack changed, and how the agent picks the change up
+func checkPackRules(dir string) {
+	if _, err := rules.Load(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ The rules directory no longer loads: %v\n", err)
+		fmt.Fprintln(os.Stderr, "  The agent keeps its current rules until this is fixed")
+		os.Exit(1)
+	}
+	fmt.Println("  The agent applies it on its next rules reload (SIGHUP, or automatically with rules.reload_on: change)")
+}
+
+// replayFiles expands paths into spool files ordered by modification time,
+// so events are replayed roughly in the order Santa wrote them
+func replayFiles(paths []string) ([]string, error) {
+	type spoolFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []spoolFile
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
 			}
+			files = append(files, spoolFile{path: p, modTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list spool files: %w", err)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
 		}
+		return files[i].path < files[j].path
+	})
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f.path
 	}
+	return out, nil
 }
 
-func statusCommand() {
-	fs := flag.NewFlagSet("status", flag.ExitOnError)
+func tuneCommand() {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
 	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	since := fs.Duration("since", 0, "Analyze signals from this far back (default: state.history.retention)")
+	ruleID := fs.String("rule", "", "Only analyze this rule ID")
+	defaults := tune.DefaultOptions()
+	minSignals := fs.Int("min-signals", defaults.MinSignals, "Skip rules with fewer signals than this")
+	minShare := fs.Float64("min-share", defaults.MinShare, "Fraction of a rule's signals a pattern must cover (0-1]")
 	_ = fs.Parse(os.Args[2:])
 
+	if *minShare <= 0 || *minShare > 1 {
+		log.Fatalf("--min-share must be in (0, 1]")
+	}
+
 	cfg, err := config.LoadForReadOnly(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	window := *since
+	if window <= 0 {
+		window = cfg.State.History.Retention
+	}
+
+	rulesConfig, err := rules.Load(cfg.Rules.Path)
+	if err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
+	}
+	ruleByID := make(map[string]*rules.Rule, len(rulesConfig.Rules))
+	for _, r := range rulesConfig.Rules {
+		ruleByID[r.ID] = r
+	}
 
-	db, err := state.Open(cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
+	db, err := state.OpenStore(cfg.State.Backend, cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer func() { _ = db.Close() }()
 
-	stats, err := db.Stats()
+	entries, err := db.History(time.Now().Add(-window))
 	if err != nil {
-		log.Fatalf("Failed to read stats: %v", err)
+		log.Fatalf("Failed to read signal history: %v", err)
+	}
+	fireHistory, err := db.RuleFireHistory()
+	if err != nil {
+		log.Fatalf("Failed to read rule fire history: %v", err)
+	}
+	lifetime := make(map[string]*state.RuleFires, len(fireHistory))
+	for _, f := range fireHistory {
+		lifetime[f.RuleID] = f
 	}
 
-	fmt.Printf("santamon %s\n", version)
-	fmt.Printf("Agent ID:   %s\n", cfg.Agent.ID)
-	fmt.Printf("State DB:   %s\n", cfg.State.DBPath)
-	fmt.Printf("Signals queued: %v\n", stats["signals"])
-	fmt.Printf("Signals shipped: %v\n", stats["shipped"])
+	// Only simple rule signals carry the per-event context exceptions are suggested from
+	var filtered []*state.HistoryEntry
+	for _, e := range entries {
+		if _, ok := ruleByID[e.RuleID]; !ok {
+			continue
+		}
+		if *ruleID != "" && e.RuleID != *ruleID {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
 
-	encoded, _ := json.MarshalIndent(stats, "", "  ")
-	fmt.Printf("\nFull stats:\n%s\n", string(encoded))
-}
+	fmt.Printf("Analyzed %d signals from the last %s\n", len(filtered), window)
 
-func newDBFlagSet(errorHandling flag.ErrorHandling) (*flag.FlagSet, *string) {
-	fs := flag.NewFlagSet("db", errorHandling)
-	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
-	return fs, configPath
+	suggestions := tune.Analyze(filtered, tune.Options{MinSignals: *minSignals, MinShare: *minShare})
+	if len(suggestions) == 0 {
+		fmt.Println("No tuning suggestions: no rule is dominated by a single actor, target or signer")
+		return
+	}
+
+	for _, s := range suggestions {
+		rule := ruleByID[s.RuleID]
+		fmt.Printf("\n%s: %s\n", s.RuleID, rule.Title)
+		if f := lifetime[s.RuleID]; f != nil {
+			fmt.Printf("  %d fires since %s, last %s\n", f.Count,
+				f.First.Local().Format(time.DateOnly), f.Last.Local().Format(time.DateTime))
+		}
+		fmt.Printf("  %.0f%% of %s fires (%d/%d) are %s %s — consider an exception\n",
+			s.Share()*100, s.RuleID, s.Count, s.Total, s.Field, s.Pattern())
+		if len(rule.Exceptions) > 0 {
+			fmt.Printf("  (rule already has %d exception(s); append to its list)\n", len(rule.Exceptions))
+		}
+
+		snippet, err := s.YAML()
+		if err != nil {
+			log.Fatalf("Failed to render suggestion: %v", err)
+		}
+		fmt.Println()
+		for _, line := range strings.Split(strings.TrimRight(snippet, "\n"), "\n") {
+			fmt.Println("    " + line)
+		}
+	}
 }
 
-func dbCommand() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: santamon db <stats|compact> [--config PATH]")
+func baselineCommand() {
+	if len(os.Args) < 3 || (os.Args[2] != "export" && os.Args[2] != "import") {
+		fmt.Println("Usage: santamon baseline <export|import> [--config PATH] [--out FILE] [--rule ID] [FILE]")
 		os.Exit(1)
 	}
-
 	subCmd := os.Args[2]
 
-	fs, configPath := newDBFlagSet(flag.ExitOnError)
+	fs := flag.NewFlagSet("baseline "+subCmd, flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	out := fs.String("out", "", "Write the exported seed to this file instead of stdout")
+	ruleID := fs.String("rule", "", "Only export this baseline rule's patterns")
 	_ = fs.Parse(os.Args[3:])
 
-	// Load config to get DB path (skip shipper validation for read-only ops)
 	cfg, err := config.LoadForReadOnly(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	db, err := state.Open(cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
+	var data []byte
+	if subCmd == "import" {
+		if fs.NArg() != 1 {
+			log.Fatalf("Usage: santamon baseline import [--config PATH] FILE")
+		}
+		if data, err = os.ReadFile(fs.Arg(0)); err != nil {
+			log.Fatalf("Failed to read seed: %v", err)
+		}
+	}
+
+	db, err := state.OpenStore(cfg.State.Backend, cfg.State.DBPath, cfg.State.FirstSeen.MaxEntries, cfg.State.SyncWrites)
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+		log.Fatalf("Failed to open database (is the agent running?): %v", err)
 	}
 	defer func() { _ = db.Close() }()
 
-	switch subCmd {
-	case "stats":
-		stats, err := db.Stats()
+	if subCmd == "import" {
+		seed, err := baseline.ParseSeed(data)
 		if err != nil {
-			log.Fatalf("Failed to get stats: %v", err)
+			log.Fatalf("%v", err)
+		}
+		added, err := baseline.Import(db, seed)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		if err := db.SetMeta(metaBaselineSeeded, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			log.Fatalf("Failed to store baseline_seeded metadata: %v", err)
 		}
+		fmt.Printf("Imported %d new of %d baseline patterns exported from %s\n", added, len(seed.Patterns), seed.AgentID)
+		return
+	}
 
-		data, _ := json.MarshalIndent(stats, "", "  ")
+	rulesConfig, err := rules.Load(cfg.Rules.Path)
+	if err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
+	}
+	var ruleIDs []string
+	for _, b := range rulesConfig.Baselines {
+		if *ruleID == "" || b.ID == *ruleID {
+			ruleIDs = append(ruleIDs, b.ID)
+		}
+	}
+	if len(ruleIDs) == 0 {
+		log.Fatalf("No baseline rules to export")
+	}
+
+	seed, err := baseline.Export(db, cfg.Agent.ID, ruleIDs)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	data, err = json.MarshalIndent(seed, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode seed: %v", err)
+	}
+	if *out == "" {
 		fmt.Println(string(data))
+		return
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0600); err != nil {
+		log.Fatalf("Failed to write seed: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d baseline patterns of %d rules to %s\n", len(seed.Patterns), len(ruleIDs), *out)
+}
 
-	case "compact":
-		fmt.Println("Compacting database...")
-		if err := db.Compact(); err != nil {
-			log.Fatalf("Failed to compact: %v", err)
+// tailKinds are the signal kinds santamon tail can filter on
+var tailKinds = []string{"rule", "correlation", "baseline", "absence", "health", "dedup", "aggregate"}
+
+func tailCommand() {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	asJSON := fs.Bool("json", false, "Print one JSON signal per line instead of the console format")
+	severity := fs.String("severity", "", "Only signals at least this severe (low, medium, high, critical)")
+	ruleIDs := fs.String("rule", "", "Comma-separated rule IDs")
+	kinds := fs.String("kind", "", "Comma-separated signal kinds ("+strings.Join(tailKinds, ", ")+")")
+	_ = fs.Parse(os.Args[2:])
+
+	filter := admin.TailFilter{MinSeverity: strings.ToLower(*severity)}
+	switch filter.MinSeverity {
+	case "", "info", "low", "medium", "high", "critical":
+	default:
+		log.Fatalf("Invalid --severity %q: must be info, low, medium, high or critical", *severity)
+	}
+	for id := range strings.SplitSeq(*ruleIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			filter.Rules = append(filter.Rules, id)
 		}
-		fmt.Println("Done")
+	}
+	for kind := range strings.SplitSeq(*kinds, ",") {
+		if kind = strings.TrimSpace(kind); kind == "" {
+			continue
+		}
+		if !slices.Contains(tailKinds, kind) {
+			log.Fatalf("Invalid --kind %q: must be one of %s", kind, strings.Join(tailKinds, ", "))
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+
+	cfg, err := config.LoadForReadOnly(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var ndjson *signals.NDJSONWriter
+	if *asJSON {
+		ndjson = signals.NewNDJSONWriter(os.Stdout)
+	} else {
+		// Show each signal's context line and when it arrived
+		logutil.SetVerbosity(logutil.VerboseLevel)
+		logutil.SetTimestamps(true)
+		fmt.Fprintf(os.Stderr, "Streaming signals from %s (Ctrl+C to stop)\n", cfg.Agent.AdminSocket)
+	}
 
+	err = admin.NewClient(cfg.Agent.AdminSocket).Tail(ctx, filter, func(e *admin.TailEvent) error {
+		if ndjson != nil {
+			return ndjson.Write(e.Signal)
+		}
+		logutil.Signal(e.Kind, e.Signal.RuleID, e.Signal.Severity, e.Signal.Title, formatSignalContext(e.Signal.Context))
+		return nil
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil:
+		log.Fatalf("Tail failed: %v", err)
 	default:
-		fmt.Printf("Unknown db command: %s\n", subCmd)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Agent stopped")
 	}
 }
 
-func rulesCommand() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: santamon rules <validate> [--config PATH]")
+func shipperCommand() {
+	if len(os.Args) < 3 || os.Args[2] != "queue" {
+		fmt.Println("Usage: santamon shipper queue [--list] [--limit N] [--flush] [--drop ID] [--config PATH]")
 		os.Exit(1)
 	}
 
-	subCmd := os.Args[2]
-
-	// Parse config flag
-	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	fs := flag.NewFlagSet("shipper queue", flag.ExitOnError)
 	configPath := fs.String("config", defaultConfigPath, "Configuration file path")
+	_ = fs.Bool("list", false, "List queued signals (default)")
+	limit := fs.Int("limit", 50, "Maximum signals to list (0 = all)")
+	flush := fs.Bool("flush", false, "Ship queued signals now, ignoring the circuit breaker")
+	drop := fs.String("drop", "", "Remove the queued signal with this ID")
 	_ = fs.Parse(os.Args[3:])
 
-	cfg, err := config.Load(*configPath)
+	if *flush && *drop != "" {
+		log.Fatalf("--flush and --drop are mutually exclusive")
+	}
+
+	cfg, err := config.LoadForReadOnly(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	client := admin.NewClient(cfg.Agent.AdminSocket)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
 
-	switch subCmd {
-	case "validate":
-		rulesConfig, err := rules.Load(cfg.Rules.Path)
+	switch {
+	case *flush:
+		result, err := client.Flush(ctx)
 		if err != nil {
-			log.Fatalf("Validation failed: %v", err)
+			log.Fatalf("Flush failed: %v", err)
 		}
-
-		// Try to compile rules
-		engine, err := rules.NewEngine()
-		if err != nil {
-			log.Fatalf("Failed to create engine: %v", err)
+		fmt.Printf("Shipped %d signals, %d remaining\n", result.Shipped, result.Remaining)
+		if result.Error != "" {
+			log.Fatalf("Flush stopped: %s", result.Error)
 		}
 
-		if err := engine.LoadRules(rulesConfig); err != nil {
-			log.Fatalf("Failed to compile rules: %v", err)
+	case *drop != "":
+		result, err := client.Drop(ctx, *drop)
+		if err != nil {
+			log.Fatalf("Drop failed: %v", err)
 		}
-
-		fmt.Printf("✓ Rules validated successfully\n")
-		fmt.Printf("  %d rules\n", len(rulesConfig.Rules))
-		fmt.Printf("  %d correlations\n", len(rulesConfig.Correlations))
-		fmt.Printf("  %d baselines\n", len(rulesConfig.Baselines))
+		fmt.Printf("Dropped signal %s (%d queued entries)\n", *drop, result.Dropped)
 
 	default:
-		fmt.Printf("Unknown rules command: %s\n", subCmd)
-		os.Exit(1)
+		list, err := client.ListQueue(ctx, *limit)
+		if err != nil {
+			log.Fatalf("Failed to list queue: %v", err)
+		}
+		if list.Count == 0 {
+			fmt.Println("Shipping queue is empty")
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "QUEUED\tSIGNAL ID\tRULE\tSEVERITY\tLANE")
+		for _, sig := range list.Signals {
+			lane := "bulk"
+			if sig.Priority {
+				lane = "priority"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				sig.QueuedAt.Local().Format(time.DateTime), sig.ID, sig.RuleID, sig.Severity, lane)
+		}
+		_ = tw.Flush()
+		if *limit > 0 && list.Count == *limit {
+			fmt.Printf("\nShowing the first %d signals (use --limit 0 for all)\n", *limit)
+		}
 	}
 }
@@ -3,7 +3,10 @@ package main
 import (
 	"flag"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDBCommandConfigFlag(t *testing.T) {
@@ -34,3 +37,43 @@ func TestDBCommandConfigFlag(t *testing.T) {
 		}
 	})
 }
+
+func TestReplayFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"c.pb", "a.pb", "sub/b.pb", ".hidden"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("failed to set mtime: %v", err)
+		}
+	}
+	single := filepath.Join(t.TempDir(), "single.pb")
+	if err := os.WriteFile(single, []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Chtimes(single, now.Add(time.Hour), now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+
+	files, err := replayFiles([]string{dir, single})
+	if err != nil {
+		t.Fatalf("replayFiles failed: %v", err)
+	}
+	// Ordered by modification time, hidden files skipped
+	want := []string{filepath.Join(dir, "c.pb"), filepath.Join(dir, "a.pb"), filepath.Join(dir, "sub/b.pb"), single}
+	if len(files) != len(want) {
+		t.Fatalf("expected %v, got %v", want, files)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("file %d: expected %s, got %s", i, want[i], files[i])
+		}
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/google/cel-go v0.26.1
 	github.com/klauspost/compress v1.17.7
+	github.com/mattn/go-sqlite3 v1.14.33
 	go.etcd.io/bbolt v1.4.3
 	golang.org/x/sync v0.10.0
 	google.golang.org/protobuf v1.36.10
@@ -0,0 +1,198 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/httpapi"
+	"github.com/0x4d31/santamon/internal/logutil"
+	"github.com/0x4d31/santamon/internal/shadow"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+var logger = logutil.For("admin")
+
+// Queue is the outbox as seen by the admin API
+type Queue interface {
+	ListQueue(limit int) ([]*state.QueuedSignal, error)
+	DropQueued(signalID string) (int, error)
+}
+
+// Flusher ships queued signals on demand
+type Flusher interface {
+	Flush(ctx context.Context) (int, error)
+}
+
+// Shadow reports the shadow rules comparison
+type Shadow interface {
+	Report() *shadow.Report
+}
+
+// FireHistory reports how often each rule has fired
+type FireHistory interface {
+	RuleFireHistory() ([]*state.RuleFires, error)
+}
+
+// QueueList is the response of GET /v1/queue
+type QueueList struct {
+	Count   int                   `json:"count"`
+	Signals []*state.QueuedSignal `json:"signals"`
+}
+
+// FlushResult is the response of POST /v1/queue/flush
+type FlushResult struct {
+	Shipped   int    `json:"shipped"`
+	Remaining int    `json:"remaining"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DropResult is the response of DELETE /v1/queue/{id}
+type DropResult struct {
+	Dropped int `json:"dropped"`
+}
+
+// Server exposes operator commands on a unix socket only root can reach.
+// The state DB is locked while the agent runs, so CLI commands that touch
+// the queue go through here.
+type Server struct {
+	path    string
+	queue   Queue
+	flusher Flusher
+	shadow  Shadow
+	history FireHistory
+	stream  *Stream
+}
+
+// NewServer creates an admin server listening on the unix socket at path
+func NewServer(path string, queue Queue, flusher Flusher) *Server {
+	return &Server{path: path, queue: queue, flusher: flusher}
+}
+
+// SetShadow exposes the shadow rules comparison at GET /v1/shadow
+func (s *Server) SetShadow(sh Shadow) {
+	s.shadow = sh
+}
+
+// SetFireHistory exposes the rules' fire history at GET /v1/rules/stats
+func (s *Server) SetFireHistory(h FireHistory) {
+	s.history = h
+}
+
+// SetStream streams the agent's signals at GET /v1/tail
+func (s *Server) SetStream(stream *Stream) {
+	s.stream = stream
+}
+
+// Handler returns the admin API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/queue", s.handleList)
+	mux.HandleFunc("POST /v1/queue/flush", s.handleFlush)
+	mux.HandleFunc("DELETE /v1/queue/{id}", s.handleDrop)
+	mux.HandleFunc("GET /v1/shadow", s.handleShadow)
+	mux.HandleFunc("GET /v1/rules/stats", s.handleRuleStats)
+	mux.HandleFunc("GET /v1/tail", s.handleTail)
+	return mux
+}
+
+// Start serves the admin API until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := httpapi.ListenUnix(s.path, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to start admin socket: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		// Ends streaming requests on shutdown, which Shutdown would wait for
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warn("Admin socket stopped: %v", err)
+		}
+	}()
+	logger.Verbose("Admin socket listening on %s", s.path)
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	_ = srv.Shutdown(shutdownCtx)
+	cancel()
+	_ = os.Remove(s.path)
+	return ctx.Err()
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	signals, err := s.queue.ListQueue(limit)
+	if err != nil {
+		httpapi.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpapi.WriteJSON(w, http.StatusOK, QueueList{Count: len(signals), Signals: signals})
+}
+
+func (s *Server) handleFlush(w http.ResponseWriter, r *http.Request) {
+	shipped, err := s.flusher.Flush(r.Context())
+	result := FlushResult{Shipped: shipped}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if remaining, err := s.queue.ListQueue(0); err == nil {
+		result.Remaining = len(remaining)
+	}
+	logger.Info("Manual flush shipped %d signals (%d remaining)", result.Shipped, result.Remaining)
+	httpapi.WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleDrop(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dropped, err := s.queue.DropQueued(id)
+	if err != nil {
+		httpapi.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if dropped == 0 {
+		httpapi.WriteError(w, http.StatusNotFound, fmt.Sprintf("signal %s is not queued", id))
+		return
+	}
+	logger.Warn("Dropped queued signal %s at operator request", id)
+	httpapi.WriteJSON(w, http.StatusOK, DropResult{Dropped: dropped})
+}
+
+func (s *Server) handleShadow(w http.ResponseWriter, r *http.Request) {
+	if s.shadow == nil {
+		httpapi.WriteError(w, http.StatusNotFound, "no shadow rules configured (rules.shadow_path)")
+		return
+	}
+	httpapi.WriteJSON(w, http.StatusOK, s.shadow.Report())
+}
+
+func (s *Server) handleRuleStats(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		httpapi.WriteError(w, http.StatusNotFound, "rule fire history is not available")
+		return
+	}
+	history, err := s.history.RuleFireHistory()
+	if err != nil {
+		httpapi.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpapi.WriteJSON(w, http.StatusOK, history)
+}
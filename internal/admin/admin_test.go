@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/shadow"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// fakeQueue is an in-memory outbox that ships everything on Flush
+type fakeQueue struct {
+	mu      sync.Mutex
+	signals []*state.QueuedSignal
+	failing bool
+}
+
+func (q *fakeQueue) ListQueue(limit int) ([]*state.QueuedSignal, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limit > 0 && limit < len(q.signals) {
+		return q.signals[:limit], nil
+	}
+	return q.signals, nil
+}
+
+func (q *fakeQueue) DropQueued(signalID string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, sig := range q.signals {
+		if sig.ID == signalID {
+			q.signals = append(q.signals[:i], q.signals[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (q *fakeQueue) Flush(context.Context) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failing {
+		return 0, errors.New("backend unreachable")
+	}
+	n := len(q.signals)
+	q.signals = nil
+	return n, nil
+}
+
+// fakeHistory returns a fixed fire history
+type fakeHistory []*state.RuleFires
+
+func (f fakeHistory) RuleFireHistory() ([]*state.RuleFires, error) { return f, nil }
+
+// fakeShadow returns a fixed shadow report
+type fakeShadow struct{ report *shadow.Report }
+
+func (f fakeShadow) Report() *shadow.Report { return f.report }
+
+func startServer(t *testing.T, q *fakeQueue, sh Shadow) *Client {
+	t.Helper()
+	sock := socketPath(t)
+	srv := NewServer(sock, q, q)
+	if sh != nil {
+		srv.SetShadow(sh)
+	}
+	return serve(t, srv, sock)
+}
+
+// socketPath returns a path for a test admin socket
+func socketPath(t *testing.T) string {
+	t.Helper()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir() path
+	dir, err := os.MkdirTemp("", "adm")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "admin.sock")
+}
+
+// serve starts srv on sock until the test ends and returns a client for it
+func serve(t *testing.T, srv *Server, sock string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = srv.Start(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, err := os.Stat(sock); err == nil && info.Mode().Perm() == 0600 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return NewClient(sock)
+}
+
+func TestQueueCommands(t *testing.T) {
+	q := &fakeQueue{}
+	for _, id := range []string{"sig-1", "sig-2", "poison"} {
+		q.signals = append(q.signals, &state.QueuedSignal{Signal: &state.Signal{ID: id, RuleID: "R1"}, QueuedAt: time.Now()})
+	}
+	client := startServer(t, q, nil)
+	ctx := context.Background()
+
+	list, err := client.ListQueue(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListQueue() error = %v", err)
+	}
+	if list.Count != 2 || list.Signals[0].ID != "sig-1" || list.Signals[0].RuleID != "R1" {
+		t.Errorf("ListQueue() = %+v", list)
+	}
+
+	dropped, err := client.Drop(ctx, "poison")
+	if err != nil || dropped.Dropped != 1 {
+		t.Fatalf("Drop() = %+v, %v", dropped, err)
+	}
+	if _, err := client.Drop(ctx, "poison"); err == nil || !strings.Contains(err.Error(), "not queued") {
+		t.Errorf("Expected not queued error, got %v", err)
+	}
+
+	q.mu.Lock()
+	q.failing = true
+	q.mu.Unlock()
+	result, err := client.Flush(ctx)
+	if err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if result.Error == "" || result.Remaining != 2 {
+		t.Errorf("Flush() with failing backend = %+v", result)
+	}
+
+	q.mu.Lock()
+	q.failing = false
+	q.mu.Unlock()
+	result, err = client.Flush(ctx)
+	if err != nil || result.Shipped != 2 || result.Remaining != 0 {
+		t.Errorf("Flush() = %+v, %v", result, err)
+	}
+}
+
+func TestShadow(t *testing.T) {
+	ctx := context.Background()
+	if _, err := startServer(t, &fakeQueue{}, nil).Shadow(ctx); err == nil || !strings.Contains(err.Error(), "rules.shadow_path") {
+		t.Errorf("Expected no shadow rules error, got %v", err)
+	}
+
+	sh := fakeShadow{report: &shadow.Report{
+		ActiveVersion: "v1",
+		ShadowVersion: "v2",
+		Events:        10,
+		Rules:         []*shadow.RuleDiff{{RuleID: "R1", ShadowOnly: 3}},
+	}}
+	report, err := startServer(t, &fakeQueue{}, sh).Shadow(ctx)
+	if err != nil {
+		t.Fatalf("Shadow() error = %v", err)
+	}
+	if report.ShadowVersion != "v2" || report.Events != 10 || len(report.Rules) != 1 || report.Rules[0].ShadowOnly != 3 {
+		t.Errorf("Shadow() = %+v", report)
+	}
+}
+
+func TestRuleStats(t *testing.T) {
+	ctx := context.Background()
+	if _, err := startServer(t, &fakeQueue{}, nil).RuleStats(ctx); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("Expected no fire history error, got %v", err)
+	}
+
+	last := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	sock := socketPath(t)
+	srv := NewServer(sock, &fakeQueue{}, &fakeQueue{})
+	srv.SetFireHistory(fakeHistory{{RuleID: "R1", Count: 4, First: last.Add(-time.Hour), Last: last}})
+	history, err := serve(t, srv, sock).RuleStats(ctx)
+	if err != nil {
+		t.Fatalf("RuleStats() error = %v", err)
+	}
+	if len(history) != 1 || history[0].RuleID != "R1" || history[0].Count != 4 || !history[0].Last.Equal(last) {
+		t.Errorf("RuleStats() = %+v", history)
+	}
+}
+
+func TestClientNotRunning(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	if _, err := client.ListQueue(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "is santamon running") {
+		t.Errorf("Expected connection error, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/0x4d31/santamon/internal/httpapi"
+	"github.com/0x4d31/santamon/internal/shadow"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// Client talks to a running agent's admin socket
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the admin socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// ListQueue returns up to limit queued signals (0 = all)
+func (c *Client) ListQueue(ctx context.Context, limit int) (*QueueList, error) {
+	var list QueueList
+	err := c.do(ctx, http.MethodGet, "/v1/queue?limit="+strconv.Itoa(limit), &list)
+	return &list, err
+}
+
+// Flush ships queued signals now, ignoring the circuit breaker
+func (c *Client) Flush(ctx context.Context) (*FlushResult, error) {
+	var result FlushResult
+	err := c.do(ctx, http.MethodPost, "/v1/queue/flush", &result)
+	return &result, err
+}
+
+// Drop removes a queued signal by ID
+func (c *Client) Drop(ctx context.Context, signalID string) (*DropResult, error) {
+	var result DropResult
+	err := c.do(ctx, http.MethodDelete, "/v1/queue/"+url.PathEscape(signalID), &result)
+	return &result, err
+}
+
+// Shadow returns the comparison of the shadow rules with the active rules
+func (c *Client) Shadow(ctx context.Context) (*shadow.Report, error) {
+	var report shadow.Report
+	err := c.do(ctx, http.MethodGet, "/v1/shadow", &report)
+	return &report, err
+}
+
+// RuleStats returns the fire history of every rule that has fired
+func (c *Client) RuleStats(ctx context.Context) ([]*state.RuleFires, error) {
+	var history []*state.RuleFires
+	err := c.do(ctx, http.MethodGet, "/v1/rules/stats", &history)
+	return history, err
+}
+
+// maxTailLine bounds a streamed signal; signals carry at most a 100KB context
+// plus the event when rules include it
+const maxTailLine = 8 << 20
+
+// Tail streams the signals the agent emits that match filter to fn until ctx
+// is done, the agent stops, or fn returns an error
+func (c *Client) Tail(ctx context.Context, filter TailFilter, fn func(*TailEvent) error) error {
+	path := "/v1/tail"
+	if q := filter.query().Encode(); q != "" {
+		path += "?" + q
+	}
+	resp, err := c.send(ctx, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxTailLine)
+	for scanner.Scan() {
+		var event TailEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to decode streamed signal: %w", err)
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("signal stream interrupted: %w", err)
+	}
+	return ctx.Err()
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, out any) error {
+	resp, err := c.send(ctx, method, path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode admin response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request and returns the response if it succeeded; otherwise
+// the error the agent reported
+func (c *Client) send(ctx context.Context, method, path string) (*http.Response, error) {
+	// The host is ignored: every connection goes to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://santamon"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach agent admin socket (is santamon running?): %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		var e httpapi.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			return nil, fmt.Errorf("admin request failed with status %d", resp.StatusCode)
+		}
+		return nil, errors.New(e.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/0x4d31/santamon/internal/httpapi"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// tailBuffer is the number of signals buffered per tailing client; a client
+// that falls further behind misses signals rather than slowing the agent
+const tailBuffer = 256
+
+// TailEvent is one line of GET /v1/tail
+type TailEvent struct {
+	Kind   string        `json:"kind"` // rule, correlation, baseline, absence, health, dedup, aggregate or follow_up
+	Signal *state.Signal `json:"signal"`
+}
+
+// TailFilter selects the signals streamed to a client. Empty fields match
+// everything.
+type TailFilter struct {
+	MinSeverity string
+	Rules       []string
+	Kinds       []string
+}
+
+// Match reports whether a signal of kind passes the filter
+func (f TailFilter) Match(kind string, sig *state.Signal) bool {
+	if f.MinSeverity != "" && !rules.SeverityAtLeast(sig.Severity, f.MinSeverity) {
+		return false
+	}
+	if len(f.Rules) > 0 && !slices.Contains(f.Rules, sig.RuleID) {
+		return false
+	}
+	return len(f.Kinds) == 0 || slices.Contains(f.Kinds, kind)
+}
+
+// query encodes the filter as GET /v1/tail parameters
+func (f TailFilter) query() url.Values {
+	q := url.Values{}
+	if f.MinSeverity != "" {
+		q.Set("severity", f.MinSeverity)
+	}
+	if len(f.Rules) > 0 {
+		q.Set("rule", strings.Join(f.Rules, ","))
+	}
+	if len(f.Kinds) > 0 {
+		q.Set("kind", strings.Join(f.Kinds, ","))
+	}
+	return q
+}
+
+// parseTailFilter reads the filter from GET /v1/tail parameters
+func parseTailFilter(q url.Values) TailFilter {
+	return TailFilter{
+		MinSeverity: q.Get("severity"),
+		Rules:       splitList(q.Get("rule")),
+		Kinds:       splitList(q.Get("kind")),
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Stream fans out the signals the agent emits to clients tailing them on the
+// admin socket. Publishing never blocks: slow clients miss signals.
+type Stream struct {
+	mu   sync.Mutex
+	subs map[chan []byte]TailFilter
+}
+
+// NewStream creates a signal stream with no clients
+func NewStream() *Stream {
+	return &Stream{subs: make(map[chan []byte]TailFilter)}
+}
+
+// Publish sends a signal of kind to the clients whose filter it matches. It
+// is safe on a nil Stream.
+func (s *Stream) Publish(kind string, sig *state.Signal) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var line []byte
+	for ch, filter := range s.subs {
+		if !filter.Match(kind, sig) {
+			continue
+		}
+		// Encode once, and only when someone is listening
+		if line == nil {
+			data, err := json.Marshal(TailEvent{Kind: kind, Signal: sig})
+			if err != nil {
+				logger.Warn("Failed to encode signal %s for tail: %v", sig.ID, err)
+				return
+			}
+			line = append(data, '\n')
+		}
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// subscribe registers a client and returns its channel and the function
+// removing it
+func (s *Stream) subscribe(filter TailFilter) (chan []byte, func()) {
+	ch := make(chan []byte, tailBuffer)
+	s.mu.Lock()
+	s.subs[ch] = filter
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+func (s *Server) handleTail(w http.ResponseWriter, r *http.Request) {
+	if s.stream == nil {
+		httpapi.WriteError(w, http.StatusNotFound, "signal streaming is not available")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpapi.WriteError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	ch, unsubscribe := s.stream.subscribe(parseTailFilter(r.URL.Query()))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	logger.Verbose("Signal tail client connected")
+	defer logger.Verbose("Signal tail client disconnected")
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-ch:
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+func TestTailFilter(t *testing.T) {
+	sig := &state.Signal{RuleID: "R1", Severity: "high"}
+	tests := []struct {
+		filter TailFilter
+		kind   string
+		want   bool
+	}{
+		{TailFilter{}, "rule", true},
+		{TailFilter{MinSeverity: "medium"}, "rule", true},
+		{TailFilter{MinSeverity: "critical"}, "rule", false},
+		{TailFilter{Rules: []string{"R2", "R1"}}, "rule", true},
+		{TailFilter{Rules: []string{"R2"}}, "rule", false},
+		{TailFilter{Kinds: []string{"correlation"}}, "rule", false},
+		{TailFilter{Kinds: []string{"rule", "baseline"}}, "baseline", true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.kind, sig); got != tt.want {
+			t.Errorf("%+v.Match(%q) = %v, want %v", tt.filter, tt.kind, got, tt.want)
+		}
+	}
+
+	if got := parseTailFilter((TailFilter{MinSeverity: "high", Rules: []string{"R1", "R2"}}).query()); got.MinSeverity != "high" || len(got.Rules) != 2 || got.Kinds != nil {
+		t.Errorf("Filter did not round-trip: %+v", got)
+	}
+}
+
+func TestTail(t *testing.T) {
+	ctx := context.Background()
+	if err := startServer(t, &fakeQueue{}, nil).Tail(ctx, TailFilter{}, nil); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("Expected streaming unavailable error, got %v", err)
+	}
+
+	stream := NewStream()
+	stream.Publish("rule", &state.Signal{ID: "unheard"}) // no clients yet
+	sock := socketPath(t)
+	srv := NewServer(sock, &fakeQueue{}, &fakeQueue{})
+	srv.SetStream(stream)
+	client := serve(t, srv, sock)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	var got []*TailEvent
+	go func() {
+		done <- client.Tail(ctx, TailFilter{MinSeverity: "high"}, func(e *TailEvent) error {
+			got = append(got, e)
+			if len(got) == 2 {
+				return errors.New("enough")
+			}
+			return nil
+		})
+	}()
+
+	// Wait until the client has subscribed
+	for {
+		stream.mu.Lock()
+		subscribed := len(stream.subs) == 1
+		stream.mu.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stream.Publish("rule", &state.Signal{ID: "low", RuleID: "R1", Severity: "low"})
+	stream.Publish("rule", &state.Signal{ID: "sig-1", RuleID: "R1", Severity: "high"})
+	stream.Publish("correlation", &state.Signal{ID: "sig-2", RuleID: "C1", Severity: "critical"})
+
+	if err := <-done; err == nil || err.Error() != "enough" {
+		t.Fatalf("Tail() = %v", err)
+	}
+	if len(got) != 2 || got[0].Signal.ID != "sig-1" || got[1].Kind != "correlation" || got[1].Signal.RuleID != "C1" {
+		t.Errorf("Streamed %+v", got)
+	}
+
+	// Disconnected clients are unsubscribed
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		stream.mu.Lock()
+		n := len(stream.subs)
+		stream.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Client still subscribed after disconnecting")
+}
@@ -0,0 +1,137 @@
+package aggregate
+
+import (
+	"fmt"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// maxTargets bounds the distinct targets listed in a rollup
+const maxTargets = 100
+
+// Aggregator rolls up the matches of rules marked aggregate: true into one
+// signal per rule and interval. Matches are counted in the state DB, so
+// counts survive restarts.
+type Aggregator struct {
+	db       state.Store
+	interval time.Duration
+}
+
+// New creates an Aggregator that emits a rollup interval after each rule's
+// first match
+func New(db state.Store, interval time.Duration) *Aggregator {
+	return &Aggregator{db: db, interval: interval}
+}
+
+// Interval returns how long matches are accumulated before a rollup
+func (a *Aggregator) Interval() time.Duration {
+	return a.interval
+}
+
+// Target identifies what a match was about: the target path, else the
+// target executable's SHA-256
+func Target(msg *santapb.SantaMessage) string {
+	if p := events.TargetPath(msg); p != "" {
+		return p
+	}
+	return events.TargetSHA256(msg)
+}
+
+// Add counts the match that produced sig at now
+func (a *Aggregator) Add(sig *state.Signal, target string, now time.Time) error {
+	if err := a.db.AddAggregate(sig.RuleID, target, sig, now, maxTargets); err != nil {
+		return fmt.Errorf("failed to record aggregate: %w", err)
+	}
+	return nil
+}
+
+// metaLastRollup prefixes the meta key holding a rule's last rollup ID
+const metaLastRollup = "last_rollup/"
+
+// Flush returns the rollups of rules whose interval has ended at now.
+//
+// Each rollup updates the rule's previous one and takes its triage status:
+// once a rollup is closed the rule's rollups stop until it is reopened, and
+// while it is acknowledged new rollups are shipped acknowledged.
+func (a *Aggregator) Flush(now time.Time) ([]*state.Signal, error) {
+	expired, err := a.db.ExpireAggregates(now.Add(-a.interval))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire aggregates: %w", err)
+	}
+	rollups := make([]*state.Signal, 0, len(expired))
+	for _, entry := range expired {
+		sig := Rollup(entry)
+		if sig == nil {
+			continue
+		}
+		ship, err := a.inheritStatus(sig, now)
+		if err != nil {
+			return rollups, err
+		}
+		if ship {
+			rollups = append(rollups, sig)
+		}
+	}
+	return rollups, nil
+}
+
+// inheritStatus gives a rollup the triage status of the rule's previous
+// rollup and reports whether it should be shipped
+func (a *Aggregator) inheritStatus(sig *state.Signal, now time.Time) (bool, error) {
+	key := metaLastRollup + sig.RuleID
+	prev, err := a.db.GetMeta(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read last rollup: %w", err)
+	}
+	if prev != "" {
+		status, err := a.db.SignalStatus(prev)
+		if err != nil {
+			return false, fmt.Errorf("failed to read rollup status: %w", err)
+		}
+		switch status {
+		case state.StatusClosed:
+			return false, nil
+		case state.StatusAcknowledged:
+			sig.Status = state.StatusAcknowledged
+			// Later rollups chain from this one, so it carries the status too
+			if _, err := a.db.SetTriage(sig.ID, sig.RuleID, state.TriageTransition{
+				Status: state.StatusAcknowledged,
+				TS:     now,
+				By:     "santamon",
+				Note:   "previous rollup " + prev + " acknowledged",
+			}); err != nil {
+				return false, fmt.Errorf("failed to record rollup status: %w", err)
+			}
+		}
+	}
+	if err := a.db.SetMeta(key, sig.ID); err != nil {
+		return false, fmt.Errorf("failed to record last rollup: %w", err)
+	}
+	return true, nil
+}
+
+// Rollup builds the signal summarizing an aggregate: the rule metadata of the
+// first match, the match count, distinct targets and first/last timestamps.
+// The first match's context is kept under "sample".
+func Rollup(entry *state.AggregateEntry) *state.Signal {
+	if entry.Signal == nil {
+		return nil
+	}
+	first := entry.Signal
+	sig := first.Derive(fmt.Sprintf("%s|%d|aggregate", entry.RuleID, entry.First.UnixNano()), entry.Last)
+	sig.Context = map[string]any{
+		"aggregate_count":            entry.Count,
+		"aggregate_distinct_targets": len(entry.Targets),
+		"aggregate_targets":          entry.Targets,
+		"aggregate_first_seen":       entry.First.UTC().Format(time.RFC3339),
+		"aggregate_last_seen":        entry.Last.UTC().Format(time.RFC3339),
+		"sample":                     first.Context,
+	}
+	if entry.Truncated {
+		sig.Context["aggregate_targets_truncated"] = true
+	}
+	return sig
+}
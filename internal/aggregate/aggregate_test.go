@@ -0,0 +1,150 @@
+package aggregate
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+func setupTestDB(t *testing.T) *state.DB {
+	t.Helper()
+	db, err := state.Open(filepath.Join(t.TempDir(), "test.db"), 1000, false)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestAddAndFlush(t *testing.T) {
+	a := New(setupTestDB(t), 5*time.Minute)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 300; i++ {
+		sig := &state.Signal{
+			ID:       fmt.Sprintf("sig-%d", i),
+			RuleID:   "NOISY",
+			Title:    "Script interpreter",
+			Severity: "low",
+			Context:  map[string]any{"target_path": fmt.Sprintf("/tmp/%d", i%3)},
+		}
+		if err := a.Add(sig, fmt.Sprintf("/tmp/%d", i%3), start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("Failed to add match: %v", err)
+		}
+	}
+	if err := a.Add(&state.Signal{ID: "other", RuleID: "OTHER"}, "", start.Add(4*time.Minute)); err != nil {
+		t.Fatalf("Failed to add match: %v", err)
+	}
+
+	if rollups, err := a.Flush(start.Add(time.Minute)); err != nil || len(rollups) != 0 {
+		t.Fatalf("Flush() before the interval = %v, %v", rollups, err)
+	}
+
+	rollups, err := a.Flush(start.Add(6 * time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	if len(rollups) != 1 {
+		t.Fatalf("Expected 1 rollup (OTHER is still accumulating), got %d", len(rollups))
+	}
+	r := rollups[0]
+	if r.RuleID != "NOISY" || r.Title != "Script interpreter" || r.ID == "sig-0" {
+		t.Errorf("Unexpected rollup: %+v", r)
+	}
+	if !r.TS.Equal(start.Add(299 * time.Second)) {
+		t.Errorf("Rollup TS = %v, want last match", r.TS)
+	}
+	if r.Context["aggregate_count"] != 300 || r.Context["aggregate_distinct_targets"] != 3 {
+		t.Errorf("Unexpected rollup context: %v", r.Context)
+	}
+	if r.Context["aggregate_first_seen"] != "2026-10-01T12:00:00Z" || r.Context["aggregate_last_seen"] != "2026-10-01T12:04:59Z" {
+		t.Errorf("Unexpected rollup timestamps: %v", r.Context)
+	}
+	if sample, ok := r.Context["sample"].(map[string]any); !ok || sample["target_path"] != "/tmp/0" {
+		t.Errorf("Unexpected rollup sample: %v", r.Context["sample"])
+	}
+
+	// The next match starts a new aggregate
+	if rollups, _ := a.Flush(start.Add(time.Hour)); len(rollups) != 1 || rollups[0].RuleID != "OTHER" {
+		t.Errorf("Expected OTHER rollup, got %v", rollups)
+	}
+	if rollups, _ := a.Flush(start.Add(2 * time.Hour)); len(rollups) != 0 {
+		t.Errorf("Expected no rollups, got %v", rollups)
+	}
+}
+
+func TestTargetsTruncated(t *testing.T) {
+	a := New(setupTestDB(t), time.Minute)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < maxTargets+5; i++ {
+		if err := a.Add(&state.Signal{RuleID: "R1"}, fmt.Sprintf("/tmp/%d", i), start); err != nil {
+			t.Fatalf("Failed to add match: %v", err)
+		}
+	}
+
+	rollups, err := a.Flush(start.Add(2 * time.Minute))
+	if err != nil || len(rollups) != 1 {
+		t.Fatalf("Flush() = %v, %v", rollups, err)
+	}
+	ctx := rollups[0].Context
+	if ctx["aggregate_count"] != maxTargets+5 || ctx["aggregate_distinct_targets"] != maxTargets || ctx["aggregate_targets_truncated"] != true {
+		t.Errorf("Unexpected rollup context: %v", ctx)
+	}
+}
+
+func TestRollupTriage(t *testing.T) {
+	db := setupTestDB(t)
+	a := New(db, time.Minute)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	flush := func(i int) *state.Signal {
+		t.Helper()
+		at := start.Add(time.Duration(i) * time.Hour)
+		if err := a.Add(&state.Signal{RuleID: "R1", Status: state.StatusOpen}, "/tmp/x", at); err != nil {
+			t.Fatalf("Failed to add match: %v", err)
+		}
+		rollups, err := a.Flush(at.Add(2 * time.Minute))
+		if err != nil || len(rollups) > 1 {
+			t.Fatalf("Flush() = %v, %v", rollups, err)
+		}
+		if len(rollups) == 0 {
+			return nil
+		}
+		return rollups[0]
+	}
+	triage := func(id, status string, at time.Time) {
+		t.Helper()
+		if _, err := db.SetTriage(id, "R1", state.TriageTransition{Status: status, TS: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := flush(0)
+	if first == nil || first.Status != state.StatusOpen {
+		t.Fatalf("First rollup = %+v, want open", first)
+	}
+
+	// Acknowledging a rollup carries over to the next ones
+	triage(first.ID, state.StatusAcknowledged, start.Add(10*time.Minute))
+	second := flush(1)
+	if second == nil || second.Status != state.StatusAcknowledged {
+		t.Fatalf("Second rollup = %+v, want acknowledged", second)
+	}
+	third := flush(2)
+	if third == nil || third.Status != state.StatusAcknowledged {
+		t.Fatalf("Third rollup = %+v, want acknowledged", third)
+	}
+
+	// Closing one stops them until it is reopened
+	triage(third.ID, state.StatusClosed, start.Add(3*time.Hour))
+	if r := flush(4); r != nil {
+		t.Errorf("Rollup after close = %+v, want none", r)
+	}
+	triage(third.ID, state.StatusOpen, start.Add(5*time.Hour))
+	if r := flush(6); r == nil || r.Status != state.StatusOpen {
+		t.Errorf("Rollup after reopening = %+v, want open", r)
+	}
+}
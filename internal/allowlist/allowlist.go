@@ -0,0 +1,146 @@
+package allowlist
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// signingIDPattern matches Santa signing IDs: a team ID or "platform", a
+// colon, and the code signing identifier
+var signingIDPattern = regexp.MustCompile(`^(?:[A-Z0-9]{10}|platform):[^\s:]+$`)
+
+// Allowlist is a set of trusted executables, matched on the code signature
+// or content hash of the binary being executed. Lookups are map hits, so it
+// can run ahead of CEL evaluation for every event.
+type Allowlist struct {
+	teamIDs    map[string]struct{}
+	signingIDs map[string]struct{} // TEAMID:signing_id or platform:signing_id
+	cdhashes   map[string]struct{}
+	sha256     map[string]struct{}
+}
+
+// file is the YAML allowlist file format
+type file struct {
+	TeamIDs    []string `yaml:"team_ids"`
+	SigningIDs []string `yaml:"signing_ids"`
+	CDHashes   []string `yaml:"cdhashes"`
+	SHA256     []string `yaml:"sha256"`
+}
+
+// Load reads an allowlist file
+func Load(path string) (*Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowlist: %w", err)
+	}
+	a, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, nil
+}
+
+// Parse parses an allowlist YAML document
+func Parse(data []byte) (*Allowlist, error) {
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse allowlist: %w", err)
+	}
+
+	a := &Allowlist{
+		teamIDs:    make(map[string]struct{}, len(f.TeamIDs)),
+		signingIDs: make(map[string]struct{}, len(f.SigningIDs)),
+		cdhashes:   make(map[string]struct{}, len(f.CDHashes)),
+		sha256:     make(map[string]struct{}, len(f.SHA256)),
+	}
+	for _, id := range f.TeamIDs {
+		if id = strings.TrimSpace(id); id == "" {
+			return nil, fmt.Errorf("team_ids: empty entry")
+		}
+		a.teamIDs[id] = struct{}{}
+	}
+	for _, id := range f.SigningIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return nil, fmt.Errorf("signing_ids: empty entry")
+		}
+		// A bare signing ID can be claimed by any ad hoc signed binary
+		if !signingIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("signing_ids: %q must be TEAMID:signing_id or platform:signing_id", id)
+		}
+		a.signingIDs[id] = struct{}{}
+	}
+	if err := addHashes(a.cdhashes, "cdhashes", f.CDHashes, 20); err != nil {
+		return nil, err
+	}
+	if err := addHashes(a.sha256, "sha256", f.SHA256, 32); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// addHashes adds hex hashes of the given byte length, normalized to lower case
+func addHashes(set map[string]struct{}, key string, hashes []string, size int) error {
+	for _, h := range hashes {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if b, err := hex.DecodeString(h); err != nil || len(b) != size {
+			return fmt.Errorf("%s: %q is not a %d-character hex hash", key, h, size*2)
+		}
+		set[h] = struct{}{}
+	}
+	return nil
+}
+
+// Len returns the number of entries
+func (a *Allowlist) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.teamIDs) + len(a.signingIDs) + len(a.cdhashes) + len(a.sha256)
+}
+
+// Match reports whether msg is an execution of an allowlisted binary and
+// returns the entry that matched (e.g. "team_id=EQHXZ8M8AV"). Other event
+// types never match. A nil allowlist matches nothing.
+func (a *Allowlist) Match(msg *santapb.SantaMessage) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	target := msg.GetExecution().GetTarget()
+	if target == nil {
+		return "", false
+	}
+
+	cs := target.GetCodeSignature()
+	if id := cs.GetTeamId(); id != "" {
+		if _, ok := a.teamIDs[id]; ok {
+			return "team_id=" + id, true
+		}
+	}
+	if id := events.SigningKey(target); id != "" {
+		if _, ok := a.signingIDs[id]; ok {
+			return "signing_id=" + id, true
+		}
+	}
+	if cdhash := cs.GetCdhash(); len(cdhash) > 0 && len(a.cdhashes) > 0 {
+		h := hex.EncodeToString(cdhash)
+		if _, ok := a.cdhashes[h]; ok {
+			return "cdhash=" + h, true
+		}
+	}
+	if hash := target.GetExecutable().GetHash(); hash.GetType() == santapb.Hash_HASH_ALGO_SHA256 {
+		h := strings.ToLower(hash.GetHash())
+		if _, ok := a.sha256[h]; ok {
+			return "sha256=" + h, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package allowlist
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+const testAllowlist = `team_ids: ["EQHXZ8M8AV"]
+signing_ids: ["platform:com.apple.ls", "2ZEFAR8TH3:com.jetbrains.goland"]
+cdhashes: ["00112233445566778899AABBCCDDEEFF00112233"]
+sha256: ["e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"]
+`
+
+func execution(teamID, signingID, cdhash, sha256 string) *santapb.SantaMessage {
+	return signedExecution(teamID, signingID, cdhash, sha256, false)
+}
+
+func signedExecution(teamID, signingID, cdhash, sha256 string, platform bool) *santapb.SantaMessage {
+	cs := &santapb.CodeSignature{}
+	if teamID != "" {
+		cs.TeamId = proto.String(teamID)
+	}
+	if signingID != "" {
+		cs.SigningId = proto.String(signingID)
+	}
+	if cdhash != "" {
+		cs.Cdhash, _ = hex.DecodeString(cdhash)
+	}
+	exe := &santapb.FileInfo{Path: proto.String("/usr/bin/true")}
+	if sha256 != "" {
+		algo := santapb.Hash_HASH_ALGO_SHA256
+		exe.Hash = &santapb.Hash{Type: &algo, Hash: proto.String(sha256)}
+	}
+	return &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{CodeSignature: cs, Executable: exe, IsPlatformBinary: proto.Bool(platform)},
+			},
+		},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist.yaml")
+	if err := os.WriteFile(path, []byte(testAllowlist), 0644); err != nil {
+		t.Fatalf("Failed to write allowlist: %v", err)
+	}
+	a, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load allowlist: %v", err)
+	}
+	if a.Len() != 5 {
+		t.Errorf("Expected 5 entries, got %d", a.Len())
+	}
+
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want string
+	}{
+		{name: "team id", msg: execution("EQHXZ8M8AV", "com.google.Chrome", "", ""), want: "team_id=EQHXZ8M8AV"},
+		{name: "platform signing id", msg: signedExecution("", "com.apple.ls", "", "", true), want: "signing_id=platform:com.apple.ls"},
+		{name: "team signing id", msg: execution("2ZEFAR8TH3", "com.jetbrains.goland", "", ""), want: "signing_id=2ZEFAR8TH3:com.jetbrains.goland"},
+		{name: "ad hoc signing id", msg: execution("", "com.apple.ls", "", "")},
+		{name: "other team signing id", msg: execution("ABCDE12345", "com.jetbrains.goland", "", "")},
+		{name: "cdhash", msg: execution("", "", "00112233445566778899aabbccddeeff00112233", ""), want: "cdhash=00112233445566778899aabbccddeeff00112233"},
+		{name: "sha256 upper case", msg: execution("", "", "", "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"), want: "sha256=e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "unknown", msg: execution("ABCDE12345", "com.example.tool", "", "")},
+		{name: "not an execution", msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := a.Match(tt.msg)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Match() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	var nilList *Allowlist
+	if _, ok := nilList.Match(execution("EQHXZ8M8AV", "", "", "")); ok {
+		t.Error("Nil allowlist matched")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "short cdhash", data: `cdhashes: ["0011"]`, want: "cdhashes"},
+		{name: "not hex", data: `sha256: ["zz"]`, want: "sha256"},
+		{name: "empty team id", data: `team_ids: [""]`, want: "team_ids"},
+		{name: "bare signing id", data: `signing_ids: ["com.apple.ls"]`, want: "TEAMID:signing_id"},
+		{name: "signing id without team", data: `signing_ids: [":com.apple.ls"]`, want: "TEAMID:signing_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -2,19 +2,23 @@ package baseline
 
 import (
 	"fmt"
-	"log/slog"
+	"math"
 	"strings"
 	"time"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
 	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/logutil"
 	"github.com/0x4d31/santamon/internal/rules"
 	"github.com/0x4d31/santamon/internal/state"
 )
 
+var logger = logutil.For("baseline").Slog()
+
 // Processor evaluates baseline rules and tracks first-seen patterns
 type Processor struct {
-	db *state.DB
+	db        state.Store
+	eventTime bool // Measure learning periods in event time (replay)
 }
 
 // BaselineMatch represents a baseline rule match (first occurrence)
@@ -24,34 +28,58 @@ type BaselineMatch struct {
 	Severity    string
 	Tags        []string
 	Description string
-	Pattern     string // The unique pattern that was seen
+	Pattern     string // The unique pattern that was seen, prefixed by its scope
+	Scope       string // Values of the rule's scope fields, if it has any
 	Message     *santapb.SantaMessage
 	Timestamp   time.Time
-	InLearning  bool // Whether this occurred during learning period
+	InLearning  bool                // Whether this occurred during learning period
+	LastSeen    time.Time           // When a pattern forgotten after forget_after was last seen, else zero
+	Occurrences int                 // Times the pattern has been seen, including this one
+	Patterns    int                 // Distinct patterns the rule tracks
+	Sightings   int                 // Sightings of all patterns the rule tracks
+	Rarity      float64             // Share of the rule's sightings that were other patterns
+	Rule        *rules.BaselineRule // Keep reference to rule for signal generation
 }
 
 // NewProcessor creates a new baseline processor
-func NewProcessor(db *state.DB) *Processor {
+func NewProcessor(db state.Store) *Processor {
 	return &Processor{
 		db: db,
 	}
 }
 
+// UseEventTime measures learning periods against each event's timestamp
+// instead of the wall clock, for replaying historical events
+func (p *Processor) UseEventTime() {
+	p.eventTime = true
+}
+
 // Process evaluates an event against baseline rules.
 func (p *Processor) Process(
 	msg *santapb.SantaMessage,
 	baselines []*rules.CompiledBaseline,
 	engine *rules.Engine,
+) ([]*BaselineMatch, error) {
+	return p.ProcessEvent(rules.NewEvent(msg), baselines, engine)
+}
+
+// ProcessEvent evaluates an event against baseline rules, reusing its cached
+// activation and event map.
+func (p *Processor) ProcessEvent(
+	ev *rules.Event,
+	baselines []*rules.CompiledBaseline,
+	engine *rules.Engine,
 ) ([]*BaselineMatch, error) {
 	if len(baselines) == 0 {
 		return nil, nil
 	}
+	msg := ev.Message()
 
-	// Build typed activation with enum constants for CEL evaluation.
+	// Typed activation with enum constants for CEL evaluation.
 	// Note: We use typed protobuf for CEL (fast, type-safe), but convert to map
-	// for pattern extraction (flexible field access). ToMap is called lazily
+	// for pattern extraction (flexible field access). The map is built lazily
 	// only after filter matches (~1% of events) to minimize overhead.
-	activation := rules.BuildActivation(msg)
+	activation := ev.Activation()
 
 	matches := make([]*BaselineMatch, 0, 1) // Most events won't match
 
@@ -59,46 +87,58 @@ func (p *Processor) Process(
 		// Evaluate filter expression against typed protobuf
 		result, _, err := baseline.Program.Eval(activation)
 		if err != nil {
-			slog.Warn("baseline filter evaluation error", "rule_id", baseline.Rule.ID, "error", err)
+			logger.Warn("baseline filter evaluation error", "rule_id", baseline.Rule.ID, "error", err)
 			continue
 		}
 
 		matched, ok := result.Value().(bool)
 		if !ok {
-			slog.Warn("baseline filter returned non-boolean", "rule_id", baseline.Rule.ID)
+			logger.Warn("baseline filter returned non-boolean", "rule_id", baseline.Rule.ID)
 			continue
 		}
 
-		if !matched {
+		if !matched || baseline.Exceptions.Suppress(activation) {
 			continue
 		}
 
 		// Only convert to map after filter matches (lazy evaluation for performance).
 		// Pattern extraction needs flattened map structure for flexible field access.
-		eventMap, err := events.ToMap(msg)
+		eventMap, err := ev.Map()
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert message to map: %w", err)
 		}
-		events.BuildActivation(msg, eventMap)
 
-		// Extract pattern to track (use event map for field extraction)
+		// Extract pattern to track (use event map for field extraction).
+		// A scoped rule learns a baseline per scope value, so the scope
+		// leads the pattern.
 		pattern := p.extractPattern(eventMap, baseline.Rule.Track)
+		var scope string
+		if len(baseline.Rule.Scope) > 0 {
+			scope = p.extractPattern(eventMap, baseline.Rule.Scope)
+			pattern = scope + "|" + pattern
+		}
 
-		// Check if we've seen this pattern before
-		isFirst, err := p.db.IsFirstSeen(baseline.Rule.ID, pattern)
+		// Count sightings of the pattern, within forget_after
+		now := p.now(msg)
+		count, lastSeen, err := p.db.RecordSeen(baseline.Rule.ID, pattern, now, baseline.Rule.ForgetAfter)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check first seen for %s: %w", baseline.Rule.ID, err)
 		}
 
-		if isFirst {
-			inLearning := engine.IsInLearningPeriod(baseline.Rule)
+		if baseline.Rule.Alerts(count) {
+			inLearning := engine.IsInLearningPeriodAt(baseline.Rule, now)
 
 			if inLearning {
-				slog.Debug("baseline match during learning period",
+				logger.Debug("baseline match during learning period",
 					"rule_id", baseline.Rule.ID,
 					"pattern", pattern)
 			}
 
+			patterns, sightings, err := p.db.FirstSeenStats(baseline.Rule.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read baseline statistics for %s: %w", baseline.Rule.ID, err)
+			}
+
 			matches = append(matches, &BaselineMatch{
 				RuleID:      baseline.Rule.ID,
 				Title:       baseline.Rule.Title,
@@ -106,9 +146,16 @@ func (p *Processor) Process(
 				Tags:        baseline.Rule.Tags,
 				Description: baseline.Rule.Description,
 				Pattern:     pattern,
+				Scope:       scope,
 				Message:     msg,
 				Timestamp:   events.EventTime(msg),
 				InLearning:  inLearning,
+				LastSeen:    lastSeen,
+				Occurrences: count,
+				Patterns:    patterns,
+				Sightings:   sightings,
+				Rarity:      rarity(count, sightings),
+				Rule:        baseline.Rule,
 			})
 		}
 	}
@@ -116,6 +163,16 @@ func (p *Processor) Process(
 	return matches, nil
 }
 
+// rarity scores a pattern seen count times out of the rule's sightings, from
+// 0 when it is all the rule has seen to nearly 1 when it is a needle in a
+// large haystack
+func rarity(count, sightings int) float64 {
+	if sightings <= 0 || count >= sightings {
+		return 0
+	}
+	return math.Round((1-float64(count)/float64(sightings))*1000) / 1000
+}
+
 // extractPattern builds a unique pattern from tracked fields.
 // The pattern is used to deduplicate baseline matches - only the first occurrence
 // of each unique pattern triggers an alert.
@@ -133,3 +190,13 @@ func (p *Processor) extractPattern(event map[string]any, trackFields []string) s
 
 	return strings.Join(parts, "|")
 }
+
+// now returns the time learning periods are measured at
+func (p *Processor) now(msg *santapb.SantaMessage) time.Time {
+	if p.eventTime {
+		if ts := events.EventTime(msg); !ts.IsZero() {
+			return ts
+		}
+	}
+	return time.Now()
+}
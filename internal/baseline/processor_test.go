@@ -178,6 +178,249 @@ func TestProcessLearningPeriod(t *testing.T) {
 	}
 }
 
+func TestProcessLearningPeriodEventTime(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	proc := NewProcessor(db)
+	proc.UseEventTime()
+	engine, _ := rules.NewEngine()
+
+	baseline := &rules.BaselineRule{
+		ID:             "TEST-REPLAY",
+		Title:          "Replay learning test",
+		Expr:           "kind == \"execution\"",
+		Track:          []string{"execution.target.executable.path"},
+		Severity:       "low",
+		Enabled:        true,
+		LearningPeriod: time.Hour,
+	}
+	compiled, err := compileBaseline(t, engine, baseline)
+	if err != nil {
+		t.Fatalf("Failed to compile baseline: %v", err)
+	}
+
+	// A month-old event, ten minutes after the first replayed event: still
+	// learning in event time, though the wall-clock period is long over
+	msg := createTestMessage(t, "DECISION_UNKNOWN")
+	eventTime := time.Now().Add(-30 * 24 * time.Hour)
+	msg.EventTime = timestamppb.New(eventTime)
+	engine.SetStartTime(eventTime.Add(-10 * time.Minute))
+
+	matches, err := proc.Process(msg, []*rules.CompiledBaseline{compiled}, engine)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(matches) != 1 || !matches[0].InLearning {
+		t.Errorf("Expected one match during the learning period, got %+v", matches)
+	}
+}
+
+func TestProcessForgetAfter(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	proc := NewProcessor(db)
+	proc.UseEventTime()
+	engine, _ := rules.NewEngine()
+
+	baseline := &rules.BaselineRule{
+		ID:          "TEST-FORGET",
+		Title:       "Forget test",
+		Expr:        "kind == \"execution\"",
+		Track:       []string{"execution.target.executable.path"},
+		Severity:    "low",
+		Enabled:     true,
+		ForgetAfter: 30 * 24 * time.Hour,
+	}
+	compiled, err := compileBaseline(t, engine, baseline)
+	if err != nil {
+		t.Fatalf("Failed to compile baseline: %v", err)
+	}
+
+	start := time.Now().Add(-100 * 24 * time.Hour)
+	tests := []struct {
+		name     string
+		age      time.Duration // Since start
+		want     bool
+		lastSeen time.Duration // Since start, when the match reports a return
+	}{
+		{"first occurrence", 0, true, -1},
+		{"seen within forget_after", 20 * 24 * time.Hour, false, -1},
+		{"unseen less than forget_after", 45 * 24 * time.Hour, false, -1},
+		{"returned after forget_after", 80 * 24 * time.Hour, true, 45 * 24 * time.Hour},
+		{"seen again", 81 * 24 * time.Hour, false, -1},
+	}
+	for _, tt := range tests {
+		msg := createTestMessage(t, "DECISION_ALLOW")
+		msg.EventTime = timestamppb.New(start.Add(tt.age))
+
+		matches, err := proc.Process(msg, []*rules.CompiledBaseline{compiled}, engine)
+		if err != nil {
+			t.Fatalf("%s: Process failed: %v", tt.name, err)
+		}
+		if got := len(matches) == 1; got != tt.want {
+			t.Fatalf("%s: matched = %v, want %v", tt.name, got, tt.want)
+		}
+		if !tt.want {
+			continue
+		}
+		if tt.lastSeen < 0 {
+			if !matches[0].LastSeen.IsZero() {
+				t.Errorf("%s: LastSeen = %v, want zero", tt.name, matches[0].LastSeen)
+			}
+		} else if want := start.Add(tt.lastSeen); !matches[0].LastSeen.Equal(want) {
+			t.Errorf("%s: LastSeen = %v, want %v", tt.name, matches[0].LastSeen, want)
+		}
+	}
+}
+
+func TestProcessMinOccurrences(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	proc := NewProcessor(db)
+	engine, _ := rules.NewEngine()
+
+	baseline := &rules.BaselineRule{
+		ID:             "TEST-MIN-OCC",
+		Title:          "Min occurrences test",
+		Expr:           "kind == \"execution\"",
+		Track:          []string{"execution.target.executable.path"},
+		Severity:       "low",
+		Enabled:        true,
+		MinOccurrences: 3,
+	}
+	compiled, err := compileBaseline(t, engine, baseline)
+	if err != nil {
+		t.Fatalf("Failed to compile baseline: %v", err)
+	}
+
+	// The first two sightings alert; the third baselines the pattern
+	for i, want := range []bool{true, true, false, false} {
+		matches, err := proc.Process(createTestMessage(t, "DECISION_ALLOW"), []*rules.CompiledBaseline{compiled}, engine)
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		if got := len(matches) == 1; got != want {
+			t.Fatalf("sighting %d: matched = %v, want %v", i+1, got, want)
+		}
+		if want && matches[0].Occurrences != i+1 {
+			t.Errorf("sighting %d: Occurrences = %d", i+1, matches[0].Occurrences)
+		}
+	}
+}
+
+func TestProcessScope(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	proc := NewProcessor(db)
+	engine, _ := rules.NewEngine()
+
+	baseline := &rules.BaselineRule{
+		ID:       "TEST-SCOPE",
+		Title:    "Scope test",
+		Expr:     "kind == \"execution\"",
+		Track:    []string{"execution.target.executable.path"},
+		Scope:    []string{"event.execution.instigator.effective_user.name"},
+		Severity: "low",
+		Enabled:  true,
+	}
+	compiled, err := compileBaseline(t, engine, baseline)
+	if err != nil {
+		t.Fatalf("Failed to compile baseline: %v", err)
+	}
+
+	// The same binary is new once per user
+	for i, tt := range []struct {
+		user string
+		want bool
+	}{
+		{"alice", true},
+		{"alice", false},
+		{"bob", true},
+	} {
+		msg := createTestMessage(t, "DECISION_ALLOW")
+		msg.GetExecution().Instigator.EffectiveUser.Name = proto.String(tt.user)
+
+		matches, err := proc.Process(msg, []*rules.CompiledBaseline{compiled}, engine)
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		if got := len(matches) == 1; got != tt.want {
+			t.Fatalf("event %d: matched = %v, want %v", i, got, tt.want)
+		}
+		if !tt.want {
+			continue
+		}
+		wantScope := "execution.instigator.effective_user.name=" + tt.user
+		if matches[0].Scope != wantScope {
+			t.Errorf("Scope = %q, want %q", matches[0].Scope, wantScope)
+		}
+		if want := wantScope + "|execution.target.executable.path=/usr/bin/curl"; matches[0].Pattern != want {
+			t.Errorf("Pattern = %q, want %q", matches[0].Pattern, want)
+		}
+	}
+}
+
+func TestProcessRarity(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	proc := NewProcessor(db)
+	engine, _ := rules.NewEngine()
+
+	baseline := &rules.BaselineRule{
+		ID:       "TEST-RARITY",
+		Title:    "Rarity test",
+		Expr:     "kind == \"execution\"",
+		Track:    []string{"execution.target.executable.path"},
+		Severity: "low",
+		Enabled:  true,
+	}
+	compiled, err := compileBaseline(t, engine, baseline)
+	if err != nil {
+		t.Fatalf("Failed to compile baseline: %v", err)
+	}
+
+	// A common pattern seen 9 times makes the new one rare
+	for range 9 {
+		if _, _, err := db.RecordSeen(baseline.ID, "execution.target.executable.path=/bin/common", time.Now(), 0); err != nil {
+			t.Fatalf("Failed to record sighting: %v", err)
+		}
+	}
+
+	matches, err := proc.Process(createTestMessage(t, "DECISION_ALLOW"), []*rules.CompiledBaseline{compiled}, engine)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(matches))
+	}
+	if m := matches[0]; m.Patterns != 2 || m.Sightings != 10 || m.Rarity != 0.9 {
+		t.Errorf("Patterns, Sightings, Rarity = %d, %d, %v, want 2, 10, 0.9", m.Patterns, m.Sightings, m.Rarity)
+	}
+}
+
+func TestRarity(t *testing.T) {
+	tests := []struct {
+		count, sightings int
+		want             float64
+	}{
+		{1, 1, 0},
+		{1, 4, 0.75},
+		{1, 3, 0.667},
+		{2, 1000, 0.998},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := rarity(tt.count, tt.sightings); got != tt.want {
+			t.Errorf("rarity(%d, %d) = %v, want %v", tt.count, tt.sightings, got, tt.want)
+		}
+	}
+}
+
 func TestProcessMultipleTrackFields(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() { _ = db.Close() }()
@@ -384,6 +627,45 @@ func TestProcessFilterNotMatching(t *testing.T) {
 	}
 }
 
+func TestProcessExceptions(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	proc := NewProcessor(db)
+	engine, _ := rules.NewEngine()
+	err := engine.LoadRules(&rules.RulesConfig{Baselines: []*rules.BaselineRule{{
+		ID:       "TEST-EXC",
+		Title:    "New executable",
+		Expr:     `kind == "execution"`,
+		Track:    []string{"execution.target.executable.path"},
+		Severity: "high",
+		Enabled:  true,
+		Exceptions: []rules.Exception{
+			{Field: "event.execution.target.executable.path", Values: []string{"/usr/bin/curl"}},
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	msg := createTestMessage(t, "DECISION_ALLOW")
+	matches, err := proc.Process(msg, engine.GetBaselines(), engine)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("Expected excepted event to be ignored, got %d matches", len(matches))
+	}
+	if got := engine.Suppressions()["TEST-EXC"]; got != 1 {
+		t.Errorf("Expected 1 suppression, got %d", got)
+	}
+
+	// The excepted pattern was not recorded, so it is still first-seen
+	if isFirst, err := db.IsFirstSeen("TEST-EXC", "execution.target.executable.path=/usr/bin/curl"); err != nil || !isFirst {
+		t.Errorf("Expected excepted pattern not to be tracked, got %v, %v", isFirst, err)
+	}
+}
+
 func TestExtractPattern(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() { _ = db.Close() }()
@@ -575,6 +857,6 @@ func compileBaseline(t *testing.T, engine *rules.Engine, baseline *rules.Baselin
 
 	return &rules.CompiledBaseline{
 		Rule:    baseline,
-		Program: program,
+		Program: &rules.Program{Program: program},
 	}, nil
 }
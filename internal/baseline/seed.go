@@ -0,0 +1,95 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// seedVersion is the version of the seed format Export writes
+const seedVersion = 1
+
+// Seed holds the baseline patterns learned on one host, for pre-seeding
+// other hosts so they skip per-host learning
+type Seed struct {
+	Version    int           `json:"version"`
+	AgentID    string        `json:"agent_id,omitempty"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Patterns   []SeedPattern `json:"patterns"`
+}
+
+// SeedPattern is one learned baseline pattern
+type SeedPattern struct {
+	RuleID    string    `json:"rule_id"`
+	Pattern   string    `json:"pattern"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+// Export returns the patterns the baseline rules ruleIDs have learned
+func Export(db state.Store, agentID string, ruleIDs []string) (*Seed, error) {
+	seed := &Seed{
+		Version:    seedVersion,
+		AgentID:    agentID,
+		ExportedAt: time.Now().UTC(),
+		Patterns:   make([]SeedPattern, 0),
+	}
+	for _, ruleID := range ruleIDs {
+		entries, err := db.FirstSeenEntries(ruleID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read patterns of %s: %w", ruleID, err)
+		}
+		for _, pattern := range slices.Sorted(maps.Keys(entries)) {
+			entry := entries[pattern]
+			seed.Patterns = append(seed.Patterns, SeedPattern{
+				RuleID:    ruleID,
+				Pattern:   pattern,
+				FirstSeen: entry.First,
+				LastSeen:  entry.Last,
+				Count:     entry.Count,
+			})
+		}
+	}
+	return seed, nil
+}
+
+// ParseSeed decodes an exported seed
+func ParseSeed(data []byte) (*Seed, error) {
+	var seed Seed
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline seed: %w", err)
+	}
+	if seed.Version != seedVersion {
+		return nil, fmt.Errorf("unsupported baseline seed version %d", seed.Version)
+	}
+	return &seed, nil
+}
+
+// Import merges the patterns of seed into the first-seen store and returns
+// how many were new. Patterns a host already learned keep their own history,
+// extended by the seed's sightings.
+func Import(db state.Store, seed *Seed) (int, error) {
+	added := 0
+	for _, p := range seed.Patterns {
+		if p.RuleID == "" || p.Pattern == "" {
+			continue
+		}
+		isNew, err := db.SeedFirstSeen(p.RuleID, p.Pattern, state.FirstSeenEntry{
+			First: p.FirstSeen,
+			Count: max(p.Count, 1),
+			Last:  p.LastSeen,
+		})
+		if err != nil {
+			return added, fmt.Errorf("failed to import pattern of %s: %w", p.RuleID, err)
+		}
+		if isNew {
+			added++
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,91 @@
+package baseline
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSeedRoundTrip(t *testing.T) {
+	golden := setupTestDB(t)
+	defer func() { _ = golden.Close() }()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, pattern := range []string{"/bin/b", "/bin/a", "/bin/a"} {
+		if _, _, err := golden.RecordSeen("BL-1", pattern, now, 0); err != nil {
+			t.Fatalf("Failed to record sighting: %v", err)
+		}
+	}
+	if _, _, err := golden.RecordSeen("BL-OTHER", "/bin/c", now, 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+
+	seed, err := Export(golden, "golden", []string{"BL-1"})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(seed.Patterns) != 2 || seed.Patterns[0].Pattern != "/bin/a" || seed.Patterns[0].Count != 2 {
+		t.Fatalf("Unexpected exported patterns: %+v", seed.Patterns)
+	}
+
+	data, err := json.Marshal(seed)
+	if err != nil {
+		t.Fatalf("Failed to encode seed: %v", err)
+	}
+	parsed, err := ParseSeed(data)
+	if err != nil {
+		t.Fatalf("ParseSeed failed: %v", err)
+	}
+
+	host := setupTestDB(t)
+	defer func() { _ = host.Close() }()
+	if _, _, err := host.RecordSeen("BL-1", "/bin/b", now, 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+
+	added, err := Import(host, parsed)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("Expected 1 new pattern, got %d", added)
+	}
+	for _, pattern := range []string{"/bin/a", "/bin/b"} {
+		first, err := host.IsFirstSeen("BL-1", pattern)
+		if err != nil {
+			t.Fatalf("Failed to check first seen: %v", err)
+		}
+		if first {
+			t.Errorf("Expected %s to be known after import", pattern)
+		}
+	}
+	first, err := host.IsFirstSeen("BL-OTHER", "/bin/c")
+	if err != nil {
+		t.Fatalf("Failed to check first seen: %v", err)
+	}
+	if !first {
+		t.Error("Expected patterns of unexported rules to stay unknown")
+	}
+}
+
+func TestParseSeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `{"version": 1, "patterns": []}`, false},
+		{"unsupported version", `{"version": 2, "patterns": []}`, true},
+		{"missing version", `{"patterns": []}`, true},
+		{"invalid json", `{`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSeed([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSeed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package baseline
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// maxSummaryPatterns bounds the patterns listed in a learning summary
+const maxSummaryPatterns = 100
+
+// LearningSummary describes what a baseline rule learned during its
+// learning period
+type LearningSummary struct {
+	Rule      *rules.BaselineRule
+	Start     time.Time
+	End       time.Time
+	Patterns  []string // Learned patterns, most seen first, at most maxSummaryPatterns
+	Total     int      // Learned patterns, including those not listed
+	Sightings int      // Sightings of all learned patterns
+}
+
+// Summarize builds the learning summary of rule, whose learning period ran
+// from start to end
+func Summarize(db state.Store, rule *rules.BaselineRule, start, end time.Time) (*LearningSummary, error) {
+	entries, err := db.FirstSeenEntries(rule.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patterns of %s: %w", rule.ID, err)
+	}
+
+	patterns := make([]string, 0, len(entries))
+	sightings := 0
+	for pattern, entry := range entries {
+		patterns = append(patterns, pattern)
+		sightings += entry.Count
+	}
+	slices.SortFunc(patterns, func(a, b string) int {
+		return cmp.Or(cmp.Compare(entries[b].Count, entries[a].Count), cmp.Compare(a, b))
+	})
+
+	return &LearningSummary{
+		Rule:      rule,
+		Start:     start,
+		End:       end,
+		Patterns:  patterns[:min(len(patterns), maxSummaryPatterns)],
+		Total:     len(patterns),
+		Sightings: sightings,
+	}, nil
+}
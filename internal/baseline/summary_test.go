@@ -0,0 +1,60 @@
+package baseline
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+func TestSummarize(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	sightings := map[string]int{"/bin/rare": 1, "/bin/common": 5, "/bin/b": 2, "/bin/a": 2}
+	for pattern, n := range sightings {
+		for range n {
+			if _, _, err := db.RecordSeen("BL-1", pattern, now, 0); err != nil {
+				t.Fatalf("Failed to record sighting: %v", err)
+			}
+		}
+	}
+	if _, _, err := db.RecordSeen("BL-2", "/bin/other", now, 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+
+	rule := &rules.BaselineRule{ID: "BL-1", LearningPeriod: time.Hour}
+	summary, err := Summarize(db, rule, now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	want := []string{"/bin/common", "/bin/a", "/bin/b", "/bin/rare"}
+	if !slices.Equal(summary.Patterns, want) {
+		t.Errorf("Patterns = %v, want %v", summary.Patterns, want)
+	}
+	if summary.Total != 4 || summary.Sightings != 10 {
+		t.Errorf("Total, Sightings = %d, %d, want 4, 10", summary.Total, summary.Sightings)
+	}
+}
+
+func TestSummarizeTruncates(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	for i := range maxSummaryPatterns + 5 {
+		if _, _, err := db.RecordSeen("BL-1", fmt.Sprintf("/bin/%03d", i), time.Now(), 0); err != nil {
+			t.Fatalf("Failed to record sighting: %v", err)
+		}
+	}
+
+	summary, err := Summarize(db, &rules.BaselineRule{ID: "BL-1"}, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(summary.Patterns) != maxSummaryPatterns || summary.Total != maxSummaryPatterns+5 {
+		t.Errorf("Listed %d of %d patterns, want %d of %d", len(summary.Patterns), summary.Total, maxSummaryPatterns, maxSummaryPatterns+5)
+	}
+}
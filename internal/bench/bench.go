@@ -0,0 +1,135 @@
+package bench
+
+import (
+	"sort"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/baseline"
+	"github.com/0x4d31/santamon/internal/correlation"
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+// Rule types reported by Run
+const (
+	TypeRule        = "rule"
+	TypeCorrelation = "correlation"
+	TypeBaseline    = "baseline"
+)
+
+// RuleResult is the measured cost of one rule over the corpus
+type RuleResult struct {
+	RuleID      string        `json:"rule_id"`
+	Type        string        `json:"type"`
+	Evaluations int           `json:"evaluations"` // Events of the kinds the rule applies to
+	Matches     int           `json:"matches"`
+	Total       time.Duration `json:"total_ns"`
+}
+
+// Average returns the mean time of one evaluation
+func (r *RuleResult) Average() time.Duration {
+	if r.Evaluations == 0 {
+		return 0
+	}
+	return r.Total / time.Duration(r.Evaluations)
+}
+
+// Report is the result of a benchmark run
+type Report struct {
+	Events   int           `json:"events"`   // Events in the corpus
+	Passes   int           `json:"passes"`   // Times the corpus was evaluated
+	Duration time.Duration `json:"total_ns"` // Wall time of all passes
+	Rules    []*RuleResult `json:"rules"`    // Slowest average evaluation first
+}
+
+// Runner evaluates a corpus against a compiled rule set. Correlation and
+// baseline rules are evaluated one at a time so each rule's window and
+// pattern bookkeeping is timed with it; they need Windows and Baselines,
+// which should use a scratch state DB since the run records state.
+type Runner struct {
+	Engine    *rules.Engine
+	Windows   *correlation.WindowManager // nil skips correlation rules
+	Baselines *baseline.Processor        // nil skips baseline rules
+}
+
+// Run evaluates every event of corpus passes times and returns the per-rule
+// cost. Events are evaluated in order, so correlation windows see the corpus
+// as a stream.
+func (r *Runner) Run(corpus []*rules.Event, passes int) *Report {
+	passes = max(passes, 1)
+	results := make(map[string]*RuleResult)
+	result := func(id, typ string) *RuleResult {
+		res := results[typ+"/"+id]
+		if res == nil {
+			res = &RuleResult{RuleID: id, Type: typ}
+			results[typ+"/"+id] = res
+		}
+		return res
+	}
+
+	stats := make(map[string]*rules.RuleStats)
+	r.Engine.CollectStats(stats)
+	defer r.Engine.CollectStats(nil)
+
+	var correlations []*rules.CompiledCorrelation
+	if r.Windows != nil {
+		correlations = r.Engine.GetCorrelations()
+	}
+	var baselines []*rules.CompiledBaseline
+	if r.Baselines != nil {
+		baselines = r.Engine.GetBaselines()
+	}
+
+	// The activation and event map are built once per event and shared by
+	// all rules in the agent, so they are built up front, not charged to
+	// whichever rule needs them first
+	for _, ev := range corpus {
+		ev.Activation()
+		_, _ = ev.Map()
+	}
+
+	start := time.Now()
+	for range passes {
+		for _, ev := range corpus {
+			// Rule evaluation errors are logged by the engine and not timed
+			_, _ = r.Engine.EvaluateEvent(ev)
+
+			for _, c := range correlations {
+				res := result(c.Rule.ID, TypeCorrelation)
+				t := time.Now()
+				matches, err := r.Windows.ProcessEvent(ev, []*rules.CompiledCorrelation{c})
+				res.Total += time.Since(t)
+				if err == nil {
+					res.Evaluations++
+					res.Matches += len(matches)
+				}
+			}
+			for _, b := range baselines {
+				res := result(b.Rule.ID, TypeBaseline)
+				t := time.Now()
+				matches, err := r.Baselines.ProcessEvent(ev, []*rules.CompiledBaseline{b}, r.Engine)
+				res.Total += time.Since(t)
+				if err == nil {
+					res.Evaluations++
+					res.Matches += len(matches)
+				}
+			}
+		}
+	}
+	report := &Report{Events: len(corpus), Passes: passes, Duration: time.Since(start)}
+
+	for id, st := range stats {
+		res := result(id, TypeRule)
+		res.Evaluations, res.Matches, res.Total = st.Evaluations, st.Matches, st.Duration
+	}
+	for _, res := range results {
+		report.Rules = append(report.Rules, res)
+	}
+	sort.Slice(report.Rules, func(i, j int) bool {
+		a, b := report.Rules[i], report.Rules[j]
+		if a.Average() != b.Average() {
+			return a.Average() > b.Average()
+		}
+		return a.RuleID < b.RuleID
+	})
+	return report
+}
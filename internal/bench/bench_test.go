@@ -0,0 +1,119 @@
+package bench
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/0x4d31/santamon/internal/baseline"
+	"github.com/0x4d31/santamon/internal/correlation"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+const testRules = `
+rules:
+  - id: TMP-EXEC
+    title: Execution from /tmp
+    expr: kind == "execution" && event.execution.target.executable.path.startsWith("/tmp/")
+    severity: high
+    enabled: true
+  - id: FILE-ACCESS
+    title: File access
+    expr: kind == "file_access"
+    severity: low
+    enabled: true
+correlations:
+  - id: TMP-BURST
+    title: Burst of /tmp executions
+    expr: kind == "execution" && event.execution.target.executable.path.startsWith("/tmp/")
+    window: 5m
+    group_by: ["machine_id"]
+    threshold: 2
+    severity: high
+    enabled: true
+baselines:
+  - id: NEW-PATH
+    title: New executable path
+    expr: kind == "execution"
+    track: ["event.execution.target.executable.path"]
+    severity: medium
+    enabled: true
+`
+
+func execEvent(path string, at time.Time) *rules.Event {
+	return rules.NewEvent(&santapb.SantaMessage{
+		MachineId: proto.String("host-1"),
+		EventTime: timestamppb.New(at),
+		Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+			Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}},
+		}},
+	})
+}
+
+func TestRun(t *testing.T) {
+	rc, err := rules.Parse([]byte(testRules))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+	db, err := state.Open(filepath.Join(t.TempDir(), "state.db"), 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	windows := correlation.NewWindowManager(db, 100, time.Minute)
+	windows.UseEventTime()
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	corpus := []*rules.Event{
+		execEvent("/tmp/a", now),
+		execEvent("/bin/ls", now.Add(time.Second)),
+		execEvent("/tmp/b", now.Add(2*time.Second)),
+	}
+	r := &Runner{Engine: engine, Windows: windows, Baselines: baseline.NewProcessor(db)}
+	report := r.Run(corpus, 2)
+
+	if report.Events != 3 || report.Passes != 2 {
+		t.Errorf("Report covers %d events in %d passes, want 3 in 2", report.Events, report.Passes)
+	}
+	byID := make(map[string]*RuleResult)
+	for _, res := range report.Rules {
+		byID[res.RuleID] = res
+	}
+	if res := byID["TMP-EXEC"]; res == nil || res.Type != TypeRule || res.Evaluations != 6 || res.Matches != 4 {
+		t.Errorf("TMP-EXEC = %+v, want 6 evaluations and 4 matches", res)
+	}
+	if _, ok := byID["FILE-ACCESS"]; ok {
+		t.Error("FILE-ACCESS only applies to file_access events and should not be reported")
+	}
+	if res := byID["TMP-BURST"]; res == nil || res.Type != TypeCorrelation || res.Evaluations != 6 || res.Matches == 0 {
+		t.Errorf("TMP-BURST = %+v, want 6 evaluations and a match", res)
+	}
+	if res := byID["NEW-PATH"]; res == nil || res.Type != TypeBaseline || res.Evaluations != 6 {
+		t.Errorf("NEW-PATH = %+v, want 6 evaluations", res)
+	}
+	for i := 1; i < len(report.Rules); i++ {
+		if report.Rules[i].Average() > report.Rules[i-1].Average() {
+			t.Errorf("Rules are not sorted slowest first: %v after %v", report.Rules[i].Average(), report.Rules[i-1].Average())
+		}
+	}
+
+	// Stats collection stops with the run
+	if _, err := engine.EvaluateEvent(corpus[0]); err != nil {
+		t.Fatal(err)
+	}
+	if byID["TMP-EXEC"].Evaluations != 6 {
+		t.Error("The engine kept timing rules after the run")
+	}
+}
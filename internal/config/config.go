@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,53 +15,304 @@ import (
 
 // Config represents the complete santamon configuration
 type Config struct {
-	Agent   AgentConfig   `yaml:"agent"`
-	Santa   SantaConfig   `yaml:"santa"`
-	Rules   RulesConfig   `yaml:"rules"`
-	State   StateConfig   `yaml:"state"`
-	Shipper ShipperConfig `yaml:"shipper"`
+	Agent    AgentConfig    `yaml:"agent"`
+	Santa    SantaConfig    `yaml:"santa"`
+	Rules    RulesConfig    `yaml:"rules"`
+	State    StateConfig    `yaml:"state"`
+	Shipper  ShipperConfig  `yaml:"shipper"`
+	Health   HealthConfig   `yaml:"health"`
+	Recorder RecorderConfig `yaml:"recorder"`
+	Identity IdentityConfig `yaml:"identity"`
+	Intel    IntelConfig    `yaml:"intel"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+
+	Reputation   ReputationConfig   `yaml:"reputation"`
+	YARA         YARAConfig         `yaml:"yara"`
+	LoadShedding LoadSheddingConfig `yaml:"load_shedding"`
+	Prefilter    PrefilterConfig    `yaml:"prefilter"`
+	SantaRules   SantaRulesConfig   `yaml:"santa_rules"`
+	Response     ResponseConfig     `yaml:"response"`
 }
 
 // AgentConfig contains agent-level settings
 type AgentConfig struct {
-	ID       string `yaml:"id"`
-	StateDir string `yaml:"state_dir"`
-	LogLevel string `yaml:"log_level"`
+	ID          string            `yaml:"id"`
+	StateDir    string            `yaml:"state_dir"`
+	LogLevel    string            `yaml:"log_level"`
+	LogFormat   string            `yaml:"log_format"`   // text (colorized console) or json
+	LogLevels   map[string]string `yaml:"log_levels"`   // Per-component overrides (e.g. shipper: debug)
+	LogFile     string            `yaml:"log_file"`     // Write logs here instead of stderr (optional)
+	LogRotation LogRotationConfig `yaml:"log_rotation"` // Rotation settings for log_file
+	ReloadOn    string            `yaml:"reload_on"`    // SIGHUP (default) or change (also reload when the file changes)
+	AdminSocket string            `yaml:"admin_socket"` // Unix socket for operator commands (santamon shipper queue)
+	Tags        map[string]string `yaml:"tags"`         // Host tags that rules select with hosts: (e.g. role: developer)
+
+	InventoryInterval time.Duration `yaml:"inventory_interval"` // How often the machine inventory is refreshed
+}
+
+// LogRotationConfig defines size-based rotation for the agent log file
+type LogRotationConfig struct {
+	MaxSizeMB  int           `yaml:"max_size_mb"` // Rotate once the file exceeds this size
+	MaxBackups int           `yaml:"max_backups"` // Rotated files to keep (default 5; -1 = unlimited)
+	MaxAge     time.Duration `yaml:"max_age"`     // Remove rotated files older than this (default 7d; negative, e.g. -1s = keep forever)
 }
 
 // SantaConfig defines Santa spool settings
 type SantaConfig struct {
+	Mode          string         `yaml:"mode"`
+	SpoolDir      SpoolDirs      `yaml:"spool_dir"` // One directory, or a list of sources with their own settings
+	ArchiveDir    string         `yaml:"archive_dir"`
+	Archive       ArchiveConfig  `yaml:"archive"`  // Compression, layout and retention of archive_dir
+	Pressure      PressureConfig `yaml:"pressure"` // Spool backlog and disk space monitoring
+	StabilityWait time.Duration  `yaml:"stability_wait"`
+	ClaimFiles    bool           `yaml:"claim_files"` // Claim files before processing when several agents share a spool
+	ClaimDir      string         `yaml:"claim_dir"`   // Per-agent work directory (must be on the spool filesystem)
+	Workers       int            `yaml:"workers"`     // Spool files claimed and decoded concurrently ahead of detection
+	EventLog      EventLogConfig `yaml:"event_log"`   // Read Santa's text event log when spool export is not enabled
+	Ingest        IngestConfig   `yaml:"ingest"`      // Accept event batches pushed by forwarders or remote agents
+}
+
+// IngestConfig defines the ingest server. Accepted batches are spooled into
+// spool_dir, which is watched as the ingest source.
+type IngestConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Listen     string `yaml:"listen"`       // Absolute unix socket path, or host:port
+	Token      string `yaml:"token"`        // Bearer token clients must present (required off loopback)
+	SpoolDir   string `yaml:"spool_dir"`    // Where accepted batches are spooled
+	MaxBatchMB int64  `yaml:"max_batch_mb"` // Largest accepted batch
+}
+
+// EventLogConfig defines ingest from Santa's text event log. Tailed events
+// are converted to protobuf and spooled into spool_dir, which is watched as
+// the santa_log source.
+type EventLogConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Source        string        `yaml:"source"`         // file (default): tail path; unified: stream the unified log
+	Path          string        `yaml:"path"`           // Santa's event log file (EventLogType filelog)
+	Predicate     string        `yaml:"predicate"`      // log stream predicate selecting Santa's events (EventLogType syslog)
+	SpoolDir      string        `yaml:"spool_dir"`      // Where converted events are spooled
+	BatchInterval time.Duration `yaml:"batch_interval"` // How often tailed events are written to the spool
+	BatchSize     int           `yaml:"batch_size"`     // Events per spool file
+}
+
+// ArchiveConfig bounds the spool archive so archiving cannot fill the disk
+type ArchiveConfig struct {
+	Compress  bool          `yaml:"compress"`    // zstd-compress archived files
+	Partition bool          `yaml:"partition"`   // Archive into daily YYYY-MM-DD subdirectories
+	MaxAge    time.Duration `yaml:"max_age"`     // Remove archived files older than this (0 = keep forever)
+	MaxSizeMB int64         `yaml:"max_size_mb"` // Remove the oldest archived files beyond this size (0 = unlimited)
+}
+
+// PressureConfig defines when the spool counts as under pressure: too much
+// waiting to be processed, or too little free space on its filesystem
+type PressureConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Interval   time.Duration `yaml:"interval"`     // How often spool size and free space are checked
+	MaxSpoolMB int64         `yaml:"max_spool_mb"` // Spool size that counts as falling behind (0 = not checked)
+	MinFreeMB  int64         `yaml:"min_free_mb"`  // Free space below which the disk is under pressure (0 = not checked)
+	Action     string        `yaml:"action"`       // none (default): signal only; sample: shed load; newest: process newest files first
+}
+
+// SpoolSource is one spool directory watched by the agent. Unset settings
+// default to the santa-level ones.
+type SpoolSource struct {
+	Path          string        `yaml:"path"`
+	Label         string        `yaml:"label"` // Tags events and signals from this source (default: directory name, when several)
 	Mode          string        `yaml:"mode"`
-	SpoolDir      string        `yaml:"spool_dir"`
-	ArchiveDir    string        `yaml:"archive_dir"`
 	StabilityWait time.Duration `yaml:"stability_wait"`
+	ClaimDir      string        `yaml:"claim_dir"` // Per-agent work directory on this source's filesystem
+}
+
+// SpoolDirs is santa.spool_dir: a single path, or a list of paths and sources
+type SpoolDirs []SpoolSource
+
+// UnmarshalYAML accepts a path or a list whose items are paths or sources
+func (d *SpoolDirs) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Tag == "!!null" || value.Value == "" {
+			*d = nil
+			return nil
+		}
+		*d = SpoolDirs{{Path: value.Value}}
+		return nil
+	case yaml.SequenceNode:
+		sources := make(SpoolDirs, 0, len(value.Content))
+		for _, item := range value.Content {
+			var src SpoolSource
+			if item.Kind == yaml.ScalarNode {
+				src.Path = item.Value
+			} else if err := item.Decode(&src); err != nil {
+				return err
+			}
+			sources = append(sources, src)
+		}
+		*d = sources
+		return nil
+	}
+	return fmt.Errorf("line %d: santa.spool_dir must be a path or a list of sources", value.Line)
+}
+
+// Paths returns the directory of each source
+func (d SpoolDirs) Paths() []string {
+	paths := make([]string, len(d))
+	for i, src := range d {
+		paths[i] = src.Path
+	}
+	return paths
 }
 
 // RulesConfig defines detection rules settings
 type RulesConfig struct {
-	Path     string `yaml:"path"`
-	ReloadOn string `yaml:"reload_on"`
+	Path           string            `yaml:"path"`
+	ReloadOn       string            `yaml:"reload_on"`       // SIGHUP (default) or change (also reload when files under path change)
+	ReloadDebounce time.Duration     `yaml:"reload_debounce"` // Quiet period after a change before reloading
+	Remote         RemoteRulesConfig `yaml:"remote"`
+	Rollback       RollbackConfig    `yaml:"rollback"`
+	Allowlist      AllowlistConfig   `yaml:"allowlist"`
+	Budget         BudgetConfig      `yaml:"budget"`
+	Workers        int               `yaml:"workers"` // Goroutines evaluating simple rules across a spool file's events
+
+	Suppressions string `yaml:"suppressions"` // Optional file of per-rule exceptions, managed apart from the rules
+	ShadowPath   string `yaml:"shadow_path"`  // Optional candidate rules evaluated alongside the active rules, never shipped
+
+	Packs     map[string]RuleOverrideConfig `yaml:"packs"`     // Enable, disable or re-grade every rule of a pack
+	Overrides map[string]RuleOverrideConfig `yaml:"overrides"` // Enable, disable or re-grade rules by ID; wins over packs
+}
+
+// RuleOverrideConfig changes a rule, or every rule of a pack, without
+// editing the rules files
+type RuleOverrideConfig struct {
+	Enabled  *bool  `yaml:"enabled"`  // Replaces the rules' enabled, when set
+	Severity string `yaml:"severity"` // Replaces the rules' severity: low, medium, high or critical
+}
+
+// BudgetConfig bounds the CEL evaluation cost and latency of a rule per
+// event. Rules that keep exceeding it, or that fail too often, are disabled
+// until the rules are reloaded.
+type BudgetConfig struct {
+	CostLimit      uint64        `yaml:"cost_limit"`      // CEL cost units per rule per event
+	LatencyLimit   time.Duration `yaml:"latency_limit"`   // Wall time per rule per event (0 = unlimited)
+	Timeout        time.Duration `yaml:"timeout"`         // Wall time after which a rule's comprehensions are interrupted
+	DisableAfter   int           `yaml:"disable_after"`   // Exceeded budgets before the rule is disabled
+	ErrorRate      float64       `yaml:"error_rate"`      // Share of failed evaluations that disables a rule (0 = never)
+	MinEvaluations int           `yaml:"min_evaluations"` // Evaluations before error_rate applies
+}
+
+// AllowlistConfig defines a global allowlist of trusted executables (team
+// IDs, signing IDs and hashes) checked before any rule evaluation
+type AllowlistConfig struct {
+	Path  string `yaml:"path"`  // YAML file with team_ids, signing_ids, cdhashes and sha256 lists
+	Scope string `yaml:"scope"` // rules (default): skip simple rules; all: also skip correlations and baselines
+}
+
+// RollbackConfig defines when newly loaded rules are rolled back to the
+// previous set: too many evaluation errors within the probation window.
+type RollbackConfig struct {
+	ErrorThreshold int           `yaml:"error_threshold"` // Evaluation errors that count as a storm
+	Window         time.Duration `yaml:"window"`          // Probation period after a reload
+}
+
+// RemoteRulesConfig defines a signed rules bundle pulled over HTTPS. When URL
+// is set the verified bundle replaces the rules at path, which remain the
+// fallback until the first bundle has been fetched.
+type RemoteRulesConfig struct {
+	URL          string        `yaml:"url"`           // HTTPS URL of the rules YAML bundle
+	SignatureURL string        `yaml:"signature_url"` // Detached signature (default: url's path + ".sig"; required when url has a query string)
+	PublicKey    string        `yaml:"public_key"`    // Base64 Ed25519 or minisign public key
+	Interval     time.Duration `yaml:"interval"`      // How often to check for a new bundle
+	CacheDir     string        `yaml:"cache_dir"`     // Last verified bundle, used at startup
 }
 
 // StateConfig defines database settings
 type StateConfig struct {
+	Backend         string          `yaml:"backend"` // bolt, or sqlite in builds with the sqlite tag
 	DBPath          string          `yaml:"db_path"`
 	SyncWrites      bool            `yaml:"sync_writes"`
 	CompactInterval time.Duration   `yaml:"compact_interval"`
 	FirstSeen       FirstSeenConfig `yaml:"first_seen"`
 	Windows         WindowsConfig   `yaml:"windows"`
+	History         HistoryConfig   `yaml:"history"`
+	Dedup           DedupConfig     `yaml:"dedup"`
+	Aggregate       AggregateConfig `yaml:"aggregate"`
+	Retention       RetentionConfig `yaml:"retention"`
+	Lineage         LineageConfig   `yaml:"lineage"`
+}
+
+// LineageConfig bounds the process lineage cache used for process trees and
+// lineage correlations. Snapshots are written to the state DB so lineage
+// survives agent restarts within a boot session.
+type LineageConfig struct {
+	MaxEntries       int           `yaml:"max_entries"`
+	TTL              time.Duration `yaml:"ttl"`               // Processes executed longer ago than this are forgotten
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"` // How often the cache is persisted, and at shutdown
+}
+
+// RetentionConfig caps how long each class of state is kept, enforced every
+// compact_interval. Zero keeps entries until they are evicted or consumed.
+type RetentionConfig struct {
+	FirstSeen     time.Duration `yaml:"first_seen"`     // Baseline patterns not seen for this long
+	WindowEvents  time.Duration `yaml:"window_events"`  // Correlation window events older than this
+	Journal       time.Duration `yaml:"journal"`        // Processed-file markers not updated for this long
+	QueuedSignals time.Duration `yaml:"queued_signals"` // Signals queued for this long without being shipped
+	Triage        time.Duration `yaml:"triage"`         // Triage status of signals not updated for this long
+}
+
+// AggregateConfig defines how rules marked aggregate: true are rolled up
+type AggregateConfig struct {
+	Interval time.Duration `yaml:"interval"` // One rollup signal per rule is emitted this long after its first match
+}
+
+// DedupConfig collapses repeated simple rule signals for the same rule and
+// target into the first signal plus a summary with the repeat count
+type DedupConfig struct {
+	Cooldown time.Duration `yaml:"cooldown"` // Repeats within this long of the first signal are counted, not emitted (0 disables)
+}
+
+// HistoryConfig defines local signal history retention (used by santamon tune)
+type HistoryConfig struct {
+	Retention time.Duration `yaml:"retention"`
 }
 
 // FirstSeenConfig defines first-seen tracking settings
 type FirstSeenConfig struct {
-	MaxEntries int    `yaml:"max_entries"`
-	Eviction   string `yaml:"eviction"`
+	MaxEntries int                  `yaml:"max_entries"`
+	Eviction   string               `yaml:"eviction"`
+	Fleet      FleetFirstSeenConfig `yaml:"fleet"`
+	Seed       BaselineSeedConfig   `yaml:"seed"`
+	Learning   LearningConfig       `yaml:"learning"`
+}
+
+// LearningConfig defines what is shipped for baseline rules during and at the
+// end of their learning period. Patterns are recorded either way.
+type LearningConfig struct {
+	Ship    bool `yaml:"ship"`    // Ship learning-period matches as info signals instead of only logging them
+	Summary bool `yaml:"summary"` // Ship one signal listing the learned patterns when a rule's learning period ends
+}
+
+// BaselineSeedConfig defines pre-seeding of baseline patterns from the seed
+// published on the collector
+type BaselineSeedConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	RetryInterval time.Duration `yaml:"retry_interval"` // Pull again until a seed is imported
+}
+
+// FleetFirstSeenConfig defines the collector lookup made before alerting on a
+// locally first-seen baseline pattern
+type FleetFirstSeenConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	MinHosts int           `yaml:"min_hosts"` // Suppress once this many hosts (including this one) have seen the pattern
+	Timeout  time.Duration `yaml:"timeout"`   // Alert anyway if the collector has not answered by then
 }
 
 // WindowsConfig defines correlation window settings
 type WindowsConfig struct {
-	GCInterval time.Duration `yaml:"gc_interval"`
-	MaxEvents  int           `yaml:"max_events"`
+	GCInterval         time.Duration `yaml:"gc_interval"`
+	MaxEvents          int           `yaml:"max_events"`           // Events kept per group
+	MaxRuleEvents      int           `yaml:"max_rule_events"`      // Events kept across the groups of a rule
+	MaxTotalEvents     int           `yaml:"max_total_events"`     // Events kept across all rules
+	CompactThresholdMB int           `yaml:"compact_threshold_mb"` // Compact the state DB at next start above this size
+	CrossHost          bool          `yaml:"cross_host"`           // Share windows between machine IDs (central deployments)
 }
 
 // ShipperConfig defines signal shipping settings
@@ -73,6 +326,26 @@ type ShipperConfig struct {
 	FlushOnEnqueue *bool           `yaml:"flush_on_enqueue"`
 	TLSSkipVerify  bool            `yaml:"tls_skip_verify"`
 	Heartbeat      HeartbeatConfig `yaml:"heartbeat"`
+	Triage         TriageConfig    `yaml:"triage"`
+	Sinks          []SinkConfig    `yaml:"sinks"`
+}
+
+// SinkConfig defines an extra destination that receives a transformed copy of
+// every new signal. Delivery is best effort: sinks are not queued in the
+// state DB or retried.
+type SinkConfig struct {
+	Name       string            `yaml:"name"`
+	URL        string            `yaml:"url"`
+	Headers    map[string]string `yaml:"headers"`    // Sent with every request, e.g. Authorization
+	Transforms []TransformConfig `yaml:"transforms"` // Applied in order to the signal JSON
+}
+
+// TransformConfig is one step of a sink pipeline. Exactly one field is set.
+type TransformConfig struct {
+	Filter string            `yaml:"filter"` // CEL expression over signal; signals it rejects are not sent
+	Drop   []string          `yaml:"drop"`   // Dotted field paths to remove
+	Rename map[string]string `yaml:"rename"` // Dotted field path to its new path
+	Labels map[string]string `yaml:"labels"` // Dotted field paths set to static values
 }
 
 // HeartbeatConfig defines agent heartbeat settings
@@ -81,6 +354,151 @@ type HeartbeatConfig struct {
 	Interval time.Duration `yaml:"interval"`
 }
 
+// TriageConfig defines polling the backend for signals analysts acknowledged
+// or closed
+type TriageConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// HealthConfig defines the health endpoint and liveness file
+type HealthConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Listen       string        `yaml:"listen"`        // host:port (localhost only) or absolute unix socket path
+	LivenessFile string        `yaml:"liveness_file"` // Rewritten with current status every interval
+	Interval     time.Duration `yaml:"interval"`
+}
+
+// RecorderConfig defines the event sampling recorder used to build rule development corpora
+type RecorderConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Dir          string   `yaml:"dir"`           // Corpus directory
+	SampleRate   float64  `yaml:"sample_rate"`   // Fraction of events to record (0-1]
+	Filter       string   `yaml:"filter"`        // Optional CEL expression events must match
+	RedactFields []string `yaml:"redact_fields"` // Extra field names to redact on top of the defaults
+	MaxBytes     int64    `yaml:"max_bytes"`     // Stop recording once the corpus reaches this size
+}
+
+// IdentityConfig defines how local users are mapped to directory identities
+// (user_email, department) attached to signals
+type IdentityConfig struct {
+	Provider string        `yaml:"provider"`  // "" (disabled), file or command
+	File     string        `yaml:"file"`      // JSON identity cache (provider: file)
+	Command  []string      `yaml:"command"`   // Lookup command and arguments (provider: command)
+	Timeout  time.Duration `yaml:"timeout"`   // Per-lookup command timeout
+	CacheTTL time.Duration `yaml:"cache_ttl"` // How long command results are cached
+}
+
+// ReputationConfig defines hash reputation lookups (VirusTotal or an internal
+// service) whose detection ratios are attached to execution signals
+type ReputationConfig struct {
+	Provider  string        `yaml:"provider"`   // "" (disabled), virustotal or http
+	URL       string        `yaml:"url"`        // Lookup base URL; <url>/<sha256> is fetched
+	APIKey    string        `yaml:"api_key"`    // Sent as x-apikey (virustotal) or a bearer token (http)
+	Timeout   time.Duration `yaml:"timeout"`    // Per-lookup timeout
+	CacheTTL  time.Duration `yaml:"cache_ttl"`  // How long results, including unknown hashes, are cached
+	RateLimit int           `yaml:"rate_limit"` // Lookups per minute; signals beyond it are not enriched
+}
+
+// YARAConfig defines YARA scans of the executables of execution signals,
+// run with the yara command-line scanner
+type YARAConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Binary        string        `yaml:"binary"`           // yara scanner (default: yara from PATH)
+	Rules         string        `yaml:"rules"`            // YARA rules file
+	Compiled      bool          `yaml:"compiled"`         // rules was compiled with yarac
+	MinSeverity   string        `yaml:"min_severity"`     // Only signals at least this severe are scanned (default: high)
+	MaxFileSizeMB int           `yaml:"max_file_size_mb"` // Larger executables are not scanned
+	Timeout       time.Duration `yaml:"timeout"`          // Per scan
+	MaxConcurrent int           `yaml:"max_concurrent"`   // Scans running at once, off the detection loop
+	CacheTTL      time.Duration `yaml:"cache_ttl"`        // How long results are cached by SHA-256
+}
+
+// IntelConfig defines threat intel feeds of SHA-256 hashes, team IDs and
+// signing IDs, matched by intel_match() in rules and attached to signals
+type IntelConfig struct {
+	Feeds    []FeedConfig  `yaml:"feeds"`
+	Interval time.Duration `yaml:"interval"` // How often feeds are reloaded
+}
+
+// FeedConfig defines one IOC feed. Exactly one of URL and Path is set.
+type FeedConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`     // HTTPS URL of the feed
+	Path    string            `yaml:"path"`    // Local feed file
+	Format  string            `yaml:"format"`  // csv, json or stix (default: from the .csv or .json extension)
+	Headers map[string]string `yaml:"headers"` // Sent when fetching url, e.g. an API key
+}
+
+// TracingConfig defines optional OpenTelemetry tracing of spool file
+// processing and signal shipping, exported over OTLP/HTTP
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint"`     // OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces
+	Headers     map[string]string `yaml:"headers"`      // Extra request headers, e.g. collector auth
+	ServiceName string            `yaml:"service_name"` // Reported as service.name
+	SampleRate  float64           `yaml:"sample_rate"`  // Fraction of spool files traced (0-1]
+	Timeout     time.Duration     `yaml:"timeout"`      // Export request timeout
+}
+
+// LoadSheddingConfig defines how detection work is shed when spool files
+// back up, trading coverage for bounded latency
+type LoadSheddingConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	SkipLowBacklog int     `yaml:"skip_low_backlog"` // Pending files at which info/low rules are skipped
+	SampleBacklog  int     `yaml:"sample_backlog"`   // Pending files at which events are also sampled
+	SampleRate     float64 `yaml:"sample_rate"`      // Fraction of events evaluated by non-priority rules when sampling
+}
+
+// PrefilterConfig drops events before rule evaluation, to bound CPU use on
+// very chatty hosts. Unlike load shedding it always applies, to every rule.
+type PrefilterConfig struct {
+	Filter string             `yaml:"filter"` // CEL expression events must match to be evaluated (empty = all)
+	Sample map[string]float64 `yaml:"sample"` // Fraction of each event kind evaluated (0 = drop the kind)
+}
+
+// SantaRulesConfig controls the Santa rules suggested for signals about
+// unsigned or newly seen binaries
+type SantaRulesConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	MinSeverity string `yaml:"min_severity"` // Only signals at least this severe get a suggestion
+	File        string `yaml:"file"`         // Also collect suggestions in this santactl rule --import file (optional)
+	MaxRules    int    `yaml:"max_rules"`    // Suggestions kept in file; the oldest are dropped
+}
+
+// ResponseConfig defines the catalog of local actions rules may trigger with
+// actions. Rules can only name actions listed here.
+type ResponseConfig struct {
+	Enabled  bool                   `yaml:"enabled"`
+	AuditLog string                 `yaml:"audit_log"` // JSON lines record of every action run
+	Timeout  time.Duration          `yaml:"timeout"`   // Default per-action timeout
+	Actions  []ResponseActionConfig `yaml:"actions"`
+
+	SyncServer SyncServerConfig `yaml:"sync_server"`
+}
+
+// ResponseActionConfig defines one response action
+type ResponseActionConfig struct {
+	Name        string        `yaml:"name"`
+	Type        string        `yaml:"type"`         // command, file or santactl
+	Command     []string      `yaml:"command"`      // Program and arguments; {{field}} is replaced with signal values (type: command)
+	Path        string        `yaml:"path"`         // File signals are appended to as JSON lines (type: file)
+	Policy      string        `yaml:"policy"`       // block (default) or silent_block (type: santactl)
+	MinSeverity string        `yaml:"min_severity"` // Only signals at least this severe run the action (default: all)
+	Timeout     time.Duration `yaml:"timeout"`      // Overrides response.timeout
+}
+
+// SyncServerConfig defines pushing block rules for signals of rules tagged
+// respond:block to a Santa sync server, which distributes them to the fleet
+type SyncServerConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	URL         string            `yaml:"url"`          // Endpoint block rules are POSTed to
+	Headers     map[string]string `yaml:"headers"`      // Sent with every request, e.g. Authorization
+	DryRun      bool              `yaml:"dry_run"`      // Only audit the rules that would be pushed
+	MinSeverity string            `yaml:"min_severity"` // Only signals at least this severe push a rule (default: all)
+	Timeout     time.Duration     `yaml:"timeout"`      // Overrides response.timeout
+}
+
 // RetryConfig defines retry behavior
 type RetryConfig struct {
 	MaxAttempts int           `yaml:"max_attempts"`
@@ -137,12 +555,71 @@ func (c *Config) applyDefaults() {
 	if c.Agent.LogLevel == "" {
 		c.Agent.LogLevel = "info"
 	}
+	if c.Agent.LogFormat == "" {
+		c.Agent.LogFormat = "text"
+	}
+	if c.Agent.ReloadOn == "" {
+		c.Agent.ReloadOn = "SIGHUP"
+	}
+	if c.Agent.AdminSocket == "" {
+		c.Agent.AdminSocket = filepath.Join(c.Agent.StateDir, "admin.sock")
+	}
+	if c.Agent.InventoryInterval == 0 {
+		c.Agent.InventoryInterval = time.Hour
+	}
+	if c.Agent.LogRotation.MaxSizeMB == 0 {
+		c.Agent.LogRotation.MaxSizeMB = 10
+	}
+	if c.Agent.LogRotation.MaxBackups == 0 {
+		c.Agent.LogRotation.MaxBackups = 5
+	}
+	if c.Agent.LogRotation.MaxAge == 0 {
+		c.Agent.LogRotation.MaxAge = 7 * 24 * time.Hour
+	}
 
 	if c.Santa.Mode == "" {
 		c.Santa.Mode = "protobuf"
 	}
-	if c.Santa.SpoolDir == "" {
-		c.Santa.SpoolDir = "/var/db/santa/spool"
+	if c.Santa.EventLog.Enabled {
+		if c.Santa.EventLog.Source == "" {
+			c.Santa.EventLog.Source = "file"
+		}
+		if c.Santa.EventLog.Path == "" {
+			c.Santa.EventLog.Path = "/var/db/santa/santa.log"
+		}
+		if c.Santa.EventLog.Predicate == "" {
+			c.Santa.EventLog.Predicate = `process == "santad" OR process == "com.northpolesec.santa.daemon"`
+		}
+		if c.Santa.EventLog.SpoolDir == "" {
+			c.Santa.EventLog.SpoolDir = filepath.Join(c.Agent.StateDir, "log_spool")
+		}
+		if c.Santa.EventLog.BatchInterval == 0 {
+			c.Santa.EventLog.BatchInterval = 2 * time.Second
+		}
+		if c.Santa.EventLog.BatchSize == 0 {
+			c.Santa.EventLog.BatchSize = 1000
+		}
+	}
+	if c.Santa.Ingest.Enabled {
+		if c.Santa.Ingest.Listen == "" {
+			c.Santa.Ingest.Listen = filepath.Join(c.Agent.StateDir, "ingest.sock")
+		}
+		if c.Santa.Ingest.SpoolDir == "" {
+			c.Santa.Ingest.SpoolDir = filepath.Join(c.Agent.StateDir, "ingest_spool")
+		}
+		if c.Santa.Ingest.MaxBatchMB == 0 {
+			c.Santa.Ingest.MaxBatchMB = 16
+		}
+	}
+	// Without spool export the event log or ingest server can be the only input
+	if len(c.Santa.SpoolDir) == 0 && !c.Santa.EventLog.Enabled && !c.Santa.Ingest.Enabled {
+		c.Santa.SpoolDir = SpoolDirs{{Path: "/var/db/santa/spool"}}
+	}
+	if c.Santa.EventLog.Enabled && !slices.Contains(c.Santa.SpoolDir.Paths(), c.Santa.EventLog.SpoolDir) {
+		c.Santa.SpoolDir = append(c.Santa.SpoolDir, SpoolSource{Path: c.Santa.EventLog.SpoolDir, Label: "santa_log", Mode: "protobuf"})
+	}
+	if c.Santa.Ingest.Enabled && !slices.Contains(c.Santa.SpoolDir.Paths(), c.Santa.Ingest.SpoolDir) {
+		c.Santa.SpoolDir = append(c.Santa.SpoolDir, SpoolSource{Path: c.Santa.Ingest.SpoolDir, Label: "ingest", Mode: "protobuf"})
 	}
 	if c.Santa.ArchiveDir == "" {
 		c.Santa.ArchiveDir = filepath.Join(c.Agent.StateDir, "spool_hits")
@@ -150,6 +627,40 @@ func (c *Config) applyDefaults() {
 	if c.Santa.StabilityWait == 0 {
 		c.Santa.StabilityWait = 2 * time.Second
 	}
+	if c.Santa.Workers == 0 {
+		c.Santa.Workers = 1
+	}
+	if c.Santa.Pressure.Enabled {
+		if c.Santa.Pressure.Interval == 0 {
+			c.Santa.Pressure.Interval = 30 * time.Second
+		}
+		if c.Santa.Pressure.MaxSpoolMB == 0 && c.Santa.Pressure.MinFreeMB == 0 {
+			c.Santa.Pressure.MaxSpoolMB = 1024
+			c.Santa.Pressure.MinFreeMB = 1024
+		}
+		if c.Santa.Pressure.Action == "" {
+			c.Santa.Pressure.Action = "none"
+		}
+	}
+	if c.Santa.ClaimFiles && c.Santa.ClaimDir == "" {
+		c.Santa.ClaimDir = filepath.Join(c.Santa.SpoolDir[0].Path, "claimed", c.Agent.ID)
+	}
+	for i := range c.Santa.SpoolDir {
+		src := &c.Santa.SpoolDir[i]
+		if src.Mode == "" {
+			src.Mode = c.Santa.Mode
+		}
+		if src.StabilityWait == 0 {
+			src.StabilityWait = c.Santa.StabilityWait
+		}
+		if src.Label == "" && len(c.Santa.SpoolDir) > 1 {
+			src.Label = filepath.Base(src.Path)
+		}
+		// The first source claims into santa.claim_dir, the others next to their spool
+		if c.Santa.ClaimFiles && src.ClaimDir == "" && i > 0 {
+			src.ClaimDir = filepath.Join(src.Path, "claimed", c.Agent.ID)
+		}
+	}
 
 	if c.Rules.Path == "" {
 		c.Rules.Path = "/etc/santamon/rules.yaml"
@@ -157,7 +668,48 @@ func (c *Config) applyDefaults() {
 	if c.Rules.ReloadOn == "" {
 		c.Rules.ReloadOn = "SIGHUP"
 	}
+	if c.Rules.ReloadDebounce == 0 {
+		c.Rules.ReloadDebounce = time.Second
+	}
+	if c.Rules.Workers == 0 {
+		c.Rules.Workers = 1
+	}
+	if c.Rules.Rollback.ErrorThreshold == 0 {
+		c.Rules.Rollback.ErrorThreshold = 100
+	}
+	if c.Rules.Rollback.Window == 0 {
+		c.Rules.Rollback.Window = 10 * time.Minute
+	}
+	if c.Rules.Budget.CostLimit == 0 {
+		c.Rules.Budget.CostLimit = 1_000_000
+	}
+	if c.Rules.Budget.Timeout == 0 {
+		c.Rules.Budget.Timeout = 100 * time.Millisecond
+	}
+	if c.Rules.Budget.DisableAfter == 0 {
+		c.Rules.Budget.DisableAfter = 10
+	}
+	if c.Rules.Budget.MinEvaluations == 0 {
+		c.Rules.Budget.MinEvaluations = 100
+	}
+	if c.Rules.Allowlist.Path != "" && c.Rules.Allowlist.Scope == "" {
+		c.Rules.Allowlist.Scope = "rules"
+	}
+	if c.Rules.Remote.URL != "" {
+		if c.Rules.Remote.SignatureURL == "" {
+			c.Rules.Remote.SignatureURL = defaultSignatureURL(c.Rules.Remote.URL)
+		}
+		if c.Rules.Remote.Interval == 0 {
+			c.Rules.Remote.Interval = 15 * time.Minute
+		}
+		if c.Rules.Remote.CacheDir == "" {
+			c.Rules.Remote.CacheDir = filepath.Join(c.Agent.StateDir, "rules")
+		}
+	}
 
+	if c.State.Backend == "" {
+		c.State.Backend = "bolt"
+	}
 	if c.State.DBPath == "" {
 		c.State.DBPath = "/var/lib/santamon/state.db"
 	}
@@ -170,12 +722,45 @@ func (c *Config) applyDefaults() {
 	if c.State.FirstSeen.Eviction == "" {
 		c.State.FirstSeen.Eviction = "lru"
 	}
+	if c.State.FirstSeen.Fleet.MinHosts == 0 {
+		c.State.FirstSeen.Fleet.MinHosts = 5
+	}
+	if c.State.FirstSeen.Fleet.Timeout == 0 {
+		c.State.FirstSeen.Fleet.Timeout = 2 * time.Second
+	}
+	if c.State.FirstSeen.Seed.RetryInterval == 0 {
+		c.State.FirstSeen.Seed.RetryInterval = 10 * time.Minute
+	}
 	if c.State.Windows.GCInterval == 0 {
 		c.State.Windows.GCInterval = 1 * time.Minute
 	}
 	if c.State.Windows.MaxEvents == 0 {
 		c.State.Windows.MaxEvents = 1000
 	}
+	if c.State.Windows.MaxRuleEvents == 0 {
+		c.State.Windows.MaxRuleEvents = 20000
+	}
+	if c.State.Windows.MaxTotalEvents == 0 {
+		c.State.Windows.MaxTotalEvents = 100000
+	}
+	if c.State.Windows.CompactThresholdMB == 0 {
+		c.State.Windows.CompactThresholdMB = 256
+	}
+	if c.State.History.Retention == 0 {
+		c.State.History.Retention = 30 * 24 * time.Hour
+	}
+	if c.State.Aggregate.Interval == 0 {
+		c.State.Aggregate.Interval = 5 * time.Minute
+	}
+	if c.State.Lineage.MaxEntries == 0 {
+		c.State.Lineage.MaxEntries = 50000
+	}
+	if c.State.Lineage.TTL == 0 {
+		c.State.Lineage.TTL = time.Hour
+	}
+	if c.State.Lineage.SnapshotInterval == 0 {
+		c.State.Lineage.SnapshotInterval = 5 * time.Minute
+	}
 
 	if c.Shipper.BatchSize == 0 {
 		c.Shipper.BatchSize = 100
@@ -207,6 +792,144 @@ func (c *Config) applyDefaults() {
 	if c.Shipper.Heartbeat.Interval == 0 {
 		c.Shipper.Heartbeat.Interval = 30 * time.Second
 	}
+	if c.Shipper.Triage.Interval == 0 {
+		c.Shipper.Triage.Interval = time.Minute
+	}
+
+	if c.Health.Interval == 0 {
+		c.Health.Interval = 30 * time.Second
+	}
+	if c.Health.Enabled && c.Health.LivenessFile == "" {
+		c.Health.LivenessFile = filepath.Join(c.Agent.StateDir, "health.json")
+	}
+
+	if c.Recorder.Dir == "" {
+		c.Recorder.Dir = filepath.Join(c.Agent.StateDir, "corpus")
+	}
+	if c.Recorder.SampleRate == 0 {
+		c.Recorder.SampleRate = 0.01
+	}
+	if c.Recorder.MaxBytes == 0 {
+		c.Recorder.MaxBytes = 100 * 1024 * 1024
+	}
+
+	if c.Identity.Timeout == 0 {
+		c.Identity.Timeout = 2 * time.Second
+	}
+	if c.Identity.CacheTTL == 0 {
+		c.Identity.CacheTTL = 1 * time.Hour
+	}
+
+	if c.Reputation.Provider == "virustotal" && c.Reputation.URL == "" {
+		c.Reputation.URL = "https://www.virustotal.com/api/v3/files"
+	}
+	if c.Reputation.Timeout == 0 {
+		c.Reputation.Timeout = 5 * time.Second
+	}
+	if c.Reputation.CacheTTL == 0 {
+		c.Reputation.CacheTTL = 24 * time.Hour
+	}
+	if c.Reputation.RateLimit == 0 {
+		c.Reputation.RateLimit = 4 // VirusTotal public API quota
+	}
+
+	if c.YARA.MinSeverity == "" {
+		c.YARA.MinSeverity = "high"
+	}
+	if c.YARA.MaxFileSizeMB == 0 {
+		c.YARA.MaxFileSizeMB = 100
+	}
+	if c.YARA.Timeout == 0 {
+		c.YARA.Timeout = 10 * time.Second
+	}
+	if c.YARA.MaxConcurrent == 0 {
+		c.YARA.MaxConcurrent = 2
+	}
+	if c.YARA.CacheTTL == 0 {
+		c.YARA.CacheTTL = 24 * time.Hour
+	}
+
+	if c.SantaRules.MinSeverity == "" {
+		c.SantaRules.MinSeverity = "medium"
+	}
+	if c.SantaRules.MaxRules == 0 {
+		c.SantaRules.MaxRules = 1000
+	}
+
+	if c.Response.AuditLog == "" {
+		c.Response.AuditLog = filepath.Join(c.Agent.StateDir, "response-audit.jsonl")
+	}
+	if c.Response.Timeout == 0 {
+		c.Response.Timeout = 30 * time.Second
+	}
+	for i := range c.Response.Actions {
+		action := &c.Response.Actions[i]
+		if action.Type == "santactl" && action.Policy == "" {
+			action.Policy = "block"
+		}
+	}
+
+	if c.Intel.Interval == 0 {
+		c.Intel.Interval = 1 * time.Hour
+	}
+	for i := range c.Intel.Feeds {
+		feed := &c.Intel.Feeds[i]
+		if feed.Format == "" {
+			source := feed.Path
+			if source == "" {
+				if u, err := url.Parse(feed.URL); err == nil {
+					source = u.Path
+				}
+			}
+			switch strings.ToLower(filepath.Ext(source)) {
+			case ".csv":
+				feed.Format = "csv"
+			case ".json":
+				feed.Format = "json"
+			}
+		}
+	}
+
+	if c.Tracing.Endpoint == "" {
+		c.Tracing.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "santamon"
+	}
+	if c.Tracing.SampleRate == 0 {
+		c.Tracing.SampleRate = 1.0
+	}
+	if c.Tracing.Timeout == 0 {
+		c.Tracing.Timeout = 10 * time.Second
+	}
+
+	if c.LoadShedding.SkipLowBacklog == 0 {
+		c.LoadShedding.SkipLowBacklog = 200
+	}
+	if c.LoadShedding.SampleBacklog == 0 {
+		c.LoadShedding.SampleBacklog = 500
+	}
+	if c.LoadShedding.SampleRate == 0 {
+		c.LoadShedding.SampleRate = 0.25
+	}
+}
+
+// defaultSignatureURL returns the bundle URL with ".sig" appended to its
+// path, or "" when the URL has a query string (e.g. an S3 presigned URL)
+func defaultSignatureURL(bundleURL string) string {
+	u, err := url.Parse(bundleURL)
+	if err != nil {
+		// Reported by Validate
+		return bundleURL + ".sig"
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		return ""
+	}
+	u.Path += ".sig"
+	if u.RawPath != "" {
+		u.RawPath += ".sig"
+	}
+	return u.String()
 }
 
 // Validate checks the configuration for errors
@@ -226,6 +949,39 @@ func (c *Config) ValidateWithOptions(skipShipper bool) error {
 	if !isValidLogLevel(c.Agent.LogLevel) {
 		return fmt.Errorf("invalid log level: %s", c.Agent.LogLevel)
 	}
+	if c.Agent.LogFormat != "" && c.Agent.LogFormat != "text" && c.Agent.LogFormat != "json" {
+		return fmt.Errorf("agent.log_format must be 'text' or 'json'")
+	}
+	for component, level := range c.Agent.LogLevels {
+		if !isValidLogLevel(level) {
+			return fmt.Errorf("invalid log level for component %s: %s", component, level)
+		}
+	}
+	if c.Agent.ReloadOn != "" && c.Agent.ReloadOn != "SIGHUP" && c.Agent.ReloadOn != "change" {
+		return fmt.Errorf("agent.reload_on must be 'SIGHUP' or 'change'")
+	}
+	for key := range c.Agent.Tags {
+		if key == "" || strings.ContainsAny(key, "= \t*?") {
+			return fmt.Errorf("agent.tags: invalid tag name %q", key)
+		}
+	}
+	if c.Agent.InventoryInterval < 0 {
+		return fmt.Errorf("agent.inventory_interval cannot be negative")
+	}
+	if c.Agent.AdminSocket != "" && !filepath.IsAbs(c.Agent.AdminSocket) {
+		return fmt.Errorf("agent.admin_socket must be an absolute path")
+	}
+	if c.Agent.LogFile != "" && !filepath.IsAbs(c.Agent.LogFile) {
+		return fmt.Errorf("agent.log_file must be an absolute path")
+	}
+	if c.Agent.LogRotation.MaxSizeMB < 0 {
+		return fmt.Errorf("agent.log_rotation.max_size_mb must be non-negative")
+	}
+	// 0 takes the default, so -1 is how unlimited is spelled; any negative
+	// max_age keeps rotated files forever
+	if c.Agent.LogRotation.MaxBackups < -1 {
+		return fmt.Errorf("agent.log_rotation.max_backups must be -1 (unlimited) or non-negative")
+	}
 	if !filepath.IsAbs(c.Agent.StateDir) {
 		return fmt.Errorf("agent.state_dir must be an absolute path")
 	}
@@ -234,25 +990,169 @@ func (c *Config) ValidateWithOptions(skipShipper bool) error {
 	if c.Santa.Mode != "protobuf" && c.Santa.Mode != "json" {
 		return fmt.Errorf("santa.mode must be 'protobuf' or 'json'")
 	}
-	if !filepath.IsAbs(c.Santa.SpoolDir) {
-		return fmt.Errorf("santa.spool_dir must be an absolute path")
+	if len(c.Santa.SpoolDir) == 0 {
+		return fmt.Errorf("santa.spool_dir is required")
 	}
 	if c.Santa.ArchiveDir != "" && !filepath.IsAbs(c.Santa.ArchiveDir) {
 		return fmt.Errorf("santa.archive_dir must be an absolute path")
 	}
+	if c.Santa.Archive.MaxAge < 0 {
+		return fmt.Errorf("santa.archive.max_age must be non-negative")
+	}
+	if c.Santa.Archive.MaxSizeMB < 0 {
+		return fmt.Errorf("santa.archive.max_size_mb must be non-negative")
+	}
+	if c.Santa.Pressure.Enabled {
+		if c.Santa.Pressure.Interval < time.Second {
+			return fmt.Errorf("santa.pressure.interval must be at least 1s")
+		}
+		if c.Santa.Pressure.MaxSpoolMB < 0 || c.Santa.Pressure.MinFreeMB < 0 {
+			return fmt.Errorf("santa.pressure.max_spool_mb and min_free_mb must be non-negative")
+		}
+		switch c.Santa.Pressure.Action {
+		case "none", "newest":
+		case "sample":
+			if !c.LoadShedding.Enabled {
+				return fmt.Errorf("santa.pressure.action 'sample' requires load_shedding.enabled")
+			}
+		default:
+			return fmt.Errorf("santa.pressure.action must be 'none', 'sample' or 'newest'")
+		}
+	}
 	if c.Santa.StabilityWait < 0 {
 		return fmt.Errorf("santa.stability_wait cannot be negative")
 	}
 	if c.Santa.StabilityWait > 60*time.Second {
 		return fmt.Errorf("santa.stability_wait too large (max 60s)")
 	}
+	if c.Santa.Workers < 0 {
+		return fmt.Errorf("santa.workers must be non-negative")
+	}
+	if c.Santa.ClaimFiles {
+		if !filepath.IsAbs(c.Santa.ClaimDir) {
+			return fmt.Errorf("santa.claim_dir must be an absolute path")
+		}
+	}
+	labels := make(map[string]bool)
+	if c.Santa.EventLog.Enabled {
+		if err := c.Santa.EventLog.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Santa.Ingest.Enabled {
+		if err := c.Santa.Ingest.validate(); err != nil {
+			return err
+		}
+	}
+	for _, src := range c.Santa.SpoolDir {
+		if err := c.Santa.validateSource(src); err != nil {
+			return err
+		}
+		if labels[src.Label] {
+			return fmt.Errorf("santa.spool_dir label %q is used by several sources; set a unique label", src.Label)
+		}
+		labels[src.Label] = true
+	}
 
 	// Validate rules config
 	if !filepath.IsAbs(c.Rules.Path) {
 		return fmt.Errorf("rules.path must be an absolute path")
 	}
+	if c.Rules.ReloadOn != "" && c.Rules.ReloadOn != "SIGHUP" && c.Rules.ReloadOn != "change" {
+		return fmt.Errorf("rules.reload_on must be 'SIGHUP' or 'change'")
+	}
+	if c.Rules.ReloadOn == "change" && c.Rules.Remote.URL != "" {
+		return fmt.Errorf("rules.reload_on 'change' watches rules.path, which rules.remote replaces")
+	}
+	if c.Rules.ReloadDebounce < 0 {
+		return fmt.Errorf("rules.reload_debounce must be non-negative")
+	}
+	if c.Rules.Workers < 0 {
+		return fmt.Errorf("rules.workers must be non-negative")
+	}
+	if c.Rules.Rollback.ErrorThreshold < 0 {
+		return fmt.Errorf("rules.rollback.error_threshold must be positive")
+	}
+	if c.Rules.Rollback.Window < 0 {
+		return fmt.Errorf("rules.rollback.window must be non-negative")
+	}
+	if c.Rules.Suppressions != "" && !filepath.IsAbs(c.Rules.Suppressions) {
+		return fmt.Errorf("rules.suppressions must be an absolute path")
+	}
+	if c.Rules.ShadowPath != "" && !filepath.IsAbs(c.Rules.ShadowPath) {
+		return fmt.Errorf("rules.shadow_path must be an absolute path")
+	}
+	if c.Rules.Budget.DisableAfter < 0 {
+		return fmt.Errorf("rules.budget.disable_after must be positive")
+	}
+	if c.Rules.Budget.LatencyLimit < 0 {
+		return fmt.Errorf("rules.budget.latency_limit cannot be negative")
+	}
+	if c.Rules.Budget.Timeout < 0 {
+		return fmt.Errorf("rules.budget.timeout cannot be negative")
+	}
+	if c.Rules.Budget.ErrorRate < 0 || c.Rules.Budget.ErrorRate > 1 {
+		return fmt.Errorf("rules.budget.error_rate must be between 0 and 1")
+	}
+	if c.Rules.Budget.MinEvaluations < 0 {
+		return fmt.Errorf("rules.budget.min_evaluations cannot be negative")
+	}
+	for field, overrides := range map[string]map[string]RuleOverrideConfig{"packs": c.Rules.Packs, "overrides": c.Rules.Overrides} {
+		for name, o := range overrides {
+			switch o.Severity {
+			case "", "low", "medium", "high", "critical":
+			default:
+				return fmt.Errorf("rules.%s.%s.severity must be low, medium, high or critical", field, name)
+			}
+		}
+	}
+	if allow := c.Rules.Allowlist; allow.Path != "" {
+		if !filepath.IsAbs(allow.Path) {
+			return fmt.Errorf("rules.allowlist.path must be an absolute path")
+		}
+		if allow.Scope != "rules" && allow.Scope != "all" {
+			return fmt.Errorf("rules.allowlist.scope must be rules or all")
+		}
+	}
+	if remote := c.Rules.Remote; remote.URL != "" {
+		if remote.SignatureURL == "" {
+			// Presigned URLs sign the path, so the signature needs a URL of its own
+			return fmt.Errorf("rules.remote.signature_url is required when rules.remote.url has a query string")
+		}
+		for _, field := range []struct{ name, raw string }{
+			{"url", remote.URL},
+			{"signature_url", remote.SignatureURL},
+		} {
+			name := field.name
+			u, err := url.Parse(field.raw)
+			if err != nil || u.Host == "" {
+				return fmt.Errorf("rules.remote.%s must be a valid URL", name)
+			}
+			// Allow HTTP only for localhost testing; the signature is still required
+			switch host := u.Hostname(); {
+			case u.Scheme == "https":
+			case u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1"):
+			default:
+				return fmt.Errorf("rules.remote.%s must use HTTPS", name)
+			}
+		}
+		if remote.PublicKey == "" {
+			return fmt.Errorf("rules.remote.public_key is required")
+		}
+		if remote.Interval < time.Minute {
+			return fmt.Errorf("rules.remote.interval must be at least 1m")
+		}
+		if !filepath.IsAbs(remote.CacheDir) {
+			return fmt.Errorf("rules.remote.cache_dir must be an absolute path")
+		}
+	}
 
 	// Validate state config
+	switch c.State.Backend {
+	case "", "bolt", "sqlite":
+	default:
+		return fmt.Errorf("state.backend must be 'bolt' or 'sqlite'")
+	}
 	if !filepath.IsAbs(c.State.DBPath) {
 		return fmt.Errorf("state.db_path must be an absolute path")
 	}
@@ -265,12 +1165,236 @@ func (c *Config) ValidateWithOptions(skipShipper bool) error {
 	if c.State.FirstSeen.Eviction != "lru" {
 		return fmt.Errorf("state.first_seen.eviction must be 'lru'")
 	}
+	if c.State.FirstSeen.Fleet.MinHosts < 0 {
+		return fmt.Errorf("state.first_seen.fleet.min_hosts must be positive")
+	}
+	if c.State.FirstSeen.Fleet.Timeout < 0 {
+		return fmt.Errorf("state.first_seen.fleet.timeout must be positive")
+	}
+	retention := c.State.Retention
+	for name, ttl := range map[string]time.Duration{
+		"first_seen":     retention.FirstSeen,
+		"window_events":  retention.WindowEvents,
+		"journal":        retention.Journal,
+		"queued_signals": retention.QueuedSignals,
+		"triage":         retention.Triage,
+	} {
+		if ttl < 0 {
+			return fmt.Errorf("state.retention.%s must be positive", name)
+		}
+	}
+	if c.State.FirstSeen.Seed.RetryInterval < 0 {
+		return fmt.Errorf("state.first_seen.seed.retry_interval must be positive")
+	}
 	if c.State.Windows.MaxEvents <= 0 {
 		return fmt.Errorf("state.windows.max_events must be positive")
 	}
 	if c.State.Windows.MaxEvents > 100000 {
 		return fmt.Errorf("state.windows.max_events too large (max 100000)")
 	}
+	if c.State.Windows.MaxRuleEvents < 0 {
+		return fmt.Errorf("state.windows.max_rule_events must be positive")
+	}
+	if c.State.Windows.MaxTotalEvents < 0 {
+		return fmt.Errorf("state.windows.max_total_events must be positive")
+	}
+	if c.State.Windows.CompactThresholdMB < 0 {
+		return fmt.Errorf("state.windows.compact_threshold_mb must be positive")
+	}
+	if c.State.History.Retention < 0 {
+		return fmt.Errorf("state.history.retention must be non-negative")
+	}
+	if c.State.Dedup.Cooldown < 0 {
+		return fmt.Errorf("state.dedup.cooldown must be non-negative")
+	}
+	if c.State.Aggregate.Interval < 0 {
+		return fmt.Errorf("state.aggregate.interval must be positive")
+	}
+	if c.State.Lineage.MaxEntries < 0 || c.State.Lineage.TTL < 0 || c.State.Lineage.SnapshotInterval < 0 {
+		return fmt.Errorf("state.lineage.max_entries, ttl and snapshot_interval must be positive")
+	}
+
+	// Validate health config
+	if c.Health.Enabled {
+		if err := validateHealthListen(c.Health.Listen); err != nil {
+			return err
+		}
+		if c.Health.LivenessFile != "" && !filepath.IsAbs(c.Health.LivenessFile) {
+			return fmt.Errorf("health.liveness_file must be an absolute path")
+		}
+		if c.Health.Interval < time.Second {
+			return fmt.Errorf("health.interval too small (min 1s)")
+		}
+	}
+
+	// Validate recorder config
+	if c.Recorder.Enabled {
+		if !filepath.IsAbs(c.Recorder.Dir) {
+			return fmt.Errorf("recorder.dir must be an absolute path")
+		}
+		if c.Recorder.SampleRate <= 0 || c.Recorder.SampleRate > 1 {
+			return fmt.Errorf("recorder.sample_rate must be between 0 and 1")
+		}
+		if c.Recorder.MaxBytes < 0 {
+			return fmt.Errorf("recorder.max_bytes cannot be negative")
+		}
+	}
+
+	// Validate identity config
+	switch c.Identity.Provider {
+	case "":
+	case "file":
+		if !filepath.IsAbs(c.Identity.File) {
+			return fmt.Errorf("identity.file must be an absolute path")
+		}
+	case "command":
+		if len(c.Identity.Command) == 0 || !filepath.IsAbs(c.Identity.Command[0]) {
+			return fmt.Errorf("identity.command must start with an absolute executable path")
+		}
+	default:
+		return fmt.Errorf("identity.provider must be 'file' or 'command'")
+	}
+
+	// Validate reputation config
+	switch c.Reputation.Provider {
+	case "":
+	case "virustotal", "http":
+		u, err := url.Parse(c.Reputation.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("reputation.url must be an http(s) URL")
+		}
+		if u.Scheme == "http" {
+			host := u.Hostname()
+			if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+				return fmt.Errorf("reputation.url must use HTTPS (not HTTP) for remote hosts")
+			}
+		}
+		if c.Reputation.Provider == "virustotal" && c.Reputation.APIKey == "" {
+			return fmt.Errorf("reputation.api_key is required for virustotal")
+		}
+		if c.Reputation.Timeout < 0 || c.Reputation.CacheTTL < 0 {
+			return fmt.Errorf("reputation.timeout and reputation.cache_ttl cannot be negative")
+		}
+		if c.Reputation.RateLimit < 0 {
+			return fmt.Errorf("reputation.rate_limit cannot be negative")
+		}
+	default:
+		return fmt.Errorf("reputation.provider must be 'virustotal' or 'http'")
+	}
+
+	// Validate YARA config
+	if c.YARA.Enabled {
+		if c.YARA.Rules == "" || !filepath.IsAbs(c.YARA.Rules) {
+			return fmt.Errorf("yara.rules must be an absolute path")
+		}
+		if c.YARA.Binary != "" && strings.Contains(c.YARA.Binary, "/") && !filepath.IsAbs(c.YARA.Binary) {
+			return fmt.Errorf("yara.binary must be an absolute path or a command name")
+		}
+		switch c.YARA.MinSeverity {
+		case "low", "medium", "high", "critical":
+		default:
+			return fmt.Errorf("yara.min_severity must be low, medium, high or critical")
+		}
+		if c.YARA.MaxFileSizeMB < 0 || c.YARA.MaxConcurrent < 0 {
+			return fmt.Errorf("yara.max_file_size_mb and yara.max_concurrent cannot be negative")
+		}
+		if c.YARA.Timeout < 0 || c.YARA.CacheTTL < 0 {
+			return fmt.Errorf("yara.timeout and yara.cache_ttl cannot be negative")
+		}
+	}
+
+	// Validate intel config
+	if c.Intel.Interval < 0 {
+		return fmt.Errorf("intel.interval cannot be negative")
+	}
+	feedNames := make(map[string]bool, len(c.Intel.Feeds))
+	for i, feed := range c.Intel.Feeds {
+		if err := feed.validate(); err != nil {
+			return fmt.Errorf("intel.feeds[%d]: %w", i, err)
+		}
+		if feedNames[feed.Name] {
+			return fmt.Errorf("intel.feeds[%d]: duplicate name %q", i, feed.Name)
+		}
+		feedNames[feed.Name] = true
+	}
+
+	// Validate tracing config
+	if c.Tracing.Enabled {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("tracing.endpoint must be an http(s) URL")
+		}
+		if u.Scheme == "http" {
+			host := u.Hostname()
+			if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+				return fmt.Errorf("tracing.endpoint must use HTTPS (not HTTP) for remote hosts")
+			}
+		}
+		if c.Tracing.SampleRate <= 0 || c.Tracing.SampleRate > 1 {
+			return fmt.Errorf("tracing.sample_rate must be between 0 and 1")
+		}
+		if c.Tracing.Timeout < 0 {
+			return fmt.Errorf("tracing.timeout cannot be negative")
+		}
+	}
+
+	// Validate load shedding config
+	if c.LoadShedding.Enabled {
+		if c.LoadShedding.SkipLowBacklog < 1 {
+			return fmt.Errorf("load_shedding.skip_low_backlog must be positive")
+		}
+		if c.LoadShedding.SampleBacklog <= c.LoadShedding.SkipLowBacklog {
+			return fmt.Errorf("load_shedding.sample_backlog must be greater than skip_low_backlog")
+		}
+		if c.LoadShedding.SampleRate <= 0 || c.LoadShedding.SampleRate > 1 {
+			return fmt.Errorf("load_shedding.sample_rate must be between 0 and 1")
+		}
+	}
+
+	// Validate santa_rules config
+	if c.SantaRules.Enabled {
+		switch c.SantaRules.MinSeverity {
+		case "low", "medium", "high", "critical":
+		default:
+			return fmt.Errorf("santa_rules.min_severity must be low, medium, high or critical")
+		}
+		if c.SantaRules.File != "" && !filepath.IsAbs(c.SantaRules.File) {
+			return fmt.Errorf("santa_rules.file must be an absolute path")
+		}
+		if c.SantaRules.MaxRules < 0 {
+			return fmt.Errorf("santa_rules.max_rules cannot be negative")
+		}
+	}
+
+	// Validate response config
+	if c.Response.Enabled {
+		if !filepath.IsAbs(c.Response.AuditLog) {
+			return fmt.Errorf("response.audit_log must be an absolute path")
+		}
+		if c.Response.Timeout < 0 {
+			return fmt.Errorf("response.timeout cannot be negative")
+		}
+		actionNames := make(map[string]bool, len(c.Response.Actions))
+		for i, action := range c.Response.Actions {
+			if err := action.validate(); err != nil {
+				return fmt.Errorf("response.actions[%d]: %w", i, err)
+			}
+			if actionNames[action.Name] {
+				return fmt.Errorf("response.actions[%d]: duplicate name %q", i, action.Name)
+			}
+			actionNames[action.Name] = true
+		}
+		if err := c.Response.SyncServer.validate(); err != nil {
+			return fmt.Errorf("response.sync_server: %w", err)
+		}
+	}
+
+	// Validate prefilter config (kinds are checked when the prefilter is built)
+	for kind, rate := range c.Prefilter.Sample {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("prefilter.sample.%s must be between 0 and 1", kind)
+		}
+	}
 
 	// Validate shipper config (skip for read-only commands)
 	if !skipShipper {
@@ -315,12 +1439,255 @@ func (c *Config) ValidateWithOptions(skipShipper bool) error {
 		if c.Shipper.Retry.Backoff != "exponential" && c.Shipper.Retry.Backoff != "linear" {
 			return fmt.Errorf("shipper.retry.backoff must be 'exponential' or 'linear'")
 		}
+		if c.Shipper.Triage.Interval < 0 {
+			return fmt.Errorf("shipper.triage.interval cannot be negative")
+		}
+		names := make(map[string]bool, len(c.Shipper.Sinks))
+		for i, sink := range c.Shipper.Sinks {
+			if err := sink.validate(); err != nil {
+				return fmt.Errorf("shipper.sinks[%d]: %w", i, err)
+			}
+			if names[sink.Name] {
+				return fmt.Errorf("shipper.sinks[%d]: duplicate name %q", i, sink.Name)
+			}
+			names[sink.Name] = true
+		}
+	}
+
+	return nil
+}
+
+// SourceClaimDir returns the directory files of src are claimed into, or ""
+// when claiming is off
+func (s *SantaConfig) SourceClaimDir(src SpoolSource) string {
+	if !s.ClaimFiles {
+		return ""
+	}
+	if src.ClaimDir != "" {
+		return src.ClaimDir
+	}
+	return s.ClaimDir
+}
+
+// validateSource checks the settings of one santa.spool_dir source
+func (s *SantaConfig) validateSource(src SpoolSource) error {
+	if !filepath.IsAbs(src.Path) {
+		return fmt.Errorf("santa.spool_dir must be an absolute path: %q", src.Path)
+	}
+	if src.Mode != "" && src.Mode != "protobuf" && src.Mode != "json" {
+		return fmt.Errorf("santa.spool_dir %s: mode must be 'protobuf' or 'json'", src.Path)
+	}
+	if src.StabilityWait < 0 {
+		return fmt.Errorf("santa.spool_dir %s: stability_wait cannot be negative", src.Path)
+	}
+	if src.StabilityWait > 60*time.Second {
+		return fmt.Errorf("santa.spool_dir %s: stability_wait too large (max 60s)", src.Path)
+	}
+	if s.ClaimFiles {
+		claimDir := s.SourceClaimDir(src)
+		if !filepath.IsAbs(claimDir) {
+			return fmt.Errorf("santa.claim_dir must be an absolute path")
+		}
+		if filepath.Clean(claimDir) == filepath.Join(src.Path, "new") {
+			return fmt.Errorf("santa.claim_dir cannot be the spool new/ directory")
+		}
+	}
+	return nil
+}
+
+// validate checks the santa.event_log settings
+func (e *EventLogConfig) validate() error {
+	switch e.Source {
+	case "file":
+		if !filepath.IsAbs(e.Path) {
+			return fmt.Errorf("santa.event_log.path must be an absolute path")
+		}
+	case "unified":
+		if e.Predicate == "" {
+			return fmt.Errorf("santa.event_log.predicate is required for the unified source")
+		}
+	default:
+		return fmt.Errorf("santa.event_log.source must be 'file' or 'unified'")
+	}
+	if !filepath.IsAbs(e.SpoolDir) {
+		return fmt.Errorf("santa.event_log.spool_dir must be an absolute path")
+	}
+	if e.BatchInterval < 100*time.Millisecond {
+		return fmt.Errorf("santa.event_log.batch_interval must be at least 100ms")
+	}
+	if e.BatchSize < 1 {
+		return fmt.Errorf("santa.event_log.batch_size must be positive")
 	}
+	return nil
+}
 
+// validate checks the santa.ingest settings. Off loopback, clients must
+// present a token.
+func (i *IngestConfig) validate() error {
+	if !filepath.IsAbs(i.Listen) {
+		host, _, err := net.SplitHostPort(i.Listen)
+		if err != nil {
+			return fmt.Errorf("santa.ingest.listen invalid address: %w", err)
+		}
+		loopback := host == "localhost" || (net.ParseIP(host) != nil && net.ParseIP(host).IsLoopback())
+		if !loopback && i.Token == "" {
+			return fmt.Errorf("santa.ingest.token is required when listening on %s", i.Listen)
+		}
+	}
+	if !filepath.IsAbs(i.SpoolDir) {
+		return fmt.Errorf("santa.ingest.spool_dir must be an absolute path")
+	}
+	if i.MaxBatchMB < 1 {
+		return fmt.Errorf("santa.ingest.max_batch_mb must be positive")
+	}
+	return nil
+}
+
+// validate checks a sink's URL and that each transform sets exactly one step
+func (s *SinkConfig) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	if u.Scheme == "http" {
+		host := u.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("url must use HTTPS (not HTTP) for remote hosts")
+		}
+	}
+	for i, t := range s.Transforms {
+		steps := 0
+		if t.Filter != "" {
+			steps++
+		}
+		if len(t.Drop) > 0 {
+			steps++
+		}
+		if len(t.Rename) > 0 {
+			steps++
+		}
+		if len(t.Labels) > 0 {
+			steps++
+		}
+		if steps != 1 {
+			return fmt.Errorf("transforms[%d] must set exactly one of filter, drop, rename or labels", i)
+		}
+	}
+	return nil
+}
+
+// validate checks that a feed has one source and a known format
+func (f *FeedConfig) validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch {
+	case (f.URL == "") == (f.Path == ""):
+		return fmt.Errorf("exactly one of url and path is required")
+	case f.Path != "" && !filepath.IsAbs(f.Path):
+		return fmt.Errorf("path must be an absolute path")
+	case f.URL != "":
+		u, err := url.Parse(f.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("url must be an http(s) URL")
+		}
+		if u.Scheme == "http" {
+			host := u.Hostname()
+			if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+				return fmt.Errorf("url must use HTTPS (not HTTP) for remote hosts")
+			}
+		}
+	}
+	switch f.Format {
+	case "csv", "json", "stix":
+	case "":
+		return fmt.Errorf("format is required when the source has no .csv or .json extension")
+	default:
+		return fmt.Errorf("format must be 'csv', 'json' or 'stix'")
+	}
+	return nil
+}
+
+// validate checks that an action has the settings of its type
+func (a *ResponseActionConfig) validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch a.Type {
+	case "command":
+		if len(a.Command) == 0 {
+			return fmt.Errorf("command is required for type command")
+		}
+		if !filepath.IsAbs(a.Command[0]) {
+			return fmt.Errorf("command program must be an absolute path")
+		}
+	case "file":
+		if a.Path == "" || !filepath.IsAbs(a.Path) {
+			return fmt.Errorf("path must be an absolute path for type file")
+		}
+	case "santactl":
+		if a.Policy != "block" && a.Policy != "silent_block" {
+			return fmt.Errorf("policy must be 'block' or 'silent_block'")
+		}
+	default:
+		return fmt.Errorf("type must be 'command', 'file' or 'santactl'")
+	}
+	switch a.MinSeverity {
+	case "", "low", "medium", "high", "critical":
+	default:
+		return fmt.Errorf("min_severity must be low, medium, high or critical")
+	}
+	if a.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+// validate checks the endpoint of an enabled sync server integration
+func (s *SyncServerConfig) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	if u.Scheme == "http" {
+		host := u.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("url must use HTTPS (not HTTP) for remote hosts")
+		}
+	}
+	switch s.MinSeverity {
+	case "", "low", "medium", "high", "critical":
+	default:
+		return fmt.Errorf("min_severity must be low, medium, high or critical")
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateHealthListen allows an absolute unix socket path or a loopback host:port
+func validateHealthListen(listen string) error {
+	if listen == "" || filepath.IsAbs(listen) {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("health.listen invalid address: %w", err)
+	}
+	if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		return fmt.Errorf("health.listen must be a loopback address or unix socket path")
+	}
 	return nil
 }
 
 func isValidLogLevel(level string) bool {
 	level = strings.ToLower(level)
-	return level == "debug" || level == "info" || level == "warn" || level == "error"
+	return level == "debug" || level == "verbose" || level == "info" || level == "warn" || level == "error"
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -61,8 +62,8 @@ func TestValidConfig(t *testing.T) {
 	if cfg.Santa.Mode != "json" {
 		t.Errorf("Santa.Mode = %v, want json", cfg.Santa.Mode)
 	}
-	if cfg.Santa.SpoolDir != "/var/db/santa/spool" {
-		t.Errorf("Santa.SpoolDir = %v, want /var/db/santa/spool", cfg.Santa.SpoolDir)
+	if paths := cfg.Santa.SpoolDir.Paths(); len(paths) != 1 || paths[0] != "/var/db/santa/spool" {
+		t.Errorf("Santa.SpoolDir = %v, want /var/db/santa/spool", paths)
 	}
 	if cfg.Santa.ArchiveDir != "/tmp/santamon/spool_hits" {
 		t.Errorf("Santa.ArchiveDir = %v, want /tmp/santamon/spool_hits", cfg.Santa.ArchiveDir)
@@ -116,7 +117,7 @@ func TestValidateEmptyAgentID(t *testing.T) {
 		},
 		Santa: SantaConfig{
 			Mode:     "json",
-			SpoolDir: "/tmp/spool",
+			SpoolDir: SpoolDirs{{Path: "/tmp/spool"}},
 		},
 		Rules: RulesConfig{
 			Path: "/tmp/rules.yaml",
@@ -175,7 +176,7 @@ func TestValidateRelativePaths(t *testing.T) {
 			field: "santa.spool_dir",
 			value: "relative/spool",
 			modifier: func(cfg *Config) {
-				cfg.Santa.SpoolDir = "relative/spool"
+				cfg.Santa.SpoolDir = SpoolDirs{{Path: "relative/spool"}}
 			},
 		},
 		{
@@ -222,6 +223,170 @@ func TestValidateInvalidLogLevel(t *testing.T) {
 	}
 }
 
+func TestValidateLogFormatAndComponents(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Agent.LogFormat = "json"
+	cfg.Agent.LogLevels = map[string]string{"shipper": "debug", "spool": "warn"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cfg.Agent.LogFormat = "xml"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "log_format") {
+		t.Errorf("Expected log_format validation error, got: %v", err)
+	}
+
+	cfg.Agent.LogFormat = "text"
+	cfg.Agent.LogLevels["shipper"] = "loud"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "component shipper") {
+		t.Errorf("Expected component log level error, got: %v", err)
+	}
+}
+
+func TestValidateLogFile(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Agent.LogFile = "/var/log/santamon.log"
+	cfg.Agent.LogRotation = LogRotationConfig{MaxSizeMB: 10, MaxBackups: 5, MaxAge: 24 * time.Hour}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cfg.Agent.LogFile = "santamon.log"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "log_file") {
+		t.Errorf("Expected log_file validation error, got: %v", err)
+	}
+
+	cfg.Agent.LogFile = "/var/log/santamon.log"
+	cfg.Agent.LogRotation.MaxBackups = -2
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "max_backups") {
+		t.Errorf("Expected max_backups validation error, got: %v", err)
+	}
+
+	// -1 is unlimited and survives defaults, which replace 0
+	cfg.Agent.LogRotation = LogRotationConfig{MaxSizeMB: 10, MaxBackups: -1, MaxAge: -time.Second}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if cfg.Agent.LogRotation.MaxBackups != -1 || cfg.Agent.LogRotation.MaxAge != -time.Second {
+		t.Errorf("Unlimited rotation changed by defaults: %+v", cfg.Agent.LogRotation)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Agent.Tags = map[string]string{"role": "developer", "site": "ams"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cfg.Agent.Tags["team=eng"] = "x"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "agent.tags") {
+		t.Errorf("Expected agent.tags validation error, got: %v", err)
+	}
+}
+
+func TestValidateIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     IdentityConfig
+		wantErr string
+	}{
+		{name: "disabled", cfg: IdentityConfig{}},
+		{name: "file", cfg: IdentityConfig{Provider: "file", File: "/var/lib/santamon/identities.json"}},
+		{name: "command", cfg: IdentityConfig{Provider: "command", Command: []string{"/usr/local/bin/idlookup", "--okta"}}},
+		{name: "relative file", cfg: IdentityConfig{Provider: "file", File: "identities.json"}, wantErr: "identity.file"},
+		{name: "empty command", cfg: IdentityConfig{Provider: "command"}, wantErr: "identity.command"},
+		{name: "unknown provider", cfg: IdentityConfig{Provider: "ldap"}, wantErr: "identity.provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Identity = tt.cfg
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateRuleOverrides(t *testing.T) {
+	disabled := false
+	cfg := validTestConfig()
+	cfg.Rules.Packs = map[string]RuleOverrideConfig{"community": {Enabled: &disabled}}
+	cfg.Rules.Overrides = map[string]RuleOverrideConfig{"SM-001": {Severity: "high"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cfg.Rules.Packs["community"] = RuleOverrideConfig{Severity: "urgent"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "rules.packs.community.severity") {
+		t.Errorf("Expected pack severity validation error, got: %v", err)
+	}
+}
+
+func TestValidateRemoteRules(t *testing.T) {
+	remote := func(url string) RemoteRulesConfig {
+		return RemoteRulesConfig{
+			URL:       url,
+			PublicKey: "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3",
+			CacheDir:  "/var/lib/santamon/rules",
+		}
+	}
+	tests := []struct {
+		name    string
+		modify  func(*RemoteRulesConfig)
+		wantErr string
+	}{
+		{name: "https", modify: func(*RemoteRulesConfig) {}},
+		{name: "localhost http", modify: func(r *RemoteRulesConfig) { *r = remote("http://127.0.0.1:8080/rules.yaml") }},
+		{name: "remote http", modify: func(r *RemoteRulesConfig) { *r = remote("http://rules.example.com/rules.yaml") }, wantErr: "rules.remote.url must use HTTPS"},
+		{name: "bad signature url", modify: func(r *RemoteRulesConfig) { r.SignatureURL = "ftp://rules.example.com/rules.sig" }, wantErr: "rules.remote.signature_url"},
+		{name: "presigned url", modify: func(r *RemoteRulesConfig) { r.URL += "?X-Amz-Signature=abc" }, wantErr: "rules.remote.signature_url is required"},
+		{name: "presigned urls", modify: func(r *RemoteRulesConfig) {
+			r.URL += "?X-Amz-Signature=abc"
+			r.SignatureURL = "https://rules.example.com/santamon/rules.yaml.sig?X-Amz-Signature=def"
+		}},
+		{name: "missing key", modify: func(r *RemoteRulesConfig) { r.PublicKey = "" }, wantErr: "rules.remote.public_key"},
+		{name: "short interval", modify: func(r *RemoteRulesConfig) { r.Interval = time.Second }, wantErr: "rules.remote.interval"},
+		{name: "relative cache dir", modify: func(r *RemoteRulesConfig) { r.CacheDir = "rules" }, wantErr: "rules.remote.cache_dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Rules.Remote = remote("https://rules.example.com/santamon/rules.yaml")
+			tt.modify(&cfg.Rules.Remote)
+			cfg.applyDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	cfg := validTestConfig()
+	cfg.Rules.Remote.URL = "https://rules.example.com/rules.yaml"
+	cfg.applyDefaults()
+	if cfg.Rules.Remote.SignatureURL != "https://rules.example.com/rules.yaml.sig" {
+		t.Errorf("SignatureURL default = %q", cfg.Rules.Remote.SignatureURL)
+	}
+	if got := defaultSignatureURL("https://rules.example.com/rules.yaml#latest"); got != "https://rules.example.com/rules.yaml.sig#latest" {
+		t.Errorf("defaultSignatureURL() with fragment = %q", got)
+	}
+	if cfg.Rules.Remote.CacheDir != "/tmp/test/rules" {
+		t.Errorf("CacheDir default = %q", cfg.Rules.Remote.CacheDir)
+	}
+}
+
 func TestValidateInvalidSantaMode(t *testing.T) {
 	cfg := validTestConfig()
 	cfg.Santa.Mode = "invalid"
@@ -252,6 +417,210 @@ func TestValidateBounds(t *testing.T) {
 			},
 			wantErr: "must be positive",
 		},
+		{
+			name: "santa.archive.max_age negative",
+			modifier: func(cfg *Config) {
+				cfg.Santa.Archive.MaxAge = -time.Hour
+			},
+			wantErr: "santa.archive.max_age",
+		},
+		{
+			name: "santa.archive.max_size_mb negative",
+			modifier: func(cfg *Config) {
+				cfg.Santa.Archive.MaxSizeMB = -1
+			},
+			wantErr: "santa.archive.max_size_mb",
+		},
+		{
+			name: "agent.reload_on invalid",
+			modifier: func(cfg *Config) {
+				cfg.Agent.ReloadOn = "inotify"
+			},
+			wantErr: "agent.reload_on",
+		},
+		{
+			name: "rules.reload_on invalid",
+			modifier: func(cfg *Config) {
+				cfg.Rules.ReloadOn = "inotify"
+			},
+			wantErr: "rules.reload_on",
+		},
+		{
+			name: "rules.reload_on change with remote rules",
+			modifier: func(cfg *Config) {
+				cfg.Rules.ReloadOn = "change"
+				cfg.Rules.Remote.URL = "https://rules.example.com/bundle.yaml"
+			},
+			wantErr: "rules.reload_on",
+		},
+		{
+			name: "rules.reload_debounce negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.ReloadDebounce = -time.Second
+			},
+			wantErr: "rules.reload_debounce",
+		},
+		{
+			name: "santa.workers negative",
+			modifier: func(cfg *Config) {
+				cfg.Santa.Workers = -1
+			},
+			wantErr: "santa.workers",
+		},
+		{
+			name: "rules.workers negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Workers = -1
+			},
+			wantErr: "rules.workers",
+		},
+		{
+			name: "admin_socket relative",
+			modifier: func(cfg *Config) {
+				cfg.Agent.AdminSocket = "admin.sock"
+			},
+			wantErr: "agent.admin_socket",
+		},
+		{
+			name: "rollback.error_threshold negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Rollback.ErrorThreshold = -1
+			},
+			wantErr: "rules.rollback.error_threshold",
+		},
+		{
+			name: "budget.disable_after negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Budget.DisableAfter = -1
+			},
+			wantErr: "rules.budget.disable_after",
+		},
+		{
+			name: "budget.latency_limit negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Budget.LatencyLimit = -time.Millisecond
+			},
+			wantErr: "rules.budget.latency_limit",
+		},
+		{
+			name: "budget.timeout negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Budget.Timeout = -time.Millisecond
+			},
+			wantErr: "rules.budget.timeout",
+		},
+		{
+			name: "budget.error_rate above 1",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Budget.ErrorRate = 1.5
+			},
+			wantErr: "rules.budget.error_rate",
+		},
+		{
+			name: "budget.min_evaluations negative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Budget.MinEvaluations = -1
+			},
+			wantErr: "rules.budget.min_evaluations",
+		},
+		{
+			name: "suppressions relative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.Suppressions = "suppressions.yaml"
+			},
+			wantErr: "rules.suppressions",
+		},
+		{
+			name: "shadow_path relative",
+			modifier: func(cfg *Config) {
+				cfg.Rules.ShadowPath = "rules-next"
+			},
+			wantErr: "rules.shadow_path",
+		},
+		{
+			name: "first_seen.fleet.min_hosts negative",
+			modifier: func(cfg *Config) {
+				cfg.State.FirstSeen.Fleet.MinHosts = -1
+			},
+			wantErr: "state.first_seen.fleet.min_hosts",
+		},
+		{
+			name: "first_seen.fleet.timeout negative",
+			modifier: func(cfg *Config) {
+				cfg.State.FirstSeen.Fleet.Timeout = -time.Second
+			},
+			wantErr: "state.first_seen.fleet.timeout",
+		},
+		{
+			name: "windows.max_rule_events negative",
+			modifier: func(cfg *Config) {
+				cfg.State.Windows.MaxRuleEvents = -1
+			},
+			wantErr: "state.windows.max_rule_events",
+		},
+		{
+			name: "windows.compact_threshold_mb negative",
+			modifier: func(cfg *Config) {
+				cfg.State.Windows.CompactThresholdMB = -1
+			},
+			wantErr: "state.windows.compact_threshold_mb",
+		},
+		{
+			name: "state.backend unknown",
+			modifier: func(cfg *Config) {
+				cfg.State.Backend = "leveldb"
+			},
+			wantErr: "state.backend",
+		},
+		{
+			name: "retention.queued_signals negative",
+			modifier: func(cfg *Config) {
+				cfg.State.Retention.QueuedSignals = -time.Hour
+			},
+			wantErr: "state.retention.queued_signals",
+		},
+		{
+			name: "retention.triage negative",
+			modifier: func(cfg *Config) {
+				cfg.State.Retention.Triage = -time.Hour
+			},
+			wantErr: "state.retention.triage",
+		},
+		{
+			name: "shipper.triage.interval negative",
+			modifier: func(cfg *Config) {
+				cfg.Shipper.Triage.Interval = -time.Minute
+			},
+			wantErr: "shipper.triage.interval",
+		},
+		{
+			name: "first_seen.seed.retry_interval negative",
+			modifier: func(cfg *Config) {
+				cfg.State.FirstSeen.Seed.RetryInterval = -time.Second
+			},
+			wantErr: "state.first_seen.seed.retry_interval",
+		},
+		{
+			name: "history.retention negative",
+			modifier: func(cfg *Config) {
+				cfg.State.History.Retention = -time.Hour
+			},
+			wantErr: "history.retention",
+		},
+		{
+			name: "dedup.cooldown negative",
+			modifier: func(cfg *Config) {
+				cfg.State.Dedup.Cooldown = -time.Hour
+			},
+			wantErr: "state.dedup.cooldown",
+		},
+		{
+			name: "aggregate.interval negative",
+			modifier: func(cfg *Config) {
+				cfg.State.Aggregate.Interval = -time.Minute
+			},
+			wantErr: "state.aggregate.interval",
+		},
 		{
 			name: "batch_size too large",
 			modifier: func(cfg *Config) {
@@ -377,6 +746,640 @@ func TestLocalhostHTTPAllowed(t *testing.T) {
 	}
 }
 
+func TestValidateHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		listen  string
+		file    string
+		wantErr string
+	}{
+		{name: "loopback tcp", listen: "127.0.0.1:9110"},
+		{name: "unix socket", listen: "/var/run/santamon/health.sock"},
+		{name: "liveness file only", file: "/var/lib/santamon/health.json"},
+		{name: "remote listen", listen: "0.0.0.0:9110", wantErr: "loopback"},
+		{name: "missing port", listen: "localhost", wantErr: "invalid address"},
+		{name: "relative liveness file", file: "health.json", wantErr: "liveness_file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Health = HealthConfig{
+				Enabled:      true,
+				Listen:       tt.listen,
+				LivenessFile: tt.file,
+				Interval:     30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestClaimDirDefaults(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Santa.ClaimFiles = true
+	cfg.applyDefaults()
+
+	want := filepath.Join(cfg.Santa.SpoolDir[0].Path, "claimed", cfg.Agent.ID)
+	if cfg.Santa.ClaimDir != want {
+		t.Errorf("ClaimDir = %q, want %q", cfg.Santa.ClaimDir, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cfg.Santa.ClaimDir = "claimed"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "claim_dir") {
+		t.Errorf("Expected claim_dir error, got: %v", err)
+	}
+
+	cfg.Santa.ClaimDir = filepath.Join(cfg.Santa.SpoolDir[0].Path, "new")
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "claim_dir") {
+		t.Errorf("Expected claim_dir error, got: %v", err)
+	}
+}
+
+func TestSpoolDirList(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `agent:
+  id: "test"
+  state_dir: "/tmp/test"
+santa:
+  stability_wait: 3s
+  claim_files: true
+  spool_dir:
+    - /var/db/santa/spool
+    - path: /var/db/forwarder/spool
+      label: forwarder
+      mode: json
+      stability_wait: 10s
+rules:
+  path: "/tmp/rules.yaml"
+state:
+  db_path: "/tmp/test.db"
+shipper:
+  endpoint: "https://localhost/ingest"
+  api_key: "test-secret-key-1234567890"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want := SpoolDirs{
+		{Path: "/var/db/santa/spool", Label: "spool", Mode: "protobuf", StabilityWait: 3 * time.Second},
+		{Path: "/var/db/forwarder/spool", Label: "forwarder", Mode: "json", StabilityWait: 10 * time.Second,
+			ClaimDir: "/var/db/forwarder/spool/claimed/test"},
+	}
+	if !reflect.DeepEqual(cfg.Santa.SpoolDir, want) {
+		t.Errorf("SpoolDir = %+v, want %+v", cfg.Santa.SpoolDir, want)
+	}
+	if got := cfg.Santa.SourceClaimDir(cfg.Santa.SpoolDir[0]); got != "/var/db/santa/spool/claimed/test" {
+		t.Errorf("first source claim dir = %q", got)
+	}
+
+	// Labels must tell the sources apart
+	cfg.Santa.SpoolDir[1].Label = "spool"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "label") {
+		t.Errorf("Expected duplicate label error, got: %v", err)
+	}
+	cfg.Santa.SpoolDir[1].Label = "forwarder"
+	cfg.Santa.SpoolDir[1].Mode = "xml"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mode") {
+		t.Errorf("Expected mode error, got: %v", err)
+	}
+}
+
+func TestEventLogSource(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `agent:
+  id: "test"
+  state_dir: "/tmp/test"
+santa:
+  event_log:
+    enabled: true
+rules:
+  path: "/tmp/rules.yaml"
+state:
+  db_path: "/tmp/test.db"
+shipper:
+  endpoint: "https://localhost/ingest"
+  api_key: "test-secret-key-1234567890"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Santa.EventLog.Source != "file" || cfg.Santa.EventLog.Path != "/var/db/santa/santa.log" {
+		t.Errorf("EventLog = %+v", cfg.Santa.EventLog)
+	}
+	// Without spool export the event log spool is the only source
+	want := SpoolDirs{{Path: "/tmp/test/log_spool", Label: "santa_log", Mode: "protobuf", StabilityWait: 2 * time.Second}}
+	if !reflect.DeepEqual(cfg.Santa.SpoolDir, want) {
+		t.Errorf("SpoolDir = %+v, want %+v", cfg.Santa.SpoolDir, want)
+	}
+
+	cfg.Santa.EventLog.Source = "syslog"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "santa.event_log.source") {
+		t.Errorf("Expected source error, got: %v", err)
+	}
+	cfg.Santa.EventLog.Source = "unified"
+	cfg.Santa.EventLog.BatchInterval = time.Millisecond
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "batch_interval") {
+		t.Errorf("Expected batch_interval error, got: %v", err)
+	}
+}
+
+func TestValidateIngest(t *testing.T) {
+	tests := []struct {
+		name    string
+		ingest  IngestConfig
+		wantErr string
+	}{
+		{name: "unix socket", ingest: IngestConfig{Enabled: true, Listen: "/var/run/santamon/ingest.sock", SpoolDir: "/tmp/ingest", MaxBatchMB: 16}},
+		{name: "loopback", ingest: IngestConfig{Enabled: true, Listen: "127.0.0.1:7443", SpoolDir: "/tmp/ingest", MaxBatchMB: 16}},
+		{name: "remote with token", ingest: IngestConfig{Enabled: true, Listen: ":7443", Token: "secret", SpoolDir: "/tmp/ingest", MaxBatchMB: 16}},
+		{name: "remote without token", ingest: IngestConfig{Enabled: true, Listen: "0.0.0.0:7443", SpoolDir: "/tmp/ingest", MaxBatchMB: 16}, wantErr: "santa.ingest.token"},
+		{name: "bad address", ingest: IngestConfig{Enabled: true, Listen: "ingest.sock", SpoolDir: "/tmp/ingest", MaxBatchMB: 16}, wantErr: "santa.ingest.listen"},
+		{name: "relative spool", ingest: IngestConfig{Enabled: true, Listen: "/tmp/ingest.sock", SpoolDir: "ingest", MaxBatchMB: 16}, wantErr: "santa.ingest.spool_dir"},
+		{name: "disabled", ingest: IngestConfig{Listen: "0.0.0.0:7443"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Santa.Ingest = tt.ingest
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatePrefilter(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Prefilter.Sample = map[string]float64{"fork": 0, "close": 0.01}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cfg.Prefilter.Sample["exit"] = 1.5
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "prefilter.sample.exit") {
+		t.Errorf("Expected prefilter.sample.exit error, got: %v", err)
+	}
+}
+
+func TestValidateRecorder(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		rate    float64
+		wantErr string
+	}{
+		{name: "valid", dir: "/var/lib/santamon/corpus", rate: 0.05},
+		{name: "full rate", dir: "/var/lib/santamon/corpus", rate: 1},
+		{name: "relative dir", dir: "corpus", rate: 0.05, wantErr: "recorder.dir"},
+		{name: "rate too high", dir: "/var/lib/santamon/corpus", rate: 1.5, wantErr: "sample_rate"},
+		{name: "negative rate", dir: "/var/lib/santamon/corpus", rate: -0.1, wantErr: "sample_rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Recorder = RecorderConfig{Enabled: true, Dir: tt.dir, SampleRate: tt.rate}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateTracing(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		rate     float64
+		wantErr  string
+	}{
+		{name: "local collector", endpoint: "http://localhost:4318/v1/traces", rate: 1},
+		{name: "remote https", endpoint: "https://otel.example.com/v1/traces", rate: 0.1},
+		{name: "remote http", endpoint: "http://otel.example.com/v1/traces", rate: 1, wantErr: "HTTPS"},
+		{name: "not a URL", endpoint: "localhost:4318", rate: 1, wantErr: "tracing.endpoint"},
+		{name: "rate too high", endpoint: "http://localhost:4318/v1/traces", rate: 2, wantErr: "sample_rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Tracing = TracingConfig{Enabled: true, Endpoint: tt.endpoint, SampleRate: tt.rate}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateLoadShedding(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     LoadSheddingConfig
+		wantErr string
+	}{
+		{name: "valid", cfg: LoadSheddingConfig{Enabled: true, SkipLowBacklog: 100, SampleBacklog: 300, SampleRate: 0.5}},
+		{name: "thresholds out of order", cfg: LoadSheddingConfig{Enabled: true, SkipLowBacklog: 300, SampleBacklog: 100, SampleRate: 0.5}, wantErr: "sample_backlog"},
+		{name: "negative threshold", cfg: LoadSheddingConfig{Enabled: true, SkipLowBacklog: -1, SampleBacklog: 100, SampleRate: 0.5}, wantErr: "skip_low_backlog"},
+		{name: "rate too high", cfg: LoadSheddingConfig{Enabled: true, SkipLowBacklog: 100, SampleBacklog: 300, SampleRate: 2}, wantErr: "sample_rate"},
+		{name: "disabled", cfg: LoadSheddingConfig{SkipLowBacklog: 300, SampleBacklog: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.LoadShedding = tt.cfg
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatePressure(t *testing.T) {
+	tests := []struct {
+		name     string
+		pressure PressureConfig
+		shedding bool
+		wantErr  string
+	}{
+		{name: "valid", pressure: PressureConfig{Enabled: true, Interval: time.Minute, MaxSpoolMB: 500, Action: "newest"}},
+		{name: "sample with shedding", pressure: PressureConfig{Enabled: true, Interval: time.Minute, MinFreeMB: 500, Action: "sample"}, shedding: true},
+		{name: "sample without shedding", pressure: PressureConfig{Enabled: true, Interval: time.Minute, MinFreeMB: 500, Action: "sample"}, wantErr: "load_shedding.enabled"},
+		{name: "unknown action", pressure: PressureConfig{Enabled: true, Interval: time.Minute, Action: "drop"}, wantErr: "santa.pressure.action"},
+		{name: "interval too short", pressure: PressureConfig{Enabled: true, Interval: time.Millisecond, Action: "none"}, wantErr: "santa.pressure.interval"},
+		{name: "disabled", pressure: PressureConfig{Action: "drop"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Santa.Pressure = tt.pressure
+			if tt.shedding {
+				cfg.LoadShedding = LoadSheddingConfig{Enabled: true, SkipLowBacklog: 100, SampleBacklog: 300, SampleRate: 0.5}
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateSinks(t *testing.T) {
+	chat := SinkConfig{
+		Name: "chatops",
+		URL:  "https://hooks.example.com/services/x",
+		Transforms: []TransformConfig{
+			{Filter: `signal.severity in ["high", "critical"]`},
+			{Drop: []string{"context.args"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		sinks   []SinkConfig
+		wantErr string
+	}{
+		{name: "valid", sinks: []SinkConfig{chat}},
+		{name: "local http", sinks: []SinkConfig{{Name: "local", URL: "http://127.0.0.1:9000/hook"}}},
+		{name: "remote http", sinks: []SinkConfig{{Name: "chat", URL: "http://hooks.example.com"}}, wantErr: "HTTPS"},
+		{name: "missing name", sinks: []SinkConfig{{URL: "https://hooks.example.com"}}, wantErr: "shipper.sinks[0]: name"},
+		{name: "duplicate name", sinks: []SinkConfig{chat, chat}, wantErr: "duplicate name"},
+		{
+			name:    "empty transform",
+			sinks:   []SinkConfig{{Name: "chat", URL: "https://hooks.example.com", Transforms: []TransformConfig{{}}}},
+			wantErr: "transforms[0]",
+		},
+		{
+			name: "two steps in one transform",
+			sinks: []SinkConfig{{Name: "chat", URL: "https://hooks.example.com", Transforms: []TransformConfig{
+				{Filter: "true", Drop: []string{"context"}},
+			}}},
+			wantErr: "exactly one",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Shipper.Sinks = tt.sinks
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateIntel(t *testing.T) {
+	tests := []struct {
+		name       string
+		feed       FeedConfig
+		wantErr    string
+		wantFormat string
+	}{
+		{name: "csv file", feed: FeedConfig{Name: "local", Path: "/etc/santamon/iocs.csv"}, wantFormat: "csv"},
+		{name: "json url", feed: FeedConfig{Name: "vendor", URL: "https://intel.example.com/feed.json?key=x"}, wantFormat: "json"},
+		{name: "stix url", feed: FeedConfig{Name: "taxii", URL: "https://intel.example.com/bundle", Format: "stix"}, wantFormat: "stix"},
+		{name: "no format", feed: FeedConfig{Name: "vendor", URL: "https://intel.example.com/feed"}, wantErr: "format is required"},
+		{name: "bad format", feed: FeedConfig{Name: "vendor", Path: "/tmp/feed.txt", Format: "txt"}, wantErr: "format must be"},
+		{name: "both sources", feed: FeedConfig{Name: "x", Path: "/tmp/a.csv", URL: "https://a/b.csv"}, wantErr: "exactly one of url and path"},
+		{name: "relative path", feed: FeedConfig{Name: "x", Path: "iocs.csv"}, wantErr: "absolute"},
+		{name: "remote http", feed: FeedConfig{Name: "x", URL: "http://intel.example.com/a.csv"}, wantErr: "HTTPS"},
+		{name: "missing name", feed: FeedConfig{Path: "/tmp/a.csv"}, wantErr: "intel.feeds[0]: name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Intel.Feeds = []FeedConfig{tt.feed}
+			cfg.applyDefaults()
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				if got := cfg.Intel.Feeds[0].Format; got != tt.wantFormat {
+					t.Errorf("Format = %q, want %q", got, tt.wantFormat)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	cfg := validTestConfig()
+	cfg.Intel.Feeds = []FeedConfig{{Name: "a", Path: "/tmp/a.csv", Format: "csv"}, {Name: "a", Path: "/tmp/b.csv", Format: "csv"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate name") {
+		t.Errorf("Expected duplicate name error, got: %v", err)
+	}
+}
+
+func TestValidateReputation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ReputationConfig
+		wantErr string
+		wantURL string
+	}{
+		{name: "disabled", cfg: ReputationConfig{}},
+		{name: "virustotal", cfg: ReputationConfig{Provider: "virustotal", APIKey: "key"}, wantURL: "https://www.virustotal.com/api/v3/files"},
+		{name: "internal", cfg: ReputationConfig{Provider: "http", URL: "https://reputation.example.com/v1/hashes"}, wantURL: "https://reputation.example.com/v1/hashes"},
+		{name: "virustotal without key", cfg: ReputationConfig{Provider: "virustotal"}, wantErr: "api_key is required"},
+		{name: "http without url", cfg: ReputationConfig{Provider: "http"}, wantErr: "reputation.url must be"},
+		{name: "remote http", cfg: ReputationConfig{Provider: "http", URL: "http://reputation.example.com"}, wantErr: "HTTPS"},
+		{name: "negative rate limit", cfg: ReputationConfig{Provider: "virustotal", APIKey: "key", RateLimit: -1}, wantErr: "rate_limit"},
+		{name: "unknown provider", cfg: ReputationConfig{Provider: "hybrid-analysis"}, wantErr: "reputation.provider must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Reputation = tt.cfg
+			cfg.applyDefaults()
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				if cfg.Reputation.URL != tt.wantURL {
+					t.Errorf("URL = %q, want %q", cfg.Reputation.URL, tt.wantURL)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	cfg := validTestConfig()
+	cfg.applyDefaults()
+	if cfg.Reputation.RateLimit != 4 || cfg.Reputation.CacheTTL != 24*time.Hour {
+		t.Errorf("Unexpected defaults: %+v", cfg.Reputation)
+	}
+}
+
+func TestValidateYARA(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     YARAConfig
+		wantErr string
+	}{
+		{name: "disabled", cfg: YARAConfig{Rules: "relative.yar"}},
+		{name: "enabled", cfg: YARAConfig{Enabled: true, Rules: "/etc/santamon/rules.yar"}},
+		{name: "binary name", cfg: YARAConfig{Enabled: true, Rules: "/etc/santamon/rules.yar", Binary: "yara"}},
+		{name: "without rules", cfg: YARAConfig{Enabled: true}, wantErr: "yara.rules"},
+		{name: "relative binary", cfg: YARAConfig{Enabled: true, Rules: "/r.yar", Binary: "bin/yara"}, wantErr: "yara.binary"},
+		{name: "bad severity", cfg: YARAConfig{Enabled: true, Rules: "/r.yar", MinSeverity: "info"}, wantErr: "yara.min_severity"},
+		{name: "negative size", cfg: YARAConfig{Enabled: true, Rules: "/r.yar", MaxFileSizeMB: -1}, wantErr: "max_file_size_mb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.YARA = tt.cfg
+			cfg.applyDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	cfg := validTestConfig()
+	cfg.applyDefaults()
+	if cfg.YARA.MinSeverity != "high" || cfg.YARA.MaxConcurrent != 2 || cfg.YARA.MaxFileSizeMB != 100 {
+		t.Errorf("Unexpected defaults: %+v", cfg.YARA)
+	}
+}
+
+func TestValidateAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AllowlistConfig
+		wantErr string
+	}{
+		{name: "valid", cfg: AllowlistConfig{Path: "/etc/santamon/allowlist.yaml", Scope: "all"}},
+		{name: "disabled", cfg: AllowlistConfig{Scope: "bogus"}},
+		{name: "relative path", cfg: AllowlistConfig{Path: "allowlist.yaml", Scope: "rules"}, wantErr: "rules.allowlist.path"},
+		{name: "invalid scope", cfg: AllowlistConfig{Path: "/etc/santamon/allowlist.yaml", Scope: "priority"}, wantErr: "rules.allowlist.scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Rules.Allowlist = tt.cfg
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateSantaRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SantaRulesConfig
+		wantErr string
+	}{
+		{name: "valid", cfg: SantaRulesConfig{Enabled: true, MinSeverity: "high", File: "/var/lib/santamon/santa-rules.json"}},
+		{name: "disabled", cfg: SantaRulesConfig{MinSeverity: "bogus"}},
+		{name: "invalid severity", cfg: SantaRulesConfig{Enabled: true, MinSeverity: "info"}, wantErr: "santa_rules.min_severity"},
+		{name: "relative file", cfg: SantaRulesConfig{Enabled: true, MinSeverity: "low", File: "rules.json"}, wantErr: "santa_rules.file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.SantaRules = tt.cfg
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	block := ResponseActionConfig{Name: "block", Type: "santactl", Policy: "block", MinSeverity: "critical"}
+	tests := []struct {
+		name    string
+		actions []ResponseActionConfig
+		sync    SyncServerConfig
+		wantErr string
+	}{
+		{name: "valid", actions: []ResponseActionConfig{
+			block,
+			{Name: "isolate", Type: "command", Command: []string{"/usr/local/bin/isolate", "{{target_path}}"}, Timeout: 10 * time.Second},
+			{Name: "record", Type: "file", Path: "/var/log/santamon/critical.jsonl"},
+		}},
+		{name: "missing name", actions: []ResponseActionConfig{{Type: "file", Path: "/tmp/x"}}, wantErr: "name is required"},
+		{name: "duplicate name", actions: []ResponseActionConfig{block, block}, wantErr: "duplicate name"},
+		{name: "unknown type", actions: []ResponseActionConfig{{Name: "x", Type: "webhook"}}, wantErr: "type must be"},
+		{name: "relative command", actions: []ResponseActionConfig{{Name: "x", Type: "command", Command: []string{"isolate"}}}, wantErr: "absolute path"},
+		{name: "empty command", actions: []ResponseActionConfig{{Name: "x", Type: "command"}}, wantErr: "command is required"},
+		{name: "relative file", actions: []ResponseActionConfig{{Name: "x", Type: "file", Path: "hits.jsonl"}}, wantErr: "path must be"},
+		{name: "bad policy", actions: []ResponseActionConfig{{Name: "x", Type: "santactl", Policy: "allow"}}, wantErr: "policy must be"},
+		{name: "bad severity", actions: []ResponseActionConfig{{Name: "x", Type: "santactl", Policy: "block", MinSeverity: "urgent"}}, wantErr: "min_severity"},
+		{name: "sync server", sync: SyncServerConfig{Enabled: true, URL: "https://santa.example.com/api/rules", DryRun: true}},
+		{name: "sync server over http", sync: SyncServerConfig{Enabled: true, URL: "http://santa.example.com/api/rules"}, wantErr: "response.sync_server: url must use HTTPS"},
+		{name: "sync server without url", sync: SyncServerConfig{Enabled: true}, wantErr: "response.sync_server: url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Response = ResponseConfig{Enabled: true, AuditLog: "/var/lib/santamon/response-audit.jsonl", Actions: tt.actions, SyncServer: tt.sync}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 // Helper function to create a valid test config
 func validTestConfig() *Config {
 	return &Config{
@@ -387,7 +1390,7 @@ func validTestConfig() *Config {
 		},
 		Santa: SantaConfig{
 			Mode:          "json",
-			SpoolDir:      "/tmp/spool",
+			SpoolDir:      SpoolDirs{{Path: "/tmp/spool"}},
 			ArchiveDir:    "/tmp/test/spool_hits",
 			StabilityWait: 2 * time.Second,
 		},
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable lists settings (by YAML path) that can change without a restart.
+// A path also covers everything nested below it.
+var reloadable = []string{
+	"agent.log_level",
+	"agent.log_levels",
+	"rules.path",
+	"rules.rollback",
+	"rules.allowlist",
+	"rules.suppressions",
+	"shipper.endpoint",
+	"shipper.api_key",
+	"shipper.batch_size",
+	"shipper.flush_interval",
+	"shipper.timeout",
+	"shipper.retry",
+	"shipper.heartbeat.interval",
+}
+
+// Reload merges the hot-reloadable settings of next into a copy of c.
+// It returns the merged config, the reloadable settings that changed, and the
+// settings that changed but only take effect after a restart. next must
+// already be validated; c is never modified.
+func (c *Config) Reload(next *Config) (merged *Config, applied, restart []string) {
+	m := *c
+	m.Agent.LogLevel = next.Agent.LogLevel
+	m.Agent.LogLevels = next.Agent.LogLevels
+	m.Rules.Path = next.Rules.Path
+	m.Rules.Rollback = next.Rules.Rollback
+	m.Rules.Allowlist = next.Rules.Allowlist
+	m.Rules.Suppressions = next.Rules.Suppressions
+	m.Shipper.Endpoint = next.Shipper.Endpoint
+	m.Shipper.APIKey = next.Shipper.APIKey
+	m.Shipper.BatchSize = next.Shipper.BatchSize
+	m.Shipper.FlushInterval = next.Shipper.FlushInterval
+	m.Shipper.Timeout = next.Shipper.Timeout
+	m.Shipper.Retry = next.Shipper.Retry
+	m.Shipper.Heartbeat.Interval = next.Shipper.Heartbeat.Interval
+
+	for _, path := range diff("", reflect.ValueOf(*c), reflect.ValueOf(*next)) {
+		if isReloadable(path) {
+			applied = append(applied, path)
+		} else {
+			restart = append(restart, path)
+		}
+	}
+	return &m, applied, restart
+}
+
+func isReloadable(path string) bool {
+	for _, r := range reloadable {
+		if path == r || strings.HasPrefix(path, r+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns the YAML paths of leaf settings that differ between a and b
+func diff(prefix string, a, b reflect.Value) []string {
+	if a.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var paths []string
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		paths = append(paths, diff(name, a.Field(i), b.Field(i))...)
+	}
+	return paths
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	current := validTestConfig()
+	next := validTestConfig()
+
+	next.Agent.LogLevel = "debug"
+	next.Shipper.Endpoint = "https://siem.example.com/ingest"
+	next.Shipper.BatchSize = 500
+	next.Shipper.Retry.MaxAttempts = 7
+	next.Santa.SpoolDir = SpoolDirs{{Path: "/var/db/santa/other"}}
+	next.State.DBPath = "/var/lib/santamon/other.db"
+
+	merged, applied, restart := current.Reload(next)
+
+	wantApplied := []string{"agent.log_level", "shipper.endpoint", "shipper.batch_size", "shipper.retry.max_attempts"}
+	if !reflect.DeepEqual(applied, wantApplied) {
+		t.Errorf("applied = %v, want %v", applied, wantApplied)
+	}
+	wantRestart := []string{"santa.spool_dir", "state.db_path"}
+	if !reflect.DeepEqual(restart, wantRestart) {
+		t.Errorf("restart = %v, want %v", restart, wantRestart)
+	}
+
+	// Reloadable settings come from next, everything else stays as it was
+	if merged.Agent.LogLevel != "debug" || merged.Shipper.Endpoint != next.Shipper.Endpoint ||
+		merged.Shipper.BatchSize != 500 || merged.Shipper.Retry.MaxAttempts != 7 {
+		t.Errorf("Reloadable settings not applied: %+v", merged)
+	}
+	if merged.Santa.SpoolDir[0] != current.Santa.SpoolDir[0] || merged.State.DBPath != current.State.DBPath {
+		t.Error("Restart-only settings should not be applied")
+	}
+
+	// The current config is never modified
+	if current.Agent.LogLevel == "debug" || current.Shipper.BatchSize == 500 {
+		t.Error("Reload modified the current config")
+	}
+}
+
+func TestReloadNoChanges(t *testing.T) {
+	current := validTestConfig()
+	next := validTestConfig()
+
+	_, applied, restart := current.Reload(next)
+	if len(applied) != 0 || len(restart) != 0 {
+		t.Errorf("Expected no changes, got applied=%v restart=%v", applied, restart)
+	}
+
+	next.Shipper.Heartbeat.Interval = 5 * time.Minute
+	next.Shipper.Heartbeat.Enabled = !current.Shipper.Heartbeat.Enabled
+	_, applied, restart = current.Reload(next)
+	if !reflect.DeepEqual(applied, []string{"shipper.heartbeat.interval"}) {
+		t.Errorf("applied = %v", applied)
+	}
+	if !reflect.DeepEqual(restart, []string{"shipper.heartbeat.enabled"}) {
+		t.Errorf("restart = %v", restart)
+	}
+}
@@ -0,0 +1,194 @@
+package correlation
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+// groupRef identifies a correlation group in the window store
+type groupRef struct {
+	ruleID    string
+	windowKey string
+}
+
+// groupUsage is the number of stored events of a group
+type groupUsage struct {
+	groupRef
+	events int
+}
+
+// windowUsage tracks the stored events of correlation groups, most recently
+// used first, so caps are enforced without scanning the state DB
+type windowUsage struct {
+	order  *list.List // *groupUsage
+	groups map[groupRef]*list.Element
+	rules  map[string]int // Stored events per rule
+	total  int
+}
+
+func newWindowUsage() *windowUsage {
+	return &windowUsage{
+		order:  list.New(),
+		groups: make(map[groupRef]*list.Element),
+		rules:  make(map[string]int),
+	}
+}
+
+// set records that a group stores n events and marks it most recently used;
+// a group with no events is forgotten
+func (u *windowUsage) set(ref groupRef, n int) {
+	if e, ok := u.groups[ref]; ok {
+		g := e.Value.(*groupUsage)
+		u.rules[ref.ruleID] += n - g.events
+		u.total += n - g.events
+		if n == 0 {
+			u.order.Remove(e)
+			delete(u.groups, ref)
+			return
+		}
+		g.events = n
+		u.order.MoveToFront(e)
+		return
+	}
+	if n == 0 {
+		return
+	}
+	u.groups[ref] = u.order.PushFront(&groupUsage{groupRef: ref, events: n})
+	u.rules[ref.ruleID] += n
+	u.total += n
+}
+
+// stored returns the number of events a group stores
+func (u *windowUsage) stored(ref groupRef) int {
+	if e, ok := u.groups[ref]; ok {
+		return e.Value.(*groupUsage).events
+	}
+	return 0
+}
+
+// oldest returns the least recently used group of ruleID, or of any rule
+// when ruleID is empty
+func (u *windowUsage) oldest(ruleID string) (groupRef, bool) {
+	for e := u.order.Back(); e != nil; e = e.Prev() {
+		g := e.Value.(*groupUsage)
+		if ruleID == "" || g.ruleID == ruleID {
+			return g.groupRef, true
+		}
+	}
+	return groupRef{}, false
+}
+
+// sync replaces the tracked counts with counts read from the store, keeping
+// the order of groups still stored; new groups are least recently used
+func (u *windowUsage) sync(counts map[groupRef]int) {
+	for ref := range u.groups {
+		if _, ok := counts[ref]; !ok {
+			u.set(ref, 0)
+		}
+	}
+	for ref, n := range counts {
+		if e, ok := u.groups[ref]; ok {
+			g := e.Value.(*groupUsage)
+			u.rules[ref.ruleID] += n - g.events
+			u.total += n - g.events
+			g.events = n
+			continue
+		}
+		if n > 0 {
+			u.groups[ref] = u.order.PushBack(&groupUsage{groupRef: ref, events: n})
+			u.rules[ref.ruleID] += n
+			u.total += n
+		}
+	}
+}
+
+// SetCaps caps the events stored across all groups of a correlation rule and
+// across all rules, evicting the least recently used groups. A rule's
+// max_stored_events overrides ruleEvents; zero disables a cap.
+func (wm *WindowManager) SetCaps(ruleEvents, totalEvents int) {
+	wm.ruleCap = ruleEvents
+	wm.totalCap = totalEvents
+}
+
+// capped reports whether any cap applies to rule
+func (wm *WindowManager) capped(rule *rules.CorrelationRule) bool {
+	return wm.ruleCap > 0 || wm.totalCap > 0 || rule.MaxStoredEvents > 0
+}
+
+// syncUsage loads the stored event counts of all groups, the first time a
+// capped rule is processed and after garbage collection
+func (wm *WindowManager) syncUsage() error {
+	counts := make(map[groupRef]int)
+	err := wm.db.WindowUsage(func(ruleID, windowKey string, events int) {
+		counts[groupRef{ruleID, windowKey}] += events
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read window usage: %w", err)
+	}
+	if wm.usage == nil {
+		wm.usage = newWindowUsage()
+	}
+	wm.usage.sync(counts)
+	return nil
+}
+
+// stored returns the number of events a group stores, if rule is capped
+func (wm *WindowManager) stored(rule *rules.CorrelationRule, windowKey string) (int, error) {
+	if !wm.capped(rule) {
+		return 0, nil
+	}
+	if wm.usage == nil {
+		if err := wm.syncUsage(); err != nil {
+			return 0, err
+		}
+	}
+	return wm.usage.stored(groupRef{rule.ID, windowKey}), nil
+}
+
+// track records that a group of rule now stores n events and evicts the
+// least recently used groups while the rule or all rules exceed their cap
+func (wm *WindowManager) track(rule *rules.CorrelationRule, windowKey string, n int) error {
+	if !wm.capped(rule) {
+		return nil
+	}
+	if wm.usage == nil {
+		if err := wm.syncUsage(); err != nil {
+			return err
+		}
+	}
+	wm.usage.set(groupRef{rule.ID, windowKey}, n)
+
+	limit := wm.ruleCap
+	if rule.MaxStoredEvents > 0 {
+		limit = rule.MaxStoredEvents
+	}
+	for limit > 0 && wm.usage.rules[rule.ID] > limit {
+		if ok, err := wm.evictOldest(rule.ID); err != nil || !ok {
+			return err
+		}
+	}
+	for wm.totalCap > 0 && wm.usage.total > wm.totalCap {
+		if ok, err := wm.evictOldest(""); err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// evictOldest removes the least recently used group of ruleID, or of any
+// rule when ruleID is empty. It returns false when there is none.
+func (wm *WindowManager) evictOldest(ruleID string) (bool, error) {
+	ref, ok := wm.usage.oldest(ruleID)
+	if !ok {
+		return false, nil
+	}
+	if err := wm.db.DropWindowGroup(ref.ruleID, ref.windowKey); err != nil {
+		return false, fmt.Errorf("failed to evict correlation group: %w", err)
+	}
+	wm.usage.set(ref, 0)
+	wm.reclaimed.Evicted++
+	logger.Debug("evicted least recently used correlation group", "rule_id", ref.ruleID)
+	return true, nil
+}
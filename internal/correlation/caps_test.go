@@ -0,0 +1,115 @@
+package correlation
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+func TestWindowUsage(t *testing.T) {
+	u := newWindowUsage()
+	a := groupRef{"R1", "a"}
+	b := groupRef{"R1", "b"}
+	c := groupRef{"R2", "c"}
+	u.set(a, 2)
+	u.set(b, 3)
+	u.set(c, 1)
+	u.set(a, 4) // a is now most recently used
+
+	if u.total != 8 || u.rules["R1"] != 7 || u.rules["R2"] != 1 {
+		t.Errorf("total = %d, rules = %v; want 8, R1=7 R2=1", u.total, u.rules)
+	}
+	if ref, _ := u.oldest(""); ref != b {
+		t.Errorf("oldest() = %v, want %v", ref, b)
+	}
+	if ref, _ := u.oldest("R2"); ref != c {
+		t.Errorf("oldest(R2) = %v, want %v", ref, c)
+	}
+
+	u.set(b, 0)
+	if _, ok := u.groups[b]; ok || u.total != 5 {
+		t.Errorf("set(b, 0) kept group or total = %d, want 5", u.total)
+	}
+
+	// Sync keeps the order of known groups and adds new ones as oldest
+	u.sync(map[groupRef]int{a: 1, c: 1, {"R3", "d"}: 2})
+	if u.total != 4 || u.stored(a) != 1 {
+		t.Errorf("after sync total = %d, stored(a) = %d; want 4, 1", u.total, u.stored(a))
+	}
+	if ref, _ := u.oldest(""); ref != (groupRef{"R3", "d"}) {
+		t.Errorf("oldest() after sync = %v, want R3/d", ref)
+	}
+}
+
+func TestProcessCaps(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:              "TEST-CAP-RULE",
+				Title:           "Per-path groups",
+				Expr:            "kind == \"execution\"",
+				Window:          time.Hour,
+				GroupBy:         []string{"event.execution.target.executable.path"},
+				Threshold:       100,
+				MaxStoredEvents: 2,
+				Severity:        "low",
+				Enabled:         true,
+			},
+			{
+				ID:        "TEST-CAP-TOTAL",
+				Title:     "Global",
+				Expr:      "kind == \"execution\"",
+				Window:    time.Hour,
+				Threshold: 100,
+				Severity:  "low",
+				Enabled:   true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Hour)
+	wm.SetCaps(0, 4)
+	for _, path := range []string{"/bin/a", "/bin/b", "/bin/c"} {
+		if _, err := wm.Process(createTestMessageWithPath(path, "DECISION_ALLOW"), engine.GetCorrelations()); err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+	}
+
+	// The rule's cap of 2 evicts /bin/a with the third event; the global
+	// window then brings the total to 5 and the global cap of 4 evicts the
+	// least recently used group of any rule, /bin/b
+	groups, err := db.WindowGroups("TEST-CAP-RULE")
+	if err != nil {
+		t.Fatalf("WindowGroups failed: %v", err)
+	}
+	want := []string{hostKey("test-machine", "execution.target.executable.path=/bin/c")}
+	if !slices.Equal(groups, want) {
+		t.Errorf("WindowGroups() = %q, want %q", groups, want)
+	}
+	stored, err := db.GetWindowEvents("TEST-CAP-TOTAL", hostKey("test-machine", "_global"))
+	if err != nil {
+		t.Fatalf("GetWindowEvents failed: %v", err)
+	}
+	if len(stored) != 3 {
+		t.Errorf("got %d global events, want 3", len(stored))
+	}
+	if got := wm.Reclaimed().Evicted; got != 2 {
+		t.Errorf("Reclaimed().Evicted = %d, want 2", got)
+	}
+}
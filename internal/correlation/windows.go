@@ -2,25 +2,75 @@ package correlation
 
 import (
 	"fmt"
-	"log/slog"
+	"maps"
+	"math"
+	"slices"
 	"strings"
 	"time"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
 	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/lineage"
+	"github.com/0x4d31/santamon/internal/logutil"
 	"github.com/0x4d31/santamon/internal/rules"
 	"github.com/0x4d31/santamon/internal/state"
 )
 
+var logger = logutil.For("correlation").Slog()
+
 // WindowManager manages correlation windows
 type WindowManager struct {
-	db         *state.DB
+	db         state.Store
 	maxEvents  int
 	gcInterval time.Duration
 	lastGC     time.Time
+	eventTime  bool // Measure windows in event time (replay)
+	crossHost  bool // Share windows between machine IDs
+	lineage    *lineage.Store
+	reclaimed  GCStats
+
+	// Caps on stored events and the usage they are enforced against, loaded
+	// from the store when first needed
+	ruleCap          int
+	totalCap         int
+	usage            *windowUsage
+	compactBytes     int64
+	compactRequested bool
+}
+
+// GCStats counts correlation state removed by garbage collection
+type GCStats struct {
+	Events  int // Window events older than their window
+	Windows int // Windows left without events
+	Fired   int // Fire times past refire_after
+	Rates   int // Rate counters decayed below rateFloor
+	Dropped int // Groups of rules no longer loaded
+	Evicted int // Least recently used groups evicted to stay within caps
 }
 
-// WindowMatch represents a correlation window that exceeded threshold
+// Total returns the number of entries removed
+func (s GCStats) Total() int {
+	return s.Events + s.Windows + s.Fired + s.Rates + s.Dropped + s.Evicted
+}
+
+func (s *GCStats) add(o GCStats) {
+	s.Events += o.Events
+	s.Windows += o.Windows
+	s.Fired += o.Fired
+	s.Rates += o.Rates
+	s.Dropped += o.Dropped
+	s.Evicted += o.Evicted
+}
+
+// rateFloor is the decayed value below which a rate counter no longer moves
+// the estimated rate and is removed
+const rateFloor = 0.01
+
+// expiresField holds the deadline of a pending absence trigger in the window store
+const expiresField = "_expires"
+
+// WindowMatch represents a correlation window that exceeded threshold, or the
+// triggers of an absence correlation whose expected event never came
 type WindowMatch struct {
 	RuleID      string
 	Title       string
@@ -28,13 +78,15 @@ type WindowMatch struct {
 	Tags        []string
 	Description string
 	Count       int
+	Rate        float64 // Estimated events per second of a rate correlation
 	Events      []map[string]any
+	Hosts       []string // Distinct machine IDs of Events, with cross-host windows
 	GroupKey    string
 	Rule        *rules.CorrelationRule // Keep reference to rule for signal generation
 }
 
 // NewWindowManager creates a new correlation window manager
-func NewWindowManager(db *state.DB, maxEvents int, gcInterval time.Duration) *WindowManager {
+func NewWindowManager(db state.Store, maxEvents int, gcInterval time.Duration) *WindowManager {
 	return &WindowManager{
 		db:         db,
 		maxEvents:  maxEvents,
@@ -43,54 +95,121 @@ func NewWindowManager(db *state.DB, maxEvents int, gcInterval time.Duration) *Wi
 	}
 }
 
+// UseEventTime measures windows against each event's timestamp instead of
+// the wall clock, for replaying historical events
+func (wm *WindowManager) UseEventTime() {
+	wm.eventTime = true
+}
+
+// CrossHost shares correlation windows between the machine IDs of events,
+// for central deployments processing spools of many hosts. By default each
+// window only holds events of one machine.
+func (wm *WindowManager) CrossHost() {
+	wm.crossHost = true
+}
+
+// SetLineage sets the process lineage store that lineage.* group_by and
+// count_distinct fields are read from; without one they are empty
+func (wm *WindowManager) SetLineage(store *lineage.Store) {
+	wm.lineage = store
+}
+
+// eventMap returns the event map a rule groups and stores: the shared map,
+// or a copy with the event's lineage fields under "lineage". It returns nil
+// when the rule uses lineage fields the event's process has none of, so
+// processes of unknown ancestry don't all share one group.
+func (wm *WindowManager) eventMap(ev *rules.Event, rule *rules.CorrelationRule) (map[string]any, error) {
+	eventMap, err := ev.Map()
+	if err != nil || !rule.UsesLineage() {
+		return eventMap, err
+	}
+	if wm.lineage == nil {
+		return nil, nil
+	}
+	values := wm.lineage.Values(ev.Message())
+	if values == nil {
+		return nil, nil
+	}
+	withLineage := maps.Clone(eventMap)
+	withLineage["lineage"] = values
+	return withLineage, nil
+}
+
+// now returns the time windows end at for msg
+func (wm *WindowManager) now(msg *santapb.SantaMessage) time.Time {
+	if wm.eventTime {
+		if ts := events.EventTime(msg); !ts.IsZero() {
+			return ts
+		}
+	}
+	return time.Now()
+}
+
 // Process evaluates an event against correlation rules.
 func (wm *WindowManager) Process(msg *santapb.SantaMessage, correlationRules []*rules.CompiledCorrelation) ([]*WindowMatch, error) {
+	return wm.ProcessEvent(rules.NewEvent(msg), correlationRules)
+}
+
+// ProcessEvent evaluates an event against correlation rules, reusing its
+// cached activation and event map.
+func (wm *WindowManager) ProcessEvent(ev *rules.Event, correlationRules []*rules.CompiledCorrelation) ([]*WindowMatch, error) {
 	if len(correlationRules) == 0 {
 		return nil, nil
 	}
+	msg := ev.Message()
 
-	// Build typed activation with enum constants for CEL evaluation
-	activation := rules.BuildActivation(msg)
-
-	// Build event map for storage and grouping (correlation windows still use maps)
-	eventMap, err := events.ToMap(msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert message to map: %w", err)
-	}
-	events.BuildActivation(msg, eventMap)
+	// Typed activation with enum constants for CEL evaluation
+	activation := ev.Activation()
 
 	matches := make([]*WindowMatch, 0, 1) // Most events won't trigger correlations
 
 	for _, rule := range correlationRules {
-		result, _, err := rule.Program.Eval(activation)
-		if err != nil {
-			slog.Warn("correlation filter evaluation error", "rule_id", rule.Rule.ID, "error", err)
+		if rule.Expect != nil {
+			if err := wm.processAbsence(ev, rule); err != nil {
+				return nil, err
+			}
 			continue
 		}
-		matched, ok := result.Value().(bool)
-		if !ok {
-			slog.Warn("correlation filter returned non-boolean", "rule_id", rule.Rule.ID)
+		if !evalFilter(rule.Program, rule.Rule.ID, activation) || rule.Exceptions.Suppress(activation) {
 			continue
 		}
-		if !matched {
+
+		// Event map for storage and grouping (correlation windows still use maps)
+		eventMap, err := wm.eventMap(ev, rule.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert message to map: %w", err)
+		}
+		if eventMap == nil {
 			continue
 		}
-
 		groupKey := wm.extractGroupKey(eventMap, rule.Rule.GroupBy)
+		windowKey := wm.windowKey(eventMap, groupKey)
+		now := wm.now(msg)
+
+		if rule.Rule.IsRate() {
+			match, err := wm.processRate(rule.Rule, groupKey, windowKey, eventMap, now)
+			if err != nil {
+				return nil, err
+			}
+			if match != nil {
+				matches = append(matches, match)
+			}
+			continue
+		}
 
-		if err := wm.db.StoreWindowEvent(rule.Rule.ID, groupKey, eventMap); err != nil {
+		if err := wm.db.StoreWindowEvent(rule.Rule.ID, windowKey, eventMap); err != nil {
 			return nil, fmt.Errorf("failed to store window event: %w", err)
 		}
 
-		windowEvents, err := wm.db.GetWindowEvents(rule.Rule.ID, groupKey)
+		windowEvents, err := wm.db.GetWindowEvents(rule.Rule.ID, windowKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get window events: %w", err)
 		}
 
-		now := time.Now()
+		start := windowStart(rule.Rule, now)
 		recentEvents := make([]map[string]any, 0)
 		for _, evt := range windowEvents {
-			if withinWindow(evt, now, rule.Rule.Window) {
+			if withinWindow(evt, start) {
 				recentEvents = append(recentEvents, evt)
 			}
 		}
@@ -101,7 +220,20 @@ func (wm *WindowManager) Process(msg *santapb.SantaMessage, correlationRules []*
 
 		count := wm.countEvents(recentEvents, rule.Rule)
 
-		if count >= rule.Rule.Threshold {
+		matched := count >= rule.Rule.Threshold
+		if rule.Rule.MinDistinct > 0 {
+			matched = count >= rule.Rule.MinDistinct &&
+				(rule.Rule.MaxCount == 0 || len(recentEvents) <= rule.Rule.MaxCount)
+		}
+		if matched {
+			// Keep counting while the group is quiet; the window fires again
+			// with the next event once the period ends
+			if matched, err = wm.canFire(rule.Rule, windowKey, now); err != nil {
+				return nil, err
+			}
+		}
+
+		if matched {
 			matches = append(matches, &WindowMatch{
 				RuleID:      rule.Rule.ID,
 				Title:       rule.Rule.Title,
@@ -110,42 +242,484 @@ func (wm *WindowManager) Process(msg *santapb.SantaMessage, correlationRules []*
 				Description: rule.Rule.Description,
 				Count:       count,
 				Events:      recentEvents,
+				Hosts:       wm.hosts(recentEvents),
 				GroupKey:    groupKey,
 				Rule:        rule.Rule, // Store rule for signal generation
 			})
 
-			if err := wm.db.ReplaceWindowEvents(rule.Rule.ID, groupKey, nil); err != nil {
+			if err := wm.markFired(rule.Rule, windowKey, now); err != nil {
+				return nil, err
+			}
+			retained := recentEvents
+			if !rule.Rule.RetainOnMatch() {
+				retained = nil
+			}
+			if err := wm.db.ReplaceWindowEvents(rule.Rule.ID, windowKey, retained); err != nil {
 				return nil, fmt.Errorf("failed to clear window: %w", err)
 			}
+			if err := wm.track(rule.Rule, windowKey, len(retained)); err != nil {
+				return nil, err
+			}
 		} else {
-			if err := wm.db.ReplaceWindowEvents(rule.Rule.ID, groupKey, recentEvents); err != nil {
+			if err := wm.db.ReplaceWindowEvents(rule.Rule.ID, windowKey, recentEvents); err != nil {
 				return nil, fmt.Errorf("failed to persist window: %w", err)
 			}
+			if err := wm.track(rule.Rule, windowKey, len(recentEvents)); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	// Periodic garbage collection
+	// Periodic garbage collection of groups that stopped receiving events
 	if time.Since(wm.lastGC) >= wm.gcInterval {
 		wm.lastGC = time.Now()
-		// GC would clean old windows here
+		if _, err := wm.GC(wm.now(msg), correlationRules); err != nil {
+			logger.Warn("correlation window garbage collection failed", "error", err)
+		}
 	}
 
 	return matches, nil
 }
 
+// GC removes the state of correlationRules that can no longer match by now:
+// window events older than their window, fire times past refire_after and
+// decayed rate counters. Pending absence triggers are left for Expire.
+func (wm *WindowManager) GC(now time.Time, correlationRules []*rules.CompiledCorrelation) (GCStats, error) {
+	var stats GCStats
+	for _, compiled := range correlationRules {
+		rule := compiled.Rule
+		switch {
+		case rule.IsAbsence():
+			continue
+		case rule.IsRate():
+			n, err := wm.db.PruneRates(rule.ID, func(value float64, updated time.Time) bool {
+				if elapsed := now.Sub(updated); elapsed > 0 {
+					value *= math.Exp(-elapsed.Seconds() / rule.Window.Seconds())
+				}
+				return value >= rateFloor
+			})
+			if err != nil {
+				return stats, fmt.Errorf("failed to prune rate counters of %s: %w", rule.ID, err)
+			}
+			stats.Rates += n
+		default:
+			start := windowStart(rule, now)
+			removed, windows, err := wm.db.PruneWindowEvents(rule.ID, func(event map[string]any) bool {
+				return withinWindow(event, start)
+			})
+			if err != nil {
+				return stats, fmt.Errorf("failed to prune windows of %s: %w", rule.ID, err)
+			}
+			stats.Events += removed
+			stats.Windows += windows
+		}
+
+		n, err := wm.db.PruneWindowFired(rule.ID, func(fired time.Time) bool {
+			return now.Sub(fired) < rule.RefireAfter
+		})
+		if err != nil {
+			return stats, fmt.Errorf("failed to prune fire times of %s: %w", rule.ID, err)
+		}
+		stats.Fired += n
+	}
+
+	wm.reclaimed.add(stats)
+	if stats.Total() > 0 {
+		logger.Debug("reclaimed correlation state", "events", stats.Events, "windows", stats.Windows,
+			"fired", stats.Fired, "rates", stats.Rates)
+	}
+	if wm.usage != nil {
+		if err := wm.syncUsage(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, wm.checkSize()
+}
+
+// PruneBefore removes the stored window events of every rule, including rules
+// no longer loaded, whose event time is before cutoff. It enforces a
+// retention limit independent of rule windows and returns the number of
+// events removed.
+func (wm *WindowManager) PruneBefore(cutoff time.Time) (int, error) {
+	ruleIDs, err := wm.db.WindowRules()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list correlation rules: %w", err)
+	}
+	var stats GCStats
+	for _, ruleID := range ruleIDs {
+		removed, windows, err := wm.db.PruneWindowEvents(ruleID, func(event map[string]any) bool {
+			return withinWindow(event, cutoff)
+		})
+		if err != nil {
+			return stats.Events, fmt.Errorf("failed to prune windows of %s: %w", ruleID, err)
+		}
+		stats.Events += removed
+		stats.Windows += windows
+	}
+
+	wm.reclaimed.add(stats)
+	if wm.usage != nil && stats.Events > 0 {
+		if err := wm.syncUsage(); err != nil {
+			return stats.Events, err
+		}
+	}
+	return stats.Events, nil
+}
+
+// CompactAbove requests a compaction of the state DB at the next start once
+// correlation state takes more than size bytes, so the file shrinks after
+// pathological group keys are evicted. Zero disables the check.
+func (wm *WindowManager) CompactAbove(size int64) {
+	wm.compactBytes = size
+}
+
+// checkSize requests a state DB compaction when correlation state exceeds the
+// CompactAbove size
+func (wm *WindowManager) checkSize() error {
+	if wm.compactBytes <= 0 || wm.compactRequested {
+		return nil
+	}
+	size, err := wm.db.WindowBytes()
+	if err != nil {
+		return fmt.Errorf("failed to measure correlation state: %w", err)
+	}
+	if size <= wm.compactBytes {
+		return nil
+	}
+	if err := wm.db.RequestCompaction(); err != nil {
+		return fmt.Errorf("failed to request compaction: %w", err)
+	}
+	wm.compactRequested = true
+	logger.Warn("correlation state exceeds compaction threshold, compacting state DB at next start",
+		"bytes", size, "threshold", wm.compactBytes)
+	return nil
+}
+
+// Forget removes the state of correlation rules that are not in
+// correlationRules, such as rules deleted or disabled before a reload, and
+// returns the number of groups removed
+func (wm *WindowManager) Forget(correlationRules []*rules.CompiledCorrelation) (int, error) {
+	ids, err := wm.db.WindowRules()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list correlation state: %w", err)
+	}
+	loaded := make(map[string]bool, len(correlationRules))
+	for _, rule := range correlationRules {
+		loaded[rule.Rule.ID] = true
+	}
+
+	dropped := 0
+	for _, id := range ids {
+		if loaded[id] {
+			continue
+		}
+		n, err := wm.db.DropWindowRule(id)
+		if err != nil {
+			return dropped, fmt.Errorf("failed to drop correlation state of %s: %w", id, err)
+		}
+		dropped += n
+	}
+	wm.reclaimed.Dropped += dropped
+	if dropped > 0 && wm.usage != nil {
+		if err := wm.syncUsage(); err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+// Reclaimed returns the correlation state removed by GC, Forget and cap
+// eviction so far
+func (wm *WindowManager) Reclaimed() GCStats {
+	return wm.reclaimed
+}
+
+// windowStart returns when the window of rule ending at now starts
+func windowStart(rule *rules.CorrelationRule, now time.Time) time.Time {
+	if rule.IsTumbling() {
+		return now.Truncate(rule.Window)
+	}
+	return now.Add(-rule.Window)
+}
+
+// processRate adds an event to a rate correlation's decaying counter and
+// returns a match when the estimated rate reaches the rule's rate. The
+// counter holds about rate*window events at a steady rate.
+func (wm *WindowManager) processRate(rule *rules.CorrelationRule, groupKey, windowKey string, eventMap map[string]any, now time.Time) (*WindowMatch, error) {
+	value, err := wm.db.IncrementRate(rule.ID, windowKey, now, rule.Window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update rate counter: %w", err)
+	}
+	if err := wm.track(rule, windowKey, 1); err != nil {
+		return nil, err
+	}
+	rate := value / rule.Window.Seconds()
+	if rate < rule.Rate {
+		return nil, nil
+	}
+	if ok, err := wm.canFire(rule, windowKey, now); err != nil || !ok {
+		return nil, err
+	}
+	if err := wm.markFired(rule, windowKey, now); err != nil {
+		return nil, err
+	}
+	if !rule.RetainOnMatch() {
+		if err := wm.db.ResetRate(rule.ID, windowKey); err != nil {
+			return nil, fmt.Errorf("failed to reset rate counter: %w", err)
+		}
+		if err := wm.track(rule, windowKey, 0); err != nil {
+			return nil, err
+		}
+	}
+	return &WindowMatch{
+		RuleID:      rule.ID,
+		Title:       rule.Title,
+		Severity:    rule.Severity,
+		Tags:        rule.Tags,
+		Description: rule.Description,
+		Count:       int(math.Round(value)),
+		Rate:        rate,
+		Events:      []map[string]any{eventMap},
+		GroupKey:    groupKey,
+		Rule:        rule,
+	}, nil
+}
+
+// canFire reports whether a group's refire_after period has passed
+func (wm *WindowManager) canFire(rule *rules.CorrelationRule, windowKey string, now time.Time) (bool, error) {
+	if rule.RefireAfter == 0 {
+		return true, nil
+	}
+	fired, err := wm.db.WindowFired(rule.ID, windowKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to get window fire time: %w", err)
+	}
+	return now.Sub(fired) >= rule.RefireAfter, nil
+}
+
+// markFired records a match for refire_after
+func (wm *WindowManager) markFired(rule *rules.CorrelationRule, windowKey string, now time.Time) error {
+	if rule.RefireAfter == 0 {
+		return nil
+	}
+	if err := wm.db.SetWindowFired(rule.ID, windowKey, now); err != nil {
+		return fmt.Errorf("failed to store window fire time: %w", err)
+	}
+	return nil
+}
+
+// DistinctValue returns the count_distinct value of an event: the fields'
+// values joined by "|", or "" when all of them are empty
+func DistinctValue(event map[string]any, fields []string) string {
+	values := make([]string, len(fields))
+	empty := true
+	for i, field := range fields {
+		// Strip "event." prefix if present (config uses event.field.path, but map doesn't have that prefix)
+		values[i] = events.ExtractField(event, strings.TrimPrefix(field, "event."))
+		if values[i] != "" {
+			empty = false
+		}
+	}
+	if empty {
+		return ""
+	}
+	return strings.Join(values, "|")
+}
+
+// evalFilter evaluates a correlation expression, logging errors as no match
+func evalFilter(program *rules.Program, ruleID string, activation any) bool {
+	result, _, err := program.Eval(activation)
+	if err != nil {
+		logger.Warn("correlation filter evaluation error", "rule_id", ruleID, "error", err)
+		return false
+	}
+	matched, ok := result.Value().(bool)
+	if !ok {
+		logger.Warn("correlation filter returned non-boolean", "rule_id", ruleID)
+		return false
+	}
+	return matched
+}
+
+// processAbsence resolves the pending triggers an expected event answers and
+// starts a new pending trigger when the event matches the rule expression
+func (wm *WindowManager) processAbsence(ev *rules.Event, rule *rules.CompiledCorrelation) error {
+	activation := ev.Activation()
+	now := wm.now(ev.Message())
+
+	if evalFilter(rule.Expect, rule.Rule.ID, activation) {
+		eventMap, err := wm.eventMap(ev, rule.Rule)
+		if err != nil {
+			return fmt.Errorf("failed to convert message to map: %w", err)
+		}
+		var pending []map[string]any
+		windowKey := ""
+		if eventMap != nil {
+			windowKey = wm.windowKey(eventMap, groupKeyFrom(eventMap, rule.Rule.ExpectFields(), rule.Rule.GroupBy))
+			if pending, err = wm.db.GetWindowEvents(rule.Rule.ID, windowKey); err != nil {
+				return fmt.Errorf("failed to get window events: %w", err)
+			}
+		}
+		if len(pending) > 0 {
+			// Triggers past their deadline stay for Expire to report: the
+			// expected event came too late
+			overdue := make([]map[string]any, 0)
+			for _, evt := range pending {
+				if deadline, ok := eventTime(evt[expiresField]); ok && now.After(deadline) {
+					overdue = append(overdue, evt)
+				}
+			}
+			if err := wm.db.ReplaceWindowEvents(rule.Rule.ID, windowKey, overdue); err != nil {
+				return fmt.Errorf("failed to persist window: %w", err)
+			}
+			if err := wm.track(rule.Rule, windowKey, len(overdue)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !evalFilter(rule.Program, rule.Rule.ID, activation) || rule.Exceptions.Suppress(activation) {
+		return nil
+	}
+	eventMap, err := wm.eventMap(ev, rule.Rule)
+	if err != nil {
+		return fmt.Errorf("failed to convert message to map: %w", err)
+	}
+	if eventMap == nil {
+		return nil
+	}
+	// The event map is shared with other rules; store a copy with the deadline
+	trigger := maps.Clone(eventMap)
+	trigger[expiresField] = now.Add(rule.Rule.Window).UTC().Format(time.RFC3339Nano)
+	windowKey := wm.windowKey(eventMap, wm.extractGroupKey(eventMap, rule.Rule.GroupBy))
+	stored, err := wm.stored(rule.Rule, windowKey)
+	if err != nil {
+		return err
+	}
+	if err := wm.db.StoreWindowEvent(rule.Rule.ID, windowKey, trigger); err != nil {
+		return fmt.Errorf("failed to store window event: %w", err)
+	}
+	stored++
+	if wm.maxEvents > 0 {
+		if err := wm.db.CleanWindowEvents(rule.Rule.ID, windowKey, wm.maxEvents); err != nil {
+			return fmt.Errorf("failed to trim window: %w", err)
+		}
+		stored = min(stored, wm.maxEvents)
+	}
+	return wm.track(rule.Rule, windowKey, stored)
+}
+
+// Expire reports the pending triggers of absence correlations whose window
+// ended before now without the expected event, one match per group, and
+// removes them from the store. It should only run once the spool backlog is
+// processed, so expected events waiting in the spool are seen first.
+func (wm *WindowManager) Expire(now time.Time, correlationRules []*rules.CompiledCorrelation) ([]*WindowMatch, error) {
+	var matches []*WindowMatch
+	for _, rule := range correlationRules {
+		if rule.Expect == nil {
+			continue
+		}
+		windowKeys, err := wm.db.WindowGroups(rule.Rule.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list windows: %w", err)
+		}
+		for _, windowKey := range windowKeys {
+			pending, err := wm.db.GetWindowEvents(rule.Rule.ID, windowKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get window events: %w", err)
+			}
+			expired := make([]map[string]any, 0)
+			remaining := make([]map[string]any, 0, len(pending))
+			for _, evt := range pending {
+				deadline, ok := eventTime(evt[expiresField])
+				if ok && !now.After(deadline) {
+					remaining = append(remaining, evt)
+					continue
+				}
+				delete(evt, expiresField)
+				expired = append(expired, evt)
+			}
+			if len(expired) == 0 {
+				continue
+			}
+			if err := wm.db.ReplaceWindowEvents(rule.Rule.ID, windowKey, remaining); err != nil {
+				return nil, fmt.Errorf("failed to persist window: %w", err)
+			}
+			if err := wm.track(rule.Rule, windowKey, len(remaining)); err != nil {
+				return nil, err
+			}
+			groupKey := windowKey
+			if _, after, ok := strings.Cut(windowKey, hostSeparator); ok {
+				groupKey = after
+			}
+			matches = append(matches, &WindowMatch{
+				RuleID:      rule.Rule.ID,
+				Title:       rule.Rule.Title,
+				Severity:    rule.Rule.Severity,
+				Tags:        rule.Rule.Tags,
+				Description: rule.Rule.Description,
+				Count:       len(expired),
+				Events:      expired,
+				Hosts:       wm.hosts(expired),
+				GroupKey:    groupKey,
+				Rule:        rule.Rule,
+			})
+		}
+	}
+	return matches, nil
+}
+
 // extractGroupKey builds a group key from event fields.
 // If no groupBy fields are specified, returns "_global" to group all events together.
 func (wm *WindowManager) extractGroupKey(event map[string]any, groupBy []string) string {
-	if len(groupBy) == 0 {
+	return groupKeyFrom(event, groupBy, groupBy)
+}
+
+// hostSeparator separates the machine ID from the group key of a window
+// that only holds one machine's events
+const hostSeparator = "\x00"
+
+// windowKey returns the key a group's window is stored under: the group key,
+// prefixed with the event's machine ID unless windows are cross-host
+func (wm *WindowManager) windowKey(event map[string]any, groupKey string) string {
+	if wm.crossHost {
+		return groupKey
+	}
+	return hostKey(events.ExtractField(event, "machine_id"), groupKey)
+}
+
+func hostKey(machineID, groupKey string) string {
+	return machineID + hostSeparator + groupKey
+}
+
+// hosts returns the distinct machine IDs of window events with cross-host
+// windows, in order of appearance
+func (wm *WindowManager) hosts(windowEvents []map[string]any) []string {
+	if !wm.crossHost {
+		return nil
+	}
+	var hosts []string
+	for _, evt := range windowEvents {
+		if id := events.ExtractField(evt, "machine_id"); id != "" && !slices.Contains(hosts, id) {
+			hosts = append(hosts, id)
+		}
+	}
+	return hosts
+}
+
+// groupKeyFrom builds a group key from the values of fields, named after the
+// matching entries of names, so an expected event keys like its trigger
+func groupKeyFrom(event map[string]any, fields, names []string) string {
+	if len(fields) == 0 {
 		return "_global"
 	}
 
-	parts := make([]string, 0, len(groupBy))
-	for _, field := range groupBy {
+	parts := make([]string, 0, len(fields))
+	for i, field := range fields {
 		// Strip "event." prefix if present (config uses event.field.path, but map doesn't have that prefix)
 		cleanField := strings.TrimPrefix(field, "event.")
 		value := events.ExtractField(event, cleanField)
-		parts = append(parts, fmt.Sprintf("%s=%s", cleanField, value))
+		parts = append(parts, fmt.Sprintf("%s=%s", strings.TrimPrefix(names[i], "event."), value))
 	}
 
 	return strings.Join(parts, "|")
@@ -153,14 +727,11 @@ func (wm *WindowManager) extractGroupKey(event map[string]any, groupBy []string)
 
 // countEvents counts events based on correlation rule configuration
 func (wm *WindowManager) countEvents(windowEvents []map[string]any, rule *rules.CorrelationRule) int {
-	if rule.CountDistinct != "" {
-		// Count distinct values of a field
+	if len(rule.CountDistinct) > 0 {
+		// Count distinct value tuples of the fields
 		seen := make(map[string]struct{})
 		for _, evt := range windowEvents {
-			// Strip "event." prefix if present (config uses event.field.path, but map doesn't have that prefix)
-			cleanField := strings.TrimPrefix(rule.CountDistinct, "event.")
-			value := events.ExtractField(evt, cleanField)
-			if value != "" {
+			if value := DistinctValue(evt, rule.CountDistinct); value != "" {
 				seen[value] = struct{}{}
 			}
 		}
@@ -171,29 +742,27 @@ func (wm *WindowManager) countEvents(windowEvents []map[string]any, rule *rules.
 	return len(windowEvents)
 }
 
-func withinWindow(event map[string]any, now time.Time, window time.Duration) bool {
-	if window == 0 {
-		return true
-	}
-	v, ok := event["event_time"]
-	if !ok || v == nil {
+// withinWindow reports whether an event happened at or after the window start
+func withinWindow(event map[string]any, start time.Time) bool {
+	ts, ok := eventTime(event["event_time"])
+	if !ok {
 		return false
 	}
-	var ts time.Time
+	return !ts.Before(start)
+}
+
+// eventTime reads a timestamp stored in a window event
+func eventTime(v any) (time.Time, bool) {
 	switch t := v.(type) {
 	case time.Time:
-		ts = t
+		return t, true
 	case string:
 		// Try RFC3339Nano then RFC3339
 		if parsed, err := time.Parse(time.RFC3339Nano, t); err == nil {
-			ts = parsed
+			return parsed, true
 		} else if parsed, err := time.Parse(time.RFC3339, t); err == nil {
-			ts = parsed
-		} else {
-			return false
+			return parsed, true
 		}
-	default:
-		return false
 	}
-	return now.Sub(ts) <= window
+	return time.Time{}, false
 }
@@ -8,6 +8,8 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/lineage"
 	"github.com/0x4d31/santamon/internal/rules"
 	"github.com/0x4d31/santamon/internal/state"
 )
@@ -239,7 +241,7 @@ func TestProcessCountDistinct(t *testing.T) {
 				Title:         "Multiple binaries blocked",
 				Expr:          "kind == \"execution\" && event.execution.decision == DECISION_DENY",
 				Window:        5 * time.Minute,
-				CountDistinct: "execution.target.executable.hash.hash",
+				CountDistinct: rules.Fields{"execution.target.executable.hash.hash"},
 				Threshold:     3,
 				Severity:      "high",
 				Enabled:       true,
@@ -287,6 +289,220 @@ func TestProcessCountDistinct(t *testing.T) {
 	}
 }
 
+func TestProcessMinDistinct(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// Three distinct hash and user pairs within at most four events: a sweep,
+	// not one binary retried over and over
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:    "TEST-DISTINCT-002",
+				Title: "Denied sweep",
+				Expr:  "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+				CountDistinct: rules.Fields{
+					"execution.target.executable.hash.hash",
+					"event.execution.instigator.effective_user.name",
+				},
+				Window:      5 * time.Minute,
+				MinDistinct: 3,
+				MaxCount:    4,
+				Severity:    "high",
+				Enabled:     true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Minute)
+	correlations := engine.GetCorrelations()
+
+	testCases := []struct {
+		hash, user    string
+		shouldTrigger bool
+	}{
+		{"hash1", "user1", false},
+		{"hash2", "user1", false},
+		{"hash1", "user2", true}, // 3 distinct pairs in 3 events
+		{"hash1", "user1", false},
+		{"hash1", "user1", false},
+		{"hash1", "user1", false},
+		{"hash2", "user1", false},
+		{"hash3", "user1", false}, // 3 distinct pairs, but in 5 events
+	}
+
+	for i, tc := range testCases {
+		matches, err := wm.Process(createTestMessageWithHashUser(tc.hash, tc.user), correlations)
+		if err != nil {
+			t.Fatalf("case %d: Process failed: %v", i, err)
+		}
+		if tc.shouldTrigger != (len(matches) == 1) {
+			t.Errorf("case %d: got %d matches, want trigger %v", i, len(matches), tc.shouldTrigger)
+		}
+		if len(matches) == 1 && matches[0].Count != 3 {
+			t.Errorf("case %d: Count = %d, want 3 distinct", i, matches[0].Count)
+		}
+	}
+}
+
+func TestProcessCrossHost(t *testing.T) {
+	// The same denied hash on three machines
+	tests := []struct {
+		name      string
+		crossHost bool
+		want      []string // Hosts of the match, nil for none
+	}{
+		{"per host", false, nil},
+		{"cross host", true, []string{"host-a", "host-b", "host-c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+			if err != nil {
+				t.Fatalf("failed to open db: %v", err)
+			}
+			defer func() { _ = db.Close() }()
+
+			engine, err := rules.NewEngine()
+			if err != nil {
+				t.Fatalf("NewEngine failed: %v", err)
+			}
+			err = engine.LoadRules(&rules.RulesConfig{
+				Correlations: []*rules.CorrelationRule{
+					{
+						ID:            "TEST-FLEET-001",
+						Title:         "Hash denied on many hosts",
+						Expr:          "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+						Window:        10 * time.Minute,
+						GroupBy:       []string{"event.execution.target.executable.hash.hash"},
+						CountDistinct: rules.Fields{"machine_id"},
+						Threshold:     3,
+						Severity:      "high",
+						Enabled:       true,
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("LoadRules failed: %v", err)
+			}
+
+			wm := NewWindowManager(db, 100, time.Minute)
+			if tt.crossHost {
+				wm.CrossHost()
+			}
+			var matches []*WindowMatch
+			for _, host := range []string{"host-a", "host-b", "host-a", "host-c"} {
+				msg := createTestMessageWithHashUser("hash1", "user1")
+				msg.MachineId = proto.String(host)
+				matches, err = wm.Process(msg, engine.GetCorrelations())
+				if err != nil {
+					t.Fatalf("Process failed: %v", err)
+				}
+			}
+
+			if tt.want == nil {
+				if len(matches) != 0 {
+					t.Errorf("expected per-host windows not to match, got %d matches", len(matches))
+				}
+				return
+			}
+			if len(matches) != 1 {
+				t.Fatalf("expected 1 match, got %d", len(matches))
+			}
+			if got := matches[0].Hosts; len(got) != len(tt.want) || got[0] != tt.want[0] || got[2] != tt.want[2] {
+				t.Errorf("Hosts = %v, want %v", got, tt.want)
+			}
+			if matches[0].GroupKey != "execution.target.executable.hash.hash=hash1" {
+				t.Errorf("GroupKey = %q", matches[0].GroupKey)
+			}
+		})
+	}
+}
+
+func TestProcessLineage(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:        "TEST-LINEAGE-001",
+				Title:     "Busy session",
+				Expr:      "kind == \"execution\"",
+				Window:    5 * time.Minute,
+				GroupBy:   []string{"lineage.root"},
+				Threshold: 3,
+				Severity:  "medium",
+				Enabled:   true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	store := lineage.NewStore(lineage.Config{})
+	wm := NewWindowManager(db, 100, time.Minute)
+	wm.SetLineage(store)
+
+	tests := []struct {
+		pid, parent int32
+		path        string
+		seen        bool // Recorded in the lineage store
+		want        bool
+	}{
+		{1, 0, "/Applications/Terminal.app", true, false},
+		{10, 0, "/Applications/Xcode.app", true, false},
+		{2, 1, "/bin/zsh", true, false},
+		{11, 10, "/usr/bin/clang", true, false},
+		{20, 19, "/usr/bin/unknown", false, false}, // No lineage: not counted
+		{3, 2, "/usr/bin/curl", true, true},        // Third process under Terminal
+	}
+	for i, tt := range tests {
+		msg := createTestMessageWithPath(tt.path, "DECISION_ALLOW")
+		exec := msg.GetExecution()
+		exec.Target.Id = &santapb.ProcessID{Pid: proto.Int32(tt.pid), Pidversion: proto.Int32(1)}
+		exec.Target.ParentId = &santapb.ProcessID{Pid: proto.Int32(tt.parent), Pidversion: proto.Int32(1)}
+		if tt.seen {
+			store.UpsertFromExecution(msg, exec)
+		}
+
+		matches, err := wm.Process(msg, engine.GetCorrelations())
+		if err != nil {
+			t.Fatalf("case %d: Process failed: %v", i, err)
+		}
+		if tt.want != (len(matches) == 1) {
+			t.Fatalf("case %d: got %d matches, want match %v", i, len(matches), tt.want)
+		}
+		if tt.want {
+			if matches[0].GroupKey != "lineage.root=1:1" {
+				t.Errorf("GroupKey = %q, want lineage.root=1:1", matches[0].GroupKey)
+			}
+			if path := events.ExtractField(matches[0].Events[2], "lineage.parent_path"); path != "/bin/zsh" {
+				t.Errorf("stored lineage.parent_path = %q, want /bin/zsh", path)
+			}
+		}
+	}
+}
+
 func TestProcessWindowExpiration(t *testing.T) {
 	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
 	if err != nil {
@@ -348,6 +564,313 @@ func TestProcessWindowExpiration(t *testing.T) {
 	}
 }
 
+func TestProcessEventTime(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:        "TEST-REPLAY-001",
+				Title:     "Replay window test",
+				Expr:      "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+				Window:    time.Minute,
+				Threshold: 3,
+				Severity:  "low",
+				Enabled:   true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Minute)
+	wm.UseEventTime()
+	correlations := engine.GetCorrelations()
+
+	// Three denials within a minute, a week ago: outside any wall-clock window
+	start := time.Now().Add(-7 * 24 * time.Hour)
+	var matches []*WindowMatch
+	for i := 0; i < 3; i++ {
+		msg := createTestMessage("machine-1", "DECISION_DENY")
+		msg.EventTime = timestamppb.New(start.Add(time.Duration(i) * 10 * time.Second))
+		matches, err = wm.Process(msg, correlations)
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+	}
+	if len(matches) != 1 || matches[0].Count != 3 {
+		t.Errorf("expected one match of 3 historical events, got %+v", matches)
+	}
+}
+
+func TestProcessEmitPolicy(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		modifier func(cr *rules.CorrelationRule)
+		offsets  []time.Duration // Event times after start
+		want     []int           // Match counts per event, 0 for none
+	}{
+		{
+			name:    "sliding clears on match",
+			offsets: []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 40 * time.Second},
+			want:    []int{0, 2, 0, 2, 0},
+		},
+		{
+			name:     "sliding retains on match",
+			modifier: func(cr *rules.CorrelationRule) { cr.OnMatch = "retain" },
+			offsets:  []time.Duration{0, 10 * time.Second, 20 * time.Second, 90 * time.Second},
+			want:     []int{0, 2, 3, 0},
+		},
+		{
+			name: "retain with refire suppression",
+			modifier: func(cr *rules.CorrelationRule) {
+				cr.OnMatch = "retain"
+				cr.RefireAfter = 30 * time.Second
+			},
+			offsets: []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 45 * time.Second},
+			want:    []int{0, 2, 0, 0, 5},
+		},
+		{
+			// Boundaries at whole minutes: the first two events fall in
+			// different intervals even though they are 20s apart
+			name:     "tumbling",
+			modifier: func(cr *rules.CorrelationRule) { cr.WindowMode = "tumbling" },
+			offsets:  []time.Duration{50 * time.Second, 70 * time.Second, 80 * time.Second, 130 * time.Second},
+			want:     []int{0, 0, 2, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+			if err != nil {
+				t.Fatalf("failed to open db: %v", err)
+			}
+			defer func() { _ = db.Close() }()
+
+			rule := &rules.CorrelationRule{
+				ID:        "TEST-POLICY-001",
+				Title:     "Emit policy test",
+				Expr:      "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+				Window:    time.Minute,
+				Threshold: 2,
+				Severity:  "low",
+				Enabled:   true,
+			}
+			if tt.modifier != nil {
+				tt.modifier(rule)
+			}
+			engine, err := rules.NewEngine()
+			if err != nil {
+				t.Fatalf("NewEngine failed: %v", err)
+			}
+			if err := engine.LoadRules(&rules.RulesConfig{Correlations: []*rules.CorrelationRule{rule}}); err != nil {
+				t.Fatalf("LoadRules failed: %v", err)
+			}
+
+			wm := NewWindowManager(db, 100, time.Minute)
+			wm.UseEventTime()
+			for i, offset := range tt.offsets {
+				msg := createTestMessage("machine-1", "DECISION_DENY")
+				msg.EventTime = timestamppb.New(start.Add(offset))
+				matches, err := wm.Process(msg, engine.GetCorrelations())
+				if err != nil {
+					t.Fatalf("Process failed: %v", err)
+				}
+				got := 0
+				if len(matches) > 0 {
+					got = matches[0].Count
+				}
+				if got != tt.want[i] {
+					t.Errorf("event %d at +%v: match count = %d, want %d", i, offset, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRate(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:       "TEST-RATE-001",
+				Title:    "Denial storm",
+				Expr:     "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+				Window:   2 * time.Second,
+				Rate:     5,
+				Severity: "high",
+				Enabled:  true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Minute)
+	wm.UseEventTime()
+	correlations := engine.GetCorrelations()
+
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	send := func(n int, interval time.Duration) []*WindowMatch {
+		t.Helper()
+		var all []*WindowMatch
+		for range n {
+			at = at.Add(interval)
+			msg := createTestMessage("machine-1", "DECISION_DENY")
+			msg.EventTime = timestamppb.New(at)
+			matches, err := wm.Process(msg, correlations)
+			if err != nil {
+				t.Fatalf("Process failed: %v", err)
+			}
+			all = append(all, matches...)
+		}
+		return all
+	}
+
+	// One event per second stays well below 5/s however long it lasts
+	if matches := send(60, time.Second); len(matches) != 0 {
+		t.Errorf("expected no matches at 1/s, got %d", len(matches))
+	}
+
+	// Twenty per second crosses it quickly, and the counter restarts after a match
+	matches := send(40, 50*time.Millisecond)
+	if len(matches) < 2 {
+		t.Fatalf("expected repeated matches at 20/s, got %d", len(matches))
+	}
+	m := matches[0]
+	if m.Rate < 5 || m.Count < 10 || len(m.Events) != 1 {
+		t.Errorf("match = rate %v, count %d, %d events; want rate >= 5, count >= 10, 1 event", m.Rate, m.Count, len(m.Events))
+	}
+}
+
+func TestProcessAbsence(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	// A denied binary that is not allowed within a minute
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:            "TEST-ABSENCE-001",
+				Title:         "Denied binary never allowed",
+				Expr:          "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+				Expect:        "kind == \"execution\" && event.execution.decision == DECISION_ALLOW",
+				GroupBy:       []string{"execution.target.executable.path"},
+				ExpectGroupBy: []string{"event.execution.target.executable.path"},
+				Window:        time.Minute,
+				Severity:      "medium",
+				Enabled:       true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Minute)
+	wm.UseEventTime()
+	correlations := engine.GetCorrelations()
+
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	process := func(path, decision string, offset time.Duration) {
+		t.Helper()
+		msg := createTestMessageWithPath(path, decision)
+		msg.EventTime = timestamppb.New(start.Add(offset))
+		matches, err := wm.Process(msg, correlations)
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		if len(matches) != 0 {
+			t.Errorf("Process() returned %d matches, absence rules only match on Expire", len(matches))
+		}
+	}
+
+	process("/bin/answered", "DECISION_DENY", 0)
+	process("/bin/missing", "DECISION_DENY", 0)
+	process("/bin/late", "DECISION_DENY", 0)
+	process("/bin/answered", "DECISION_ALLOW", 30*time.Second)
+	// Another path's follow-up does not answer the trigger
+	process("/bin/other", "DECISION_ALLOW", 30*time.Second)
+
+	// Nothing expires inside the window
+	matches, err := wm.Expire(start.Add(59*time.Second), correlations)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected no matches inside the window, got %d", len(matches))
+	}
+
+	// A follow-up after the deadline is too late
+	process("/bin/late", "DECISION_ALLOW", 2*time.Minute)
+
+	matches, err = wm.Expire(start.Add(2*time.Minute), correlations)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	got := make(map[string]int)
+	for _, m := range matches {
+		got[m.GroupKey] = m.Count
+		if m.Rule == nil || !m.Rule.IsAbsence() {
+			t.Errorf("match %s lacks its absence rule", m.GroupKey)
+		}
+		for _, evt := range m.Events {
+			if _, ok := evt[expiresField]; ok {
+				t.Errorf("match %s event still has %s", m.GroupKey, expiresField)
+			}
+		}
+	}
+	want := map[string]int{
+		"execution.target.executable.path=/bin/missing": 1,
+		"execution.target.executable.path=/bin/late":    1,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expire() groups = %v, want %v", got, want)
+	}
+	for key, count := range want {
+		if got[key] != count {
+			t.Errorf("group %s count = %d, want %d", key, got[key], count)
+		}
+	}
+
+	// Reported triggers are removed
+	matches, err = wm.Expire(start.Add(time.Hour), correlations)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected expired triggers to be removed, got %d matches", len(matches))
+	}
+}
+
 func TestProcessPrunesExpiredStoredEvents(t *testing.T) {
 	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
 	if err != nil {
@@ -395,7 +918,7 @@ func TestProcessPrunesExpiredStoredEvents(t *testing.T) {
 		t.Fatalf("Process failed: %v", err)
 	}
 
-	stored, err := db.GetWindowEvents("TEST-PRUNE-001", "_global")
+	stored, err := db.GetWindowEvents("TEST-PRUNE-001", hostKey("test-machine", "_global"))
 	if err != nil {
 		t.Fatalf("GetWindowEvents failed: %v", err)
 	}
@@ -407,6 +930,145 @@ func TestProcessPrunesExpiredStoredEvents(t *testing.T) {
 	}
 }
 
+func TestGC(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{
+			{
+				ID:          "TEST-GC-COUNT",
+				Title:       "Count",
+				Expr:        "kind == \"execution\"",
+				Window:      time.Minute,
+				Threshold:   5,
+				RefireAfter: 10 * time.Minute,
+				Severity:    "low",
+				Enabled:     true,
+			},
+			{
+				ID:       "TEST-GC-RATE",
+				Title:    "Rate",
+				Expr:     "kind == \"execution\"",
+				Window:   time.Minute,
+				Rate:     100,
+				Severity: "low",
+				Enabled:  true,
+			},
+			{
+				ID:       "TEST-GC-ABSENCE",
+				Title:    "Absence",
+				Expr:     "kind == \"execution\"",
+				Expect:   "kind == \"file_access\"",
+				Window:   time.Minute,
+				Severity: "low",
+				Enabled:  true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Hour)
+	correlations := engine.GetCorrelations()
+	if _, err := wm.Process(createTestMessage("test-machine", "DECISION_ALLOW"), correlations); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	now := time.Now()
+	if err := db.SetWindowFired("TEST-GC-COUNT", hostKey("test-machine", "_global"), now); err != nil {
+		t.Fatalf("Failed to set window fire time: %v", err)
+	}
+
+	// Nothing has expired yet
+	stats, err := wm.GC(now, correlations)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if stats.Total() != 0 {
+		t.Errorf("GC(now) = %+v, want nothing removed", stats)
+	}
+
+	stats, err = wm.GC(now.Add(time.Hour), correlations)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	want := GCStats{Events: 1, Windows: 1, Fired: 1, Rates: 1}
+	if stats != want {
+		t.Errorf("GC(now+1h) = %+v, want %+v", stats, want)
+	}
+	if got := wm.Reclaimed(); got != want {
+		t.Errorf("Reclaimed() = %+v, want %+v", got, want)
+	}
+
+	// Pending absence triggers are left for Expire to report
+	pending, err := db.GetWindowEvents("TEST-GC-ABSENCE", hostKey("test-machine", "_global"))
+	if err != nil {
+		t.Fatalf("GetWindowEvents failed: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Errorf("got %d pending absence triggers, want 1", len(pending))
+	}
+
+	// Forget drops the state of rules that are no longer loaded
+	dropped, err := wm.Forget(correlations[:1])
+	if err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if dropped != 1 {
+		t.Errorf("Forget() dropped %d groups, want 1", dropped)
+	}
+	if ids, err := db.WindowRules(); err != nil || len(ids) != 1 || ids[0] != "TEST-GC-COUNT" {
+		t.Errorf("WindowRules() = %v, %v; want [TEST-GC-COUNT]", ids, err)
+	}
+}
+
+func TestPruneBefore(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	for _, ev := range []struct {
+		rule, group string
+		age         time.Duration
+	}{
+		{"R1", "a", 3 * time.Hour},
+		{"R1", "a", time.Minute},
+		{"R1", "b", 2 * time.Hour},
+		{"R2", "a", 5 * time.Hour},
+	} {
+		event := map[string]any{"event_time": now.Add(-ev.age).Format(time.RFC3339Nano)}
+		if err := db.StoreWindowEvent(ev.rule, ev.group, event); err != nil {
+			t.Fatalf("Failed to store window event: %v", err)
+		}
+	}
+
+	wm := NewWindowManager(db, 100, time.Hour)
+	removed, err := wm.PruneBefore(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("PruneBefore failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("PruneBefore() removed %d events, want 3", removed)
+	}
+	if got := wm.Reclaimed(); got.Events != 3 || got.Windows != 2 {
+		t.Errorf("Reclaimed() = %+v, want 3 events and 2 windows", got)
+	}
+	if events, _ := db.GetWindowEvents("R1", "a"); len(events) != 1 {
+		t.Errorf("got %d events left in R1/a, want 1", len(events))
+	}
+}
+
 func TestProcessBoundsStoredEvents(t *testing.T) {
 	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
 	if err != nil {
@@ -448,7 +1110,7 @@ func TestProcessBoundsStoredEvents(t *testing.T) {
 		}
 	}
 
-	stored, err := db.GetWindowEvents("TEST-BOUNDS-001", "_global")
+	stored, err := db.GetWindowEvents("TEST-BOUNDS-001", hostKey("test-machine", "_global"))
 	if err != nil {
 		t.Fatalf("GetWindowEvents failed: %v", err)
 	}
@@ -541,6 +1203,51 @@ func TestProcessFilterExpression(t *testing.T) {
 	}
 }
 
+func TestProcessExceptions(t *testing.T) {
+	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	err = engine.LoadRules(&rules.RulesConfig{
+		Correlations: []*rules.CorrelationRule{{
+			ID:         "TEST-EXC-001",
+			Title:      "Denials",
+			Expr:       `kind == "execution" && event.execution.decision == DECISION_DENY`,
+			Window:     5 * time.Minute,
+			Threshold:  2,
+			Severity:   "high",
+			Enabled:    true,
+			Exceptions: []rules.Exception{{Expr: `event.execution.target.executable.path.startsWith("/opt/build/")`}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	wm := NewWindowManager(db, 100, time.Minute)
+	correlations := engine.GetCorrelations()
+
+	// Excepted events never count toward the threshold
+	for i, path := range []string{"/opt/build/a", "/opt/build/b", "/tmp/x"} {
+		matches, err := wm.Process(createTestMessageWithPath(path, "DECISION_DENY"), correlations)
+		if err != nil {
+			t.Fatalf("case %d: Process failed: %v", i, err)
+		}
+		if len(matches) != 0 {
+			t.Errorf("case %d: unexpected match", i)
+		}
+	}
+	if got := engine.Suppressions()["TEST-EXC-001"]; got != 2 {
+		t.Errorf("Expected 2 suppressions, got %d", got)
+	}
+}
+
 func TestProcessMultipleCorrelations(t *testing.T) {
 	db, err := state.Open(t.TempDir()+"/test.db", 1000, false)
 	if err != nil {
@@ -704,24 +1411,34 @@ func TestCountEvents(t *testing.T) {
 
 	tests := []struct {
 		name          string
-		countDistinct string
+		countDistinct rules.Fields
 		want          int
 	}{
 		{
 			name:          "count all",
-			countDistinct: "",
+			countDistinct: nil,
 			want:          4,
 		},
 		{
 			name:          "count distinct hashes",
-			countDistinct: "hash",
+			countDistinct: rules.Fields{"hash"},
 			want:          2, // hash1, hash2
 		},
 		{
 			name:          "count distinct users",
-			countDistinct: "user",
+			countDistinct: rules.Fields{"user"},
 			want:          2, // user1, user2
 		},
+		{
+			name:          "count distinct tuples",
+			countDistinct: rules.Fields{"hash", "user"},
+			want:          3, // hash1|user1, hash1|user2, hash2|user1
+		},
+		{
+			name:          "count distinct missing field",
+			countDistinct: rules.Fields{"path"},
+			want:          0,
+		},
 	}
 
 	for _, tt := range tests {
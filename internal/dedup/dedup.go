@@ -0,0 +1,115 @@
+package dedup
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// Deduper collapses repeated signals for the same rule and target. The first
+// signal is emitted; repeats within the cooldown are counted in the state DB
+// and reported by one summary signal once the cooldown ends.
+type Deduper struct {
+	db       state.Store
+	cooldown time.Duration
+}
+
+// New creates a Deduper with the given cooldown
+func New(db state.Store, cooldown time.Duration) *Deduper {
+	return &Deduper{db: db, cooldown: cooldown}
+}
+
+// Cooldown returns how long repeats are collapsed after the first signal
+func (d *Deduper) Cooldown() time.Duration {
+	return d.cooldown
+}
+
+// Key identifies a rule match by rule ID and target: the SHA-256 of the
+// target executable, else the target path. It is empty when the event has no
+// target, and such matches are never deduplicated.
+func Key(ruleID string, msg *santapb.SantaMessage) string {
+	target := events.TargetSHA256(msg)
+	if target == "" {
+		target = events.TargetPath(msg)
+	}
+	if target == "" {
+		return ""
+	}
+	return ruleID + "|" + target
+}
+
+// Check records sig under key at now and reports whether it should be
+// emitted. When sig opens a new window in place of one that had repeats, the
+// summary of the old window is returned too.
+func (d *Deduper) Check(key string, sig *state.Signal, now time.Time) (bool, *state.Signal, error) {
+	if key == "" {
+		return true, nil, nil
+	}
+	emit, expired, err := d.db.Dedup(key, sig, now, d.cooldown)
+	if err != nil {
+		return true, nil, fmt.Errorf("failed to record dedup entry: %w", err)
+	}
+	if expired != nil {
+		return emit, d.summarize(expired), nil
+	}
+	return emit, nil, nil
+}
+
+// Flush ends the windows whose cooldown has passed at now and returns the
+// summaries of those with repeats
+func (d *Deduper) Flush(now time.Time) ([]*state.Signal, error) {
+	expired, err := d.db.ExpireDedup(now.Add(-d.cooldown))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire dedup entries: %w", err)
+	}
+	var summaries []*state.Signal
+	for _, entry := range expired {
+		if sig := d.summarize(entry); sig != nil {
+			summaries = append(summaries, sig)
+		}
+	}
+	return summaries, nil
+}
+
+// summarize returns the summary of a window, taking the triage status of its
+// first signal: a closed signal gets no more updates, and the summary of an
+// acknowledged one is shipped acknowledged. A status that cannot be read
+// counts as open, so the summary is not lost.
+func (d *Deduper) summarize(entry *state.DedupEntry) *state.Signal {
+	sig := Summary(entry)
+	if sig == nil {
+		return nil
+	}
+	status, _ := d.db.SignalStatus(entry.Signal.ID)
+	switch status {
+	case state.StatusClosed:
+		return nil
+	case state.StatusAcknowledged:
+		sig.Status = state.StatusAcknowledged
+	}
+	return sig
+}
+
+// Summary returns a copy of the window's first signal that reports how often
+// it repeated, or nil when it did not repeat. The summary has its own ID so
+// it does not collide with the signal already shipped.
+func Summary(entry *state.DedupEntry) *state.Signal {
+	if entry.Signal == nil || entry.Count < 2 {
+		return nil
+	}
+	first := entry.Signal
+	sig := first.Derive(first.ID+"|dedup", entry.Last)
+	sig.Context = maps.Clone(first.Context)
+	if sig.Context == nil {
+		sig.Context = map[string]any{}
+	}
+	sig.Context["dedup_count"] = entry.Count
+	sig.Context["dedup_first_seen"] = entry.First.UTC().Format(time.RFC3339)
+	sig.Context["dedup_last_seen"] = entry.Last.UTC().Format(time.RFC3339)
+	sig.Context["dedup_signal_id"] = first.ID
+	return sig
+}
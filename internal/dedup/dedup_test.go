@@ -0,0 +1,165 @@
+package dedup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/state"
+	"google.golang.org/protobuf/proto"
+)
+
+func setupTestDB(t *testing.T) *state.DB {
+	t.Helper()
+	db, err := state.Open(filepath.Join(t.TempDir(), "test.db"), 1000, false)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestKey(t *testing.T) {
+	algo := santapb.Hash_HASH_ALGO_SHA256
+	exec := func(hash string) *santapb.SantaMessage {
+		exe := &santapb.FileInfo{Path: proto.String("/tmp/build/tool")}
+		if hash != "" {
+			exe.Hash = &santapb.Hash{Type: &algo, Hash: proto.String(hash)}
+		}
+		return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+			Target: &santapb.ProcessInfo{Executable: exe},
+		}}}
+	}
+
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want string
+	}{
+		{name: "hash", msg: exec("abc123"), want: "R1|abc123"},
+		{name: "path", msg: exec(""), want: "R1|/tmp/build/tool"},
+		{name: "no target", msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key("R1", tt.msg); got != tt.want {
+				t.Errorf("Key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAndFlush(t *testing.T) {
+	d := New(setupTestDB(t), time.Hour)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	first := &state.Signal{ID: "sig-1", RuleID: "R1", Title: "Unsigned binary", Context: map[string]any{"target_path": "/tmp/tool"}}
+
+	emit, summary, err := d.Check("R1|/tmp/tool", first, start)
+	if err != nil {
+		t.Fatalf("Failed to check signal: %v", err)
+	}
+	if !emit || summary != nil {
+		t.Fatalf("First signal: emit = %v, summary = %v", emit, summary)
+	}
+
+	for i := 1; i < 10000; i++ {
+		emit, _, err := d.Check("R1|/tmp/tool", &state.Signal{ID: "dup"}, start.Add(time.Duration(i)*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Failed to check signal: %v", err)
+		}
+		if emit {
+			t.Fatalf("Repeat %d was emitted", i)
+		}
+	}
+
+	// Other targets and targetless matches are not affected
+	if emit, _, _ := d.Check("R1|/tmp/other", &state.Signal{ID: "sig-2"}, start); !emit {
+		t.Error("Signal for another target was suppressed")
+	}
+	if emit, _, _ := d.Check("", &state.Signal{ID: "sig-3"}, start); !emit {
+		t.Error("Signal without a key was suppressed")
+	}
+
+	// Nothing is flushed before the cooldown ends
+	if summaries, err := d.Flush(start.Add(30 * time.Minute)); err != nil || len(summaries) != 0 {
+		t.Fatalf("Flush() before cooldown = %v, %v", summaries, err)
+	}
+
+	summaries, err := d.Flush(start.Add(time.Hour + time.Second))
+	if err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("Expected 1 summary (the other target did not repeat), got %d", len(summaries))
+	}
+	s := summaries[0]
+	if s.ID == first.ID || s.RuleID != "R1" || s.Title != first.Title {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	if s.Context["dedup_count"] != 10000 || s.Context["dedup_signal_id"] != "sig-1" || s.Context["target_path"] != "/tmp/tool" {
+		t.Errorf("Unexpected summary context: %v", s.Context)
+	}
+	if _, ok := first.Context["dedup_count"]; ok {
+		t.Error("Summary modified the original signal context")
+	}
+
+	// After the window ends the next match is a new signal
+	if emit, _, _ := d.Check("R1|/tmp/tool", &state.Signal{ID: "sig-4"}, start.Add(2*time.Hour)); !emit {
+		t.Error("Signal after the cooldown was suppressed")
+	}
+}
+
+func TestCheckReplacesEndedWindow(t *testing.T) {
+	d := New(setupTestDB(t), time.Minute)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := d.Check("R1|x", &state.Signal{ID: "sig-1"}, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("Failed to check signal: %v", err)
+		}
+	}
+
+	// A match after the cooldown, before any flush, returns the old summary
+	emit, summary, err := d.Check("R1|x", &state.Signal{ID: "sig-2"}, start.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to check signal: %v", err)
+	}
+	if !emit {
+		t.Error("Signal after the cooldown was suppressed")
+	}
+	if summary == nil || summary.Context["dedup_count"] != 3 {
+		t.Errorf("Expected summary with 3 occurrences, got %+v", summary)
+	}
+}
+
+func TestSummaryTriage(t *testing.T) {
+	db := setupTestDB(t)
+	d := New(db, time.Minute)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, id := range []string{"acked", "closed"} {
+		for i := 0; i < 2; i++ {
+			sig := &state.Signal{ID: id, RuleID: "R1", Status: state.StatusOpen}
+			if _, _, err := d.Check("R1|"+id, sig, start.Add(time.Duration(i)*time.Second)); err != nil {
+				t.Fatalf("Failed to check signal: %v", err)
+			}
+		}
+	}
+	for id, status := range map[string]string{"acked": state.StatusAcknowledged, "closed": state.StatusClosed} {
+		if _, err := db.SetTriage(id, "R1", state.TriageTransition{Status: status, TS: start}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summaries, err := d.Flush(start.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Context["dedup_signal_id"] != "acked" {
+		t.Fatalf("Expected only the acknowledged signal's summary, got %+v", summaries)
+	}
+	if summaries[0].Status != state.StatusAcknowledged {
+		t.Errorf("Summary status = %q, want acknowledged", summaries[0].Status)
+	}
+}
@@ -2,12 +2,13 @@ package events
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
 )
@@ -48,20 +49,10 @@ var EventTypes = []string{
 	"xprotect",
 }
 
-// ToMap converts a SantaMessage to a map suitable for CEL evaluation.
+// ToMap converts a SantaMessage to a map suitable for CEL evaluation. The map
+// has the shape of the message's protojson encoding (see messageToMap).
 func ToMap(msg *santapb.SantaMessage) (map[string]any, error) {
-	data, err := jsonMarshal.Marshal(msg)
-	if err != nil {
-		return nil, err
-	}
-
-	var result map[string]any
-	if err := json.Unmarshal(data, &result); err != nil {
-		return nil, err
-	}
-
-	decodeExecutionStringLists(result)
-	return result, nil
+	return messageToMap(msg.ProtoReflect())
 }
 
 // BuildActivation enriches the eventMap in-place with metadata fields needed for CEL evaluation.
@@ -81,6 +72,15 @@ func BuildActivation(msg *santapb.SantaMessage, eventMap map[string]any) {
 		eventMap["processed_time"] = pt.AsTime()
 	}
 
+	// Target entitlements by key; the protobuf only has a list of pairs
+	if ents := Entitlements(msg); ents != nil {
+		m := make(map[string]any, len(ents))
+		for key, value := range ents {
+			m[key] = value
+		}
+		eventMap["entitlements"] = m
+	}
+
 }
 
 // Kind returns the lower-case event type name for a Santa message.
@@ -296,6 +296,184 @@ func TargetSigningID(msg *santapb.SantaMessage) string {
 	return ""
 }
 
+// SigningKey returns the signing ID of a process as Santa rules and intel
+// feeds name it: "TEAMID:signing_id", or "platform:signing_id" for platform
+// binaries. Unsigned and ad hoc signed code, whose signing ID any binary can
+// claim, has none.
+func SigningKey(p *santapb.ProcessInfo) string {
+	cs := p.GetCodeSignature()
+	id := cs.GetSigningId()
+	switch {
+	case id == "":
+		return ""
+	case cs.GetTeamId() != "":
+		return cs.GetTeamId() + ":" + id
+	case p.GetIsPlatformBinary():
+		return "platform:" + id
+	}
+	return ""
+}
+
+// TargetSigningKey returns the SigningKey of an execution's target.
+func TargetSigningKey(msg *santapb.SantaMessage) string {
+	return SigningKey(msg.GetExecution().GetTarget())
+}
+
+// ActorSigningKey returns the SigningKey of a file access instigator.
+// NOTE: Only works for FileAccess events, like ActorSigningID.
+func ActorSigningKey(msg *santapb.SantaMessage) string {
+	return SigningKey(msg.GetFileAccess().GetInstigator())
+}
+
+// ActorUser returns the instigator's user for events that carry an instigator.
+// The real user is preferred since it identifies the person behind setuid
+// binaries; the effective user is used when no real user name is present.
+func ActorUser(msg *santapb.SantaMessage) *santapb.UserInfo {
+	m := msg.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("event"))
+	if fd == nil || fd.Message() == nil {
+		return nil
+	}
+	ev := m.Get(fd).Message()
+	instFd := ev.Descriptor().Fields().ByName("instigator")
+	if instFd == nil || instFd.Message() == nil || !ev.Has(instFd) {
+		return nil
+	}
+	inst := ev.Get(instFd).Message()
+	for _, name := range []protoreflect.Name{"real_user", "effective_user"} {
+		userFd := inst.Descriptor().Fields().ByName(name)
+		if userFd == nil || !inst.Has(userFd) {
+			continue
+		}
+		if user, ok := inst.Get(userFd).Message().Interface().(*santapb.UserInfo); ok && user.GetName() != "" {
+			return user
+		}
+	}
+	return nil
+}
+
+// EffectiveUser returns the user an event's process runs as: the target's
+// effective user for executions, so a setuid binary such as sudo reports
+// root, and the instigator's effective user otherwise
+func EffectiveUser(msg *santapb.SantaMessage) *santapb.UserInfo {
+	if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
+		return ev.Execution.GetTarget().GetEffectiveUser()
+	}
+	m := msg.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("event"))
+	if fd == nil || fd.Message() == nil {
+		return nil
+	}
+	ev := m.Get(fd).Message()
+	instFd := ev.Descriptor().Fields().ByName("instigator")
+	if instFd == nil || instFd.Message() == nil || !ev.Has(instFd) {
+		return nil
+	}
+	inst := ev.Get(instFd).Message()
+	userFd := inst.Descriptor().Fields().ByName("effective_user")
+	if userFd == nil || !inst.Has(userFd) {
+		return nil
+	}
+	user, _ := inst.Get(userFd).Message().Interface().(*santapb.UserInfo)
+	return user
+}
+
+// RemoteSource returns the source address of open_ssh and screen_sharing
+// events and its type (ipv4, ipv6 or named_socket), or "" for other events.
+func RemoteSource(msg *santapb.SantaMessage) (addr, addrType string) {
+	var src *santapb.SocketAddress
+	switch ev := msg.GetEvent().(type) {
+	case *santapb.SantaMessage_OpenSsh:
+		if login := ev.OpenSsh.GetLogin(); login != nil {
+			src = login.GetSource()
+		} else {
+			src = ev.OpenSsh.GetLogout().GetSource()
+		}
+	case *santapb.SantaMessage_ScreenSharing:
+		if attach := ev.ScreenSharing.GetAttach(); attach != nil {
+			src = attach.GetSource()
+		} else {
+			src = ev.ScreenSharing.GetDetach().GetSource()
+		}
+	}
+	if len(src.GetAddress()) == 0 {
+		return "", ""
+	}
+	return string(src.GetAddress()), strings.ToLower(strings.TrimPrefix(src.GetType().String(), "TYPE_"))
+}
+
+// AuthSuccess reports whether the login of an open_ssh or screen_sharing
+// event, or an authentication, succeeded. ok is false for events without an
+// outcome, such as logouts.
+func AuthSuccess(msg *santapb.SantaMessage) (success, ok bool) {
+	switch ev := msg.GetEvent().(type) {
+	case *santapb.SantaMessage_OpenSsh:
+		if login := ev.OpenSsh.GetLogin(); login != nil {
+			return login.GetResult() == santapb.OpenSSHLogin_RESULT_AUTH_SUCCESS, true
+		}
+	case *santapb.SantaMessage_ScreenSharing:
+		if attach := ev.ScreenSharing.GetAttach(); attach != nil {
+			return attach.GetSuccess(), true
+		}
+	case *santapb.SantaMessage_Authentication:
+		return ev.Authentication.GetSuccess(), true
+	}
+	return false, false
+}
+
+// SSHResult returns the outcome of an open_ssh login, e.g. auth_success or
+// invalid_user, or "" for other events.
+func SSHResult(msg *santapb.SantaMessage) string {
+	if login := msg.GetOpenSsh().GetLogin(); login != nil {
+		return strings.ToLower(strings.TrimPrefix(login.GetResult().String(), "RESULT_"))
+	}
+	return ""
+}
+
+// AuthUser returns the account an open_ssh, screen_sharing or authentication
+// event is for. Unlike ActorUser this is the user logging in, not the
+// process handling the login.
+func AuthUser(msg *santapb.SantaMessage) string {
+	switch ev := msg.GetEvent().(type) {
+	case *santapb.SantaMessage_OpenSsh:
+		if login := ev.OpenSsh.GetLogin(); login != nil {
+			return login.GetUser().GetName()
+		}
+		return ev.OpenSsh.GetLogout().GetUser().GetName()
+	case *santapb.SantaMessage_ScreenSharing:
+		attach := ev.ScreenSharing.GetAttach()
+		if name := attach.GetAuthenticationUser().GetName(); name != "" {
+			return name
+		}
+		return attach.GetSessionUser().GetName()
+	case *santapb.SantaMessage_Authentication:
+		switch {
+		case ev.Authentication.GetAuthenticationOd() != nil:
+			return ev.Authentication.GetAuthenticationOd().GetRecordName()
+		case ev.Authentication.GetAuthenticationTouchId() != nil:
+			return ev.Authentication.GetAuthenticationTouchId().GetUser().GetName()
+		case ev.Authentication.GetAuthenticationAutoUnlock() != nil:
+			return ev.Authentication.GetAuthenticationAutoUnlock().GetUserInfo().GetName()
+		}
+	}
+	return ""
+}
+
+// Entitlements returns the entitlements of an execution's target by key,
+// with JSON encoded values, or nil for other events and targets without any.
+// Santa may leave some out (see EntitlementInfo.entitlements_filtered).
+func Entitlements(msg *santapb.SantaMessage) map[string]string {
+	list := msg.GetExecution().GetEntitlementInfo().GetEntitlements()
+	if len(list) == 0 {
+		return nil
+	}
+	ents := make(map[string]string, len(list))
+	for _, e := range list {
+		ents[e.GetKey()] = e.GetValue()
+	}
+	return ents
+}
+
 // EventTime returns the event timestamp, or zero if missing.
 func EventTime(msg *santapb.SantaMessage) time.Time {
 	if ts := msg.GetEventTime(); ts != nil {
@@ -324,47 +502,6 @@ func DecodedArgs(msg *santapb.SantaMessage) []string {
 	return decoded
 }
 
-func decodeExecutionStringLists(m map[string]any) {
-	execRaw, ok := m["execution"].(map[string]any)
-	if !ok {
-		return
-	}
-
-	if decoded, ok := decodeBase64List(execRaw["args"]); ok {
-		execRaw["args"] = decoded
-	}
-	if decoded, ok := decodeBase64List(execRaw["envs"]); ok {
-		execRaw["envs"] = decoded
-	}
-}
-
-func decodeBase64List(raw any) ([]string, bool) {
-	values, ok := raw.([]any)
-	if !ok {
-		return nil, false
-	}
-
-	if len(values) == 0 {
-		return []string{}, true
-	}
-
-	decoded := make([]string, len(values))
-	for i, v := range values {
-		s, ok := v.(string)
-		if !ok {
-			decoded[i] = fmt.Sprint(v)
-			continue
-		}
-		data, err := base64.StdEncoding.DecodeString(s)
-		if err != nil {
-			decoded[i] = s
-			continue
-		}
-		decoded[i] = string(data)
-	}
-	return decoded, true
-}
-
 // ExtractField walks a dotted path within the event map and returns the value as string.
 func ExtractField(event map[string]any, field string) string {
 	parts := strings.Split(field, ".")
@@ -388,6 +525,73 @@ func ExtractField(event map[string]any, field string) string {
 	return toString(current)
 }
 
+// LookupField reads a dotted field path directly from the typed message and
+// formats it as ExtractField formats the same path of the ToMap map, without
+// the protojson round-trip. ok is false for paths it does not handle (unknown
+// fields, repeated and map fields, messages, well-known types and fields
+// only present in the activation map); callers fall back to ExtractField.
+func LookupField(msg *santapb.SantaMessage, field string) (value string, ok bool) {
+	if field == "kind" {
+		return Kind(msg), true
+	}
+
+	m := msg.ProtoReflect()
+	parts := strings.Split(field, ".")
+	for i, part := range parts {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(part))
+		if fd == nil || fd.IsList() || fd.IsMap() {
+			return "", false
+		}
+		if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			if i == len(parts)-1 || fd.Message().FullName().Parent() == "google.protobuf" {
+				return "", false
+			}
+			if !m.Has(fd) {
+				return "", true
+			}
+			m = m.Get(fd).Message()
+			continue
+		}
+		if i != len(parts)-1 {
+			return "", false
+		}
+		if fd.ContainingOneof() != nil && !m.Has(fd) {
+			return "", true
+		}
+		return scalarString(fd, m.Get(fd)), true
+	}
+	return "", false
+}
+
+// scalarString formats a scalar field value the way protojson and
+// ExtractField render it
+func scalarString(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return toString(float64(v.Enum()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return toString(float64(v.Int()))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return toString(float64(v.Uint()))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return toString(v.Float())
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	}
+	return v.String()
+}
+
 func toString(v any) string {
 	switch val := v.(type) {
 	case string:
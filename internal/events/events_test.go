@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
@@ -99,6 +100,9 @@ func TestBuildActivation(t *testing.T) {
 						Path: proto.String("/bin/sh"),
 					},
 				},
+				EntitlementInfo: &santapb.EntitlementInfo{Entitlements: []*santapb.Entitlement{
+					{Key: proto.String("com.apple.security.get-task-allow"), Value: proto.String("true")},
+				}},
 			},
 		},
 	}
@@ -120,6 +124,10 @@ func TestBuildActivation(t *testing.T) {
 		{"boot_session_uuid", func(v any) bool { return v == "boot-uuid" }},
 		{"kind", func(v any) bool { return v == "execution" }},
 		{"event_time", func(v any) bool { _, ok := v.(time.Time); return ok }},
+		{"entitlements", func(v any) bool {
+			m, ok := v.(map[string]any)
+			return ok && m["com.apple.security.get-task-allow"] == "true"
+		}},
 	}
 
 	for _, tt := range tests {
@@ -312,6 +320,142 @@ func TestDecision(t *testing.T) {
 	}
 }
 
+func TestActorUser(t *testing.T) {
+	user := func(uid int32, name string) *santapb.UserInfo {
+		return &santapb.UserInfo{Uid: proto.Int32(uid), Name: proto.String(name)}
+	}
+
+	tests := []struct {
+		name     string
+		msg      *santapb.SantaMessage
+		wantName string
+	}{
+		{
+			name: "execution real user",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+				Instigator: &santapb.ProcessInfoLight{RealUser: user(501, "alice"), EffectiveUser: user(0, "root")},
+			}}},
+			wantName: "alice",
+		},
+		{
+			name: "file access effective user fallback",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_FileAccess{FileAccess: &santapb.FileAccess{
+				Instigator: &santapb.ProcessInfo{EffectiveUser: user(502, "bob")},
+			}}},
+			wantName: "bob",
+		},
+		{
+			name: "no instigator",
+			msg:  &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}},
+		},
+		{
+			name: "no event",
+			msg:  &santapb.SantaMessage{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ActorUser(tt.msg)
+			if got.GetName() != tt.wantName {
+				t.Errorf("ActorUser() = %q, want %q", got.GetName(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestEffectiveUser(t *testing.T) {
+	user := func(uid int32, name string) *santapb.UserInfo {
+		return &santapb.UserInfo{Uid: proto.Int32(uid), Name: proto.String(name)}
+	}
+
+	sudo := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+		Instigator: &santapb.ProcessInfoLight{RealUser: user(501, "alice"), EffectiveUser: user(501, "alice")},
+		Target:     &santapb.ProcessInfo{RealUser: user(501, "alice"), EffectiveUser: user(0, "root")},
+	}}}
+	if got := EffectiveUser(sudo).GetName(); got != "root" {
+		t.Errorf("EffectiveUser(execution) = %q, want the target's root", got)
+	}
+
+	access := &santapb.SantaMessage{Event: &santapb.SantaMessage_FileAccess{FileAccess: &santapb.FileAccess{
+		Instigator: &santapb.ProcessInfo{RealUser: user(501, "alice"), EffectiveUser: user(502, "bob")},
+	}}}
+	if got := EffectiveUser(access).GetName(); got != "bob" {
+		t.Errorf("EffectiveUser(file_access) = %q, want bob", got)
+	}
+
+	if got := EffectiveUser(&santapb.SantaMessage{}); got != nil {
+		t.Errorf("EffectiveUser(no event) = %v, want nil", got)
+	}
+}
+
+func TestRemoteAccess(t *testing.T) {
+	alice := &santapb.UserInfo{Uid: proto.Int32(501), Name: proto.String("alice")}
+	source := &santapb.SocketAddress{Address: []byte("203.0.113.7"), Type: santapb.SocketAddress_TYPE_IPV4.Enum()}
+	failed := santapb.OpenSSHLogin_RESULT_AUTH_FAIL_PASSWD
+
+	tests := []struct {
+		name        string
+		msg         *santapb.SantaMessage
+		addr        string
+		success, ok bool
+		result      string
+		user        string
+	}{
+		{
+			name: "ssh login",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_OpenSsh{OpenSsh: &santapb.OpenSSH{
+				Event: &santapb.OpenSSH_Login{Login: &santapb.OpenSSHLogin{Result: &failed, Source: source, User: alice}},
+			}}},
+			addr: "203.0.113.7", ok: true, result: "auth_fail_passwd", user: "alice",
+		},
+		{
+			name: "ssh logout",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_OpenSsh{OpenSsh: &santapb.OpenSSH{
+				Event: &santapb.OpenSSH_Logout{Logout: &santapb.OpenSSHLogout{Source: source, User: alice}},
+			}}},
+			addr: "203.0.113.7", user: "alice",
+		},
+		{
+			name: "screen sharing attach",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_ScreenSharing{ScreenSharing: &santapb.ScreenSharing{
+				Event: &santapb.ScreenSharing_Attach{Attach: &santapb.ScreenSharingAttach{Success: proto.Bool(true), Source: source, SessionUser: alice}},
+			}}},
+			addr: "203.0.113.7", success: true, ok: true, user: "alice",
+		},
+		{
+			name: "touch id authentication",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_Authentication{Authentication: &santapb.Authentication{
+				Success: proto.Bool(true),
+				Event:   &santapb.Authentication_AuthenticationTouchId{AuthenticationTouchId: &santapb.AuthenticationTouchID{User: alice}},
+			}}},
+			success: true, ok: true, user: "alice",
+		},
+		{
+			name: "execution",
+			msg:  &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, addrType := RemoteSource(tt.msg)
+			if addr != tt.addr || (addr != "" && addrType != "ipv4") {
+				t.Errorf("RemoteSource() = %q, %q; want %q", addr, addrType, tt.addr)
+			}
+			if success, ok := AuthSuccess(tt.msg); success != tt.success || ok != tt.ok {
+				t.Errorf("AuthSuccess() = %v, %v; want %v, %v", success, ok, tt.success, tt.ok)
+			}
+			if got := SSHResult(tt.msg); got != tt.result {
+				t.Errorf("SSHResult() = %q, want %q", got, tt.result)
+			}
+			if got := AuthUser(tt.msg); got != tt.user {
+				t.Errorf("AuthUser() = %q, want %q", got, tt.user)
+			}
+		})
+	}
+}
+
 func TestExtractField(t *testing.T) {
 	event := map[string]any{
 		"execution": map[string]any{
@@ -375,6 +519,161 @@ func TestExtractField(t *testing.T) {
 	}
 }
 
+// populate sets every scalar field of m, and of its messages down to depth,
+// to a non-default value
+func populate(m protoreflect.Message, depth int) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsMap() {
+			continue
+		}
+		if fd.IsList() {
+			if fd.Kind() == protoreflect.BytesKind {
+				m.Mutable(fd).List().Append(protoreflect.ValueOfBytes([]byte("--flag")))
+			}
+			continue
+		}
+		switch fd.Kind() {
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			if depth > 0 && fd.Message().FullName().Parent() != "google.protobuf" {
+				populate(m.Mutable(fd).Message(), depth-1)
+			}
+		case protoreflect.StringKind:
+			m.Set(fd, protoreflect.ValueOfString(string(fd.Name())))
+		case protoreflect.BoolKind:
+			m.Set(fd, protoreflect.ValueOfBool(true))
+		case protoreflect.EnumKind:
+			m.Set(fd, protoreflect.ValueOfEnum(1))
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+			m.Set(fd, protoreflect.ValueOfInt32(-32))
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+			m.Set(fd, protoreflect.ValueOfUint32(32))
+		case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			m.Set(fd, protoreflect.ValueOfInt64(-1<<40))
+		case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			m.Set(fd, protoreflect.ValueOfUint64(1<<40))
+		case protoreflect.FloatKind:
+			m.Set(fd, protoreflect.ValueOfFloat32(1.5))
+		case protoreflect.DoubleKind:
+			m.Set(fd, protoreflect.ValueOfFloat64(0.25))
+		case protoreflect.BytesKind:
+			m.Set(fd, protoreflect.ValueOfBytes([]byte{0xde, 0xad}))
+		}
+	}
+}
+
+// fieldPaths lists the dotted paths of fields below m down to depth
+func fieldPaths(md protoreflect.MessageDescriptor, prefix string, depth int) []string {
+	var paths []string
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+		paths = append(paths, path)
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() && depth > 0 {
+			paths = append(paths, fieldPaths(fd.Message(), path+".", depth-1)...)
+		}
+	}
+	return paths
+}
+
+func TestLookupFieldMatchesExtractField(t *testing.T) {
+	full := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+	populate(full.ProtoReflect(), 4)
+	access := &santapb.SantaMessage{}
+	populate(access.ProtoReflect(), 4) // Sets the last oneof event
+	empty := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+
+	paths := append(fieldPaths(full.ProtoReflect().Descriptor(), "", 4), "kind", "no_such_field", "execution.target.nope")
+	for _, msg := range []*santapb.SantaMessage{full, access, empty} {
+		eventMap, err := ToMap(msg)
+		if err != nil {
+			t.Fatalf("ToMap() failed: %v", err)
+		}
+		BuildActivation(msg, eventMap)
+
+		typed := 0
+		for _, path := range paths {
+			got, ok := LookupField(msg, path)
+			if !ok {
+				continue
+			}
+			typed++
+			if want := ExtractField(eventMap, path); got != want {
+				t.Errorf("%s: LookupField(%q) = %q, ExtractField = %q", Kind(msg), path, got, want)
+			}
+		}
+		if typed < 100 {
+			t.Errorf("%s: only %d paths read from the typed message", Kind(msg), typed)
+		}
+	}
+}
+
+func TestLookupFieldFallback(t *testing.T) {
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+		Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String("/bin/sh")}},
+	}}}
+	for _, path := range []string{"execution.args", "execution.target", "event_time", "execution.target.executable.missing"} {
+		if _, ok := LookupField(msg, path); ok {
+			t.Errorf("LookupField(%q) should fall back to the event map", path)
+		}
+	}
+}
+
+// extraContextFields are typical extra_context paths, compared below between
+// the event map and typed lookups
+var extraContextFields = []string{
+	"execution.decision",
+	"execution.target.executable.path",
+	"execution.target.code_signature.team_id",
+}
+
+func benchmarkMessage() *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		MachineId:       proto.String("test-machine"),
+		BootSessionUuid: proto.String("boot-123"),
+		EventTime:       timestamppb.New(time.Now()),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Decision: santapb.Execution_DECISION_ALLOW.Enum(),
+				Args:     [][]byte{[]byte("/bin/sh"), []byte("-c"), []byte("id")},
+				Target: &santapb.ProcessInfo{
+					Executable:    &santapb.FileInfo{Path: proto.String("/bin/sh")},
+					CodeSignature: &santapb.CodeSignature{TeamId: proto.String("APPLE")},
+				},
+			},
+		},
+	}
+}
+
+func BenchmarkExtractFieldFromMap(b *testing.B) {
+	msg := benchmarkMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		eventMap, err := ToMap(msg)
+		if err != nil {
+			b.Fatalf("ToMap() failed: %v", err)
+		}
+		BuildActivation(msg, eventMap)
+		for _, field := range extraContextFields {
+			_ = ExtractField(eventMap, field)
+		}
+	}
+}
+
+func BenchmarkLookupField(b *testing.B) {
+	msg := benchmarkMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, field := range extraContextFields {
+			if _, ok := LookupField(msg, field); !ok {
+				b.Fatalf("LookupField(%q) fell back", field)
+			}
+		}
+	}
+}
+
 func BenchmarkToMap(b *testing.B) {
 	msg := &santapb.SantaMessage{
 		MachineId:       proto.String("test-machine"),
@@ -0,0 +1,182 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timestampName is the full name of google.protobuf.Timestamp
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// messageToMap converts m to the map encoding/json would decode from its
+// protojson encoding (proto names, unpopulated fields included), without the
+// round trip: 64-bit integers and bytes become strings, other numbers
+// float64, enums their names, timestamps RFC 3339 strings, and unset
+// messages nil. Execution args and envs are decoded to []string.
+func messageToMap(m protoreflect.Message) (map[string]any, error) {
+	fields := m.Descriptor().Fields()
+	result := make(map[string]any, fields.Len())
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			if fd.ContainingOneof() != nil {
+				continue
+			}
+			if fd.HasPresence() {
+				result[fd.TextName()] = nil
+				continue
+			}
+		}
+		v, err := fieldToValue(m.Get(fd), fd)
+		if err != nil {
+			return nil, err
+		}
+		result[fd.TextName()] = v
+	}
+	return result, nil
+}
+
+func fieldToValue(v protoreflect.Value, fd protoreflect.FieldDescriptor) (any, error) {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		if isExecutionStrings(fd) {
+			strs := make([]string, list.Len())
+			for i := range list.Len() {
+				strs[i] = string(list.Get(i).Bytes())
+			}
+			return strs, nil
+		}
+		values := make([]any, list.Len())
+		for i := range list.Len() {
+			item, err := singularToValue(list.Get(i), fd)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = item
+		}
+		return values, nil
+
+	case fd.IsMap():
+		entries := make(map[string]any, v.Map().Len())
+		var err error
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			var item any
+			if item, err = singularToValue(mv, fd.MapValue()); err == nil {
+				entries[k.String()] = item
+			}
+			return err == nil
+		})
+		return entries, err
+	}
+	return singularToValue(v, fd)
+}
+
+func singularToValue(v protoreflect.Value, fd protoreflect.FieldDescriptor) (any, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool(), nil
+	case protoreflect.StringKind:
+		if !utf8.ValidString(v.String()) {
+			return nil, fmt.Errorf("field %s contains invalid UTF-8", fd.FullName())
+		}
+		return v.String(), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return float64(v.Int()), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return float64(v.Uint()), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case protoreflect.FloatKind:
+		return floatToValue(v.Float(), 32), nil
+	case protoreflect.DoubleKind:
+		return floatToValue(v.Float(), 64), nil
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+	case protoreflect.EnumKind:
+		if desc := fd.Enum().Values().ByNumber(v.Enum()); desc != nil {
+			return string(desc.Name()), nil
+		}
+		return float64(v.Enum()), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		m := v.Message()
+		switch name := m.Descriptor().FullName(); {
+		case name == timestampName:
+			return timestampToValue(m)
+		case name.Parent() == "google.protobuf":
+			// Other well-known types have special JSON forms; none are used by
+			// Santa events, so take the slow path
+			return wellKnownToValue(m)
+		}
+		return messageToMap(m)
+	}
+	return nil, fmt.Errorf("field %s has unsupported kind %v", fd.FullName(), fd.Kind())
+}
+
+// isExecutionStrings reports whether fd is Execution.args or Execution.envs,
+// which hold strings as bytes
+func isExecutionStrings(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.BytesKind &&
+		(fd.Name() == "args" || fd.Name() == "envs") &&
+		fd.ContainingMessage().Name() == "Execution"
+}
+
+// floatToValue returns the number protojson writes for n, which is a string
+// for NaN and infinities, as decoded by encoding/json
+func floatToValue(n float64, bitSize int) any {
+	switch {
+	case math.IsNaN(n):
+		return "NaN"
+	case math.IsInf(n, 1):
+		return "Infinity"
+	case math.IsInf(n, -1):
+		return "-Infinity"
+	}
+	if bitSize == 32 {
+		// protojson writes the shortest float32 representation
+		n, _ = strconv.ParseFloat(strconv.FormatFloat(n, 'g', -1, 32), 64)
+	}
+	return n
+}
+
+// timestampToValue formats a Timestamp as protojson does: RFC 3339 in UTC
+// with 0, 3, 6 or 9 fractional digits
+func timestampToValue(m protoreflect.Message) (any, error) {
+	fields := m.Descriptor().Fields()
+	secs := m.Get(fields.ByName("seconds")).Int()
+	nanos := m.Get(fields.ByName("nanos")).Int()
+	if secs < -62135596800 || secs > 253402300799 {
+		return nil, fmt.Errorf("google.protobuf.Timestamp: seconds out of range %d", secs)
+	}
+	if nanos < 0 || nanos >= 1e9 {
+		return nil, fmt.Errorf("google.protobuf.Timestamp: nanos out of range %d", nanos)
+	}
+	s := time.Unix(secs, nanos).UTC().Format("2006-01-02T15:04:05.000000000")
+	s = strings.TrimSuffix(s, "000")
+	s = strings.TrimSuffix(s, "000")
+	s = strings.TrimSuffix(s, ".000")
+	return s + "Z", nil
+}
+
+func wellKnownToValue(m protoreflect.Message) (any, error) {
+	data, err := jsonMarshal.Marshal(m.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
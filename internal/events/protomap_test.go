@@ -0,0 +1,189 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toMapJSON is the protojson round trip ToMap replaced
+func toMapJSON(t *testing.T, msg *santapb.SantaMessage) map[string]any {
+	t.Helper()
+	data, err := jsonMarshal.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	if exec, ok := result["execution"].(map[string]any); ok {
+		for _, key := range []string{"args", "envs"} {
+			values := exec[key].([]any)
+			decoded := make([]string, len(values))
+			for i, v := range values {
+				data, _ := base64.StdEncoding.DecodeString(v.(string))
+				decoded[i] = string(data)
+			}
+			exec[key] = decoded
+		}
+	}
+	return result
+}
+
+// populateAll sets every field of m, including lists and maps, choosing the first field of nested oneofs,
+// with values that vary by field
+func populateAll(m protoreflect.Message, depth int, n *int) {
+	if m.Descriptor().FullName() == timestampName {
+		// Valid timestamps, with each precision protojson distinguishes
+		fields := m.Descriptor().Fields()
+		m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(1_700_000_000+int64(*n)))
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32([]int32{0, 500_000_000, 123_000, 7}[*n%4]))
+		*n++
+		return
+	}
+	fields := m.Descriptor().Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && oneof.Fields().Get(0) != fd {
+			continue
+		}
+		*n++
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for range 2 {
+				if fd.Message() != nil {
+					if depth > 0 {
+						elem := list.NewElement()
+						populateAll(elem.Message(), depth-1, n)
+						list.Append(elem)
+					}
+					continue
+				}
+				*n++
+				list.Append(scalarValue(fd, *n))
+			}
+		case fd.IsMap():
+			mm := m.Mutable(fd).Map()
+			key := scalarValue(fd.MapKey(), *n).MapKey()
+			if fd.MapValue().Message() != nil {
+				if depth > 0 {
+					val := mm.NewValue()
+					populateAll(val.Message(), depth-1, n)
+					mm.Set(key, val)
+				}
+			} else {
+				mm.Set(key, scalarValue(fd.MapValue(), *n))
+			}
+		case fd.Message() != nil:
+			if depth > 0 {
+				populateAll(m.Mutable(fd).Message(), depth-1, n)
+			}
+		default:
+			m.Set(fd, scalarValue(fd, *n))
+		}
+	}
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, n int) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(n%2 == 0)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(fmt.Sprintf("value-%d-ünïcode", n))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(-n * 1000))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(n * 7919))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(-int64(n) << 40)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(math.MaxUint64 - uint64(n))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(n) / 3)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(float64(n) / 7)
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte{byte(n), 0xff, 'a', 0})
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(n % values.Len()).Number())
+	}
+	panic(fmt.Sprintf("unexpected kind %v", fd.Kind()))
+}
+
+func TestToMapMatchesProtoJSON(t *testing.T) {
+	oneof := (&santapb.SantaMessage{}).ProtoReflect().Descriptor().Oneofs().ByName("event")
+	for i := range oneof.Fields().Len() {
+		fd := oneof.Fields().Get(i)
+		t.Run(string(fd.Name()), func(t *testing.T) {
+			// Unpopulated fields of an empty event, then every field set
+			for _, depth := range []int{-1, 4} {
+				msg := &santapb.SantaMessage{}
+				m := msg.ProtoReflect()
+				if depth < 0 {
+					m.Set(fd, protoreflect.ValueOfMessage(m.NewField(fd).Message()))
+				} else {
+					n := 0
+					populateAll(m, 0, &n)
+					if set := m.WhichOneof(oneof); set != nil {
+						m.Clear(set)
+					}
+					populateAll(m.Mutable(fd).Message(), depth, &n)
+				}
+
+				got, err := ToMap(msg)
+				if err != nil {
+					t.Fatalf("ToMap() failed: %v", err)
+				}
+				if want := toMapJSON(t, msg); !reflect.DeepEqual(got, want) {
+					gotJSON, _ := json.Marshal(got)
+					wantJSON, _ := json.Marshal(want)
+					t.Errorf("ToMap() differs from protojson (depth %d)\ngot:  %s\nwant: %s", depth, gotJSON, wantJSON)
+				}
+			}
+		})
+	}
+}
+
+func TestToMapSpecialValues(t *testing.T) {
+	tests := []struct {
+		name string
+		n    float64
+		bits int
+		want any
+	}{
+		{"NaN", math.NaN(), 64, "NaN"},
+		{"+Inf", math.Inf(1), 32, "Infinity"},
+		{"-Inf", math.Inf(-1), 64, "-Infinity"},
+		{"float32", float64(float32(0.1)), 32, 0.1},
+		{"double", 0.1, 64, 0.1},
+	}
+	for _, tt := range tests {
+		if got := floatToValue(tt.n, tt.bits); got != tt.want {
+			t.Errorf("floatToValue(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	msg := &santapb.SantaMessage{MachineId: new(string)}
+	*msg.MachineId = "bad\xffutf8"
+	if _, err := ToMap(msg); err == nil {
+		t.Error("Expected error for invalid UTF-8")
+	}
+
+	// Nanos must be below one second, as protojson requires
+	for _, nanos := range []int32{-1, 1e9} {
+		msg := &santapb.SantaMessage{EventTime: &timestamppb.Timestamp{Nanos: nanos}}
+		if _, err := ToMap(msg); err == nil {
+			t.Errorf("Expected error for nanos %d", nanos)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/fsutil"
+	"github.com/0x4d31/santamon/internal/httpapi"
+	"github.com/0x4d31/santamon/internal/logutil"
+)
+
+var logger = logutil.For("health")
+
+// Probe reports the health of a single component. A nil error means healthy.
+type Probe func() error
+
+// ComponentStatus is the reported state of one component
+type ComponentStatus struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Status is the aggregated agent health report
+type Status struct {
+	Status     string                     `json:"status"` // "ok" or "degraded"
+	AgentID    string                     `json:"agent_id"`
+	Version    string                     `json:"version"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Uptime     float64                    `json:"uptime_seconds"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Healthy reports whether every component is OK
+func (s *Status) Healthy() bool {
+	return s.Status == "ok"
+}
+
+// Options configures the health server
+type Options struct {
+	AgentID      string
+	Version      string
+	Listen       string        // host:port or absolute unix socket path (empty = no endpoint)
+	LivenessFile string        // File rewritten on every interval (empty = disabled)
+	Interval     time.Duration // How often to refresh the liveness file (default: 30s)
+}
+
+// Checker aggregates component probes and exposes them over HTTP and a liveness file
+type Checker struct {
+	opts      Options
+	startTime time.Time
+
+	mu     sync.RWMutex
+	probes map[string]Probe
+}
+
+// NewChecker creates a new health checker
+func NewChecker(opts Options) *Checker {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	return &Checker{
+		opts:      opts,
+		startTime: time.Now(),
+		probes:    make(map[string]Probe),
+	}
+}
+
+// Register adds (or replaces) a named component probe
+func (c *Checker) Register(name string, probe Probe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.probes[name] = probe
+}
+
+// Check runs all probes and returns the aggregated status
+func (c *Checker) Check() *Status {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.probes))
+	for name := range c.probes {
+		names = append(names, name)
+	}
+	probes := make(map[string]Probe, len(c.probes))
+	for k, v := range c.probes {
+		probes[k] = v
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	status := &Status{
+		Status:     "ok",
+		AgentID:    c.opts.AgentID,
+		Version:    c.opts.Version,
+		Timestamp:  time.Now().UTC(),
+		Uptime:     time.Since(c.startTime).Seconds(),
+		Components: make(map[string]ComponentStatus, len(names)),
+	}
+
+	for _, name := range names {
+		if err := probes[name](); err != nil {
+			status.Status = "degraded"
+			status.Components[name] = ComponentStatus{OK: false, Error: err.Error()}
+			continue
+		}
+		status.Components[name] = ComponentStatus{OK: true}
+	}
+
+	return status
+}
+
+// ServeHTTP implements http.Handler for the /healthz endpoint
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := c.Check()
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// Start serves the health endpoint and refreshes the liveness file until ctx is cancelled
+func (c *Checker) Start(ctx context.Context) error {
+	var srv *http.Server
+	if c.opts.Listen != "" {
+		ln, err := httpapi.Listen(c.opts.Listen, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to start health endpoint: %w", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", c)
+		srv = &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Warn("Health endpoint stopped: %v", err)
+			}
+		}()
+		logger.Verbose("Health endpoint listening on %s", c.opts.Listen)
+	}
+
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	c.touchLiveness()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if srv != nil {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				_ = srv.Shutdown(shutdownCtx)
+				cancel()
+			}
+			if httpapi.IsUnixSocket(c.opts.Listen) {
+				_ = os.Remove(c.opts.Listen)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+			c.touchLiveness()
+		}
+	}
+}
+
+// touchLiveness atomically rewrites the liveness file with the current status
+func (c *Checker) touchLiveness() {
+	if c.opts.LivenessFile == "" {
+		return
+	}
+	if err := WriteLivenessFile(c.opts.LivenessFile, c.Check()); err != nil {
+		logger.Warn("Failed to write liveness file: %v", err)
+	}
+}
+
+// WriteLivenessFile writes status to path via a temp file and rename so readers never see partial content
+func WriteLivenessFile(path string, status *Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create liveness directory: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write liveness file: %w", err)
+	}
+	return nil
+}
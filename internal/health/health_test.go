@@ -0,0 +1,189 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckAggregatesProbes(t *testing.T) {
+	c := NewChecker(Options{AgentID: "test-agent", Version: "dev"})
+	c.Register("db", func() error { return nil })
+	c.Register("shipper", func() error { return nil })
+
+	status := c.Check()
+	if !status.Healthy() {
+		t.Fatalf("Expected healthy status, got %s", status.Status)
+	}
+	if len(status.Components) != 2 {
+		t.Fatalf("Expected 2 components, got %d", len(status.Components))
+	}
+	if status.AgentID != "test-agent" {
+		t.Errorf("AgentID = %q, want test-agent", status.AgentID)
+	}
+
+	c.Register("shipper", func() error { return errors.New("circuit breaker open") })
+	status = c.Check()
+	if status.Healthy() {
+		t.Fatal("Expected degraded status when a probe fails")
+	}
+	comp := status.Components["shipper"]
+	if comp.OK || comp.Error != "circuit breaker open" {
+		t.Errorf("Unexpected shipper component status: %+v", comp)
+	}
+	if !status.Components["db"].OK {
+		t.Error("Expected db component to remain OK")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	c := NewChecker(Options{})
+	healthy := true
+	c.Register("watcher", func() error {
+		if healthy {
+			return nil
+		}
+		return errors.New("not running")
+	})
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var status Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.Status != "ok" {
+		t.Errorf("Status = %q, want ok", status.Status)
+	}
+
+	healthy = false
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405, got %d", rec.Code)
+	}
+}
+
+func TestWriteLivenessFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "health.json")
+	status := &Status{Status: "ok", AgentID: "a", Components: map[string]ComponentStatus{}}
+
+	if err := WriteLivenessFile(path, status); err != nil {
+		t.Fatalf("WriteLivenessFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read liveness file: %v", err)
+	}
+	var got Status
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Liveness file is not valid JSON: %v", err)
+	}
+	if got.AgentID != "a" {
+		t.Errorf("AgentID = %q, want a", got.AgentID)
+	}
+
+	// No temp files should be left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the liveness file, found %d entries", len(entries))
+	}
+}
+
+func TestStartTouchesLivenessFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "health.json")
+	c := NewChecker(Options{LivenessFile: path, Interval: 20 * time.Millisecond})
+	c.Register("db", func() error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	var first time.Time
+	for time.Now().Before(deadline) {
+		if info, err := os.Stat(path); err == nil {
+			if first.IsZero() {
+				first = info.ModTime()
+			} else if info.ModTime().After(first) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if first.IsZero() {
+		t.Fatal("Liveness file was never written")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Checker did not stop after cancellation")
+	}
+}
+
+func TestStartServesUnixSocket(t *testing.T) {
+	// Unix socket paths are length-limited, so avoid the long t.TempDir() path
+	dir, err := os.MkdirTemp("", "hc")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	sock := filepath.Join(dir, "h.sock")
+
+	c := NewChecker(Options{Listen: sock, Interval: time.Second})
+	c.Register("db", func() error { return nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Start(ctx) }()
+
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+
+	var resp *http.Response
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err = client.Get("http://santamon/healthz")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Failed to query health socket: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
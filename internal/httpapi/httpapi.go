@@ -0,0 +1,61 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrorResponse is the body of any non-2xx response of the agent's APIs
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Listen opens a unix socket with mode for absolute paths (see ListenUnix),
+// or a TCP listener for host:port
+func Listen(addr string, mode os.FileMode) (net.Listener, error) {
+	if IsUnixSocket(addr) {
+		return ListenUnix(addr, mode)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// ListenUnix opens a unix socket at path, replacing a stale one left behind
+// by an unclean shutdown, and restricts it to mode
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// IsUnixSocket reports whether a listen address is a unix socket path
+func IsUnixSocket(addr string) bool {
+	return strings.HasPrefix(addr, "/")
+}
+
+// WriteJSON writes v as a JSON response with status
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes an ErrorResponse with status
+func WriteError(w http.ResponseWriter, status int, msg string) {
+	WriteJSON(w, status, ErrorResponse{Error: msg})
+}
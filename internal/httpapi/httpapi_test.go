@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "agent.sock")
+	// A stale file from an unclean shutdown is replaced
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := Listen(path, 0660)
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	if ln.Addr().Network() != "unix" {
+		t.Errorf("Network = %s, want unix", ln.Addr().Network())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("Mode = %v, want 0660", info.Mode().Perm())
+	}
+	if IsUnixSocket("127.0.0.1:9110") {
+		t.Error("host:port treated as a unix socket")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, http.StatusNotFound, "signal x is not queued")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Response = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var e ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Error != "signal x is not queued" {
+		t.Errorf("Body = %s, %v", w.Body.String(), err)
+	}
+}
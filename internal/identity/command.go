@@ -0,0 +1,70 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// CommandProvider resolves users by running an external command with the
+// username and UID appended to its arguments. The command prints a JSON
+// identity ({"email": ..., "department": ...}) on stdout, or nothing if the
+// user is unknown. Results, including misses, are cached for the TTL.
+type CommandProvider struct {
+	argv    []string
+	timeout time.Duration
+	cache   *cache
+}
+
+// NewCommandProvider creates a provider running argv for each uncached user
+func NewCommandProvider(argv []string, timeout, ttl time.Duration) (*CommandProvider, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("identity command is empty")
+	}
+	return &CommandProvider{
+		argv:    argv,
+		timeout: timeout,
+		cache:   newCache(ttl),
+	}, nil
+}
+
+// Lookup returns the cached identity or runs the command
+func (p *CommandProvider) Lookup(ctx context.Context, user User) (*Identity, error) {
+	if id, ok := p.cache.get(user); ok {
+		return id, nil
+	}
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	args := append(append([]string{}, p.argv[1:]...), user.Name, strconv.Itoa(int(user.UID)))
+	cmd := exec.CommandContext(ctx, p.argv[0], args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Errors are not cached so a transient failure is retried on the next signal
+		return nil, fmt.Errorf("identity command failed: %w (%s)", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var id *Identity
+	if out = bytes.TrimSpace(out); len(out) > 0 {
+		id = &Identity{}
+		if err := json.Unmarshal(out, id); err != nil {
+			return nil, fmt.Errorf("failed to parse identity command output: %w", err)
+		}
+		if id.Email == "" {
+			id = nil
+		}
+	}
+
+	p.cache.put(user, id)
+	return id, nil
+}
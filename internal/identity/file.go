@@ -0,0 +1,92 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileProvider resolves users from a JSON identity cache maintained outside
+// the agent (e.g. by an MDM script syncing from Okta or AD). The file is
+// re-read whenever its modification time changes.
+//
+// Format:
+//
+//	{"users": [{"username": "alice", "uid": 501, "email": "alice@example.com", "department": "Engineering"}]}
+type FileProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	byName  map[string]*Identity
+	byUID   map[int32]*Identity
+}
+
+// fileEntry is one user in the identity cache file
+type fileEntry struct {
+	Username string `json:"username"`
+	UID      *int32 `json:"uid,omitempty"`
+	Identity
+}
+
+// NewFileProvider creates a provider backed by the JSON file at path
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// Lookup matches by username first, then by UID
+func (p *FileProvider) Lookup(_ context.Context, user User) (*Identity, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	if id, ok := p.byName[user.Name]; ok && user.Name != "" {
+		return id, nil
+	}
+	return p.byUID[user.UID], nil
+}
+
+// refresh reloads the file if it changed since the last load
+func (p *FileProvider) refresh() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat identity file: %w", err)
+	}
+	if p.byName != nil && info.ModTime().Equal(p.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read identity file: %w", err)
+	}
+	var doc struct {
+		Users []fileEntry `json:"users"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse identity file: %w", err)
+	}
+
+	byName := make(map[string]*Identity, len(doc.Users))
+	byUID := make(map[int32]*Identity, len(doc.Users))
+	for i := range doc.Users {
+		e := &doc.Users[i]
+		if e.Email == "" {
+			continue
+		}
+		if e.Username != "" {
+			byName[e.Username] = &e.Identity
+		}
+		if e.UID != nil {
+			byUID[*e.UID] = &e.Identity
+		}
+	}
+
+	p.byName, p.byUID, p.modTime = byName, byUID, info.ModTime()
+	return nil
+}
@@ -0,0 +1,59 @@
+package identity
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// User is a local macOS account as seen in Santa telemetry
+type User struct {
+	UID  int32
+	Name string
+}
+
+// Identity is the directory identity (Okta, AD, ...) behind a local account
+type Identity struct {
+	Email      string `json:"email"`
+	Department string `json:"department,omitempty"`
+}
+
+// Provider maps local users to directory identities. Lookup returns nil
+// without an error when the user is unknown to the directory.
+type Provider interface {
+	Lookup(ctx context.Context, user User) (*Identity, error)
+}
+
+// cache remembers lookups, including misses, for a fixed TTL
+type cache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[User]cacheEntry
+}
+
+type cacheEntry struct {
+	identity *Identity
+	expires  time.Time
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{ttl: ttl, now: time.Now, entries: make(map[User]cacheEntry)}
+}
+
+func (c *cache) get(user User) (*Identity, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[user]
+	if !ok || c.now().After(e.expires) {
+		return nil, false
+	}
+	return e.identity, true
+}
+
+func (c *cache) put(user User, id *Identity) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[user] = cacheEntry{identity: id, expires: c.now().Add(c.ttl)}
+}
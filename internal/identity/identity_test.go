@@ -0,0 +1,132 @@
+package identity
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identities.json")
+	writeFile(t, path, `{"users": [
+		{"username": "alice", "uid": 501, "email": "alice@example.com", "department": "Engineering"},
+		{"uid": 502, "email": "bob@example.com"},
+		{"username": "noemail"}
+	]}`, 0644)
+
+	p := NewFileProvider(path)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		user      User
+		wantEmail string
+	}{
+		{"by username", User{UID: 9999, Name: "alice"}, "alice@example.com"},
+		{"by uid", User{UID: 502, Name: "bob"}, "bob@example.com"},
+		{"unknown", User{UID: 503, Name: "carol"}, ""},
+		{"entry without email", User{Name: "noemail"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := p.Lookup(ctx, tt.user)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			got := ""
+			if id != nil {
+				got = id.Email
+			}
+			if got != tt.wantEmail {
+				t.Errorf("Lookup() email = %q, want %q", got, tt.wantEmail)
+			}
+		})
+	}
+
+	// The file is re-read once it changes
+	writeFile(t, path, `{"users": [{"username": "carol", "email": "carol@example.com", "department": "Finance"}]}`, 0644)
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Failed to touch identity file: %v", err)
+	}
+	id, err := p.Lookup(ctx, User{Name: "carol"})
+	if err != nil || id == nil || id.Department != "Finance" {
+		t.Errorf("Lookup() after change = %+v, %v", id, err)
+	}
+
+	if _, err := NewFileProvider(filepath.Join(t.TempDir(), "missing.json")).Lookup(ctx, User{Name: "alice"}); err == nil {
+		t.Error("Expected error for missing identity file")
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "lookup.sh")
+	writeFile(t, script, `#!/bin/sh
+echo "$2 $3" >> "`+calls+`"
+if [ "$2" = "alice" ]; then
+  echo '{"email": "alice@example.com", "department": "Engineering"}'
+fi
+`, 0755)
+
+	p, err := NewCommandProvider([]string{script, "--lookup"}, 5*time.Second, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		id, err := p.Lookup(ctx, User{UID: 501, Name: "alice"})
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if id == nil || id.Email != "alice@example.com" || id.Department != "Engineering" {
+			t.Errorf("Lookup() = %+v", id)
+		}
+	}
+
+	id, err := p.Lookup(ctx, User{UID: 502, Name: "bob"})
+	if err != nil || id != nil {
+		t.Errorf("Lookup(unknown) = %+v, %v; want nil, nil", id, err)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("Failed to read call log: %v", err)
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); len(got) != 2 || got[0] != "alice 501" {
+		t.Errorf("Command calls = %q, want one per user", got)
+	}
+}
+
+func TestCommandProviderErrors(t *testing.T) {
+	if _, err := NewCommandProvider(nil, time.Second, time.Hour); err == nil {
+		t.Error("Expected error for empty command")
+	}
+
+	dir := t.TempDir()
+	failing := filepath.Join(dir, "fail.sh")
+	writeFile(t, failing, "#!/bin/sh\necho 'directory unreachable' >&2\nexit 1\n", 0755)
+	p, _ := NewCommandProvider([]string{failing}, time.Second, time.Hour)
+	if _, err := p.Lookup(context.Background(), User{Name: "alice"}); err == nil || !strings.Contains(err.Error(), "directory unreachable") {
+		t.Errorf("Expected command failure with stderr, got %v", err)
+	}
+
+	garbage := filepath.Join(dir, "garbage.sh")
+	writeFile(t, garbage, "#!/bin/sh\necho 'not json'\n", 0755)
+	p, _ = NewCommandProvider([]string{garbage}, time.Second, time.Hour)
+	if _, err := p.Lookup(context.Background(), User{Name: "alice"}); err == nil {
+		t.Error("Expected parse error for non-JSON output")
+	}
+}
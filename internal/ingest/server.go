@@ -0,0 +1,144 @@
+package ingest
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/httpapi"
+	"github.com/0x4d31/santamon/internal/logutil"
+	"github.com/0x4d31/santamon/internal/spool"
+)
+
+var logger = logutil.For("ingest")
+
+// Options configures a Server
+type Options struct {
+	Listen   string // Absolute unix socket path, or host:port
+	Token    string // Bearer token clients must present (empty = none)
+	SpoolDir string // Spool accepted batches are written to
+	MaxBytes int64  // Largest accepted request body
+}
+
+// Result is the response of POST /v1/events
+type Result struct {
+	Events int `json:"events"`
+}
+
+// Server accepts batches of Santa events pushed by forwarders or remote
+// agents. A batch is anything the spool decoder reads: a LogBatch, optionally
+// zstd- or gzip-compressed, or protojson lines. Valid batches are written to
+// the spool directory, where the spool watcher picks them up like files Santa
+// exported itself; malformed ones are rejected so they never reach detection.
+type Server struct {
+	opts    Options
+	decoder *spool.Decoder
+	seq     atomic.Uint64 // Keeps names of batches accepted in the same instant apart
+}
+
+// NewServer creates an ingest server
+func NewServer(opts Options) *Server {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 16 << 20
+	}
+	return &Server{
+		opts:    opts,
+		decoder: spool.NewDecoder().WithLimits(opts.MaxBytes, 500<<20, 100),
+	}
+}
+
+// Handler returns the ingest API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/events", s.handleEvents)
+	return mux
+}
+
+// Start serves the ingest API until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Join(s.opts.SpoolDir, "new"), 0755); err != nil {
+		return fmt.Errorf("failed to create ingest spool: %w", err)
+	}
+	// Forwarders may run as another user in the socket's group
+	ln, err := httpapi.Listen(s.opts.Listen, 0660)
+	if err != nil {
+		return fmt.Errorf("failed to start ingest server: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       time.Minute,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Ingest server stopped: %v", err)
+		}
+	}()
+	logger.Info("Ingest server listening on %s", s.opts.Listen)
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = srv.Shutdown(shutdownCtx)
+	cancel()
+	if httpapi.IsUnixSocket(s.opts.Listen) {
+		_ = os.Remove(s.opts.Listen)
+	}
+	return ctx.Err()
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			httpapi.WriteError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+	}
+
+	// Stage the batch next to new/ so the watcher never sees a partial or invalid file
+	tmp, err := os.CreateTemp(s.opts.SpoolDir, ".ingest-*")
+	if err != nil {
+		httpapi.WriteError(w, http.StatusInternalServerError, "failed to stage batch")
+		logger.Error("Failed to stage ingest batch: %v", err)
+		return
+	}
+	staged := tmp.Name()
+	defer func() { _ = os.Remove(staged) }()
+
+	_, err = io.Copy(tmp, http.MaxBytesReader(w, r.Body, s.opts.MaxBytes))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			httpapi.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch exceeds %d bytes", s.opts.MaxBytes))
+			return
+		}
+		httpapi.WriteError(w, http.StatusBadRequest, "failed to read batch")
+		return
+	}
+
+	msgs, err := s.decoder.DecodeEventsContext(r.Context(), staged)
+	if err != nil {
+		httpapi.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := fmt.Sprintf("ingest-%d-%d", time.Now().UnixNano(), s.seq.Add(1))
+	if err := os.Rename(staged, filepath.Join(s.opts.SpoolDir, "new", name)); err != nil {
+		httpapi.WriteError(w, http.StatusInternalServerError, "failed to spool batch")
+		logger.Error("Failed to spool ingest batch: %v", err)
+		return
+	}
+	logger.Verbose("Accepted %d events from %s", len(msgs), r.RemoteAddr)
+	httpapi.WriteJSON(w, http.StatusOK, Result{Events: len(msgs)})
+}
@@ -0,0 +1,99 @@
+package ingest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// logBatch returns a marshalled LogBatch of n fork events
+func logBatch(t *testing.T, n int) []byte {
+	t.Helper()
+	batch := &santapb.LogBatch{}
+	for i := range n {
+		msg := &santapb.SantaMessage{
+			MachineId: proto.String("REMOTE-1"),
+			Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{
+				Child: &santapb.ProcessInfoLight{Id: &santapb.ProcessID{Pid: proto.Int32(int32(100 + i))}},
+			}},
+		}
+		record, err := anypb.New(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch.Records = append(batch.Records, record)
+	}
+	data, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHandleEvents(t *testing.T) {
+	spoolDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(spoolDir, "new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(Options{SpoolDir: spoolDir, Token: "secret", MaxBytes: 4096})
+	handler := s.Handler()
+
+	post := func(token string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/events", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	spooled := func() int {
+		entries, err := os.ReadDir(filepath.Join(spoolDir, "new"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	if rec := post("secret", logBatch(t, 3)); rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"events":3`)) {
+		t.Errorf("valid batch: %d %s", rec.Code, rec.Body)
+	}
+	if n := spooled(); n != 1 {
+		t.Errorf("spooled %d files, want 1", n)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		body  []byte
+		want  int
+	}{
+		{name: "missing token", body: logBatch(t, 1), want: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", body: logBatch(t, 1), want: http.StatusUnauthorized},
+		{name: "malformed", token: "secret", body: []byte("not a batch"), want: http.StatusBadRequest},
+		{name: "empty", token: "secret", want: http.StatusBadRequest},
+		{name: "too large", token: "secret", body: bytes.Repeat([]byte("x"), 8192), want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := post(tt.token, tt.body); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	// Rejected batches leave nothing behind, staged or spooled
+	if n := spooled(); n != 1 {
+		t.Errorf("spooled %d files after rejections, want 1", n)
+	}
+	if staged, _ := filepath.Glob(filepath.Join(spoolDir, ".ingest-*")); len(staged) != 0 {
+		t.Errorf("staged files left behind: %v", staged)
+	}
+}
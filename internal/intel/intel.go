@@ -0,0 +1,208 @@
+package intel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Indicator types
+const (
+	TypeSHA256    = "sha256"
+	TypeTeamID    = "team_id"
+	TypeSigningID = "signing_id"
+)
+
+var (
+	sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	teamIDPattern = regexp.MustCompile(`^[A-Z0-9]{10}$`)
+
+	// signingIDPattern matches Santa signing IDs: a team ID or "platform",
+	// a colon, and the code signing identifier
+	signingIDPattern = regexp.MustCompile(`^(?:[A-Z0-9]{10}|platform):[^\s:]+$`)
+
+	// stixSHA256Pattern extracts SHA-256 file hashes from STIX patterns
+	stixSHA256Pattern = regexp.MustCompile(`file:hashes\.(?:'SHA-256'|"SHA-256"|SHA256)\s*=\s*'([0-9a-fA-F]{64})'`)
+)
+
+// Classify returns the normalized form and type of an indicator, or false if
+// value is not a SHA-256 hash, team ID or signing ID
+func Classify(value string) (string, string, bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case sha256Pattern.MatchString(value):
+		return strings.ToLower(value), TypeSHA256, true
+	case teamIDPattern.MatchString(value):
+		return value, TypeTeamID, true
+	case signingIDPattern.MatchString(value):
+		return value, TypeSigningID, true
+	}
+	return "", "", false
+}
+
+// Parse extracts the indicators of a feed document and returns them
+// normalized, with the number of entries that were not recognized.
+//
+//   - csv: the first field of each row that is an indicator; header and
+//     comment (#) rows are skipped
+//   - json: an array, or an object with an "indicators" array, of strings or
+//     of objects with a "value" or "indicator" field
+//   - stix: SHA-256 file hashes in the patterns of a STIX 2.x bundle's
+//     indicators, skipping revoked and expired ones
+func Parse(data []byte, format string) ([]string, int, error) {
+	switch format {
+	case "csv":
+		return parseCSV(data)
+	case "json":
+		return parseJSON(data)
+	case "stix":
+		return parseSTIX(data, time.Now())
+	}
+	return nil, 0, fmt.Errorf("unknown feed format %q", format)
+}
+
+func parseCSV(data []byte) ([]string, int, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	var values []string
+	skipped := 0
+	for row := 0; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to parse CSV feed: %w", err)
+		}
+		found := false
+		for _, field := range record {
+			if v, _, ok := Classify(field); ok {
+				values = append(values, v)
+				found = true
+				break
+			}
+		}
+		if !found && row > 0 { // The first row may be a header
+			skipped++
+		}
+	}
+	return values, skipped, nil
+}
+
+func parseJSON(data []byte) ([]string, int, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse JSON feed: %w", err)
+	}
+	if obj, ok := doc.(map[string]any); ok {
+		doc = obj["indicators"]
+	}
+	entries, ok := doc.([]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("JSON feed must be an array or an object with an indicators array")
+	}
+
+	var values []string
+	skipped := 0
+	for _, entry := range entries {
+		var s string
+		switch e := entry.(type) {
+		case string:
+			s = e
+		case map[string]any:
+			s, _ = e["value"].(string)
+			if s == "" {
+				s, _ = e["indicator"].(string)
+			}
+		}
+		if v, _, ok := Classify(s); ok {
+			values = append(values, v)
+		} else {
+			skipped++
+		}
+	}
+	return values, skipped, nil
+}
+
+// stixBundle is the part of a STIX 2.x bundle that carries indicators
+type stixBundle struct {
+	Objects []struct {
+		Type       string    `json:"type"`
+		Pattern    string    `json:"pattern"`
+		Revoked    bool      `json:"revoked"`
+		ValidUntil time.Time `json:"valid_until"`
+	} `json:"objects"`
+}
+
+func parseSTIX(data []byte, now time.Time) ([]string, int, error) {
+	var bundle stixBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse STIX bundle: %w", err)
+	}
+
+	var values []string
+	skipped := 0
+	for _, obj := range bundle.Objects {
+		if obj.Type != "indicator" || obj.Revoked || (!obj.ValidUntil.IsZero() && obj.ValidUntil.Before(now)) {
+			continue
+		}
+		matches := stixSHA256Pattern.FindAllStringSubmatch(obj.Pattern, -1)
+		if len(matches) == 0 {
+			skipped++
+		}
+		for _, m := range matches {
+			values = append(values, strings.ToLower(m[1]))
+		}
+	}
+	return values, skipped, nil
+}
+
+// Set is an index from indicator to the names of the feeds listing it
+type Set struct {
+	feeds map[string][]string
+}
+
+// NewSet indexes the indicators of each named feed
+func NewSet(feeds map[string][]string) *Set {
+	s := &Set{feeds: make(map[string][]string)}
+	for _, name := range slices.Sorted(maps.Keys(feeds)) {
+		for _, v := range feeds[name] {
+			if list := s.feeds[v]; len(list) == 0 || list[len(list)-1] != name {
+				s.feeds[v] = append(list, name)
+			}
+		}
+	}
+	return s
+}
+
+// Match returns the sorted names of the feeds listing value. A nil set
+// matches nothing.
+func (s *Set) Match(value string) []string {
+	if s == nil || value == "" {
+		return nil
+	}
+	if names, ok := s.feeds[value]; ok {
+		return names
+	}
+	// Hashes are indexed in lower case
+	return s.feeds[strings.ToLower(strings.TrimSpace(value))]
+}
+
+// Len returns the number of distinct indicators
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.feeds)
+}
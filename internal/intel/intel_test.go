@@ -0,0 +1,145 @@
+package intel
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testHash   = "6f3c2a5b4e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b"
+	testTeamID = "EQHXZ8M8AV"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		value    string
+		want     string
+		wantType string
+		wantOK   bool
+	}{
+		{value: strings.ToUpper(testHash), want: testHash, wantType: TypeSHA256, wantOK: true},
+		{value: " " + testTeamID + " ", want: testTeamID, wantType: TypeTeamID, wantOK: true},
+		{value: "EQHXZ8M8AV:com.google.Chrome", want: "EQHXZ8M8AV:com.google.Chrome", wantType: TypeSigningID, wantOK: true},
+		{value: "platform:com.apple.curl", want: "platform:com.apple.curl", wantType: TypeSigningID, wantOK: true},
+		{value: "eqhxz8m8av"},
+		{value: "com.google.Chrome"},
+		{value: testHash[:40]},
+		{value: ""},
+	}
+	for _, tt := range tests {
+		got, typ, ok := Classify(tt.value)
+		if got != tt.want || typ != tt.wantType || ok != tt.wantOK {
+			t.Errorf("Classify(%q) = %q, %q, %v; want %q, %q, %v", tt.value, got, typ, ok, tt.want, tt.wantType, tt.wantOK)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		data        string
+		want        []string
+		wantSkipped int
+		wantErr     bool
+	}{
+		{
+			name:   "csv with header",
+			format: "csv",
+			data: "indicator,description\n" +
+				"# comment\n" +
+				strings.ToUpper(testHash) + ",stealer\n" +
+				"first seen,EQHXZ8M8AV\n" +
+				"example.com,domain\n",
+			want:        []string{testHash, testTeamID},
+			wantSkipped: 1,
+		},
+		{
+			name:        "json array",
+			format:      "json",
+			data:        `["` + testHash + `", {"value": "EQHXZ8M8AV:com.evil.app"}, {"indicator": "platform:com.apple.osascript"}, 42]`,
+			want:        []string{testHash, "EQHXZ8M8AV:com.evil.app", "platform:com.apple.osascript"},
+			wantSkipped: 1,
+		},
+		{
+			name:   "json object",
+			format: "json",
+			data:   `{"indicators": ["` + testTeamID + `"]}`,
+			want:   []string{testTeamID},
+		},
+		{
+			name:    "json without indicators",
+			format:  "json",
+			data:    `{"data": []}`,
+			wantErr: true,
+		},
+		{
+			name:   "stix",
+			format: "stix",
+			data: `{"type": "bundle", "objects": [
+				{"type": "indicator", "pattern": "[file:hashes.'SHA-256' = '` + testHash + `']"},
+				{"type": "indicator", "pattern": "[file:hashes.'SHA-256' = '` + strings.Repeat("a", 64) + `']", "revoked": true},
+				{"type": "indicator", "pattern": "[file:hashes.'SHA-256' = '` + strings.Repeat("b", 64) + `']", "valid_until": "2001-01-01T00:00:00Z"},
+				{"type": "indicator", "pattern": "[domain-name:value = 'example.com']"},
+				{"type": "malware", "name": "Stealer"}
+			]}`,
+			want:        []string{testHash},
+			wantSkipped: 1,
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped, err := Parse([]byte(tt.data), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+			if skipped != tt.wantSkipped {
+				t.Errorf("skipped = %d, want %d", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestParseSTIXValidUntil(t *testing.T) {
+	data := []byte(`{"objects": [{"type": "indicator", "pattern": "[file:hashes.'SHA-256' = '` + testHash + `']", "valid_until": "2030-01-01T00:00:00Z"}]}`)
+	if got, _, _ := parseSTIX(data, time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)); len(got) != 1 {
+		t.Errorf("Indicator valid until 2030 dropped in 2029: %v", got)
+	}
+	if got, _, _ := parseSTIX(data, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)); len(got) != 0 {
+		t.Errorf("Expired indicator kept: %v", got)
+	}
+}
+
+func TestSet(t *testing.T) {
+	set := NewSet(map[string][]string{
+		"b-feed": {testHash, testTeamID, testTeamID},
+		"a-feed": {testHash},
+	})
+	if got := set.Match(strings.ToUpper(testHash)); !slices.Equal(got, []string{"a-feed", "b-feed"}) {
+		t.Errorf("Match(hash) = %v, want [a-feed b-feed]", got)
+	}
+	if got := set.Match(testTeamID); !slices.Equal(got, []string{"b-feed"}) {
+		t.Errorf("Match(team) = %v, want [b-feed]", got)
+	}
+	if got := set.Match("ABCDEFGHIJ"); got != nil {
+		t.Errorf("Match(unknown) = %v, want nil", got)
+	}
+	if set.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", set.Len())
+	}
+
+	var empty *Set
+	if empty.Match(testTeamID) != nil || empty.Len() != 0 {
+		t.Error("Nil set should match nothing")
+	}
+}
@@ -0,0 +1,152 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/logutil"
+)
+
+var logger = logutil.For("intel")
+
+// maxFeedSize caps feed downloads so a bad URL can't exhaust memory
+const maxFeedSize = 64 << 20
+
+// Store holds the indicators of the configured feeds and reloads them in the
+// background. A feed that fails to reload keeps its last loaded indicators.
+type Store struct {
+	cfg    config.IntelConfig
+	client *http.Client
+	set    atomic.Pointer[Set]
+
+	mu      sync.Mutex
+	loaded  map[string][]string // Last good indicators of each feed
+	lastErr error
+}
+
+// NewStore creates a store for the configured feeds. Nothing is loaded until
+// Load or Run is called.
+func NewStore(cfg config.IntelConfig) *Store {
+	return &Store{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		loaded: make(map[string][]string),
+	}
+}
+
+// Load reads every feed and replaces the indicator set. It returns the
+// errors of the feeds that could not be read.
+func (s *Store) Load(ctx context.Context) error {
+	var errs []error
+	s.mu.Lock()
+	for _, feed := range s.cfg.Feeds {
+		values, skipped, err := s.read(ctx, feed)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("feed %s: %w", feed.Name, err))
+			continue
+		}
+		if skipped > 0 {
+			logger.Verbose("Feed %s: skipped %d entries that are not SHA-256 hashes, team IDs or signing IDs", feed.Name, skipped)
+		}
+		s.loaded[feed.Name] = values
+	}
+	set := NewSet(s.loaded)
+	s.lastErr = errors.Join(errs...)
+	s.mu.Unlock()
+
+	s.set.Store(set)
+	return errors.Join(errs...)
+}
+
+// Run reloads the feeds every interval until ctx is cancelled
+func (s *Store) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := s.Load(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("Intel feed reload failed: %v", err)
+		}
+		logger.Verbose("Intel feeds reloaded: %d indicators", s.Len())
+	}
+}
+
+// Match returns the sorted names of the feeds listing value. A nil store
+// matches nothing.
+func (s *Store) Match(value string) []string {
+	if s == nil {
+		return nil
+	}
+	return s.set.Load().Match(value)
+}
+
+// Len returns the number of distinct indicators loaded
+func (s *Store) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.set.Load().Len()
+}
+
+// Health reports the feeds that failed to load last time
+func (s *Store) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// read fetches and parses one feed
+func (s *Store) read(ctx context.Context, feed config.FeedConfig) ([]string, int, error) {
+	var data []byte
+	var err error
+	if feed.Path != "" {
+		data, err = os.ReadFile(feed.Path)
+	} else {
+		data, err = s.get(ctx, feed)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return Parse(data, feed.Format)
+}
+
+// get downloads a feed with a size cap
+func (s *Store) get(ctx context.Context, feed config.FeedConfig) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range feed.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFeedSize {
+		return nil, errors.New("response exceeds 64MB limit")
+	}
+	return data, nil
+}
@@ -0,0 +1,95 @@
+package intel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+func TestStoreLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "teams.csv")
+	if err := os.WriteFile(path, []byte(testTeamID+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write feed: %v", err)
+	}
+
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`["` + testHash + `"]`))
+	}))
+	defer server.Close()
+
+	store := NewStore(config.IntelConfig{
+		Interval: time.Hour,
+		Feeds: []config.FeedConfig{
+			{Name: "local", Path: path, Format: "csv"},
+			{Name: "remote", URL: server.URL, Format: "json", Headers: map[string]string{"Authorization": "Bearer token"}},
+		},
+	})
+	if store.Match(testHash) != nil {
+		t.Error("Store matched before loading")
+	}
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Failed to load feeds: %v", err)
+	}
+	if store.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", store.Len())
+	}
+	if got := store.Match(testHash); !slices.Equal(got, []string{"remote"}) {
+		t.Errorf("Match(hash) = %v, want [remote]", got)
+	}
+	if err := store.Health(); err != nil {
+		t.Errorf("Health() = %v, want nil", err)
+	}
+
+	// A failing feed keeps its last indicators
+	fail.Store(true)
+	err := store.Load(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "feed remote") {
+		t.Fatalf("Expected feed remote error, got %v", err)
+	}
+	if store.Match(testHash) == nil {
+		t.Error("Indicators of the failed feed were dropped")
+	}
+	if store.Health() == nil {
+		t.Error("Health() should report the failed feed")
+	}
+
+	fail.Store(false)
+	if err := store.Load(context.Background()); err != nil || store.Health() != nil {
+		t.Errorf("Load() after recovery = %v, Health() = %v", err, store.Health())
+	}
+}
+
+func TestStoreMissingFile(t *testing.T) {
+	store := NewStore(config.IntelConfig{Feeds: []config.FeedConfig{
+		{Name: "local", Path: filepath.Join(t.TempDir(), "missing.csv"), Format: "csv"},
+	}})
+	if err := store.Load(context.Background()); err == nil {
+		t.Error("Expected error for missing feed file")
+	}
+	if store.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", store.Len())
+	}
+
+	var empty *Store
+	if empty.Match(testTeamID) != nil || empty.Len() != 0 {
+		t.Error("Nil store should match nothing")
+	}
+}
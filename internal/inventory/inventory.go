@@ -0,0 +1,169 @@
+package inventory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/logutil"
+)
+
+var logger = logutil.For("inventory")
+
+// commandTimeout bounds each command run while collecting
+const commandTimeout = 10 * time.Second
+
+// Inventory describes the machine the agent runs on. Fields that could not
+// be collected are empty.
+type Inventory struct {
+	Hostname     string    `json:"hostname,omitempty"`
+	OSVersion    string    `json:"os_version,omitempty"` // e.g. 14.2.1
+	OSBuild      string    `json:"os_build,omitempty"`   // e.g. 23C71
+	Model        string    `json:"model,omitempty"`      // e.g. Mac14,2
+	SerialNumber string    `json:"serial_number,omitempty"`
+	HardwareUUID string    `json:"hardware_uuid,omitempty"`
+	SantaVersion string    `json:"santa_version,omitempty"`
+	SantaMode    string    `json:"santa_mode,omitempty"` // Client mode: monitor, lockdown or standalone
+	CollectedAt  time.Time `json:"collected_at"`
+}
+
+// runFunc runs a command and returns its stdout
+type runFunc func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// Collector collects the inventory and keeps the latest one
+type Collector struct {
+	run      runFunc
+	hostname func() (string, error)
+	current  atomic.Pointer[Inventory]
+}
+
+// NewCollector creates a collector. Nothing is collected until Collect or
+// Run is called.
+func NewCollector() *Collector {
+	return &Collector{run: runCommand, hostname: os.Hostname}
+}
+
+// Current returns the latest inventory, nil before the first collection or
+// for a nil collector
+func (c *Collector) Current() *Inventory {
+	if c == nil {
+		return nil
+	}
+	return c.current.Load()
+}
+
+// Collect gathers the inventory and makes it current. Sources that fail are
+// logged and left empty, so Collect always returns an inventory.
+func (c *Collector) Collect(ctx context.Context) *Inventory {
+	inv := &Inventory{CollectedAt: time.Now().UTC()}
+	if name, err := c.hostname(); err == nil {
+		inv.Hostname = name
+	}
+	inv.OSVersion = c.output(ctx, "sw_vers", "-productVersion")
+	inv.OSBuild = c.output(ctx, "sw_vers", "-buildVersion")
+	inv.Model = c.output(ctx, "sysctl", "-n", "hw.model")
+	if out := c.output(ctx, "ioreg", "-rd1", "-c", "IOPlatformExpertDevice"); out != "" {
+		props := parseIORegistry(out)
+		inv.SerialNumber = props["IOPlatformSerialNumber"]
+		inv.HardwareUUID = props["IOPlatformUUID"]
+	}
+	if out := c.output(ctx, "santactl", "version", "--json"); out != "" {
+		inv.SantaVersion = parseSantaVersion(out)
+	}
+	if out := c.output(ctx, "santactl", "status", "--json"); out != "" {
+		inv.SantaMode = parseSantaMode(out)
+	}
+
+	if prev := c.current.Swap(inv); prev != nil {
+		if prev.SantaMode != inv.SantaMode {
+			logger.Info("Santa client mode changed: %s -> %s", prev.SantaMode, inv.SantaMode)
+		}
+		if prev.OSVersion != inv.OSVersion {
+			logger.Info("macOS version changed: %s -> %s", prev.OSVersion, inv.OSVersion)
+		}
+	}
+	return inv
+}
+
+// Run collects the inventory every interval until ctx is done. The first
+// collection is up to the caller (see Collect).
+func (c *Collector) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.Collect(ctx)
+		}
+	}
+}
+
+// output runs a command and returns its trimmed output, "" if it failed
+func (c *Collector) output(ctx context.Context, name string, args ...string) string {
+	out, err := c.run(ctx, name, args...)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Verbose("Inventory command %s failed: %v", name, err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// parseIORegistry reads the string properties of ioreg output, lines such
+// as "IOPlatformUUID" = "1A2B..."
+func parseIORegistry(out string) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if !ok || !strings.HasPrefix(value, `"`) {
+			continue
+		}
+		props[strings.Trim(key, `"`)] = strings.Trim(value, `"`)
+	}
+	return props
+}
+
+// parseSantaVersion reads the santad version from santactl version --json
+func parseSantaVersion(out string) string {
+	var v struct {
+		Santad struct {
+			Version string `json:"version"`
+		} `json:"santad"`
+	}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		logger.Verbose("Failed to parse santactl version output: %v", err)
+		return ""
+	}
+	return v.Santad.Version
+}
+
+// parseSantaMode reads the client mode from santactl status --json,
+// lowercased
+func parseSantaMode(out string) string {
+	var s struct {
+		Daemon struct {
+			Mode string `json:"mode"`
+		} `json:"daemon"`
+	}
+	if err := json.Unmarshal([]byte(out), &s); err != nil {
+		logger.Verbose("Failed to parse santactl status output: %v", err)
+		return ""
+	}
+	return strings.ToLower(s.Daemon.Mode)
+}
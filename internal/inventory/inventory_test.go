@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const ioregOutput = `+-o J314sAP  <class IOPlatformExpertDevice, id 0x100000227, registered, matched, active, busy 0 (74 ms), retain 36>
+    {
+      "IOPlatformSerialNumber" = "C02XL0GHJGH5"
+      "compatible" = <"J314sAP","MacBookPro18,3","AppleARM">
+      "IOPlatformUUID" = "5D3F1C2A-8B7E-4F10-9A2B-3C4D5E6F7081"
+      "IOBusyInterest" = "IOCommand is not serializable"
+    }
+`
+
+func fakeRun(outputs map[string]string) runFunc {
+	return func(_ context.Context, name string, args ...string) ([]byte, error) {
+		out, ok := outputs[strings.Join(append([]string{name}, args...), " ")]
+		if !ok {
+			return nil, errors.New("exit status 1")
+		}
+		return []byte(out), nil
+	}
+}
+
+func TestCollect(t *testing.T) {
+	c := &Collector{
+		run: fakeRun(map[string]string{
+			"sw_vers -productVersion":              "14.2.1\n",
+			"sw_vers -buildVersion":                "23C71\n",
+			"sysctl -n hw.model":                   "MacBookPro18,3\n",
+			"ioreg -rd1 -c IOPlatformExpertDevice": ioregOutput,
+			"santactl version --json":              `{"santad":{"version":"2025.1 (build 123)"},"santactl":{"version":"2025.1"}}`,
+			"santactl status --json":               `{"daemon":{"mode":"Lockdown","file_logging":true}}`,
+		}),
+		hostname: func() (string, error) { return "mbp-alice", nil },
+	}
+	if c.Current() != nil {
+		t.Fatal("Current() should be nil before the first collection")
+	}
+
+	inv := c.Collect(context.Background())
+	want := Inventory{
+		Hostname:     "mbp-alice",
+		OSVersion:    "14.2.1",
+		OSBuild:      "23C71",
+		Model:        "MacBookPro18,3",
+		SerialNumber: "C02XL0GHJGH5",
+		HardwareUUID: "5D3F1C2A-8B7E-4F10-9A2B-3C4D5E6F7081",
+		SantaVersion: "2025.1 (build 123)",
+		SantaMode:    "lockdown",
+		CollectedAt:  inv.CollectedAt,
+	}
+	if *inv != want {
+		t.Errorf("Collect() = %+v, want %+v", *inv, want)
+	}
+	if c.Current() != inv {
+		t.Error("Collect() should make the inventory current")
+	}
+}
+
+func TestCollectFailures(t *testing.T) {
+	c := &Collector{
+		run: fakeRun(map[string]string{
+			"sw_vers -productVersion": "15.0\n",
+			"santactl status --json":  "not json",
+		}),
+		hostname: func() (string, error) { return "", errors.New("no hostname") },
+	}
+
+	inv := c.Collect(context.Background())
+	if inv.OSVersion != "15.0" {
+		t.Errorf("OSVersion = %q, want 15.0", inv.OSVersion)
+	}
+	if inv.Hostname != "" || inv.HardwareUUID != "" || inv.SantaMode != "" || inv.SantaVersion != "" {
+		t.Errorf("Failed sources should be left empty, got %+v", *inv)
+	}
+	if inv.CollectedAt.IsZero() {
+		t.Error("CollectedAt should be set")
+	}
+
+	var nilCollector *Collector
+	if nilCollector.Current() != nil {
+		t.Error("A nil collector should have no inventory")
+	}
+}
@@ -0,0 +1,108 @@
+package lineage
+
+import "slices"
+
+// addLocked records n, replacing any node with the same key, and indexes it
+// under its parent and by age
+func (s *Store) addLocked(n *Node) {
+	if s.nodes == nil {
+		s.nodes = make(map[Key]*Node, s.maxEntries)
+	}
+	if s.children == nil {
+		s.children = make(map[Key][]Key)
+	}
+	if old, ok := s.nodes[n.Key]; ok {
+		s.unindexLocked(old)
+	}
+	s.nodes[n.Key] = n
+	s.pushAgeLocked(n)
+	if !n.Parent.IsZero() {
+		s.children[n.Parent] = append(s.children[n.Parent], n.Key)
+	}
+}
+
+// removeLocked forgets the node of k. Its children stay recorded but are no
+// longer reachable from it.
+func (s *Store) removeLocked(k Key) {
+	n, ok := s.nodes[k]
+	if !ok {
+		return
+	}
+	s.unindexLocked(n)
+	delete(s.nodes, k)
+	delete(s.children, k)
+}
+
+// unindexLocked removes n from its parent's children
+func (s *Store) unindexLocked(n *Node) {
+	siblings := s.children[n.Parent]
+	if i := slices.Index(siblings, n.Key); i >= 0 {
+		siblings = slices.Delete(siblings, i, i+1)
+	}
+	if len(siblings) == 0 {
+		delete(s.children, n.Parent)
+	} else {
+		s.children[n.Parent] = siblings
+	}
+}
+
+// Descendants returns the recorded processes spawned by key and by its
+// descendants, up to maxDepth generations below it and at most limit nodes,
+// children before grandchildren.
+func (s *Store) Descendants(key Key, maxDepth, limit int) []*Node {
+	if maxDepth <= 0 {
+		maxDepth = 8
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []*Node
+	level := []Key{key}
+	seen := map[Key]struct{}{key: {}}
+	for depth := 0; depth < maxDepth && len(level) > 0; depth++ {
+		var next []Key
+		for _, parent := range level {
+			for _, child := range s.children[parent] {
+				n := s.nodes[child]
+				if n == nil {
+					continue
+				}
+				if _, exists := seen[child]; exists {
+					continue
+				}
+				seen[child] = struct{}{}
+				out = append(out, n)
+				if limit > 0 && len(out) >= limit {
+					return out
+				}
+				next = append(next, child)
+			}
+		}
+		level = next
+	}
+	return out
+}
+
+// SerializeDescendants converts the descendants of root (see Descendants)
+// into a JSON-friendly structure. depth counts generations below root.
+func SerializeDescendants(root Key, nodes []*Node) []map[string]any {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	depths := map[Key]int{root: 0}
+	out := make([]map[string]any, len(nodes))
+	for i, n := range nodes {
+		depth := depths[n.Parent] + 1
+		depths[n.Key] = depth
+		relation := "child"
+		if depth > 1 {
+			relation = "descendant"
+		}
+		m := serializeNode(n, relation, depth)
+		m["parent_pid"] = n.Parent.Pid
+		out[i] = m
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package lineage
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+// ageEntry records when the node of key was created
+type ageEntry struct {
+	key     Key
+	created time.Time
+}
+
+// ageHeap is a min-heap of recorded nodes by creation time, so the oldest
+// node is found in O(log n). Entries of nodes since replaced or removed are
+// left in place and dropped when they reach the top.
+type ageHeap []ageEntry
+
+func (h ageHeap) Len() int           { return len(h) }
+func (h ageHeap) Less(i, j int) bool { return h[i].created.Before(h[j].created) }
+func (h ageHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *ageHeap) Push(x any)        { *h = append(*h, x.(ageEntry)) }
+func (h *ageHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// maxStaleAges is how many more heap entries than nodes are tolerated before
+// the heap is rebuilt, bounding the entries left by replaced nodes
+const maxStaleAges = 1024
+
+// pushAgeLocked adds n to the age heap
+func (s *Store) pushAgeLocked(n *Node) {
+	heap.Push(&s.ages, ageEntry{key: n.Key, created: n.CreatedAt})
+	if len(s.ages) > 2*len(s.nodes)+maxStaleAges {
+		s.ages = s.ages[:0]
+		for _, n := range s.nodes {
+			s.ages = append(s.ages, ageEntry{key: n.Key, created: n.CreatedAt})
+		}
+		heap.Init(&s.ages)
+	}
+}
+
+// oldestLocked returns the oldest recorded node, dropping stale entries on
+// the way, or nil when the store is empty
+func (s *Store) oldestLocked() *Node {
+	for len(s.ages) > 0 {
+		e := s.ages[0]
+		if n := s.nodes[e.key]; n != nil && n.CreatedAt.Equal(e.created) {
+			return n
+		}
+		heap.Pop(&s.ages)
+	}
+	return nil
+}
+
+// sweepBatch is how many expired nodes a sweep removes per lock hold
+const sweepBatch = 512
+
+// Sweep removes up to limit nodes created before the TTL, oldest first, and
+// returns how many were removed
+func (s *Store) Sweep(now time.Time, limit int) int {
+	if s == nil || s.ttl <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for n := s.oldestLocked(); n != nil && removed < limit && n.CreatedAt.Before(cutoff); n = s.oldestLocked() {
+		heap.Pop(&s.ages)
+		s.removeLocked(n.Key)
+		removed++
+	}
+	return removed
+}
+
+// RunSweeper removes expired nodes every interval until ctx is cancelled.
+// Expiry is kept off UpsertFromExecution so bursts of executions only pay
+// for the MaxEntries cap; each sweep releases the lock between batches so
+// executions recorded meanwhile aren't held up.
+func (s *Store) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		for s.Sweep(now, sweepBatch) == sweepBatch {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// evictOldestLocked removes the oldest node
+func (s *Store) evictOldestLocked() {
+	if n := s.oldestLocked(); n != nil {
+		heap.Pop(&s.ages)
+		s.removeLocked(n.Key)
+	}
+}
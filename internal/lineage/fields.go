@@ -0,0 +1,115 @@
+package lineage
+
+import (
+	"fmt"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+)
+
+// rootDepth bounds the ancestor walk when looking for a chain's root
+const rootDepth = 64
+
+// Fields are the values Values returns, usable in correlation rules as
+// lineage.<name>
+var Fields = map[string]bool{
+	"root":             true, // pid:pidversion of the oldest known ancestor
+	"root_path":        true,
+	"parent_path":      true,
+	"responsible":      true, // pid:pidversion of the responsible process
+	"responsible_path": true,
+}
+
+// SubjectKey returns the process an event is about: the target of an
+// execution, else the instigator of the event, if it has one
+func SubjectKey(msg *santapb.SantaMessage) Key {
+	if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
+		return FromProcessID(msg.GetBootSessionUuid(), ev.Execution.GetTarget().GetId())
+	}
+	m := msg.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("event"))
+	if fd == nil || fd.Message() == nil {
+		return Key{}
+	}
+	event := m.Get(fd).Message()
+	inst := event.Descriptor().Fields().ByName("instigator")
+	if inst == nil || inst.Message() == nil || !event.Has(inst) {
+		return Key{}
+	}
+	idField := inst.Message().Fields().ByName("id")
+	if idField == nil || idField.Message() == nil {
+		return Key{}
+	}
+	id, _ := event.Get(inst).Message().Get(idField).Message().Interface().(*santapb.ProcessID)
+	return FromProcessID(msg.GetBootSessionUuid(), id)
+}
+
+// Values returns the lineage fields of an event's subject process, or nil
+// when the process has not been seen executing. The root is the oldest
+// ancestor still in the store, so processes started before the agent or
+// evicted after the TTL end the chain early.
+func (s *Store) Values(msg *santapb.SantaMessage) map[string]any {
+	key := SubjectKey(msg)
+	if key.IsZero() {
+		return nil
+	}
+	chain := s.Lineage(key, rootDepth)
+	if len(chain) == 0 {
+		return nil
+	}
+
+	leaf, root := chain[0], chain[len(chain)-1]
+	values := map[string]any{
+		"root":      processRef(root.Key),
+		"root_path": root.Path,
+	}
+	if len(chain) > 1 {
+		values["parent_path"] = chain[1].Path
+	}
+	if !leaf.Responsible.IsZero() {
+		values["responsible"] = processRef(leaf.Responsible)
+		s.mu.RLock()
+		if n := s.nodes[leaf.Responsible]; n != nil {
+			values["responsible_path"] = n.Path
+		}
+		s.mu.RUnlock()
+	}
+	return values
+}
+
+func processRef(k Key) string {
+	return fmt.Sprintf("%d:%d", k.Pid, k.PidVersion)
+}
+
+// AncestorPaths returns the executable paths of the ancestors of an event's
+// subject process, parent first. Executions start at the target's parent, so
+// the target need not be recorded yet; like Values, the chain ends at the
+// oldest ancestor still in the store. A nil store has no ancestors.
+func (s *Store) AncestorPaths(msg *santapb.SantaMessage) []string {
+	if s == nil {
+		return nil
+	}
+	var chain []*Node
+	if ev, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); ok {
+		parent := FromProcessID(msg.GetBootSessionUuid(), parentID(ev.Execution))
+		if parent.IsZero() {
+			return nil
+		}
+		chain = s.Lineage(parent, rootDepth)
+	} else {
+		key := SubjectKey(msg)
+		if key.IsZero() {
+			return nil
+		}
+		if chain = s.Lineage(key, rootDepth+1); len(chain) > 0 {
+			chain = chain[1:]
+		}
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	paths := make([]string, len(chain))
+	for i, n := range chain {
+		paths[i] = n.Path
+	}
+	return paths
+}
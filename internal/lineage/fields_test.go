@@ -0,0 +1,130 @@
+package lineage
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+)
+
+func execMessage(pid, parent, responsible int32, path string) *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		BootSessionUuid: proto.String("boot"),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{
+					Id:            &santapb.ProcessID{Pid: proto.Int32(pid), Pidversion: proto.Int32(pid * 10)},
+					ParentId:      &santapb.ProcessID{Pid: proto.Int32(parent), Pidversion: proto.Int32(parent * 10)},
+					ResponsibleId: &santapb.ProcessID{Pid: proto.Int32(responsible), Pidversion: proto.Int32(responsible * 10)},
+					Executable:    &santapb.FileInfo{Path: proto.String(path)},
+				},
+			},
+		},
+	}
+}
+
+func TestSubjectKey(t *testing.T) {
+	fileAccess := &santapb.SantaMessage{
+		BootSessionUuid: proto.String("boot"),
+		Event: &santapb.SantaMessage_FileAccess{
+			FileAccess: &santapb.FileAccess{
+				Instigator: &santapb.ProcessInfo{
+					Id: &santapb.ProcessID{Pid: proto.Int32(7), Pidversion: proto.Int32(70)},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want Key
+	}{
+		{"execution target", execMessage(3, 2, 1, "/bin/zsh"), Key{BootUUID: "boot", Pid: 3, PidVersion: 30}},
+		{"instigator", fileAccess, Key{BootUUID: "boot", Pid: 7, PidVersion: 70}},
+		{"no instigator", &santapb.SantaMessage{Event: &santapb.SantaMessage_FileAccess{FileAccess: &santapb.FileAccess{}}}, Key{}},
+		{"no event", &santapb.SantaMessage{}, Key{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SubjectKey(tt.msg); got != tt.want {
+				t.Errorf("SubjectKey() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues(t *testing.T) {
+	store := NewStore(Config{})
+	// Terminal -> zsh -> curl, all attributed to Terminal
+	for _, msg := range []*santapb.SantaMessage{
+		execMessage(1, 0, 1, "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal"),
+		execMessage(2, 1, 1, "/bin/zsh"),
+		execMessage(3, 2, 1, "/usr/bin/curl"),
+	} {
+		store.UpsertFromExecution(msg, msg.GetExecution())
+	}
+
+	got := store.Values(execMessage(3, 2, 1, "/usr/bin/curl"))
+	want := map[string]any{
+		"root":             "1:10",
+		"root_path":        "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal",
+		"parent_path":      "/bin/zsh",
+		"responsible":      "1:10",
+		"responsible_path": "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Values() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Values()[%s] = %v, want %v", k, got[k], v)
+		}
+		if !Fields[k] {
+			t.Errorf("%s is missing from Fields", k)
+		}
+	}
+
+	if got := store.Values(execMessage(9, 8, 8, "/bin/unknown")); got != nil {
+		t.Errorf("Values() of an unseen process = %v, want nil", got)
+	}
+}
+
+func TestAncestorPaths(t *testing.T) {
+	store := NewStore(Config{})
+	for _, msg := range []*santapb.SantaMessage{
+		execMessage(1, 0, 1, "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal"),
+		execMessage(2, 1, 1, "/bin/zsh"),
+	} {
+		store.UpsertFromExecution(msg, msg.GetExecution())
+	}
+
+	// The executed process itself need not be recorded yet
+	got := store.AncestorPaths(execMessage(3, 2, 1, "/usr/bin/osascript"))
+	want := []string{"/bin/zsh", "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("AncestorPaths() = %v, want %v", got, want)
+	}
+
+	// Other events start at the instigator's parent
+	fileAccess := &santapb.SantaMessage{
+		BootSessionUuid: proto.String("boot"),
+		Event: &santapb.SantaMessage_FileAccess{
+			FileAccess: &santapb.FileAccess{
+				Instigator: &santapb.ProcessInfo{
+					Id: &santapb.ProcessID{Pid: proto.Int32(2), Pidversion: proto.Int32(20)},
+				},
+			},
+		},
+	}
+	if got := store.AncestorPaths(fileAccess); len(got) != 1 || got[0] != want[1] {
+		t.Errorf("AncestorPaths(file access) = %v, want %v", got, want[1:])
+	}
+
+	if got := store.AncestorPaths(execMessage(9, 8, 8, "/bin/ls")); got != nil {
+		t.Errorf("Unknown parent should have no ancestors, got %v", got)
+	}
+	if got := (*Store)(nil).AncestorPaths(fileAccess); got != nil {
+		t.Errorf("Nil store should have no ancestors, got %v", got)
+	}
+}
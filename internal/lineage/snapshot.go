@@ -0,0 +1,65 @@
+package lineage
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Snapshot encodes the store's nodes as JSON, one entry per boot session,
+// so lineage can be persisted and restored across agent restarts.
+func (s *Store) Snapshot() (map[string][]byte, error) {
+	s.mu.RLock()
+	byBoot := make(map[string][]*Node)
+	for _, n := range s.nodes {
+		byBoot[n.Key.BootUUID] = append(byBoot[n.Key.BootUUID], n)
+	}
+	s.mu.RUnlock()
+
+	// Nodes are replaced rather than modified, so they can be encoded unlocked
+	out := make(map[string][]byte, len(byBoot))
+	for boot, nodes := range byBoot {
+		data, err := json.Marshal(nodes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode lineage of boot session %s: %w", boot, err)
+		}
+		out[boot] = data
+	}
+	return out, nil
+}
+
+// Restore loads nodes from a snapshot produced by Snapshot. Nodes past the
+// TTL and nodes already in the store are skipped, and the newest nodes are
+// kept when the snapshot holds more than the store has room for. It returns
+// the number of nodes restored.
+func (s *Store) Restore(snapshot map[string][]byte, now time.Time) (int, error) {
+	var nodes []*Node
+	for boot, data := range snapshot {
+		var session []*Node
+		if err := json.Unmarshal(data, &session); err != nil {
+			return 0, fmt.Errorf("failed to decode lineage of boot session %s: %w", boot, err)
+		}
+		nodes = append(nodes, session...)
+	}
+	slices.SortFunc(nodes, func(a, b *Node) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	cutoff := now.Add(-s.ttl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	restored := 0
+	for _, n := range nodes {
+		if len(s.nodes) >= s.maxEntries || n.CreatedAt.Before(cutoff) {
+			break
+		}
+		if n.Key.IsZero() {
+			continue
+		}
+		if _, ok := s.nodes[n.Key]; ok {
+			continue
+		}
+		s.addLocked(n)
+		restored++
+	}
+	return restored, nil
+}
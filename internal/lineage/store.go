@@ -9,9 +9,9 @@ import (
 
 // Key uniquely identifies a process within a boot session.
 type Key struct {
-	BootUUID   string
-	Pid        int32
-	PidVersion int32
+	BootUUID   string `json:"boot_uuid"`
+	Pid        int32  `json:"pid"`
+	PidVersion int32  `json:"pidversion"`
 }
 
 // IsZero reports whether the key has no meaningful value.
@@ -33,26 +33,34 @@ func FromProcessID(bootUUID string, pid *santapb.ProcessID) Key {
 
 // Node captures execution-time information about a process.
 type Node struct {
-	Key         Key
-	Parent      Key
-	Responsible Key
-
-	Path      string
-	User      string
-	UID       int32
-	Group     string
-	GID       int32
-	SessionID int32
-
-	Args      []string
-	StartTime time.Time
-	CreatedAt time.Time
+	Key         Key `json:"key"`
+	Parent      Key `json:"parent"`
+	Responsible Key `json:"responsible"`
+
+	Path      string `json:"path,omitempty"`
+	User      string `json:"user,omitempty"`
+	UID       int32  `json:"uid"`
+	Group     string `json:"group,omitempty"`
+	GID       int32  `json:"gid"`
+	SessionID int32  `json:"session_id"`
+
+	Args      []string  `json:"args,omitempty"`
+	StartTime time.Time `json:"start_time,omitzero"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Code signing and hash of the executable, for trust context
+	TeamID         string `json:"team_id,omitempty"`
+	SigningID      string `json:"signing_id,omitempty"`
+	PlatformBinary bool   `json:"platform_binary,omitempty"`
+	SHA256         string `json:"sha256,omitempty"`
 }
 
 // Store keeps a bounded, per-boot cache of process nodes for lineage building.
 type Store struct {
 	mu         sync.RWMutex
 	nodes      map[Key]*Node
+	children   map[Key][]Key // Recorded children by parent
+	ages       ageHeap       // Recorded nodes by creation time, for eviction
 	maxEntries int
 	ttl        time.Duration
 }
@@ -73,6 +81,7 @@ func NewStore(cfg Config) *Store {
 	}
 	return &Store{
 		nodes:      make(map[Key]*Node, cfg.MaxEntries),
+		children:   make(map[Key][]Key),
 		maxEntries: cfg.MaxEntries,
 		ttl:        cfg.TTL,
 	}
@@ -97,6 +106,7 @@ func (s *Store) UpsertFromExecution(msg *santapb.SantaMessage, ev *santapb.Execu
 
 	var (
 		path      string
+		sha256    string
 		userName  string
 		uid       int32
 		groupName string
@@ -106,6 +116,7 @@ func (s *Store) UpsertFromExecution(msg *santapb.SantaMessage, ev *santapb.Execu
 
 	if exe := target.GetExecutable(); exe != nil {
 		path = exe.GetPath()
+		sha256 = exe.GetHash().GetHash()
 	}
 	if u := target.GetEffectiveUser(); u != nil {
 		userName = u.GetName()
@@ -132,21 +143,22 @@ func (s *Store) UpsertFromExecution(msg *santapb.SantaMessage, ev *santapb.Execu
 		Args:        decodeArgs(ev.GetArgs()),
 		StartTime:   startTime,
 		CreatedAt:   now,
+
+		TeamID:         target.GetCodeSignature().GetTeamId(),
+		SigningID:      target.GetCodeSignature().GetSigningId(),
+		PlatformBinary: target.GetIsPlatformBinary(),
+		SHA256:         sha256,
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Basic TTL-based cleanup on write to prevent unbounded growth.
-	s.evictExpiredLocked(now)
+	// Expired nodes are removed by RunSweeper; only the cap is enforced here
 	if len(s.nodes) >= s.maxEntries {
 		s.evictOldestLocked()
 	}
 
-	if s.nodes == nil {
-		s.nodes = make(map[Key]*Node, s.maxEntries)
-	}
-	s.nodes[key] = node
+	s.addLocked(node)
 }
 
 // Lineage builds an ancestor chain starting from key, following Parent links.
@@ -189,6 +201,47 @@ func (s *Store) Lineage(key Key, maxDepth int) []*Node {
 	return chain
 }
 
+// launchdPid is the pid of launchd, the parent of every XPC service and app
+const launchdPid = 1
+
+// ResponsibleLineage builds an ancestor chain like Lineage, but where the
+// parent chain ends or reaches launchd it continues with the responsible
+// process, the way macOS attributes responsibility. A shell spawned by an
+// app's XPC helper then reads shell, helper, app instead of stopping at the
+// helper, whose parent is launchd.
+func (s *Store) ResponsibleLineage(key Key, maxDepth int) []*Node {
+	if maxDepth <= 0 {
+		maxDepth = 8
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chain := make([]*Node, 0, maxDepth)
+	seen := make(map[Key]struct{}, maxDepth)
+	current := s.nodes[key]
+	for current != nil && len(chain) < maxDepth {
+		chain = append(chain, current)
+		seen[current.Key] = struct{}{}
+
+		next := s.nodes[current.Parent]
+		if next == nil || next.Key.Pid == launchdPid {
+			// Apps are responsible for themselves; keep their parent then
+			if resp := s.nodes[current.Responsible]; resp != nil && resp.Key != current.Key {
+				next = resp
+			}
+		}
+		if next == nil {
+			break
+		}
+		if _, exists := seen[next.Key]; exists {
+			break
+		}
+		current = next
+	}
+	return chain
+}
+
 // Serialize converts a lineage chain into a JSON-friendly structure.
 func Serialize(nodes []*Node) []map[string]any {
 	if len(nodes) == 0 {
@@ -198,62 +251,50 @@ func Serialize(nodes []*Node) []map[string]any {
 	out := make([]map[string]any, len(nodes))
 	for i, n := range nodes {
 		relation := "target"
-		if i == 1 {
+		switch {
+		case i > 0 && nodes[i-1].Parent != n.Key && nodes[i-1].Responsible == n.Key:
+			// Reached through responsibility (see ResponsibleLineage)
+			relation = "responsible"
+		case i == 1:
 			relation = "parent"
-		} else if i > 1 {
+		case i > 1:
 			relation = "ancestor"
 		}
 
-		m := map[string]any{
-			"relation":   relation,
-			"depth":      i,
-			"pid":        n.Key.Pid,
-			"pidversion": n.Key.PidVersion,
-			"path":       n.Path,
-			"user":       n.User,
-			"uid":        n.UID,
-			"group":      n.Group,
-			"gid":        n.GID,
-			"session_id": n.SessionID,
-			"start_time": n.StartTime,
-		}
-		if len(n.Args) > 0 {
-			m["args"] = n.Args
-		}
-		out[i] = m
+		out[i] = serializeNode(n, relation, i)
 	}
 	return out
 }
 
-func (s *Store) evictExpiredLocked(now time.Time) {
-	if s.ttl <= 0 || len(s.nodes) == 0 {
-		return
+// serializeNode converts one node of a lineage chain or subtree
+func serializeNode(n *Node, relation string, depth int) map[string]any {
+	m := map[string]any{
+		"relation":   relation,
+		"depth":      depth,
+		"pid":        n.Key.Pid,
+		"pidversion": n.Key.PidVersion,
+		"path":       n.Path,
+		"user":       n.User,
+		"uid":        n.UID,
+		"group":      n.Group,
+		"gid":        n.GID,
+		"session_id": n.SessionID,
+		"start_time": n.StartTime,
 	}
-	cutoff := now.Add(-s.ttl)
-	for k, n := range s.nodes {
-		if n.CreatedAt.Before(cutoff) {
-			delete(s.nodes, k)
-		}
+	if len(n.Args) > 0 {
+		m["args"] = n.Args
 	}
-}
-
-func (s *Store) evictOldestLocked() {
-	if len(s.nodes) == 0 {
-		return
+	m["platform_binary"] = n.PlatformBinary
+	if n.TeamID != "" {
+		m["team_id"] = n.TeamID
 	}
-	var oldestKey Key
-	var oldestTime time.Time
-	first := true
-	for k, n := range s.nodes {
-		if first || n.CreatedAt.Before(oldestTime) {
-			oldestKey = k
-			oldestTime = n.CreatedAt
-			first = false
-		}
+	if n.SigningID != "" {
+		m["signing_id"] = n.SigningID
 	}
-	if !oldestKey.IsZero() {
-		delete(s.nodes, oldestKey)
+	if n.SHA256 != "" {
+		m["sha256"] = n.SHA256
 	}
+	return m
 }
 
 func decodeArgs(raw [][]byte) []string {
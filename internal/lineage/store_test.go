@@ -1,10 +1,12 @@
 package lineage
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
 )
 
 // TestFromProcessID tests Key creation from ProcessID
@@ -261,34 +263,67 @@ func TestMaxDepthLimit(t *testing.T) {
 	}
 }
 
-// TestTTLEviction tests that old entries are evicted
+// TestTTLEviction tests that sweeps evict expired entries in batches
 func TestTTLEviction(t *testing.T) {
 	store := NewStore(Config{
 		MaxEntries: 100,
 		TTL:        100 * time.Millisecond, // Very short TTL for testing
 	})
 
-	bootUUID := "test-boot"
-	key := Key{BootUUID: bootUUID, Pid: 1, PidVersion: 100}
-
-	// Add a node
+	now := time.Now()
+	key := func(pid int32) Key { return Key{BootUUID: "test-boot", Pid: pid, PidVersion: 100} }
 	store.mu.Lock()
-	store.nodes[key] = &Node{
-		Key:       key,
-		Parent:    Key{},
-		Path:      "/bin/bash",
-		CreatedAt: time.Now().Add(-200 * time.Millisecond), // Already expired
+	for pid := int32(1); pid <= 3; pid++ {
+		store.addLocked(&Node{Key: key(pid), Path: "/bin/bash", CreatedAt: now.Add(-200 * time.Millisecond)})
+	}
+	store.addLocked(&Node{Key: key(4), Path: "/bin/bash", CreatedAt: now})
+	store.mu.Unlock()
+
+	if removed := store.Sweep(now, 2); removed != 2 {
+		t.Errorf("Sweep() removed %d nodes, want the batch of 2", removed)
+	}
+	if removed := store.Sweep(now, 2); removed != 1 {
+		t.Errorf("Sweep() removed %d nodes, want the last expired one", removed)
 	}
 
-	// Call eviction (must hold lock)
-	store.evictExpiredLocked(time.Now())
+	store.mu.RLock()
+	_, expired := store.nodes[key(1)]
+	_, fresh := store.nodes[key(4)]
+	store.mu.RUnlock()
+	if expired || !fresh {
+		t.Error("Expected only the expired nodes to be evicted")
+	}
+}
 
-	// Should be evicted
-	_, exists := store.nodes[key]
+// TestRunSweeper tests that the background sweeper evicts expired entries
+func TestRunSweeper(t *testing.T) {
+	store := NewStore(Config{MaxEntries: 100, TTL: time.Millisecond})
+	store.mu.Lock()
+	store.addLocked(&Node{Key: Key{BootUUID: "boot", Pid: 1}, CreatedAt: time.Now()})
 	store.mu.Unlock()
 
-	if exists {
-		t.Error("Expected expired node to be evicted")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.RunSweeper(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	size := func() int {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		return len(store.nodes)
+	}
+	deadline := time.Now().Add(time.Second)
+	for size() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expired node was not swept")
+		}
+		time.Sleep(5 * time.Millisecond)
 	}
 }
 
@@ -305,11 +340,11 @@ func TestMaxEntriesEviction(t *testing.T) {
 	for i := 1; i <= 3; i++ {
 		key := Key{BootUUID: bootUUID, Pid: int32(i), PidVersion: int32(i * 100)}
 		store.mu.Lock()
-		store.nodes[key] = &Node{
+		store.addLocked(&Node{
 			Key:       key,
 			Path:      "/bin/test",
 			CreatedAt: time.Now().Add(-time.Duration(i) * time.Minute), // Higher i = older timestamp
-		}
+		})
 		store.mu.Unlock()
 	}
 
@@ -417,6 +452,133 @@ func TestSerialize(t *testing.T) {
 	}
 }
 
+// TestResponsibleLineage tests that the responsible process continues a
+// chain that ends at launchd
+func TestResponsibleLineage(t *testing.T) {
+	store := NewStore(Config{MaxEntries: 100, TTL: time.Hour})
+	launchd := Key{BootUUID: "boot", Pid: 1, PidVersion: 1}
+	app := Key{BootUUID: "boot", Pid: 100, PidVersion: 1}
+	helper := Key{BootUUID: "boot", Pid: 200, PidVersion: 1}
+	shell := Key{BootUUID: "boot", Pid: 300, PidVersion: 1}
+
+	store.mu.Lock()
+	store.nodes[launchd] = &Node{Key: launchd, Path: "/sbin/launchd"}
+	store.nodes[app] = &Node{Key: app, Parent: launchd, Responsible: app, Path: "/Applications/Editor.app/Contents/MacOS/Editor"}
+	store.nodes[helper] = &Node{Key: helper, Parent: launchd, Responsible: app, Path: "/Applications/Editor.app/Contents/XPCServices/Helper.xpc/Contents/MacOS/Helper"}
+	store.nodes[shell] = &Node{Key: shell, Parent: helper, Responsible: app, Path: "/bin/zsh"}
+	store.mu.Unlock()
+
+	if chain := store.Lineage(shell, 8); len(chain) != 3 || chain[2].Key != launchd {
+		t.Fatalf("Parent lineage should end at launchd, got %d nodes", len(chain))
+	}
+
+	chain := store.ResponsibleLineage(shell, 8)
+	want := []Key{shell, helper, app, launchd}
+	if len(chain) != len(want) {
+		t.Fatalf("Expected %d nodes, got %d", len(want), len(chain))
+	}
+	for i, k := range want {
+		if chain[i].Key != k {
+			t.Errorf("chain[%d] = %+v, want %+v", i, chain[i].Key, k)
+		}
+	}
+
+	relations := []string{"target", "parent", "responsible", "ancestor"}
+	for i, m := range Serialize(chain) {
+		if m["relation"] != relations[i] {
+			t.Errorf("relation[%d] = %v, want %s", i, m["relation"], relations[i])
+		}
+	}
+}
+
+// TestDescendants tests the children index, including after eviction
+func TestDescendants(t *testing.T) {
+	store := NewStore(Config{MaxEntries: 100, TTL: time.Hour})
+	key := func(pid int32) Key { return Key{BootUUID: "boot", Pid: pid, PidVersion: 1} }
+	now := time.Now()
+	add := func(pid, parent int32, path string, age time.Duration) {
+		store.mu.Lock()
+		store.addLocked(&Node{Key: key(pid), Parent: key(parent), Path: path, CreatedAt: now.Add(-age)})
+		store.mu.Unlock()
+	}
+	add(2, 1, "/bin/zsh", 0)
+	add(3, 2, "/usr/bin/curl", 0)
+	add(4, 2, "/usr/bin/python3", 0)
+	add(5, 4, "/bin/sh", 2*time.Hour)
+
+	got := store.Descendants(key(2), 8, 0)
+	if len(got) != 3 || got[0].Key != key(3) || got[1].Key != key(4) || got[2].Key != key(5) {
+		t.Fatalf("Descendants() = %d nodes, want curl, python3, sh", len(got))
+	}
+	if got := store.Descendants(key(2), 1, 0); len(got) != 2 {
+		t.Errorf("Expected children only at depth 1, got %d nodes", len(got))
+	}
+	if got := store.Descendants(key(2), 8, 1); len(got) != 1 {
+		t.Errorf("Expected the limit to apply, got %d nodes", len(got))
+	}
+
+	m := SerializeDescendants(key(2), got)
+	if m[0]["relation"] != "child" || m[0]["depth"] != 1 || m[0]["parent_pid"] != int32(2) {
+		t.Errorf("Unexpected serialized child: %v", m[0])
+	}
+	if m := SerializeDescendants(key(2), store.Descendants(key(2), 8, 0)); m[2]["relation"] != "descendant" || m[2]["depth"] != 2 {
+		t.Errorf("Unexpected serialized grandchild: %v", m[2])
+	}
+
+	store.Sweep(now, sweepBatch)
+	store.mu.Lock()
+	_, indexed := store.children[key(4)]
+	store.mu.Unlock()
+	if indexed {
+		t.Error("Evicted child still indexed under its parent")
+	}
+	if got := store.Descendants(key(2), 8, 0); len(got) != 2 {
+		t.Errorf("Expected 2 descendants after eviction, got %d", len(got))
+	}
+}
+
+// TestCodeSigning tests that signing info is recorded and serialized
+func TestCodeSigning(t *testing.T) {
+	store := NewStore(Config{})
+	msg := &santapb.SantaMessage{
+		BootSessionUuid: proto.String("boot"),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{
+					Id: &santapb.ProcessID{Pid: proto.Int32(10), Pidversion: proto.Int32(1)},
+					Executable: &santapb.FileInfo{
+						Path: proto.String("/usr/bin/osascript"),
+						Hash: &santapb.Hash{Hash: proto.String("4f2a")},
+					},
+					CodeSignature: &santapb.CodeSignature{
+						TeamId:    proto.String(""),
+						SigningId: proto.String("com.apple.osascript"),
+					},
+					IsPlatformBinary: proto.Bool(true),
+				},
+			},
+		},
+	}
+	store.UpsertFromExecution(msg, msg.GetExecution())
+
+	chain := store.Lineage(Key{BootUUID: "boot", Pid: 10, PidVersion: 1}, 1)
+	if len(chain) != 1 {
+		t.Fatalf("Expected the executed process in the store, got %d nodes", len(chain))
+	}
+	n := chain[0]
+	if n.SigningID != "com.apple.osascript" || !n.PlatformBinary || n.SHA256 != "4f2a" || n.TeamID != "" {
+		t.Errorf("Unexpected signing info: %+v", n)
+	}
+
+	m := Serialize(chain)[0]
+	if m["signing_id"] != "com.apple.osascript" || m["platform_binary"] != true || m["sha256"] != "4f2a" {
+		t.Errorf("Unexpected serialized signing info: %v", m)
+	}
+	if _, ok := m["team_id"]; ok {
+		t.Error("Empty team ID should be omitted")
+	}
+}
+
 // TestConcurrentAccess tests concurrent reads and writes
 func TestConcurrentAccess(t *testing.T) {
 	store := NewStore(Config{MaxEntries: 1000, TTL: time.Hour})
@@ -471,3 +633,88 @@ func TestEmptyLineage(t *testing.T) {
 		t.Error("Expected nil lineage for non-existent key")
 	}
 }
+
+// TestSnapshotRestore tests that lineage survives a snapshot round trip
+func TestSnapshotRestore(t *testing.T) {
+	now := time.Now()
+	store := NewStore(Config{MaxEntries: 100, TTL: time.Hour})
+	root := Key{BootUUID: "boot-1", Pid: 1, PidVersion: 1}
+	child := Key{BootUUID: "boot-1", Pid: 200, PidVersion: 7}
+	stale := Key{BootUUID: "boot-0", Pid: 300, PidVersion: 1}
+
+	store.mu.Lock()
+	store.nodes[root] = &Node{Key: root, Path: "/sbin/launchd", CreatedAt: now.Add(-time.Minute)}
+	store.nodes[child] = &Node{
+		Key:       child,
+		Parent:    root,
+		Path:      "/bin/zsh",
+		Args:      []string{"zsh", "-l"},
+		StartTime: now.Add(-time.Minute).UTC(),
+		CreatedAt: now,
+	}
+	store.nodes[stale] = &Node{Key: stale, Path: "/usr/bin/old", CreatedAt: now.Add(-2 * time.Hour)}
+	store.mu.Unlock()
+
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if len(snapshot) != 2 {
+		t.Fatalf("Expected one snapshot entry per boot session, got %d", len(snapshot))
+	}
+
+	restored := NewStore(Config{MaxEntries: 100, TTL: time.Hour})
+	n, err := restored.Restore(snapshot, now)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 nodes restored (expired one skipped), got %d", n)
+	}
+	chain := restored.Lineage(child, 8)
+	if len(chain) != 2 || chain[0].Path != "/bin/zsh" || chain[1].Path != "/sbin/launchd" {
+		t.Fatalf("Unexpected restored lineage: %+v", chain)
+	}
+	if len(chain[0].Args) != 2 || !chain[0].StartTime.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Restored node lost fields: %+v", chain[0])
+	}
+
+	// A full store keeps the newest nodes
+	small := NewStore(Config{MaxEntries: 1, TTL: time.Hour})
+	if n, _ := small.Restore(snapshot, now); n != 1 || len(small.Lineage(child, 1)) != 1 {
+		t.Errorf("Expected only the newest node restored into a full store, got %d", n)
+	}
+
+	if _, err := restored.Restore(map[string][]byte{"boot-1": []byte("{")}, now); err == nil {
+		t.Error("Expected error for a corrupt snapshot")
+	}
+}
+
+// BenchmarkUpsertAtCapacity measures recording executions once the store is
+// full, where every insert evicts the oldest node
+func BenchmarkUpsertAtCapacity(b *testing.B) {
+	store := NewStore(Config{MaxEntries: 50000, TTL: time.Hour})
+	msgs := make([]*santapb.SantaMessage, 100000)
+	for i := range msgs {
+		msgs[i] = &santapb.SantaMessage{
+			BootSessionUuid: proto.String("boot"),
+			Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{
+					Id:         &santapb.ProcessID{Pid: proto.Int32(int32(i)), Pidversion: proto.Int32(1)},
+					ParentId:   &santapb.ProcessID{Pid: proto.Int32(int32(i / 2)), Pidversion: proto.Int32(1)},
+					Executable: &santapb.FileInfo{Path: proto.String("/bin/sh")},
+				},
+			}},
+		}
+	}
+	for _, msg := range msgs[:50000] {
+		store.UpsertFromExecution(msg, msg.GetExecution())
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg := msgs[50000+i%50000]
+		store.UpsertFromExecution(msg, msg.GetExecution())
+	}
+}
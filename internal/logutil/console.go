@@ -0,0 +1,240 @@
+package logutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ANSI color codes
+const (
+	colorReset       = "\033[0m"
+	colorRed         = "\033[91m"
+	colorGreen       = "\033[92m"
+	colorYellow      = "\033[93m"
+	colorOrange      = "\033[38;5;208m"
+	colorCyan        = "\033[96m"
+	colorGray        = "\033[90m"
+	colorDimGray     = "\033[38;5;240m" // Very dim gray for timestamps
+	colorContextGray = "\033[38;5;8m"   // Dim gray for context
+	colorBrightWhite = "\033[97m"       // Bright white for rule IDs
+	colorNormalWhite = "\033[37m"       // Normal white for titles
+	colorBold        = "\033[1m"
+)
+
+// ansiPattern matches the escape sequences stripped when color is disabled
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Attribute keys with special meaning to the console handler
+const (
+	successKey = "success"
+	signalKey  = "signal"
+)
+
+var (
+	// Unicode symbols with colors
+	checkMark = colorGreen + "✓" + colorReset  // green checkmark
+	warnMark  = colorYellow + "⚠" + colorReset // yellow warning
+	crossMark = colorRed + "✗" + colorReset    // red cross
+	infoMark  = colorGray + "ℹ" + colorReset   // gray info
+
+	// Severity icons (no color, just emoji)
+	severityIcons = map[string]string{
+		"critical": "🔴",
+		"high":     "🟠",
+		"medium":   "🟡",
+		"low":      "🟢",
+		"info":     "🔵",
+	}
+
+	// Severity text colors
+	severityColors = map[string]string{
+		"critical": colorRed,
+		"high":     colorOrange,
+		"medium":   colorYellow,
+		"low":      colorGreen,
+		"info":     colorCyan,
+	}
+)
+
+// consoleHandler renders records in santamon's colorized console format.
+// Level filtering is done by router, so every record passed in is written.
+type consoleHandler struct {
+	mu      *sync.Mutex
+	w       io.Writer
+	noColor bool // Strip ANSI colors (e.g. when writing to a log file)
+	attrs   []slog.Attr
+	group   string
+}
+
+func newConsoleHandler(w io.Writer, noColor bool) *consoleHandler {
+	return &consoleHandler{mu: &sync.Mutex{}, w: w, noColor: noColor}
+}
+
+func (h *consoleHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	c.attrs = append(c.attrs, h.attrs...)
+	for _, a := range attrs {
+		c.attrs = append(c.attrs, h.qualify(a))
+	}
+	return &c
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	if c.group != "" {
+		c.group += "."
+	}
+	c.group += name
+	return &c
+}
+
+func (h *consoleHandler) qualify(a slog.Attr) slog.Attr {
+	if h.group != "" {
+		a.Key = h.group + "." + a.Key
+	}
+	return a
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, h.qualify(a))
+		return true
+	})
+
+	// Log files always get full timestamps; the console only in verbose mode
+	ts := ""
+	if h.noColor {
+		ts = r.Time.Format(time.RFC3339) + " "
+	} else if ShowTimestamps {
+		ts = colorDimGray + r.Time.Format("15:04:05") + colorReset + " "
+	}
+
+	var sb strings.Builder
+	if isSet(attrs, signalKey) {
+		writeSignal(&sb, ts, r.Message, attrs)
+	} else {
+		mark := infoMark
+		switch {
+		case r.Level >= slog.LevelError:
+			mark = crossMark
+		case r.Level >= slog.LevelWarn:
+			mark = warnMark
+		case isSet(attrs, successKey):
+			mark = checkMark
+		}
+		sb.WriteString(ts + mark + " " + r.Message)
+
+		// Structured attributes are dimmed after the message; component is implied
+		for _, a := range attrs {
+			if a.Key == successKey || a.Key == "component" {
+				continue
+			}
+			fmt.Fprintf(&sb, " %s%s=%v%s", colorContextGray, a.Key, a.Value.Any(), colorReset)
+		}
+		sb.WriteByte('\n')
+	}
+
+	line := sb.String()
+	if h.noColor {
+		line = ansiPattern.ReplaceAllString(line, "")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line)
+	return err
+}
+
+// isSet reports whether a boolean attribute is present and true
+func isSet(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key && a.Value.Kind() == slog.KindBool {
+			return a.Value.Bool()
+		}
+	}
+	return false
+}
+
+// attrString returns the string value of an attribute, or ""
+func attrString(attrs []slog.Attr, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+func severityLabel(severity string) string {
+	s := strings.ToLower(severity)
+	color, ok := severityColors[s]
+	if !ok {
+		color = severityColors["info"]
+		s = "info"
+	}
+	// Get icon for severity
+	icon := severityIcons[s]
+	if icon == "" {
+		icon = "•"
+	}
+	return icon + " " + color + colorBold + strings.ToUpper(severity) + colorReset
+}
+
+// writeSignal renders a detection signal record
+func writeSignal(sb *strings.Builder, ts, title string, attrs []slog.Attr) {
+	ruleID := attrString(attrs, "rule_id")
+	severity := attrString(attrs, "severity")
+	extra := attrString(attrs, "context")
+
+	// Add blank line before each signal in verbose mode for better separation
+	if CurrentVerbosity >= VerboseLevel {
+		sb.WriteByte('\n')
+	}
+
+	// Format: [timestamp] ICON SEVERITY  RULE_ID: Title
+	sev := severityLabel(severity)
+
+	// Get severity color for the colon
+	sevColor, ok := severityColors[strings.ToLower(severity)]
+	if !ok {
+		sevColor = severityColors["info"]
+	}
+
+	// Rule ID in bright white bold, colon in severity color
+	ruleIDStyled := colorBrightWhite + colorBold + ruleID + colorReset
+	colonStyled := sevColor + colorBold + ":" + colorReset
+
+	// Calculate spaces needed after styled rule ID and colon for alignment (12 chars total)
+	spacesNeeded := 12 - len(ruleID) - 1 // -1 for the colon
+	if spacesNeeded < 0 {
+		spacesNeeded = 0
+	}
+	ruleIDDisplay := ruleIDStyled + colonStyled + strings.Repeat(" ", spacesNeeded)
+
+	// Title in normal white
+	coloredTitle := colorNormalWhite + title + colorReset
+
+	fmt.Fprintf(sb, "%s%s %s %s\n", ts, sev, ruleIDDisplay, coloredTitle)
+
+	// Context line: only show in verbose mode
+	if extra != "" && CurrentVerbosity >= VerboseLevel {
+		indent := "         " + strings.Repeat(" ", len(ansiPattern.ReplaceAllString(ts, ""))) // account for timestamp
+		fmt.Fprintf(sb, "%s%s└─ %s%s\n", indent, colorContextGray, extra, colorReset)
+	}
+}
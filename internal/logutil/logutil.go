@@ -1,11 +1,14 @@
 package logutil
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
 )
 
 // VerbosityLevel represents the logging verbosity
@@ -18,20 +21,13 @@ const (
 	VerboseLevel
 )
 
-// ANSI color codes
+// LevelVerbose sits between debug and info; it is what --verbose enables
+const LevelVerbose = slog.LevelInfo - 2
+
+// Log formats
 const (
-	colorReset       = "\033[0m"
-	colorRed         = "\033[91m"
-	colorGreen       = "\033[92m"
-	colorYellow      = "\033[93m"
-	colorOrange      = "\033[38;5;208m"
-	colorCyan        = "\033[96m"
-	colorGray        = "\033[90m"
-	colorDimGray     = "\033[38;5;240m" // Very dim gray for timestamps
-	colorContextGray = "\033[38;5;8m"   // Dim gray for context
-	colorBrightWhite = "\033[97m"       // Bright white for rule IDs
-	colorNormalWhite = "\033[37m"       // Normal white for titles
-	colorBold        = "\033[1m"
+	FormatText = "text" // Colorized console output (default)
+	FormatJSON = "json" // One JSON object per line for log pipelines
 )
 
 var (
@@ -39,42 +35,128 @@ var (
 	CurrentVerbosity = NormalLevel
 	// ShowTimestamps controls whether timestamps are shown
 	ShowTimestamps = false
-
-	// Unicode symbols with colors
-	checkMark = colorGreen + "✓" + colorReset  // green checkmark
-	warnMark  = colorYellow + "⚠" + colorReset // yellow warning
-	crossMark = colorRed + "✗" + colorReset    // red cross
-	infoMark  = colorGray + "ℹ" + colorReset   // gray info
-
-	// Severity icons (no color, just emoji)
-	severityIcons = map[string]string{
-		"critical": "🔴",
-		"high":     "🟠",
-		"medium":   "🟡",
-		"low":      "🟢",
-		"info":     "🔵",
-	}
-
-	// Severity text colors
-	severityColors = map[string]string{
-		"critical": colorRed,
-		"high":     colorOrange,
-		"medium":   colorYellow,
-		"low":      colorGreen,
-		"info":     colorCyan,
-	}
 )
 
+// Options configures agent logging
+type Options struct {
+	Format     string            // "text" or "json" (default: text)
+	Level      string            // Default level: debug, verbose, info, warn, error (default: info)
+	Components map[string]string // Per-component level overrides, e.g. {"shipper": "debug"}
+	Output     io.Writer         // Destination (default: os.Stderr)
+	NoColor    bool              // Disable ANSI colors in text output
+}
+
+// settings is the active logging configuration, swapped atomically by Configure
+type settings struct {
+	handler    slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+}
+
+var current atomic.Pointer[settings]
+
 func init() {
-	// Simple, consistent log format without default timestamps;
-	// we render our own prefixes instead.
-	log.SetFlags(0)
-	log.SetOutput(os.Stderr)
+	level := slog.LevelInfo
+	if os.Getenv("SANTAMON_DEBUG") == "1" {
+		level = slog.LevelDebug
+	}
+	current.Store(&settings{
+		handler:    newConsoleHandler(os.Stderr, false),
+		level:      level,
+		components: map[string]slog.Level{},
+	})
+	// Route slog's default logger (and the standard log package) through us
+	slog.SetDefault(slog.New(&router{}))
+}
+
+// Configure replaces the active logging configuration
+func Configure(opts Options) error {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	level := slog.LevelInfo
+	if opts.Level != "" {
+		l, err := ParseLevel(opts.Level)
+		if err != nil {
+			return err
+		}
+		level = l
+	}
+	if os.Getenv("SANTAMON_DEBUG") == "1" {
+		level = slog.LevelDebug
+	}
+
+	components := make(map[string]slog.Level, len(opts.Components))
+	for name, lvl := range opts.Components {
+		l, err := ParseLevel(lvl)
+		if err != nil {
+			return fmt.Errorf("component %s: %w", name, err)
+		}
+		components[name] = l
+	}
+
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", FormatText:
+		h = newConsoleHandler(out, opts.NoColor)
+	case FormatJSON:
+		h = slog.NewJSONHandler(out, &slog.HandlerOptions{
+			Level:       slog.LevelDebug, // Filtering happens in router
+			ReplaceAttr: replaceLevelName,
+		})
+	default:
+		return fmt.Errorf("unknown log format: %s", opts.Format)
+	}
+
+	if level <= LevelVerbose {
+		CurrentVerbosity = VerboseLevel
+	} else {
+		CurrentVerbosity = NormalLevel
+	}
+
+	current.Store(&settings{handler: h, level: level, components: components})
+	return nil
+}
+
+// ParseLevel converts a level name into a slog level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "verbose":
+		return LevelVerbose, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level: %s", level)
+}
+
+// replaceLevelName gives LevelVerbose a readable name in JSON output
+func replaceLevelName(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if lvl, ok := a.Value.Any().(slog.Level); ok && lvl == LevelVerbose {
+			return slog.String(slog.LevelKey, "VERBOSE")
+		}
+	}
+	return a
 }
 
 // SetVerbosity sets the current verbosity level
 func SetVerbosity(level VerbosityLevel) {
 	CurrentVerbosity = level
+	s := *current.Load()
+	if level >= VerboseLevel && s.level > LevelVerbose {
+		s.level = LevelVerbose
+	} else if level < VerboseLevel && s.level <= LevelVerbose {
+		s.level = slog.LevelInfo
+	}
+	current.Store(&s)
 }
 
 // SetTimestamps enables or disables timestamps
@@ -82,116 +164,159 @@ func SetTimestamps(enabled bool) {
 	ShowTimestamps = enabled
 }
 
-func timestamp() string {
-	if ShowTimestamps {
-		return colorDimGray + time.Now().Format("15:04:05") + colorReset + " "
-	}
-	return ""
+// router is the slog.Handler behind every logger. It applies the global and
+// per-component levels and forwards records to the active output handler, so
+// loggers created at package init pick up later Configure calls.
+type router struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler // Pending WithAttrs/WithGroup calls
 }
 
-// timestampForSignals returns a timestamp for signals (only in verbose mode)
-func timestampForSignals() string {
-	if ShowTimestamps {
-		return colorDimGray + time.Now().Format("15:04:05") + colorReset + " "
+func (r *router) Enabled(_ context.Context, level slog.Level) bool {
+	s := current.Load()
+	if lvl, ok := s.components[r.component]; ok {
+		return level >= lvl
 	}
-	return ""
+	return level >= s.level
 }
 
-func Info(format string, args ...any) {
-	if CurrentVerbosity < NormalLevel {
-		return
+func (r *router) Handle(ctx context.Context, rec slog.Record) error {
+	h := current.Load().handler
+	if r.component != "" {
+		h = h.WithAttrs([]slog.Attr{slog.String("component", r.component)})
+	}
+	for _, op := range r.ops {
+		h = op(h)
 	}
-	msg := fmt.Sprintf(format, args...)
-	log.Println(timestamp() + infoMark + " " + msg)
+	return h.Handle(ctx, rec)
 }
 
-func Warn(format string, args ...any) {
-	msg := fmt.Sprintf(format, args...)
-	log.Println(timestamp() + warnMark + " " + msg)
+func (r *router) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return r.with(func(h slog.Handler) slog.Handler { return h.WithAttrs(attrs) })
 }
 
-func Error(format string, args ...any) {
-	msg := fmt.Sprintf(format, args...)
-	log.Println(timestamp() + crossMark + " " + msg)
+func (r *router) WithGroup(name string) slog.Handler {
+	return r.with(func(h slog.Handler) slog.Handler { return h.WithGroup(name) })
 }
 
-func Success(format string, args ...any) {
-	if CurrentVerbosity < NormalLevel {
-		return
+func (r *router) with(op func(slog.Handler) slog.Handler) *router {
+	ops := make([]func(slog.Handler) slog.Handler, len(r.ops), len(r.ops)+1)
+	copy(ops, r.ops)
+	return &router{component: r.component, ops: append(ops, op)}
+}
+
+// Logger is a component-scoped logger with the same printf-style helpers as
+// the package-level functions
+type Logger struct {
+	slog *slog.Logger
+}
+
+var (
+	loggersMu sync.Mutex
+	loggers   = map[string]*Logger{}
+	std       = For("")
+)
+
+// For returns the logger for a component (e.g. "shipper", "spool"). Records
+// carry a component attribute and honour per-component levels.
+func For(component string) *Logger {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	if l, ok := loggers[component]; ok {
+		return l
 	}
-	msg := fmt.Sprintf(format, args...)
-	log.Println(timestamp() + checkMark + " " + msg)
+	l := &Logger{slog: slog.New(&router{component: component})}
+	loggers[component] = l
+	return l
 }
 
-// Verbose logs a message only in verbose mode
-func Verbose(format string, args ...any) {
-	if CurrentVerbosity < VerboseLevel {
+// Slog returns the underlying structured logger
+func (l *Logger) Slog() *slog.Logger {
+	return l.slog
+}
+
+func (l *Logger) logf(level slog.Level, format string, args []any, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !l.slog.Enabled(ctx, level) {
 		return
 	}
-	msg := fmt.Sprintf(format, args...)
-	log.Println(timestamp() + infoMark + " " + msg)
+	l.slog.LogAttrs(ctx, level, fmt.Sprintf(format, args...), attrs...)
 }
 
-func severityLabel(severity string) string {
-	s := strings.ToLower(severity)
-	color, ok := severityColors[s]
-	if !ok {
-		color = severityColors["info"]
-		s = "info"
-	}
-	// Get icon for severity
-	icon := severityIcons[s]
-	if icon == "" {
-		icon = "•"
-	}
-	return icon + " " + color + colorBold + strings.ToUpper(severity) + colorReset
+// Debug logs a message at debug level
+func (l *Logger) Debug(format string, args ...any) {
+	l.logf(slog.LevelDebug, format, args)
 }
 
-// Signal formats any detection signal (simple, correlation, baseline).
-// kind is "rule", "correlation", or "baseline" (no longer displayed in output).
-// extra contains context information that will be displayed on a second line (only in verbose mode).
-func Signal(kind, ruleID, severity, title, extra string) {
-	// Add blank line before each signal in verbose mode for better separation
-	if CurrentVerbosity >= VerboseLevel {
-		fmt.Println()
-	}
+// Verbose logs a message only in verbose mode
+func (l *Logger) Verbose(format string, args ...any) {
+	l.logf(LevelVerbose, format, args)
+}
 
-	// Format: [timestamp] ICON SEVERITY  RULE_ID: Title
-	ts := timestampForSignals()
-	sev := severityLabel(severity)
+// Info logs an informational message
+func (l *Logger) Info(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args)
+}
 
-	// Get severity color for the colon
-	s := strings.ToLower(severity)
-	sevColor, ok := severityColors[s]
-	if !ok {
-		sevColor = severityColors["info"]
-	}
+// Success logs an informational message marked as a successful outcome
+func (l *Logger) Success(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args, slog.Bool(successKey, true))
+}
 
-	// Rule ID in bright white bold, colon in severity color
-	ruleIDStyled := colorBrightWhite + colorBold + ruleID + colorReset
-	colonStyled := sevColor + colorBold + ":" + colorReset
+// Warn logs a warning
+func (l *Logger) Warn(format string, args ...any) {
+	l.logf(slog.LevelWarn, format, args)
+}
 
-	// Calculate spaces needed after styled rule ID and colon for alignment (12 chars total)
-	spacesNeeded := 12 - len(ruleID) - 1 // -1 for the colon
-	if spacesNeeded < 0 {
-		spacesNeeded = 0
-	}
-	ruleIDDisplay := ruleIDStyled + colonStyled + strings.Repeat(" ", spacesNeeded)
+// Error logs an error
+func (l *Logger) Error(format string, args ...any) {
+	l.logf(slog.LevelError, format, args)
+}
 
-	// Title in normal white
-	coloredTitle := colorNormalWhite + title + colorReset
+// Debug logs a message at debug level
+func Debug(format string, args ...any) {
+	std.Debug(format, args...)
+}
 
-	line := fmt.Sprintf("%s%s %s %s", ts, sev, ruleIDDisplay, coloredTitle)
-	log.Println(line)
+// Info logs an informational message
+func Info(format string, args ...any) {
+	std.Info(format, args...)
+}
 
-	// Context line: only show in verbose mode
-	if extra != "" && CurrentVerbosity >= VerboseLevel {
-		indent := "         "
-		if ShowTimestamps {
-			indent = "          " // account for HH:MM:SS timestamp
-		}
-		log.Printf("%s%s└─ %s%s\n", indent, colorContextGray, extra, colorReset)
+// Warn logs a warning
+func Warn(format string, args ...any) {
+	std.Warn(format, args...)
+}
+
+// Error logs an error
+func Error(format string, args ...any) {
+	std.Error(format, args...)
+}
+
+// Success logs an informational message marked as a successful outcome
+func Success(format string, args ...any) {
+	std.Success(format, args...)
+}
+
+// Verbose logs a message only in verbose mode
+func Verbose(format string, args ...any) {
+	std.Verbose(format, args...)
+}
+
+// Signal formats any detection signal (simple, correlation, baseline).
+// kind is "rule", "correlation", or "baseline" (no longer displayed in output).
+// extra contains context information that will be displayed on a second line (only in verbose mode).
+func Signal(kind, ruleID, severity, title, extra string) {
+	attrs := []slog.Attr{
+		slog.Bool(signalKey, true),
+		slog.String("kind", kind),
+		slog.String("rule_id", ruleID),
+		slog.String("severity", severity),
+	}
+	if extra != "" {
+		attrs = append(attrs, slog.String("context", extra))
 	}
+	std.slog.LogAttrs(context.Background(), slog.LevelInfo, title, attrs...)
 }
 
 // SignalContext formats signal context information for the second line
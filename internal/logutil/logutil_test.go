@@ -0,0 +1,133 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// configureForTest points logging at a buffer and restores defaults afterwards
+func configureForTest(t *testing.T, opts Options) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	opts.Output = &buf
+	if err := Configure(opts); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	t.Cleanup(func() { _ = Configure(Options{}) })
+	return &buf
+}
+
+func TestJSONFormat(t *testing.T) {
+	buf := configureForTest(t, Options{Format: FormatJSON})
+
+	For("shipper").Warn("Flush error: %v", "boom")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("Output is not JSON: %v (%q)", err, buf.String())
+	}
+	if rec["level"] != "WARN" {
+		t.Errorf("level = %v, want WARN", rec["level"])
+	}
+	if rec["msg"] != "Flush error: boom" {
+		t.Errorf("msg = %v, want formatted message", rec["msg"])
+	}
+	if rec["component"] != "shipper" {
+		t.Errorf("component = %v, want shipper", rec["component"])
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	buf := configureForTest(t, Options{
+		Format:     FormatJSON,
+		Level:      "warn",
+		Components: map[string]string{"spool": "debug"},
+	})
+
+	For("shipper").Info("hidden")
+	For("spool").Debug("shown")
+	Info("hidden too")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("Records below the default level were written: %s", out)
+	}
+	if !strings.Contains(out, "shown") {
+		t.Errorf("Component override was not applied: %s", out)
+	}
+}
+
+func TestVerboseLevel(t *testing.T) {
+	buf := configureForTest(t, Options{Format: FormatJSON, Level: "verbose"})
+
+	Verbose("details")
+	Debug("noise")
+
+	out := buf.String()
+	if !strings.Contains(out, `"level":"VERBOSE"`) {
+		t.Errorf("Expected VERBOSE record, got %s", out)
+	}
+	if strings.Contains(out, "noise") {
+		t.Errorf("Debug record written at verbose level: %s", out)
+	}
+	if CurrentVerbosity != VerboseLevel {
+		t.Error("Expected verbose console mode")
+	}
+}
+
+func TestConsoleFormat(t *testing.T) {
+	buf := configureForTest(t, Options{Format: FormatText})
+
+	Success("Shipped %d signals", 3)
+	Signal("rule", "SM-001", "high", "Suspicious thing", "kind=execution")
+
+	out := buf.String()
+	if !strings.Contains(out, checkMark+" Shipped 3 signals\n") {
+		t.Errorf("Expected success line, got %q", out)
+	}
+	if strings.Contains(out, "success=") {
+		t.Errorf("Success marker should not be rendered as an attribute: %q", out)
+	}
+	if !strings.Contains(out, "SM-001") || !strings.Contains(out, "Suspicious thing") {
+		t.Errorf("Expected signal line, got %q", out)
+	}
+	if strings.Contains(out, "kind=execution") {
+		t.Errorf("Signal context should only show in verbose mode: %q", out)
+	}
+}
+
+func TestStdlibAndSlogRouted(t *testing.T) {
+	buf := configureForTest(t, Options{Format: FormatJSON})
+
+	log.Printf("from log")
+	slog.Warn("from slog", "rule_id", "R1")
+	For("baseline").Slog().Warn("structured", "rule_id", "R2")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 records, got %d: %s", len(lines), buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[2]), &rec); err != nil {
+		t.Fatalf("Output is not JSON: %v", err)
+	}
+	if rec["component"] != "baseline" || rec["rule_id"] != "R2" {
+		t.Errorf("Unexpected structured record: %v", rec)
+	}
+}
+
+func TestConfigureErrors(t *testing.T) {
+	if err := Configure(Options{Format: "xml"}); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if err := Configure(Options{Level: "loud"}); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if err := Configure(Options{Components: map[string]string{"shipper": "loud"}}); err == nil {
+		t.Error("Expected error for unknown component level")
+	}
+}
@@ -0,0 +1,141 @@
+package logutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files so they sort chronologically
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is an io.WriteCloser that rotates the log file once it exceeds
+// a size limit, keeping a bounded number of timestamped backups
+type RotatingFile struct {
+	path       string
+	maxSize    int64         // Rotate when the file would exceed this many bytes
+	maxBackups int           // Backups to keep (0 or less = unlimited)
+	maxAge     time.Duration // Remove backups older than this (0 or less = keep forever)
+	now        func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens (or creates) path for appending
+func OpenRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("max size must be positive")
+	}
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+		now:        time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p, rotating first if it would push the file past the size limit
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+
+	backup := r.path + "." + r.now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune removes backups beyond maxBackups or older than maxAge
+func (r *RotatingFile) prune() {
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+	prefix := r.path + "."
+	type backup struct {
+		path string
+		ts   time.Time
+	}
+	var valid []backup
+	for _, b := range backups {
+		if ts, err := time.Parse(backupTimeFormat, strings.TrimPrefix(b, prefix)); err == nil {
+			valid = append(valid, backup{path: b, ts: ts})
+		}
+	}
+	// Newest first
+	sort.Slice(valid, func(i, j int) bool { return valid[i].ts.After(valid[j].ts) })
+
+	cutoff := r.now().Add(-r.maxAge)
+	for i, b := range valid {
+		expired := r.maxAge > 0 && b.ts.Before(cutoff)
+		if (r.maxBackups > 0 && i >= r.maxBackups) || expired {
+			_ = os.Remove(b.path)
+		}
+	}
+}
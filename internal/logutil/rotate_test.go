@@ -0,0 +1,142 @@
+package logutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestRotatingFile opens a rotating file with a controllable clock
+func openTestRotatingFile(t *testing.T, maxSize int64, maxBackups int, maxAge time.Duration) (*RotatingFile, *time.Time) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs", "santamon.log")
+	r, err := OpenRotatingFile(path, maxSize, maxBackups, maxAge)
+	if err != nil {
+		t.Fatalf("Failed to open rotating file: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func backupsOf(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	return matches
+}
+
+func TestRotatingFileRotatesAtSize(t *testing.T) {
+	r, _ := openTestRotatingFile(t, 10, 0, 0)
+
+	if _, err := r.Write([]byte("12345678\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if got := backupsOf(t, r.path); len(got) != 0 {
+		t.Fatalf("Rotated before reaching max size: %v", got)
+	}
+
+	if _, err := r.Write([]byte("abc\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	backups := backupsOf(t, r.path)
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+
+	old, _ := os.ReadFile(backups[0])
+	if string(old) != "12345678\n" {
+		t.Errorf("Backup content = %q", old)
+	}
+	cur, _ := os.ReadFile(r.path)
+	if string(cur) != "abc\n" {
+		t.Errorf("Current content = %q", cur)
+	}
+}
+
+func TestRotatingFileOversizedWrite(t *testing.T) {
+	r, _ := openTestRotatingFile(t, 4, 0, 0)
+
+	// A single write larger than the limit goes to an empty file rather than looping
+	if _, err := r.Write([]byte("longer than four\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if got := backupsOf(t, r.path); len(got) != 0 {
+		t.Errorf("Empty file should not be rotated: %v", got)
+	}
+}
+
+func TestRotatingFileMaxBackups(t *testing.T) {
+	r, now := openTestRotatingFile(t, 5, 2, 0)
+
+	for i := 0; i < 5; i++ {
+		*now = now.Add(time.Second)
+		if _, err := r.Write([]byte("line\n")); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	backups := backupsOf(t, r.path)
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	// The newest backups are kept
+	if !strings.HasSuffix(backups[1], "20250101T120005.000") {
+		t.Errorf("Newest backup missing: %v", backups)
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	r, now := openTestRotatingFile(t, 5, 0, time.Hour)
+
+	if _, err := r.Write([]byte("line\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if _, err := r.Write([]byte("line\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if got := backupsOf(t, r.path); len(got) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", got)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	if _, err := r.Write([]byte("line\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	backups := backupsOf(t, r.path)
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], "20250101T140000.000") {
+		t.Errorf("Expected only the fresh backup, got %v", backups)
+	}
+}
+
+func TestRotatingFileNoColorOutput(t *testing.T) {
+	r, _ := openTestRotatingFile(t, 1024*1024, 0, 0)
+	if err := Configure(Options{Output: r, NoColor: true}); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	t.Cleanup(func() { _ = Configure(Options{}) })
+
+	Warn("Disk almost full")
+	Signal("rule", "SM-001", "high", "Suspicious exec", "")
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	out := string(data)
+	if strings.Contains(out, "\x1b[") {
+		t.Errorf("Log file contains ANSI escapes: %q", out)
+	}
+	if !strings.Contains(out, "Disk almost full") || !strings.Contains(out, "SM-001") {
+		t.Errorf("Missing records: %q", out)
+	}
+	if _, err := time.Parse(time.RFC3339, strings.Fields(out)[0]); err != nil {
+		t.Errorf("Log lines should start with a timestamp: %q", out)
+	}
+}
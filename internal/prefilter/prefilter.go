@@ -0,0 +1,105 @@
+package prefilter
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// Prefilter decides which events reach rule evaluation at all. Per-kind
+// sampling keeps every Nth event of the kind, so the same input is always
+// filtered the same way; the CEL filter then applies to what is left.
+type Prefilter struct {
+	match func(*santapb.SantaMessage) (bool, error)
+	every map[string]uint64 // Keep one in N events of the kind; 0 drops the kind
+
+	mu      sync.Mutex
+	seen    map[string]uint64
+	dropped map[string]int // Since the last Dropped call
+}
+
+// New creates a prefilter from cfg. match evaluates cfg.Filter (see
+// rules.Engine.CompileFilter) and may be nil when no filter is set.
+func New(cfg config.PrefilterConfig, match func(*santapb.SantaMessage) (bool, error)) (*Prefilter, error) {
+	if cfg.Filter != "" && match == nil {
+		return nil, fmt.Errorf("prefilter.filter is set but was not compiled")
+	}
+	p := &Prefilter{
+		match:   match,
+		every:   make(map[string]uint64),
+		seen:    make(map[string]uint64),
+		dropped: make(map[string]int),
+	}
+	for kind, rate := range cfg.Sample {
+		if !slices.Contains(events.EventTypes, kind) {
+			return nil, fmt.Errorf("prefilter.sample: unknown event kind %q", kind)
+		}
+		if rate <= 0 {
+			p.every[kind] = 0
+			continue
+		}
+		p.every[kind] = uint64(max(1, math.Round(1/rate)))
+	}
+	return p, nil
+}
+
+// Keep reports whether msg should be evaluated. Events the filter cannot
+// evaluate are kept, so a bad expression never hides activity.
+func (p *Prefilter) Keep(msg *santapb.SantaMessage) bool {
+	kind := events.Kind(msg)
+	if !p.sample(kind) {
+		p.drop(kind)
+		return false
+	}
+	if p.match != nil {
+		if ok, err := p.match(msg); err == nil && !ok {
+			p.drop(kind)
+			return false
+		}
+	}
+	return true
+}
+
+// sample applies the sampling rate of kind
+func (p *Prefilter) sample(kind string) bool {
+	every, ok := p.every[kind]
+	if !ok {
+		return true
+	}
+	if every == 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seen[kind]++
+	return (p.seen[kind]-1)%every == 0
+}
+
+func (p *Prefilter) drop(kind string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dropped[kind]++
+}
+
+// Dropped returns how many events of each kind were dropped since the last
+// call, formatted as "close=120, fork=40" (empty when none were)
+func (p *Prefilter) Dropped() (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	parts := make([]string, 0, len(p.dropped))
+	for _, kind := range slices.Sorted(maps.Keys(p.dropped)) {
+		total += p.dropped[kind]
+		parts = append(parts, fmt.Sprintf("%s=%d", kind, p.dropped[kind]))
+	}
+	clear(p.dropped)
+	return total, strings.Join(parts, ", ")
+}
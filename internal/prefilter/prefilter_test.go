@@ -0,0 +1,90 @@
+package prefilter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+func fork() *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}}}
+}
+
+func closeEvent(path string) *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Close{Close: &santapb.Close{
+		Target: &santapb.FileInfo{Path: proto.String(path)},
+	}}}
+}
+
+func execution() *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+}
+
+func TestSampling(t *testing.T) {
+	p, err := New(config.PrefilterConfig{Sample: map[string]float64{"fork": 0, "close": 0.25}}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for range 3 {
+		if p.Keep(fork()) {
+			t.Error("Forks should be dropped")
+		}
+		if !p.Keep(execution()) {
+			t.Error("Kinds without a rate should be kept")
+		}
+	}
+	var pattern []bool
+	for range 8 {
+		pattern = append(pattern, p.Keep(closeEvent("/tmp/a")))
+	}
+	want := []bool{true, false, false, false, true, false, false, false}
+	for i := range want {
+		if pattern[i] != want[i] {
+			t.Fatalf("close sampling = %v, want %v", pattern, want)
+		}
+	}
+
+	total, summary := p.Dropped()
+	if total != 9 || summary != "close=6, fork=3" {
+		t.Errorf("Dropped() = %d, %q", total, summary)
+	}
+	if total, _ := p.Dropped(); total != 0 {
+		t.Errorf("Dropped() should reset, got %d", total)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	match := func(msg *santapb.SantaMessage) (bool, error) {
+		path := msg.GetClose().GetTarget().GetPath()
+		if path == "" {
+			return false, errors.New("no such field")
+		}
+		return !strings.HasPrefix(path, "/private/var/folders/"), nil
+	}
+	p, err := New(config.PrefilterConfig{Filter: `!event.close.target.path.startsWith("/private/var/folders/")`}, match)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if p.Keep(closeEvent("/private/var/folders/xy/T/cache")) {
+		t.Error("Event rejected by the filter should be dropped")
+	}
+	if !p.Keep(closeEvent("/Users/alice/notes.txt")) {
+		t.Error("Event matching the filter should be kept")
+	}
+	if !p.Keep(fork()) {
+		t.Error("Events the filter cannot evaluate should be kept")
+	}
+}
+
+func TestUnknownKind(t *testing.T) {
+	if _, err := New(config.PrefilterConfig{Sample: map[string]float64{"forks": 0}}, nil); err == nil || !strings.Contains(err.Error(), "forks") {
+		t.Errorf("Expected unknown kind error, got: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package recorder
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/0x4d31/santamon/internal/logutil"
+)
+
+var logger = logutil.For("recorder")
+
+// Options configures the event sampling recorder
+type Options struct {
+	Dir          string                           // Corpus directory
+	SampleRate   float64                          // Fraction of events to keep (0-1]
+	Filter       func(*santapb.SantaMessage) bool // Optional predicate events must satisfy
+	RedactFields []string                         // Extra field names to fully redact
+	MaxBytes     int64                            // Stop once the corpus reaches this size (0 = unlimited)
+	Rand         func() float64                   // Random source (default: math/rand)
+	Now          func() time.Time                 // Clock (default: time.Now)
+}
+
+// Recorder samples decoded events into daily JSON-lines files. Each line is a
+// protojson SantaMessage, so corpus files can be read back by the spool decoder.
+type Recorder struct {
+	opts     Options
+	redactor *Redactor
+	marshal  protojson.MarshalOptions
+
+	mu      sync.Mutex
+	file    *os.File
+	day     string
+	written int64
+	full    bool
+}
+
+// New creates a recorder writing into opts.Dir
+func New(opts Options) (*Recorder, error) {
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate must be in (0, 1], got %v", opts.SampleRate)
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Float64
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create corpus directory: %w", err)
+	}
+
+	size, err := dirSize(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to size corpus directory: %w", err)
+	}
+
+	return &Recorder{
+		opts:     opts,
+		redactor: NewRedactor(opts.RedactFields),
+		marshal:  protojson.MarshalOptions{UseProtoNames: true},
+		written:  size,
+	}, nil
+}
+
+// Record samples msg and, if selected, appends a redacted copy to the corpus.
+// It reports whether the event was written.
+func (r *Recorder) Record(msg *santapb.SantaMessage) (bool, error) {
+	if msg == nil || r.opts.Rand() >= r.opts.SampleRate {
+		return false, nil
+	}
+	if r.opts.Filter != nil && !r.opts.Filter(msg) {
+		return false, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.full {
+		return false, nil
+	}
+
+	line, err := r.marshal.Marshal(r.redactor.Redact(msg))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	line = append(line, '\n')
+
+	if r.opts.MaxBytes > 0 && r.written+int64(len(line)) > r.opts.MaxBytes {
+		r.full = true
+		logger.Warn("Event corpus reached %d bytes, recording stopped", r.opts.MaxBytes)
+		return false, nil
+	}
+
+	f, err := r.fileFor(r.opts.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := f.Write(line)
+	r.written += int64(n)
+	if err != nil {
+		return false, fmt.Errorf("failed to write event: %w", err)
+	}
+	return true, nil
+}
+
+// Close closes the current corpus file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// fileFor returns the corpus file for the given day, rotating at midnight
+func (r *Recorder) fileFor(now time.Time) (*os.File, error) {
+	day := now.Format("20060102")
+	if r.file != nil && r.day == day {
+		return r.file, nil
+	}
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+
+	path := filepath.Join(r.opts.Dir, "events-"+day+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corpus file: %w", err)
+	}
+	r.file = f
+	r.day = day
+	return f, nil
+}
+
+// dirSize sums the size of corpus files already in dir
+func dirSize(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
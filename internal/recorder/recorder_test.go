@@ -0,0 +1,206 @@
+package recorder
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func testExecution(path string) *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		MachineId:       proto.String("C02ABC123"),
+		BootSessionUuid: proto.String("boot-123"),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Decision: santapb.Execution_DECISION_ALLOW.Enum(),
+				Target: &santapb.ProcessInfo{
+					EffectiveUser: &santapb.UserInfo{Uid: proto.Int32(501), Name: proto.String("alice")},
+					Executable:    &santapb.FileInfo{Path: proto.String(path)},
+				},
+				Args: [][]byte{[]byte(path), []byte("--mail=alice@example.com")},
+			},
+		},
+	}
+}
+
+func readLines(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to list corpus: %v", err)
+	}
+	var lines []string
+	for _, m := range matches {
+		f, err := os.Open(m)
+		if err != nil {
+			t.Fatalf("Failed to open corpus file: %v", err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		_ = f.Close()
+	}
+	return lines
+}
+
+func TestRecordRedactsPII(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{Dir: dir, SampleRate: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	msg := testExecution("/Users/alice/Downloads/tool")
+	ok, err := r.Record(msg)
+	if err != nil || !ok {
+		t.Fatalf("Record() = %v, %v; want true, nil", ok, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := readLines(t, dir)
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 recorded event, got %d", len(lines))
+	}
+	for _, secret := range []string{"alice", "C02ABC123"} {
+		if strings.Contains(lines[0], secret) {
+			t.Errorf("Recorded event still contains %q: %s", secret, lines[0])
+		}
+	}
+
+	// Corpus lines must round-trip as Santa messages
+	var got santapb.SantaMessage
+	if err := protojson.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("Recorded line is not a SantaMessage: %v", err)
+	}
+	if p := got.GetExecution().GetTarget().GetExecutable().GetPath(); p != "/Users/REDACTED/Downloads/tool" {
+		t.Errorf("Path = %q, want home directory redacted", p)
+	}
+	if string(got.GetExecution().GetArgs()[1]) != "--mail=REDACTED" {
+		t.Errorf("Args not redacted: %q", got.GetExecution().GetArgs()[1])
+	}
+
+	// The caller's message must be left intact
+	if msg.GetMachineId() != "C02ABC123" {
+		t.Error("Record() modified the original message")
+	}
+}
+
+func TestRecordSamplingAndFilter(t *testing.T) {
+	dir := t.TempDir()
+	draws := []float64{0.05, 0.5, 0.01}
+	r, err := New(Options{
+		Dir:        dir,
+		SampleRate: 0.1,
+		Rand: func() float64 {
+			v := draws[0]
+			draws = draws[1:]
+			return v
+		},
+		Filter: func(msg *santapb.SantaMessage) bool {
+			return !strings.HasPrefix(msg.GetExecution().GetTarget().GetExecutable().GetPath(), "/usr/")
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	results := []bool{}
+	for _, path := range []string{"/tmp/a", "/tmp/b", "/usr/bin/c"} {
+		ok, err := r.Record(testExecution(path))
+		if err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		results = append(results, ok)
+	}
+
+	// Sampled in, sampled out, filtered out
+	want := []bool{true, false, false}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("Record(%d) = %v, want %v", i, results[i], want[i])
+		}
+	}
+}
+
+func TestRecordMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{Dir: dir, SampleRate: 1, MaxBytes: 600})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	written := 0
+	for i := 0; i < 20; i++ {
+		ok, err := r.Record(testExecution("/tmp/x"))
+		if err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if ok {
+			written++
+		}
+	}
+	if written == 0 || written == 20 {
+		t.Fatalf("Expected the size cap to stop recording part way, wrote %d", written)
+	}
+
+	// A new recorder counts existing corpus files toward the cap
+	r2, err := New(Options{Dir: dir, SampleRate: 1, MaxBytes: 600})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = r2.Close() }()
+	if ok, _ := r2.Record(testExecution("/tmp/x")); ok {
+		t.Error("Expected recording to remain stopped once the corpus is full")
+	}
+}
+
+func TestRecordRotatesDaily(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 1, 23, 59, 0, 0, time.UTC)
+	r, err := New(Options{Dir: dir, SampleRate: 1, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	_, _ = r.Record(testExecution("/tmp/a"))
+	now = now.Add(2 * time.Minute)
+	_, _ = r.Record(testExecution("/tmp/b"))
+
+	for _, name := range []string{"events-20250101.jsonl", "events-20250102.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected corpus file %s: %v", name, err)
+		}
+	}
+}
+
+func TestNewRejectsInvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, -0.5, 1.5} {
+		if _, err := New(Options{Dir: t.TempDir(), SampleRate: rate}); err == nil {
+			t.Errorf("Expected error for sample rate %v", rate)
+		}
+	}
+}
+
+func TestRedactorExtraFields(t *testing.T) {
+	r := NewRedactor([]string{"boot_session_uuid"})
+	out := r.Redact(testExecution("/Users/Shared/tool"))
+	if out.GetBootSessionUuid() != redacted {
+		t.Errorf("BootSessionUuid = %q, want redacted", out.GetBootSessionUuid())
+	}
+	if p := out.GetExecution().GetTarget().GetExecutable().GetPath(); p != "/Users/Shared/tool" {
+		t.Errorf("Shared paths should be kept, got %q", p)
+	}
+}
@@ -0,0 +1,135 @@
+package recorder
+
+import (
+	"regexp"
+	"strings"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const redacted = "REDACTED"
+
+var (
+	// homeDirPattern matches the user component of macOS home directory paths
+	homeDirPattern = regexp.MustCompile(`/Users/[^/\s"']+`)
+	// emailPattern matches email addresses embedded anywhere in a string
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+	// defaultRedactFields are fully redacted regardless of content
+	defaultRedactFields = []string{
+		"santa.telemetry.v1.UserInfo.name",
+		"santa.telemetry.v1.SantaMessage.machine_id",
+	}
+
+	// sharedHomeDirs are /Users entries that do not identify a person
+	sharedHomeDirs = map[string]bool{
+		"/Users/Shared": true,
+	}
+)
+
+// Redactor strips PII from Santa messages before they are written to disk
+type Redactor struct {
+	fields map[protoreflect.Name]bool     // Short field names to redact (from config)
+	full   map[protoreflect.FullName]bool // Fully qualified field names to redact
+}
+
+// NewRedactor creates a redactor that also fully redacts the given field names
+// (proto short names such as "hostname" or fully qualified names)
+func NewRedactor(extraFields []string) *Redactor {
+	r := &Redactor{
+		fields: make(map[protoreflect.Name]bool),
+		full:   make(map[protoreflect.FullName]bool),
+	}
+	for _, f := range defaultRedactFields {
+		r.full[protoreflect.FullName(f)] = true
+	}
+	for _, f := range extraFields {
+		if strings.Contains(f, ".") {
+			r.full[protoreflect.FullName(f)] = true
+		} else {
+			r.fields[protoreflect.Name(f)] = true
+		}
+	}
+	return r
+}
+
+// Redact returns a redacted copy of msg; the original is not modified
+func (r *Redactor) Redact(msg *santapb.SantaMessage) *santapb.SantaMessage {
+	clone := proto.Clone(msg).(*santapb.SantaMessage)
+	r.redactMessage(clone.ProtoReflect())
+	return clone
+}
+
+// RedactString applies pattern-based redaction to free text
+func (r *Redactor) RedactString(s string) string {
+	s = homeDirPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if sharedHomeDirs[m] {
+			return m
+		}
+		return "/Users/" + redacted
+	})
+	return emailPattern.ReplaceAllString(s, redacted)
+}
+
+func (r *Redactor) redactMessage(m protoreflect.Message) {
+	// Collect first; setting fields while ranging is not allowed
+	type field struct {
+		fd protoreflect.FieldDescriptor
+		v  protoreflect.Value
+	}
+	var fields []field
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fields = append(fields, field{fd, v})
+		return true
+	})
+
+	for _, f := range fields {
+		fd, v := f.fd, f.v
+		full := r.full[fd.FullName()] || r.fields[fd.Name()]
+
+		switch {
+		case fd.IsMap():
+			// Santa telemetry has no PII-bearing maps; leave them untouched
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				if nv, ok := r.redactValue(fd, list.Get(i), full); ok {
+					list.Set(i, nv)
+				}
+			}
+		default:
+			if nv, ok := r.redactValue(fd, v, full); ok {
+				m.Set(fd, nv)
+			}
+		}
+	}
+}
+
+// redactValue redacts a single (non-list) value, returning the replacement if it changed
+func (r *Redactor) redactValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, full bool) (protoreflect.Value, bool) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		r.redactMessage(v.Message())
+		return v, false
+	case protoreflect.StringKind:
+		if full {
+			return protoreflect.ValueOfString(redacted), true
+		}
+		s := v.String()
+		if out := r.RedactString(s); out != s {
+			return protoreflect.ValueOfString(out), true
+		}
+	case protoreflect.BytesKind:
+		// Execution args and envs are raw bytes that often contain paths
+		if full {
+			return protoreflect.ValueOfBytes([]byte(redacted)), true
+		}
+		b := v.Bytes()
+		if out := r.RedactString(string(b)); out != string(b) {
+			return protoreflect.ValueOfBytes([]byte(out)), true
+		}
+	}
+	return v, false
+}
@@ -0,0 +1,215 @@
+package reputation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/ttlcache"
+)
+
+// ErrRateLimited is returned when a lookup would exceed the configured rate
+// limit, or the service asked to back off
+var ErrRateLimited = errors.New("reputation lookup rate limited")
+
+// maxCacheEntries bounds the result cache; expired entries are dropped first
+const maxCacheEntries = 10000
+
+// Result is the antivirus verdict summary for a hash
+type Result struct {
+	Malicious int // Engines that flagged the file
+	Total     int // Engines that scanned it
+}
+
+// Provider looks up the reputation of a SHA-256 hash. Lookup returns nil
+// without an error when the service does not know the hash.
+type Provider interface {
+	Lookup(ctx context.Context, sha256 string) (*Result, error)
+}
+
+// Client queries VirusTotal or an internal reputation service, caching
+// results and limiting lookups per minute
+type Client struct {
+	provider string
+	url      string
+	apiKey   string
+	timeout  time.Duration
+	client   *http.Client
+	limiter  *limiter
+
+	now   func() time.Time
+	cache *ttlcache.Cache[*Result]
+}
+
+// New creates a client for the configured provider
+func New(cfg config.ReputationConfig) *Client {
+	return &Client{
+		provider: cfg.Provider,
+		url:      strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:   cfg.APIKey,
+		timeout:  cfg.Timeout,
+		client:   &http.Client{},
+		limiter:  newLimiter(cfg.RateLimit),
+		now:      time.Now,
+		cache:    ttlcache.New[*Result](cfg.CacheTTL, maxCacheEntries),
+	}
+}
+
+// Lookup returns the cached result or queries the service
+func (c *Client) Lookup(ctx context.Context, sha256 string) (*Result, error) {
+	sha256 = strings.ToLower(sha256)
+	if result, ok := c.cached(sha256); ok {
+		return result, nil
+	}
+	if !c.limiter.allow(c.now()) {
+		return nil, ErrRateLimited
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	result, err := c.fetch(ctx, sha256)
+	if err != nil {
+		// Errors are not cached so a transient failure is retried on the next signal
+		return nil, err
+	}
+	c.store(sha256, result)
+	return result, nil
+}
+
+// fetch queries <url>/<sha256>
+func (c *Client) fetch(ctx context.Context, sha256 string) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/"+sha256, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		if c.provider == "virustotal" {
+			req.Header.Set("x-apikey", c.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reputation request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusTooManyRequests:
+		// Quota exhausted: stop asking until the next window
+		c.limiter.exhaust(c.now())
+		return nil, ErrRateLimited
+	default:
+		return nil, fmt.Errorf("reputation service returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reputation response: %w", err)
+	}
+	if c.provider == "virustotal" {
+		return parseVirusTotal(data)
+	}
+	return parseHTTP(data)
+}
+
+// parseVirusTotal reads the last analysis stats of a v3 file report. Engines
+// that failed or do not support the file type are not counted.
+func parseVirusTotal(data []byte) (*Result, error) {
+	var report struct {
+		Data struct {
+			Attributes struct {
+				Stats struct {
+					Malicious  int `json:"malicious"`
+					Suspicious int `json:"suspicious"`
+					Undetected int `json:"undetected"`
+					Harmless   int `json:"harmless"`
+				} `json:"last_analysis_stats"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse VirusTotal report: %w", err)
+	}
+	st := report.Data.Attributes.Stats
+	return &Result{
+		Malicious: st.Malicious,
+		Total:     st.Malicious + st.Suspicious + st.Undetected + st.Harmless,
+	}, nil
+}
+
+// parseHTTP reads an internal service response: {"malicious": 3, "total": 70}
+func parseHTTP(data []byte) (*Result, error) {
+	var doc struct {
+		Malicious *int `json:"malicious"`
+		Total     *int `json:"total"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse reputation response: %w", err)
+	}
+	if doc.Malicious == nil || doc.Total == nil {
+		return nil, fmt.Errorf("reputation response must have malicious and total")
+	}
+	return &Result{Malicious: *doc.Malicious, Total: *doc.Total}, nil
+}
+
+func (c *Client) cached(sha256 string) (*Result, bool) {
+	return c.cache.Get(sha256, c.now())
+}
+
+func (c *Client) store(sha256 string, result *Result) {
+	c.cache.Put(sha256, result, c.now())
+}
+
+// limiter allows a fixed number of lookups per minute
+type limiter struct {
+	perMinute int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	used        int
+}
+
+func newLimiter(perMinute int) *limiter {
+	return &limiter{perMinute: perMinute}
+}
+
+// allow reports whether another lookup fits in the current minute
+func (l *limiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.windowStart) >= time.Minute {
+		l.windowStart, l.used = now, 0
+	}
+	if l.used >= l.perMinute {
+		return false
+	}
+	l.used++
+	return true
+}
+
+// exhaust uses up the rest of the current minute
+func (l *limiter) exhaust(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.windowStart) >= time.Minute {
+		l.windowStart = now
+	}
+	l.used = l.perMinute
+}
@@ -0,0 +1,144 @@
+package reputation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+const (
+	knownHash   = "6f3c2a5b4e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b"
+	unknownHash = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func TestVirusTotal(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("x-apikey") != "vt-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/files/" + knownHash:
+			_, _ = w.Write([]byte(`{"data": {"attributes": {"last_analysis_stats": {
+				"malicious": 3, "suspicious": 1, "undetected": 60, "harmless": 0, "type-unsupported": 7, "failure": 1}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := New(config.ReputationConfig{
+		Provider:  "virustotal",
+		URL:       server.URL + "/api/v3/files/",
+		APIKey:    "vt-key",
+		CacheTTL:  time.Hour,
+		RateLimit: 10,
+	})
+	ctx := context.Background()
+
+	result, err := c.Lookup(ctx, strings.ToUpper(knownHash))
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if result == nil || result.Malicious != 3 || result.Total != 64 {
+		t.Errorf("Lookup() = %+v, want 3/64", result)
+	}
+	if result, err := c.Lookup(ctx, unknownHash); err != nil || result != nil {
+		t.Errorf("Lookup(unknown) = %+v, %v; want nil, nil", result, err)
+	}
+
+	// Hits and misses are cached
+	_, _ = c.Lookup(ctx, knownHash)
+	_, _ = c.Lookup(ctx, unknownHash)
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+
+	// Until the TTL expires
+	c.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, _ = c.Lookup(ctx, knownHash)
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected a new request after the TTL, got %d requests", n)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/v1/hashes/") {
+		case knownHash:
+			_, _ = w.Write([]byte(`{"malicious": 0, "total": 12}`))
+		case unknownHash:
+			_, _ = w.Write([]byte(`{"verdict": "clean"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	c := New(config.ReputationConfig{Provider: "http", URL: server.URL + "/v1/hashes", APIKey: "token", CacheTTL: time.Hour, RateLimit: 10})
+	ctx := context.Background()
+
+	if result, err := c.Lookup(ctx, knownHash); err != nil || result == nil || result.Malicious != 0 || result.Total != 12 {
+		t.Errorf("Lookup() = %+v, %v; want 0/12", result, err)
+	}
+	if _, err := c.Lookup(ctx, unknownHash); err == nil || !strings.Contains(err.Error(), "malicious and total") {
+		t.Errorf("Expected malformed response error, got %v", err)
+	}
+	if _, err := c.Lookup(ctx, strings.Repeat("a", 64)); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("Expected status error, got %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var throttle atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if throttle.Load() {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := New(config.ReputationConfig{Provider: "http", URL: server.URL, CacheTTL: time.Hour, RateLimit: 2})
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := range 2 {
+		if _, err := c.Lookup(ctx, strings.Repeat(string(rune('a'+i)), 64)); err != nil {
+			t.Fatalf("Lookup %d failed: %v", i, err)
+		}
+	}
+	if _, err := c.Lookup(ctx, strings.Repeat("c", 64)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+	// Cached results don't count against the limit
+	if _, err := c.Lookup(ctx, strings.Repeat("a", 64)); err != nil {
+		t.Errorf("Cached lookup failed: %v", err)
+	}
+
+	// A new minute allows lookups again, until the service pushes back
+	now = now.Add(time.Minute)
+	throttle.Store(true)
+	if _, err := c.Lookup(ctx, strings.Repeat("c", 64)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited on 429, got %v", err)
+	}
+	throttle.Store(false)
+	if _, err := c.Lookup(ctx, strings.Repeat("d", 64)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the rest of the minute to be blocked after 429, got %v", err)
+	}
+}
@@ -0,0 +1,405 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/logutil"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/santarule"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+var logger = logutil.For("response")
+
+// Action types of response.actions
+const (
+	TypeCommand  = "command"
+	TypeFile     = "file"
+	TypeSantactl = "santactl"
+
+	// TypeSyncServer pushes a block rule to the sync server (see BlockTag);
+	// it is not a catalog type
+	TypeSyncServer = "sync_server"
+)
+
+// Audit statuses
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	StatusDropped = "dropped"
+	StatusDryRun  = "dry_run"
+)
+
+const (
+	santactlPath = "/usr/local/bin/santactl"
+	queueSize    = 256  // Pending action runs before new ones are dropped
+	workers      = 4    // Action runs executed concurrently
+	maxOutput    = 4096 // Bytes of command output kept in the audit log
+)
+
+// systemPrefixes hold SIP-protected platform binaries. Response actions
+// never block them: a rule matching /bin/sh must not take the shell away.
+var systemPrefixes = []string{"/System/", "/bin/", "/sbin/", "/usr/bin/", "/usr/sbin/", "/usr/libexec/"}
+
+// placeholder matches {{field}} in command arguments
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// AuditEntry is one line of the audit log, written for every action a
+// signal triggers, whether it ran or not
+type AuditEntry struct {
+	TS         time.Time       `json:"ts"`
+	Action     string          `json:"action"`
+	Type       string          `json:"type"`
+	SignalID   string          `json:"signal_id"`
+	RuleID     string          `json:"rule_id"`
+	Severity   string          `json:"severity"`
+	Argv       []string        `json:"argv,omitempty"`
+	Path       string          `json:"path,omitempty"`
+	URL        string          `json:"url,omitempty"`
+	SantaRule  *santarule.Rule `json:"santa_rule,omitempty"` // Rule pushed to the sync server
+	Status     string          `json:"status"`               // ok, failed, skipped, dropped or dry_run
+	Error      string          `json:"error,omitempty"`
+	Output     string          `json:"output,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// runFunc runs a program with the signal JSON on stdin and returns its
+// combined output; tests replace it
+type runFunc func(ctx context.Context, argv []string, stdin []byte) ([]byte, error)
+
+// job is one action run for one signal
+type job struct {
+	action config.ResponseActionConfig
+	signal *state.Signal
+}
+
+// Responder runs the response actions rules declare for their signals. Only
+// actions of the configured catalog run; each one has a timeout, and every
+// run is recorded in the audit log. Actions run in the background so a
+// slow script never stalls detection.
+type Responder struct {
+	actions map[string]config.ResponseActionConfig
+	timeout time.Duration
+	run     runFunc
+	queue   chan job
+	sync    *syncServer
+
+	byRule atomic.Pointer[map[string][]string]
+
+	auditMu sync.Mutex
+	audit   *os.File
+
+	ran, failed, dropped atomic.Int64
+}
+
+// New creates the responder and opens the audit log, or returns nil when
+// response is disabled
+func New(cfg config.ResponseConfig) (*Responder, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	audit, err := os.OpenFile(cfg.AuditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open response audit log: %w", err)
+	}
+	r := &Responder{
+		actions: make(map[string]config.ResponseActionConfig, len(cfg.Actions)),
+		timeout: cfg.Timeout,
+		run:     runCommand,
+		queue:   make(chan job, queueSize),
+		sync:    newSyncServer(cfg.SyncServer),
+		audit:   audit,
+	}
+	for _, a := range cfg.Actions {
+		r.actions[a.Name] = a
+	}
+	return r, nil
+}
+
+// SetRules sets the actions of each rule (see rules.RulesConfig.Actions).
+// References to actions missing from the catalog are dropped and returned
+// as errors, one per rule and action.
+func (r *Responder) SetRules(byRule map[string][]string) []error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	known := make(map[string][]string, len(byRule))
+	for ruleID, names := range byRule {
+		for _, name := range names {
+			if _, ok := r.actions[name]; !ok {
+				errs = append(errs, fmt.Errorf("rule %s: unknown response action %q", ruleID, name))
+				continue
+			}
+			known[ruleID] = append(known[ruleID], name)
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	r.byRule.Store(&known)
+	return errs
+}
+
+// Trigger queues the actions of the signal's rule that its severity
+// reaches, and the sync server push for rules tagged respond:block, and
+// lists them in the signal context as response_actions. Call it once the
+// signal is final, right before it is enqueued. A nil responder does
+// nothing.
+func (r *Responder) Trigger(sig *state.Signal) {
+	if r == nil {
+		return
+	}
+	var triggered []config.ResponseActionConfig
+	if byRule := r.byRule.Load(); byRule != nil {
+		for _, name := range (*byRule)[sig.RuleID] {
+			action := r.actions[name]
+			if action.MinSeverity == "" || rules.SeverityAtLeast(sig.Severity, action.MinSeverity) {
+				triggered = append(triggered, action)
+			}
+		}
+	}
+	if r.sync.applies(sig) {
+		triggered = append(triggered, r.sync.action())
+	}
+	if len(triggered) == 0 {
+		return
+	}
+	names := make([]string, len(triggered))
+	for i, action := range triggered {
+		names[i] = action.Name
+	}
+	sig.Context["response_actions"] = names
+
+	// Actions get their own copy; the caller keeps enriching and shipping sig
+	snapshot := *sig
+	snapshot.Context = maps.Clone(sig.Context)
+	for _, action := range triggered {
+		select {
+		case r.queue <- job{action: action, signal: &snapshot}:
+		default:
+			r.dropped.Add(1)
+			logger.Warn("Response queue full, dropping action %s for signal %s", action.Name, sig.ID)
+			r.record(r.entry(action, sig, StatusDropped, "response queue full"))
+		}
+	}
+}
+
+// Run executes queued actions until ctx is done, then closes the audit log.
+// Actions still running are cancelled with ctx.
+func (r *Responder) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-r.queue:
+					r.execute(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	logger.Info("Response metrics: ran=%d, failed=%d, dropped=%d", r.ran.Load(), r.failed.Load(), r.dropped.Load())
+	r.auditMu.Lock()
+	defer r.auditMu.Unlock()
+	if err := r.audit.Close(); err != nil {
+		logger.Warn("Failed to close response audit log: %v", err)
+	}
+	return ctx.Err()
+}
+
+// execute runs one action and records it in the audit log
+func (r *Responder) execute(ctx context.Context, j job) {
+	timeout := j.action.Timeout
+	if timeout == 0 {
+		timeout = r.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	entry := r.entry(j.action, j.signal, StatusOK, "")
+	out, err := r.perform(ctx, j.action, j.signal, &entry)
+	entry.DurationMS = time.Since(start).Milliseconds()
+	if len(out) > maxOutput {
+		out = out[:maxOutput]
+	}
+	entry.Output = strings.TrimSpace(string(out))
+
+	switch {
+	case errors.Is(err, errSkip):
+		// perform set the reason
+	case err != nil:
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			entry.Error = fmt.Sprintf("timed out after %s", timeout)
+		}
+		r.failed.Add(1)
+		logger.Warn("Response action %s for signal %s (%s) failed: %s", j.action.Name, j.signal.ID, j.signal.RuleID, entry.Error)
+	default:
+		r.ran.Add(1)
+		logger.Info("Response action %s ran for signal %s (%s)", j.action.Name, j.signal.ID, j.signal.RuleID)
+	}
+	r.record(entry)
+}
+
+// errSkip reports an action that did not apply to the signal
+var errSkip = errors.New("skipped")
+
+// perform runs an action of any type, filling in what it ran on entry
+func (r *Responder) perform(ctx context.Context, a config.ResponseActionConfig, sig *state.Signal, entry *AuditEntry) ([]byte, error) {
+	switch a.Type {
+	case TypeFile:
+		entry.Path = a.Path
+		return nil, appendSignal(a.Path, sig)
+	case TypeSyncServer:
+		return r.sync.push(ctx, sig, entry)
+	case TypeSantactl:
+		argv, reason := santactlArgs(a, sig)
+		if argv == nil {
+			entry.Status, entry.Error = StatusSkipped, reason
+			logger.Verbose("Response action %s skipped for signal %s: %s", a.Name, sig.ID, reason)
+			return nil, errSkip
+		}
+		entry.Argv = argv
+		return r.run(ctx, argv, nil)
+	default:
+		argv := Expand(a.Command, sig)
+		entry.Argv = argv
+		data, err := json.Marshal(sig)
+		if err != nil {
+			return nil, err
+		}
+		return r.run(ctx, argv, data)
+	}
+}
+
+// santactlArgs returns the santactl command blocking the signal's target,
+// or nil and the reason it cannot be blocked
+func santactlArgs(a config.ResponseActionConfig, sig *state.Signal) ([]string, string) {
+	hash, _ := sig.Context["target_sha256"].(string)
+	if hash == "" {
+		return nil, "signal has no target_sha256"
+	}
+	path, _ := sig.Context["target_path"].(string)
+	if isSystemPath(path) {
+		return nil, "target is a system binary: " + path
+	}
+	if a.Policy == "silent_block" {
+		return []string{santactlPath, "rule", "--silent-block", "--identifier", hash}, ""
+	}
+	return []string{santactlPath, "rule", "--block", "--identifier", hash, "--message", "Blocked by santamon: " + sig.Title}, ""
+}
+
+// Expand replaces {{field}} in each argument with the signal's field: one of
+// signal_id, rule_id, severity, title and host_id, or a context field.
+// Unknown fields and context values that are not scalars expand to "".
+// Arguments are passed to the program as they are, never to a shell.
+func Expand(args []string, sig *state.Signal) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = placeholder.ReplaceAllStringFunc(arg, func(m string) string {
+			return field(sig, placeholder.FindStringSubmatch(m)[1])
+		})
+	}
+	return out
+}
+
+func field(sig *state.Signal, name string) string {
+	switch name {
+	case "signal_id":
+		return sig.ID
+	case "rule_id":
+		return sig.RuleID
+	case "severity":
+		return sig.Severity
+	case "title":
+		return sig.Title
+	case "host_id":
+		return sig.HostID
+	}
+	switch v := sig.Context[name].(type) {
+	case string:
+		return v
+	case bool, int, int32, int64, uint32, uint64, float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// appendSignal appends the signal to path as a JSON line
+func appendSignal(path string, sig *state.Signal) error {
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runCommand runs argv directly, without a shell
+func runCommand(ctx context.Context, argv []string, stdin []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	return cmd.CombinedOutput()
+}
+
+// entry starts the audit entry of an action run
+func (r *Responder) entry(a config.ResponseActionConfig, sig *state.Signal, status, errMsg string) AuditEntry {
+	return AuditEntry{
+		TS:       time.Now().UTC(),
+		Action:   a.Name,
+		Type:     a.Type,
+		SignalID: sig.ID,
+		RuleID:   sig.RuleID,
+		Severity: sig.Severity,
+		Status:   status,
+		Error:    errMsg,
+	}
+}
+
+// record appends an entry to the audit log
+func (r *Responder) record(entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logger.Warn("Failed to encode response audit entry: %v", err)
+		return
+	}
+	r.auditMu.Lock()
+	defer r.auditMu.Unlock()
+	if _, err := r.audit.Write(append(data, '\n')); err != nil {
+		logger.Warn("Failed to write response audit log: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package response
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+const hash = "5f70bf18a086007016e948b04aed3b82103a36bea41755b6cddfaf10ace3c6ef"
+
+func testSignal(severity string) *state.Signal {
+	return &state.Signal{
+		ID:       "abc123",
+		HostID:   "host-1",
+		RuleID:   "R-1",
+		Severity: severity,
+		Title:    "Unsigned tool",
+		Context: map[string]any{
+			"target_path":   "/Users/alice/Downloads/tool",
+			"target_sha256": hash,
+			"event_count":   3,
+			"grouped_by":    map[string]any{"user": "alice"},
+		},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	got := Expand([]string{"--rule={{rule_id}}", "{{ target_path }}", "{{event_count}}", "{{grouped_by}}", "{{missing}}", "plain"}, testSignal("high"))
+	want := []string{"--rule=R-1", "/Users/alice/Downloads/tool", "3", "", "", "plain"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+}
+
+func TestSantactlArgs(t *testing.T) {
+	block := config.ResponseActionConfig{Name: "block", Type: TypeSantactl, Policy: "block"}
+	argv, _ := santactlArgs(block, testSignal("critical"))
+	want := []string{santactlPath, "rule", "--block", "--identifier", hash, "--message", "Blocked by santamon: Unsigned tool"}
+	if !slices.Equal(argv, want) {
+		t.Errorf("santactlArgs() = %q, want %q", argv, want)
+	}
+
+	silent := block
+	silent.Policy = "silent_block"
+	if argv, _ := santactlArgs(silent, testSignal("critical")); !slices.Contains(argv, "--silent-block") {
+		t.Errorf("silent_block should use --silent-block, got %q", argv)
+	}
+
+	system := testSignal("critical")
+	system.Context["target_path"] = "/bin/sh"
+	if argv, reason := santactlArgs(block, system); argv != nil || !strings.Contains(reason, "system binary") {
+		t.Errorf("System binaries must not be blocked, got %q (%s)", argv, reason)
+	}
+
+	noHash := testSignal("critical")
+	delete(noHash.Context, "target_sha256")
+	if argv, _ := santactlArgs(block, noHash); argv != nil {
+		t.Errorf("Signals without a target hash cannot be blocked, got %q", argv)
+	}
+}
+
+// fakeRunner records the commands it is asked to run
+type fakeRunner struct {
+	mu    sync.Mutex
+	argv  [][]string
+	stdin [][]byte
+}
+
+func (f *fakeRunner) run(ctx context.Context, argv []string, stdin []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.argv = append(f.argv, argv)
+	f.stdin = append(f.stdin, stdin)
+	return []byte("done\n"), nil
+}
+
+func readAudit(t *testing.T, path string, want int) []AuditEntry {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var entries []AuditEntry
+		if f, err := os.Open(path); err == nil {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var e AuditEntry
+				if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+					t.Fatalf("Audit line is not valid JSON: %v", err)
+				}
+				entries = append(entries, e)
+			}
+			_ = f.Close()
+		}
+		if len(entries) >= want || time.Now().After(deadline) {
+			return entries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestResponder(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.jsonl")
+	hitsPath := filepath.Join(dir, "hits.jsonl")
+	r, err := New(config.ResponseConfig{
+		Enabled:  true,
+		AuditLog: auditPath,
+		Timeout:  time.Second,
+		Actions: []config.ResponseActionConfig{
+			{Name: "notify", Type: TypeCommand, Command: []string{"/usr/local/bin/notify", "{{rule_id}}"}},
+			{Name: "record", Type: TypeFile, Path: hitsPath},
+			{Name: "block", Type: TypeSantactl, Policy: "block", MinSeverity: "critical"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	runner := &fakeRunner{}
+	r.run = runner.run
+
+	errs := r.SetRules(map[string][]string{"R-1": {"notify", "record", "block", "isolate"}})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `unknown response action "isolate"`) {
+		t.Errorf("SetRules() = %v, want the unknown action", errs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	// block needs a critical signal
+	high := testSignal("high")
+	r.Trigger(high)
+	if got := high.Context["response_actions"]; !slices.Equal(got.([]string), []string{"notify", "record"}) {
+		t.Errorf("response_actions = %v, want [notify record]", got)
+	}
+	entries := readAudit(t, auditPath, 2)
+	if len(entries) != 2 {
+		t.Fatalf("Audit log has %d entries, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if e.Status != StatusOK || e.SignalID != "abc123" {
+			t.Errorf("Unexpected audit entry: %+v", e)
+		}
+	}
+
+	r.Trigger(testSignal("critical"))
+	entries = readAudit(t, auditPath, 5)
+	if len(entries) != 5 {
+		t.Fatalf("Audit log has %d entries, want 5", len(entries))
+	}
+
+	cancel()
+	<-done
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if !slices.ContainsFunc(runner.argv, func(argv []string) bool { return slices.Equal(argv, []string{"/usr/local/bin/notify", "R-1"}) }) {
+		t.Errorf("notify not run with the expanded arguments: %q", runner.argv)
+	}
+	if !slices.ContainsFunc(runner.argv, func(argv []string) bool { return argv[0] == santactlPath }) {
+		t.Errorf("santactl not run for the critical signal: %q", runner.argv)
+	}
+	hits, err := os.ReadFile(hitsPath)
+	if err != nil || strings.Count(string(hits), "\n") != 2 {
+		t.Errorf("File action should append one line per signal, got %q (%v)", hits, err)
+	}
+
+	untracked := testSignal("critical")
+	untracked.RuleID = "R-2"
+	r.Trigger(untracked)
+	if _, ok := untracked.Context["response_actions"]; ok {
+		t.Error("Rules without actions should trigger nothing")
+	}
+
+	var disabled *Responder
+	disabled.Trigger(high) // nil-safe
+}
+
+func TestTimeout(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	r, err := New(config.ResponseConfig{
+		Enabled:  true,
+		AuditLog: auditPath,
+		Timeout:  time.Second,
+		Actions:  []config.ResponseActionConfig{{Name: "slow", Type: TypeCommand, Command: []string{"/bin/sleep"}, Timeout: 20 * time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	r.run = func(ctx context.Context, argv []string, stdin []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	r.SetRules(map[string][]string{"R-1": {"slow"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = r.Run(ctx) }()
+
+	r.Trigger(testSignal("low"))
+	entries := readAudit(t, auditPath, 1)
+	if len(entries) != 1 || entries[0].Status != StatusFailed || !strings.Contains(entries[0].Error, "timed out") {
+		t.Errorf("Expected a timed out audit entry, got %+v", entries)
+	}
+}
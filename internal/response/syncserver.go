@@ -0,0 +1,177 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/santarule"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// BlockTag marks rules whose signals push a block rule to the sync server
+const BlockTag = "respond:block"
+
+// maxPushed bounds the rules remembered as pushed; past it the memory is
+// reset and a rule may be pushed again
+const maxPushed = 10000
+
+// pushRequest is the body POSTed to the sync server. Rules use the format of
+// Santa's sync protocol and santactl rule --import.
+type pushRequest struct {
+	Rules    []santarule.Rule `json:"rules"`
+	HostID   string           `json:"host_id"`
+	SignalID string           `json:"signal_id"`
+	RuleID   string           `json:"rule_id"`
+	Title    string           `json:"title"`
+}
+
+// syncServer pushes block rules to a Santa sync server
+type syncServer struct {
+	cfg    config.SyncServerConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	pushed map[string]bool // Rule type and identifier of rules already pushed
+}
+
+func newSyncServer(cfg config.SyncServerConfig) *syncServer {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &syncServer{cfg: cfg, client: &http.Client{}, pushed: make(map[string]bool)}
+}
+
+// applies reports whether a signal pushes a block rule: its rule is tagged
+// respond:block and it is severe enough
+func (s *syncServer) applies(sig *state.Signal) bool {
+	if s == nil || !slices.Contains(sig.Tags, BlockTag) {
+		return false
+	}
+	return s.cfg.MinSeverity == "" || rules.SeverityAtLeast(sig.Severity, s.cfg.MinSeverity)
+}
+
+// action is the catalog entry sync server pushes run as
+func (s *syncServer) action() config.ResponseActionConfig {
+	return config.ResponseActionConfig{Name: TypeSyncServer, Type: TypeSyncServer, Timeout: s.cfg.Timeout}
+}
+
+// BlockRule returns the Santa rule blocking the signal's target, or nil and
+// the reason there is none. The rule suggested as santa_rule is used when it
+// blocks; otherwise the target's SHA-256 is blocked.
+func BlockRule(sig *state.Signal) (*santarule.Rule, string) {
+	path, _ := sig.Context["target_path"].(string)
+	if isSystemPath(path) {
+		return nil, "target is a system binary: " + path
+	}
+	if suggested, ok := sig.Context["santa_rule"].(map[string]any); ok && suggested["policy"] == santarule.PolicyBlocklist {
+		rule := &santarule.Rule{Policy: santarule.PolicyBlocklist}
+		rule.Identifier, _ = suggested["identifier"].(string)
+		rule.RuleType, _ = suggested["rule_type"].(string)
+		rule.CustomMsg, _ = suggested["custom_msg"].(string)
+		if rule.Identifier != "" && rule.RuleType != "" {
+			return rule, ""
+		}
+	}
+	hash, _ := sig.Context["target_sha256"].(string)
+	if hash == "" {
+		return nil, "signal has no target_sha256"
+	}
+	return &santarule.Rule{
+		Identifier: hash,
+		Policy:     santarule.PolicyBlocklist,
+		RuleType:   santarule.TypeBinary,
+		CustomMsg:  "Blocked by santamon: " + sig.Title,
+	}, ""
+}
+
+// push sends the block rule of a signal to the sync server, or only audits
+// it in dry-run mode. Rules already pushed are skipped.
+func (s *syncServer) push(ctx context.Context, sig *state.Signal, entry *AuditEntry) ([]byte, error) {
+	rule, reason := BlockRule(sig)
+	if rule == nil {
+		entry.Status, entry.Error = StatusSkipped, reason
+		return nil, errSkip
+	}
+	entry.SantaRule = rule
+	entry.URL = s.cfg.URL
+
+	key := rule.RuleType + ":" + rule.Identifier
+	if !s.claim(key) {
+		entry.Status, entry.Error = StatusSkipped, "rule already pushed"
+		return nil, errSkip
+	}
+	if s.cfg.DryRun {
+		entry.Status = StatusDryRun
+		logger.Info("Dry run: would push %s rule %s %s for signal %s (%s)", rule.Policy, rule.RuleType, rule.Identifier, sig.ID, sig.RuleID)
+		return nil, errSkip
+	}
+
+	body, err := json.Marshal(pushRequest{
+		Rules:    []santarule.Rule{*rule},
+		HostID:   sig.HostID,
+		SignalID: sig.ID,
+		RuleID:   sig.RuleID,
+		Title:    sig.Title,
+	})
+	if err != nil {
+		s.release(key)
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		s.release(key)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.release(key)
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	out, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s.release(key)
+		return out, fmt.Errorf("sync server returned %s", resp.Status)
+	}
+	return out, nil
+}
+
+// claim records a rule as pushed, reporting false if it already was. Rules
+// are claimed before the request so concurrent signals push them once.
+func (s *syncServer) claim(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pushed[key] {
+		return false
+	}
+	if len(s.pushed) >= maxPushed {
+		clear(s.pushed)
+	}
+	s.pushed[key] = true
+	return true
+}
+
+// release forgets a rule whose push failed, so the next signal retries it
+func (s *syncServer) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pushed, key)
+}
+
+// isSystemPath reports whether path is under a SIP-protected system directory
+func isSystemPath(path string) bool {
+	return slices.ContainsFunc(systemPrefixes, func(p string) bool { return strings.HasPrefix(path, p) })
+}
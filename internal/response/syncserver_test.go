@@ -0,0 +1,140 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/santarule"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+func TestBlockRule(t *testing.T) {
+	rule, _ := BlockRule(testSignal("critical"))
+	want := santarule.Rule{Identifier: hash, Policy: santarule.PolicyBlocklist, RuleType: santarule.TypeBinary, CustomMsg: "Blocked by santamon: Unsigned tool"}
+	if rule == nil || *rule != want {
+		t.Errorf("BlockRule() = %+v, want %+v", rule, want)
+	}
+
+	suggested := testSignal("critical")
+	suggested.Context["santa_rule"] = (&santarule.Rule{Identifier: "EQHXZ8M8AV:com.example.tool", Policy: santarule.PolicyBlocklist, RuleType: santarule.TypeSigningID}).Context()
+	if rule, _ := BlockRule(suggested); rule == nil || rule.RuleType != santarule.TypeSigningID {
+		t.Errorf("The suggested block rule should be used, got %+v", rule)
+	}
+
+	allow := testSignal("critical")
+	allow.Context["santa_rule"] = (&santarule.Rule{Identifier: hash, Policy: santarule.PolicyAllowlist, RuleType: santarule.TypeBinary}).Context()
+	if rule, _ := BlockRule(allow); rule == nil || rule.Policy != santarule.PolicyBlocklist {
+		t.Errorf("An allowlist suggestion must never be pushed, got %+v", rule)
+	}
+
+	system := testSignal("critical")
+	system.Context["target_path"] = "/usr/bin/curl"
+	if rule, _ := BlockRule(system); rule != nil {
+		t.Errorf("System binaries must not be blocked, got %+v", rule)
+	}
+}
+
+func TestSyncServer(t *testing.T) {
+	var mu sync.Mutex
+	var pushes []pushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req pushRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		pushes = append(pushes, req)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	newResponder := func(t *testing.T, dryRun bool) (*Responder, string) {
+		auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+		r, err := New(config.ResponseConfig{
+			Enabled:  true,
+			AuditLog: auditPath,
+			Timeout:  5 * time.Second,
+			SyncServer: config.SyncServerConfig{
+				Enabled:     true,
+				URL:         server.URL,
+				Headers:     map[string]string{"Authorization": "Bearer token"},
+				DryRun:      dryRun,
+				MinSeverity: "high",
+			},
+		})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		r.SetRules(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() { _ = r.Run(ctx) }()
+		return r, auditPath
+	}
+
+	tagged := func(severity string) *state.Signal {
+		sig := testSignal(severity)
+		sig.Tags = []string{"execution", BlockTag}
+		return sig
+	}
+
+	t.Run("push", func(t *testing.T) {
+		r, auditPath := newResponder(t, false)
+		untagged := testSignal("critical")
+		r.Trigger(untagged)
+		r.Trigger(tagged("medium"))
+		sig := tagged("critical")
+		r.Trigger(sig)
+		if got, _ := sig.Context["response_actions"].([]string); !slices.Equal(got, []string{TypeSyncServer}) {
+			t.Errorf("response_actions = %v, want [sync_server]", got)
+		}
+		entries := readAudit(t, auditPath, 1)
+		if len(entries) != 1 || entries[0].Status != StatusOK || entries[0].SantaRule == nil || entries[0].URL != server.URL {
+			t.Fatalf("Unexpected audit entries: %+v", entries)
+		}
+
+		// The same rule is pushed once
+		r.Trigger(tagged("critical"))
+		entries = readAudit(t, auditPath, 2)
+		if len(entries) != 2 || entries[1].Status != StatusSkipped {
+			t.Errorf("Repeated rule should be skipped, got %+v", entries)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(pushes) != 1 || pushes[0].SignalID != "abc123" || pushes[0].Rules[0].Identifier != hash {
+			t.Errorf("Sync server received %+v", pushes)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		mu.Lock()
+		pushes = nil
+		mu.Unlock()
+
+		r, auditPath := newResponder(t, true)
+		r.Trigger(tagged("critical"))
+		entries := readAudit(t, auditPath, 1)
+		if len(entries) != 1 || entries[0].Status != StatusDryRun || entries[0].SantaRule.Identifier != hash {
+			t.Errorf("Expected a dry_run audit entry, got %+v", entries)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(pushes) != 0 {
+			t.Errorf("Dry run must not call the sync server, got %+v", pushes)
+		}
+	})
+}
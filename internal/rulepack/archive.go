@@ -0,0 +1,83 @@
+package rulepack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractTarGz extracts the rules files of a gzipped tar archive into dir,
+// rejecting entries that would land outside it. Other files, links and
+// special files are skipped. An archive with a single top-level
+// directory, as made by git hosting services, is unwrapped.
+func extractTarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	type file struct {
+		name string
+		data []byte
+	}
+	var files []file
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !isRulesFile(hdr.Name) {
+			continue
+		}
+		name := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(hdr.Name, "./")))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid archive: entry %q is outside the archive", hdr.Name)
+		}
+		if total += hdr.Size; total > maxPackSize {
+			return fmt.Errorf("archive is larger than %d MB extracted", maxPackSize>>20)
+		}
+		body, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		files = append(files, file{name, body})
+	}
+
+	// Unwrap a single top-level directory
+	prefix := ""
+	for i, f := range files {
+		top, _, nested := strings.Cut(filepath.ToSlash(f.name), "/")
+		if !nested || (i > 0 && top != prefix) {
+			prefix = ""
+			break
+		}
+		prefix = top
+	}
+
+	for _, f := range files {
+		name := f.name
+		if prefix != "" {
+			name = strings.TrimPrefix(filepath.ToSlash(name), prefix+"/")
+		}
+		out := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, f.data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
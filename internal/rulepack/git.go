@@ -0,0 +1,53 @@
+package rulepack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// fetchGit checks out src at its ref (the remote HEAD by default) into dir
+// and returns the resolved commit. Only that commit is fetched; refs may be
+// tags, branches or, where the server allows it, commit IDs.
+func (m *Manager) fetchGit(ctx context.Context, src Source, dir string) (string, error) {
+	url := strings.TrimPrefix(src.URL, "git+")
+	if strings.HasPrefix(url, "-") || strings.HasPrefix(src.Ref, "-") {
+		return "", fmt.Errorf("invalid git source %s", describe(src))
+	}
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	git := m.Git
+	if git == "" {
+		git = "git"
+	}
+	run := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, git, append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--", url, ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if _, err := run(args...); err != nil {
+			return "", fmt.Errorf("failed to fetch %s: %w", describe(src), err)
+		}
+	}
+	commit, err := run("rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", describe(src), err)
+	}
+	return commit, nil
+}
@@ -0,0 +1,421 @@
+// Package rulepack installs versioned rule packs from git repositories and
+// HTTP archives into the rules directory. Each pack lives in a subdirectory
+// named after it, so its rules join that pack (see rules.RulesConfig.Packs),
+// and the installed versions and checksums are tracked in a lockfile.
+package rulepack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFile is the name of the lockfile in the rules directory. It does not
+// end in .yaml, so the rules loader skips it.
+const LockFile = "packs.lock"
+
+const (
+	// maxDownloadSize caps archive and rules file downloads
+	maxDownloadSize = 64 << 20
+
+	// maxPackSize caps the rules extracted from one pack
+	maxPackSize = 64 << 20
+)
+
+// namePattern restricts pack names to safe directory names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Source is where a pack is fetched from
+type Source struct {
+	URL  string // git+<url> or a URL ending in .git for git; otherwise an HTTP(S) .tar.gz or .yaml
+	Ref  string // git: tag, branch or commit (default: the remote HEAD); HTTP: version substituted for {ref} in URL
+	Path string // Subdirectory of the repository or archive holding the rules
+}
+
+// IsGit reports whether the source is a git repository
+func (s Source) IsGit() bool {
+	return strings.HasPrefix(s.URL, "git+") || strings.HasSuffix(strings.TrimSuffix(s.URL, "/"), ".git")
+}
+
+// Entry is the lockfile record of an installed pack
+type Entry struct {
+	Source        string    `yaml:"source"`
+	Ref           string    `yaml:"ref,omitempty"`
+	Path          string    `yaml:"path,omitempty"`
+	Commit        string    `yaml:"commit,omitempty"`         // Resolved git commit
+	ArchiveSHA256 string    `yaml:"archive_sha256,omitempty"` // SHA-256 of the HTTP download
+	SHA256        string    `yaml:"sha256"`                   // Checksum of the installed rules files (see Checksum)
+	Installed     time.Time `yaml:"installed"`
+}
+
+// Lock is the lockfile: the installed packs by name
+type Lock struct {
+	Packs map[string]*Entry `yaml:"packs"`
+}
+
+// Manager installs, updates and removes the packs of a rules directory
+type Manager struct {
+	Dir      string                 // Rules directory
+	Client   *http.Client           // For HTTP sources
+	Git      string                 // git binary (default: git from PATH)
+	Validate func(dir string) error // Rejects a fetched pack before it is installed (optional)
+}
+
+// NewManager creates a manager for the rules directory dir
+func NewManager(dir string) *Manager {
+	return &Manager{Dir: dir, Client: &http.Client{Timeout: 5 * time.Minute}, Git: "git"}
+}
+
+// ValidateName checks that name can be used as a pack name
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid pack name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// Lock reads the lockfile; a missing lockfile is an empty one
+func (m *Manager) Lock() (*Lock, error) {
+	lock := &Lock{Packs: make(map[string]*Entry)}
+	data, err := os.ReadFile(filepath.Join(m.Dir, LockFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LockFile, err)
+	}
+	if lock.Packs == nil {
+		lock.Packs = make(map[string]*Entry)
+	}
+	return lock, nil
+}
+
+// saveLock writes the lockfile atomically
+func (m *Manager) saveLock(lock *Lock) error {
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	data = append([]byte("# Managed by santamon pack; do not edit\n"), data...)
+	tmp := filepath.Join(m.Dir, "."+LockFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(m.Dir, LockFile)); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// Add fetches a pack and installs it as name. wantSHA256, when set, must
+// match the HTTP download (or, for git, the installed rules checksum).
+func (m *Manager) Add(ctx context.Context, name string, src Source, wantSHA256 string) (*Entry, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := lock.Packs[name]; ok {
+		return nil, fmt.Errorf("pack %s is already installed; use update", name)
+	}
+	if _, err := os.Stat(filepath.Join(m.Dir, name)); err == nil {
+		return nil, fmt.Errorf("%s already exists and is not a managed pack", filepath.Join(m.Dir, name))
+	}
+	entry, err := m.install(ctx, name, src, wantSHA256)
+	if err != nil {
+		return nil, err
+	}
+	lock.Packs[name] = entry
+	return entry, m.saveLock(lock)
+}
+
+// Update fetches the pack again, at ref when set, and reinstalls it when its
+// rules changed. It reports whether they did.
+func (m *Manager) Update(ctx context.Context, name, ref, wantSHA256 string) (*Entry, bool, error) {
+	lock, err := m.Lock()
+	if err != nil {
+		return nil, false, err
+	}
+	old, ok := lock.Packs[name]
+	if !ok {
+		return nil, false, fmt.Errorf("pack %s is not installed", name)
+	}
+	src := Source{URL: old.Source, Ref: old.Ref, Path: old.Path}
+	if ref != "" {
+		src.Ref = ref
+	}
+	entry, err := m.install(ctx, name, src, wantSHA256)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := entry.SHA256 != old.SHA256 || entry.Ref != old.Ref
+	if !changed {
+		entry.Installed = old.Installed
+	}
+	lock.Packs[name] = entry
+	return entry, changed, m.saveLock(lock)
+}
+
+// Remove uninstalls a pack
+func (m *Manager) Remove(name string) error {
+	lock, err := m.Lock()
+	if err != nil {
+		return err
+	}
+	if _, ok := lock.Packs[name]; !ok {
+		return fmt.Errorf("pack %s is not installed", name)
+	}
+	if err := os.RemoveAll(filepath.Join(m.Dir, name)); err != nil {
+		return fmt.Errorf("failed to remove pack %s: %w", name, err)
+	}
+	delete(lock.Packs, name)
+	return m.saveLock(lock)
+}
+
+// Verify checks that the installed rules of a pack match the lockfile
+func (m *Manager) Verify(name string, entry *Entry) error {
+	sum, err := Checksum(filepath.Join(m.Dir, name))
+	if err != nil {
+		return err
+	}
+	if sum != entry.SHA256 {
+		return fmt.Errorf("pack %s was modified: checksum %s, lockfile %s", name, short(sum), short(entry.SHA256))
+	}
+	return nil
+}
+
+// install fetches src, checks and validates it, and swaps it in as name
+func (m *Manager) install(ctx context.Context, name string, src Source, wantSHA256 string) (*Entry, error) {
+	fetched, err := os.MkdirTemp("", "santamon-pack-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(fetched) }()
+
+	entry := &Entry{Source: src.URL, Ref: src.Ref, Path: src.Path, Installed: time.Now().UTC().Truncate(time.Second)}
+	if src.IsGit() {
+		if entry.Commit, err = m.fetchGit(ctx, src, fetched); err != nil {
+			return nil, err
+		}
+	} else {
+		url := strings.ReplaceAll(src.URL, "{ref}", src.Ref)
+		if entry.ArchiveSHA256, err = m.fetchHTTP(ctx, url, fetched); err != nil {
+			return nil, err
+		}
+		if wantSHA256 != "" && !strings.EqualFold(wantSHA256, entry.ArchiveSHA256) {
+			return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, entry.ArchiveSHA256, wantSHA256)
+		}
+	}
+
+	root, err := safeJoin(fetched, src.Path)
+	if err != nil {
+		return nil, err
+	}
+	// Stage inside the rules directory, so the swap is a rename; the rules
+	// loader skips hidden directories
+	staged := filepath.Join(m.Dir, "."+name+".new")
+	_ = os.RemoveAll(staged)
+	defer func() { _ = os.RemoveAll(staged) }()
+	n, err := copyRules(root, staged)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%s has no rules files (.yaml or .yml)", describe(src))
+	}
+	if entry.SHA256, err = Checksum(staged); err != nil {
+		return nil, err
+	}
+	if src.IsGit() && wantSHA256 != "" && !strings.EqualFold(wantSHA256, entry.SHA256) {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", describe(src), entry.SHA256, wantSHA256)
+	}
+	if m.Validate != nil {
+		if err := m.Validate(staged); err != nil {
+			return nil, fmt.Errorf("pack %s: %w", name, err)
+		}
+	}
+
+	dest := filepath.Join(m.Dir, name)
+	old := filepath.Join(m.Dir, "."+name+".old")
+	_ = os.RemoveAll(old)
+	if err := os.Rename(dest, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to replace pack %s: %w", name, err)
+	}
+	if err := os.Rename(staged, dest); err != nil {
+		_ = os.Rename(old, dest)
+		return nil, fmt.Errorf("failed to install pack %s: %w", name, err)
+	}
+	_ = os.RemoveAll(old)
+	return entry, nil
+}
+
+// fetchHTTP downloads a .tar.gz archive, extracting it into dir, or a single
+// rules file. It returns the SHA-256 of the download.
+func (m *Manager) fetchHTTP(ctx context.Context, url, dir string) (string, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return "", fmt.Errorf("unsupported pack source %q: use git+<url>, a .git URL, or an http(s) URL", url)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	if len(data) > maxDownloadSize {
+		return "", fmt.Errorf("%s is larger than %d MB", url, maxDownloadSize>>20)
+	}
+	sum := sha256.Sum256(data)
+
+	base := strings.ToLower(strings.SplitN(filepath.Base(url), "?", 2)[0])
+	switch {
+	case strings.HasSuffix(base, ".tar.gz") || strings.HasSuffix(base, ".tgz"):
+		err = extractTarGz(data, dir)
+	case strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml"):
+		err = os.WriteFile(filepath.Join(dir, base), data, 0644)
+	default:
+		err = fmt.Errorf("unsupported pack download %s: expected .tar.gz, .tgz, .yaml or .yml", base)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// copyRules copies the .yaml and .yml files under src to dst, keeping their
+// relative paths and skipping hidden files and directories. It returns the
+// number of files copied.
+func copyRules(src, dst string) (int, error) {
+	n := 0
+	var total int64
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != src && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isRulesFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if total += int64(len(data)); total > maxPackSize {
+			return fmt.Errorf("pack rules are larger than %d MB", maxPackSize>>20)
+		}
+		out := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		n++
+		return os.WriteFile(out, data, 0644)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("path %s not found in the pack source", filepath.Base(src))
+	}
+	return n, err
+}
+
+// Checksum returns the SHA-256 over the relative paths and contents of the
+// rules files under dir, in path order
+func Checksum(dir string) (string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && isRulesFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		rel, _ := filepath.Rel(dir, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fileSum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%x  %s\n", fileSum, filepath.ToSlash(rel))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isRulesFile reports whether path is a file the rules loader reads
+func isRulesFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// safeJoin joins a relative path from a pack source to root, rejecting paths
+// that leave it
+func safeJoin(root, rel string) (string, error) {
+	if rel == "" {
+		return root, nil
+	}
+	if filepath.IsAbs(rel) || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid pack path %q: must be relative and inside the source", rel)
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// describe names a source in errors
+func describe(src Source) string {
+	s := src.URL
+	if src.Ref != "" {
+		s += "@" + src.Ref
+	}
+	if src.Path != "" {
+		s += " (" + src.Path + ")"
+	}
+	return s
+}
+
+// short abbreviates a checksum for display
+func short(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
@@ -0,0 +1,209 @@
+package rulepack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const packRule = "rules:\n  - id: PACK-1\n    title: T\n    expr: kind == \"execution\"\n    severity: low\n    enabled: true\n"
+
+// tarGz builds a gzipped tar archive of files
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHTTPPacks(t *testing.T) {
+	archives := map[string][]byte{
+		"/pack-1.0.tar.gz": tarGz(t, map[string]string{
+			"pack-1.0/README.md":         "not a rule",
+			"pack-1.0/rules/exec.yaml":   packRule,
+			"pack-1.0/tests/events.yaml": "events: []\n",
+		}),
+		"/pack-1.1.tar.gz": tarGz(t, map[string]string{
+			"pack-1.1/rules/exec.yaml": strings.Replace(packRule, "low", "high", 1),
+		}),
+		"/single.yaml": []byte(packRule),
+		"/evil.tar.gz": tarGz(t, map[string]string{"../escape.yaml": packRule}),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(dir)
+	ctx := context.Background()
+	sum := sha256.Sum256(archives["/pack-1.0.tar.gz"])
+
+	if _, err := m.Add(ctx, "community", Source{URL: srv.URL + "/pack-{ref}.tar.gz", Ref: "1.0", Path: "rules"}, "00"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Add() with a wrong checksum: %v", err)
+	}
+	entry, err := m.Add(ctx, "community", Source{URL: srv.URL + "/pack-{ref}.tar.gz", Ref: "1.0", Path: "rules"}, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if entry.ArchiveSHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("ArchiveSHA256 = %s", entry.ArchiveSHA256)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "community", "exec.yaml")); err != nil || string(data) != packRule {
+		t.Fatalf("Installed rules = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "community", "README.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Non-rules file was installed: %v", err)
+	}
+	if _, err := m.Add(ctx, "community", Source{URL: srv.URL + "/single.yaml"}, ""); err == nil {
+		t.Error("Add() installed a pack twice")
+	}
+
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatalf("Lock() failed: %v", err)
+	}
+	if got := lock.Packs["community"]; got == nil || got.Ref != "1.0" || got.SHA256 != entry.SHA256 {
+		t.Fatalf("Lockfile entry = %+v", got)
+	}
+	if err := m.Verify("community", lock.Packs["community"]); err != nil {
+		t.Errorf("Verify() failed: %v", err)
+	}
+
+	// Same version: unchanged
+	if _, changed, err := m.Update(ctx, "community", "", ""); err != nil || changed {
+		t.Errorf("Update() = %v, %v; want unchanged", changed, err)
+	}
+	// Local edits are detected
+	if err := os.WriteFile(filepath.Join(dir, "community", "exec.yaml"), []byte("rules: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Verify("community", lock.Packs["community"]); err == nil || !strings.Contains(err.Error(), "was modified") {
+		t.Errorf("Verify() of an edited pack = %v", err)
+	}
+	// New version
+	updated, changed, err := m.Update(ctx, "community", "1.1", "")
+	if err != nil || !changed || updated.Ref != "1.1" {
+		t.Fatalf("Update() = %+v, %v, %v", updated, changed, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "community", "exec.yaml")); !strings.Contains(string(data), "high") {
+		t.Errorf("Update() did not install the new rules: %q", data)
+	}
+
+	// A failed validation leaves the installed pack alone
+	m.Validate = func(string) error { return errors.New("does not compile") }
+	if _, _, err := m.Update(ctx, "community", "1.0", ""); err == nil || !strings.Contains(err.Error(), "does not compile") {
+		t.Errorf("Update() with a failing validation = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "community", "exec.yaml")); !strings.Contains(string(data), "high") {
+		t.Errorf("Failed update replaced the pack: %q", data)
+	}
+	m.Validate = nil
+
+	if _, err := m.Add(ctx, "single", Source{URL: srv.URL + "/single.yaml"}, ""); err != nil {
+		t.Fatalf("Add() of a rules file failed: %v", err)
+	}
+	if _, err := m.Add(ctx, "evil", Source{URL: srv.URL + "/evil.tar.gz"}, ""); err == nil || !strings.Contains(err.Error(), "outside the archive") {
+		t.Errorf("Add() of an archive escaping its directory = %v", err)
+	}
+	if _, err := m.Add(ctx, "../up", Source{URL: srv.URL + "/single.yaml"}, ""); err == nil {
+		t.Error("Add() accepted an invalid pack name")
+	}
+
+	if err := m.Remove("single"); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "single")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Removed pack still on disk: %v", err)
+	}
+	if lock, _ := m.Lock(); len(lock.Packs) != 1 {
+		t.Errorf("Lockfile packs after remove = %v", lock.Packs)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("Staging left %s behind", e.Name())
+		}
+	}
+}
+
+func TestGitPacks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(data string) {
+		if err := os.MkdirAll(filepath.Join(repo, "rules"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, "rules", "exec.yaml"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "--quiet")
+	write(packRule)
+	git("add", ".")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1")
+	write(strings.Replace(packRule, "low", "high", 1))
+	git("commit", "--quiet", "-am", "v2")
+
+	dir := t.TempDir()
+	m := NewManager(dir)
+	entry, err := m.Add(context.Background(), "corp", Source{URL: "git+file://" + repo, Ref: "v1", Path: "rules"}, "")
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if len(entry.Commit) != 40 {
+		t.Errorf("Commit = %q", entry.Commit)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "corp", "exec.yaml")); string(data) != packRule {
+		t.Errorf("Installed rules = %q, want v1", data)
+	}
+
+	updated, changed, err := m.Update(context.Background(), "corp", "HEAD", "")
+	if err != nil || !changed || updated.Commit == entry.Commit {
+		t.Fatalf("Update() = %+v, %v, %v", updated, changed, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "corp", "exec.yaml")); !strings.Contains(string(data), "high") {
+		t.Errorf("Installed rules = %q, want v2", data)
+	}
+}
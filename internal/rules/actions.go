@@ -0,0 +1,42 @@
+package rules
+
+import "fmt"
+
+// validateActions checks the response action names of a rule. Whether the
+// names are in the response.actions catalog is checked when the rules are
+// installed, since the catalog is agent config.
+func validateActions(actions []string) error {
+	seen := make(map[string]bool, len(actions))
+	for i, name := range actions {
+		if name == "" {
+			return fmt.Errorf("actions[%d] is empty", i)
+		}
+		if seen[name] {
+			return fmt.Errorf("actions: duplicate action %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Actions returns the response actions of enabled rules of every type, by
+// rule ID
+func (rc *RulesConfig) Actions() map[string][]string {
+	actions := make(map[string][]string)
+	for _, r := range rc.Rules {
+		if r.Enabled && len(r.Actions) > 0 {
+			actions[r.ID] = r.Actions
+		}
+	}
+	for _, cr := range rc.Correlations {
+		if cr.Enabled && len(cr.Actions) > 0 {
+			actions[cr.ID] = cr.Actions
+		}
+	}
+	for _, br := range rc.Baselines {
+		if br.Enabled && len(br.Actions) > 0 {
+			actions[br.ID] = br.Actions
+		}
+	}
+	return actions
+}
@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"regexp"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Ancestry looks up process ancestry for ancestor_paths, parent_path and
+// has_ancestor (see lineage.Store.AncestorPaths)
+type Ancestry interface {
+	AncestorPaths(msg *santapb.SantaMessage) []string // Parent first
+}
+
+// SetAncestry sets the process lineage the ancestry functions look up.
+// Without it no event has ancestors. It must be called before rules are
+// evaluated.
+func (e *Engine) SetAncestry(a Ancestry) {
+	e.ancestry = a
+}
+
+// ancestryCall matches expressions calling an ancestry function
+var ancestryCall = regexp.MustCompile(`\b(ancestor_paths|parent_path|has_ancestor)\s*\(`)
+
+// usesAncestry reports whether expr calls an ancestry function, so the
+// lineage store has to be kept
+func usesAncestry(expr string) bool {
+	return ancestryCall.MatchString(expr)
+}
+
+// ancestryFunctions declares the process ancestry functions of the event
+// being evaluated. Rules call them without arguments (has_ancestor takes a
+// glob); macros pass the event along:
+//
+//	ancestor_paths(): executable paths of the ancestors, parent first
+//	parent_path(): executable path of the parent, "" when unknown
+//	has_ancestor(glob): an ancestor's path matches the glob (see glob)
+func (e *Engine) ancestryFunctions(eventType *cel.Type) []cel.EnvOption {
+	paths := func(v ref.Val) []string {
+		msg, ok := v.Value().(*santapb.SantaMessage)
+		if !ok || e.ancestry == nil {
+			return nil
+		}
+		return e.ancestry.AncestorPaths(msg)
+	}
+	return []cel.EnvOption{
+		cel.Macros(
+			withEvent("ancestor_paths", 0),
+			withEvent("parent_path", 0),
+			withEvent("has_ancestor", 1),
+		),
+		cel.Function("ancestor_paths",
+			cel.Overload("ancestor_paths_event", []*cel.Type{eventType}, cel.ListType(cel.StringType),
+				cel.UnaryBinding(func(ev ref.Val) ref.Val {
+					return types.NewStringList(types.DefaultTypeAdapter, paths(ev))
+				}))),
+		cel.Function("parent_path",
+			cel.Overload("parent_path_event", []*cel.Type{eventType}, cel.StringType,
+				cel.UnaryBinding(func(ev ref.Val) ref.Val {
+					if p := paths(ev); len(p) > 0 {
+						return types.String(p[0])
+					}
+					return types.String("")
+				}))),
+		cel.Function("has_ancestor",
+			cel.Overload("has_ancestor_event_string", []*cel.Type{eventType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(ev, pattern ref.Val) ref.Val {
+					re, err := compilePattern(string(pattern.(types.String)), true)
+					if err != nil {
+						return types.NewErr("has_ancestor: %v", err)
+					}
+					for _, p := range paths(ev) {
+						if re.MatchString(p) {
+							return types.True
+						}
+					}
+					return types.False
+				}))),
+	}
+}
+
+// withEvent expands a call of function with argCount arguments into a call
+// that also passes the event
+func withEvent(function string, argCount int) cel.Macro {
+	return cel.GlobalMacro(function, argCount,
+		func(eh cel.MacroExprFactory, _ celast.Expr, args []celast.Expr) (celast.Expr, *common.Error) {
+			return eh.NewCall(function, append([]celast.Expr{eh.NewIdent("event")}, args...)...), nil
+		})
+}
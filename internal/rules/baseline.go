@@ -1,9 +1,11 @@
 package rules
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"time"
-
-	"github.com/google/cel-go/cel"
 )
 
 // BaselineRule detects first-occurrence or deviation from baseline
@@ -11,18 +13,41 @@ type BaselineRule struct {
 	ID             string        `yaml:"id"`
 	Title          string        `yaml:"title"`
 	Description    string        `yaml:"description,omitempty"`
-	Expr           string        `yaml:"expr"`             // Filter expression
-	Track          []string      `yaml:"track"`            // Fields to track for uniqueness
+	Expr           string        `yaml:"expr"`                 // Filter expression
+	Track          []string      `yaml:"track"`                // Fields to track for uniqueness
+	Scope          []string      `yaml:"scope,omitempty"`      // Fields whose values each learn their own baseline
+	Exceptions     []Exception   `yaml:"exceptions,omitempty"` // Matching events are neither tracked nor alerted
 	Severity       string        `yaml:"severity"`
 	Tags           []string      `yaml:"tags,omitempty"`
 	Enabled        bool          `yaml:"enabled"`
 	LearningPeriod time.Duration `yaml:"learning_period,omitempty"` // Suppress alerts during learning
+	ForgetAfter    time.Duration `yaml:"forget_after,omitempty"`    // Alert again on patterns unseen this long
+	MinOccurrences int           `yaml:"min_occurrences,omitempty"` // Sightings before a pattern stops alerting
+	Actions        []string      `yaml:"actions,omitempty"`         // Response actions (response.actions) run for each signal
+	HostSelector   `yaml:",inline"`
+	Metadata       `yaml:",inline"`
+}
+
+// Alerts reports whether the count-th sighting of a pattern alerts: the
+// first one, and every one before the pattern was seen MinOccurrences times
+func (br *BaselineRule) Alerts(count int) bool {
+	return count == 1 || count < br.MinOccurrences
+}
+
+// ContentHash identifies what the rule learns: its expression, track and
+// scope fields. Edits elsewhere, such as to severity or learning_period, keep
+// the hash and so the learned state.
+func (br *BaselineRule) ContentHash() string {
+	data, _ := json.Marshal([]any{br.Expr, br.Track, br.Scope})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
 }
 
 // CompiledBaseline holds a baseline rule plus its compiled CEL program
 type CompiledBaseline struct {
-	Rule    *BaselineRule
-	Program cel.Program
+	Rule       *BaselineRule
+	Program    *Program
+	Exceptions *Suppressor // nil when the rule has no exceptions
 }
 
 // Validate checks a baseline rule
@@ -52,6 +77,30 @@ func (br *BaselineRule) Validate() error {
 			return ErrInvalidField("track", i)
 		}
 	}
+	for i, field := range br.Scope {
+		if field == "" {
+			return ErrInvalidField("scope", i)
+		}
+	}
+
+	if br.ForgetAfter < 0 {
+		return fmt.Errorf("baseline %s: forget_after must not be negative", br.ID)
+	}
+	if br.MinOccurrences < 0 || br.MinOccurrences == 1 {
+		return fmt.Errorf("baseline %s: min_occurrences must be at least 2 (the first occurrence always alerts)", br.ID)
+	}
+	if err := validateExceptions(br.Exceptions); err != nil {
+		return fmt.Errorf("baseline %s: %w", br.ID, err)
+	}
+	if err := validateActions(br.Actions); err != nil {
+		return fmt.Errorf("baseline %s: %w", br.ID, err)
+	}
+	if err := br.HostSelector.Validate(); err != nil {
+		return fmt.Errorf("baseline %s: %w", br.ID, err)
+	}
+	if err := br.Metadata.Validate(); err != nil {
+		return fmt.Errorf("baseline %s: %w", br.ID, err)
+	}
 
 	return nil
 }
@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBaselineRuleAlerts(t *testing.T) {
+	tests := []struct {
+		minOccurrences int
+		count          int
+		want           bool
+	}{
+		{0, 1, true},
+		{0, 2, false},
+		{3, 1, true},
+		{3, 2, true},
+		{3, 3, false},
+		{3, 10, false},
+	}
+	for _, tt := range tests {
+		br := &BaselineRule{MinOccurrences: tt.minOccurrences}
+		if got := br.Alerts(tt.count); got != tt.want {
+			t.Errorf("Alerts(%d) with min_occurrences %d = %v, want %v", tt.count, tt.minOccurrences, got, tt.want)
+		}
+	}
+}
+
+func TestBaselineRuleValidateMinOccurrences(t *testing.T) {
+	for _, n := range []int{-1, 1} {
+		br := &BaselineRule{ID: "B1", Title: "B", Expr: "true", Track: []string{"f"}, Severity: "low", MinOccurrences: n}
+		if err := br.Validate(); err == nil {
+			t.Errorf("Validate() with min_occurrences %d succeeded, want error", n)
+		}
+	}
+	br := &BaselineRule{ID: "B1", Title: "B", Expr: "true", Track: []string{"f"}, Severity: "low", MinOccurrences: 2}
+	if err := br.Validate(); err != nil {
+		t.Errorf("Validate() with min_occurrences 2 failed: %v", err)
+	}
+}
+
+func TestBaselineRuleValidateScope(t *testing.T) {
+	br := &BaselineRule{ID: "B1", Title: "B", Expr: "true", Track: []string{"f"}, Severity: "low", Scope: []string{"user", ""}}
+	if err := br.Validate(); err == nil {
+		t.Error("Validate() with an empty scope field succeeded, want error")
+	}
+	br.Scope = []string{"execution.target.effective_user.name"}
+	if err := br.Validate(); err != nil {
+		t.Errorf("Validate() with scope failed: %v", err)
+	}
+}
+
+func TestBaselineRuleContentHash(t *testing.T) {
+	br := &BaselineRule{ID: "B1", Expr: "true", Track: []string{"a", "b"}, Severity: "low"}
+	hash := br.ContentHash()
+
+	edited := *br
+	edited.Severity = "high"
+	edited.LearningPeriod = time.Hour
+	if edited.ContentHash() != hash {
+		t.Error("Severity or learning_period change altered the content hash")
+	}
+
+	for _, change := range []func(*BaselineRule){
+		func(r *BaselineRule) { r.Expr = "false" },
+		func(r *BaselineRule) { r.Track = []string{"a"} },
+		func(r *BaselineRule) { r.Track = []string{"a,b"} },
+		func(r *BaselineRule) { r.Scope = []string{"a"} },
+	} {
+		edited := *br
+		change(&edited)
+		if edited.ContentHash() == hash {
+			t.Errorf("Content hash unchanged for %+v", edited)
+		}
+	}
+}
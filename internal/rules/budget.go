@@ -0,0 +1,210 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+)
+
+// Budget bounds the work a rule may do for a single event
+type Budget struct {
+	CostLimit    uint64        // CEL cost units per evaluation; 0 means unlimited
+	LatencyLimit time.Duration // Wall time per evaluation; 0 means unlimited
+	Timeout      time.Duration // Wall time after which comprehensions are interrupted; 0 means never
+	DisableAfter int           // Exceeded cost, latency or time limits before the rule is disabled; 0 never disables
+
+	// A rule whose evaluations fail at ErrorRate or more (0 never disables)
+	// is disabled once it has been evaluated MinEvaluations times
+	ErrorRate      float64
+	MinEvaluations int
+}
+
+// DefaultBudget is applied by NewEngine. Typical rules cost well under a
+// thousand units and a few microseconds; the limits are meant to stop runaway
+// comprehensions (e.g. a nested loop over a large environment), not to
+// constrain normal rules.
+var DefaultBudget = Budget{CostLimit: 1_000_000, Timeout: 100 * time.Millisecond, DisableAfter: 10}
+
+// interruptCheckFrequency is the number of comprehension iterations between
+// checks of an evaluation's timeout
+const interruptCheckFrequency = 100
+
+// Program is a compiled rule expression. Evaluations that exceed the cost
+// budget fail, and a rule that exceeds its cost or latency budget too often,
+// or fails too often, is disabled until the rules are reloaded.
+type Program struct {
+	cel.Program
+	ruleID         string
+	budget         Budget
+	interruptible  bool       // Evaluations are bounded by budget.Timeout
+	scope          *hostScope // machine_id selection of a host-scoped rule; nil for all events
+	evaluations    atomic.Int64
+	failed         atomic.Int64
+	exceeded       atomic.Int64
+	disabled       atomic.Bool
+	disabledReason atomic.Pointer[string]
+}
+
+// Eval evaluates the expression. A disabled rule, or one scoped to other
+// hosts than the event's, always evaluates to false.
+func (p *Program) Eval(input any) (ref.Val, *cel.EvalDetails, error) {
+	if p.disabled.Load() || !p.inScope(input) {
+		return types.False, nil, nil
+	}
+	var start time.Time
+	if p.budget.LatencyLimit > 0 {
+		start = time.Now()
+	}
+	var result ref.Val
+	var details *cel.EvalDetails
+	var err error
+	timedOut := false
+	if p.interruptible {
+		ctx, cancel := context.WithTimeout(context.Background(), p.budget.Timeout)
+		result, details, err = p.Program.ContextEval(ctx, input)
+		timedOut = err != nil && ctx.Err() != nil
+		cancel()
+	} else {
+		result, details, err = p.Program.Eval(input)
+	}
+	evaluations := p.evaluations.Add(1)
+
+	switch {
+	case err != nil && costExceeded(err):
+		p.exceed("CEL cost limit")
+	case timedOut:
+		err = fmt.Errorf("evaluation interrupted after %s: %w", p.budget.Timeout, err)
+		p.exceed("timeout")
+	case err != nil:
+		// Only failing evaluations check the rate, so healthy rules pay nothing
+		failed := p.failed.Add(1)
+		if b := p.budget; b.ErrorRate > 0 && evaluations >= int64(b.MinEvaluations) &&
+			float64(failed) >= b.ErrorRate*float64(evaluations) {
+			p.disable(fmt.Sprintf("%d of %d evaluations failed", failed, evaluations))
+		}
+	case p.budget.LatencyLimit > 0 && time.Since(start) > p.budget.LatencyLimit:
+		p.exceed("latency limit")
+	}
+	return result, details, err
+}
+
+// exceed counts an evaluation over the cost or latency limit and disables
+// the rule once it has exceeded them DisableAfter times
+func (p *Program) exceed(limit string) {
+	n := p.exceeded.Add(1)
+	if p.budget.DisableAfter > 0 && n == int64(p.budget.DisableAfter) {
+		p.disable(fmt.Sprintf("exceeded its evaluation budget %d times, last its %s", n, limit))
+	}
+}
+
+// disable stops evaluating the rule until the rules are reloaded
+func (p *Program) disable(reason string) {
+	if !p.disabledReason.CompareAndSwap(nil, &reason) {
+		return
+	}
+	p.disabled.Store(true)
+	logger.Warn("rule %s disabled: %s", p.ruleID, reason)
+}
+
+// Disabled reports whether the rule was disabled for exceeding its budget
+func (p *Program) Disabled() bool {
+	return p.disabled.Load()
+}
+
+// DisabledReason describes why the rule was disabled, or is empty while it
+// is enabled
+func (p *Program) DisabledReason() string {
+	if r := p.disabledReason.Load(); r != nil {
+		return *r
+	}
+	return ""
+}
+
+// Exceeded returns how many evaluations exceeded the budget
+func (p *Program) Exceeded() int64 {
+	return p.exceeded.Load()
+}
+
+// costExceeded reports whether err is a CEL evaluation cancelled for
+// exceeding its cost limit
+func costExceeded(err error) bool {
+	var cancelled interpreter.EvalCancelledError
+	return errors.As(err, &cancelled) && cancelled.Cause == interpreter.CostLimitExceeded
+}
+
+// SetBudget sets the evaluation budget of rules compiled afterwards. It must
+// be called before LoadRules.
+func (e *Engine) SetBudget(b Budget) {
+	e.budget = b
+}
+
+// newProgram compiles a rule expression into a budgeted Program
+func (e *Engine) newProgram(ruleID, expr string) (*Program, error) {
+	program, interruptible, err := e.compileProgram(ruleID, expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{Program: program, ruleID: ruleID, budget: e.budget, interruptible: interruptible}, nil
+}
+
+// hasComprehension reports whether ast has a comprehension (all, exists,
+// exists_one, map or filter), the only expressions evaluation can interrupt
+func hasComprehension(ast *cel.Ast) bool {
+	found := false
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		if e.Kind() == celast.ComprehensionKind {
+			found = true
+		}
+	}))
+	return found
+}
+
+// DisabledRules returns the IDs of rules disabled for exceeding their
+// evaluation budget
+func (e *Engine) DisabledRules() []string {
+	var ids []string
+	for _, r := range e.rules {
+		if r.Program.Disabled() {
+			ids = append(ids, r.Rule.ID)
+		}
+	}
+	for _, c := range e.correlations {
+		if c.Program.Disabled() {
+			ids = append(ids, c.Rule.ID)
+		}
+	}
+	for _, b := range e.baselines {
+		if b.Program.Disabled() {
+			ids = append(ids, b.Rule.ID)
+		}
+	}
+	return ids
+}
+
+// DisabledReasons returns why each disabled rule was disabled, by rule ID
+func (e *Engine) DisabledReasons() map[string]string {
+	reasons := make(map[string]string)
+	add := func(id string, p *Program) {
+		if p.Disabled() {
+			reasons[id] = p.DisabledReason()
+		}
+	}
+	for _, r := range e.rules {
+		add(r.Rule.ID, r.Program)
+	}
+	for _, c := range e.correlations {
+		add(c.Rule.ID, c.Program)
+	}
+	for _, b := range e.baselines {
+		add(b.Rule.ID, b.Program)
+	}
+	return reasons
+}
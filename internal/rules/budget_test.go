@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+)
+
+func TestBudget(t *testing.T) {
+	rc := &RulesConfig{Rules: []*Rule{
+		{
+			ID:       "QUADRATIC",
+			Title:    "Nested loop over arguments",
+			Expr:     `kind == "execution" && decoded_args.exists(a, decoded_args.exists(b, a + b == "--x--y"))`,
+			Severity: "low",
+			Enabled:  true,
+		},
+		{ID: "CHEAP", Title: "Exec", Expr: `kind == "execution"`, Severity: "low", Enabled: true},
+	}}
+
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	engine.SetBudget(Budget{CostLimit: 1000, DisableAfter: 2})
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	args := make([][]byte, 200)
+	for i := range args {
+		args[i] = []byte(fmt.Sprintf("arg%d", i))
+	}
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{Args: args}}}
+
+	for i := 0; i < 3; i++ {
+		matches, err := engine.Evaluate(msg)
+		if err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+		if len(matches) != 1 || matches[0].RuleID != "CHEAP" {
+			t.Errorf("Evaluate() #%d matched %v, want only CHEAP", i, matches)
+		}
+	}
+
+	// Two exceeded budgets disable the rule; the third evaluation is skipped
+	if got := engine.EvalErrors(); got != 2 {
+		t.Errorf("EvalErrors() = %d, want 2", got)
+	}
+	if got := engine.DisabledRules(); len(got) != 1 || got[0] != "QUADRATIC" {
+		t.Errorf("DisabledRules() = %v, want [QUADRATIC]", got)
+	}
+	if got := engine.rules[0].Program.Exceeded(); got != 2 {
+		t.Errorf("Exceeded() = %d, want 2", got)
+	}
+
+	// The default budget leaves the same rule alone on small events
+	engine, err = NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	small := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{Args: args[:10]}}}
+	if _, err := engine.Evaluate(small); err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if got := engine.EvalErrors(); got != 0 {
+		t.Errorf("EvalErrors() = %d, want 0", got)
+	}
+}
+
+func TestBudgetLatencyAndErrors(t *testing.T) {
+	rc := &RulesConfig{Rules: []*Rule{
+		{ID: "SLOW", Title: "Exec", Expr: `kind == "execution"`, Severity: "low", Enabled: true},
+		{ID: "FAILING", Title: "Fifth argument", Expr: `decoded_args[5] == "-x"`, Severity: "low", Enabled: true},
+	}}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	// Every evaluation takes longer than a nanosecond
+	engine.SetBudget(Budget{LatencyLimit: time.Nanosecond, DisableAfter: 3, ErrorRate: 0.5, MinEvaluations: 4})
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := engine.Evaluate(msg); err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+	}
+	// FAILING has failed 3 times, but fewer than MinEvaluations
+	if got := engine.DisabledReasons(); len(got) != 1 || !strings.Contains(got["SLOW"], "latency limit") {
+		t.Errorf("DisabledReasons() = %v, want SLOW over its latency limit", got)
+	}
+
+	if _, err := engine.Evaluate(msg); err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	got := engine.DisabledReasons()
+	if got["FAILING"] != "4 of 4 evaluations failed" {
+		t.Errorf("FAILING disabled for %q, want 4 of 4 evaluations failed", got["FAILING"])
+	}
+	if engine.EvalErrors() != 4 {
+		t.Errorf("EvalErrors() = %d, want 4", engine.EvalErrors())
+	}
+
+	// The default budget has no latency or error limits
+	engine, err = NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		_, _ = engine.Evaluate(msg)
+	}
+	if got := engine.DisabledRules(); len(got) != 0 {
+		t.Errorf("DisabledRules() = %v, want none", got)
+	}
+}
+
+func TestBudgetTimeout(t *testing.T) {
+	rc := &RulesConfig{Rules: []*Rule{
+		{
+			ID:       "QUADRATIC",
+			Title:    "Nested loop over arguments",
+			Expr:     `decoded_args.exists(a, decoded_args.exists(b, a + b == "--x--y"))`,
+			Severity: "low",
+			Enabled:  true,
+		},
+		{ID: "CHEAP", Title: "Exec", Expr: `kind == "execution"`, Severity: "low", Enabled: true},
+	}}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	engine.SetBudget(Budget{Timeout: time.Millisecond, DisableAfter: 1})
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	if !engine.rules[0].Program.interruptible || engine.rules[1].Program.interruptible {
+		t.Fatal("Only the rule with comprehensions should be interruptible")
+	}
+
+	// Without a cost limit the nested loop runs for millions of iterations
+	args := make([][]byte, 5000)
+	for i := range args {
+		args[i] = []byte(fmt.Sprintf("arg%d", i))
+	}
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{Args: args}}}
+
+	start := time.Now()
+	matches, err := engine.Evaluate(msg)
+	if err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Evaluate() took %s, want it interrupted", elapsed)
+	}
+	if len(matches) != 1 || matches[0].RuleID != "CHEAP" {
+		t.Errorf("Evaluate() matched %v, want only CHEAP", matches)
+	}
+	if got := engine.DisabledReasons()["QUADRATIC"]; !strings.Contains(got, "timeout") {
+		t.Errorf("QUADRATIC disabled for %q, want its timeout", got)
+	}
+}
@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+)
+
+// CheckContext reports context a rule requests but can never receive for the
+// event kinds its expression matches: include_process_tree and
+// include_entitlements outside execution events, and extra_context paths
+// under another event type. Signals of such rules would silently lack the
+// requested fields.
+func (e *Engine) CheckContext(rules *RulesConfig) []error {
+	var issues []error
+	for _, rule := range rules.Rules {
+		if !rule.IncludeProcessTree && len(rule.Entitlements) == 0 && len(rule.ExtraContext) == 0 {
+			continue
+		}
+		ast, iss := e.env.Compile(rule.Expr)
+		if iss != nil && iss.Err() != nil {
+			continue
+		}
+		kinds := matchedKinds(ast.NativeRep().Expr())
+		if len(kinds) == 0 {
+			continue
+		}
+		matches := strings.Join(slices.Sorted(maps.Keys(kinds)), ", ")
+
+		if rule.IncludeProcessTree && !kinds["execution"] {
+			issues = append(issues, &LintIssue{RuleID: rule.ID,
+				Message: fmt.Sprintf("include_process_tree is only filled for execution events, but the rule matches %s", matches)})
+		}
+		if len(rule.Entitlements) > 0 && !kinds["execution"] {
+			issues = append(issues, &LintIssue{RuleID: rule.ID,
+				Message: fmt.Sprintf("include_entitlements is only filled for execution events, but the rule matches %s", matches)})
+		}
+		for _, path := range rule.ExtraContext {
+			kind, _, _ := strings.Cut(strings.TrimPrefix(path, "event."), ".")
+			if eventKinds[kind] && !kinds[kind] {
+				issues = append(issues, &LintIssue{RuleID: rule.ID,
+					Message: fmt.Sprintf("extra_context %q is never set: the rule matches %s", path, matches)})
+			}
+		}
+	}
+	return issues
+}
+
+// eventKinds are the event types of a SantaMessage, named as kind reports them
+var eventKinds = func() map[string]bool {
+	kinds := make(map[string]bool)
+	fields := (&santapb.SantaMessage{}).ProtoReflect().Descriptor().Oneofs().ByName("event").Fields()
+	for i := range fields.Len() {
+		kinds[string(fields.Get(i).Name())] = true
+	}
+	return kinds
+}()
+
+// matchedKinds returns the event kinds an expression can match, or nil when
+// it does not constrain kind to literal values (see constrainsKind)
+func matchedKinds(expr celast.Expr) map[string]bool {
+	if expr.Kind() == celast.SelectKind {
+		sel := expr.AsSelect()
+		if sel.IsTestOnly() && isIdent(sel.Operand(), "event") && eventKinds[sel.FieldName()] {
+			return map[string]bool{sel.FieldName(): true}
+		}
+		return nil
+	}
+	if expr.Kind() != celast.CallKind {
+		return nil
+	}
+	call := expr.AsCall()
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalAnd:
+		left, right := matchedKinds(args[0]), matchedKinds(args[1])
+		if left == nil {
+			return right
+		}
+		if right == nil {
+			return left
+		}
+		maps.DeleteFunc(left, func(kind string, _ bool) bool { return !right[kind] })
+		return left
+	case operators.LogicalOr:
+		return unionKinds(matchedKinds(args[0]), matchedKinds(args[1]))
+	case operators.Conditional:
+		return unionKinds(matchedKinds(args[1]), matchedKinds(args[2]))
+	case operators.Equals:
+		for i, arg := range args {
+			if isIdent(arg, "kind") {
+				if s, ok := stringLiteral(args[1-i]); ok {
+					return map[string]bool{s: true}
+				}
+			}
+		}
+	case operators.In:
+		if isIdent(args[0], "kind") && args[1].Kind() == celast.ListKind {
+			kinds := make(map[string]bool)
+			for _, elem := range args[1].AsList().Elements() {
+				s, ok := stringLiteral(elem)
+				if !ok {
+					return nil
+				}
+				kinds[s] = true
+			}
+			return kinds
+		}
+	}
+	return nil
+}
+
+// unionKinds merges the kinds of two branches; either being unconstrained
+// makes the union unconstrained
+func unionKinds(a, b map[string]bool) map[string]bool {
+	if a == nil || b == nil {
+		return nil
+	}
+	maps.Copy(a, b)
+	return a
+}
+
+func stringLiteral(expr celast.Expr) (string, bool) {
+	if expr.Kind() != celast.LiteralKind {
+		return "", false
+	}
+	s, ok := expr.AsLiteral().(types.String)
+	return string(s), ok
+}
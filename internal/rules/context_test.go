@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr string
+	}{
+		{
+			name:    "process tree on file access",
+			rule:    Rule{Expr: `kind == "file_access"`, IncludeProcessTree: true},
+			wantErr: "include_process_tree is only filled for execution events, but the rule matches file_access",
+		},
+		{
+			name:    "process tree on has()",
+			rule:    Rule{Expr: `has(event.fork) && machine_id != ""`, IncludeProcessTree: true},
+			wantErr: "rule matches fork",
+		},
+		{
+			name: "process tree on execution",
+			rule: Rule{Expr: `kind in ["execution", "fork"]`, IncludeProcessTree: true},
+		},
+		{
+			name: "process tree without kind",
+			rule: Rule{Expr: `machine_id == "m1"`, IncludeProcessTree: true},
+		},
+		{
+			name:    "entitlements on launch item",
+			rule:    Rule{Expr: `kind == "launch_item"`, Entitlements: []string{"com.apple.security.*"}},
+			wantErr: "include_entitlements is only filled for execution events, but the rule matches launch_item",
+		},
+		{
+			name:    "extra context of another kind",
+			rule:    Rule{Expr: `kind == "launch_item" || kind == "tcc_modification"`, ExtraContext: []string{"event.execution.args", "launch_item.item_path"}},
+			wantErr: `extra_context "event.execution.args" is never set: the rule matches launch_item, tcc_modification`,
+		},
+		{
+			name: "extra context of a matched kind",
+			rule: Rule{Expr: `kind == "execution" && has(event.execution.target)`, ExtraContext: []string{"execution.args", "machine_id"}},
+		},
+		{
+			name: "branch without kind",
+			rule: Rule{Expr: `kind == "fork" || machine_id == "m1"`, IncludeProcessTree: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngine()
+			if err != nil {
+				t.Fatalf("NewEngine() failed: %v", err)
+			}
+			tt.rule.ID = "R1"
+			issues := engine.CheckContext(&RulesConfig{Rules: []*Rule{&tt.rule}})
+			if tt.wantErr == "" {
+				if len(issues) != 0 {
+					t.Errorf("Unexpected issues: %v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || !strings.Contains(issues[0].Error(), tt.wantErr) {
+				t.Errorf("CheckContext() = %v, want %q", issues, tt.wantErr)
+			}
+		})
+	}
+}
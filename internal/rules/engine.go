@@ -2,6 +2,8 @@ package rules
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/cel-go/cel"
@@ -11,6 +13,8 @@ import (
 	"github.com/0x4d31/santamon/internal/logutil"
 )
 
+var logger = logutil.For("rules")
+
 // santaEnums maps Santa protobuf enum names to their integer values
 // These are registered as CEL constants for use in rules
 var santaEnums = map[string]int64{
@@ -69,22 +73,60 @@ var santaEnums = map[string]int64{
 // Engine evaluates detection rules against events
 type Engine struct {
 	rules        []*CompiledRule
+	priority     []*CompiledRule // Subset of rules marked priority: true
+	bulk         []*CompiledRule // Remaining rules
 	correlations []*CompiledCorrelation
 	baselines    []*CompiledBaseline
 	env          *cel.Env
-	startTime    time.Time // For learning period calculation
+	baseEnv      *cel.Env            // env before the rules' lists are declared
+	lists        map[string][]string // Named lists of the loaded rules
+	intel        IntelMatcher        // Threat intel indicators for intel_match, if configured
+	ancestry     Ancestry            // Process lineage for the ancestry functions, if tracked
+	startTime    time.Time           // For learning period calculation
+	version      string              // Version of the loaded rules (see RulesConfig.Version)
+	evalErrors   atomic.Int64
+	budget       Budget                // Applied to rules as they are compiled
+	host         *Host                 // Host the rules are scoped to; nil loads every rule
+	suppressions *Suppressions         // Merged into rule exceptions as they are compiled
+	stats        map[string]*RuleStats // Per-rule cost, collected while non-nil
+	statsMu      sync.Mutex            // Guards stats during parallel evaluation
+	learningFrom map[string]time.Time  // Persisted learning starts by baseline rule ID
+
+	// Rules still evaluated under load shedding: everything except
+	// non-priority rules with info or low severity
+	essentialBulk         []*CompiledRule
+	essentialCorrelations []*CompiledCorrelation
+	essentialBaselines    []*CompiledBaseline
+	sheddable             []string // IDs of the skipped rules
+
+	// rules, priority, bulk and essentialBulk indexed by event kind
+	rulesByKind, priorityByKind, bulkByKind, essentialBulkByKind ruleIndex
+}
+
+// RuleStats is the accumulated evaluation cost of one rule
+type RuleStats struct {
+	Evaluations int
+	Matches     int
+	Duration    time.Duration
 }
 
 // CompiledRule is a rule ready for evaluation
 type CompiledRule struct {
-	Rule    *Rule
-	Program cel.Program
+	Rule       *Rule
+	Program    *Program
+	Exceptions *Suppressor // nil when the rule has no exceptions
+
+	escalations []compiledEscalation // Most severe first
+	kinds       map[string]bool      // Event kinds the rule can match; nil for any
+	schedule    *schedule            // When the rule signals; nil for always
 }
 
 // CompiledCorrelation holds a correlation rule plus its compiled CEL program.
 type CompiledCorrelation struct {
-    Rule    *CorrelationRule
-    Program cel.Program
+	Rule       *CorrelationRule
+	Program    *Program
+	Exceptions *Suppressor // Matching events are not added to the window
+	Expect     *Program    // Follow-up event of an absence correlation, else nil
 }
 
 // Match represents a rule match
@@ -96,6 +138,12 @@ type Match struct {
 	Message   *santapb.SantaMessage
 	Timestamp time.Time
 	Rule      *Rule
+
+	// When and how long the rule was evaluated; set while stats are collected
+	EvalStart    time.Time
+	EvalDuration time.Duration
+
+	event *Event // Evaluation state of Message, shared by EventMap
 }
 
 // NewEngine creates a new rules engine
@@ -113,29 +161,42 @@ func NewEngine() (*Engine, error) {
 		cel.Variable("boot_session_uuid", cel.StringType),
 		cel.Variable("decoded_args", cel.ListType(cel.StringType)),
 	}
+	envOpts = append(envOpts, celFunctions()...)
 
 	// Register Santa enum constants
 	for name := range santaEnums {
 		envOpts = append(envOpts, cel.Variable(name, cel.IntType))
 	}
 
+	e := &Engine{
+		rules:        make([]*CompiledRule, 0),
+		correlations: make([]*CompiledCorrelation, 0),
+		baselines:    make([]*CompiledBaseline, 0),
+		startTime:    time.Now(),
+		budget:       DefaultBudget,
+	}
+	envOpts = append(envOpts, e.intelFunction())
+	envOpts = append(envOpts, e.ancestryFunctions(cel.ObjectType(string(msgDesc.FullName())))...)
+	envOpts = append(envOpts, entitlementFunctions(cel.ObjectType(string(msgDesc.FullName())))...)
+	envOpts = append(envOpts, scheduleFunctions(cel.ObjectType(string(msgDesc.FullName())))...)
+
 	// Register Santa protobuf types with CEL
 	env, err := cel.NewEnv(envOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
 	}
-
-	return &Engine{
-		rules:        make([]*CompiledRule, 0),
-		correlations: make([]*CompiledCorrelation, 0),
-		baselines:    make([]*CompiledBaseline, 0),
-		env:          env,
-		startTime:    time.Now(),
-	}, nil
+	e.env = env
+	e.baseEnv = env
+	return e, nil
 }
 
 // LoadRules compiles rules from the rules configuration
 func (e *Engine) LoadRules(rules *RulesConfig) error {
+	e.version = rules.Version()
+	if err := e.setLists(rules.Lists); err != nil {
+		return err
+	}
+
 	// Pre-allocate slices with capacity to avoid reallocations
 	enabledRules := 0
 	enabledCorrs := 0
@@ -157,75 +218,255 @@ func (e *Engine) LoadRules(rules *RulesConfig) error {
 	}
 
 	e.rules = make([]*CompiledRule, 0, enabledRules)
+	e.priority = nil
+	e.bulk = make([]*CompiledRule, 0, enabledRules)
 	e.correlations = make([]*CompiledCorrelation, 0, enabledCorrs)
 	e.baselines = make([]*CompiledBaseline, 0, enabledBaselines)
+	e.essentialBulk = nil
+	e.essentialCorrelations = nil
+	e.essentialBaselines = nil
+	e.sheddable = nil
+
+	for _, id := range e.suppressions.UnknownRules(rules) {
+		logger.Warn("suppressions for unknown rule %s are ignored", id)
+	}
 
 	// Compile each enabled rule
 	for _, rule := range rules.Rules {
 		if !rule.Enabled {
 			continue
 		}
-		compiled, err := e.compileExpression(rule.ID, rule.Expr)
+		scope, ok, err := e.scope(rule.HostSelector)
+		if err != nil {
+			return fmt.Errorf("failed to compile rule %s: %w", rule.ID, err)
+		}
+		if !ok {
+			logger.Verbose("rule %s is scoped to other hosts", rule.ID)
+			continue
+		}
+		compiled, err := e.newProgram(rule.ID, rule.Expr)
+		if err != nil {
+			return fmt.Errorf("failed to compile rule %s: %w", rule.ID, err)
+		}
+		compiled.scope = scope
+		exceptions, err := e.compileExceptions(rule.ID, rule.Exceptions)
+		if err != nil {
+			return fmt.Errorf("failed to compile rule %s: %w", rule.ID, err)
+		}
+		escalations, err := e.compileEscalations(rule.ID, rule.Escalations)
 		if err != nil {
 			return fmt.Errorf("failed to compile rule %s: %w", rule.ID, err)
 		}
-		e.rules = append(e.rules, &CompiledRule{
-			Rule:    rule,
-			Program: compiled,
-		})
+		sched, err := parseSchedule(rule.ActiveHours, rule.ActiveDays, rule.Timezone)
+		if err != nil {
+			return fmt.Errorf("failed to compile rule %s: %w", rule.ID, err)
+		}
+		cr := &CompiledRule{
+			Rule:        rule,
+			Program:     compiled,
+			Exceptions:  exceptions,
+			escalations: escalations,
+			kinds:       e.ruleKinds(rule.Expr),
+			schedule:    sched,
+		}
+		e.rules = append(e.rules, cr)
+		switch {
+		case rule.Priority:
+			e.priority = append(e.priority, cr)
+		case lowSeverity(highestSeverity(rule)):
+			e.bulk = append(e.bulk, cr)
+			e.sheddable = append(e.sheddable, rule.ID)
+		default:
+			e.bulk = append(e.bulk, cr)
+			e.essentialBulk = append(e.essentialBulk, cr)
+		}
 	}
 
 	// Compile each enabled correlation rule
-    for _, corr := range rules.Correlations {
-        if !corr.Enabled {
-            continue
-        }
-        compiled, err := e.compileExpression(corr.ID, corr.Expr)
-        if err != nil {
-            return fmt.Errorf("failed to compile correlation %s: %w", corr.ID, err)
-        }
-        e.correlations = append(e.correlations, &CompiledCorrelation{Rule: corr, Program: compiled})
-    }
+	for _, corr := range rules.Correlations {
+		if !corr.Enabled {
+			continue
+		}
+		scope, ok, err := e.scope(corr.HostSelector)
+		if err != nil {
+			return fmt.Errorf("failed to compile correlation %s: %w", corr.ID, err)
+		}
+		if !ok {
+			logger.Verbose("correlation %s is scoped to other hosts", corr.ID)
+			continue
+		}
+		compiled, err := e.newProgram(corr.ID, corr.Expr)
+		if err != nil {
+			return fmt.Errorf("failed to compile correlation %s: %w", corr.ID, err)
+		}
+		compiled.scope = scope
+		exceptions, err := e.compileExceptions(corr.ID, corr.Exceptions)
+		if err != nil {
+			return fmt.Errorf("failed to compile correlation %s: %w", corr.ID, err)
+		}
+		cc := &CompiledCorrelation{Rule: corr, Program: compiled, Exceptions: exceptions}
+		if corr.IsAbsence() {
+			if cc.Expect, err = e.newProgram(corr.ID, corr.Expect); err != nil {
+				return fmt.Errorf("failed to compile correlation %s expect: %w", corr.ID, err)
+			}
+		}
+		e.correlations = append(e.correlations, cc)
+		if lowSeverity(corr.Severity) {
+			e.sheddable = append(e.sheddable, corr.ID)
+		} else {
+			e.essentialCorrelations = append(e.essentialCorrelations, cc)
+		}
+	}
 
 	// Compile each enabled baseline rule
 	for _, baseline := range rules.Baselines {
 		if !baseline.Enabled {
 			continue
 		}
-		compiled, err := e.compileExpression(baseline.ID, baseline.Expr)
+		scope, ok, err := e.scope(baseline.HostSelector)
+		if err != nil {
+			return fmt.Errorf("failed to compile baseline %s: %w", baseline.ID, err)
+		}
+		if !ok {
+			logger.Verbose("baseline %s is scoped to other hosts", baseline.ID)
+			continue
+		}
+		compiled, err := e.newProgram(baseline.ID, baseline.Expr)
 		if err != nil {
 			return fmt.Errorf("failed to compile baseline %s: %w", baseline.ID, err)
 		}
-		e.baselines = append(e.baselines, &CompiledBaseline{
-			Rule:    baseline,
-			Program: compiled,
-		})
+		compiled.scope = scope
+		exceptions, err := e.compileExceptions(baseline.ID, baseline.Exceptions)
+		if err != nil {
+			return fmt.Errorf("failed to compile baseline %s: %w", baseline.ID, err)
+		}
+		cb := &CompiledBaseline{
+			Rule:       baseline,
+			Program:    compiled,
+			Exceptions: exceptions,
+		}
+		e.baselines = append(e.baselines, cb)
+		if lowSeverity(baseline.Severity) {
+			e.sheddable = append(e.sheddable, baseline.ID)
+		} else {
+			e.essentialBaselines = append(e.essentialBaselines, cb)
+		}
 	}
 
+	e.rulesByKind = newRuleIndex(e.rules)
+	e.priorityByKind = newRuleIndex(e.priority)
+	e.bulkByKind = newRuleIndex(e.bulk)
+	e.essentialBulkByKind = newRuleIndex(e.essentialBulk)
 	return nil
 }
 
+// CheckExpressions compiles the expression of every rule, including disabled
+// ones, and returns all compilation errors instead of stopping at the first
+func (e *Engine) CheckExpressions(rules *RulesConfig) []error {
+	if err := e.setLists(rules.Lists); err != nil {
+		return []error{err}
+	}
+	var errs []error
+	check := func(kind, id, expr string, exceptions []Exception) {
+		if _, err := e.compileExpression(id, expr); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", kind, id, err))
+		}
+		if _, err := e.compileExceptions(id, exceptions); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", kind, id, err))
+		}
+	}
+	for _, rule := range rules.Rules {
+		check("rule", rule.ID, rule.Expr, rule.Exceptions)
+		if _, err := e.compileEscalations(rule.ID, rule.Escalations); err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.ID, err))
+		}
+	}
+	for _, corr := range rules.Correlations {
+		check("correlation", corr.ID, corr.Expr, corr.Exceptions)
+		if corr.IsAbsence() {
+			if _, err := e.compileExpression(corr.ID, corr.Expect); err != nil {
+				errs = append(errs, fmt.Errorf("correlation %s expect: %w", corr.ID, err))
+			}
+		}
+	}
+	for _, baseline := range rules.Baselines {
+		check("baseline", baseline.ID, baseline.Expr, baseline.Exceptions)
+	}
+	return errs
+}
+
 // compileExpression compiles a CEL expression into an executable program.
 // Used for both simple rules and correlation rules.
 func (e *Engine) compileExpression(ruleID, expr string) (cel.Program, error) {
+	program, _, err := e.compileProgram(ruleID, expr)
+	return program, err
+}
+
+// compileProgram compiles expr like compileExpression and also reports
+// whether the program can be interrupted by the budget's timeout
+func (e *Engine) compileProgram(ruleID, expr string) (cel.Program, bool, error) {
 	// Parse the CEL expression
 	ast, issues := e.env.Compile(expr)
 	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("CEL compilation error: %w", issues.Err())
+		return nil, false, fmt.Errorf("CEL compilation error: %w", issues.Err())
 	}
 
 	// Validate that the expression returns a boolean
 	if !ast.OutputType().IsExactType(cel.BoolType) {
-		return nil, fmt.Errorf("expression must return boolean, got %v", ast.OutputType())
+		return nil, false, fmt.Errorf("expression must return boolean, got %v", ast.OutputType())
+	}
+	if err := checkFunctionArgs(ast); err != nil {
+		return nil, false, err
+	}
+	if err := checkListRefs(ast, e.lists); err != nil {
+		return nil, false, err
 	}
 
-	// Create the executable program
-	program, err := e.env.Program(ast)
+	// Create the executable program, bounded by the cost budget. Only
+	// comprehensions check for interruption, so only expressions with one
+	// pay for a timeout.
+	var opts []cel.ProgramOption
+	if e.budget.CostLimit > 0 {
+		opts = append(opts, cel.CostLimit(e.budget.CostLimit))
+	}
+	interruptible := e.budget.Timeout > 0 && hasComprehension(ast)
+	if interruptible {
+		opts = append(opts, cel.InterruptCheckFrequency(interruptCheckFrequency))
+	}
+	program, err := e.env.Program(ast, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("program creation error: %w", err)
+		return nil, false, fmt.Errorf("program creation error: %w", err)
 	}
 
-	return program, nil
+	return program, interruptible, nil
+}
+
+// Filter is a standalone CEL expression evaluated against single events
+type Filter struct {
+	Expr    string
+	program cel.Program
+}
+
+// CompileFilter compiles a boolean CEL expression using the rule environment
+func (e *Engine) CompileFilter(expr string) (*Filter, error) {
+	program, err := e.compileExpression("filter", expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{Expr: expr, program: program}, nil
+}
+
+// Match reports whether the event satisfies the filter
+func (f *Filter) Match(msg *santapb.SantaMessage) (bool, error) {
+	result, _, err := f.program.Eval(BuildActivation(msg))
+	if err != nil {
+		return false, err
+	}
+	matched, ok := result.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("filter returned non-boolean: %T", result.Value())
+	}
+	return matched, nil
 }
 
 // BuildActivation creates a CEL activation map from a Santa message with all required variables
@@ -248,40 +489,102 @@ func BuildActivation(msg *santapb.SantaMessage) map[string]any {
 
 // Evaluate runs all rules against an event and returns matches.
 func (e *Engine) Evaluate(msg *santapb.SantaMessage) ([]*Match, error) {
-	if len(e.rules) == 0 {
+	return e.evaluate(NewEvent(msg), e.rulesByKind)
+}
+
+// EvaluateEvent runs all rules against an event, reusing its cached activation.
+func (e *Engine) EvaluateEvent(ev *Event) ([]*Match, error) {
+	return e.evaluate(ev, e.rulesByKind)
+}
+
+// EvaluatePriority runs only priority rules against an event (the fast lane).
+func (e *Engine) EvaluatePriority(ev *Event) ([]*Match, error) {
+	return e.evaluate(ev, e.priorityByKind)
+}
+
+// EvaluateBulk runs only non-priority rules against an event.
+func (e *Engine) EvaluateBulk(ev *Event) ([]*Match, error) {
+	return e.evaluate(ev, e.bulkByKind)
+}
+
+// EvaluateBulkEssential evaluates the non-priority rules kept under load
+// shedding (severity above low)
+func (e *Engine) EvaluateBulkEssential(ev *Event) ([]*Match, error) {
+	return e.evaluate(ev, e.essentialBulkByKind)
+}
+
+// HasPriorityRules reports whether any enabled rule is marked priority
+func (e *Engine) HasPriorityRules() bool {
+	return len(e.priority) > 0
+}
+
+// evaluate runs the indexed rules that can match the event's kind against it
+func (e *Engine) evaluate(ev *Event, idx ruleIndex) ([]*Match, error) {
+	msg := ev.Message()
+	rules := idx.forKind(events.Kind(msg))
+	if len(rules) == 0 {
 		return nil, nil
 	}
 
-	activation := BuildActivation(msg)
+	activation := ev.Activation()
+	ts := events.EventTime(msg)
+	when := ts // Schedules fall back to the time of evaluation
+	if when.IsZero() {
+		when = time.Now()
+	}
 
 	// Pre-allocate assuming ~5% match rate (tune based on real-world data)
-	matches := make([]*Match, 0, max(1, len(e.rules)/20))
+	matches := make([]*Match, 0, max(1, len(rules)/20))
 
 	// Evaluate each rule
-	for _, compiled := range e.rules {
+	for _, compiled := range rules {
+		if compiled.Program.Disabled() {
+			continue
+		}
+		// Rules outside their active hours are not evaluated at all
+		if compiled.schedule != nil && !compiled.schedule.active(when) {
+			continue
+		}
+		var start time.Time
+		if e.stats != nil {
+			start = time.Now()
+		}
 		result, _, err := compiled.Program.Eval(activation)
 		if err != nil {
 			// Log error but continue with other rules to avoid single rule failure breaking all detection
-			logutil.Warn("rule evaluation error for %s: %v", compiled.Rule.ID, err)
+			logger.Warn("rule evaluation error for %s: %v", compiled.Rule.ID, err)
+			e.evalErrors.Add(1)
 			continue
 		}
 
 		// Check if rule matched
 		matched, ok := result.Value().(bool)
 		if !ok {
-			logutil.Warn("rule %s returned non-boolean: %T", compiled.Rule.ID, result.Value())
+			logger.Warn("rule %s returned non-boolean: %T", compiled.Rule.ID, result.Value())
+			e.evalErrors.Add(1)
 			continue
 		}
+		if matched && compiled.Exceptions.Suppress(activation) {
+			matched = false
+		}
+		var elapsed time.Duration
+		if e.stats != nil {
+			elapsed = time.Since(start)
+			e.recordStats(compiled.Rule.ID, elapsed, matched)
+		}
 
 		if matched {
 			matches = append(matches, &Match{
-				RuleID:    compiled.Rule.ID,
-				Title:     compiled.Rule.Title,
-				Severity:  compiled.Rule.Severity,
-				Tags:      compiled.Rule.Tags,
-				Message:   msg,
-				Timestamp: events.EventTime(msg),
-				Rule:      compiled.Rule,
+				RuleID:       compiled.Rule.ID,
+				Title:        compiled.Rule.Title,
+				Severity:     e.severity(compiled, activation),
+				Tags:         compiled.Rule.Tags,
+				Message:      msg,
+				Timestamp:    ts,
+				Rule:         compiled.Rule,
+				EvalStart:    start,
+				EvalDuration: elapsed,
+				event:        ev,
 			})
 		}
 	}
@@ -307,12 +610,106 @@ func (e *Engine) GetBaselines() []*CompiledBaseline {
 	return e.baselines
 }
 
+// GetEssentialCorrelations returns the correlation rules kept under load shedding
+func (e *Engine) GetEssentialCorrelations() []*CompiledCorrelation {
+	return e.essentialCorrelations
+}
+
+// GetEssentialBaselines returns the baseline rules kept under load shedding
+func (e *Engine) GetEssentialBaselines() []*CompiledBaseline {
+	return e.essentialBaselines
+}
+
+// SheddableRules returns the IDs of the rules skipped under load shedding:
+// non-priority simple, correlation and baseline rules with info or low severity
+func (e *Engine) SheddableRules() []string {
+	return e.sheddable
+}
+
+// lowSeverity reports whether rules of this severity may be shed under load
+func lowSeverity(severity string) bool {
+	return severity == "info" || severity == "low"
+}
+
+// Version returns the version of the loaded rules
+func (e *Engine) Version() string {
+	return e.version
+}
+
+// CollectStats makes evaluations accumulate per-rule cost into stats, keyed
+// by rule ID, until called again with nil. Rules are not timed while stats
+// is nil.
+func (e *Engine) CollectStats(stats map[string]*RuleStats) {
+	e.stats = stats
+}
+
+func (e *Engine) recordStats(ruleID string, d time.Duration, matched bool) {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+	st := e.stats[ruleID]
+	if st == nil {
+		st = &RuleStats{}
+		e.stats[ruleID] = st
+	}
+	st.Evaluations++
+	st.Duration += d
+	if matched {
+		st.Matches++
+	}
+}
+
+// EvalErrors returns how many rule evaluations failed since the rules were loaded
+func (e *Engine) EvalErrors() int64 {
+	return e.evalErrors.Load()
+}
+
+// InheritStartTime carries the learning period clock over from prev, so
+// reloading rules does not restart baseline learning
+func (e *Engine) InheritStartTime(prev *Engine) {
+	if prev != nil {
+		e.startTime = prev.startTime
+	}
+}
+
+// SetStartTime starts the learning period clock at t instead of when the
+// rules were loaded, e.g. at the first event when replaying historical files
+func (e *Engine) SetStartTime(t time.Time) {
+	e.startTime = t
+}
+
+// SetLearningStart starts the learning period of baseline rule ruleID at t,
+// e.g. when it was first loaded in an earlier run of the agent
+func (e *Engine) SetLearningStart(ruleID string, t time.Time) {
+	if e.learningFrom == nil {
+		e.learningFrom = make(map[string]time.Time)
+	}
+	e.learningFrom[ruleID] = t
+}
+
+// LearningEnd returns when a baseline rule's learning period ends, or the
+// zero time if it has none
+func (e *Engine) LearningEnd(baseline *BaselineRule) time.Time {
+	if baseline.LearningPeriod == 0 {
+		return time.Time{}
+	}
+	start := e.startTime
+	if t, ok := e.learningFrom[baseline.ID]; ok {
+		start = t
+	}
+	return start.Add(baseline.LearningPeriod)
+}
+
 // IsInLearningPeriod checks if a baseline rule is still in its learning period
 func (e *Engine) IsInLearningPeriod(baseline *BaselineRule) bool {
+	return e.IsInLearningPeriodAt(baseline, time.Now())
+}
+
+// IsInLearningPeriodAt checks if a baseline rule is in its learning period at now
+func (e *Engine) IsInLearningPeriodAt(baseline *BaselineRule, now time.Time) bool {
 	if baseline.LearningPeriod == 0 {
 		return false
 	}
-	return time.Since(e.startTime) < baseline.LearningPeriod
+	return now.Before(e.LearningEnd(baseline))
 }
 
 // GetEnv returns the CEL environment (used for testing)
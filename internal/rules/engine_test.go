@@ -321,7 +321,6 @@ func TestEvaluate(t *testing.T) {
 	}
 }
 
-
 func TestEvaluateEmpty(t *testing.T) {
 	engine, err := NewEngine()
 	if err != nil {
@@ -355,6 +354,345 @@ func TestEvaluateEmpty(t *testing.T) {
 	}
 }
 
+func TestEvaluatePriorityLanes(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+
+	err = engine.LoadRules(&RulesConfig{
+		Rules: []*Rule{
+			{
+				ID:       "EXEC-ANY",
+				Title:    "Any Execution",
+				Expr:     "kind == \"execution\"",
+				Severity: "low",
+				Enabled:  true,
+			},
+			{
+				ID:       "EXEC-DENY",
+				Title:    "Execution Denied",
+				Expr:     "kind == \"execution\" && event.execution.decision == DECISION_DENY",
+				Severity: "critical",
+				Enabled:  true,
+				Priority: true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	if !engine.HasPriorityRules() {
+		t.Fatal("expected HasPriorityRules() to be true")
+	}
+
+	msg := &santapb.SantaMessage{
+		MachineId:       proto.String("test-machine"),
+		BootSessionUuid: proto.String("boot-123"),
+		EventTime:       timestamppb.New(time.Now()),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Decision: santapb.Execution_DECISION_DENY.Enum(),
+			},
+		},
+	}
+
+	priority, err := engine.EvaluatePriority(NewEvent(msg))
+	if err != nil {
+		t.Fatalf("EvaluatePriority() failed: %v", err)
+	}
+	if len(priority) != 1 || priority[0].RuleID != "EXEC-DENY" {
+		t.Errorf("EvaluatePriority() = %v, want [EXEC-DENY]", priority)
+	}
+
+	bulk, err := engine.EvaluateBulk(NewEvent(msg))
+	if err != nil {
+		t.Fatalf("EvaluateBulk() failed: %v", err)
+	}
+	if len(bulk) != 1 || bulk[0].RuleID != "EXEC-ANY" {
+		t.Errorf("EvaluateBulk() = %v, want [EXEC-ANY]", bulk)
+	}
+
+	// Evaluate still covers both lanes
+	all, err := engine.Evaluate(msg)
+	if err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Evaluate() returned %d matches, want 2", len(all))
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+
+	filter, err := engine.CompileFilter(`kind == "execution" && event.execution.decision == DECISION_DENY`)
+	if err != nil {
+		t.Fatalf("CompileFilter() failed: %v", err)
+	}
+
+	deny := &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{Decision: santapb.Execution_DECISION_DENY.Enum()},
+		},
+	}
+	allow := &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{Decision: santapb.Execution_DECISION_ALLOW.Enum()},
+		},
+	}
+
+	if ok, err := filter.Match(deny); err != nil || !ok {
+		t.Errorf("Match(deny) = %v, %v; want true", ok, err)
+	}
+	if ok, err := filter.Match(allow); err != nil || ok {
+		t.Errorf("Match(allow) = %v, %v; want false", ok, err)
+	}
+
+	if _, err := engine.CompileFilter(`kind`); err == nil {
+		t.Error("Expected error for non-boolean filter")
+	}
+}
+
+func TestRuleExceptions(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{{
+		ID:       "R1",
+		Title:    "Exec",
+		Expr:     `kind == "execution"`,
+		Severity: "low",
+		Enabled:  true,
+		Exceptions: []Exception{
+			{Expr: `event.execution.target.executable.path.startsWith("/Applications/")`},
+			{Expr: `event.execution.decision == DECISION_DENY`},
+			{Field: "event.execution.target.executable.path", Values: []string{"/usr/bin/true", "/usr/bin/false"}},
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	exec := func(path string, decision santapb.Execution_Decision) *santapb.SantaMessage {
+		return &santapb.SantaMessage{
+			Event: &santapb.SantaMessage_Execution{
+				Execution: &santapb.Execution{
+					Target:   &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}},
+					Decision: decision.Enum(),
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want int
+	}{
+		{"no exception", exec("/tmp/evil", santapb.Execution_DECISION_ALLOW), 1},
+		{"path exception", exec("/Applications/Tool.app/Contents/MacOS/tool", santapb.Execution_DECISION_ALLOW), 0},
+		{"decision exception", exec("/tmp/evil", santapb.Execution_DECISION_DENY), 0},
+		{"value list exception", exec("/usr/bin/true", santapb.Execution_DECISION_ALLOW), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := engine.Evaluate(tt.msg)
+			if err != nil {
+				t.Fatalf("Evaluate() failed: %v", err)
+			}
+			if len(matches) != tt.want {
+				t.Errorf("Evaluate() returned %d matches, want %d", len(matches), tt.want)
+			}
+		})
+	}
+	if got := engine.Suppressions(); got["R1"] != 3 {
+		t.Errorf("Suppressions() = %v, want R1: 3", got)
+	}
+
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{{
+		ID: "R2", Title: "Bad", Expr: "true", Severity: "low", Enabled: true,
+		Exceptions: []Exception{{Expr: "kind"}},
+	}}})
+	if err == nil {
+		t.Error("Expected error for non-boolean exception")
+	}
+}
+
+func TestVersionAndEvalErrors(t *testing.T) {
+	rc := &RulesConfig{Rules: []*Rule{{
+		ID:       "R1",
+		Title:    "Broken at runtime",
+		Expr:     `int(kind) > 0`,
+		Severity: "low",
+		Enabled:  true,
+	}}}
+
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	if engine.Version() == "" || engine.Version() != rc.Version() {
+		t.Errorf("Version() = %q, want %q", engine.Version(), rc.Version())
+	}
+
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+	for i := 0; i < 3; i++ {
+		if _, err := engine.Evaluate(msg); err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+	}
+	if got := engine.EvalErrors(); got != 3 {
+		t.Errorf("EvalErrors() = %d, want 3", got)
+	}
+
+	rc.Rules[0].Expr = `kind == "execution"`
+	if v := rc.Version(); v == engine.Version() {
+		t.Error("Version() did not change with the rules")
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	rc := &RulesConfig{Rules: []*Rule{
+		{ID: "EXEC", Title: "Exec", Expr: `kind == "execution"`, Severity: "low", Enabled: true},
+		{ID: "NEVER", Title: "Never", Expr: `machine_id == "none"`, Severity: "low", Enabled: true},
+		{ID: "FORK", Title: "Fork", Expr: `kind == "fork"`, Severity: "low", Enabled: true},
+	}}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+	stats := map[string]*RuleStats{}
+	engine.CollectStats(stats)
+	for i := 0; i < 2; i++ {
+		matches, err := engine.Evaluate(msg)
+		if err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+		// Matches carry their evaluation time for tracing
+		if len(matches) != 1 || matches[0].EvalStart.IsZero() {
+			t.Errorf("Matches while collecting stats = %+v, want EXEC with its evaluation time", matches)
+		}
+	}
+	engine.CollectStats(nil)
+	matches, err := engine.Evaluate(msg)
+	if err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if len(matches) != 1 || !matches[0].EvalStart.IsZero() || matches[0].EvalDuration != 0 {
+		t.Errorf("Match without stats = %+v, want no evaluation time", matches[0])
+	}
+
+	if st := stats["EXEC"]; st == nil || st.Evaluations != 2 || st.Matches != 2 {
+		t.Errorf("EXEC stats = %+v, want 2 evaluations and 2 matches", st)
+	}
+	if st := stats["NEVER"]; st == nil || st.Evaluations != 2 || st.Matches != 0 {
+		t.Errorf("NEVER stats = %+v, want 2 evaluations and no matches", st)
+	}
+	// Rules for other event kinds are not evaluated
+	if st := stats["FORK"]; st != nil {
+		t.Errorf("FORK stats = %+v, want none", st)
+	}
+}
+
+func TestEssentialRules(t *testing.T) {
+	rc := &RulesConfig{
+		Rules: []*Rule{
+			{ID: "HIGH", Title: "High", Expr: `kind == "execution"`, Severity: "high", Enabled: true},
+			{ID: "LOW", Title: "Low", Expr: `kind == "execution"`, Severity: "low", Enabled: true},
+			{ID: "LOW-PRIO", Title: "Low priority lane", Expr: `kind == "execution"`, Severity: "low", Priority: true, Enabled: true},
+		},
+		Correlations: []*CorrelationRule{
+			{ID: "CORR-INFO", Title: "Info", Expr: `kind == "execution"`, Window: time.Minute, Threshold: 2, Severity: "info", Enabled: true},
+		},
+		Baselines: []*BaselineRule{
+			{ID: "BASE-MED", Title: "Medium", Expr: `kind == "execution"`, Track: []string{"kind"}, Severity: "medium", Enabled: true},
+		},
+	}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	if got := engine.SheddableRules(); len(got) != 2 || got[0] != "LOW" || got[1] != "CORR-INFO" {
+		t.Errorf("SheddableRules() = %v, want [LOW CORR-INFO]", got)
+	}
+	if len(engine.GetEssentialCorrelations()) != 0 || len(engine.GetEssentialBaselines()) != 1 {
+		t.Errorf("Unexpected essential correlations/baselines")
+	}
+
+	msg := &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}}}
+	matches, err := engine.EvaluateBulkEssential(NewEvent(msg))
+	if err != nil {
+		t.Fatalf("EvaluateBulkEssential() failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].RuleID != "HIGH" {
+		t.Errorf("EvaluateBulkEssential() matched %d rules, want only HIGH", len(matches))
+	}
+}
+
+func TestInheritStartTime(t *testing.T) {
+	prev, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	prev.startTime = time.Now().Add(-2 * time.Hour)
+
+	next, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	baseline := &BaselineRule{LearningPeriod: time.Hour}
+	if !next.IsInLearningPeriod(baseline) {
+		t.Fatal("New engine should start in its learning period")
+	}
+
+	next.InheritStartTime(prev)
+	if next.IsInLearningPeriod(baseline) {
+		t.Error("Reloaded engine restarted the learning period")
+	}
+}
+
+func TestSetLearningStart(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	learned := &BaselineRule{ID: "B1", LearningPeriod: time.Hour}
+	learning := &BaselineRule{ID: "B2", LearningPeriod: time.Hour}
+
+	engine.SetLearningStart("B1", time.Now().Add(-2*time.Hour))
+	if engine.IsInLearningPeriod(learned) {
+		t.Error("Rule with an earlier learning start is still learning")
+	}
+	if !engine.IsInLearningPeriod(learning) {
+		t.Error("Rule without a learning start should use the engine start")
+	}
+	if end := engine.LearningEnd(learned); !end.Before(time.Now()) {
+		t.Errorf("LearningEnd = %v, want in the past", end)
+	}
+	if end := engine.LearningEnd(&BaselineRule{ID: "B3"}); !end.IsZero() {
+		t.Errorf("LearningEnd without a learning period = %v, want zero", end)
+	}
+}
+
 func TestCompileExpression(t *testing.T) {
 	engine, err := NewEngine()
 	if err != nil {
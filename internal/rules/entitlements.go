@@ -0,0 +1,56 @@
+package rules
+
+import (
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// entitlementFunctions declares has_entitlement(glob): the target of the
+// execution being evaluated has an entitlement whose key matches the glob
+// (see glob) and whose value is not false. Like the ancestry functions, a
+// macro passes the event along.
+func entitlementFunctions(eventType *cel.Type) []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Macros(withEvent("has_entitlement", 1)),
+		cel.Function("has_entitlement",
+			cel.Overload("has_entitlement_event_string", []*cel.Type{eventType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(ev, pattern ref.Val) ref.Val {
+					msg, ok := ev.Value().(*santapb.SantaMessage)
+					if !ok {
+						return types.False
+					}
+					re, err := compilePattern(string(pattern.(types.String)), true)
+					if err != nil {
+						return types.NewErr("has_entitlement: %v", err)
+					}
+					for key, value := range events.Entitlements(msg) {
+						if value != "false" && re.MatchString(key) {
+							return types.True
+						}
+					}
+					return types.False
+				}))),
+	}
+}
+
+// MatchedEntitlements returns the entitlements of msg's target that match the
+// rule's include_entitlements globs, or nil when none do
+func (r *Rule) MatchedEntitlements(msg *santapb.SantaMessage) map[string]string {
+	var matched map[string]string
+	for key, value := range events.Entitlements(msg) {
+		for _, g := range r.Entitlements {
+			if re, err := compilePattern(g, true); err == nil && re.MatchString(key) {
+				if matched == nil {
+					matched = make(map[string]string)
+				}
+				matched[key] = value
+				break
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Escalation raises the severity of a rule's matches for which When is true,
+// instead of duplicating the rule for each severity:
+//
+//	severity: medium
+//	escalate:
+//	  - when: event.execution.decision == DECISION_DENY
+//	    severity: high
+type Escalation struct {
+	When     string `yaml:"when"`
+	Severity string `yaml:"severity"`
+}
+
+// compiledEscalation is an escalation with its compiled condition
+type compiledEscalation struct {
+	program  cel.Program
+	severity string
+}
+
+// validateEscalations checks that each escalation has a condition and raises
+// the rule's base severity
+func validateEscalations(base string, escalations []Escalation) error {
+	for i, esc := range escalations {
+		if strings.TrimSpace(esc.When) == "" {
+			return fmt.Errorf("escalate %d: when is required", i)
+		}
+		if !ValidSeverities[esc.Severity] {
+			return fmt.Errorf("escalate %d: %w", i, ErrInvalidSeverity(esc.Severity))
+		}
+		if severityRank[esc.Severity] <= severityRank[base] {
+			return fmt.Errorf("escalate %d: severity %s does not raise %s", i, esc.Severity, base)
+		}
+	}
+	return nil
+}
+
+// compileEscalations compiles escalation conditions, most severe first, so
+// the first one that matches gives the severity
+func (e *Engine) compileEscalations(ruleID string, escalations []Escalation) ([]compiledEscalation, error) {
+	compiled := make([]compiledEscalation, 0, len(escalations))
+	for i, esc := range escalations {
+		program, err := e.compileExpression(ruleID, esc.When)
+		if err != nil {
+			return nil, fmt.Errorf("escalate %d: %w", i, err)
+		}
+		compiled = append(compiled, compiledEscalation{program: program, severity: esc.Severity})
+	}
+	slices.SortStableFunc(compiled, func(a, b compiledEscalation) int {
+		return severityRank[b.severity] - severityRank[a.severity]
+	})
+	return compiled, nil
+}
+
+// severity returns the severity of a match: the most severe escalation whose
+// condition holds, else the rule's own. Conditions that fail to evaluate are
+// counted as evaluation errors and skipped.
+func (e *Engine) severity(cr *CompiledRule, activation any) string {
+	for _, esc := range cr.escalations {
+		out, _, err := esc.program.Eval(activation)
+		if err != nil {
+			logger.Warn("escalation evaluation error for %s: %v", cr.Rule.ID, err)
+			e.evalErrors.Add(1)
+			continue
+		}
+		if matched, _ := out.Value().(bool); matched {
+			return esc.severity
+		}
+	}
+	return cr.Rule.Severity
+}
+
+// highestSeverity returns the most severe severity a rule's matches can have
+func highestSeverity(r *Rule) string {
+	highest := r.Severity
+	for _, esc := range r.Escalations {
+		if severityRank[esc.Severity] > severityRank[highest] {
+			highest = esc.Severity
+		}
+	}
+	return highest
+}
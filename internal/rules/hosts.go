@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Host describes the agent evaluating the rules, for host-scoped rules
+type Host struct {
+	Tags map[string]string // agent.tags, e.g. role: developer
+}
+
+// HostSelector scopes a rule to part of a fleet, so one rules bundle can
+// serve different kinds of hosts. Each selector is either key=value, matching
+// the agent's host tag key (the value may be a glob), or a glob of the
+// event's machine_id. A rule with hosts applies where any of them matches;
+// exclude_hosts takes precedence.
+type HostSelector struct {
+	Hosts        []string `yaml:"hosts,omitempty"`
+	ExcludeHosts []string `yaml:"exclude_hosts,omitempty"`
+}
+
+// Validate checks the syntax of the selectors
+func (s HostSelector) Validate() error {
+	for _, field := range []struct {
+		name      string
+		selectors []string
+	}{{"hosts", s.Hosts}, {"exclude_hosts", s.ExcludeHosts}} {
+		for _, sel := range field.selectors {
+			if _, _, err := parseHostSelector(sel); err != nil {
+				return fmt.Errorf("%s: %w", field.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseHostSelector splits a selector into a tag key (empty for a machine_id
+// glob) and the compiled glob of the value
+func parseHostSelector(sel string) (string, *regexp.Regexp, error) {
+	sel = strings.TrimSpace(sel)
+	if sel == "" {
+		return "", nil, fmt.Errorf("empty host selector")
+	}
+	key, pattern, isTag := strings.Cut(sel, "=")
+	if !isTag {
+		key, pattern = "", sel
+	} else if key = strings.TrimSpace(key); key == "" || strings.ContainsAny(key, " \t*?") {
+		return "", nil, fmt.Errorf("invalid host tag %q in %q", key, sel)
+	}
+	re, err := compilePattern(strings.TrimSpace(pattern), true)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid host selector %q: %w", sel, err)
+	}
+	return key, re, nil
+}
+
+// SetHost scopes rules loaded afterwards to host: rules whose host tag
+// selectors exclude it are not loaded, and machine_id selectors are checked
+// per event. Without a host, as when validating rules, every rule is loaded
+// and applies to every event.
+func (e *Engine) SetHost(host *Host) {
+	e.host = host
+}
+
+// hostScope is the part of a rule's host selection decided per event
+type hostScope struct {
+	include []*regexp.Regexp // machine_id globs, one of which must match; nil when the host tags already matched
+	exclude []*regexp.Regexp // machine_id globs, none of which may match
+}
+
+// scope resolves a rule's selectors against the host. It reports false when
+// the rule cannot apply on this host, and returns the per-event scope, or nil
+// when the rule applies to every event.
+func (e *Engine) scope(sel HostSelector) (*hostScope, bool, error) {
+	if e.host == nil || (len(sel.Hosts) == 0 && len(sel.ExcludeHosts) == 0) {
+		return nil, true, nil
+	}
+	tagged := func(key string, re *regexp.Regexp) bool {
+		value, ok := e.host.Tags[key]
+		return ok && re.MatchString(value)
+	}
+
+	s := &hostScope{}
+	included := len(sel.Hosts) == 0
+	for _, h := range sel.Hosts {
+		key, re, err := parseHostSelector(h)
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case key == "":
+			s.include = append(s.include, re)
+		case tagged(key, re):
+			included = true
+		}
+	}
+	for _, h := range sel.ExcludeHosts {
+		key, re, err := parseHostSelector(h)
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case key == "":
+			s.exclude = append(s.exclude, re)
+		case tagged(key, re):
+			return nil, false, nil
+		}
+	}
+
+	if included {
+		s.include = nil
+	} else if len(s.include) == 0 {
+		return nil, false, nil
+	}
+	if len(s.include) == 0 && len(s.exclude) == 0 {
+		return nil, true, nil
+	}
+	return s, true, nil
+}
+
+// matches reports whether the rule applies to an event of machineID
+func (s *hostScope) matches(machineID string) bool {
+	anyMatch := func(res []*regexp.Regexp) bool {
+		for _, re := range res {
+			if re.MatchString(machineID) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(s.include) > 0 && !anyMatch(s.include) {
+		return false
+	}
+	return !anyMatch(s.exclude)
+}
+
+// inScope reports whether the activation's event is from a host the program
+// applies to
+func (p *Program) inScope(input any) bool {
+	if p.scope == nil {
+		return true
+	}
+	activation, _ := input.(map[string]any)
+	machineID, _ := activation["machine_id"].(string)
+	return p.scope.matches(machineID)
+}
@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+const hostScopedRules = `
+rules:
+  - id: DEV-ONLY
+    title: Developer hosts
+    expr: kind == "execution"
+    hosts: ["role=dev*"]
+    severity: low
+    enabled: true
+  - id: NOT-KIOSK
+    title: Everywhere but kiosks
+    expr: kind == "execution"
+    exclude_hosts: ["role=kiosk"]
+    severity: low
+    enabled: true
+  - id: BUILD-FLEET
+    title: Build machines
+    expr: kind == "execution"
+    hosts: ["build-*"]
+    exclude_hosts: ["build-99"]
+    severity: low
+    enabled: true
+  - id: SERVERS-OR-CANARY
+    title: Servers or the canary
+    expr: kind == "execution"
+    hosts: ["role=server", "canary-01"]
+    severity: low
+    enabled: true
+correlations:
+  - id: DEV-BURST
+    title: Execution burst on a developer host
+    expr: kind == "execution"
+    window: 1m
+    group_by: [machine_id]
+    threshold: 100
+    hosts: ["role=developer"]
+    severity: low
+    enabled: true
+`
+
+func TestHostScoping(t *testing.T) {
+	rc, err := Parse([]byte(hostScopedRules))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	exec := func(machineID string) *santapb.SantaMessage {
+		return &santapb.SantaMessage{
+			MachineId: proto.String(machineID),
+			Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String("/bin/ls")}},
+			}},
+		}
+	}
+	tests := []struct {
+		name      string
+		host      *Host
+		machineID string
+		want      []string
+	}{
+		{"no host", nil, "m1", []string{"BUILD-FLEET", "DEV-ONLY", "NOT-KIOSK", "SERVERS-OR-CANARY"}},
+		{"developer", &Host{Tags: map[string]string{"role": "developer"}}, "m1", []string{"DEV-ONLY", "NOT-KIOSK"}},
+		{"kiosk", &Host{Tags: map[string]string{"role": "kiosk"}}, "m1", nil},
+		{"untagged build machine", &Host{}, "build-07", []string{"BUILD-FLEET", "NOT-KIOSK"}},
+		{"excluded build machine", &Host{}, "build-99", []string{"NOT-KIOSK"}},
+		{"canary", &Host{}, "canary-01", []string{"NOT-KIOSK", "SERVERS-OR-CANARY"}},
+		{"server", &Host{Tags: map[string]string{"role": "server"}}, "web-1", []string{"NOT-KIOSK", "SERVERS-OR-CANARY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngine()
+			if err != nil {
+				t.Fatalf("NewEngine() failed: %v", err)
+			}
+			engine.SetHost(tt.host)
+			if err := engine.LoadRules(rc); err != nil {
+				t.Fatalf("LoadRules() failed: %v", err)
+			}
+			matches, err := engine.Evaluate(exec(tt.machineID))
+			if err != nil {
+				t.Fatalf("Evaluate() failed: %v", err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.RuleID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Rules that cannot apply on the host are not loaded at all
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	engine.SetHost(&Host{Tags: map[string]string{"role": "kiosk"}})
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	if n := len(engine.correlations); n != 0 {
+		t.Errorf("Loaded %d correlations on a kiosk, want 0", n)
+	}
+}
+
+func TestHostSelectorErrors(t *testing.T) {
+	for sel, wantErr := range map[string]string{
+		"":          "empty host selector",
+		"=dev":      "invalid host tag",
+		"ro le=dev": "invalid host tag",
+		"build-[":   "invalid host selector",
+	} {
+		err := HostSelector{ExcludeHosts: []string{sel}}.Validate()
+		if err == nil || !strings.Contains(err.Error(), wantErr) || !strings.Contains(err.Error(), "exclude_hosts") {
+			t.Errorf("Validate(%q) error = %v, want %q", sel, err, wantErr)
+		}
+	}
+	if _, err := Parse([]byte("rules:\n  - id: R1\n    title: T\n    expr: kind == \"execution\"\n    hosts: [\"=x\"]\n    severity: low\n    enabled: true\n")); err == nil {
+		t.Error("Parse() accepted an invalid host selector")
+	}
+}
@@ -0,0 +1,211 @@
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeKeyPrefix names the hidden top-level keys included files are nested
+// under when a rules file is expanded
+const includeKeyPrefix = "__santamon_include_"
+
+// maxIncludeDepth bounds how deeply includes may nest
+const maxIncludeDepth = 8
+
+// expandedFile is a rules file with its includes resolved
+type expandedFile struct {
+	data     []byte   // Included files nested ahead of the file's own content
+	included []string // Every file pulled in, directly or transitively
+}
+
+// expandIncludes reads a rules file and resolves its include directive.
+// Included files are nested under hidden keys ahead of the file's own
+// content, so anchors they define can be referenced from the including file.
+func expandIncludes(path string, stack []string) (*expandedFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+		}
+	}
+	if len(stack) > maxIncludeDepth {
+		return nil, fmt.Errorf("includes nested deeper than %d at %s", maxIncludeDepth, path)
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	data, err = singleDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	patterns, err := includePatterns(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(patterns) == 0 {
+		return &expandedFile{data: data}, nil
+	}
+
+	var buf bytes.Buffer
+	var included []string
+	n := 0
+	for _, pattern := range patterns {
+		if filepath.IsAbs(pattern) {
+			return nil, fmt.Errorf("%s: include %q must be relative to the including file", path, pattern)
+		}
+		matches, err := filepath.Glob(filepath.Join(filepath.Dir(abs), pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %q: %w", path, pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: include %q matched no files", path, pattern)
+		}
+		for _, match := range matches {
+			inc, err := expandIncludes(match, stack)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, "%s%d:\n", includeKeyPrefix, n)
+			indent(&buf, inc.data)
+			included = append(included, match)
+			included = append(included, inc.included...)
+			n++
+		}
+	}
+	buf.Write(data)
+
+	return &expandedFile{data: buf.Bytes(), included: included}, nil
+}
+
+// includePatterns extracts the top-level include directive. It is read from
+// the raw text because the file cannot be parsed as YAML until the anchors
+// it references from included files are in place.
+func includePatterns(data []byte) ([]string, error) {
+	var snippet []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if snippet == nil {
+			if strings.HasPrefix(line, "include:") {
+				snippet = append(snippet, line)
+			}
+			continue
+		}
+		// The directive ends at the next top-level key
+		if line != "" && line[0] != ' ' && line[0] != '#' && line[0] != '-' {
+			break
+		}
+		snippet = append(snippet, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if snippet == nil {
+		return nil, nil
+	}
+
+	var doc struct {
+		Include yaml.Node `yaml:"include"`
+	}
+	if err := yaml.Unmarshal([]byte(strings.Join(snippet, "\n")), &doc); err != nil {
+		return nil, fmt.Errorf("invalid include directive: %w", err)
+	}
+	var patterns []string
+	switch doc.Include.Kind {
+	case yaml.ScalarNode:
+		patterns = []string{doc.Include.Value}
+	case yaml.SequenceNode:
+		if err := doc.Include.Decode(&patterns); err != nil {
+			return nil, fmt.Errorf("invalid include directive: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("include must be a path or a list of paths")
+	}
+	for i, p := range patterns {
+		if strings.TrimSpace(p) == "" {
+			return nil, ErrInvalidField("include", i)
+		}
+	}
+	return patterns, nil
+}
+
+// singleDocument strips a leading document marker and rejects multi-document
+// files, which cannot be nested into an including file
+func singleDocument(data []byte) ([]byte, error) {
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte("---")) {
+			continue
+		}
+		if i == 0 {
+			lines[0] = nil
+			continue
+		}
+		return nil, fmt.Errorf("multiple YAML documents are not supported in rules files")
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
+
+// indent writes data indented one level, for nesting under a mapping key
+func indent(buf *bytes.Buffer, data []byte) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			buf.WriteByte('\n')
+			continue
+		}
+		buf.WriteString("  ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+}
+
+// decodeExpanded decodes an expanded rules file. Rules from included files
+// come before the file's own rules.
+func decodeExpanded(data []byte) (*RulesConfig, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return &RulesConfig{}, nil
+	}
+	return decodeNode(root.Content[0])
+}
+
+func decodeNode(node *yaml.Node) (*RulesConfig, error) {
+	merged := &RulesConfig{}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !strings.HasPrefix(node.Content[i].Value, includeKeyPrefix) {
+				continue
+			}
+			inc, err := decodeNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			merged.Merge(inc)
+		}
+	}
+
+	var own RulesConfig
+	if err := node.Decode(&own); err != nil {
+		return nil, err
+	}
+	merged.Merge(&own)
+	merged.setPack(own.Pack) // Includes without a pack of their own join the file's
+	return merged, nil
+}
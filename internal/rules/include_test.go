@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRuleFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFiles(t, dir, map[string]string{
+		"common/defaults.yaml": `definitions:
+  defaults: &defaults
+    severity: high
+    enabled: true
+    tags: &persistence ["persistence", "T1543"]
+`,
+		"common/shared.yaml": `rules:
+  - id: INC-000
+    title: "Shared rule"
+    expr: kind == "fork"
+    severity: low
+    enabled: true
+`,
+		"persistence/launch.yaml": `# Shared boilerplate
+include:
+  - ../common/*.yaml
+
+rules:
+  - <<: *defaults
+    id: INC-001
+    title: "Launch item"
+    expr: kind == "launch_item"
+  - <<: *defaults
+    id: INC-002
+    title: "Login item"
+    expr: kind == "login_item"
+    severity: medium
+    tags: *persistence
+`,
+	})
+
+	t.Run("file", func(t *testing.T) {
+		config, err := Load(filepath.Join(dir, "persistence/launch.yaml"))
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(config.Rules) != 3 {
+			t.Fatalf("expected 3 rules, got %d", len(config.Rules))
+		}
+		if config.Rules[0].ID != "INC-000" {
+			t.Errorf("expected included rules first, got %s", config.Rules[0].ID)
+		}
+		r1, r2 := config.Rules[1], config.Rules[2]
+		if r1.Severity != "high" || !r1.Enabled || len(r1.Tags) != 2 {
+			t.Errorf("INC-001 did not inherit defaults: %+v", r1)
+		}
+		if r2.Severity != "medium" || r2.Tags[0] != "persistence" {
+			t.Errorf("INC-002 override or tag alias lost: %+v", r2)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		// Included files are loaded once, through the file including them
+		config, err := Load(dir)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(config.Rules) != 3 {
+			t.Errorf("expected 3 rules, got %d", len(config.Rules))
+		}
+	})
+}
+
+func TestLoadIncludesInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"main.yaml":  "include: a.yaml\n",
+				"a.yaml":     "include: [main.yaml]\n",
+				"other.yaml": "rules: []\n",
+			},
+			want: "include cycle",
+		},
+		{
+			name:  "no match",
+			files: map[string]string{"main.yaml": "include: missing/*.yaml\n"},
+			want:  "matched no files",
+		},
+		{
+			name:  "absolute",
+			files: map[string]string{"main.yaml": "include: /etc/santamon/rules.yaml\n"},
+			want:  "must be relative",
+		},
+		{
+			name: "multiple documents",
+			files: map[string]string{
+				"main.yaml": "include: a.yaml\n",
+				"a.yaml":    "---\nrules: []\n---\nrules: []\n",
+			},
+			want: "multiple YAML documents",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRuleFiles(t, dir, tt.files)
+			_, err := Load(filepath.Join(dir, "main.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInclude(t *testing.T) {
+	if _, err := Parse([]byte("include: common.yaml\nrules: []\n")); err == nil {
+		t.Error("expected include to be rejected in a bundle")
+	}
+}
@@ -0,0 +1,50 @@
+package rules
+
+// ruleIndex groups compiled rules by the event kinds their expressions can
+// match (see matchedKinds), so an event is only evaluated against rules that
+// could fire for its kind. Rules are kept in load order within each kind.
+type ruleIndex struct {
+	byKind map[string][]*CompiledRule // Rules for the kind, including unconstrained ones
+	any    []*CompiledRule            // Rules that do not constrain kind
+}
+
+// newRuleIndex indexes rules by the kinds recorded when they were compiled
+func newRuleIndex(rules []*CompiledRule) ruleIndex {
+	idx := ruleIndex{byKind: make(map[string][]*CompiledRule)}
+	for _, r := range rules {
+		if r.kinds == nil {
+			idx.any = append(idx.any, r)
+		}
+		for kind := range r.kinds {
+			if _, ok := idx.byKind[kind]; !ok {
+				idx.byKind[kind] = nil
+			}
+		}
+	}
+	for kind := range idx.byKind {
+		for _, r := range rules {
+			if r.kinds == nil || r.kinds[kind] {
+				idx.byKind[kind] = append(idx.byKind[kind], r)
+			}
+		}
+	}
+	return idx
+}
+
+// forKind returns the rules that can match an event of kind
+func (idx ruleIndex) forKind(kind string) []*CompiledRule {
+	if rules, ok := idx.byKind[kind]; ok {
+		return rules
+	}
+	return idx.any
+}
+
+// ruleKinds returns the event kinds expr can match, or nil when it is not
+// constrained to literal kinds. Unparseable expressions are unconstrained.
+func (e *Engine) ruleKinds(expr string) map[string]bool {
+	ast, iss := e.env.Parse(expr)
+	if iss != nil && iss.Err() != nil {
+		return nil
+	}
+	return matchedKinds(ast.NativeRep().Expr())
+}
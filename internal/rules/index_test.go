@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"slices"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+func TestRuleIndex(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	rule := func(id, expr string, priority bool) *Rule {
+		return &Rule{ID: id, Title: id, Expr: expr, Severity: "high", Priority: priority, Enabled: true}
+	}
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{
+		rule("EXEC", `kind == "execution" && event.execution.decision == DECISION_DENY`, false),
+		rule("ANY", `machine_id != ""`, false),
+		rule("FILE", `kind in ["close", "rename"] || has(event.unlink)`, false),
+		rule("NOT", `kind != "execution"`, false),
+		rule("PRIO", `has(event.execution) && true`, true),
+		// has() on a field that is not an event kind does not constrain kind
+		rule("HAS", `has(event.machine_id)`, false),
+		rule("EXEC_HAS", `kind == "execution" && has(event.machine_id)`, false),
+	}})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	ids := func(rules []*CompiledRule) []string {
+		var out []string
+		for _, r := range rules {
+			out = append(out, r.Rule.ID)
+		}
+		return out
+	}
+	tests := []struct {
+		kind     string
+		all      []string
+		priority []string
+	}{
+		{"execution", []string{"EXEC", "ANY", "NOT", "PRIO", "HAS", "EXEC_HAS"}, []string{"PRIO"}},
+		{"rename", []string{"ANY", "FILE", "NOT", "HAS"}, nil},
+		{"unlink", []string{"ANY", "FILE", "NOT", "HAS"}, nil},
+		{"fork", []string{"ANY", "NOT", "HAS"}, nil},
+		{"unknown", []string{"ANY", "NOT", "HAS"}, nil},
+		{"machine_id", []string{"ANY", "NOT", "HAS"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			if got := ids(engine.rulesByKind.forKind(tt.kind)); !slices.Equal(got, tt.all) {
+				t.Errorf("rules for %s = %v, want %v", tt.kind, got, tt.all)
+			}
+			if got := ids(engine.priorityByKind.forKind(tt.kind)); !slices.Equal(got, tt.priority) {
+				t.Errorf("priority rules for %s = %v, want %v", tt.kind, got, tt.priority)
+			}
+		})
+	}
+
+	// The has() rules fire for an execution carrying a machine ID
+	matches, err := engine.Evaluate(&santapb.SantaMessage{
+		MachineId: proto.String("m1"),
+		Event:     &santapb.SantaMessage_Execution{Execution: &santapb.Execution{}},
+	})
+	if err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	var matched []string
+	for _, m := range matches {
+		matched = append(matched, m.RuleID)
+	}
+	for _, id := range []string{"HAS", "EXEC_HAS"} {
+		if !slices.Contains(matched, id) {
+			t.Errorf("%s did not match: %v", id, matched)
+		}
+	}
+}
+
+// The index relies on kind naming each event after its SantaMessage field
+func TestEventKindsMatchKind(t *testing.T) {
+	oneof := (&santapb.SantaMessage{}).ProtoReflect().Descriptor().Oneofs().ByName("event")
+	fields := oneof.Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		msg := &santapb.SantaMessage{}
+		m := msg.ProtoReflect()
+		m.Set(field, protoreflect.ValueOfMessage(m.NewField(field).Message()))
+		if got := events.Kind(msg); got != string(field.Name()) {
+			t.Errorf("Kind() = %q for the %s event", got, field.Name())
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+)
+
+// eventVariables are the CEL variables that carry data from the event
+var eventVariables = map[string]bool{
+	"event":             true,
+	"kind":              true,
+	"machine_id":        true,
+	"boot_session_uuid": true,
+	"decoded_args":      true,
+}
+
+// LintIssue is a likely mistake in a rule expression that still compiles
+type LintIssue struct {
+	RuleID  string
+	Message string
+}
+
+func (i *LintIssue) Error() string {
+	return fmt.Sprintf("%s: %s", i.RuleID, i.Message)
+}
+
+// Lint statically checks the expression of every rule for mistakes the
+// compiler accepts: expressions that don't reference the event (always true
+// or always false), that don't constrain kind (and so run against every
+// event type), and enum fields compared to strings or bare numbers.
+// Expressions that fail to compile are skipped; see CheckExpressions.
+func (e *Engine) Lint(rules *RulesConfig) []error {
+	var issues []error
+	lint := func(id, expr string) {
+		ast, iss := e.env.Compile(expr)
+		if iss != nil && iss.Err() != nil {
+			return
+		}
+		for _, msg := range e.lintExpression(ast) {
+			issues = append(issues, &LintIssue{RuleID: id, Message: msg})
+		}
+	}
+
+	for _, rule := range rules.Rules {
+		lint(rule.ID, rule.Expr)
+	}
+	for _, corr := range rules.Correlations {
+		lint(corr.ID, corr.Expr)
+	}
+	for _, baseline := range rules.Baselines {
+		lint(baseline.ID, baseline.Expr)
+	}
+	return issues
+}
+
+// lintExpression returns the issues found in one checked expression
+func (e *Engine) lintExpression(ast *cel.Ast) []string {
+	native := ast.NativeRep()
+	root := native.Expr()
+
+	var msgs []string
+	usesEvent := false
+	celast.PreOrderVisit(root, celast.NewExprVisitor(func(expr celast.Expr) {
+		switch expr.Kind() {
+		case celast.IdentKind:
+			if eventVariables[expr.AsIdent()] {
+				usesEvent = true
+			}
+		case celast.LiteralKind:
+			if s, ok := expr.AsLiteral().(types.String); ok {
+				if _, isEnum := santaEnums[string(s)]; isEnum {
+					msgs = append(msgs, fmt.Sprintf("string %q is an enum name; enum fields are ints, use the constant %s without quotes", string(s), string(s)))
+				}
+			}
+		case celast.CallKind:
+			if msg := lintEnumNumber(native, expr.AsCall()); msg != "" {
+				msgs = append(msgs, msg)
+			}
+		}
+	}))
+
+	if !usesEvent {
+		result := "false"
+		if program, err := e.env.Program(ast); err == nil {
+			if out, _, err := program.Eval(BuildActivation(&santapb.SantaMessage{})); err == nil && out == types.True {
+				result = "true"
+			}
+		}
+		return append(msgs, fmt.Sprintf("expression does not reference the event and is always %s", result))
+	}
+	if !constrainsKind(root) {
+		msgs = append(msgs, `expression does not constrain kind and is evaluated against every event type; add kind == "..." (or has(event.<type>)) to every branch`)
+	}
+	return msgs
+}
+
+// constrainsKind reports whether an expression can only match events of
+// specific kinds: every branch that can make it true checks kind or tests
+// for the event type with has(event.<type>)
+func constrainsKind(expr celast.Expr) bool {
+	if expr.Kind() == celast.SelectKind {
+		sel := expr.AsSelect()
+		return sel.IsTestOnly() && isIdent(sel.Operand(), "event")
+	}
+	if expr.Kind() != celast.CallKind {
+		return false
+	}
+	call := expr.AsCall()
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalAnd:
+		return constrainsKind(args[0]) || constrainsKind(args[1])
+	case operators.LogicalOr:
+		return constrainsKind(args[0]) && constrainsKind(args[1])
+	case operators.Conditional:
+		return constrainsKind(args[1]) && constrainsKind(args[2])
+	case operators.Equals, operators.In:
+		return isIdent(args[0], "kind") || isIdent(args[1], "kind")
+	}
+	return false
+}
+
+func isIdent(expr celast.Expr, name string) bool {
+	return expr.Kind() == celast.IdentKind && expr.AsIdent() == name
+}
+
+// lintEnumNumber flags an enum field compared to a bare number, which
+// silently breaks if the meaning of the number is misremembered
+func lintEnumNumber(native *celast.AST, call celast.CallExpr) string {
+	switch call.FunctionName() {
+	case operators.Equals, operators.NotEquals, operators.In:
+	default:
+		return ""
+	}
+	args := call.Args()
+	if len(args) != 2 {
+		return ""
+	}
+	for i, field := range args {
+		fd := enumField(native, field)
+		if fd == nil {
+			continue
+		}
+		other := args[1-i]
+		var numbers []celast.Expr
+		switch other.Kind() {
+		case celast.LiteralKind:
+			numbers = []celast.Expr{other}
+		case celast.ListKind:
+			numbers = other.AsList().Elements()
+		}
+		for _, n := range numbers {
+			if n.Kind() != celast.LiteralKind {
+				continue
+			}
+			v, ok := n.AsLiteral().(types.Int)
+			if !ok {
+				continue
+			}
+			name := "a named constant"
+			if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(v)); ev != nil {
+				if _, ok := santaEnums[string(ev.Name())]; ok {
+					name = string(ev.Name())
+				}
+			}
+			return fmt.Sprintf("enum field %s compared to the number %d; use %s", fd.Name(), int64(v), name)
+		}
+	}
+	return ""
+}
+
+// enumField returns the protobuf field selected by expr if it is an enum
+func enumField(native *celast.AST, expr celast.Expr) protoreflect.FieldDescriptor {
+	if expr.Kind() != celast.SelectKind {
+		return nil
+	}
+	sel := expr.AsSelect()
+	t := native.GetType(sel.Operand().ID())
+	if t == nil {
+		return nil
+	}
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(t.TypeName()))
+	if err != nil {
+		return nil
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil
+	}
+	fd := md.Fields().ByName(protoreflect.Name(sel.FieldName()))
+	if fd == nil || fd.Kind() != protoreflect.EnumKind {
+		return nil
+	}
+	return fd
+}
@@ -0,0 +1,57 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want string // Expected issue substring, empty for none
+	}{
+		{name: "constrained", expr: `kind == "execution" && event.execution.target.executable.path.startsWith("/tmp/")`},
+		{name: "kind in list", expr: `kind in ["execution", "fork"] && machine_id != ""`},
+		{name: "has event type", expr: `has(event.execution) && !event.execution.target.executable.path.startsWith("/System/")`},
+		{name: "both branches", expr: `(kind == "execution" && event.execution.decision == DECISION_DENY) || (kind == "exit")`},
+		{name: "constant true", expr: `true || DECISION_ALLOW == 1`, want: "always true"},
+		{name: "constant false", expr: `DECISION_DENY == 1`, want: "always false"},
+		{name: "unconstrained", expr: `!event.execution.target.executable.path.startsWith("/System/")`, want: "does not constrain kind"},
+		{name: "one branch unconstrained", expr: `kind == "execution" || machine_id == "x"`, want: "does not constrain kind"},
+		{name: "negated kind", expr: `kind != "execution"`, want: "does not constrain kind"},
+		{name: "enum string", expr: `kind == "execution" && string(event.execution.decision) == "DECISION_DENY"`, want: "use the constant DECISION_DENY"},
+		{name: "enum number", expr: `kind == "execution" && event.execution.decision == 2`, want: "compared to the number 2; use DECISION_DENY"},
+		{name: "enum number list", expr: `kind == "execution" && event.execution.decision in [1, 2]`, want: "compared to the number 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := engine.Lint(&RulesConfig{Rules: []*Rule{{ID: "L1", Expr: tt.expr}}})
+			if tt.want == "" {
+				if len(issues) != 0 {
+					t.Errorf("Expected no issues, got %v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || !strings.Contains(issues[0].Error(), tt.want) {
+				t.Errorf("Expected one issue containing %q, got %v", tt.want, issues)
+			}
+		})
+	}
+}
+
+func TestLintSkipsInvalid(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if issues := engine.Lint(&RulesConfig{Rules: []*Rule{{ID: "L1", Expr: `kind ==`}}}); len(issues) != 0 {
+		t.Errorf("Expected compile errors to be left to CheckExpressions, got %v", issues)
+	}
+}
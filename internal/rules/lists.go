@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+)
+
+// listsVariable is the CEL variable holding the named lists
+const listsVariable = "lists"
+
+// listNamePattern keeps list names usable as lists.<name> in expressions
+var listNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateLists checks that every list name is a valid identifier
+func validateLists(lists map[string][]string) error {
+	for name := range lists {
+		if !listNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid list name %q: must be a letter or underscore followed by letters, digits or underscores", name)
+		}
+	}
+	return nil
+}
+
+// setLists exposes the named lists to expressions compiled afterwards as the
+// constant lists variable
+func (e *Engine) setLists(lists map[string][]string) error {
+	if lists == nil {
+		lists = map[string][]string{}
+	}
+	env, err := e.baseEnv.Extend(cel.Constant(listsVariable,
+		cel.MapType(cel.StringType, cel.ListType(cel.StringType)),
+		types.DefaultTypeAdapter.NativeToValue(lists)))
+	if err != nil {
+		return fmt.Errorf("failed to declare lists: %w", err)
+	}
+	e.env = env
+	e.lists = lists
+	return nil
+}
+
+// checkListRefs reports references to lists that are not defined, so a typo
+// fails at load time instead of on every event
+func checkListRefs(ast *cel.Ast, lists map[string][]string) error {
+	var err error
+	isLists := func(e celast.Expr) bool {
+		return e.Kind() == celast.IdentKind && e.AsIdent() == listsVariable
+	}
+	check := func(name string) {
+		if _, ok := lists[name]; !ok && err == nil {
+			err = fmt.Errorf("unknown list %q", name)
+		}
+	}
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		switch e.Kind() {
+		case celast.SelectKind:
+			if sel := e.AsSelect(); isLists(sel.Operand()) {
+				check(sel.FieldName())
+			}
+		case celast.CallKind:
+			call := e.AsCall()
+			if args := call.Args(); call.FunctionName() == operators.Index && len(args) == 2 && isLists(args[0]) {
+				if name, ok := args[1].AsLiteral().(types.String); ok {
+					check(string(name))
+				}
+			}
+		}
+	}))
+	return err
+}
@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLists(t *testing.T) {
+	rc, err := Parse([]byte(`
+lists:
+  lolbins: [/usr/bin/curl, /usr/bin/osascript]
+  admin_users: []
+rules:
+  - id: R1
+    title: LOLBin
+    expr: event.execution.target.executable.path in lists.lolbins
+    severity: low
+    enabled: true
+  - id: R2
+    title: Admin
+    expr: machine_id in lists["admin_users"]
+    severity: low
+    enabled: true
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	exec := func(path string) *santapb.SantaMessage {
+		return &santapb.SantaMessage{
+			MachineId: proto.String("m1"),
+			Event: &santapb.SantaMessage_Execution{
+				Execution: &santapb.Execution{
+					Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}},
+				},
+			},
+		}
+	}
+	matches, err := engine.Evaluate(exec("/usr/bin/curl"))
+	if err != nil {
+		t.Fatalf("Evaluate() failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].RuleID != "R1" {
+		t.Errorf("Expected only R1 to match, got %d matches", len(matches))
+	}
+	if matches, _ := engine.Evaluate(exec("/bin/ls")); len(matches) != 0 {
+		t.Errorf("Expected no matches, got %d", len(matches))
+	}
+
+	// Lists are part of the rules version
+	before := rc.Version()
+	rc.Lists["lolbins"] = append(rc.Lists["lolbins"], "/usr/bin/nc")
+	if rc.Version() == before {
+		t.Error("Version() unchanged after editing a list")
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "invalid name",
+			yaml:    "lists:\n  lol-bins: [/usr/bin/curl]\n",
+			wantErr: "invalid list name",
+		},
+		{
+			name:    "unknown list",
+			yaml:    "rules:\n  - id: R1\n    title: T\n    expr: kind in lists.lolbinz\n    severity: low\n    enabled: true\n",
+			wantErr: `unknown list "lolbinz"`,
+		},
+		{
+			name:    "unknown list by index",
+			yaml:    "rules:\n  - id: R1\n    title: T\n    expr: kind in lists[\"lolbinz\"]\n    severity: low\n    enabled: true\n",
+			wantErr: `unknown list "lolbinz"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := Parse([]byte(tt.yaml))
+			if err == nil {
+				engine, nerr := NewEngine()
+				if nerr != nil {
+					t.Fatalf("NewEngine() failed: %v", nerr)
+				}
+				err = engine.LoadRules(rc)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListsDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("rules.yaml", "rules:\n  - id: R1\n    title: T\n    expr: kind in lists.kinds\n    severity: low\n    enabled: true\n")
+	write("lists.yaml", "lists:\n  kinds: [execution]\n")
+
+	rc, err := LoadRulesDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if got := rc.Lists["kinds"]; len(got) != 1 || got[0] != "execution" {
+		t.Errorf("Lists not merged from side file: %v", rc.Lists)
+	}
+
+	write("more-lists.yaml", "lists:\n  kinds: [fork]\n")
+	if _, err := LoadRulesDir(dir); err == nil || !strings.Contains(err.Error(), "duplicate list kinds") {
+		t.Errorf("Expected duplicate list error, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package rules
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,23 +16,60 @@ import (
 
 // RulesConfig represents the rules.yaml file structure
 type RulesConfig struct {
-	Rules        []*Rule            `yaml:"rules"`
-	Correlations []*CorrelationRule `yaml:"correlations"`
-	Baselines    []*BaselineRule    `yaml:"baselines,omitempty"`
+	Rules        []*Rule             `yaml:"rules"`
+	Correlations []*CorrelationRule  `yaml:"correlations"`
+	Baselines    []*BaselineRule     `yaml:"baselines,omitempty"`
+	Lists        map[string][]string `yaml:"lists,omitempty"`  // Named lists exposed to expressions as lists.<name>
+	Macros       map[string]string   `yaml:"macros,omitempty"` // Named expression snippets, referenced as ${name}
+	Pack         string              `yaml:"pack,omitempty"`   // Default pack of the file's rules
 }
 
 // Rule represents a single detection rule
 type Rule struct {
-	ID                 string   `yaml:"id"`
-	Title              string   `yaml:"title"`
-	Description        string   `yaml:"description,omitempty"`
-	Expr               string   `yaml:"expr"`
-	Severity           string   `yaml:"severity"`
-	Tags               []string `yaml:"tags,omitempty"`
-	Enabled            bool     `yaml:"enabled"`
-	ExtraContext       []string `yaml:"extra_context,omitempty"`        // Optional extra fields to include in signal context
-	IncludeEvent       bool     `yaml:"include_event,omitempty"`        // If true, include full event map in signal context
-	IncludeProcessTree bool     `yaml:"include_process_tree,omitempty"` // If true, include process lineage in signal context
+	ID                 string       `yaml:"id"`
+	Title              string       `yaml:"title"`
+	Description        string       `yaml:"description,omitempty"`
+	Expr               string       `yaml:"expr"`
+	Severity           string       `yaml:"severity"`
+	Tags               []string     `yaml:"tags,omitempty"`
+	Enabled            bool         `yaml:"enabled"`
+	ExtraContext       []string     `yaml:"extra_context,omitempty"`        // Optional extra fields to include in signal context
+	IncludeEvent       bool         `yaml:"include_event,omitempty"`        // If true, include full event map in signal context
+	IncludeProcessTree bool         `yaml:"include_process_tree,omitempty"` // If true, include process lineage in signal context
+	ProcessTreeMode    string       `yaml:"process_tree_mode,omitempty"`    // parent (default) or responsible: how the process tree is walked
+	ProcessTreeDepth   int          `yaml:"process_tree_depth,omitempty"`   // Ancestors (and generations of children) to include; default 8
+	IncludeChildren    bool         `yaml:"include_children,omitempty"`     // If true, also include the processes the target spawned
+	Entitlements       []string     `yaml:"include_entitlements,omitempty"` // Globs of target entitlements to include in signal context
+	Priority           bool         `yaml:"priority,omitempty"`             // If true, evaluate on the fast path and ship immediately
+	Exceptions         []Exception  `yaml:"exceptions,omitempty"`           // Expressions or value lists that suppress the rule when any matches
+	Escalations        []Escalation `yaml:"escalate,omitempty"`             // Conditions that raise the severity of a match
+	Aggregate          bool         `yaml:"aggregate,omitempty"`            // If true, emit a periodic rollup signal instead of one signal per match
+	Actions            []string     `yaml:"actions,omitempty"`              // Response actions (response.actions) run for each signal
+	ActiveHours        string       `yaml:"active_hours,omitempty"`         // HH:MM-HH:MM window the rule signals in; may wrap midnight
+	ActiveDays         []string     `yaml:"active_days,omitempty"`          // Days the rule signals on: mon..sun, weekdays, weekends
+	Timezone           string       `yaml:"timezone,omitempty"`             // IANA time zone of active_hours and active_days (default: the host's)
+	HostSelector       `yaml:",inline"`
+	Metadata           `yaml:",inline"`
+}
+
+// Process tree modes of include_process_tree
+const (
+	ProcessTreeParent      = "parent"      // Follow parent processes
+	ProcessTreeResponsible = "responsible" // Continue with the responsible process where the parent chain ends at launchd
+)
+
+// Depth of process trees: the default, and the most process_tree_depth allows
+const (
+	DefaultProcessTreeDepth = 8
+	MaxProcessTreeDepth     = 64
+)
+
+// TreeDepth returns the process tree depth of the rule
+func (r *Rule) TreeDepth() int {
+	if r.ProcessTreeDepth > 0 {
+		return r.ProcessTreeDepth
+	}
+	return DefaultProcessTreeDepth
 }
 
 // CorrelationRule represents a time-window correlation rule
@@ -39,11 +80,74 @@ type CorrelationRule struct {
 	Expr          string        `yaml:"expr"`           // Filter expression
 	Window        time.Duration `yaml:"window"`         // Time window
 	GroupBy       []string      `yaml:"group_by"`       // Fields to group by
-	CountDistinct string        `yaml:"count_distinct"` // Field to count distinct values
+	CountDistinct Fields        `yaml:"count_distinct"` // Fields whose distinct value tuples are counted
 	Threshold     int           `yaml:"threshold"`      // Count threshold
+	MinDistinct   int           `yaml:"min_distinct"`   // Distinct tuples required instead of a threshold
+	MaxCount      int           `yaml:"max_count"`      // Most events a min_distinct window may hold
+	Rate          float64       `yaml:"rate"`           // Events per second, decaying over Window, instead of a threshold
 	Severity      string        `yaml:"severity"`
 	Tags          []string      `yaml:"tags,omitempty"`
 	Enabled       bool          `yaml:"enabled"`
+	Exceptions    []Exception   `yaml:"exceptions,omitempty"` // Matching events are not counted
+	Actions       []string      `yaml:"actions,omitempty"`    // Response actions (response.actions) run for each signal
+
+	// MaxStoredEvents caps the events stored across all groups of the rule,
+	// evicting the least recently used groups; it overrides
+	// state.windows.max_rule_events
+	MaxStoredEvents int `yaml:"max_stored_events,omitempty"`
+
+	// WindowMode is "sliding" (default: the last Window before each event) or
+	// "tumbling" (fixed Window-sized intervals aligned to the Unix epoch).
+	// OnMatch is "clear" (default) or "retain" the window's events after a
+	// match. RefireAfter suppresses further matches of a group for a period.
+	WindowMode  string        `yaml:"window_mode,omitempty"`
+	OnMatch     string        `yaml:"on_match,omitempty"`
+	RefireAfter time.Duration `yaml:"refire_after,omitempty"`
+
+	// Absence correlations alert when no event matching Expect follows an
+	// event matching Expr within Window. ExpectGroupBy names the fields of the
+	// expected event that must equal the trigger's GroupBy values.
+	Expect        string   `yaml:"expect,omitempty"`
+	ExpectGroupBy []string `yaml:"expect_group_by,omitempty"`
+
+	HostSelector `yaml:",inline"`
+	Metadata     `yaml:",inline"`
+}
+
+// IsAbsence reports whether the rule alerts on a missing follow-up event
+func (cr *CorrelationRule) IsAbsence() bool {
+	return cr.Expect != ""
+}
+
+// lineagePrefix marks correlation key fields taken from the process lineage
+// store instead of the event
+const lineagePrefix = "lineage."
+
+// UsesLineage reports whether the rule groups or counts by lineage fields
+func (cr *CorrelationRule) UsesLineage() bool {
+	for _, fields := range [][]string{cr.GroupBy, cr.CountDistinct, cr.ExpectGroupBy} {
+		for _, field := range fields {
+			if strings.HasPrefix(field, lineagePrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsRate reports whether the rule fires on an event rate instead of a count
+func (cr *CorrelationRule) IsRate() bool {
+	return cr.Rate > 0
+}
+
+// IsTumbling reports whether the rule counts events in fixed intervals
+func (cr *CorrelationRule) IsTumbling() bool {
+	return cr.WindowMode == "tumbling"
+}
+
+// RetainOnMatch reports whether a match keeps the window's events
+func (cr *CorrelationRule) RetainOnMatch() bool {
+	return cr.OnMatch == "retain"
 }
 
 // Load loads rules from either a file or directory, auto-detecting the type
@@ -59,19 +163,43 @@ func Load(path string) (*RulesConfig, error) {
 	return LoadRulesFile(path)
 }
 
-// LoadRulesFile loads and parses the rules YAML file
+// LoadRulesFile loads and parses the rules YAML file, resolving includes
 func LoadRulesFile(path string) (*RulesConfig, error) {
-	data, err := os.ReadFile(path)
+	expanded, err := expandIncludes(path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read rules file: %w", err)
 	}
 
+	config, err := decodeExpanded(expanded.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rules YAML: %w", err)
+	}
+	if err := config.ExpandMacros(); err != nil {
+		return nil, fmt.Errorf("invalid rules configuration: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules configuration: %w", err)
+	}
+	return config, nil
+}
+
+// Parse parses and validates a rules YAML document. Includes need a file to
+// resolve against, so they are rejected here.
+func Parse(data []byte) (*RulesConfig, error) {
+	if patterns, err := includePatterns(data); err != nil || len(patterns) > 0 {
+		return nil, fmt.Errorf("include is only supported in rules files")
+	}
+
 	var config RulesConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse rules YAML: %w", err)
 	}
+	config.setPack(config.Pack)
 
 	// Validate rules
+	if err := config.ExpandMacros(); err != nil {
+		return nil, fmt.Errorf("invalid rules configuration: %w", err)
+	}
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid rules configuration: %w", err)
 	}
@@ -92,20 +220,26 @@ func LoadRulesDir(dirPath string) (*RulesConfig, error) {
 
 	// Track all rule IDs and their source files for better error messages
 	idToFile := make(map[string]string)
+	listToFile := make(map[string]string)
+	macroToFile := make(map[string]string)
 	merged := &RulesConfig{
 		Rules:        make([]*Rule, 0),
 		Correlations: make([]*CorrelationRule, 0),
 		Baselines:    make([]*BaselineRule, 0),
 	}
 
-	// Walk directory recursively
+	// Collect rule files; files included by another are loaded through it
+	var paths []string
 	err = filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
+		// Skip directories; hidden ones, such as packs being installed, entirely
 		if d.IsDir() {
+			if path != dirPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -114,51 +248,79 @@ func LoadRulesDir(dirPath string) (*RulesConfig, error) {
 		if ext != ".yaml" && ext != ".yml" {
 			return nil
 		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Load the file
-		data, err := os.ReadFile(path)
+	expanded := make(map[string]*expandedFile, len(paths))
+	included := make(map[string]bool)
+	for _, path := range paths {
+		exp, err := expandIncludes(path, nil)
 		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", path, err)
+			return nil, err
+		}
+		expanded[path] = exp
+		for _, inc := range exp.included {
+			if abs, err := filepath.Abs(inc); err == nil {
+				included[abs] = true
+			}
+		}
+	}
+
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil && included[abs] {
+			continue
 		}
 
-		var config RulesConfig
-		if err := yaml.Unmarshal(data, &config); err != nil {
-			return fmt.Errorf("failed to parse %s: %w", path, err)
+		config, err := decodeExpanded(expanded[path].data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 		}
+		config.setPack(dirPack(dirPath, path))
 
 		// Check for duplicate IDs before merging
 		for _, rule := range config.Rules {
 			if existingFile, exists := idToFile[rule.ID]; exists {
-				return fmt.Errorf("duplicate rule ID %s: found in both %s and %s", rule.ID, existingFile, path)
+				return nil, fmt.Errorf("duplicate rule ID %s: found in both %s and %s", rule.ID, existingFile, path)
 			}
 			idToFile[rule.ID] = path
 		}
 		for _, corr := range config.Correlations {
 			if existingFile, exists := idToFile[corr.ID]; exists {
-				return fmt.Errorf("duplicate correlation ID %s: found in both %s and %s", corr.ID, existingFile, path)
+				return nil, fmt.Errorf("duplicate correlation ID %s: found in both %s and %s", corr.ID, existingFile, path)
 			}
 			idToFile[corr.ID] = path
 		}
 		for _, baseline := range config.Baselines {
 			if existingFile, exists := idToFile[baseline.ID]; exists {
-				return fmt.Errorf("duplicate baseline ID %s: found in both %s and %s", baseline.ID, existingFile, path)
+				return nil, fmt.Errorf("duplicate baseline ID %s: found in both %s and %s", baseline.ID, existingFile, path)
 			}
 			idToFile[baseline.ID] = path
 		}
+		for name := range config.Lists {
+			if existingFile, exists := listToFile[name]; exists {
+				return nil, fmt.Errorf("duplicate list %s: found in both %s and %s", name, existingFile, path)
+			}
+			listToFile[name] = path
+		}
+		for name := range config.Macros {
+			if existingFile, exists := macroToFile[name]; exists {
+				return nil, fmt.Errorf("duplicate macro %s: found in both %s and %s", name, existingFile, path)
+			}
+			macroToFile[name] = path
+		}
 
 		// Merge into combined config
-		merged.Rules = append(merged.Rules, config.Rules...)
-		merged.Correlations = append(merged.Correlations, config.Correlations...)
-		merged.Baselines = append(merged.Baselines, config.Baselines...)
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+		merged.Merge(config)
 	}
 
-	// Validate the merged configuration
+	// Validate the merged configuration; macros may be used across files
+	if err := merged.ExpandMacros(); err != nil {
+		return nil, fmt.Errorf("invalid merged rules configuration: %w", err)
+	}
 	if err := merged.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid merged rules configuration: %w", err)
 	}
@@ -166,15 +328,61 @@ func LoadRulesDir(dirPath string) (*RulesConfig, error) {
 	return merged, nil
 }
 
-// Merge combines another RulesConfig into this one
+// NeedsLineage reports whether enabled rules need the process lineage store:
+// for process trees in signals, lineage fields in correlation keys, or
+// expressions calling the ancestry functions
+func (rc *RulesConfig) NeedsLineage() bool {
+	for _, r := range rc.Rules {
+		if r.Enabled && (r.IncludeProcessTree || usesAncestry(r.Expr)) {
+			return true
+		}
+	}
+	for _, c := range rc.Correlations {
+		if c.Enabled && (c.UsesLineage() || usesAncestry(c.Expr) || usesAncestry(c.Expect)) {
+			return true
+		}
+	}
+	for _, b := range rc.Baselines {
+		if b.Enabled && usesAncestry(b.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge combines another RulesConfig into this one. Lists and macros in
+// other replace same-named ones in rc.
 func (rc *RulesConfig) Merge(other *RulesConfig) {
 	rc.Rules = append(rc.Rules, other.Rules...)
 	rc.Correlations = append(rc.Correlations, other.Correlations...)
 	rc.Baselines = append(rc.Baselines, other.Baselines...)
+	if len(other.Lists) > 0 && rc.Lists == nil {
+		rc.Lists = make(map[string][]string, len(other.Lists))
+	}
+	maps.Copy(rc.Lists, other.Lists)
+	if len(other.Macros) > 0 && rc.Macros == nil {
+		rc.Macros = make(map[string]string, len(other.Macros))
+	}
+	maps.Copy(rc.Macros, other.Macros)
+}
+
+// Version returns a short content hash identifying this set of rules. It is
+// computed over the parsed rules, so formatting and comments don't affect it.
+func (rc *RulesConfig) Version() string {
+	data, err := yaml.Marshal(rc)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
 }
 
 // Validate checks the rules configuration for errors
 func (rc *RulesConfig) Validate() error {
+	if err := validateLists(rc.Lists); err != nil {
+		return err
+	}
+
 	// Check for duplicate rule IDs across all rule types
 	seen := make(map[string]bool)
 
@@ -236,6 +444,44 @@ func (r *Rule) Validate() error {
 		return ErrInvalidSeverity(r.Severity)
 	}
 
+	if err := validateExceptions(r.Exceptions); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	if err := validateEscalations(r.Severity, r.Escalations); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	if r.Aggregate && r.Priority {
+		return fmt.Errorf("rule %s: aggregate and priority are mutually exclusive", r.ID)
+	}
+	switch r.ProcessTreeMode {
+	case "", ProcessTreeParent, ProcessTreeResponsible:
+	default:
+		return fmt.Errorf("rule %s: process_tree_mode must be %s or %s, got %q", r.ID, ProcessTreeParent, ProcessTreeResponsible, r.ProcessTreeMode)
+	}
+	if r.ProcessTreeDepth < 0 || r.ProcessTreeDepth > MaxProcessTreeDepth {
+		return fmt.Errorf("rule %s: process_tree_depth must be between 1 and %d", r.ID, MaxProcessTreeDepth)
+	}
+	if (r.ProcessTreeMode != "" || r.ProcessTreeDepth != 0 || r.IncludeChildren) && !r.IncludeProcessTree {
+		return fmt.Errorf("rule %s: process_tree_mode, process_tree_depth and include_children require include_process_tree", r.ID)
+	}
+	for _, g := range r.Entitlements {
+		if _, err := compilePattern(g, true); err != nil {
+			return fmt.Errorf("rule %s: include_entitlements: %w", r.ID, err)
+		}
+	}
+	if err := validateActions(r.Actions); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	if _, err := parseSchedule(r.ActiveHours, r.ActiveDays, r.Timezone); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	if err := r.HostSelector.Validate(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	if err := r.Metadata.Validate(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+
 	return nil
 }
 
@@ -253,8 +499,35 @@ func (cr *CorrelationRule) Validate() error {
 	if cr.Window == 0 {
 		return ErrRequired("correlation window")
 	}
-	if cr.Threshold <= 0 {
-		return fmt.Errorf("correlation threshold must be greater than 0")
+	switch cr.WindowMode {
+	case "", "sliding", "tumbling":
+	default:
+		return fmt.Errorf("correlation %s: window_mode must be 'sliding' or 'tumbling', got %q", cr.ID, cr.WindowMode)
+	}
+	switch cr.OnMatch {
+	case "", "clear", "retain":
+	default:
+		return fmt.Errorf("correlation %s: on_match must be 'clear' or 'retain', got %q", cr.ID, cr.OnMatch)
+	}
+	if cr.RefireAfter < 0 {
+		return fmt.Errorf("correlation %s: refire_after must not be negative", cr.ID)
+	}
+	if cr.MaxStoredEvents < 0 {
+		return fmt.Errorf("correlation %s: max_stored_events must not be negative", cr.ID)
+	}
+	if cr.IsAbsence() {
+		if err := cr.validateAbsence(); err != nil {
+			return fmt.Errorf("correlation %s: %w", cr.ID, err)
+		}
+	} else if cr.Rate != 0 {
+		if err := cr.validateRate(); err != nil {
+			return fmt.Errorf("correlation %s: %w", cr.ID, err)
+		}
+	} else if err := cr.validateCount(); err != nil {
+		return fmt.Errorf("correlation %s: %w", cr.ID, err)
+	}
+	if !cr.IsAbsence() && len(cr.ExpectGroupBy) > 0 {
+		return fmt.Errorf("correlation %s: expect_group_by requires expect", cr.ID)
 	}
 	if cr.Severity == "" {
 		return ErrRequired("correlation severity")
@@ -265,12 +538,100 @@ func (cr *CorrelationRule) Validate() error {
 		return ErrInvalidSeverity(cr.Severity)
 	}
 
-	// Validate group_by fields are not empty strings
+	// Validate group_by and count_distinct fields are not empty strings
 	for i, field := range cr.GroupBy {
 		if field == "" {
 			return ErrInvalidField("group_by", i)
 		}
 	}
+	for i, field := range cr.CountDistinct {
+		if field == "" {
+			return ErrInvalidField("count_distinct", i)
+		}
+	}
+
+	if err := validateExceptions(cr.Exceptions); err != nil {
+		return fmt.Errorf("correlation %s: %w", cr.ID, err)
+	}
+	if err := validateActions(cr.Actions); err != nil {
+		return fmt.Errorf("correlation %s: %w", cr.ID, err)
+	}
+	if err := cr.HostSelector.Validate(); err != nil {
+		return fmt.Errorf("correlation %s: %w", cr.ID, err)
+	}
+	if err := cr.Metadata.Validate(); err != nil {
+		return fmt.Errorf("correlation %s: %w", cr.ID, err)
+	}
+
+	return nil
+}
+
+// validateCount checks the match condition of a counting correlation: a
+// threshold, or min_distinct tuples of count_distinct within at most
+// max_count events
+func (cr *CorrelationRule) validateCount() error {
+	if cr.MinDistinct < 0 || cr.MaxCount < 0 {
+		return fmt.Errorf("min_distinct and max_count must not be negative")
+	}
+	if cr.MinDistinct == 0 {
+		if cr.MaxCount > 0 {
+			return fmt.Errorf("max_count requires min_distinct")
+		}
+		if cr.Threshold <= 0 {
+			return fmt.Errorf("threshold must be greater than 0")
+		}
+		return nil
+	}
+	if cr.Threshold != 0 {
+		return fmt.Errorf("threshold and min_distinct are mutually exclusive")
+	}
+	if len(cr.CountDistinct) == 0 {
+		return fmt.Errorf("min_distinct requires count_distinct")
+	}
+	if cr.MaxCount > 0 && cr.MaxCount < cr.MinDistinct {
+		return fmt.Errorf("max_count %d is less than min_distinct %d", cr.MaxCount, cr.MinDistinct)
+	}
+	return nil
+}
+
+// validateRate checks the options of a rate correlation
+func (cr *CorrelationRule) validateRate() error {
+	if cr.Rate < 0 || math.IsNaN(cr.Rate) || math.IsInf(cr.Rate, 0) {
+		return fmt.Errorf("rate must be a positive number of events per second")
+	}
+	if cr.Threshold != 0 || len(cr.CountDistinct) > 0 || cr.MinDistinct != 0 || cr.MaxCount != 0 {
+		return fmt.Errorf("threshold, count_distinct, min_distinct and max_count do not apply to rate correlations")
+	}
+	if cr.WindowMode != "" {
+		return fmt.Errorf("window_mode does not apply to rate correlations")
+	}
+	return nil
+}
 
+// validateAbsence checks the options of an absence correlation
+func (cr *CorrelationRule) validateAbsence() error {
+	if cr.Threshold != 0 || len(cr.CountDistinct) > 0 || cr.MinDistinct != 0 || cr.MaxCount != 0 || cr.Rate != 0 {
+		return fmt.Errorf("threshold, count_distinct, min_distinct, max_count and rate do not apply to absence correlations")
+	}
+	if cr.WindowMode != "" || cr.OnMatch != "" || cr.RefireAfter != 0 {
+		return fmt.Errorf("window_mode, on_match and refire_after do not apply to absence correlations")
+	}
+	if len(cr.ExpectGroupBy) > 0 && len(cr.ExpectGroupBy) != len(cr.GroupBy) {
+		return fmt.Errorf("expect_group_by must list one field per group_by field")
+	}
+	for i, field := range cr.ExpectGroupBy {
+		if field == "" {
+			return ErrInvalidField("expect_group_by", i)
+		}
+	}
 	return nil
 }
+
+// ExpectFields returns the fields of the expected event compared with the
+// trigger's group_by values
+func (cr *CorrelationRule) ExpectFields() []string {
+	if len(cr.ExpectGroupBy) > 0 {
+		return cr.ExpectGroupBy
+	}
+	return cr.GroupBy
+}
@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadRulesDir(t *testing.T) {
@@ -337,6 +340,230 @@ func TestLoad(t *testing.T) {
 	})
 }
 
+func TestNeedsLineage(t *testing.T) {
+	tests := []struct {
+		name string
+		rc   *RulesConfig
+		want bool
+	}{
+		{"none", &RulesConfig{
+			Rules:        []*Rule{{ID: "R1", Enabled: true}},
+			Correlations: []*CorrelationRule{{ID: "C1", Enabled: true, GroupBy: []string{"machine_id"}}},
+		}, false},
+		{"process tree", &RulesConfig{Rules: []*Rule{{ID: "R1", Enabled: true, IncludeProcessTree: true}}}, true},
+		{"disabled process tree", &RulesConfig{Rules: []*Rule{{ID: "R1", IncludeProcessTree: true}}}, false},
+		{"lineage group_by", &RulesConfig{
+			Correlations: []*CorrelationRule{{ID: "C1", Enabled: true, GroupBy: []string{"lineage.root"}}},
+		}, true},
+		{"lineage count_distinct", &RulesConfig{
+			Correlations: []*CorrelationRule{{ID: "C1", Enabled: true, CountDistinct: Fields{"lineage.parent_path"}}},
+		}, true},
+		{"disabled lineage correlation", &RulesConfig{
+			Correlations: []*CorrelationRule{{ID: "C1", GroupBy: []string{"lineage.root"}}},
+		}, false},
+		{"ancestry function", &RulesConfig{Rules: []*Rule{{ID: "R1", Enabled: true, Expr: `!has_ancestor("**/Terminal")`}}}, true},
+		{"ancestry in baseline", &RulesConfig{Baselines: []*BaselineRule{{ID: "B1", Enabled: true, Expr: `parent_path() != ""`}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rc.NeedsLineage(); got != tt.want {
+				t.Errorf("NeedsLineage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAggregate(t *testing.T) {
+	r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "low", Aggregate: true}
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+	r.Priority = true
+	if err := r.Validate(); err == nil || !contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Expected mutually exclusive error, got %v", err)
+	}
+}
+
+func TestValidateProcessTreeMode(t *testing.T) {
+	r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "low", IncludeProcessTree: true, ProcessTreeMode: ProcessTreeResponsible}
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+	r.ProcessTreeMode = "children"
+	if err := r.Validate(); err == nil || !contains(err.Error(), "process_tree_mode") {
+		t.Errorf("Expected invalid mode error, got %v", err)
+	}
+	r.ProcessTreeMode, r.ProcessTreeDepth = ProcessTreeParent, MaxProcessTreeDepth+1
+	if err := r.Validate(); err == nil || !contains(err.Error(), "process_tree_depth") {
+		t.Errorf("Expected invalid depth error, got %v", err)
+	}
+	r.ProcessTreeDepth, r.IncludeProcessTree = 0, false
+	if err := r.Validate(); err == nil || !contains(err.Error(), "require include_process_tree") {
+		t.Errorf("Expected missing include_process_tree error, got %v", err)
+	}
+}
+
+func TestValidateEntitlements(t *testing.T) {
+	r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "low", Entitlements: []string{"com.apple.security.*"}}
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+	r.Entitlements = append(r.Entitlements, "com.apple.[a")
+	if err := r.Validate(); err == nil || !contains(err.Error(), "include_entitlements") {
+		t.Errorf("Expected invalid glob error, got %v", err)
+	}
+}
+
+func TestValidateActions(t *testing.T) {
+	r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "critical", Enabled: true, Actions: []string{"block-hash"}}
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+	r.Actions = append(r.Actions, "block-hash")
+	if err := r.Validate(); err == nil || !contains(err.Error(), "duplicate action") {
+		t.Errorf("Expected duplicate action error, got %v", err)
+	}
+
+	r.Actions = []string{"block-hash"}
+	disabled := &BaselineRule{ID: "B1", Actions: []string{"isolate"}}
+	rc := &RulesConfig{Rules: []*Rule{r}, Baselines: []*BaselineRule{disabled}}
+	if got := rc.Actions(); len(got) != 1 || len(got["R1"]) != 1 {
+		t.Errorf("Actions() = %v, want only the enabled rule's", got)
+	}
+}
+
+func TestValidateAbsence(t *testing.T) {
+	tests := []struct {
+		name     string
+		modifier func(cr *CorrelationRule)
+		wantErr  string
+	}{
+		{"valid", func(cr *CorrelationRule) {}, ""},
+		{"expect_group_by defaults to group_by", func(cr *CorrelationRule) { cr.ExpectGroupBy = nil }, ""},
+		{"threshold", func(cr *CorrelationRule) { cr.Threshold = 2 }, "do not apply"},
+		{"count_distinct", func(cr *CorrelationRule) { cr.CountDistinct = Fields{"event.execution.target.executable.path"} }, "do not apply"},
+		{"expect_group_by length", func(cr *CorrelationRule) { cr.ExpectGroupBy = append(cr.ExpectGroupBy, "machine_id") }, "one field per group_by"},
+		{"empty expect_group_by field", func(cr *CorrelationRule) { cr.ExpectGroupBy = []string{""} }, "expect_group_by"},
+		{"expect_group_by without expect", func(cr *CorrelationRule) { cr.Expect = ""; cr.Threshold = 1 }, "requires expect"},
+		{"window_mode", func(cr *CorrelationRule) { cr.WindowMode = "tumbling" }, "do not apply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &CorrelationRule{
+				ID:            "C1",
+				Title:         "T",
+				Expr:          "kind == 'execution'",
+				Expect:        "kind == 'file_access'",
+				Window:        time.Minute,
+				GroupBy:       []string{"event.execution.target.executable.path"},
+				ExpectGroupBy: []string{"event.file_access.target.path"},
+				Severity:      "low",
+			}
+			tt.modifier(cr)
+			err := cr.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCountDistinct(t *testing.T) {
+	rc, err := Parse([]byte(`correlations:
+  - id: C1
+    title: T
+    expr: "true"
+    window: 1m
+    count_distinct: event.execution.target.executable.path
+    threshold: 3
+    severity: low
+  - id: C2
+    title: T
+    expr: "true"
+    window: 1m
+    count_distinct: [event.tcc_modification.identity, event.tcc_modification.service]
+    min_distinct: 5
+    severity: low
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if got := rc.Correlations[0].CountDistinct; len(got) != 1 || got[0] != "event.execution.target.executable.path" {
+		t.Errorf("count_distinct string = %v", got)
+	}
+	if got := rc.Correlations[1].CountDistinct; len(got) != 2 || got[1] != "event.tcc_modification.service" {
+		t.Errorf("count_distinct list = %v", got)
+	}
+
+	out, err := yaml.Marshal(rc.Correlations[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal rule: %v", err)
+	}
+	if !contains(string(out), "count_distinct: event.execution.target.executable.path\n") {
+		t.Errorf("Single count_distinct field not written as a string:\n%s", out)
+	}
+}
+
+func TestValidateEmitPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		modifier func(cr *CorrelationRule)
+		wantErr  string
+	}{
+		{"defaults", func(cr *CorrelationRule) {}, ""},
+		{"tumbling retain refire", func(cr *CorrelationRule) {
+			cr.WindowMode, cr.OnMatch, cr.RefireAfter = "tumbling", "retain", time.Hour
+		}, ""},
+		{"sliding clear", func(cr *CorrelationRule) { cr.WindowMode, cr.OnMatch = "sliding", "clear" }, ""},
+		{"invalid window_mode", func(cr *CorrelationRule) { cr.WindowMode = "hopping" }, "window_mode"},
+		{"min_distinct", func(cr *CorrelationRule) {
+			cr.Threshold, cr.MinDistinct, cr.MaxCount = 0, 5, 10
+			cr.CountDistinct = Fields{"event.tcc_modification.identity", "event.tcc_modification.service"}
+		}, ""},
+		{"no threshold", func(cr *CorrelationRule) { cr.Threshold = 0 }, "threshold must be greater than 0"},
+		{"min_distinct with threshold", func(cr *CorrelationRule) {
+			cr.MinDistinct, cr.CountDistinct = 5, Fields{"machine_id"}
+		}, "mutually exclusive"},
+		{"min_distinct without count_distinct", func(cr *CorrelationRule) { cr.Threshold, cr.MinDistinct = 0, 5 }, "requires count_distinct"},
+		{"max_count without min_distinct", func(cr *CorrelationRule) { cr.MaxCount = 5 }, "requires min_distinct"},
+		{"max_count below min_distinct", func(cr *CorrelationRule) {
+			cr.Threshold, cr.MinDistinct, cr.MaxCount, cr.CountDistinct = 0, 5, 4, Fields{"machine_id"}
+		}, "less than min_distinct"},
+		{"empty count_distinct field", func(cr *CorrelationRule) { cr.CountDistinct = Fields{"machine_id", ""} }, "count_distinct"},
+		{"rate", func(cr *CorrelationRule) {
+			cr.Threshold, cr.Rate, cr.OnMatch, cr.RefireAfter = 0, 50, "retain", time.Minute
+		}, ""},
+		{"negative rate", func(cr *CorrelationRule) { cr.Threshold, cr.Rate = 0, -1 }, "positive number"},
+		{"rate with threshold", func(cr *CorrelationRule) { cr.Rate = 50 }, "do not apply to rate"},
+		{"rate with window_mode", func(cr *CorrelationRule) { cr.Threshold, cr.Rate, cr.WindowMode = 0, 50, "tumbling" }, "window_mode"},
+		{"invalid on_match", func(cr *CorrelationRule) { cr.OnMatch = "keep" }, "on_match"},
+		{"negative refire_after", func(cr *CorrelationRule) { cr.RefireAfter = -time.Second }, "refire_after"},
+		{"negative max_stored_events", func(cr *CorrelationRule) { cr.MaxStoredEvents = -1 }, "max_stored_events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &CorrelationRule{ID: "C1", Title: "T", Expr: "true", Window: time.Minute, Threshold: 3, Severity: "low"}
+			tt.modifier(cr)
+			err := cr.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // Helper function to check if string contains substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || containsMiddle(s, substr)))
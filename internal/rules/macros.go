@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// macroRefPattern matches a macro reference in an expression, ${name}, or
+// an escaped literal, $${name}
+var macroRefPattern = regexp.MustCompile(`\$?\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// validateMacros checks that every macro has an identifier name and a body
+func validateMacros(macros map[string]string) error {
+	for name, body := range macros {
+		if !listNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid macro name %q: must be a letter or underscore followed by letters, digits or underscores", name)
+		}
+		if strings.TrimSpace(body) == "" {
+			return fmt.Errorf("macro %s is empty", name)
+		}
+	}
+	return nil
+}
+
+// ExpandMacros replaces the ${name} references in the rules' expressions,
+// exceptions and escalations with the named macros. Each macro is
+// parenthesized, so it keeps its meaning next to other operators. Macros may
+// reference other macros; cycles and unknown names are errors, and $${name}
+// stands for a literal ${name}. Rules without macros are left as they are.
+func (rc *RulesConfig) ExpandMacros() error {
+	if len(rc.Macros) == 0 {
+		return nil
+	}
+	if err := validateMacros(rc.Macros); err != nil {
+		return err
+	}
+	m := &macroExpander{macros: rc.Macros, resolved: make(map[string]string, len(rc.Macros))}
+
+	// Resolve every macro, so a broken one fails even before a rule uses it
+	for _, name := range slices.Sorted(maps.Keys(rc.Macros)) {
+		if _, err := m.resolve(name, nil); err != nil {
+			return err
+		}
+	}
+
+	expandExceptions := func(exceptions []Exception) error {
+		for i := range exceptions {
+			if err := m.expand(&exceptions[i].Expr); err != nil {
+				return fmt.Errorf("exception: %w", err)
+			}
+		}
+		return nil
+	}
+	for _, r := range rc.Rules {
+		if err := m.expand(&r.Expr); err != nil {
+			return fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		if err := expandExceptions(r.Exceptions); err != nil {
+			return fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		for i := range r.Escalations {
+			if err := m.expand(&r.Escalations[i].When); err != nil {
+				return fmt.Errorf("rule %s: escalation: %w", r.ID, err)
+			}
+		}
+	}
+	for _, c := range rc.Correlations {
+		if err := m.expand(&c.Expr); err != nil {
+			return fmt.Errorf("correlation rule %s: %w", c.ID, err)
+		}
+		if err := m.expand(&c.Expect); err != nil {
+			return fmt.Errorf("correlation rule %s: expect: %w", c.ID, err)
+		}
+		if err := expandExceptions(c.Exceptions); err != nil {
+			return fmt.Errorf("correlation rule %s: %w", c.ID, err)
+		}
+	}
+	for _, b := range rc.Baselines {
+		if err := m.expand(&b.Expr); err != nil {
+			return fmt.Errorf("baseline rule %s: %w", b.ID, err)
+		}
+		if err := expandExceptions(b.Exceptions); err != nil {
+			return fmt.Errorf("baseline rule %s: %w", b.ID, err)
+		}
+	}
+	return nil
+}
+
+// macroExpander expands macro references, caching resolved macros
+type macroExpander struct {
+	macros   map[string]string
+	resolved map[string]string // Fully expanded, parenthesized bodies by name
+}
+
+// expand replaces the macro references in *expr
+func (m *macroExpander) expand(expr *string) error {
+	if !strings.Contains(*expr, "${") {
+		return nil
+	}
+	out, err := m.substitute(*expr, nil)
+	if err != nil {
+		return err
+	}
+	*expr = out
+	return nil
+}
+
+// substitute replaces the macro references in s; stack holds the macros
+// being resolved, to detect cycles
+func (m *macroExpander) substitute(s string, stack []string) (string, error) {
+	var err error
+	out := macroRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		var body string
+		body, err = m.resolve(ref[2:len(ref)-1], stack)
+		return body
+	})
+	return out, err
+}
+
+// resolve returns the expanded, parenthesized body of the named macro
+func (m *macroExpander) resolve(name string, stack []string) (string, error) {
+	if body, ok := m.resolved[name]; ok {
+		return body, nil
+	}
+	raw, ok := m.macros[name]
+	if !ok {
+		return "", fmt.Errorf("unknown macro %q", name)
+	}
+	if i := slices.Index(stack, name); i >= 0 {
+		return "", fmt.Errorf("macro cycle: %s -> %s", strings.Join(stack[i:], " -> "), name)
+	}
+	body, err := m.substitute(raw, append(stack, name))
+	if err != nil {
+		return "", err
+	}
+	body = "(" + strings.TrimSpace(body) + ")"
+	m.resolved[name] = body
+	return body, nil
+}
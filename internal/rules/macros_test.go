@@ -0,0 +1,164 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMacros(t *testing.T) {
+	rc, err := Parse([]byte(`
+macros:
+  is_exec: kind == "execution"
+  unsigned: '!has(event.execution.target.code_signature.team_id) || event.execution.target.code_signature.team_id == ""'
+  from_user_dir: event.execution.target.executable.path.startsWith("/Users/")
+  unsigned_user_exec: ${is_exec} && ${unsigned} && ${from_user_dir}
+rules:
+  - id: R1
+    title: Unsigned binary from a user directory
+    expr: ${unsigned_user_exec}
+    severity: medium
+    enabled: true
+    exceptions:
+      - event.execution.target.executable.path.endsWith("/allowed")
+    escalate:
+      - when: ${from_user_dir} && event.execution.target.executable.path.contains("/Downloads/")
+        severity: high
+  - id: R2
+    title: Literal dollar braces
+    expr: ${is_exec} && "$${HOME}" in decoded_args
+    severity: low
+    enabled: true
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if got := rc.Rules[1].Expr; got != `(kind == "execution") && "${HOME}" in decoded_args` {
+		t.Errorf("R2 expanded to %q", got)
+	}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	exec := func(path, teamID string) *santapb.SantaMessage {
+		target := &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}}
+		if teamID != "" {
+			target.CodeSignature = &santapb.CodeSignature{TeamId: proto.String(teamID)}
+		}
+		return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{Target: target}}}
+	}
+	tests := []struct {
+		name         string
+		msg          *santapb.SantaMessage
+		wantSeverity string // Empty for no match
+	}{
+		{"unsigned in user dir", exec("/Users/a/tool", ""), "medium"},
+		{"escalated", exec("/Users/a/Downloads/tool", ""), "high"},
+		{"signed", exec("/Users/a/tool", "EQHXZ8M8AV"), ""},
+		{"outside user dirs", exec("/opt/tool", ""), ""},
+		{"exception", exec("/Users/a/allowed", ""), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := engine.Evaluate(tt.msg)
+			if err != nil {
+				t.Fatalf("Evaluate() failed: %v", err)
+			}
+			got := ""
+			for _, m := range matches {
+				if m.RuleID == "R1" {
+					got = m.Severity
+				}
+			}
+			if got != tt.wantSeverity {
+				t.Errorf("R1 severity = %q, want %q", got, tt.wantSeverity)
+			}
+		})
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	rule := func(expr string) string {
+		return "rules:\n  - id: R1\n    title: T\n    expr: '" + expr + "'\n    severity: low\n    enabled: true\n"
+	}
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "invalid name",
+			yaml:    "macros:\n  is-exec: kind == \"execution\"\n",
+			wantErr: "invalid macro name",
+		},
+		{
+			name:    "empty",
+			yaml:    "macros:\n  is_exec: ' '\n",
+			wantErr: "macro is_exec is empty",
+		},
+		{
+			name:    "unknown",
+			yaml:    "macros:\n  is_exec: kind == \"execution\"\n" + rule("${is_exce}"),
+			wantErr: `rule R1: unknown macro "is_exce"`,
+		},
+		{
+			name:    "cycle",
+			yaml:    "macros:\n  a: ${b} && true\n  b: ${c}\n  c: ${a}\n",
+			wantErr: "macro cycle: a -> b -> c -> a",
+		},
+		{
+			name:    "self reference",
+			yaml:    "macros:\n  a: ${a}\n",
+			wantErr: "macro cycle: a -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Without macros, dollar braces are left alone
+	rc, err := Parse([]byte(rule(`"${HOME}" in decoded_args`)))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if got := rc.Rules[0].Expr; got != `"${HOME}" in decoded_args` {
+		t.Errorf("Expr = %q, want it unchanged", got)
+	}
+}
+
+func TestMacrosDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("rules.yaml", "rules:\n  - id: R1\n    title: T\n    expr: ${is_exec}\n    severity: low\n    enabled: true\n")
+	write("macros.yaml", "macros:\n  is_exec: kind == \"execution\"\n")
+
+	rc, err := LoadRulesDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if got := rc.Rules[0].Expr; got != `(kind == "execution")` {
+		t.Errorf("Expr = %q, want the macro from the side file", got)
+	}
+
+	write("more-macros.yaml", "macros:\n  is_exec: kind == \"fork\"\n")
+	if _, err := LoadRulesDir(dir); err == nil || !strings.Contains(err.Error(), "duplicate macro is_exec") {
+		t.Errorf("Expected duplicate macro error, got %v", err)
+	}
+}
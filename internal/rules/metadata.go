@@ -0,0 +1,47 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// metadataDateLayout is the format of the created and modified dates
+const metadataDateLayout = "2006-01-02"
+
+// Metadata is triage guidance shared by every rule type. It does not affect
+// evaluation and is carried through to signal context.
+type Metadata struct {
+	References     []string `yaml:"references,omitempty"`      // URLs with background on the detection
+	Author         string   `yaml:"author,omitempty"`          // Rule author
+	Created        string   `yaml:"created,omitempty"`         // Date (2006-01-02) the rule was written
+	Modified       string   `yaml:"modified,omitempty"`        // Date (2006-01-02) the rule was last changed
+	FalsePositives string   `yaml:"false_positives,omitempty"` // Known benign causes, for analysts
+	Pack           string   `yaml:"pack,omitempty"`            // Rule pack, for enabling and overriding rules by pack (default: the file's)
+}
+
+// Validate checks the reference URLs and dates
+func (m *Metadata) Validate() error {
+	for i, ref := range m.References {
+		u, err := url.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("references[%d] %q must be an http(s) URL", i, ref)
+		}
+	}
+	var created, modified time.Time
+	var err error
+	if m.Created != "" {
+		if created, err = time.Parse(metadataDateLayout, m.Created); err != nil {
+			return fmt.Errorf("invalid created %q: must be a date (2006-01-02)", m.Created)
+		}
+	}
+	if m.Modified != "" {
+		if modified, err = time.Parse(metadataDateLayout, m.Modified); err != nil {
+			return fmt.Errorf("invalid modified %q: must be a date (2006-01-02)", m.Modified)
+		}
+	}
+	if !created.IsZero() && !modified.IsZero() && modified.Before(created) {
+		return fmt.Errorf("modified %s is before created %s", m.Modified, m.Created)
+	}
+	return nil
+}
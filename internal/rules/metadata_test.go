@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	rc, err := Parse([]byte(`
+rules:
+  - id: R1
+    title: Curl to shell
+    expr: kind == "execution"
+    severity: high
+    enabled: true
+    author: Detection Team
+    created: 2026-01-15
+    modified: 2026-09-30
+    references:
+      - https://attack.mitre.org/techniques/T1059/004/
+    false_positives: |
+      Homebrew install scripts.
+correlations:
+  - id: C1
+    title: Burst
+    expr: kind == "execution"
+    window: 1m
+    threshold: 3
+    severity: low
+    enabled: true
+    author: SOC
+baselines:
+  - id: B1
+    title: New child
+    expr: kind == "execution"
+    track: [execution.target.executable.path]
+    severity: low
+    enabled: true
+    references: [https://example.com/runbook]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	r := rc.Rules[0]
+	if r.Author != "Detection Team" || r.Created != "2026-01-15" || r.Modified != "2026-09-30" {
+		t.Errorf("Unexpected rule metadata: %+v", r.Metadata)
+	}
+	if len(r.References) != 1 || !strings.HasPrefix(r.FalsePositives, "Homebrew") {
+		t.Errorf("Unexpected rule metadata: %+v", r.Metadata)
+	}
+	if rc.Correlations[0].Author != "SOC" || len(rc.Baselines[0].References) != 1 {
+		t.Errorf("Metadata not parsed for correlation or baseline rules")
+	}
+
+	// Metadata is part of the rules version
+	before := rc.Version()
+	r.FalsePositives = "none"
+	if rc.Version() == before {
+		t.Error("Version() unchanged after editing false_positives")
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    Metadata
+		wantErr string
+	}{
+		{name: "empty", meta: Metadata{}},
+		{name: "valid", meta: Metadata{References: []string{"https://example.com/a"}, Created: "2026-01-01", Modified: "2026-01-01"}},
+		{name: "relative reference", meta: Metadata{References: []string{"docs/runbook.md"}}, wantErr: "references[0]"},
+		{name: "non-http reference", meta: Metadata{References: []string{"https://ok.example", "ftp://example.com"}}, wantErr: "references[1]"},
+		{name: "bad created", meta: Metadata{Created: "15/01/2026"}, wantErr: "invalid created"},
+		{name: "bad modified", meta: Metadata{Modified: "2026-13-01"}, wantErr: "invalid modified"},
+		{name: "modified before created", meta: Metadata{Created: "2026-02-01", Modified: "2026-01-01"}, wantErr: "before created"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rule{ID: "R1", Title: "T", Expr: "true", Severity: "low", Metadata: tt.meta}
+			err := r.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
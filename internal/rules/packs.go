@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Override enables, disables or re-grades a rule, or every rule of a pack,
+// without editing the rules files
+type Override struct {
+	Enabled  *bool  // Replaces the rule's enabled, when set
+	Severity string // Replaces the rule's severity, when set
+}
+
+// Overrides are the configured pack and rule overrides. A rule override
+// takes precedence over the override of the rule's pack.
+type Overrides struct {
+	Packs map[string]Override
+	Rules map[string]Override
+}
+
+// ApplyOverrides sets the enabled and severity of the rules the overrides
+// name, directly or through their pack. Overrides of packs and rules that rc
+// does not define are logged and ignored.
+func (rc *RulesConfig) ApplyOverrides(o *Overrides) {
+	if o == nil {
+		return
+	}
+	packs := make(map[string]bool)
+	ids := make(map[string]bool)
+	apply := func(id, pack string, enabled *bool, severity *string) {
+		ids[id] = true
+		if pack != "" {
+			packs[pack] = true
+		}
+		for _, ov := range []Override{o.Packs[pack], o.Rules[id]} {
+			if ov.Enabled != nil {
+				*enabled = *ov.Enabled
+			}
+			if ov.Severity != "" {
+				*severity = ov.Severity
+			}
+		}
+	}
+	for _, r := range rc.Rules {
+		apply(r.ID, r.Pack, &r.Enabled, &r.Severity)
+	}
+	for _, c := range rc.Correlations {
+		apply(c.ID, c.Pack, &c.Enabled, &c.Severity)
+	}
+	for _, b := range rc.Baselines {
+		apply(b.ID, b.Pack, &b.Enabled, &b.Severity)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(o.Packs)) {
+		if !packs[name] {
+			logger.Warn("overrides for unknown pack %s are ignored", name)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(o.Rules)) {
+		if !ids[id] {
+			logger.Warn("overrides for unknown rule %s are ignored", id)
+		}
+	}
+}
+
+// Packs returns the sorted names of the packs the rules belong to
+func (rc *RulesConfig) Packs() []string {
+	seen := make(map[string]bool)
+	var packs []string
+	add := func(pack string) {
+		if pack != "" && !seen[pack] {
+			seen[pack] = true
+			packs = append(packs, pack)
+		}
+	}
+	for _, r := range rc.Rules {
+		add(r.Pack)
+	}
+	for _, c := range rc.Correlations {
+		add(c.Pack)
+	}
+	for _, b := range rc.Baselines {
+		add(b.Pack)
+	}
+	sort.Strings(packs)
+	return packs
+}
+
+// setPack assigns pack to the rules that do not name their own
+func (rc *RulesConfig) setPack(pack string) {
+	if pack == "" {
+		return
+	}
+	for _, r := range rc.Rules {
+		if r.Pack == "" {
+			r.Pack = pack
+		}
+	}
+	for _, c := range rc.Correlations {
+		if c.Pack == "" {
+			c.Pack = pack
+		}
+	}
+	for _, b := range rc.Baselines {
+		if b.Pack == "" {
+			b.Pack = pack
+		}
+	}
+}
+
+// dirPack returns the pack of a file in a rules directory: the top-level
+// subdirectory it is under, or "" for files directly in the directory
+func dirPack(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return ""
+	}
+	pack, _, nested := strings.Cut(filepath.ToSlash(rel), "/")
+	if !nested {
+		return ""
+	}
+	return pack
+}
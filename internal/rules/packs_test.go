@@ -0,0 +1,96 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPacks(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule := func(id string, extra string) string {
+		return "  - id: " + id + "\n    title: T\n    expr: kind == \"execution\"\n    severity: low\n    enabled: true\n" + extra
+	}
+	write("local.yaml", "rules:\n"+strings.Replace(rule("LOCAL-1", ""), "enabled: true", "enabled: false", 1))
+	write("community/exec.yaml", "rules:\n"+rule("COMM-1", "")+rule("COMM-2", "    pack: experimental\n"))
+	write("vendor/rules.yaml", "pack: acme\nrules:\n"+rule("ACME-1", ""))
+	write(".vendor.new/rules.yaml", "rules:\n"+rule("ACME-1", "")) // Pack being installed: skipped
+
+	rc, err := LoadRulesDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	want := map[string]string{"LOCAL-1": "", "COMM-1": "community", "COMM-2": "experimental", "ACME-1": "acme"}
+	for _, r := range rc.Rules {
+		if r.Pack != want[r.ID] {
+			t.Errorf("%s pack = %q, want %q", r.ID, r.Pack, want[r.ID])
+		}
+	}
+	if got := rc.Packs(); len(got) != 3 || got[0] != "acme" || got[2] != "experimental" {
+		t.Errorf("Packs() = %v", got)
+	}
+
+	enabled, disabled := true, false
+	rc.ApplyOverrides(&Overrides{
+		Packs: map[string]Override{
+			"community": {Enabled: &disabled},
+			"acme":      {Severity: SeverityHigh},
+			"missing":   {Enabled: &disabled},
+		},
+		Rules: map[string]Override{
+			"COMM-1":  {Enabled: &enabled, Severity: SeverityMedium},
+			"LOCAL-1": {Enabled: &enabled},
+		},
+	})
+	got := make(map[string]string)
+	for _, r := range rc.Rules {
+		if r.Enabled {
+			got[r.ID] = r.Severity
+		}
+	}
+	wantEnabled := map[string]string{"LOCAL-1": "low", "COMM-1": "medium", "COMM-2": "low", "ACME-1": "high"}
+	for id, sev := range wantEnabled {
+		if got[id] != sev {
+			t.Errorf("%s: enabled with severity %q, want %q", id, got[id], sev)
+		}
+	}
+	if len(got) != len(wantEnabled) {
+		t.Errorf("Enabled rules = %v, want %v", got, wantEnabled)
+	}
+}
+
+func TestPackIncludes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("rules:\n  - id: BASE\n    title: T\n    expr: kind == \"execution\"\n    severity: low\n    enabled: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.yaml")
+	if err := os.WriteFile(main, []byte("include: [base.yaml]\npack: corp\nrules: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := LoadRulesFile(main)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if len(rc.Rules) != 1 || rc.Rules[0].Pack != "corp" {
+		t.Errorf("Included rule pack = %v, want corp", rc.Rules)
+	}
+
+	rc, err = Parse([]byte("pack: remote\nrules:\n  - id: R1\n    title: T\n    expr: kind == \"execution\"\n    severity: low\n    enabled: true\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if rc.Rules[0].Pack != "remote" {
+		t.Errorf("Parsed rule pack = %q, want remote", rc.Rules[0].Pack)
+	}
+}
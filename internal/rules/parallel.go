@@ -0,0 +1,56 @@
+package rules
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvaluateFunc evaluates rules against one event, e.g. Engine.EvaluateBulk
+type EvaluateFunc func(ev *Event) ([]*Match, error)
+
+// Result is the outcome of evaluating one event
+type Result struct {
+	Matches []*Match
+	Err     error
+}
+
+// EvaluateEach evaluates evs with up to workers goroutines and returns the
+// results in message order, so callers can emit signals as if the events had
+// been evaluated one by one. Messages whose skip entry is true are left out
+// and get an empty result; skip may be nil. Simple rules keep no state
+// between events, so their evaluation order does not affect the matches.
+func EvaluateEach(evs []*Event, skip []bool, workers int, eval EvaluateFunc) []Result {
+	results := make([]Result, len(evs))
+	run := func(i int) {
+		if skip != nil && skip[i] {
+			return
+		}
+		results[i].Matches, results[i].Err = eval(evs[i])
+	}
+
+	workers = min(workers, len(evs))
+	if workers <= 1 {
+		for i := range evs {
+			run(i)
+		}
+		return results
+	}
+
+	// Workers take the next event as they finish, which balances files
+	// mixing cheap and expensive events
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(evs) {
+					return
+				}
+				run(i)
+			}
+		})
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,65 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEvaluateEach(t *testing.T) {
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{
+		{ID: "EVEN", Title: "Even", Expr: `machine_id.endsWith("0") || machine_id.endsWith("2")`, Severity: "low", Enabled: true},
+		{ID: "ALL", Title: "All", Expr: `machine_id != ""`, Severity: "low", Enabled: true},
+	}})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	stats := map[string]*RuleStats{}
+	engine.CollectStats(stats)
+
+	msgs := make([]*santapb.SantaMessage, 200)
+	skip := make([]bool, len(msgs))
+	for i := range msgs {
+		msgs[i] = &santapb.SantaMessage{MachineId: proto.String(fmt.Sprintf("host-%d", i))}
+		skip[i] = i%10 == 9
+	}
+
+	for _, workers := range []int{0, 1, 8, 500} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			results := EvaluateEach(NewEvents(msgs), skip, workers, engine.EvaluateEvent)
+			if len(results) != len(msgs) {
+				t.Fatalf("Got %d results, want %d", len(results), len(msgs))
+			}
+			for i, res := range results {
+				if res.Err != nil {
+					t.Fatalf("Event %d failed: %v", i, res.Err)
+				}
+				want := 1
+				switch {
+				case skip[i]:
+					want = 0
+				case i%10 == 0 || i%10 == 2:
+					want = 2
+				}
+				if len(res.Matches) != want {
+					t.Fatalf("Event %d got %d matches, want %d", i, len(res.Matches), want)
+				}
+				// Results stay in event order
+				for _, m := range res.Matches {
+					if m.Message != msgs[i] {
+						t.Fatalf("Event %d got a match for another event", i)
+					}
+				}
+			}
+		})
+	}
+	if st := stats["ALL"]; st == nil || st.Evaluations != 4*180 {
+		t.Errorf("ALL stats = %+v, want %d evaluations", st, 4*180)
+	}
+}
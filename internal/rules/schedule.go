@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// clockLayout is the format of active_hours bounds and of time_of_day()
+const clockLayout = "15:04"
+
+// dayNames maps active_days entries to the days they stand for
+var dayNames = map[string][]time.Weekday{
+	"mon": {time.Monday}, "tue": {time.Tuesday}, "wed": {time.Wednesday},
+	"thu": {time.Thursday}, "fri": {time.Friday}, "sat": {time.Saturday},
+	"sun":      {time.Sunday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// schedule is the parsed active_hours, active_days and timezone of a rule
+type schedule struct {
+	start, end int   // Minutes after midnight; end is exclusive and may be before start
+	days       uint8 // Bit per time.Weekday; 0 means every day
+	loc        *time.Location
+}
+
+// parseSchedule parses a rule's schedule fields; it returns nil when the rule
+// is always active
+func parseSchedule(hours string, days []string, timezone string) (*schedule, error) {
+	if hours == "" && len(days) == 0 {
+		if timezone != "" {
+			return nil, fmt.Errorf("timezone requires active_hours or active_days")
+		}
+		return nil, nil
+	}
+	s := &schedule{loc: time.Local}
+	if timezone != "" {
+		loc, err := loadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		s.loc = loc
+	}
+	if hours != "" {
+		from, to, ok := strings.Cut(hours, "-")
+		start, err1 := time.Parse(clockLayout, strings.TrimSpace(from))
+		end, err2 := time.Parse(clockLayout, strings.TrimSpace(to))
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("active_hours must be HH:MM-HH:MM, got %q", hours)
+		}
+		s.start, s.end = start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+		if s.start == s.end {
+			return nil, fmt.Errorf("active_hours %q is empty", hours)
+		}
+	} else {
+		s.start, s.end = 0, 24*60
+	}
+	for _, name := range days {
+		weekdays, ok := dayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid active_days entry %q: use mon..sun, weekdays or weekends", name)
+		}
+		for _, d := range weekdays {
+			s.days |= 1 << d
+		}
+	}
+	return s, nil
+}
+
+// active reports whether the schedule covers t. Windows that wrap midnight
+// (22:00-06:00) cover both ends of the day; days are those of t itself.
+func (s *schedule) active(t time.Time) bool {
+	t = t.In(s.loc)
+	if s.days != 0 && s.days&(1<<t.Weekday()) == 0 {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if s.start < s.end {
+		return minute >= s.start && minute < s.end
+	}
+	return minute >= s.start || minute < s.end
+}
+
+// locations caches the time zones loaded for schedules and time_of_day
+var locations sync.Map // name -> *time.Location
+
+// loadLocation loads an IANA time zone, once per name
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// scheduleFunctions declares time_of_day(), the "HH:MM" time of the event
+// being evaluated in the host's time zone, and time_of_day(tz) in an IANA
+// time zone. Like the ancestry functions, macros pass the event along.
+func scheduleFunctions(eventType *cel.Type) []cel.EnvOption {
+	clock := func(ev ref.Val, loc *time.Location) ref.Val {
+		msg, ok := ev.Value().(*santapb.SantaMessage)
+		if !ok {
+			return types.NewErr("time_of_day: not an event")
+		}
+		t := events.EventTime(msg)
+		if t.IsZero() {
+			t = time.Now()
+		}
+		return types.String(t.In(loc).Format(clockLayout))
+	}
+	return []cel.EnvOption{
+		cel.Macros(withEvent("time_of_day", 0), withEvent("time_of_day", 1)),
+		cel.Function("time_of_day",
+			cel.Overload("time_of_day_event", []*cel.Type{eventType}, cel.StringType,
+				cel.UnaryBinding(func(ev ref.Val) ref.Val {
+					return clock(ev, time.Local)
+				})),
+			cel.Overload("time_of_day_event_string", []*cel.Type{eventType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(func(ev, tz ref.Val) ref.Val {
+					loc, err := loadLocation(string(tz.(types.String)))
+					if err != nil {
+						return types.NewErr("time_of_day: %v", err)
+					}
+					return clock(ev, loc)
+				}))),
+	}
+}
@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSchedule(t *testing.T) {
+	// 2026-10-16 is a Friday
+	at := func(day, clock string) time.Time {
+		ts, err := time.Parse("2006-01-02 15:04", "2026-10-"+day+" "+clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	tests := []struct {
+		name  string
+		hours string
+		days  []string
+		tz    string
+		at    time.Time
+		want  bool
+	}{
+		{"inside", "09:00-17:00", nil, "UTC", at("16", "09:00"), true},
+		{"end is exclusive", "09:00-17:00", nil, "UTC", at("16", "17:00"), false},
+		{"wraps midnight, evening", "18:00-08:00", nil, "UTC", at("16", "23:30"), true},
+		{"wraps midnight, morning", "18:00-08:00", nil, "UTC", at("16", "07:59"), true},
+		{"wraps midnight, daytime", "18:00-08:00", nil, "UTC", at("16", "12:00"), false},
+		{"weekend only", "", []string{"weekends"}, "UTC", at("17", "12:00"), true},
+		{"weekend only, weekday", "", []string{"weekends"}, "UTC", at("16", "12:00"), false},
+		{"days and hours", "00:00-06:00", []string{"Sat", "sun"}, "UTC", at("18", "05:00"), true},
+		{"timezone", "09:00-17:00", nil, "America/New_York", at("16", "14:00"), true},
+		{"timezone shifts the day", "", []string{"thu"}, "America/Los_Angeles", at("16", "02:00"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseSchedule(tt.hours, tt.days, tt.tz)
+			if err != nil {
+				t.Fatalf("parseSchedule() failed: %v", err)
+			}
+			if got := s.active(tt.at); got != tt.want {
+				t.Errorf("active(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+
+	if s, err := parseSchedule("", nil, ""); s != nil || err != nil {
+		t.Errorf("parseSchedule() of no schedule = %v, %v, want nil", s, err)
+	}
+	errs := []struct {
+		hours, tz string
+		days      []string
+		wantErr   string
+	}{
+		{hours: "9-17", wantErr: "active_hours must be HH:MM-HH:MM"},
+		{hours: "09:00-09:00", wantErr: "is empty"},
+		{days: []string{"friday"}, wantErr: `invalid active_days entry "friday"`},
+		{hours: "09:00-17:00", tz: "Mars/Olympus", wantErr: `invalid timezone "Mars/Olympus"`},
+		{tz: "UTC", wantErr: "timezone requires active_hours or active_days"},
+	}
+	for _, tt := range errs {
+		if _, err := parseSchedule(tt.hours, tt.days, tt.tz); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseSchedule(%q, %v, %q) error = %v, want %q", tt.hours, tt.days, tt.tz, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduledRules(t *testing.T) {
+	rc, err := Parse([]byte(`
+rules:
+  - id: AFTER-HOURS
+    title: Admin tool outside business hours
+    expr: kind == "execution"
+    active_hours: "18:00-08:00"
+    timezone: UTC
+    severity: medium
+    enabled: true
+  - id: LUNCH
+    title: Executions over lunch
+    expr: kind == "execution" && time_of_day("UTC") >= "12:00" && time_of_day("UTC") < "13:00"
+    severity: low
+    enabled: true
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := engine.LoadRules(rc); err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+
+	exec := func(clock string) *santapb.SantaMessage {
+		ts, _ := time.Parse(time.RFC3339, "2026-10-16T"+clock+":00Z")
+		return &santapb.SantaMessage{
+			EventTime: timestamppb.New(ts),
+			Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String("/usr/bin/dscl")}},
+			}},
+		}
+	}
+	for clock, want := range map[string]string{"21:15": "AFTER-HOURS", "12:30": "LUNCH", "10:00": ""} {
+		matches, err := engine.Evaluate(exec(clock))
+		if err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.RuleID)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("At %s matched %v, want %q", clock, got, want)
+		}
+	}
+
+	// Invalid schedules and time zones fail at load time
+	if _, err := Parse([]byte("rules:\n  - id: R1\n    title: T\n    expr: kind == \"execution\"\n    active_days: [someday]\n    severity: low\n    enabled: true\n")); err == nil {
+		t.Error("Parse() accepted an invalid active_days entry")
+	}
+	rc, err = Parse([]byte("rules:\n  - id: R1\n    title: T\n    expr: time_of_day(\"Nowhere/City\") > \"12:00\"\n    severity: low\n    enabled: true\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if err := engine.LoadRules(rc); err == nil || !strings.Contains(err.Error(), "invalid timezone") {
+		t.Errorf("LoadRules() error = %v, want invalid timezone", err)
+	}
+}
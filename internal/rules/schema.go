@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/lineage"
+)
+
+// activationFields are added to the event map by events.BuildActivation and
+// are not part of the Santa protobuf schema
+var activationFields = map[string]bool{
+	"kind": true,
+}
+
+// FieldError describes a rule field path that does not fit the Santa schema
+type FieldError struct {
+	RuleID string
+	Option string // track, scope, group_by, count_distinct or extra_context
+	Path   string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s %q: %s", e.RuleID, e.Option, e.Path, e.Reason)
+}
+
+// CheckFields checks the track, scope, group_by, count_distinct and extra_context
+// paths of every rule against the Santa protobuf schema. Paths used to build
+// keys must end at a scalar field; extra_context paths only need to exist.
+func CheckFields(rc *RulesConfig) []error {
+	var errs []error
+	check := func(ruleID, option, path string, scalar bool) {
+		if path == "" {
+			return
+		}
+		if reason := checkPath(path, scalar); reason != "" {
+			errs = append(errs, &FieldError{RuleID: ruleID, Option: option, Path: path, Reason: reason})
+		}
+	}
+
+	for _, r := range rc.Rules {
+		for _, path := range r.ExtraContext {
+			check(r.ID, "extra_context", path, false)
+		}
+	}
+	// Correlation keys may also use the lineage fields of the event's process
+	checkKey := func(ruleID, option, path string) {
+		name, ok := strings.CutPrefix(path, lineagePrefix)
+		if !ok {
+			check(ruleID, option, path, true)
+		} else if !lineage.Fields[name] {
+			errs = append(errs, &FieldError{RuleID: ruleID, Option: option, Path: path, Reason: "unknown lineage field"})
+		}
+	}
+	for _, c := range rc.Correlations {
+		for _, path := range c.GroupBy {
+			checkKey(c.ID, "group_by", path)
+		}
+		for _, path := range c.CountDistinct {
+			checkKey(c.ID, "count_distinct", path)
+		}
+		for _, path := range c.ExpectGroupBy {
+			checkKey(c.ID, "expect_group_by", path)
+		}
+	}
+	for _, b := range rc.Baselines {
+		for _, path := range b.Track {
+			check(b.ID, "track", path, true)
+		}
+		for _, path := range b.Scope {
+			check(b.ID, "scope", path, true)
+		}
+	}
+	return errs
+}
+
+// checkPath resolves a dotted event path against the SantaMessage descriptor
+// and returns why it is invalid, or "" if it resolves
+func checkPath(path string, scalar bool) string {
+	parts := strings.Split(strings.TrimPrefix(path, "event."), ".")
+	if len(parts) == 1 && activationFields[parts[0]] {
+		return ""
+	}
+
+	msg := (&santapb.SantaMessage{}).ProtoReflect().Descriptor()
+	for i, part := range parts {
+		fd := msg.Fields().ByName(protoreflect.Name(part))
+		if fd == nil {
+			return fmt.Sprintf("unknown field %q in %s", part, msg.Name())
+		}
+		last := i == len(parts)-1
+		name := strings.Join(parts[:i+1], ".")
+
+		switch {
+		case fd.IsMap():
+			return fmt.Sprintf("%s is a map and cannot be used in a path", name)
+		case fd.IsList() && fd.Message() != nil:
+			return fmt.Sprintf("%s is a repeated message and cannot be used in a path", name)
+		}
+
+		if fd.Message() == nil || wellKnownScalar(fd.Message()) {
+			if !last {
+				return fmt.Sprintf("%s is a %s, not a message", name, fieldType(fd))
+			}
+			return ""
+		}
+		if last {
+			if scalar {
+				return fmt.Sprintf("%s is a %s message, not a scalar field", name, fd.Message().Name())
+			}
+			return ""
+		}
+		msg = fd.Message()
+	}
+	return ""
+}
+
+// wellKnownScalar reports whether a message type is rendered as a single
+// JSON value in the event map (e.g. timestamps as RFC 3339 strings)
+func wellKnownScalar(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration":
+		return true
+	}
+	return false
+}
+
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	if md := fd.Message(); md != nil {
+		return string(md.Name())
+	}
+	if fd.IsList() {
+		return "repeated " + fd.Kind().String()
+	}
+	return fd.Kind().String()
+}
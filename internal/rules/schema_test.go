@@ -0,0 +1,43 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckFields(t *testing.T) {
+	rc := &RulesConfig{
+		Rules: []*Rule{{
+			ID:           "R1",
+			ExtraContext: []string{"execution.target", "event.execution.target.executable.path", "execution.target.bogus"},
+		}},
+		Correlations: []*CorrelationRule{{
+			ID:            "C1",
+			GroupBy:       []string{"event.execution.instigator.effective_user.uid", "machine_id", "kind", "lineage.root", "lineage.bogus"},
+			CountDistinct: Fields{"execution.target.executable", "lineage.parent_path"},
+		}},
+		Baselines: []*BaselineRule{{
+			ID:    "B1",
+			Track: []string{"execution.target.executable.path", "event.execution.args", "event_time", "execution.args.foo", "execution.fds"},
+			Scope: []string{"execution.target.effective_user.name", "execution.target.effective_user"},
+		}},
+	}
+
+	errs := CheckFields(rc)
+	want := []string{
+		`R1: extra_context "execution.target.bogus": unknown field "bogus"`,
+		`C1: group_by "lineage.bogus": unknown lineage field`,
+		`C1: count_distinct "execution.target.executable": execution.target.executable is a FileInfo message, not a scalar field`,
+		`B1: track "execution.args.foo": execution.args is a repeated bytes, not a message`,
+		`B1: track "execution.fds": execution.fds is a repeated message`,
+		`B1: scope "execution.target.effective_user": execution.target.effective_user is a UserInfo message, not a scalar field`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), want[i]) {
+			t.Errorf("Error %d = %q, want prefix %q", i, err, want[i])
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suppressions are rule exceptions kept in a separate file, so tuning can be
+// managed independently of detection content. Each entry may expire:
+//
+//	suppressions:
+//	  SM-003:
+//	    - expr: event.execution.target.executable.path.startsWith("/opt/build/")
+//	      expires: 2026-12-31
+//	      reason: "INC-1234 build agents"
+//	    - field: event.execution.target.code_signature.team_id
+//	      values: ["EQHXZ8M8AV"]
+type Suppressions struct {
+	Rules   map[string][]Suppression `yaml:"suppressions"`
+	version string
+}
+
+// Suppression is an exception for one rule with an optional expiry
+type Suppression struct {
+	Expr    string   `yaml:"expr,omitempty"`
+	Field   string   `yaml:"field,omitempty"`
+	Values  []string `yaml:"values,omitempty"`
+	Expires string   `yaml:"expires,omitempty"` // Date (2006-01-02) or RFC 3339 time from which it no longer applies
+	Reason  string   `yaml:"reason,omitempty"`
+
+	expiresAt time.Time // Zero when the suppression never expires
+}
+
+// UnmarshalYAML accepts a plain string as a CEL expression
+func (s *Suppression) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.Expr = node.Value
+		return nil
+	}
+	type plain Suppression
+	return node.Decode((*plain)(s))
+}
+
+// Exception returns the suppression's match condition
+func (s *Suppression) Exception() Exception {
+	return Exception{Expr: s.Expr, Field: s.Field, Values: s.Values}
+}
+
+// Expired reports whether the suppression no longer applies at now
+func (s *Suppression) Expired(now time.Time) bool {
+	return !s.expiresAt.IsZero() && !now.Before(s.expiresAt)
+}
+
+// LoadSuppressions reads a suppressions file
+func LoadSuppressions(path string) (*Suppressions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppressions: %w", err)
+	}
+	s, err := ParseSuppressions(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseSuppressions parses and validates a suppressions YAML document
+func ParseSuppressions(data []byte) (*Suppressions, error) {
+	var s Suppressions
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse suppressions: %w", err)
+	}
+	for ruleID, entries := range s.Rules {
+		for i := range entries {
+			entry := &entries[i]
+			if err := validateExceptions([]Exception{entry.Exception()}); err != nil {
+				return nil, fmt.Errorf("suppression %d for %s: %w", i, ruleID, err)
+			}
+			if entry.Expires == "" {
+				continue
+			}
+			expires, err := parseExpiry(entry.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("suppression %d for %s: %w", i, ruleID, err)
+			}
+			entry.expiresAt = expires
+		}
+	}
+	sum := sha256.Sum256(data)
+	s.version = hex.EncodeToString(sum[:6])
+	return &s, nil
+}
+
+// parseExpiry parses a date (midnight UTC) or an RFC 3339 time
+func parseExpiry(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expires %q: must be a date (2006-01-02) or RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// Version identifies the suppressions file content. It is empty for nil
+// suppressions.
+func (s *Suppressions) Version() string {
+	if s == nil {
+		return ""
+	}
+	return s.version
+}
+
+// Len returns the number of suppressions
+func (s *Suppressions) Len() int {
+	if s == nil {
+		return 0
+	}
+	n := 0
+	for _, entries := range s.Rules {
+		n += len(entries)
+	}
+	return n
+}
+
+// UnknownRules returns the rule IDs with suppressions that are not defined in rc
+func (s *Suppressions) UnknownRules(rc *RulesConfig) []string {
+	if s == nil {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, r := range rc.Rules {
+		known[r.ID] = true
+	}
+	for _, c := range rc.Correlations {
+		known[c.ID] = true
+	}
+	for _, b := range rc.Baselines {
+		known[b.ID] = true
+	}
+	var unknown []string
+	for id := range s.Rules {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// forRule returns the suppressions for ruleID
+func (s *Suppressions) forRule(ruleID string) []Suppression {
+	if s == nil {
+		return nil
+	}
+	return s.Rules[ruleID]
+}
+
+// SetSuppressions merges suppressions into the exceptions of rules compiled
+// afterwards. It must be called before LoadRules.
+func (e *Engine) SetSuppressions(s *Suppressions) {
+	e.suppressions = s
+}
+
+// SuppressionsVersion returns the version of the merged suppressions, or ""
+// when there are none
+func (e *Engine) SuppressionsVersion() string {
+	return e.suppressions.Version()
+}
@@ -0,0 +1,138 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+const testSuppressions = `suppressions:
+  R1:
+    - event.execution.target.executable.path == "/usr/bin/true"
+    - field: event.execution.target.executable.path
+      values: ["/usr/bin/false"]
+      expires: 2000-01-01
+      reason: "expired long ago"
+    - expr: event.execution.target.executable.path == "/usr/bin/yes"
+      expires: "2999-01-01T00:00:00Z"
+      reason: "INC-1234"
+  GONE:
+    - kind == "execution"
+`
+
+func TestLoadSuppressions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.yaml")
+	if err := os.WriteFile(path, []byte(testSuppressions), 0644); err != nil {
+		t.Fatalf("Failed to write suppressions: %v", err)
+	}
+	s, err := LoadSuppressions(path)
+	if err != nil {
+		t.Fatalf("Failed to load suppressions: %v", err)
+	}
+	if s.Len() != 4 {
+		t.Errorf("Expected 4 suppressions, got %d", s.Len())
+	}
+	if s.Version() == "" {
+		t.Error("Expected a version")
+	}
+
+	entries := s.Rules["R1"]
+	now := time.Now()
+	if entries[0].Expired(now) || !entries[1].Expired(now) || entries[2].Expired(now) {
+		t.Errorf("Unexpected expiry: %v, %v, %v", entries[0].Expired(now), entries[1].Expired(now), entries[2].Expired(now))
+	}
+	if entries[2].Reason != "INC-1234" {
+		t.Errorf("Reason = %q", entries[2].Reason)
+	}
+
+	rc := &RulesConfig{Rules: []*Rule{{ID: "R1"}}}
+	if got := s.UnknownRules(rc); len(got) != 1 || got[0] != "GONE" {
+		t.Errorf("UnknownRules() = %v, want [GONE]", got)
+	}
+}
+
+func TestParseSuppressionsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "bad expiry", data: "suppressions:\n  R1:\n    - expr: kind == \"fork\"\n      expires: next week\n", want: "invalid expires"},
+		{name: "field without values", data: "suppressions:\n  R1:\n    - field: kind\n", want: "has no values"},
+		{name: "empty", data: "suppressions:\n  R1:\n    - reason: why\n", want: "is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSuppressions([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestEngineSuppressions(t *testing.T) {
+	s, err := ParseSuppressions([]byte(testSuppressions))
+	if err != nil {
+		t.Fatalf("Failed to parse suppressions: %v", err)
+	}
+	engine, err := NewEngine()
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	engine.SetSuppressions(s)
+	err = engine.LoadRules(&RulesConfig{Rules: []*Rule{{
+		ID:         "R1",
+		Title:      "Exec",
+		Expr:       `kind == "execution"`,
+		Severity:   "low",
+		Enabled:    true,
+		Exceptions: []Exception{{Expr: `event.execution.target.executable.path == "/usr/bin/env"`}},
+	}}})
+	if err != nil {
+		t.Fatalf("LoadRules() failed: %v", err)
+	}
+	if engine.SuppressionsVersion() != s.Version() {
+		t.Errorf("SuppressionsVersion() = %q, want %q", engine.SuppressionsVersion(), s.Version())
+	}
+
+	exec := func(path string) *santapb.SantaMessage {
+		return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+			Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}},
+		}}}
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/usr/bin/env", want: false},  // Rule exception
+		{path: "/usr/bin/true", want: false}, // Suppression
+		{path: "/usr/bin/yes", want: false},  // Suppression not yet expired
+		{path: "/usr/bin/false", want: true}, // Suppression expired
+		{path: "/usr/bin/ls", want: true},
+	}
+	for _, tt := range tests {
+		matches, err := engine.Evaluate(exec(tt.path))
+		if err != nil {
+			t.Fatalf("Evaluate() failed: %v", err)
+		}
+		if got := len(matches) == 1; got != tt.want {
+			t.Errorf("%s: matched = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Suppressions stop applying once they expire, without a reload
+	checks := engine.rules[0].Exceptions.checks
+	checks[len(checks)-1].expires = time.Now().Add(-time.Second)
+	if matches, _ := engine.Evaluate(exec("/usr/bin/yes")); len(matches) != 1 {
+		t.Error("Expired suppression still applied")
+	}
+	if got := engine.Suppressions()["R1"]; got != 3 {
+		t.Errorf("Suppressions() = %d, want 3", got)
+	}
+}
@@ -1,5 +1,7 @@
 package rules
 
+import "gopkg.in/yaml.v3"
+
 // Severity levels for rules
 const (
 	SeverityLow      = "low"
@@ -15,3 +17,46 @@ var ValidSeverities = map[string]bool{
 	SeverityHigh:     true,
 	SeverityCritical: true,
 }
+
+// severityRank orders severities from least to most severe
+var severityRank = map[string]int{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// SeverityAtLeast reports whether severity is at least as severe as threshold.
+// Severities outside ValidSeverities, such as info, rank below low.
+func SeverityAtLeast(severity, threshold string) bool {
+	return severityRank[severity] >= severityRank[threshold]
+}
+
+// Fields is a list of event field paths that YAML may also give as a single
+// string
+type Fields []string
+
+// UnmarshalYAML accepts a plain string as a single field
+func (f *Fields) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*f = nil
+		if node.Value != "" {
+			*f = Fields{node.Value}
+		}
+		return nil
+	}
+	var fields []string
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	*f = fields
+	return nil
+}
+
+// MarshalYAML writes a single field back as a plain string
+func (f Fields) MarshalYAML() (any, error) {
+	if len(f) == 1 {
+		return f[0], nil
+	}
+	return []string(f), nil
+}
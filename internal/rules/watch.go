@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch calls reload once changes to the rules at path have been quiet for
+// debounce, so a burst of writes causes a single reload. path may be a rules
+// file or a directory, which is watched recursively. A file is watched via
+// its parent directory so editors that replace it on save are seen. Watch
+// returns nil when ctx is done.
+func Watch(ctx context.Context, path string, debounce time.Duration, reload func()) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat rules path: %w", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create fsnotify watcher: %w", err)
+	}
+	defer func() { _ = watcher.Close() }()
+
+	isDir := info.IsDir()
+	if isDir {
+		err = watchTree(watcher, path)
+	} else {
+		err = watcher.Add(filepath.Dir(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to watch rules path: %w", err)
+	}
+
+	// relevant reports whether a change to name can affect the loaded rules
+	relevant := func(name string) bool {
+		if !isDir {
+			return filepath.Clean(name) == filepath.Clean(path)
+		}
+		ext := strings.ToLower(filepath.Ext(name))
+		return ext == ".yaml" || ext == ".yml"
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("watcher events channel closed")
+			}
+			// New subdirectories may already hold rules files
+			if isDir && event.Has(fsnotify.Create) {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						logger.Warn("failed to watch rules directory %s: %v", event.Name, err)
+					}
+					timer.Reset(debounce)
+					continue
+				}
+			}
+			if event.Op == fsnotify.Chmod || !relevant(event.Name) {
+				continue
+			}
+			timer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("watcher errors channel closed")
+			}
+			// Dropped events may have been changes; reload to be safe
+			logger.Warn("rules watcher error: %v", err)
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			reload()
+		}
+	}
+}
+
+// watchTree adds dir and its subdirectories to watcher
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchRules runs Watch on path and returns a channel receiving each reload
+func watchRules(t *testing.T, path string) <-chan struct{} {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan struct{}, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, path, 100*time.Millisecond, func() { reloads <- struct{}{} })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch() failed: %v", err)
+		}
+	})
+	// Let the watcher start before making changes
+	time.Sleep(50 * time.Millisecond)
+	return reloads
+}
+
+// expectReloads waits for want reloads and checks no more follow
+func expectReloads(t *testing.T, reloads <-chan struct{}, want int) {
+	t.Helper()
+	for i := range want {
+		select {
+		case <-reloads:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for reload %d", i+1)
+		}
+	}
+	select {
+	case <-reloads:
+		t.Fatalf("Expected %d reloads, got more", want)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(path, []byte("rules: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	reloads := watchRules(t, path)
+
+	// A burst of writes is one reload
+	for range 5 {
+		if err := os.WriteFile(path, []byte("rules: []\n# edited\n"), 0644); err != nil {
+			t.Fatalf("Failed to write rules: %v", err)
+		}
+	}
+	expectReloads(t, reloads, 1)
+
+	// Other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(dir, "notes.yaml"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectReloads(t, reloads, 0)
+
+	// Replacing the file on save, as editors do, is a change
+	tmp := filepath.Join(dir, ".rules.yaml.tmp")
+	if err := os.WriteFile(tmp, []byte("rules: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to replace rules: %v", err)
+	}
+	expectReloads(t, reloads, 1)
+}
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	reloads := watchRules(t, dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectReloads(t, reloads, 0)
+
+	sub := filepath.Join(dir, "macos")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	expectReloads(t, reloads, 1)
+
+	// Files in new subdirectories are watched too
+	if err := os.WriteFile(filepath.Join(sub, "exec.yml"), []byte("rules: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	expectReloads(t, reloads, 1)
+}
+
+func TestWatchMissingPath(t *testing.T) {
+	err := Watch(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"), time.Second, func() {})
+	if err == nil {
+		t.Error("Expected error for missing rules path")
+	}
+}
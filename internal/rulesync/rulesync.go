@@ -0,0 +1,305 @@
+package rulesync
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/fsutil"
+	"github.com/0x4d31/santamon/internal/logutil"
+	"gopkg.in/yaml.v3"
+)
+
+var logger = logutil.For("rulesync")
+
+const (
+	bundleFile    = "rules.yaml"
+	signatureFile = "rules.yaml.sig"
+
+	// versionFile records the highest bundle_version installed, so a
+	// replayed older bundle is refused even after a restart
+	versionFile = "rules.version"
+
+	// previousSuffix marks the bundle that was cached before the current one
+	previousSuffix = ".prev"
+
+	// maxBundleSize caps downloads so a bad URL can't exhaust memory
+	maxBundleSize = 16 << 20
+)
+
+// Validator rejects bundles that verify but can't be loaded (e.g. rules that
+// fail to compile), so they are neither cached nor delivered.
+type Validator func(bundle []byte) error
+
+// Fetcher periodically pulls a signed rules bundle and delivers each new,
+// verified bundle on Updates. The last good bundle is cached on disk.
+type Fetcher struct {
+	cfg      config.RemoteRulesConfig
+	key      *PublicKey
+	validate Validator
+	client   *http.Client
+
+	updates chan []byte
+	trigger chan struct{}
+
+	mu      sync.Mutex
+	digest  [sha256.Size]byte
+	version int64 // Highest bundle_version installed
+	lastErr error
+}
+
+// NewFetcher creates a fetcher for the configured bundle
+func NewFetcher(cfg config.RemoteRulesConfig, validate Validator) (*Fetcher, error) {
+	key, err := ParsePublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules.remote.public_key: %w", err)
+	}
+	version, err := readVersion(filepath.Join(cfg.CacheDir, versionFile))
+	if err != nil {
+		return nil, err
+	}
+	// The cached bundle is the one installed; only a newer version replaces it
+	var digest [sha256.Size]byte
+	if cached, err := os.ReadFile(filepath.Join(cfg.CacheDir, bundleFile)); err == nil {
+		digest = sha256.Sum256(cached)
+	}
+	return &Fetcher{
+		cfg:      cfg,
+		key:      key,
+		validate: validate,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		updates:  make(chan []byte),
+		trigger:  make(chan struct{}, 1),
+		digest:   digest,
+		version:  version,
+	}, nil
+}
+
+// Updates delivers each new verified bundle
+func (f *Fetcher) Updates() <-chan []byte {
+	return f.updates
+}
+
+// Trigger requests an immediate fetch without waiting for the interval
+func (f *Fetcher) Trigger() {
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Health reports the last fetch error, if any
+func (f *Fetcher) Health() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastErr
+}
+
+// Cached returns the last verified bundle from the cache directory. The
+// signature is checked again so a tampered cache is never loaded. Returns an
+// error wrapping os.ErrNotExist when nothing has been cached yet.
+func (f *Fetcher) Cached() ([]byte, error) {
+	bundle, err := os.ReadFile(filepath.Join(f.cfg.CacheDir, bundleFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached bundle: %w", err)
+	}
+	sig, err := os.ReadFile(filepath.Join(f.cfg.CacheDir, signatureFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached signature: %w", err)
+	}
+	if err := f.key.Verify(bundle, sig); err != nil {
+		return nil, fmt.Errorf("cached bundle: %w", err)
+	}
+
+	f.mu.Lock()
+	f.digest = sha256.Sum256(bundle)
+	f.mu.Unlock()
+	return bundle, nil
+}
+
+// Rollback restores the previously cached bundle, so the next startup loads
+// it instead of the current one. The installed bundle_version is kept, so
+// the current bundle is not installed again, even after a restart: the fix
+// must be published with a higher one.
+func (f *Fetcher) Rollback() error {
+	for _, name := range []string{bundleFile, signatureFile} {
+		path := filepath.Join(f.cfg.CacheDir, name)
+		if err := os.Rename(path+previousSuffix, path); err != nil {
+			return fmt.Errorf("failed to restore previous %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Fetch downloads and verifies the bundle. It returns nil when the bundle
+// is unchanged since the last successful fetch. A changed bundle must raise
+// the signed bundle_version above the installed one, so neither an older
+// bundle nor one reusing a version can be replayed, and a rolled-back bundle
+// is not installed again.
+func (f *Fetcher) Fetch(ctx context.Context) ([]byte, error) {
+	bundle, err := f.get(ctx, f.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle: %w", err)
+	}
+	sig, err := f.get(ctx, f.cfg.SignatureURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	if err := f.key.Verify(bundle, sig); err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(bundle)
+	f.mu.Lock()
+	unchanged := digest == f.digest
+	installed := f.version
+	f.mu.Unlock()
+	if unchanged {
+		return nil, nil
+	}
+
+	version, err := bundleVersion(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if version <= installed {
+		return nil, fmt.Errorf("bundle_version %d is not newer than installed version %d, refusing to roll back", version, installed)
+	}
+
+	if f.validate != nil {
+		if err := f.validate(bundle); err != nil {
+			return nil, fmt.Errorf("verified bundle rejected: %w", err)
+		}
+	}
+	if err := f.store(bundle, sig); err != nil {
+		return nil, err
+	}
+	data := []byte(strconv.FormatInt(version, 10) + "\n")
+	if err := fsutil.WriteFileAtomic(filepath.Join(f.cfg.CacheDir, versionFile), data, 0600); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.digest = digest
+	f.version = version
+	f.mu.Unlock()
+	return bundle, nil
+}
+
+// Run polls for new bundles until ctx is cancelled
+func (f *Fetcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		bundle, err := f.Fetch(ctx)
+		f.mu.Lock()
+		f.lastErr = err
+		f.mu.Unlock()
+
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Warn("Remote rules update failed: %v", err)
+		case bundle != nil:
+			logger.Info("Fetched new rules bundle from %s (%d bytes)", f.cfg.URL, len(bundle))
+			select {
+			case f.updates <- bundle:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-f.trigger:
+		}
+	}
+}
+
+// get downloads url with a size cap
+func (f *Fetcher) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBundleSize {
+		return nil, errors.New("response exceeds 16MB limit")
+	}
+	return data, nil
+}
+
+// store replaces the cached bundle and signature, keeping the current pair
+// as the rollback target. A crash between the writes leaves a mismatched or
+// missing pair, which Cached rejects.
+func (f *Fetcher) store(bundle, sig []byte) error {
+	if err := os.MkdirAll(f.cfg.CacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create rules cache directory: %w", err)
+	}
+	for _, name := range []string{bundleFile, signatureFile} {
+		path := filepath.Join(f.cfg.CacheDir, name)
+		if err := os.Rename(path, path+previousSuffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to keep previous %s: %w", name, err)
+		}
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(f.cfg.CacheDir, bundleFile), bundle, 0600); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(f.cfg.CacheDir, signatureFile), sig, 0600)
+}
+
+// bundleVersion returns the bundle_version a bundle declares. It is part of
+// the signed bundle, so only the publisher can raise it.
+func bundleVersion(bundle []byte) (int64, error) {
+	var header struct {
+		BundleVersion int64 `yaml:"bundle_version"`
+	}
+	if err := yaml.Unmarshal(bundle, &header); err != nil {
+		return 0, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	if header.BundleVersion <= 0 {
+		return 0, errors.New("bundle has no bundle_version")
+	}
+	return header.BundleVersion, nil
+}
+
+// readVersion returns the bundle version recorded at path, or 0 when no
+// bundle has been installed yet
+func readVersion(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read installed bundle version: %w", err)
+	}
+	version, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid installed bundle version in %s: %w", path, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,341 @@
+package rulesync
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+func generateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return pub, priv
+}
+
+// minisignKey encodes pub as a minisign public key with the given key ID
+func minisignKey(pub ed25519.PublicKey, keyID []byte) string {
+	raw := append(append([]byte(minisignLegacy), keyID...), pub...)
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+}
+
+// minisignSign produces a minisign signature file for data
+func minisignSign(priv ed25519.PrivateKey, keyID []byte, alg string, data []byte, trusted string) []byte {
+	sig := ed25519.Sign(priv, data)
+	raw := append(append([]byte(alg), keyID...), sig...)
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), trusted...))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(raw) + "\n" +
+		"trusted comment: " + trusted + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv := generateKey(t)
+	_, otherPriv := generateKey(t)
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	data := []byte("rules: []\n")
+
+	rawKey, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatalf("Failed to parse raw key: %v", err)
+	}
+	miniKey, err := ParsePublicKey(minisignKey(pub, keyID))
+	if err != nil {
+		t.Fatalf("Failed to parse minisign key: %v", err)
+	}
+
+	rawSig := ed25519.Sign(priv, data)
+	minisig := minisignSign(priv, keyID, minisignLegacy, data, "timestamp:1700000000")
+	tampered := []byte(strings.Replace(string(minisig), "timestamp:1700000000", "timestamp:1800000000", 1))
+
+	tests := []struct {
+		name    string
+		key     *PublicKey
+		data    []byte
+		sig     []byte
+		wantErr string
+	}{
+		{name: "raw binary", key: rawKey, data: data, sig: rawSig},
+		{name: "raw base64", key: rawKey, data: data, sig: []byte(base64.StdEncoding.EncodeToString(rawSig) + "\n")},
+		{name: "raw modified data", key: rawKey, data: []byte("rules: [x]\n"), sig: rawSig, wantErr: "verification failed"},
+		{name: "raw wrong key", key: rawKey, data: data, sig: ed25519.Sign(otherPriv, data), wantErr: "verification failed"},
+		{name: "raw garbage", key: rawKey, data: data, sig: []byte("not a signature"), wantErr: "neither"},
+		{name: "minisign", key: miniKey, data: data, sig: minisig},
+		{name: "minisign with raw key", key: rawKey, data: data, sig: minisig},
+		{name: "minisign modified data", key: miniKey, data: []byte("rules: [x]\n"), sig: minisig, wantErr: "verification failed"},
+		{name: "minisign tampered comment", key: miniKey, data: data, sig: tampered, wantErr: "trusted comment"},
+		{name: "minisign other key id", key: miniKey, data: data, sig: minisignSign(priv, []byte("otherkey"), minisignLegacy, data, "x"), wantErr: "different key"},
+		{name: "minisign prehashed", key: miniKey, data: data, sig: minisignSign(priv, keyID, minisignPrehashed, data, "x"), wantErr: "prehashed"},
+		{name: "minisign key needs minisign sig", key: miniKey, data: data, sig: rawSig, wantErr: "expected a minisign signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.key.Verify(tt.data, tt.sig)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := ParsePublicKey("c2hvcnQ="); err == nil {
+		t.Error("Expected error for short public key")
+	}
+}
+
+// bundleServer serves a bundle and its signature, both replaceable
+type bundleServer struct {
+	mu          sync.Mutex
+	bundle, sig []byte
+}
+
+func (s *bundleServer) set(bundle, sig []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bundle, s.sig = bundle, sig
+}
+
+func (s *bundleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/rules.yaml":
+		_, _ = w.Write(s.bundle)
+	case "/rules.yaml.sig":
+		_, _ = w.Write(s.sig)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestFetcher(t *testing.T, url string, pub ed25519.PublicKey, validate Validator) *Fetcher {
+	t.Helper()
+	return newTestFetcherIn(t, t.TempDir(), url, pub, validate)
+}
+
+// newTestFetcherIn creates a fetcher caching bundles in dir
+func newTestFetcherIn(t *testing.T, dir, url string, pub ed25519.PublicKey, validate Validator) *Fetcher {
+	t.Helper()
+	f, err := NewFetcher(config.RemoteRulesConfig{
+		URL:          url + "/rules.yaml",
+		SignatureURL: url + "/rules.yaml.sig",
+		PublicKey:    base64.StdEncoding.EncodeToString(pub),
+		Interval:     time.Hour,
+		CacheDir:     dir,
+	}, validate)
+	if err != nil {
+		t.Fatalf("Failed to create fetcher: %v", err)
+	}
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	pub, priv := generateKey(t)
+	srv := &bundleServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	v1 := []byte("bundle_version: 1\nrules:\n  - id: v1\n")
+	srv.set(v1, ed25519.Sign(priv, v1))
+
+	f := newTestFetcher(t, ts.URL, pub, func(b []byte) error {
+		if strings.Contains(string(b), "invalid") {
+			return errors.New("bad rules")
+		}
+		return nil
+	})
+	ctx := context.Background()
+
+	if _, err := f.Cached(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Cached() before fetch error = %v, want ErrNotExist", err)
+	}
+
+	got, err := f.Fetch(ctx)
+	if err != nil || string(got) != string(v1) {
+		t.Fatalf("Fetch() = %q, %v", got, err)
+	}
+	if got, err := f.Fetch(ctx); err != nil || got != nil {
+		t.Errorf("Fetch() unchanged = %q, %v; want nil, nil", got, err)
+	}
+
+	// A bundle with a bad signature is rejected and the cache is untouched
+	srv.set([]byte("rules: []\n"), ed25519.Sign(priv, v1))
+	if _, err := f.Fetch(ctx); err == nil {
+		t.Error("Expected verification error for mismatched signature")
+	}
+
+	// A signed bundle that fails validation is rejected too
+	invalid := []byte("bundle_version: 2\ninvalid: true\n")
+	srv.set(invalid, ed25519.Sign(priv, invalid))
+	if _, err := f.Fetch(ctx); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	cached, err := f.Cached()
+	if err != nil || string(cached) != string(v1) {
+		t.Errorf("Cached() = %q, %v; want v1", cached, err)
+	}
+
+	// Tampering with the cache is detected
+	if err := os.WriteFile(filepath.Join(f.cfg.CacheDir, bundleFile), []byte("rules: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with cache: %v", err)
+	}
+	if _, err := f.Cached(); err == nil {
+		t.Error("Expected error for tampered cache")
+	}
+}
+
+func TestFetchRefusesRollback(t *testing.T) {
+	pub, priv := generateKey(t)
+	srv := &bundleServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	f := newTestFetcherIn(t, dir, ts.URL, pub, nil)
+	ctx := context.Background()
+
+	unversioned := []byte("rules:\n  - id: v0\n")
+	srv.set(unversioned, ed25519.Sign(priv, unversioned))
+	if _, err := f.Fetch(ctx); err == nil || !strings.Contains(err.Error(), "no bundle_version") {
+		t.Errorf("Expected missing bundle_version error, got %v", err)
+	}
+
+	v1 := []byte("bundle_version: 1\nrules:\n  - id: v1\n")
+	v2 := []byte("bundle_version: 2\nrules:\n  - id: v2\n")
+	srv.set(v2, ed25519.Sign(priv, v2))
+	if _, err := f.Fetch(ctx); err != nil {
+		t.Fatalf("Failed to fetch v2: %v", err)
+	}
+
+	// A validly signed but older bundle is refused, also after a restart
+	srv.set(v1, ed25519.Sign(priv, v1))
+	for _, fetcher := range []*Fetcher{f, newTestFetcherIn(t, dir, ts.URL, pub, nil)} {
+		if _, err := fetcher.Fetch(ctx); err == nil || !strings.Contains(err.Error(), "refusing to roll back") {
+			t.Errorf("Expected rollback error, got %v", err)
+		}
+	}
+	cached, err := f.Cached()
+	if err != nil || string(cached) != string(v2) {
+		t.Errorf("Cached() = %q, %v; want v2", cached, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	pub, priv := generateKey(t)
+	srv := &bundleServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	v1 := []byte("bundle_version: 1\nrules:\n  - id: v1\n")
+	srv.set(v1, ed25519.Sign(priv, v1))
+	f := newTestFetcher(t, ts.URL, pub, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	receive := func() []byte {
+		select {
+		case b := <-f.Updates():
+			return b
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for update")
+			return nil
+		}
+	}
+
+	if got := receive(); string(got) != string(v1) {
+		t.Errorf("First update = %q, want v1", got)
+	}
+
+	v2 := []byte("bundle_version: 2\nrules:\n  - id: v2\n")
+	srv.set(v2, ed25519.Sign(priv, v2))
+	f.Trigger()
+	if got := receive(); string(got) != string(v2) {
+		t.Errorf("Triggered update = %q, want v2", got)
+	}
+	if err := f.Health(); err != nil {
+		t.Errorf("Health() = %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	pub, priv := generateKey(t)
+	srv := &bundleServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	f := newTestFetcher(t, ts.URL, pub, nil)
+	ctx := context.Background()
+
+	if err := f.Rollback(); err == nil {
+		t.Error("Expected error when there is no previous bundle")
+	}
+
+	v1 := []byte("bundle_version: 1\nrules:\n  - id: v1\n")
+	v2 := []byte("bundle_version: 2\nrules:\n  - id: v2\n")
+	for _, bundle := range [][]byte{v1, v2} {
+		srv.set(bundle, ed25519.Sign(priv, bundle))
+		if _, err := f.Fetch(ctx); err != nil {
+			t.Fatalf("Failed to fetch bundle: %v", err)
+		}
+	}
+
+	if err := f.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	// The rolled-back bundle is not delivered again while it is unchanged
+	if got, err := f.Fetch(ctx); err != nil || got != nil {
+		t.Errorf("Fetch() after rollback = %q, %v; want nil, nil", got, err)
+	}
+
+	cached, err := f.Cached()
+	if err != nil || string(cached) != string(v1) {
+		t.Errorf("Cached() after rollback = %q, %v; want v1", cached, err)
+	}
+
+	// After a restart, neither the rolled-back bundle nor another bundle
+	// reusing its version is installed
+	restarted := newTestFetcherIn(t, f.cfg.CacheDir, ts.URL, pub, nil)
+	if _, err := restarted.Cached(); err != nil {
+		t.Fatalf("Cached() after restart error = %v", err)
+	}
+	v2b := []byte("bundle_version: 2\nrules:\n  - id: v2b\n")
+	for _, bundle := range [][]byte{v2, v2b} {
+		srv.set(bundle, ed25519.Sign(priv, bundle))
+		if _, err := restarted.Fetch(ctx); err == nil || !strings.Contains(err.Error(), "refusing to roll back") {
+			t.Errorf("Fetch() of %q after rollback error = %v, want rollback error", bundle, err)
+		}
+	}
+
+	v3 := []byte("bundle_version: 3\nrules:\n  - id: v3\n")
+	srv.set(v3, ed25519.Sign(priv, v3))
+	if got, err := restarted.Fetch(ctx); err != nil || string(got) != string(v3) {
+		t.Errorf("Fetch() of fix = %q, %v; want v3", got, err)
+	}
+}
@@ -0,0 +1,114 @@
+package rulesync
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Minisign signature algorithms. Only the legacy (non-prehashed) variant can
+// be verified with the standard library; prehashed signatures need BLAKE2b.
+const (
+	minisignLegacy    = "Ed"
+	minisignPrehashed = "ED"
+)
+
+// PublicKey verifies detached bundle signatures. It is either a raw Ed25519
+// key or a minisign key, in which case the signature key ID must match.
+type PublicKey struct {
+	key   ed25519.PublicKey
+	keyID []byte
+}
+
+// ParsePublicKey accepts a base64 raw Ed25519 public key (32 bytes) or a
+// minisign public key, either the base64 line alone or the full .pub file.
+func ParsePublicKey(s string) (*PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if lines := strings.Split(s, "\n"); len(lines) > 1 && strings.HasPrefix(lines[0], "untrusted comment:") {
+		s = strings.TrimSpace(lines[1])
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.PublicKeySize:
+		return &PublicKey{key: ed25519.PublicKey(raw)}, nil
+	case 2 + 8 + ed25519.PublicKeySize:
+		if string(raw[:2]) != minisignLegacy {
+			return nil, fmt.Errorf("unsupported minisign key algorithm %q", raw[:2])
+		}
+		return &PublicKey{key: ed25519.PublicKey(raw[10:]), keyID: raw[2:10]}, nil
+	default:
+		return nil, fmt.Errorf("invalid public key length %d", len(raw))
+	}
+}
+
+// Verify checks sig against data. Raw Ed25519 signatures may be binary or
+// base64; minisign signatures must be created with `minisign -S -l`.
+func (k *PublicKey) Verify(data, sig []byte) error {
+	if text := bytes.TrimSpace(sig); bytes.HasPrefix(text, []byte("untrusted comment:")) {
+		return k.verifyMinisign(data, string(text))
+	}
+	if k.keyID != nil {
+		return errors.New("expected a minisign signature for a minisign public key")
+	}
+
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return errors.New("signature is neither a raw nor a base64 Ed25519 signature")
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(k.key, data, sig) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// verifyMinisign checks a minisign signature file: the signature over the
+// data and the global signature over the signature and trusted comment.
+func (k *PublicKey) verifyMinisign(data []byte, text string) error {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return errors.New("truncated minisign signature")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return errors.New("invalid minisign signature encoding")
+	}
+	switch string(raw[:2]) {
+	case minisignLegacy:
+	case minisignPrehashed:
+		return errors.New("prehashed minisign signatures are not supported (sign with -l)")
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", raw[:2])
+	}
+	if k.keyID != nil && !bytes.Equal(raw[2:10], k.keyID) {
+		return errors.New("minisign signature was made with a different key")
+	}
+	sig := raw[10:]
+	if !ed25519.Verify(k.key, data, sig) {
+		return errors.New("signature verification failed")
+	}
+
+	trusted, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return errors.New("missing minisign trusted comment")
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("invalid minisign global signature")
+	}
+	if !ed25519.Verify(k.key, append(append([]byte{}, sig...), trusted...), global) {
+		return errors.New("minisign trusted comment verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package ruletest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"gopkg.in/yaml.v3"
+
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+// Evaluator is the part of the rules engine fixtures are run against
+type Evaluator interface {
+	Evaluate(msg *santapb.SantaMessage) ([]*rules.Match, error)
+	EvalErrors() int64
+}
+
+// DecodeFunc reads the Santa events in a fixture file
+type DecodeFunc func(path string) ([]*santapb.SantaMessage, error)
+
+// Suite is a set of expected-match assertions loaded from YAML
+type Suite struct {
+	Tests []Case `yaml:"tests"`
+
+	dir string // Fixture paths are relative to the suite file
+}
+
+// Case asserts which rules fire for a fixture file or directory.
+// A case with neither expect nor expect_not asserts that no rule fires.
+type Case struct {
+	Name      string   `yaml:"name"`
+	Events    string   `yaml:"events"`
+	Expect    []string `yaml:"expect"`
+	ExpectNot []string `yaml:"expect_not"`
+}
+
+// Fixture is the outcome of evaluating the events in a fixture
+type Fixture struct {
+	Path       string
+	Events     int
+	EvalErrors int64
+	Fired      map[string]int // Rule ID -> number of matching events
+}
+
+// RuleIDs returns the IDs of the rules that fired, sorted
+func (f *Fixture) RuleIDs() []string {
+	ids := make([]string, 0, len(f.Fired))
+	for id := range f.Fired {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Result is the outcome of a single test case
+type Result struct {
+	Case     Case
+	Fixture  *Fixture
+	Failures []string
+	Err      error
+}
+
+// Passed reports whether the case ran and all assertions held
+func (r *Result) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// LoadSuite reads and validates a test suite file
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test suite: %w", err)
+	}
+
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse test suite: %w", err)
+	}
+	if len(suite.Tests) == 0 {
+		return nil, fmt.Errorf("test suite %s has no tests", path)
+	}
+
+	names := make(map[string]bool, len(suite.Tests))
+	for i, tc := range suite.Tests {
+		if tc.Name == "" {
+			return nil, fmt.Errorf("test %d: name is required", i+1)
+		}
+		if names[tc.Name] {
+			return nil, fmt.Errorf("duplicate test name: %s", tc.Name)
+		}
+		names[tc.Name] = true
+		if tc.Events == "" {
+			return nil, fmt.Errorf("test %s: events is required", tc.Name)
+		}
+		for _, id := range tc.Expect {
+			for _, notID := range tc.ExpectNot {
+				if id == notID {
+					return nil, fmt.Errorf("test %s: rule %s is in both expect and expect_not", tc.Name, id)
+				}
+			}
+		}
+	}
+
+	suite.dir = filepath.Dir(path)
+	return &suite, nil
+}
+
+// Run evaluates every case against the engine
+func (s *Suite) Run(engine Evaluator, decode DecodeFunc) []*Result {
+	results := make([]*Result, 0, len(s.Tests))
+	for _, tc := range s.Tests {
+		result := &Result{Case: tc}
+		results = append(results, result)
+
+		path := tc.Events
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.dir, path)
+		}
+		fixture, err := Evaluate(engine, decode, path)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		result.Fixture = fixture
+		result.Failures = tc.check(fixture)
+	}
+	return results
+}
+
+// check returns a description of every assertion the fixture violates
+func (c Case) check(f *Fixture) []string {
+	var failures []string
+	if f.Events == 0 {
+		failures = append(failures, "fixture contains no events")
+	}
+	if f.EvalErrors > 0 {
+		failures = append(failures, fmt.Sprintf("%d rule evaluation errors", f.EvalErrors))
+	}
+
+	for _, id := range c.Expect {
+		if f.Fired[id] == 0 {
+			failures = append(failures, fmt.Sprintf("expected %s to fire", id))
+		}
+	}
+	for _, id := range c.ExpectNot {
+		if n := f.Fired[id]; n > 0 {
+			failures = append(failures, fmt.Sprintf("expected %s not to fire, matched %d events", id, n))
+		}
+	}
+	if len(c.Expect) == 0 && len(c.ExpectNot) == 0 && len(f.Fired) > 0 {
+		failures = append(failures, fmt.Sprintf("expected no rules to fire, got %s", strings.Join(f.RuleIDs(), ", ")))
+	}
+	return failures
+}
+
+// Evaluate runs every event in a fixture file, or in each file of a
+// fixture directory, through the engine's simple rules
+func Evaluate(engine Evaluator, decode DecodeFunc, path string) (*Fixture, error) {
+	files := []string{path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	if info.IsDir() {
+		if files, err = Files(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fixture := &Fixture{Path: path, Fired: make(map[string]int)}
+	errorsBefore := engine.EvalErrors()
+	for _, file := range files {
+		msgs, err := decode(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		for _, msg := range msgs {
+			matches, err := engine.Evaluate(msg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate %s: %w", file, err)
+			}
+			for _, m := range matches {
+				fixture.Fired[m.RuleID]++
+			}
+		}
+		fixture.Events += len(msgs)
+	}
+	fixture.EvalErrors = engine.EvalErrors() - errorsBefore
+	return fixture, nil
+}
+
+// Files lists the fixture files in dir, sorted. Hidden files and YAML files
+// (test suites) are skipped.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
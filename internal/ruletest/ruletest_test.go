@@ -0,0 +1,135 @@
+package ruletest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+// fakeEngine fires a rule named after the executed path's base name
+type fakeEngine struct {
+	errors int64
+}
+
+func (e *fakeEngine) Evaluate(msg *santapb.SantaMessage) ([]*rules.Match, error) {
+	path := msg.GetExecution().GetTarget().GetExecutable().GetPath()
+	if path == "" {
+		e.errors++
+		return nil, nil
+	}
+	return []*rules.Match{{RuleID: filepath.Base(path)}}, nil
+}
+
+func (e *fakeEngine) EvalErrors() int64 {
+	return e.errors
+}
+
+// fakeDecode treats each line of a fixture as an executed path
+func fakeDecode(path string) ([]*santapb.SantaMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []*santapb.SantaMessage
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		msgs = append(msgs, &santapb.SantaMessage{
+			Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(line)}},
+			}},
+		})
+	}
+	return msgs, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestSuiteRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "curl.jsonl"), "/usr/bin/curl\n/usr/bin/curl\n")
+	writeFile(t, filepath.Join(dir, "shells", "a.jsonl"), "/bin/sh\n")
+	writeFile(t, filepath.Join(dir, "shells", "b.jsonl"), "/bin/zsh\n")
+	writeFile(t, filepath.Join(dir, "shells", "tests.yaml"), "ignored: true\n")
+	writeFile(t, filepath.Join(dir, "broken.jsonl"), "\n")
+	writeFile(t, filepath.Join(dir, "tests.yaml"), `
+tests:
+  - name: curl fires
+    events: curl.jsonl
+    expect: [curl]
+    expect_not: [sh]
+  - name: shells directory
+    events: shells
+    expect: [sh, zsh, bash]
+  - name: quiet
+    events: curl.jsonl
+  - name: eval errors
+    events: broken.jsonl
+  - name: missing fixture
+    events: missing.jsonl
+    expect: [curl]
+`)
+
+	suite, err := LoadSuite(filepath.Join(dir, "tests.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load suite: %v", err)
+	}
+	results := suite.Run(&fakeEngine{}, fakeDecode)
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+
+	if r := results[0]; !r.Passed() || r.Fixture.Fired["curl"] != 2 || r.Fixture.Events != 2 {
+		t.Errorf("curl case = %+v, fixture %+v", r, r.Fixture)
+	}
+	if r := results[1]; r.Passed() || len(r.Failures) != 1 || !strings.Contains(r.Failures[0], "bash") {
+		t.Errorf("Expected only bash to be missing, got %v", r.Failures)
+	} else if r.Fixture.Events != 2 {
+		t.Errorf("Expected tests.yaml in fixture dir to be skipped, got %d events", r.Fixture.Events)
+	}
+	if r := results[2]; r.Passed() || !strings.Contains(r.Failures[0], "no rules to fire") {
+		t.Errorf("Expected no-match case to fail, got %v", r.Failures)
+	}
+	if r := results[3]; r.Passed() || !strings.Contains(strings.Join(r.Failures, ";"), "evaluation errors") {
+		t.Errorf("Expected evaluation errors to fail the case, got %v", r.Failures)
+	}
+	if r := results[4]; r.Passed() || r.Err == nil {
+		t.Errorf("Expected missing fixture to error, got %+v", r)
+	}
+}
+
+func TestLoadSuiteInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", "tests: []\n", "no tests"},
+		{"missing name", "tests:\n  - events: a.jsonl\n", "name is required"},
+		{"missing events", "tests:\n  - name: a\n", "events is required"},
+		{"duplicate", "tests:\n  - {name: a, events: a}\n  - {name: a, events: b}\n", "duplicate"},
+		{"conflict", "tests:\n  - {name: a, events: a, expect: [R1], expect_not: [R1]}\n", "both expect and expect_not"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tests.yaml")
+			writeFile(t, path, tt.content)
+			if _, err := LoadSuite(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadSuite() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package santalog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Parse converts one line of Santa's event log file, e.g.
+//
+//	[2025-01-15T18:01:02.123Z] I santad: action=EXEC|decision=ALLOW|...|path=/usr/bin/curl
+//
+// into a SantaMessage. Lines that are not events, and actions with no
+// telemetry counterpart, return nil and no error.
+func Parse(line string) (*santapb.SantaMessage, error) {
+	line = strings.TrimSpace(line)
+	var ts time.Time
+	if strings.HasPrefix(line, "[") {
+		if end := strings.IndexByte(line, ']'); end > 0 {
+			ts, _ = time.Parse(time.RFC3339Nano, line[1:end])
+		}
+	}
+	return ParseMessage(line, ts)
+}
+
+// ParseMessage converts the key=value event text Santa logs (to its file or
+// the unified log) into a SantaMessage stamped with ts (now when zero).
+// Fields the text log does not carry, such as environment variables, are
+// left unset.
+func ParseMessage(text string, ts time.Time) (*santapb.SantaMessage, error) {
+	i := strings.Index(text, "action=")
+	if i < 0 {
+		return nil, nil
+	}
+	f := parseFields(text[i:])
+
+	var msg *santapb.SantaMessage
+	switch f.values["action"] {
+	case "EXEC":
+		msg = f.execution()
+	case "FORK":
+		msg = f.fork()
+	case "EXIT":
+		msg = &santapb.SantaMessage{Event: &santapb.SantaMessage_Exit{Exit: &santapb.Exit{Instigator: f.light()}}}
+	case "WRITE":
+		msg = &santapb.SantaMessage{Event: &santapb.SantaMessage_Close{Close: &santapb.Close{
+			Instigator: f.light(),
+			Target:     f.fileInfo("path", ""),
+			Modified:   proto.Bool(true),
+		}}}
+	case "RENAME":
+		msg = &santapb.SantaMessage{Event: &santapb.SantaMessage_Rename{Rename: &santapb.Rename{
+			Instigator: f.light(),
+			Source:     f.fileInfo("path", ""),
+			Target:     f.str("newpath"),
+		}}}
+	case "DELETE":
+		msg = &santapb.SantaMessage{Event: &santapb.SantaMessage_Unlink{Unlink: &santapb.Unlink{
+			Instigator: f.light(),
+			Target:     f.fileInfo("path", ""),
+		}}}
+	case "LINK":
+		msg = &santapb.SantaMessage{Event: &santapb.SantaMessage_Link{Link: &santapb.Link{
+			Instigator: f.light(),
+			Source:     f.fileInfo("path", ""),
+			Target:     f.str("newpath"),
+		}}}
+	case "DISKAPPEAR", "DISKDISAPPEAR":
+		msg = f.disk()
+	case "FILE_ACCESS":
+		msg = f.fileAccess()
+	default:
+		return nil, nil
+	}
+	if f.err != nil {
+		return nil, fmt.Errorf("%s event: %w", f.values["action"], f.err)
+	}
+
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	msg.EventTime = timestamppb.New(ts)
+	msg.MachineId = f.str("machineid")
+	return msg, nil
+}
+
+// fields holds the key=value pairs of one event and the first malformed value
+type fields struct {
+	values map[string]string
+	err    error
+}
+
+// parseFields splits Santa's pipe-separated pairs. Santa escapes pipes in
+// values as <pipe>; a segment without '=' is still treated as part of the
+// previous value.
+func parseFields(text string) *fields {
+	f := &fields{values: make(map[string]string)}
+	last := ""
+	for _, part := range strings.Split(text, "|") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || strings.ContainsAny(key, " /") {
+			if last != "" {
+				f.values[last] += "|" + part
+			}
+			continue
+		}
+		f.values[key] = value
+		last = key
+	}
+	for key, value := range f.values {
+		f.values[key] = strings.ReplaceAll(value, "<pipe>", "|")
+	}
+	return f
+}
+
+func (f *fields) str(key string) *string {
+	if v := f.values[key]; v != "" {
+		return proto.String(v)
+	}
+	return nil
+}
+
+func (f *fields) int32(key string) *int32 {
+	v := f.values[key]
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		if f.err == nil {
+			f.err = fmt.Errorf("invalid %s %q", key, v)
+		}
+		return nil
+	}
+	return proto.Int32(int32(n))
+}
+
+// processID returns the process identified by pidKey and versionKey, or nil
+func (f *fields) processID(pidKey, versionKey string) *santapb.ProcessID {
+	pid := f.int32(pidKey)
+	if pid == nil {
+		return nil
+	}
+	return &santapb.ProcessID{Pid: pid, Pidversion: f.int32(versionKey)}
+}
+
+func (f *fields) user() *santapb.UserInfo {
+	uid, name := f.int32("uid"), f.str("user")
+	if uid == nil && name == nil {
+		return nil
+	}
+	return &santapb.UserInfo{Uid: uid, Name: name}
+}
+
+func (f *fields) group() *santapb.GroupInfo {
+	gid, name := f.int32("gid"), f.str("group")
+	if gid == nil && name == nil {
+		return nil
+	}
+	return &santapb.GroupInfo{Gid: gid, Name: name}
+}
+
+// fileInfo returns the file at pathKey with the SHA-256 at hashKey, or nil
+func (f *fields) fileInfo(pathKey, hashKey string) *santapb.FileInfo {
+	path := f.str(pathKey)
+	if path == nil {
+		return nil
+	}
+	file := &santapb.FileInfo{Path: path}
+	if sum := f.str(hashKey); sum != nil {
+		algo := santapb.Hash_HASH_ALGO_SHA256
+		file.Hash = &santapb.Hash{Type: &algo, Hash: sum}
+	}
+	return file
+}
+
+// light returns the process acting in a file or lifecycle event
+func (f *fields) light() *santapb.ProcessInfoLight {
+	u, g := f.user(), f.group()
+	p := &santapb.ProcessInfoLight{
+		Id:             f.processID("pid", "pidversion"),
+		ParentId:       f.processID("ppid", ""),
+		EffectiveUser:  u,
+		EffectiveGroup: g,
+		RealUser:       u,
+		RealGroup:      g,
+	}
+	if path := f.str("processpath"); path != nil {
+		p.Executable = &santapb.FileInfoLight{Path: path}
+	}
+	return p
+}
+
+// process returns the full process whose executable is at pathKey
+func (f *fields) process(pathKey, hashKey string) *santapb.ProcessInfo {
+	u, g := f.user(), f.group()
+	p := &santapb.ProcessInfo{
+		Id:             f.processID("pid", "pidversion"),
+		ParentId:       f.processID("ppid", ""),
+		EffectiveUser:  u,
+		EffectiveGroup: g,
+		RealUser:       u,
+		RealGroup:      g,
+		Executable:     f.fileInfo(pathKey, hashKey),
+	}
+	signingID, teamID := f.str("signingid"), f.str("teamid")
+	cdhash, _ := hex.DecodeString(f.values["cdhash"])
+	if signingID != nil || teamID != nil || len(cdhash) > 0 {
+		p.CodeSignature = &santapb.CodeSignature{Cdhash: cdhash, SigningId: signingID, TeamId: teamID}
+	}
+	return p
+}
+
+// reasonNames maps the reasons Santa logs to telemetry enum names where they differ
+var reasonNames = map[string]string{
+	"TEAMID":    "TEAM_ID",
+	"SIGNINGID": "SIGNING_ID",
+}
+
+func (f *fields) execution() *santapb.SantaMessage {
+	target := f.process("path", "sha256")
+	exec := &santapb.Execution{
+		Instigator:    &santapb.ProcessInfoLight{Id: target.GetParentId()},
+		Target:        target,
+		Explain:       f.str("explain"),
+		QuarantineUrl: f.str("quarantine_url"),
+	}
+	if v, ok := santapb.Execution_Decision_value["DECISION_"+f.values["decision"]]; ok {
+		exec.Decision = santapb.Execution_Decision(v).Enum()
+	}
+	reason := f.values["reason"]
+	if name, ok := reasonNames[reason]; ok {
+		reason = name
+	}
+	if v, ok := santapb.Execution_Reason_value["REASON_"+reason]; ok {
+		exec.Reason = santapb.Execution_Reason(v).Enum()
+	}
+	switch f.values["mode"] {
+	case "L":
+		exec.Mode = santapb.Execution_MODE_LOCKDOWN.Enum()
+	case "M":
+		exec.Mode = santapb.Execution_MODE_MONITOR.Enum()
+	}
+	// The text log joins arguments with spaces, so quoting is lost
+	for _, arg := range strings.Fields(f.values["args"]) {
+		exec.Args = append(exec.Args, []byte(arg))
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: exec}}
+}
+
+func (f *fields) fork() *santapb.SantaMessage {
+	child := f.light()
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{
+		Instigator: &santapb.ProcessInfoLight{Id: child.GetParentId()},
+		Child:      child,
+	}}}
+}
+
+func (f *fields) disk() *santapb.SantaMessage {
+	action := santapb.Disk_ACTION_APPEARED
+	if f.values["action"] == "DISKDISAPPEAR" {
+		action = santapb.Disk_ACTION_DISAPPEARED
+	}
+	disk := &santapb.Disk{
+		Action:  &action,
+		Mount:   f.str("mount"),
+		Volume:  f.str("volume"),
+		BsdName: f.str("bsdname"),
+		Fs:      f.str("fs"),
+		Model:   f.str("model"),
+		Serial:  f.str("serial"),
+		Bus:     f.str("bus"),
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f.values["appearance"]); err == nil {
+		disk.Appearance = timestamppb.New(t)
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Disk{Disk: disk}}
+}
+
+func (f *fields) fileAccess() *santapb.SantaMessage {
+	access := &santapb.FileAccess{
+		Instigator:    f.process("processpath", ""),
+		PolicyVersion: f.str("policy_version"),
+		PolicyName:    f.str("policy_name"),
+	}
+	if path := f.str("path"); path != nil {
+		access.Target = &santapb.FileInfoLight{Path: path}
+	}
+	if v, ok := santapb.FileAccess_AccessType_value["ACCESS_TYPE_"+f.values["access_type"]]; ok {
+		access.AccessType = santapb.FileAccess_AccessType(v).Enum()
+	}
+	if v, ok := santapb.FileAccess_PolicyDecision_value["POLICY_DECISION_"+f.values["decision"]]; ok {
+		access.PolicyDecision = santapb.FileAccess_PolicyDecision(v).Enum()
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_FileAccess{FileAccess: access}}
+}
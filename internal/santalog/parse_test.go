@@ -0,0 +1,137 @@
+package santalog
+
+import (
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+)
+
+func TestParseExecution(t *testing.T) {
+	line := "[2025-01-15T18:01:02.123Z] I santad: action=EXEC|decision=DENY|reason=TEAMID|explain=blocked team" +
+		"|sha256=4f2a9c|teamid=EQHXZ8M8AV|signingid=EQHXZ8M8AV:com.google.Chrome|cdhash=a1b2" +
+		"|pid=812|pidversion=3301|ppid=1|uid=501|user=alice|gid=20|group=staff|mode=L" +
+		"|path=/Applications/Google Chrome.app/Contents/MacOS/Google Chrome|args=chrome --flag a<pipe>b|machineid=MACHINE-1\n"
+
+	msg, err := Parse(line)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	exec := msg.GetExecution()
+	if exec == nil {
+		t.Fatalf("Expected an execution, got %v", msg)
+	}
+	if exec.GetDecision() != santapb.Execution_DECISION_DENY || exec.GetReason() != santapb.Execution_REASON_TEAM_ID {
+		t.Errorf("decision/reason = %v/%v", exec.GetDecision(), exec.GetReason())
+	}
+	if exec.GetMode() != santapb.Execution_MODE_LOCKDOWN {
+		t.Errorf("mode = %v", exec.GetMode())
+	}
+	target := exec.GetTarget()
+	if target.GetExecutable().GetPath() != "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome" {
+		t.Errorf("path = %q", target.GetExecutable().GetPath())
+	}
+	if target.GetExecutable().GetHash().GetHash() != "4f2a9c" {
+		t.Errorf("sha256 = %q", target.GetExecutable().GetHash().GetHash())
+	}
+	if target.GetId().GetPid() != 812 || target.GetId().GetPidversion() != 3301 || target.GetParentId().GetPid() != 1 {
+		t.Errorf("ids = %v / %v", target.GetId(), target.GetParentId())
+	}
+	if target.GetEffectiveUser().GetName() != "alice" || target.GetEffectiveGroup().GetGid() != 20 {
+		t.Errorf("user/group = %v / %v", target.GetEffectiveUser(), target.GetEffectiveGroup())
+	}
+	if target.GetCodeSignature().GetTeamId() != "EQHXZ8M8AV" || len(target.GetCodeSignature().GetCdhash()) != 2 {
+		t.Errorf("code signature = %v", target.GetCodeSignature())
+	}
+	if exec.GetInstigator().GetId().GetPid() != 1 {
+		t.Errorf("instigator = %v", exec.GetInstigator())
+	}
+	if len(exec.GetArgs()) != 3 || string(exec.GetArgs()[2]) != "a|b" {
+		t.Errorf("args = %q", exec.GetArgs())
+	}
+	if msg.GetMachineId() != "MACHINE-1" {
+		t.Errorf("machine id = %q", msg.GetMachineId())
+	}
+	if want := time.Date(2025, 1, 15, 18, 1, 2, 123e6, time.UTC); !msg.GetEventTime().AsTime().Equal(want) {
+		t.Errorf("event time = %v, want %v", msg.GetEventTime().AsTime(), want)
+	}
+}
+
+func TestParseFileEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		check func(*santapb.SantaMessage) bool
+	}{
+		{
+			name: "write",
+			line: "action=WRITE|path=/Users/alice/notes.txt|pid=90|ppid=1|process=vim|processpath=/usr/bin/vim|uid=501|user=alice|gid=20|group=staff",
+			check: func(m *santapb.SantaMessage) bool {
+				c := m.GetClose()
+				return c.GetTarget().GetPath() == "/Users/alice/notes.txt" && c.GetModified() &&
+					c.GetInstigator().GetExecutable().GetPath() == "/usr/bin/vim"
+			},
+		},
+		{
+			name: "rename",
+			line: "action=RENAME|path=/tmp/a|newpath=/tmp/b|pid=90|ppid=1|processpath=/bin/mv|uid=0|user=root|gid=0|group=wheel",
+			check: func(m *santapb.SantaMessage) bool {
+				r := m.GetRename()
+				return r.GetSource().GetPath() == "/tmp/a" && r.GetTarget() == "/tmp/b"
+			},
+		},
+		{
+			name: "delete",
+			line: "action=DELETE|path=/tmp/a|pid=90|ppid=1|processpath=/bin/rm|uid=0|user=root|gid=0|group=wheel",
+			check: func(m *santapb.SantaMessage) bool {
+				return m.GetUnlink().GetTarget().GetPath() == "/tmp/a"
+			},
+		},
+		{
+			name: "disk",
+			line: "action=DISKAPPEAR|mount=/Volumes/USB|volume=USB|bsdname=disk4s1|fs=msdos|model=Flash Drive|serial=123|bus=USB|appearance=2025-01-15T18:01:02.000Z",
+			check: func(m *santapb.SantaMessage) bool {
+				d := m.GetDisk()
+				return d.GetAction() == santapb.Disk_ACTION_APPEARED && d.GetMount() == "/Volumes/USB" && d.GetAppearance() != nil
+			},
+		},
+		{
+			name: "file access",
+			line: "action=FILE_ACCESS|policy_version=v1|policy_name=SSHKeys|path=/Users/alice/.ssh/id_ed25519|access_type=OPEN|decision=DENIED|pid=90|pidversion=2|ppid=1|process=cat|processpath=/bin/cat|uid=501|user=alice|gid=20|group=staff",
+			check: func(m *santapb.SantaMessage) bool {
+				a := m.GetFileAccess()
+				return a.GetPolicyName() == "SSHKeys" && a.GetTarget().GetPath() == "/Users/alice/.ssh/id_ed25519" &&
+					a.GetPolicyDecision() == santapb.FileAccess_POLICY_DECISION_DENIED &&
+					a.GetInstigator().GetExecutable().GetPath() == "/bin/cat"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if msg == nil || !tt.check(msg) {
+				t.Errorf("Unexpected message: %v", msg)
+			}
+		})
+	}
+}
+
+func TestParseSkipsAndErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"[2025-01-15T18:01:02.123Z] I santad: Connected to sync server",
+		"[2025-01-15T18:01:02.123Z] I santad: action=ALLOWLIST|pid=1|path=/tmp/x",
+	} {
+		if msg, err := Parse(line); msg != nil || err != nil {
+			t.Errorf("Parse(%q) = %v, %v; want nothing", line, msg, err)
+		}
+	}
+
+	if _, err := Parse("action=FORK|pid=abc|ppid=1"); err == nil {
+		t.Error("Expected an error for a malformed pid")
+	}
+}
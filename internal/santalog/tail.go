@@ -0,0 +1,342 @@
+package santalog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/logutil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var logger = logutil.For("santalog")
+
+// OffsetStore persists how far the event log file has been read
+type OffsetStore interface {
+	GetMeta(key string) (string, error)
+	SetMeta(key, value string) error
+}
+
+// Options configures a Tailer
+type Options struct {
+	Source        string        // file (default) or unified
+	Path          string        // Santa's event log file, for the file source
+	Predicate     string        // log stream predicate, for the unified source
+	SpoolDir      string        // Spool the converted events are written to
+	BatchInterval time.Duration // How often events are written to the spool
+	BatchSize     int           // Events per spool file
+	Offsets       OffsetStore   // Resume the file where the last run stopped (optional)
+}
+
+// Tailer follows Santa's text event log and writes the events it parses as
+// LogBatch files into a spool directory, where the spool watcher picks them
+// up like files Santa exported itself
+type Tailer struct {
+	opts    Options
+	pending []*santapb.SantaMessage
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewTailer creates a Tailer
+func NewTailer(opts Options) *Tailer {
+	if opts.Source == "" {
+		opts.Source = "file"
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = 2 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	return &Tailer{opts: opts}
+}
+
+// Start follows the log until ctx is done, spooling what is pending on the
+// way out
+func (t *Tailer) Start(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Join(t.opts.SpoolDir, "new"), 0755); err != nil {
+		return fmt.Errorf("failed to create event log spool: %w", err)
+	}
+	if t.opts.Source == "unified" {
+		return t.streamUnified(ctx)
+	}
+	return t.tailFile(ctx)
+}
+
+// Health reports why the log cannot currently be read
+func (t *Tailer) Health() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastErr
+}
+
+func (t *Tailer) setErr(err error) {
+	t.mu.Lock()
+	changed := (err == nil) != (t.lastErr == nil)
+	t.lastErr = err
+	t.mu.Unlock()
+	if changed && err != nil {
+		logger.Warn("Santa event log: %v", err)
+	} else if changed {
+		logger.Info("Santa event log: reading %s", t.describe())
+	}
+}
+
+func (t *Tailer) describe() string {
+	if t.opts.Source == "unified" {
+		return "unified log"
+	}
+	return t.opts.Path
+}
+
+// add queues a parsed event; lines that failed to parse are logged and skipped
+func (t *Tailer) add(msg *santapb.SantaMessage, err error) {
+	if err != nil {
+		logger.Warn("Skipping Santa event log line: %v", err)
+		return
+	}
+	if msg != nil {
+		t.pending = append(t.pending, msg)
+	}
+}
+
+// offsetKey is the meta key holding the read offset of the event log file
+func (t *Tailer) offsetKey() string {
+	return "santa_log_offset:" + t.opts.Path
+}
+
+func (t *Tailer) loadOffset() int64 {
+	if t.opts.Offsets == nil {
+		return 0
+	}
+	v, err := t.opts.Offsets.GetMeta(t.offsetKey())
+	if err != nil || v == "" {
+		return 0
+	}
+	offset, _ := strconv.ParseInt(v, 10, 64)
+	return offset
+}
+
+// tailFile reads the log file from the saved offset, following it across
+// rotation and truncation. The offset is saved after each flush, so events
+// are spooled at least once.
+func (t *Tailer) tailFile(ctx context.Context) error {
+	offset := t.loadOffset()
+	ticker := time.NewTicker(t.opts.BatchInterval)
+	defer ticker.Stop()
+
+	var f *os.File
+	var r *bufio.Reader
+	var partial string
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	for {
+		if f == nil {
+			file, err := os.Open(t.opts.Path)
+			if err == nil {
+				var info os.FileInfo
+				if info, err = file.Stat(); err == nil && info.Size() < offset {
+					offset = 0 // Rotated or truncated while stopped
+				}
+				if err == nil {
+					_, err = file.Seek(offset, io.SeekStart)
+				}
+				if err != nil {
+					_ = file.Close()
+				}
+			}
+			if err != nil {
+				t.setErr(err)
+			} else {
+				f, r, partial = file, bufio.NewReader(file), ""
+				t.setErr(nil)
+			}
+		}
+
+		if f != nil {
+			for {
+				chunk, err := r.ReadString('\n')
+				partial += chunk
+				if err != nil {
+					break // Wait for the rest of the line
+				}
+				offset += int64(len(partial))
+				t.add(Parse(partial))
+				partial = ""
+				if len(t.pending) >= t.opts.BatchSize {
+					t.flush(offset)
+				}
+			}
+
+			// Reopen once the path is a new file (rotated) or shorter than what was read (truncated)
+			cur, curErr := f.Stat()
+			info, err := os.Stat(t.opts.Path)
+			if err != nil || curErr != nil || !os.SameFile(cur, info) || info.Size() < offset+int64(len(partial)) {
+				_ = f.Close()
+				f = nil
+				t.flush(offset)
+				offset = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			t.flush(offset)
+			return nil
+		case <-ticker.C:
+			t.flush(offset)
+		}
+	}
+}
+
+// unifiedEntry is the part of a `log stream --style ndjson` line santamon uses
+type unifiedEntry struct {
+	EventMessage string `json:"eventMessage"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// unifiedTimeLayout is the timestamp format of `log stream --style ndjson`
+const unifiedTimeLayout = "2006-01-02 15:04:05.999999-0700"
+
+// streamUnified runs `log stream` for Santa's events, restarting it if it exits.
+// The unified log is live only, so nothing logged while santamon is down is read.
+func (t *Tailer) streamUnified(ctx context.Context) error {
+	ticker := time.NewTicker(t.opts.BatchInterval)
+	defer ticker.Stop()
+
+	for {
+		lines := make(chan string)
+		cmd := exec.CommandContext(ctx, "log", "stream", "--style", "ndjson", "--level", "info", "--predicate", t.opts.Predicate)
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			t.setErr(fmt.Errorf("failed to run log stream: %w", err))
+			close(lines)
+		} else {
+			t.setErr(nil)
+			go func() {
+				defer close(lines)
+				scanner := bufio.NewScanner(stdout)
+				scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+				for scanner.Scan() {
+					select {
+					case lines <- scanner.Text():
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		for running := true; running; {
+			select {
+			case <-ctx.Done():
+				t.flush(0)
+				if cmd.Process != nil {
+					_ = cmd.Wait()
+				}
+				return nil
+			case <-ticker.C:
+				t.flush(0)
+			case line, ok := <-lines:
+				if !ok {
+					running = false
+					break
+				}
+				var entry unifiedEntry
+				if json.Unmarshal([]byte(line), &entry) != nil {
+					continue // Header or filter line
+				}
+				ts, _ := time.Parse(unifiedTimeLayout, entry.Timestamp)
+				t.add(ParseMessage(entry.EventMessage, ts))
+				if len(t.pending) >= t.opts.BatchSize {
+					t.flush(0)
+				}
+			}
+		}
+
+		if cmd.Process != nil {
+			if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+				t.setErr(fmt.Errorf("log stream exited: %w", err))
+			}
+		}
+		// Restart after a pause rather than spinning on a broken command
+		select {
+		case <-ctx.Done():
+			t.flush(0)
+			return nil
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// flush spools the pending events and, for the file source, saves offset.
+// Events that cannot be written are kept and retried on the next flush.
+func (t *Tailer) flush(offset int64) {
+	if len(t.pending) > 0 {
+		if err := t.writeBatch(t.pending); err != nil {
+			logger.Error("Failed to spool Santa event log batch: %v", err)
+			return
+		}
+		t.pending = t.pending[:0]
+	}
+	if t.opts.Offsets != nil && t.opts.Source == "file" {
+		if err := t.opts.Offsets.SetMeta(t.offsetKey(), strconv.FormatInt(offset, 10)); err != nil {
+			logger.Warn("Failed to save Santa event log offset: %v", err)
+		}
+	}
+}
+
+// writeBatch writes msgs as one LogBatch file. The file is written in the
+// spool directory and renamed into new/, so the watcher never sees a partial file.
+func (t *Tailer) writeBatch(msgs []*santapb.SantaMessage) error {
+	batch := &santapb.LogBatch{}
+	for _, msg := range msgs {
+		record, err := anypb.New(msg)
+		if err != nil {
+			return fmt.Errorf("failed to wrap event: %w", err)
+		}
+		batch.Records = append(batch.Records, record)
+	}
+	data, err := proto.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log batch: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(t.opts.SpoolDir, ".santa-log-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	path := filepath.Join(t.opts.SpoolDir, "new", fmt.Sprintf("santa-log-%d", time.Now().UnixNano()))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to move spool file into place: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package santalog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+// memOffsets is an in-memory OffsetStore
+type memOffsets struct {
+	mu   sync.Mutex
+	meta map[string]string
+}
+
+func (m *memOffsets) GetMeta(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.meta[key], nil
+}
+
+func (m *memOffsets) SetMeta(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.meta[key] = value
+	return nil
+}
+
+// runTailer runs a file tailer until want events are spooled and returns their pids
+func runTailer(t *testing.T, opts Options, want int) []int32 {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	tailer := NewTailer(opts)
+	go func() { done <- tailer.Start(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start returned %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pids := spooledPids(t, opts.SpoolDir)
+		if len(pids) >= want {
+			return pids
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for %d events, got %v", want, pids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// spooledPids decodes the spool files in dir/new and removes them
+func spooledPids(t *testing.T, dir string) []int32 {
+	t.Helper()
+	paths, _ := filepath.Glob(filepath.Join(dir, "new", "santa-log-*"))
+	var pids []int32
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var batch santapb.LogBatch
+		if err := proto.Unmarshal(data, &batch); err != nil {
+			t.Fatalf("Invalid spool file: %v", err)
+		}
+		for _, record := range batch.GetRecords() {
+			var msg santapb.SantaMessage
+			if err := record.UnmarshalTo(&msg); err != nil {
+				t.Fatalf("Invalid record: %v", err)
+			}
+			pids = append(pids, msg.GetFork().GetChild().GetId().GetPid())
+		}
+		_ = os.Remove(path)
+	}
+	return pids
+}
+
+func TestTailFileResumesFromOffset(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "santa.log")
+	opts := Options{
+		Path:          logPath,
+		SpoolDir:      t.TempDir(),
+		BatchInterval: 20 * time.Millisecond,
+		Offsets:       &memOffsets{meta: make(map[string]string)},
+	}
+	appendLines := func(lines ...string) {
+		f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = f.Close() }()
+		for _, line := range lines {
+			if _, err := f.WriteString(line); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	appendLines(
+		"[2025-01-15T18:01:02.123Z] I santad: action=FORK|pid=10|pidversion=1|ppid=1|uid=0|gid=0\n",
+		"[2025-01-15T18:01:02.124Z] I santad: Connected to sync server\n",
+		"[2025-01-15T18:01:02.125Z] I santad: action=FORK|pid=11|pidversion=1|ppid=1|uid=0|gid=0\n",
+	)
+	if pids := runTailer(t, opts, 2); len(pids) != 2 || pids[0] != 10 || pids[1] != 11 {
+		t.Errorf("First run spooled %v, want [10 11]", pids)
+	}
+
+	// A restart picks up after the last spooled line
+	appendLines("[2025-01-15T18:01:03.000Z] I santad: action=FORK|pid=12|pidversion=1|ppid=1|uid=0|gid=0\n")
+	if pids := runTailer(t, opts, 1); len(pids) != 1 || pids[0] != 12 {
+		t.Errorf("Second run spooled %v, want [12]", pids)
+	}
+
+	// A rotated log is read from the start
+	if err := os.Rename(logPath, logPath+".0"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines("[2025-01-15T18:01:04.000Z] I santad: action=FORK|pid=13|pidversion=1|ppid=1|uid=0|gid=0\n")
+	if pids := runTailer(t, opts, 1); len(pids) != 1 || pids[0] != 13 {
+		t.Errorf("After rotation spooled %v, want [13]", pids)
+	}
+}
@@ -0,0 +1,206 @@
+package santarule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/fsutil"
+	"github.com/0x4d31/santamon/internal/logutil"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+var logger = logutil.For("santarule")
+
+// Santa rule policies and types (see santactl rule --help)
+const (
+	PolicyAllowlist = "ALLOWLIST"
+	PolicyBlocklist = "BLOCKLIST"
+
+	TypeBinary    = "BINARY"
+	TypeSigningID = "SIGNINGID"
+)
+
+// Rule is a Santa rule in the format santactl rule --import reads
+type Rule struct {
+	Identifier string `json:"identifier"`
+	Policy     string `json:"policy"`
+	RuleType   string `json:"rule_type"`
+	CustomMsg  string `json:"custom_msg,omitempty"`
+}
+
+// Context returns the rule as signal context
+func (r *Rule) Context() map[string]any {
+	ctx := map[string]any{
+		"identifier": r.Identifier,
+		"policy":     r.Policy,
+		"rule_type":  r.RuleType,
+	}
+	if r.CustomMsg != "" {
+		ctx["custom_msg"] = r.CustomMsg
+	}
+	return ctx
+}
+
+// Recommend suggests a Santa rule for the target of an execution that is
+// unsigned, ad hoc signed, or signed but seen on this host for the first
+// time. Unsigned and ad hoc signed binaries can only be identified by hash
+// (BINARY); signed ones get a SIGNINGID rule so updates are covered too.
+//
+// The suggested policy blocks the binary, unless Santa already denied it by
+// default (lockdown mode with no matching rule): the rule to consider is
+// then the one allowing it, once triage clears it. Platform binaries and
+// scripts, whose target is the interpreter, get no suggestion.
+func Recommend(msg *santapb.SantaMessage, firstSeen bool, title string) *Rule {
+	exec := msg.GetExecution()
+	target := exec.GetTarget()
+	if target == nil || target.GetIsPlatformBinary() || exec.GetScript() != nil {
+		return nil
+	}
+
+	rule := &Rule{Policy: PolicyBlocklist}
+	if exec.GetDecision() == santapb.Execution_DECISION_DENY && exec.GetReason() == santapb.Execution_REASON_UNKNOWN {
+		rule.Policy = PolicyAllowlist
+	}
+	if rule.Policy == PolicyBlocklist && title != "" {
+		rule.CustomMsg = "Blocked by santamon: " + title
+	}
+
+	cs := target.GetCodeSignature()
+	switch {
+	case cs.GetTeamId() == "":
+		rule.RuleType = TypeBinary
+		rule.Identifier = events.TargetSHA256(msg)
+	case firstSeen && cs.GetSigningId() != "":
+		rule.RuleType = TypeSigningID
+		rule.Identifier = cs.GetTeamId() + ":" + cs.GetSigningId()
+	}
+	if rule.Identifier == "" {
+		return nil
+	}
+	return rule
+}
+
+// Suggester adds suggested Santa rules to signals as santa_rule and, with
+// santa_rules.file set, collects them for santactl rule --import
+type Suggester struct {
+	minSeverity string
+	file        *File
+}
+
+// NewSuggester creates the suggester, or returns nil when santa_rules is
+// disabled
+func NewSuggester(cfg config.SantaRulesConfig) (*Suggester, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	s := &Suggester{minSeverity: cfg.MinSeverity}
+	if cfg.File != "" {
+		f, err := OpenFile(cfg.File, cfg.MaxRules)
+		if err != nil {
+			return nil, err
+		}
+		s.file = f
+	}
+	return s, nil
+}
+
+// Suggest adds a suggested rule for msg to sig, when sig is severe enough and
+// Recommend has one. firstSeen reports whether the target is new to the host.
+// A nil suggester does nothing.
+func (s *Suggester) Suggest(sig *state.Signal, msg *santapb.SantaMessage, firstSeen bool) {
+	if s == nil || !rules.SeverityAtLeast(sig.Severity, s.minSeverity) {
+		return
+	}
+	rule := Recommend(msg, firstSeen, sig.Title)
+	if rule == nil {
+		return
+	}
+	sig.Context["santa_rule"] = rule.Context()
+	if s.file == nil {
+		return
+	}
+	added, err := s.file.Add(*rule)
+	if err != nil {
+		logger.Warn("Failed to record suggested Santa rule: %v", err)
+		return
+	}
+	if added {
+		logger.Verbose("Suggested Santa rule %s %s %s for %s", rule.Policy, rule.RuleType, rule.Identifier, sig.RuleID)
+	}
+}
+
+// File collects suggested rules in a santactl rule --import file. Rules are
+// keyed by type and identifier; the latest suggestion for a key replaces
+// earlier ones, and the oldest rules are dropped past the limit.
+type File struct {
+	path string
+	max  int
+
+	mu    sync.Mutex
+	rules []Rule
+}
+
+// fileFormat is the layout santactl rule --import reads
+type fileFormat struct {
+	Rules []Rule `json:"rules"`
+}
+
+// OpenFile loads the rules already suggested in path, if it exists
+func OpenFile(path string, maxRules int) (*File, error) {
+	f := &File{path: path, max: maxRules}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read santa rules file: %w", err)
+	}
+	var existing fileFormat
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return nil, fmt.Errorf("failed to parse santa rules file %s: %w", path, err)
+	}
+	f.rules = existing.Rules
+	return f, nil
+}
+
+// Add records a suggested rule and rewrites the file. It reports whether the
+// file changed.
+func (f *File) Add(r Rule) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, existing := range f.rules {
+		if existing.RuleType != r.RuleType || existing.Identifier != r.Identifier {
+			continue
+		}
+		if existing == r {
+			return false, nil
+		}
+		f.rules = append(f.rules[:i], f.rules[i+1:]...)
+		break
+	}
+	f.rules = append(f.rules, r)
+	if f.max > 0 && len(f.rules) > f.max {
+		f.rules = f.rules[len(f.rules)-f.max:]
+	}
+	return true, f.writeLocked()
+}
+
+// writeLocked replaces the file atomically, so santactl never reads a
+// partial file. f.mu must be held.
+func (f *File) writeLocked() error {
+	data, err := json.MarshalIndent(fileFormat{Rules: f.rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Readable by admins reviewing the suggestions
+	return fsutil.WriteFileAtomic(f.path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,162 @@
+package santarule
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+const hash = "5f70bf18a086007016e948b04aed3b82103a36bea41755b6cddfaf10ace3c6ef"
+
+func execution(team, signingID string) *santapb.SantaMessage {
+	target := &santapb.ProcessInfo{
+		Executable: &santapb.FileInfo{
+			Path: proto.String("/Users/alice/Downloads/tool"),
+			Hash: &santapb.Hash{Hash: proto.String(hash)},
+		},
+	}
+	if team != "" || signingID != "" {
+		target.CodeSignature = &santapb.CodeSignature{TeamId: proto.String(team), SigningId: proto.String(signingID)}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+		Target:   target,
+		Decision: santapb.Execution_DECISION_ALLOW.Enum(),
+	}}}
+}
+
+func TestRecommend(t *testing.T) {
+	denied := execution("", "")
+	denied.GetExecution().Decision = santapb.Execution_DECISION_DENY.Enum()
+	platform := execution("", "com.apple.ls")
+	platform.GetExecution().GetTarget().IsPlatformBinary = proto.Bool(true)
+	script := execution("", "")
+	script.GetExecution().Script = &santapb.FileInfo{Path: proto.String("/tmp/run.sh")}
+	fork := &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}}}
+
+	tests := []struct {
+		name      string
+		msg       *santapb.SantaMessage
+		firstSeen bool
+		want      *Rule
+	}{
+		{"unsigned", execution("", ""), false,
+			&Rule{Identifier: hash, Policy: PolicyBlocklist, RuleType: TypeBinary, CustomMsg: "Blocked by santamon: Test"}},
+		{"ad hoc signed", execution("", "tool-55554944"), false,
+			&Rule{Identifier: hash, Policy: PolicyBlocklist, RuleType: TypeBinary, CustomMsg: "Blocked by santamon: Test"}},
+		{"denied by default", denied, false,
+			&Rule{Identifier: hash, Policy: PolicyAllowlist, RuleType: TypeBinary}},
+		{"signed, first seen", execution("EQHXZ8M8AV", "com.example.tool"), true,
+			&Rule{Identifier: "EQHXZ8M8AV:com.example.tool", Policy: PolicyBlocklist, RuleType: TypeSigningID, CustomMsg: "Blocked by santamon: Test"}},
+		{"signed, seen before", execution("EQHXZ8M8AV", "com.example.tool"), false, nil},
+		{"platform binary", platform, true, nil},
+		{"script", script, true, nil},
+		{"not an execution", fork, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Recommend(tt.msg, tt.firstSeen, "Test")
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("Recommend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func readRules(t *testing.T, path string) []Rule {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var f fileFormat
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("Rules file is not valid JSON: %v", err)
+	}
+	return f.Rules
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "santa-rules.json")
+	f, err := OpenFile(path, 2)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+
+	a := Rule{Identifier: "a", Policy: PolicyBlocklist, RuleType: TypeBinary}
+	b := Rule{Identifier: "T:b", Policy: PolicyBlocklist, RuleType: TypeSigningID}
+	c := Rule{Identifier: "c", Policy: PolicyAllowlist, RuleType: TypeBinary}
+	for _, r := range []Rule{a, b} {
+		if added, err := f.Add(r); err != nil || !added {
+			t.Fatalf("Add(%s) = %v, %v", r.Identifier, added, err)
+		}
+	}
+	if added, _ := f.Add(a); added {
+		t.Error("Adding the same rule again should not rewrite the file")
+	}
+
+	// A new policy for a known binary replaces its rule; the limit drops the oldest
+	aAllow := a
+	aAllow.Policy = PolicyAllowlist
+	if added, _ := f.Add(aAllow); !added {
+		t.Error("A changed rule should be recorded")
+	}
+	if _, err := f.Add(c); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	got := readRules(t, path)
+	if len(got) != 2 || got[0] != aAllow || got[1] != c {
+		t.Errorf("Rules file = %+v, want [%+v %+v]", got, aAllow, c)
+	}
+
+	reopened, err := OpenFile(path, 2)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if added, _ := reopened.Add(c); added {
+		t.Error("Rules from the existing file should be kept across restarts")
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path, 2); err == nil {
+		t.Error("Expected an error for a corrupt rules file")
+	}
+}
+
+func TestSuggester(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "santa-rules.json")
+	s, err := NewSuggester(config.SantaRulesConfig{Enabled: true, MinSeverity: "high", File: path, MaxRules: 10})
+	if err != nil {
+		t.Fatalf("NewSuggester failed: %v", err)
+	}
+
+	low := &state.Signal{RuleID: "R-1", Severity: "medium", Title: "Unsigned tool", Context: map[string]any{}}
+	s.Suggest(low, execution("", ""), false)
+	if _, ok := low.Context["santa_rule"]; ok {
+		t.Error("Signals below min_severity should get no suggestion")
+	}
+
+	high := &state.Signal{RuleID: "R-1", Severity: "critical", Title: "Unsigned tool", Context: map[string]any{}}
+	s.Suggest(high, execution("", ""), false)
+	rule, ok := high.Context["santa_rule"].(map[string]any)
+	if !ok || rule["identifier"] != hash || rule["policy"] != PolicyBlocklist || rule["rule_type"] != TypeBinary {
+		t.Errorf("Unexpected santa_rule context: %v", high.Context["santa_rule"])
+	}
+	if got := readRules(t, path); len(got) != 1 || got[0].Identifier != hash {
+		t.Errorf("Rules file = %+v", got)
+	}
+
+	disabled, err := NewSuggester(config.SantaRulesConfig{})
+	if err != nil || disabled != nil {
+		t.Fatalf("NewSuggester(disabled) = %v, %v", disabled, err)
+	}
+	disabled.Suggest(high, execution("", ""), false) // nil-safe
+}
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+// partitionLayout names the daily subdirectories of a partitioned archive
+// (see santa.archive.partition)
+const partitionLayout = "2006-01-02"
+
+// Options selects the archived events a search reads
+type Options struct {
+	Since time.Time // Events before this are skipped (zero = no lower bound)
+	Until time.Time // Events at or after this are skipped (zero = no upper bound)
+	Limit int       // Stop after this many matches (0 = no limit)
+}
+
+// Match is an archived event the expression matched
+type Match struct {
+	File    string
+	Message *santapb.SantaMessage
+}
+
+// Stats summarizes a search
+type Stats struct {
+	Files      int  // Spool files read
+	Events     int  // Events in the time range
+	Matches    int  // Events the expression matched
+	EvalErrors int  // Events the expression failed on, e.g. missing fields
+	FileErrors int  // Files that could not be fully decoded
+	Truncated  bool // The search stopped at the match limit
+}
+
+// DecodeFunc streams the events of a spool file, like
+// spool.Decoder.DecodeEventsStream
+type DecodeFunc func(ctx context.Context, path string) iter.Seq2[*santapb.SantaMessage, error]
+
+// Files lists the spool files under paths that may hold events at or after
+// since, oldest first. Santa writes a spool file after the events in it, so
+// files last written before since are skipped, as are the daily partitions of
+// earlier days.
+func Files(paths []string, since time.Time) ([]string, error) {
+	type spoolFile struct {
+		path    string
+		modTime time.Time
+	}
+	var firstDay string
+	if !since.IsZero() {
+		firstDay = since.Local().Format(partitionLayout) // Partitions are named by local day
+	}
+	var files []spoolFile
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != root && firstDay != "" && isPartition(d.Name()) && d.Name() < firstDay {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") || strings.HasSuffix(d.Name(), ".tmp") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !since.IsZero() && info.ModTime().Before(since) {
+				return nil
+			}
+			files = append(files, spoolFile{path: p, modTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list spool files: %w", err)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f.path
+	}
+	return out, nil
+}
+
+// isPartition reports whether name is a daily archive partition
+func isPartition(name string) bool {
+	_, err := time.Parse(partitionLayout, name)
+	return err == nil
+}
+
+// Run evaluates filter against the events of files in the time range and
+// calls fn for each match, in file order. Files that fail to decode and
+// events the filter fails on are counted and skipped. An error from fn or
+// ctx ends the search.
+func Run(ctx context.Context, files []string, decode DecodeFunc, filter *rules.Filter, opts Options, fn func(Match) error) (Stats, error) {
+	var stats Stats
+	for _, file := range files {
+		stats.Files++
+		for msg, err := range decode(ctx, file) {
+			if err != nil {
+				if ctx.Err() != nil {
+					return stats, ctx.Err()
+				}
+				stats.FileErrors++
+				break
+			}
+			if !InRange(msg, opts.Since, opts.Until) {
+				continue
+			}
+			stats.Events++
+			matched, err := filter.Match(msg)
+			if err != nil {
+				stats.EvalErrors++
+				continue
+			}
+			if !matched {
+				continue
+			}
+			stats.Matches++
+			if err := fn(Match{File: file, Message: msg}); err != nil {
+				return stats, err
+			}
+			if opts.Limit > 0 && stats.Matches >= opts.Limit {
+				stats.Truncated = true
+				return stats, nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// InRange reports whether the event happened in [since, until). Events
+// without a timestamp only match an unbounded range.
+func InRange(msg *santapb.SantaMessage, since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+	ts := events.EventTime(msg)
+	if ts.IsZero() {
+		return false
+	}
+	return (since.IsZero() || !ts.Before(since)) && (until.IsZero() || ts.Before(until))
+}
+
+// ParseTime parses a --since or --until value relative to now: a duration
+// back from now (24h), an RFC 3339 timestamp, or a local date (2006-01-02)
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("duration %q cannot be negative", s)
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(partitionLayout, s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use a duration (24h), an RFC 3339 timestamp or a date (2006-01-02)", s)
+}
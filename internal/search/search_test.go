@@ -0,0 +1,145 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/0x4d31/santamon/internal/rules"
+)
+
+var base = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func execMessage(path string, at time.Time) *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		EventTime: timestamppb.New(at),
+		Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+			Target: &santapb.ProcessInfo{Executable: &santapb.FileInfo{Path: proto.String(path)}},
+		}},
+	}
+}
+
+// fakeArchive decodes files from memory; "broken" files fail after their events
+type fakeArchive map[string][]*santapb.SantaMessage
+
+func (a fakeArchive) decode(_ context.Context, path string) iter.Seq2[*santapb.SantaMessage, error] {
+	return func(yield func(*santapb.SantaMessage, error) bool) {
+		for _, msg := range a[path] {
+			if !yield(msg, nil) {
+				return
+			}
+		}
+		if path == "broken" {
+			yield(nil, errors.New("truncated"))
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := engine.CompileFilter(`kind == "execution" && event.execution.target.executable.path.startsWith("/tmp/")`)
+	if err != nil {
+		t.Fatalf("CompileFilter failed: %v", err)
+	}
+	archive := fakeArchive{
+		"a":      {execMessage("/tmp/old", base.Add(-2*time.Hour)), execMessage("/tmp/one", base), execMessage("/bin/ls", base)},
+		"broken": {execMessage("/tmp/two", base.Add(time.Minute))},
+		"c":      {execMessage("/tmp/late", base.Add(2*time.Hour)), execMessage("/tmp/three", base.Add(time.Hour))},
+	}
+	files := []string{"a", "broken", "c"}
+	opts := Options{Since: base.Add(-time.Hour), Until: base.Add(2 * time.Hour)}
+
+	var got []string
+	collect := func(m Match) error {
+		got = append(got, m.File+":"+m.Message.GetExecution().GetTarget().GetExecutable().GetPath())
+		return nil
+	}
+	stats, err := Run(context.Background(), files, archive.decode, filter, opts, collect)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := []string{"a:/tmp/one", "broken:/tmp/two", "c:/tmp/three"}; !slices.Equal(got, want) {
+		t.Errorf("Matches = %q, want %q", got, want)
+	}
+	if want := (Stats{Files: 3, Events: 4, Matches: 3, FileErrors: 1}); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+
+	got = nil
+	opts.Limit = 2
+	stats, err = Run(context.Background(), files, archive.decode, filter, opts, collect)
+	if err != nil || len(got) != 2 || !stats.Truncated {
+		t.Errorf("Limited run = %q, %+v, %v", got, stats, err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	since := time.Date(2026, 10, 2, 12, 0, 0, 0, time.Local)
+	write := func(name string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("2026-10-01/old.zst", since.Add(time.Hour)) // Earlier partition, skipped whatever its time
+	write("2026-10-02/morning.zst", since.Add(-time.Hour))
+	write("2026-10-02/afternoon.zst", since.Add(2*time.Hour))
+	write("2026-10-03/next.zst", since.Add(24*time.Hour))
+	write("flat.zst", since.Add(time.Hour))
+	write("flat.zst.tmp", since.Add(time.Hour))
+
+	files, err := Files([]string{dir}, since)
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	for i := range files {
+		files[i], _ = filepath.Rel(dir, files[i])
+	}
+	if want := []string{"flat.zst", "2026-10-02/afternoon.zst", "2026-10-03/next.zst"}; !slices.Equal(files, want) {
+		t.Errorf("Files() = %q, want %q", files, want)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"24h", now.Add(-24 * time.Hour)},
+		{"2026-10-01T08:30:00Z", time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC)},
+		{"2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"-1h", "yesterday"} {
+		if _, err := ParseTime(in, now); err == nil {
+			t.Errorf("ParseTime(%q) should fail", in)
+		}
+	}
+}
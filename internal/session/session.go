@@ -0,0 +1,191 @@
+package session
+
+import (
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// maxHistory bounds the console changes kept for lookups by event time
+const maxHistory = 256
+
+// Console is the user at the macOS console (the login window session in the
+// foreground). A zero Console means no user is known to be logged in.
+type Console struct {
+	User   string
+	UID    int32
+	Locked bool // The screen is locked
+}
+
+// change is the console from a point in event time on
+type change struct {
+	at      time.Time
+	console Console
+}
+
+// Tracker follows the console user through login_window_session events,
+// falling back to login_logout logins (Terminal runs login(1) for each new
+// shell) until a login window event is seen. Changes are kept by event time,
+// so events are attributed to the user at the console when they happened
+// even when the session events are observed later in the same spool file.
+type Tracker struct {
+	mu      sync.Mutex
+	history []change
+	active  uint32 // Graphical session in the foreground
+	seenLWS bool   // A login_window_session event was observed
+}
+
+// NewTracker creates a tracker that knows no console user until Seed or
+// Observe
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Seed sets the console user the tracker starts from, for events before the
+// first observed session event
+func (t *Tracker) Seed(c Console) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.history = append([]change{{console: c}}, t.history...)
+	t.trim()
+}
+
+// Observe updates the console from login_window_session and login_logout
+// events; other events are ignored
+func (t *Tracker) Observe(msg *santapb.SantaMessage) {
+	switch ev := msg.GetEvent().(type) {
+	case *santapb.SantaMessage_LoginWindowSession:
+		t.observeLoginWindow(events.EventTime(msg), ev.LoginWindowSession)
+	case *santapb.SantaMessage_LoginLogout:
+		login := ev.LoginLogout.GetLogin()
+		if login == nil || !login.GetSuccess() || login.GetUser().GetName() == "" {
+			return
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if !t.seenLWS && t.current().User == "" {
+			t.record(events.EventTime(msg), Console{User: login.GetUser().GetName(), UID: login.GetUser().GetUid()})
+		}
+	}
+}
+
+func (t *Tracker) observeLoginWindow(at time.Time, lws *santapb.LoginWindowSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seenLWS = true
+
+	switch {
+	case lws.GetLogin() != nil:
+		e := lws.GetLogin()
+		t.activate(at, e.GetGraphicalSession().GetId(), e.GetUser())
+	case lws.GetUnlock() != nil:
+		// Unlocking also switches to the session with fast user switching
+		e := lws.GetUnlock()
+		t.activate(at, e.GetGraphicalSession().GetId(), e.GetUser())
+	case lws.GetLock() != nil:
+		e := lws.GetLock()
+		if t.isActive(e.GetGraphicalSession().GetId(), e.GetUser()) {
+			c := t.current()
+			c.Locked = true
+			t.record(at, c)
+		}
+	case lws.GetLogout() != nil:
+		e := lws.GetLogout()
+		if t.isActive(e.GetGraphicalSession().GetId(), e.GetUser()) {
+			t.active = 0
+			t.record(at, Console{})
+		}
+	}
+}
+
+// activate brings a graphical session to the foreground. t.mu must be held.
+func (t *Tracker) activate(at time.Time, id uint32, u *santapb.UserInfo) {
+	t.active = id
+	t.record(at, Console{User: u.GetName(), UID: u.GetUid()})
+}
+
+// isActive reports whether a graphical session is in the foreground. Before
+// the first login or unlock (e.g. after Seed) the session ID is unknown, and
+// the console user's sessions count as active. t.mu must be held.
+func (t *Tracker) isActive(id uint32, u *santapb.UserInfo) bool {
+	if t.active == 0 {
+		return u.GetName() != "" && u.GetName() == t.current().User
+	}
+	return id == t.active
+}
+
+// At returns the console at time at, the current console for a zero time.
+// A nil tracker knows no console.
+func (t *Tracker) At(at time.Time) Console {
+	if t == nil {
+		return Console{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		return t.current()
+	}
+	// First change after at; the one before it was in effect
+	i := sort.Search(len(t.history), func(i int) bool { return t.history[i].at.After(at) })
+	if i == 0 {
+		return Console{}
+	}
+	return t.history[i-1].console
+}
+
+// current returns the latest console. t.mu must be held.
+func (t *Tracker) current() Console {
+	if len(t.history) == 0 {
+		return Console{}
+	}
+	return t.history[len(t.history)-1].console
+}
+
+// record adds a console change. Changes observed out of event order are
+// recorded at the latest known time so the history stays sorted. t.mu must
+// be held.
+func (t *Tracker) record(at time.Time, c Console) {
+	n := len(t.history)
+	if n > 0 && t.history[n-1].console == c {
+		return
+	}
+	if n > 0 && at.Before(t.history[n-1].at) {
+		at = t.history[n-1].at
+	}
+	t.history = append(t.history, change{at: at, console: c})
+	t.trim()
+}
+
+// trim drops the oldest changes past maxHistory. t.mu must be held.
+func (t *Tracker) trim() {
+	if len(t.history) > maxHistory {
+		t.history = append(t.history[:0], t.history[len(t.history)-maxHistory:]...)
+	}
+}
+
+// ConsoleOwner returns the owner of /dev/console, the user logged in at the
+// login window on macOS. Root owns it when no one is logged in, which is
+// reported as a zero Console.
+func ConsoleOwner() Console {
+	info, err := os.Stat("/dev/console")
+	if err != nil {
+		return Console{}
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Uid == 0 {
+		return Console{}
+	}
+	c := Console{UID: int32(st.Uid), User: strconv.FormatUint(uint64(st.Uid), 10)}
+	if u, err := user.LookupId(c.User); err == nil {
+		c.User = u.Username
+	}
+	return c
+}
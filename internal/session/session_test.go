@@ -0,0 +1,155 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var start = time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+
+func userInfo(name string, uid int32) *santapb.UserInfo {
+	return &santapb.UserInfo{Name: proto.String(name), Uid: proto.Int32(uid)}
+}
+
+func gui(id uint32) *santapb.GraphicalSession {
+	return &santapb.GraphicalSession{Id: proto.Uint32(id)}
+}
+
+func at(minutes int, lws *santapb.LoginWindowSession) *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		EventTime: timestamppb.New(start.Add(time.Duration(minutes) * time.Minute)),
+		Event:     &santapb.SantaMessage_LoginWindowSession{LoginWindowSession: lws},
+	}
+}
+
+func login(minutes int, u *santapb.UserInfo, id uint32) *santapb.SantaMessage {
+	return at(minutes, &santapb.LoginWindowSession{Event: &santapb.LoginWindowSession_Login{
+		Login: &santapb.LoginWindowSessionLogin{User: u, GraphicalSession: gui(id)},
+	}})
+}
+
+func lock(minutes int, u *santapb.UserInfo, id uint32) *santapb.SantaMessage {
+	return at(minutes, &santapb.LoginWindowSession{Event: &santapb.LoginWindowSession_Lock{
+		Lock: &santapb.LoginWindowSessionLock{User: u, GraphicalSession: gui(id)},
+	}})
+}
+
+func unlock(minutes int, u *santapb.UserInfo, id uint32) *santapb.SantaMessage {
+	return at(minutes, &santapb.LoginWindowSession{Event: &santapb.LoginWindowSession_Unlock{
+		Unlock: &santapb.LoginWindowSessionUnlock{User: u, GraphicalSession: gui(id)},
+	}})
+}
+
+func logout(minutes int, u *santapb.UserInfo, id uint32) *santapb.SantaMessage {
+	return at(minutes, &santapb.LoginWindowSession{Event: &santapb.LoginWindowSession_Logout{
+		Logout: &santapb.LoginWindowSessionLogout{User: u, GraphicalSession: gui(id)},
+	}})
+}
+
+func TestTracker(t *testing.T) {
+	alice, bob := userInfo("alice", 501), userInfo("bob", 502)
+	tr := NewTracker()
+	for _, msg := range []*santapb.SantaMessage{
+		login(10, alice, 1),
+		lock(20, alice, 1),
+		login(21, bob, 2),  // Fast user switching
+		lock(25, alice, 1), // Background session, ignored
+		unlock(30, alice, 1),
+		logout(40, bob, 2), // Background session, ignored
+		logout(50, alice, 1),
+	} {
+		tr.Observe(msg)
+	}
+
+	for _, tc := range []struct {
+		minutes int
+		want    Console
+	}{
+		{5, Console{}},
+		{10, Console{User: "alice", UID: 501}},
+		{15, Console{User: "alice", UID: 501}},
+		{20, Console{User: "alice", UID: 501, Locked: true}},
+		{22, Console{User: "bob", UID: 502}},
+		{35, Console{User: "alice", UID: 501}},
+		{45, Console{User: "alice", UID: 501}},
+		{55, Console{}},
+	} {
+		if got := tr.At(start.Add(time.Duration(tc.minutes) * time.Minute)); got != tc.want {
+			t.Errorf("At(+%dm) = %+v, want %+v", tc.minutes, got, tc.want)
+		}
+	}
+	if got := tr.At(time.Time{}); got != (Console{}) {
+		t.Errorf("At(zero) = %+v, want the current console (none)", got)
+	}
+
+	var nilTracker *Tracker
+	if got := nilTracker.At(start); got != (Console{}) {
+		t.Errorf("A nil tracker should know no console, got %+v", got)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	tr := NewTracker()
+	tr.Seed(Console{User: "alice", UID: 501})
+	if got := tr.At(start); got.User != "alice" {
+		t.Fatalf("Seeded console = %+v, want alice", got)
+	}
+
+	// The seeded session's ID is unknown; the console user's lock applies
+	tr.Observe(lock(10, userInfo("alice", 501), 7))
+	if got := tr.At(start.Add(15 * time.Minute)); !got.Locked {
+		t.Errorf("Lock of the seeded user's session should apply, got %+v", got)
+	}
+	if got := tr.At(start); got.Locked {
+		t.Errorf("Events before the lock should see the unlocked console, got %+v", got)
+	}
+}
+
+func TestLoginLogoutFallback(t *testing.T) {
+	terminalLogin := func(minutes int, name string, success bool) *santapb.SantaMessage {
+		return &santapb.SantaMessage{
+			EventTime: timestamppb.New(start.Add(time.Duration(minutes) * time.Minute)),
+			Event: &santapb.SantaMessage_LoginLogout{LoginLogout: &santapb.LoginLogout{Event: &santapb.LoginLogout_Login{
+				Login: &santapb.Login{User: userInfo(name, 501), Success: proto.Bool(success)},
+			}}},
+		}
+	}
+
+	tr := NewTracker()
+	tr.Observe(terminalLogin(1, "mallory", false))
+	if got := tr.At(start.Add(time.Hour)); got != (Console{}) {
+		t.Errorf("Failed logins should be ignored, got %+v", got)
+	}
+	tr.Observe(terminalLogin(2, "alice", true))
+	if got := tr.At(start.Add(time.Hour)); got.User != "alice" {
+		t.Errorf("A login should stand in for the unknown console user, got %+v", got)
+	}
+
+	tr.Observe(login(3, userInfo("bob", 502), 1))
+	tr.Observe(logout(4, userInfo("bob", 502), 1))
+	tr.Observe(terminalLogin(5, "alice", true))
+	if got := tr.At(start.Add(time.Hour)); got != (Console{}) {
+		t.Errorf("Logins should not override login window sessions, got %+v", got)
+	}
+}
+
+func TestHistoryBound(t *testing.T) {
+	tr := NewTracker()
+	for i := range maxHistory + 10 {
+		if i%2 == 0 {
+			tr.Observe(login(i, userInfo("alice", 501), 1))
+		} else {
+			tr.Observe(lock(i, userInfo("alice", 501), 1))
+		}
+	}
+	if len(tr.history) != maxHistory {
+		t.Errorf("History has %d changes, want %d", len(tr.history), maxHistory)
+	}
+	if got := tr.At(start.Add(time.Duration(maxHistory+9) * time.Minute)); !got.Locked {
+		t.Errorf("Latest change should be kept, got %+v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package shadow
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/signals"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// maxSamples bounds the shadow-only signals kept per rule
+const maxSamples = 5
+
+// Report compares what the shadow rules matched with the active rules since
+// the runner was created or last reset
+type Report struct {
+	Since         time.Time   `json:"since"`
+	ActiveVersion string      `json:"active_version"`
+	ShadowVersion string      `json:"shadow_version"`
+	Events        int64       `json:"events"`
+	Rules         []*RuleDiff `json:"rules"`
+}
+
+// RuleDiff counts the events a rule ID matched in either rule set
+type RuleDiff struct {
+	RuleID     string          `json:"rule_id"`
+	Both       int64           `json:"both"`
+	ActiveOnly int64           `json:"active_only"`
+	ShadowOnly int64           `json:"shadow_only"`
+	Samples    []*state.Signal `json:"samples,omitempty"` // Latest shadow-only signals, never shipped
+}
+
+// Changed reports whether the rule sets disagreed on any event
+func (d *RuleDiff) Changed() bool {
+	return d.ActiveOnly > 0 || d.ShadowOnly > 0
+}
+
+// Runner evaluates a shadow rule set against the events the active rules
+// see. Only simple rules are compared; correlations and baselines keep state
+// and are not evaluated in shadow.
+type Runner struct {
+	mu            sync.Mutex
+	engine        *rules.Engine
+	gen           *signals.Generator
+	activeVersion string
+	since         time.Time
+	events        int64
+	diffs         map[string]*RuleDiff
+}
+
+// New creates a runner for the compiled shadow engine
+func New(hostID string, engine *rules.Engine, activeVersion string) *Runner {
+	gen := signals.NewGenerator(hostID, nil)
+	gen.SetRulesVersion(engine.Version())
+	return &Runner{
+		engine:        engine,
+		gen:           gen,
+		activeVersion: activeVersion,
+		since:         time.Now(),
+		diffs:         make(map[string]*RuleDiff),
+	}
+}
+
+// Compare evaluates the shadow rules on msg and records how they differ from
+// the matches of the active rules
+func (r *Runner) Compare(msg *santapb.SantaMessage, active []*rules.Match) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matches, err := r.engine.Evaluate(msg)
+	if err != nil {
+		return err
+	}
+	r.events++
+
+	activeIDs := make(map[string]bool, len(active))
+	for _, m := range active {
+		activeIDs[m.RuleID] = true
+	}
+	shadowIDs := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		shadowIDs[m.RuleID] = true
+		d := r.diff(m.RuleID)
+		if activeIDs[m.RuleID] {
+			d.Both++
+			continue
+		}
+		d.ShadowOnly++
+		sig := r.gen.FromRuleMatch(m)
+		sig.Context["shadow"] = true
+		if len(d.Samples) == maxSamples {
+			d.Samples = slices.Delete(d.Samples, 0, 1)
+		}
+		d.Samples = append(d.Samples, sig)
+	}
+	for id := range activeIDs {
+		if !shadowIDs[id] {
+			r.diff(id).ActiveOnly++
+		}
+	}
+	return nil
+}
+
+func (r *Runner) diff(ruleID string) *RuleDiff {
+	d, ok := r.diffs[ruleID]
+	if !ok {
+		d = &RuleDiff{RuleID: ruleID}
+		r.diffs[ruleID] = d
+	}
+	return d
+}
+
+// Report returns a copy of the comparison so far, sorted by rule ID
+func (r *Runner) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{
+		Since:         r.since,
+		ActiveVersion: r.activeVersion,
+		ShadowVersion: r.engine.Version(),
+		Events:        r.events,
+		Rules:         make([]*RuleDiff, 0, len(r.diffs)),
+	}
+	for _, id := range slices.Sorted(maps.Keys(r.diffs)) {
+		d := *r.diffs[id]
+		d.Samples = slices.Clone(d.Samples)
+		report.Rules = append(report.Rules, &d)
+	}
+	return report
+}
+
+// Reset starts a new comparison, e.g. after either rule set was reloaded
+func (r *Runner) Reset(engine *rules.Engine, activeVersion string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.engine = engine
+	r.gen.SetRulesVersion(engine.Version())
+	r.activeVersion = activeVersion
+	r.since = time.Now()
+	r.events = 0
+	r.diffs = make(map[string]*RuleDiff)
+}
@@ -0,0 +1,117 @@
+package shadow
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/rules"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newEngine(t *testing.T, rs ...*rules.Rule) *rules.Engine {
+	t.Helper()
+	engine, err := rules.NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if err := engine.LoadRules(&rules.RulesConfig{Rules: rs}); err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	return engine
+}
+
+func execMessage(path string, decision santapb.Execution_Decision) *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		MachineId:       proto.String("test-machine"),
+		BootSessionUuid: proto.String("boot-123"),
+		EventTime:       timestamppb.New(time.Now()),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Decision: decision.Enum(),
+				Target: &santapb.ProcessInfo{
+					Executable: &santapb.FileInfo{Path: proto.String(path)},
+				},
+			},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	active := newEngine(t,
+		&rules.Rule{ID: "DENY", Title: "Denied", Expr: `event.execution.decision == DECISION_DENY`, Severity: "high", Enabled: true},
+		&rules.Rule{ID: "SHELL", Title: "Shell", Expr: `event.execution.target.executable.path == "/bin/sh"`, Severity: "low", Enabled: true},
+	)
+	candidate := newEngine(t,
+		&rules.Rule{ID: "DENY", Title: "Denied", Expr: `event.execution.decision == DECISION_DENY`, Severity: "high", Enabled: true},
+		&rules.Rule{ID: "SHELL", Title: "Shell", Expr: `event.execution.target.executable.path in ["/bin/sh", "/bin/zsh"]`, Severity: "low", Enabled: true},
+	)
+	r := New("test-host", candidate, active.Version())
+
+	msgs := []*santapb.SantaMessage{
+		execMessage("/bin/sh", santapb.Execution_DECISION_ALLOW),
+		execMessage("/bin/zsh", santapb.Execution_DECISION_ALLOW),
+		execMessage("/bin/zsh", santapb.Execution_DECISION_DENY),
+		execMessage("/bin/ls", santapb.Execution_DECISION_ALLOW),
+	}
+	for _, msg := range msgs {
+		matches, err := active.Evaluate(msg)
+		if err != nil {
+			t.Fatalf("Failed to evaluate active rules: %v", err)
+		}
+		if err := r.Compare(msg, matches); err != nil {
+			t.Fatalf("Failed to compare: %v", err)
+		}
+	}
+
+	report := r.Report()
+	if report.Events != 4 || report.ActiveVersion != active.Version() || report.ShadowVersion != candidate.Version() {
+		t.Errorf("Unexpected report header: %+v", report)
+	}
+	if len(report.Rules) != 2 || report.Rules[0].RuleID != "DENY" || report.Rules[1].RuleID != "SHELL" {
+		t.Fatalf("Unexpected report rules: %+v", report.Rules)
+	}
+	if d := report.Rules[0]; d.Both != 1 || d.Changed() {
+		t.Errorf("DENY diff = %+v, want 1 agreed match", d)
+	}
+	d := report.Rules[1]
+	if d.Both != 1 || d.ShadowOnly != 2 || d.ActiveOnly != 0 {
+		t.Errorf("SHELL diff = %+v, want 1 both and 2 shadow-only", d)
+	}
+	if len(d.Samples) != 2 || d.Samples[0].Context["shadow"] != true || d.Samples[0].RulesVersion != candidate.Version() {
+		t.Errorf("Unexpected shadow samples: %v", d.Samples)
+	}
+
+	// Matches only the active rules saw are counted against the rule
+	if err := r.Compare(execMessage("/bin/ls", santapb.Execution_DECISION_ALLOW), []*rules.Match{{RuleID: "REMOVED"}}); err != nil {
+		t.Fatalf("Failed to compare: %v", err)
+	}
+	if d := r.Report().Rules[1]; d.RuleID != "REMOVED" || d.ActiveOnly != 1 {
+		t.Errorf("REMOVED diff = %+v, want 1 active-only", d)
+	}
+
+	r.Reset(candidate, "next")
+	if report := r.Report(); report.Events != 0 || len(report.Rules) != 0 || report.ActiveVersion != "next" {
+		t.Errorf("Report() after Reset = %+v", report)
+	}
+}
+
+func TestSamplesBounded(t *testing.T) {
+	candidate := newEngine(t, &rules.Rule{ID: "ANY", Title: "Any", Expr: `kind == "execution"`, Severity: "low", Enabled: true})
+	r := New("test-host", candidate, "active")
+
+	for i := 0; i < maxSamples+3; i++ {
+		if err := r.Compare(execMessage(fmt.Sprintf("/tmp/%d", i), santapb.Execution_DECISION_ALLOW), nil); err != nil {
+			t.Fatalf("Failed to compare: %v", err)
+		}
+	}
+	d := r.Report().Rules[0]
+	if d.ShadowOnly != maxSamples+3 || len(d.Samples) != maxSamples {
+		t.Fatalf("ANY diff = %d shadow-only, %d samples", d.ShadowOnly, len(d.Samples))
+	}
+	if got := d.Samples[maxSamples-1].Context["target_path"]; got != fmt.Sprintf("/tmp/%d", maxSamples+2) {
+		t.Errorf("Latest sample target = %v", got)
+	}
+}
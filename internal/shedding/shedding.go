@@ -0,0 +1,145 @@
+package shedding
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+// Level is how much detection work is being shed
+type Level int
+
+const (
+	// LevelNone evaluates everything
+	LevelNone Level = iota
+	// LevelSkipLow skips non-priority rules with info or low severity
+	LevelSkipLow
+	// LevelSample also evaluates only one in every N events against
+	// non-priority rules. Priority rules always see every event.
+	LevelSample
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelSkipLow:
+		return "skip-low"
+	case LevelSample:
+		return "sample"
+	default:
+		return "none"
+	}
+}
+
+// Policy decides how much work to shed from the spool backlog. Levels are
+// entered when the backlog reaches their threshold and left once it drops
+// below half of it, so the level does not flap around a threshold. Sampling
+// keeps every Nth event, so the same input is always shed the same way.
+type Policy struct {
+	skipLowAt int
+	sampleAt  int
+	every     int
+
+	mu      sync.Mutex
+	level   Level
+	backlog int
+	seen    uint64
+	floor   Level  // Minimum level regardless of the backlog
+	reason  string // Why the floor is set
+}
+
+// NewPolicy creates a policy from the load shedding config
+func NewPolicy(cfg config.LoadSheddingConfig) *Policy {
+	every := int(math.Round(1 / cfg.SampleRate))
+	if every < 1 {
+		every = 1
+	}
+	return &Policy{
+		skipLowAt: cfg.SkipLowBacklog,
+		sampleAt:  cfg.SampleBacklog,
+		every:     every,
+	}
+}
+
+// Update sets the level for the current backlog (files waiting to be
+// processed). It returns the level and whether it changed.
+func (p *Policy) Update(backlog int) (Level, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prev := p.level
+	next := prev
+	switch {
+	case backlog >= p.sampleAt:
+		next = LevelSample
+	case backlog >= p.skipLowAt:
+		next = max(prev, LevelSkipLow)
+	}
+	// Step down only once the backlog has drained well below the threshold
+	if next == LevelSample && backlog*2 < p.sampleAt {
+		next = LevelSkipLow
+	}
+	if next == LevelSkipLow && backlog*2 < p.skipLowAt {
+		next = LevelNone
+	}
+	next = max(next, p.floor)
+
+	p.level = next
+	p.backlog = backlog
+	if next != prev {
+		p.seen = 0
+	}
+	return next, next != prev
+}
+
+// SetFloor keeps the level at floor or above until it is reset to LevelNone,
+// e.g. while the spool disk is under pressure. reason is reported by Health.
+// The level follows on the next Update.
+func (p *Policy) SetFloor(floor Level, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.floor = floor
+	p.reason = reason
+}
+
+// Level returns the current level
+func (p *Policy) Level() Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.level
+}
+
+// SampleEvery returns N: at LevelSample one in every N events is evaluated
+func (p *Policy) SampleEvery() int {
+	return p.every
+}
+
+// Keep reports whether the next event should be evaluated against
+// non-priority rules
+func (p *Policy) Keep() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.level < LevelSample {
+		return true
+	}
+	p.seen++
+	return (p.seen-1)%uint64(p.every) == 0
+}
+
+// Health reports coverage as degraded while any work is being shed
+func (p *Policy) Health() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cause := fmt.Sprintf("backlog %d files", p.backlog)
+	if p.floor != LevelNone && p.level == p.floor {
+		cause = p.reason
+	}
+	switch p.level {
+	case LevelSkipLow:
+		return fmt.Errorf("coverage degraded: skipping info/low rules (%s)", cause)
+	case LevelSample:
+		return fmt.Errorf("coverage degraded: skipping info/low rules and evaluating 1 in %d events (%s)", p.every, cause)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package shedding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+func TestPolicyLevels(t *testing.T) {
+	p := NewPolicy(config.LoadSheddingConfig{SkipLowBacklog: 100, SampleBacklog: 300, SampleRate: 0.25})
+
+	steps := []struct {
+		backlog int
+		want    Level
+		changed bool
+	}{
+		{backlog: 10, want: LevelNone},
+		{backlog: 100, want: LevelSkipLow, changed: true},
+		{backlog: 60, want: LevelSkipLow}, // Above half the threshold: stay
+		{backlog: 350, want: LevelSample, changed: true},
+		{backlog: 200, want: LevelSample}, // Still above 300/2
+		{backlog: 120, want: LevelSkipLow, changed: true},
+		{backlog: 40, want: LevelNone, changed: true},
+		{backlog: 500, want: LevelSample, changed: true},
+		{backlog: 0, want: LevelNone, changed: true},
+	}
+	for i, step := range steps {
+		level, changed := p.Update(step.backlog)
+		if level != step.want || changed != step.changed {
+			t.Errorf("step %d: Update(%d) = %s, %v, want %s, %v", i, step.backlog, level, changed, step.want, step.changed)
+		}
+	}
+}
+
+func TestPolicyKeep(t *testing.T) {
+	p := NewPolicy(config.LoadSheddingConfig{SkipLowBacklog: 1, SampleBacklog: 2, SampleRate: 0.25})
+
+	p.Update(1)
+	for i := 0; i < 4; i++ {
+		if !p.Keep() {
+			t.Fatalf("Event %d dropped below the sampling level", i)
+		}
+	}
+
+	p.Update(2)
+	kept := 0
+	var pattern []bool
+	for i := 0; i < 8; i++ {
+		k := p.Keep()
+		pattern = append(pattern, k)
+		if k {
+			kept++
+		}
+	}
+	if kept != 2 || !pattern[0] || !pattern[4] {
+		t.Errorf("Expected every 4th event kept starting with the first, got %v", pattern)
+	}
+	if err := p.Health(); err == nil || !strings.Contains(err.Error(), "1 in 4") {
+		t.Errorf("Expected degraded coverage, got %v", err)
+	}
+
+	p.Update(0)
+	if err := p.Health(); err != nil {
+		t.Errorf("Expected full coverage, got %v", err)
+	}
+}
+
+func TestPolicyFloor(t *testing.T) {
+	p := NewPolicy(config.LoadSheddingConfig{SkipLowBacklog: 100, SampleBacklog: 300, SampleRate: 0.5})
+
+	p.SetFloor(LevelSample, "spool disk has 100 MB free")
+	if level, changed := p.Update(0); level != LevelSample || !changed {
+		t.Errorf("Update(0) with floor = %s, %v, want sample, true", level, changed)
+	}
+	if err := p.Health(); err == nil || !strings.Contains(err.Error(), "100 MB free") {
+		t.Errorf("Health() = %v, want the floor reason", err)
+	}
+
+	p.SetFloor(LevelNone, "")
+	if level, _ := p.Update(0); level != LevelNone {
+		t.Errorf("Update(0) after clearing the floor = %s, want none", level)
+	}
+}
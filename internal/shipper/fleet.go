@@ -0,0 +1,114 @@
+package shipper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/ttlcache"
+)
+
+const (
+	// fleetCacheTTL is how long the collector's answer for a pattern is
+	// reused. Host counts only grow, so a stale answer at worst alerts on a
+	// pattern that just became common.
+	fleetCacheTTL = time.Hour
+
+	// maxFleetCacheEntries bounds the answer cache
+	maxFleetCacheEntries = 10000
+)
+
+// FleetChecker asks the collector about locally first-seen baseline patterns
+// off the detection loop. Answers are cached per rule and pattern, and
+// lookups run on a bounded queue of workers.
+type FleetChecker struct {
+	lookup  func(ctx context.Context, ruleID, pattern string) (*FleetSighting, error)
+	timeout time.Duration
+	queue   chan fleetCheck
+	cache   *ttlcache.Cache[*FleetSighting]
+	now     func() time.Time
+}
+
+type fleetCheck struct {
+	ruleID  string
+	pattern string
+	done    func(*FleetSighting, error)
+}
+
+// NewFleetChecker creates a checker that queues up to queueSize lookups on s,
+// each given timeout to answer
+func NewFleetChecker(s *Shipper, timeout time.Duration, queueSize int) *FleetChecker {
+	return &FleetChecker{
+		lookup:  s.FleetFirstSeen,
+		timeout: timeout,
+		queue:   make(chan fleetCheck, queueSize),
+		cache:   ttlcache.New[*FleetSighting](fleetCacheTTL, maxFleetCacheEntries),
+		now:     time.Now,
+	}
+}
+
+// Check passes the fleet's view of a rule's pattern to done: right away when
+// the answer is cached, else from a worker once the collector answered or
+// failed. It returns false without calling done when the queue is full.
+// done must be safe to call from any goroutine.
+func (c *FleetChecker) Check(ruleID, pattern string, done func(*FleetSighting, error)) bool {
+	if sighting, ok := c.cache.Get(fleetKey(ruleID, pattern), c.now()); ok {
+		done(sighting, nil)
+		return true
+	}
+	select {
+	case c.queue <- fleetCheck{ruleID: ruleID, pattern: pattern, done: done}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run resolves queued checks on workers until ctx is done. Checks still
+// queued then get ctx's error, so their callers can alert anyway.
+func (c *FleetChecker) Run(ctx context.Context, workers int) error {
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case check := <-c.queue:
+					c.resolve(ctx, check)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for {
+		select {
+		case check := <-c.queue:
+			check.done(nil, ctx.Err())
+		default:
+			return nil
+		}
+	}
+}
+
+// resolve looks up a check, unless an earlier one cached the answer
+func (c *FleetChecker) resolve(ctx context.Context, check fleetCheck) {
+	key := fleetKey(check.ruleID, check.pattern)
+	if sighting, ok := c.cache.Get(key, c.now()); ok {
+		check.done(sighting, nil)
+		return
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	sighting, err := c.lookup(lookupCtx, check.ruleID, check.pattern)
+	cancel()
+	if err == nil {
+		c.cache.Put(key, sighting, c.now())
+	}
+	check.done(sighting, err)
+}
+
+// fleetKey keys the answer cache; rule IDs never contain NUL
+func fleetKey(ruleID, pattern string) string {
+	return ruleID + "\x00" + pattern
+}
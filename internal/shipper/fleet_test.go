@@ -0,0 +1,87 @@
+package shipper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/ttlcache"
+)
+
+func TestFleetChecker(t *testing.T) {
+	lookups := make(chan string, 10)
+	c := &FleetChecker{
+		lookup: func(ctx context.Context, ruleID, pattern string) (*FleetSighting, error) {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lookups <- ruleID + " " + pattern
+			if pattern == "down" {
+				return nil, errors.New("collector unavailable")
+			}
+			return &FleetSighting{Hosts: 7}, nil
+		},
+		timeout: time.Second,
+		queue:   make(chan fleetCheck, 1),
+		cache:   ttlcache.New[*FleetSighting](time.Hour, 10),
+		now:     time.Now,
+	}
+
+	type answer struct {
+		sighting *FleetSighting
+		err      error
+	}
+	answers := make(chan answer, 10)
+	done := func(s *FleetSighting, err error) { answers <- answer{s, err} }
+
+	if !c.Check("B1", "/bin/ls", done) {
+		t.Fatal("Check() failed")
+	}
+	// The queue holds one check
+	if c.Check("B1", "/bin/cat", done) {
+		t.Error("Check() on a full queue succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- c.Run(ctx, 2) }()
+	if a := <-answers; a.err != nil || a.sighting.Hosts != 7 {
+		t.Errorf("Answer = %+v, %v", a.sighting, a.err)
+	}
+
+	// Answers are cached and given right away; failures are not cached
+	if !c.Check("B1", "/bin/ls", done) {
+		t.Fatal("Check() failed")
+	}
+	if a := <-answers; a.err != nil || a.sighting.Hosts != 7 {
+		t.Errorf("Cached answer = %+v, %v", a.sighting, a.err)
+	}
+	c.Check("B1", "down", done)
+	if a := <-answers; a.err == nil {
+		t.Error("Failed lookup returned no error")
+	}
+	c.Check("B1", "down", done)
+	<-answers
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+	close(lookups)
+	var got []string
+	for l := range lookups {
+		got = append(got, l)
+	}
+	if len(got) != 3 {
+		t.Errorf("Lookups = %q, want /bin/ls once and down twice", got)
+	}
+
+	// Checks still queued at shutdown get the context's error
+	c.Check("B2", "/bin/ps", done)
+	if err := c.Run(ctx, 1); err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+	if a := <-answers; !errors.Is(a.err, context.Canceled) {
+		t.Errorf("Queued check at shutdown got %v", a.err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
@@ -16,14 +17,18 @@ import (
 	"time"
 
 	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/inventory"
 	"github.com/0x4d31/santamon/internal/logutil"
 	"github.com/0x4d31/santamon/internal/state"
+	"github.com/0x4d31/santamon/internal/tracing"
 )
 
+var logger = logutil.For("shipper")
+
 // Shipper sends signals to the backend
 type Shipper struct {
-	config     *config.ShipperConfig
-	db         *state.DB
+	config     atomic.Pointer[config.ShipperConfig]
+	db         state.Store
 	httpClient *http.Client
 	userAgent  string
 	agentID    string
@@ -32,6 +37,31 @@ type Shipper struct {
 	flushCh    chan struct{}
 	flushMu    sync.Mutex
 
+	// Active rules version, load shedding state, suppression and retention
+	// counts reported in heartbeats
+	rulesVersion     atomic.Pointer[string]
+	coverageDegraded atomic.Pointer[string]
+	suppressions     atomic.Pointer[map[string]int64]
+	pruned           atomic.Pointer[map[string]int64]
+	disabledRules    atomic.Pointer[map[string]string]
+
+	// Continues the trace of signals generated from traced spool files
+	tracer *tracing.Tracer
+
+	// Machine inventory reported in heartbeats
+	inventory *inventory.Collector
+
+	// Extra destinations that receive a transformed copy of each new signal
+	sinks []*Sink
+
+	// Closed and replaced on UpdateConfig so loops can pick up new intervals
+	reloadMu sync.Mutex
+	reloaded chan struct{}
+
+	// Priority lane: ships priority signals without waiting behind bulk batches
+	priorityCh chan struct{}
+	priorityMu sync.Mutex
+
 	// Circuit breaker state
 	circuitOpen      atomic.Bool
 	circuitOpenUntil atomic.Int64
@@ -54,29 +84,32 @@ func getOSVersion() string {
 }
 
 // NewShipper creates a new signal shipper
-func NewShipper(cfg *config.ShipperConfig, db *state.DB, agentID, version string) *Shipper {
+func NewShipper(cfg *config.ShipperConfig, db state.Store, agentID, version string) *Shipper {
 	// Create HTTP client with optional TLS skip verify
 	transport := &http.Transport{}
 	if cfg.TLSSkipVerify {
 		transport.TLSClientConfig = &tls.Config{
 			InsecureSkipVerify: true,
 		}
-		// Print warning without timestamp (startup message)
-		fmt.Println("\033[93m⚠\033[0m TLS certificate verification disabled")
+		// Print warning without timestamp (startup message), on stderr so stdout
+		// stays clean for --output ndjson
+		fmt.Fprintln(os.Stderr, "\033[93m⚠\033[0m TLS certificate verification disabled")
 	}
 
 	s := &Shipper{
-		config:    cfg,
 		db:        db,
 		agentID:   agentID,
 		version:   version,
 		osVersion: getOSVersion(),
 		userAgent: fmt.Sprintf("github.com/0x4d31/santamon/%s", version),
+		// Request timeouts come from the current config (see withTimeout)
 		httpClient: &http.Client{
-			Timeout:   cfg.Timeout,
 			Transport: transport,
 		},
+		priorityCh: make(chan struct{}, 1),
+		reloaded:   make(chan struct{}),
 	}
+	s.config.Store(cfg)
 	// Enable immediate flush channel only when configured
 	flushOn := cfg.FlushOnEnqueue == nil || (cfg.FlushOnEnqueue != nil && *cfg.FlushOnEnqueue)
 	if flushOn {
@@ -85,14 +118,47 @@ func NewShipper(cfg *config.ShipperConfig, db *state.DB, agentID, version string
 	return s
 }
 
+// conf returns the current shipper configuration
+func (s *Shipper) conf() *config.ShipperConfig {
+	return s.config.Load()
+}
+
+// UpdateConfig atomically swaps in a new configuration. Endpoint, API key,
+// batch size, timeouts, retry policy and intervals take effect immediately;
+// TLS and flush-on-enqueue settings are fixed at construction.
+func (s *Shipper) UpdateConfig(cfg *config.ShipperConfig) {
+	s.config.Store(cfg)
+
+	s.reloadMu.Lock()
+	close(s.reloaded)
+	s.reloaded = make(chan struct{})
+	s.reloadMu.Unlock()
+}
+
+// configChanged returns a channel closed on the next UpdateConfig
+func (s *Shipper) configChanged() <-chan struct{} {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.reloaded
+}
+
+// withTimeout bounds a single request by the configured timeout
+func (s *Shipper) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := s.conf().Timeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // Start begins the shipping loop
 func (s *Shipper) Start(ctx context.Context) error {
-	ticker := time.NewTicker(s.config.FlushInterval)
+	ticker := time.NewTicker(s.conf().FlushInterval)
 	defer ticker.Stop()
+	changed := s.configChanged()
 
 	// Immediate flush on start to clear any queued signals
 	if err := s.flushWithContext(ctx); err != nil && err != context.Canceled {
-		logutil.Warn("Initial flush error: %v", err)
+		logger.Warn("Initial flush error: %v", err)
 	}
 
 	for {
@@ -102,7 +168,7 @@ func (s *Shipper) Start(ctx context.Context) error {
 			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 			if err := s.flushWithContext(shutdownCtx); err != nil && err != context.Canceled {
-				logutil.Warn("Shutdown flush error: %v", err)
+				logger.Warn("Shutdown flush error: %v", err)
 			}
 
 			// Log final metrics
@@ -111,12 +177,15 @@ func (s *Shipper) Start(ctx context.Context) error {
 
 		case <-ticker.C:
 			if err := s.flushWithContext(ctx); err != nil && err != context.Canceled {
-				logutil.Warn("Flush error: %v", err)
+				logger.Warn("Flush error: %v", err)
 			}
 		case <-s.flushCh:
 			if err := s.flushWithContext(ctx); err != nil && err != context.Canceled {
-				logutil.Warn("Flush error: %v", err)
+				logger.Warn("Flush error: %v", err)
 			}
+		case <-changed:
+			ticker.Reset(s.conf().FlushInterval)
+			changed = s.configChanged()
 		}
 	}
 }
@@ -134,13 +203,81 @@ func (s *Shipper) flushWithContext(ctx context.Context) error {
 	}
 
 	// Dequeue signals from database
-	signals, err := s.db.DequeueSignals(s.config.BatchSize)
+	signals, err := s.db.DequeueSignals(s.conf().BatchSize)
 	if err != nil {
 		return fmt.Errorf("failed to dequeue signals: %w", err)
 	}
 
+	s.sendBatch(ctx, signals)
+	return nil
+}
+
+// Flush resets the circuit breaker and ships queued signals until the queue
+// is empty or a batch has failures (which stay queued). Returns the number of
+// signals shipped. Used to retry immediately once an outage is over.
+func (s *Shipper) Flush(ctx context.Context) (int, error) {
+	s.circuitOpen.Store(false)
+	s.consecutiveFails.Store(0)
+
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	shipped := 0
+	for {
+		signals, err := s.db.DequeueSignals(s.conf().BatchSize)
+		if err != nil {
+			return shipped, fmt.Errorf("failed to dequeue signals: %w", err)
+		}
+		if len(signals) == 0 {
+			return shipped, nil
+		}
+		sent := s.sendBatch(ctx, signals)
+		shipped += sent
+		if sent < len(signals) {
+			return shipped, fmt.Errorf("%d of %d signals failed to ship", len(signals)-sent, len(signals))
+		}
+	}
+}
+
+// StartPriorityLane ships priority signals as soon as they are enqueued,
+// independently of the bulk flush loop
+func (s *Shipper) StartPriorityLane(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.priorityCh:
+			if err := s.flushPriority(ctx); err != nil && err != context.Canceled {
+				logger.Warn("Priority flush error: %v", err)
+			}
+		}
+	}
+}
+
+// flushPriority sends queued priority signals to the backend
+func (s *Shipper) flushPriority(ctx context.Context) error {
+	s.priorityMu.Lock()
+	defer s.priorityMu.Unlock()
+
+	// Leave signals queued for the bulk loop to retry once the circuit resets
+	if s.isCircuitOpen() {
+		return fmt.Errorf("circuit breaker open, skipping priority flush")
+	}
+
+	signals, err := s.db.DequeuePrioritySignals(s.conf().BatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to dequeue priority signals: %w", err)
+	}
+
+	s.sendBatch(ctx, signals)
+	return nil
+}
+
+// sendBatch sends signals concurrently, marking successes shipped and re-queueing failures.
+// Returns the number of signals shipped.
+func (s *Shipper) sendBatch(ctx context.Context, signals []*state.Signal) int {
 	if len(signals) == 0 {
-		return nil
+		return 0
 	}
 
 	// Use worker pool for concurrent sending
@@ -184,24 +321,24 @@ func (s *Shipper) flushWithContext(ctx context.Context) error {
 	successCount := 0
 	for res := range resultsCh {
 		if res.err != nil {
-			logutil.Error("Failed to send signal %s: %v", res.sig.ID, res.err)
+			logger.Error("Failed to send signal %s: %v", res.sig.ID, res.err)
 			s.failCount.Add(1)
 			s.recordFailure()
 
 			// Re-queue signal on failure, even for permanent errors, to avoid losing data.
 			if err := s.db.EnqueueSignal(res.sig); err != nil {
-				logutil.Error("Failed to re-queue signal: %v", err)
+				logger.Error("Failed to re-queue signal: %v", err)
 			} else {
 				s.requeueCount.Add(1)
 				if isPermanentError(res.err) {
-					logutil.Warn("Permanent error sending signal %s; keeping in queue for retry", res.sig.ID)
+					logger.Warn("Permanent error sending signal %s; keeping in queue for retry", res.sig.ID)
 				}
 			}
 		} else {
 			// Mark as shipped - this is done atomically with send
 			// so we don't mark shipped unless send succeeded
 			if err := s.db.MarkShipped(res.sig.ID); err != nil {
-				logutil.Error("Failed to mark signal as shipped: %v", err)
+				logger.Error("Failed to mark signal as shipped: %v", err)
 			} else {
 				successCount++
 				s.sentCount.Add(1)
@@ -212,13 +349,12 @@ func (s *Shipper) flushWithContext(ctx context.Context) error {
 
 	if successCount > 0 {
 		if successCount == len(signals) {
-			logutil.Success("Shipped %d signal%s", successCount, pluralize(successCount))
+			logger.Success("Shipped %d signal%s", successCount, pluralize(successCount))
 		} else {
-			logutil.Warn("Shipped %d/%d signals (some failed)", successCount, len(signals))
+			logger.Warn("Shipped %d/%d signals (some failed)", successCount, len(signals))
 		}
 	}
-
-	return nil
+	return successCount
 }
 
 // pluralize returns "s" if count is not 1, empty string otherwise
@@ -240,10 +376,27 @@ func min(a, b int) int {
 //
 
 // sendSignalWithContext sends a single signal to the backend with retry and context
-func (s *Shipper) sendSignalWithContext(ctx context.Context, sig *state.Signal) error {
+func (s *Shipper) sendSignalWithContext(ctx context.Context, sig *state.Signal) (err error) {
 	var lastErr error
+	maxAttempts := s.conf().Retry.MaxAttempts
+
+	// Continue the trace of the spool file the signal came from, so the time
+	// spent queued shows up between signal.generate and signal.ship
+	attempts := 0
+	if sig.TraceID != "" {
+		_, span := s.tracer.StartSpan(tracing.WithParent(ctx, sig.TraceID, sig.SpanID), "signal.ship",
+			tracing.String("signal.id", sig.ID),
+			tracing.String("rule.id", sig.RuleID),
+			tracing.Int64("signal.age_ms", time.Since(sig.TS).Milliseconds()))
+		defer func() {
+			span.SetAttr(tracing.Int("ship.attempts", attempts))
+			span.RecordError(err)
+			span.End()
+		}()
+	}
 
-	for attempt := 0; attempt < s.config.Retry.MaxAttempts; attempt++ {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		attempts = attempt + 1
 		// Check context before each attempt
 		select {
 		case <-ctx.Done():
@@ -262,7 +415,7 @@ func (s *Shipper) sendSignalWithContext(ctx context.Context, sig *state.Signal)
 				return ctx.Err()
 			}
 
-			logutil.Warn("Retry attempt %d/%d for signal %s", attempt+1, s.config.Retry.MaxAttempts, sig.ID)
+			logger.Warn("Retry attempt %d/%d for signal %s", attempt+1, maxAttempts, sig.ID)
 		}
 
 		// Try to send with context
@@ -281,7 +434,7 @@ func (s *Shipper) sendSignalWithContext(ctx context.Context, sig *state.Signal)
 		return nil
 	}
 
-	return fmt.Errorf("all %d retry attempts failed: %w", s.config.Retry.MaxAttempts, lastErr)
+	return fmt.Errorf("all %d retry attempts failed: %w", maxAttempts, lastErr)
 }
 
 //
@@ -298,15 +451,19 @@ func (s *Shipper) sendHTTPWithContext(ctx context.Context, sig *state.Signal) er
 		return &PermanentError{error: fmt.Errorf("failed to marshal signal: %w", err)}
 	}
 
-	// Create request with context (timeout already set in parent context)
-	req, err := http.NewRequestWithContext(ctx, "POST", s.config.Endpoint, bytes.NewReader(data))
+	cfg := s.conf()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Create request with context
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.Endpoint, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", s.config.APIKey)
+	req.Header.Set("X-API-Key", cfg.APIKey)
 	req.Header.Set("User-Agent", s.userAgent)
 
 	// Send request
@@ -340,10 +497,11 @@ func (s *Shipper) sendHTTPWithContext(ctx context.Context, sig *state.Signal) er
 
 // calculateBackoff calculates retry backoff delay
 func (s *Shipper) calculateBackoff(attempt int) time.Duration {
-	if s.config.Retry.Backoff == "linear" {
-		delay := s.config.Retry.Initial * time.Duration(attempt)
-		if delay > s.config.Retry.Max {
-			return s.config.Retry.Max
+	retry := s.conf().Retry
+	if retry.Backoff == "linear" {
+		delay := retry.Initial * time.Duration(attempt)
+		if delay > retry.Max {
+			return retry.Max
 		}
 		return delay
 	}
@@ -353,9 +511,9 @@ func (s *Shipper) calculateBackoff(attempt int) time.Duration {
 	if attempt > 10 {
 		attempt = 10
 	}
-	delay := s.config.Retry.Initial * time.Duration(1<<uint(attempt))
-	if delay > s.config.Retry.Max || delay < 0 { // Check for overflow
-		delay = s.config.Retry.Max
+	delay := retry.Initial * time.Duration(1<<uint(attempt))
+	if delay > retry.Max || delay < 0 { // Check for overflow
+		delay = retry.Max
 	}
 
 	return delay
@@ -373,8 +531,8 @@ func (s *Shipper) calculateBackoffWithJitter(attempt int) time.Duration {
 	if delay < 0 {
 		delay = 0
 	}
-	if delay > s.config.Retry.Max {
-		delay = s.config.Retry.Max
+	if maxDelay := s.conf().Retry.Max; delay > maxDelay {
+		delay = maxDelay
 	}
 
 	return delay
@@ -398,7 +556,7 @@ func (s *Shipper) isCircuitOpen() bool {
 		// Reset circuit breaker
 		s.circuitOpen.Store(false)
 		s.consecutiveFails.Store(0)
-		logutil.Info("Circuit breaker reset")
+		logger.Info("Circuit breaker reset")
 		return false
 	}
 
@@ -412,7 +570,7 @@ func (s *Shipper) recordFailure() {
 		if !s.circuitOpen.Load() {
 			s.circuitOpen.Store(true)
 			s.circuitOpenUntil.Store(time.Now().Add(circuitBreakerTimeout).Unix())
-			logutil.Warn("Circuit breaker opened after %d consecutive failures", fails)
+			logger.Warn("Circuit breaker opened after %d consecutive failures", fails)
 		}
 	}
 }
@@ -428,7 +586,59 @@ func (s *Shipper) logMetrics() {
 	failed := s.failCount.Load()
 	requeued := s.requeueCount.Load()
 
-	logutil.Info("Shipper metrics: sent=%d, failed=%d, requeued=%d", sent, failed, requeued)
+	logger.Info("Shipper metrics: sent=%d, failed=%d, requeued=%d", sent, failed, requeued)
+}
+
+// Health reports shipper connectivity based on the circuit breaker and recent send results
+func (s *Shipper) Health() error {
+	if s.isCircuitOpen() {
+		return fmt.Errorf("circuit breaker open after %d consecutive failures", s.consecutiveFails.Load())
+	}
+	if fails := s.consecutiveFails.Load(); fails > 0 {
+		return fmt.Errorf("%d consecutive send failures", fails)
+	}
+	return nil
+}
+
+// SetRulesVersion sets the rules version reported in heartbeats
+func (s *Shipper) SetRulesVersion(version string) {
+	s.rulesVersion.Store(&version)
+}
+
+// SetCoverageDegraded reports in heartbeats why detection work is being shed;
+// an empty reason clears it
+func (s *Shipper) SetCoverageDegraded(reason string) {
+	s.coverageDegraded.Store(&reason)
+}
+
+// SetSuppressions reports in heartbeats how many matches each rule's
+// exceptions have suppressed
+func (s *Shipper) SetSuppressions(counts map[string]int64) {
+	s.suppressions.Store(&counts)
+}
+
+// SetPruned reports in heartbeats how many state entries of each class were
+// removed past their retention
+func (s *Shipper) SetPruned(counts map[string]int64) {
+	s.pruned.Store(&counts)
+}
+
+// SetDisabledRules reports in heartbeats the rules disabled for exceeding
+// their evaluation budget, with the reason, by rule ID
+func (s *Shipper) SetDisabledRules(reasons map[string]string) {
+	s.disabledRules.Store(&reasons)
+}
+
+// SetTracer traces shipping of signals that carry a trace ID. It must be
+// called before Start.
+func (s *Shipper) SetTracer(tracer *tracing.Tracer) {
+	s.tracer = tracer
+}
+
+// SetInventory reports the machine inventory collected by c in heartbeats.
+// It must be called before StartHeartbeat.
+func (s *Shipper) SetInventory(c *inventory.Collector) {
+	s.inventory = c
 }
 
 // GetMetrics returns current metrics (for testing/monitoring)
@@ -475,6 +685,19 @@ func (s *Shipper) EnqueueSignal(sig *state.Signal) error {
 		// Signal was already shipped, skip
 		return nil
 	}
+	for _, k := range s.sinks {
+		k.offer(sig)
+	}
+
+	// Priority signals always go out immediately on their own lane
+	if sig.Priority {
+		select {
+		case s.priorityCh <- struct{}{}:
+		default:
+			// a priority flush is already pending
+		}
+		return nil
+	}
 
 	// Request an immediate flush (non-blocking)
 	if s.flushCh != nil {
@@ -494,19 +717,31 @@ type Heartbeat struct {
 	Version   string    `json:"version"`
 	OSVersion string    `json:"os_version"`
 	Uptime    float64   `json:"uptime_seconds,omitempty"`
+
+	RulesVersion     string `json:"rules_version,omitempty"`
+	CoverageDegraded string `json:"coverage_degraded,omitempty"` // Why detection work is being shed, if it is
+
+	Suppressions map[string]int64 `json:"suppressions,omitempty"` // Matches suppressed by exceptions, by rule ID
+	Pruned       map[string]int64 `json:"pruned,omitempty"`       // State entries removed past retention, by class
+	EventSeq     uint64           `json:"event_seq,omitempty"`    // Last event sequence number assigned
+
+	DisabledRules map[string]string `json:"disabled_rules,omitempty"` // Rules disabled for exceeding their budget: rule ID to reason
+
+	Inventory *inventory.Inventory `json:"inventory,omitempty"` // Latest machine inventory, when collected
 }
 
 // StartHeartbeat begins sending periodic heartbeat pings to the backend
 func (s *Shipper) StartHeartbeat(ctx context.Context) error {
-	if !s.config.Heartbeat.Enabled {
+	if !s.conf().Heartbeat.Enabled {
 		return nil // Heartbeat disabled
 	}
 
-	ticker := time.NewTicker(s.config.Heartbeat.Interval)
+	ticker := time.NewTicker(s.conf().Heartbeat.Interval)
 	defer ticker.Stop()
+	changed := s.configChanged()
 
 	startTime := time.Now()
-	logutil.Verbose("Heartbeat enabled: sending every %s", s.config.Heartbeat.Interval)
+	logger.Verbose("Heartbeat enabled: sending every %s", s.conf().Heartbeat.Interval)
 
 	for {
 		select {
@@ -514,8 +749,11 @@ func (s *Shipper) StartHeartbeat(ctx context.Context) error {
 			return ctx.Err()
 		case <-ticker.C:
 			if err := s.sendHeartbeat(ctx, startTime); err != nil {
-				logutil.Verbose("Heartbeat failed: %v", err)
+				logger.Verbose("Heartbeat failed: %v", err)
 			}
+		case <-changed:
+			ticker.Reset(s.conf().Heartbeat.Interval)
+			changed = s.configChanged()
 		}
 	}
 }
@@ -529,14 +767,44 @@ func (s *Shipper) sendHeartbeat(ctx context.Context, startTime time.Time) error
 		OSVersion: s.osVersion,
 		Uptime:    time.Since(startTime).Seconds(),
 	}
+	if v := s.rulesVersion.Load(); v != nil {
+		hb.RulesVersion = *v
+	}
+	if v := s.coverageDegraded.Load(); v != nil {
+		hb.CoverageDegraded = *v
+	}
+	if v := s.suppressions.Load(); v != nil {
+		hb.Suppressions = *v
+	}
+	if v := s.pruned.Load(); v != nil {
+		hb.Pruned = *v
+	}
+	if v := s.disabledRules.Load(); v != nil {
+		hb.DisabledRules = *v
+	}
+	if inv := s.inventory.Current(); inv != nil {
+		hb.Inventory = inv
+		if inv.OSVersion != "" {
+			hb.OSVersion = inv.OSVersion
+		}
+	}
+	if seq, err := s.db.EventSeq(); err == nil {
+		hb.EventSeq = seq
+	} else {
+		logger.Verbose("Failed to read event sequence number: %v", err)
+	}
 
 	data, err := json.Marshal(hb)
 	if err != nil {
 		return fmt.Errorf("failed to marshal heartbeat: %w", err)
 	}
 
+	cfg := s.conf()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Parse base URL and append /agents/heartbeat path
-	baseURL := s.config.Endpoint
+	baseURL := cfg.Endpoint
 	// Remove /ingest suffix if present
 	baseURL = strings.TrimSuffix(baseURL, "/ingest")
 	heartbeatURL := baseURL + "/agents/heartbeat"
@@ -547,7 +815,7 @@ func (s *Shipper) sendHeartbeat(ctx context.Context, startTime time.Time) error
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", s.config.APIKey)
+	req.Header.Set("X-API-Key", cfg.APIKey)
 	req.Header.Set("User-Agent", s.userAgent)
 
 	resp, err := s.httpClient.Do(req)
@@ -560,9 +828,109 @@ func (s *Shipper) sendHeartbeat(ctx context.Context, startTime time.Time) error
 	}()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		logutil.Verbose("Heartbeat sent successfully")
+		logger.Verbose("Heartbeat sent successfully")
 		return nil
 	}
 
 	return fmt.Errorf("heartbeat failed with status %d", resp.StatusCode)
 }
+
+// FleetSighting is the collector's fleet-wide view of a baseline pattern
+type FleetSighting struct {
+	Hosts     int       `json:"hosts"`      // Agents that have reported the pattern, including this one
+	FirstSeen time.Time `json:"first_seen"` // Earliest report across the fleet
+}
+
+// FleetFirstSeen reports a locally first-seen baseline pattern to the
+// collector and returns how common it already is across the fleet. It fails
+// fast while the circuit breaker is open so callers can alert without waiting.
+func (s *Shipper) FleetFirstSeen(ctx context.Context, ruleID, pattern string) (*FleetSighting, error) {
+	if s.isCircuitOpen() {
+		return nil, fmt.Errorf("circuit breaker open")
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"agent_id": s.agentID,
+		"rule_id":  ruleID,
+		"pattern":  pattern,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fleet first-seen query: %w", err)
+	}
+
+	cfg := s.conf()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	fleetURL := strings.TrimSuffix(cfg.Endpoint, "/ingest") + "/fleet/first-seen"
+	req, err := http.NewRequestWithContext(ctx, "POST", fleetURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fleet first-seen request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", cfg.APIKey)
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fleet first-seen request failed: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fleet first-seen failed with status %d", resp.StatusCode)
+	}
+
+	var sighting FleetSighting
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&sighting); err != nil {
+		return nil, fmt.Errorf("failed to decode fleet first-seen response: %w", err)
+	}
+	return &sighting, nil
+}
+
+// maxBaselineSeedSize caps baseline seed downloads
+const maxBaselineSeedSize = 64 << 20
+
+// FetchBaselineSeed downloads the baseline seed published on the collector
+// (GET /fleet/baseline-seed). It returns nil when none is published.
+func (s *Shipper) FetchBaselineSeed(ctx context.Context) ([]byte, error) {
+	cfg := s.conf()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	seedURL := strings.TrimSuffix(cfg.Endpoint, "/ingest") + "/fleet/baseline-seed"
+	req, err := http.NewRequestWithContext(ctx, "GET", seedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create baseline seed request: %w", err)
+	}
+	req.Header.Set("X-API-Key", cfg.APIKey)
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("baseline seed request failed: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("baseline seed request failed with status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBaselineSeedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline seed: %w", err)
+	}
+	if len(data) > maxBaselineSeedSize {
+		return nil, fmt.Errorf("baseline seed exceeds %d bytes", maxBaselineSeedSize)
+	}
+	return data, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -36,7 +37,7 @@ func TestNewShipper(t *testing.T) {
 	if s == nil {
 		t.Fatal("NewShipper returned nil")
 	}
-	if s.config != cfg {
+	if s.conf() != cfg {
 		t.Error("Config not set correctly")
 	}
 	if s.db != db {
@@ -242,6 +243,158 @@ func TestFlushRetainsPermanentFailures(t *testing.T) {
 	}
 }
 
+func TestFlushDrainsQueue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	cfg := testConfig(server.URL)
+	cfg.BatchSize = 2
+	s := NewShipper(cfg, db, "test-agent", "1.0.0")
+
+	for i := 0; i < 5; i++ {
+		if err := db.EnqueueSignal(&state.Signal{ID: fmt.Sprintf("sig-%d", i), RuleID: "RULE-001"}); err != nil {
+			t.Fatalf("Failed to enqueue signal: %v", err)
+		}
+	}
+
+	// A manual flush ships everything even while the circuit is open
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		s.recordFailure()
+	}
+	shipped, err := s.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if shipped != 5 {
+		t.Errorf("Flush() shipped %d signals, want 5", shipped)
+	}
+	if queued, _ := db.ListQueue(0); len(queued) != 0 {
+		t.Errorf("Expected empty queue after flush, got %d", len(queued))
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	newServer := func(name string, received chan<- string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- name + ":" + r.Header.Get("X-API-Key")
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	received := make(chan string, 10)
+	oldServer := newServer("old", received)
+	defer oldServer.Close()
+	newSrv := newServer("new", received)
+	defer newSrv.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	// Long flush interval so only the reload-triggered ticker reset can flush
+	cfg := testConfig(oldServer.URL)
+	cfg.FlushInterval = time.Hour
+	flushOff := false
+	cfg.FlushOnEnqueue = &flushOff
+	s := NewShipper(cfg, db, "test-agent", "1.0.0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Start(ctx) }()
+
+	updated := testConfig(newSrv.URL)
+	updated.APIKey = "rotated-key-1234567890"
+	updated.FlushInterval = 50 * time.Millisecond
+	s.UpdateConfig(updated)
+
+	if s.conf() != updated {
+		t.Fatal("Config was not swapped")
+	}
+
+	sig := &state.Signal{ID: "sig-1", RuleID: "RULE-001", Severity: "low"}
+	if err := s.EnqueueSignal(sig); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != "new:rotated-key-1234567890" {
+			t.Errorf("Signal sent with stale config: %s", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for flush with updated interval")
+	}
+}
+
+func TestPriorityLane(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sig state.Signal
+		_ = json.NewDecoder(r.Body).Decode(&sig)
+		received <- sig.ID
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	// Disable bulk flush-on-enqueue so only the priority lane can ship
+	flushOff := false
+	cfg := testConfig(server.URL)
+	cfg.FlushOnEnqueue = &flushOff
+	s := NewShipper(cfg, db, "test-agent", "1.0.0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.StartPriorityLane(ctx) }()
+
+	bulk := &state.Signal{ID: "bulk-1", RuleID: "RULE-001", Severity: "low"}
+	prio := &state.Signal{ID: "prio-1", RuleID: "RULE-002", Severity: "critical", Priority: true}
+	if err := s.EnqueueSignal(bulk); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+	if err := s.EnqueueSignal(prio); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+
+	select {
+	case id := <-received:
+		if id != "prio-1" {
+			t.Errorf("Expected priority signal to ship first, got %s", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for priority signal")
+	}
+
+	// MarkShipped runs after the response, so poll briefly
+	shipped := false
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline) && !shipped; {
+		shipped, _ = db.IsShipped("prio-1")
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !shipped {
+		t.Error("Expected priority signal to be marked shipped")
+	}
+
+	// Bulk signal stays queued for the regular flush loop
+	select {
+	case id := <-received:
+		t.Errorf("Unexpected send of %s on the priority lane", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+	queued, err := db.DequeueSignals(10)
+	if err != nil {
+		t.Fatalf("Failed to dequeue signals: %v", err)
+	}
+	if len(queued) != 1 || queued[0].ID != "bulk-1" {
+		t.Errorf("Expected bulk signal to remain queued, got %+v", queued)
+	}
+}
+
 func TestSendSignalContextCancellation(t *testing.T) {
 	// Create test server that delays
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -360,6 +513,30 @@ func TestCircuitBreakerTimeout(t *testing.T) {
 	}
 }
 
+func TestHealth(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	cfg := testConfig("https://test.example.com")
+	s := NewShipper(cfg, db, "test-agent", "1.0.0")
+
+	if err := s.Health(); err != nil {
+		t.Errorf("Expected healthy shipper, got %v", err)
+	}
+
+	s.recordFailure()
+	if err := s.Health(); err == nil {
+		t.Error("Expected unhealthy shipper after a failure")
+	}
+
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		s.recordFailure()
+	}
+	if err := s.Health(); err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+		t.Errorf("Expected circuit breaker error, got %v", err)
+	}
+}
+
 func TestGetMetrics(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() { _ = db.Close() }()
@@ -494,6 +671,101 @@ func TestIsPermanentError(t *testing.T) {
 
 // Helper functions
 
+func TestFleetFirstSeen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fleet/first-seen" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "test-key-1234567890" {
+			t.Error("Missing or incorrect API key")
+		}
+		var q map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			t.Errorf("Invalid JSON body: %v", err)
+		}
+		if q["agent_id"] != "test-agent" || q["rule_id"] != "B1" || q["pattern"] != "/bin/ls" {
+			t.Errorf("Unexpected query: %v", q)
+		}
+		_, _ = w.Write([]byte(`{"hosts": 7, "first_seen": "2026-01-02T03:04:05Z"}`))
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	s := NewShipper(testConfig(server.URL+"/ingest"), db, "test-agent", "1.0.0")
+	sighting, err := s.FleetFirstSeen(context.Background(), "B1", "/bin/ls")
+	if err != nil {
+		t.Fatalf("FleetFirstSeen failed: %v", err)
+	}
+	if sighting.Hosts != 7 || !sighting.FirstSeen.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected sighting: %+v", sighting)
+	}
+
+	// An open circuit fails immediately instead of waiting on the backend
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		s.recordFailure()
+	}
+	if _, err := s.FleetFirstSeen(context.Background(), "B1", "/bin/ls"); err == nil {
+		t.Error("Expected error while circuit is open")
+	}
+}
+
+func TestFleetFirstSeenServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	s := NewShipper(testConfig(server.URL), db, "test-agent", "1.0.0")
+	if _, err := s.FleetFirstSeen(context.Background(), "B1", "/bin/ls"); err == nil {
+		t.Error("Expected error for 404 response")
+	}
+}
+
+func TestFetchBaselineSeed(t *testing.T) {
+	published := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/fleet/baseline-seed" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "test-key-1234567890" {
+			t.Error("Missing or incorrect API key")
+		}
+		if !published {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version": 1, "patterns": []}`))
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	s := NewShipper(testConfig(server.URL+"/ingest"), db, "test-agent", "1.0.0")
+	data, err := s.FetchBaselineSeed(context.Background())
+	if err != nil {
+		t.Fatalf("FetchBaselineSeed failed: %v", err)
+	}
+	if string(data) != `{"version": 1, "patterns": []}` {
+		t.Errorf("Unexpected seed: %s", data)
+	}
+
+	// No published seed is not an error
+	published = false
+	data, err = s.FetchBaselineSeed(context.Background())
+	if err != nil {
+		t.Fatalf("FetchBaselineSeed failed: %v", err)
+	}
+	if data != nil {
+		t.Errorf("Expected no seed, got %s", data)
+	}
+}
+
 func setupTestDB(t *testing.T) *state.DB {
 	t.Helper()
 	dbPath := t.TempDir() + "/test.db"
@@ -0,0 +1,280 @@
+package shipper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/state"
+	"github.com/google/cel-go/cel"
+)
+
+// sinkQueueSize bounds the signals waiting for each sink; newer signals are
+// dropped while it is full
+const sinkQueueSize = 1000
+
+// transformStep applies one transform to a signal document. It returns false
+// when the signal should not be sent.
+type transformStep func(doc map[string]any) (bool, error)
+
+// Sink delivers a transformed copy of each new signal to an extra destination
+type Sink struct {
+	name    string
+	url     string
+	headers map[string]string
+	steps   []transformStep
+	queue   chan *state.Signal
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64 // Signals that did not fit in the queue
+}
+
+// NewSink compiles a sink's transform pipeline
+func NewSink(cfg config.SinkConfig) (*Sink, error) {
+	k := &Sink{
+		name:    cfg.Name,
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		queue:   make(chan *state.Signal, sinkQueueSize),
+	}
+	for i, t := range cfg.Transforms {
+		var step transformStep
+		var err error
+		switch {
+		case t.Filter != "":
+			step, err = filterStep(t.Filter)
+		case len(t.Drop) > 0:
+			step = dropStep(t.Drop)
+		case len(t.Rename) > 0:
+			step = renameStep(t.Rename)
+		case len(t.Labels) > 0:
+			step = labelsStep(t.Labels)
+		default:
+			err = fmt.Errorf("no step set")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: transforms[%d]: %w", cfg.Name, i, err)
+		}
+		k.steps = append(k.steps, step)
+	}
+	return k, nil
+}
+
+// Name returns the sink name
+func (k *Sink) Name() string {
+	return k.name
+}
+
+// Transform runs the pipeline over the signal's JSON form and returns the
+// document to send, or false if a filter rejected the signal
+func (k *Sink) Transform(sig *state.Signal) (map[string]any, bool, error) {
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal signal: %w", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false, fmt.Errorf("failed to decode signal: %w", err)
+	}
+	for _, step := range k.steps {
+		keep, err := step(doc)
+		if err != nil || !keep {
+			return nil, false, err
+		}
+	}
+	return doc, true, nil
+}
+
+// offer queues a signal without blocking
+func (k *Sink) offer(sig *state.Signal) {
+	select {
+	case k.queue <- sig:
+	default:
+		if k.dropped.Add(1) == 1 {
+			logger.Warn("Sink %s queue full, dropping signals", k.name)
+		}
+	}
+}
+
+// filterStep keeps signals for which the CEL expression over signal is true
+func filterStep(expr string) (transformStep, error) {
+	env, err := cel.NewEnv(cel.Variable("signal", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("filter: %w", issues.Err())
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("filter must return boolean, got %v", ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	return func(doc map[string]any) (bool, error) {
+		out, _, err := program.Eval(map[string]any{"signal": doc})
+		if err != nil {
+			return false, fmt.Errorf("filter: %w", err)
+		}
+		keep, _ := out.Value().(bool)
+		return keep, nil
+	}, nil
+}
+
+func dropStep(paths []string) transformStep {
+	return func(doc map[string]any) (bool, error) {
+		for _, path := range paths {
+			deletePath(doc, path)
+		}
+		return true, nil
+	}
+}
+
+// renameStep moves fields in sorted order of their old path, so pipelines
+// are deterministic
+func renameStep(renames map[string]string) transformStep {
+	from := slices.Sorted(maps.Keys(renames))
+	return func(doc map[string]any) (bool, error) {
+		for _, old := range from {
+			if v, ok := deletePath(doc, old); ok {
+				setPath(doc, renames[old], v)
+			}
+		}
+		return true, nil
+	}
+}
+
+func labelsStep(labels map[string]string) transformStep {
+	paths := slices.Sorted(maps.Keys(labels))
+	return func(doc map[string]any) (bool, error) {
+		for _, path := range paths {
+			setPath(doc, path, labels[path])
+		}
+		return true, nil
+	}
+}
+
+// deletePath removes a dotted path and returns the removed value
+func deletePath(doc map[string]any, path string) (any, bool) {
+	parts := strings.Split(path, ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	last := parts[len(parts)-1]
+	v, ok := m[last]
+	delete(m, last)
+	return v, ok
+}
+
+// setPath sets a dotted path, replacing non-object values along the way
+func setPath(doc map[string]any, path string, v any) {
+	parts := strings.Split(path, ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = v
+}
+
+// SetSinks sets the extra destinations that receive new signals. It must be
+// called before signals are enqueued.
+func (s *Shipper) SetSinks(sinks []*Sink) {
+	s.sinks = sinks
+}
+
+// StartSinks delivers queued signals to each sink until ctx is done
+func (s *Shipper) StartSinks(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, k := range s.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runSink(ctx, k)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (s *Shipper) runSink(ctx context.Context, k *Sink) {
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Sink %s metrics: sent=%d, failed=%d, dropped=%d", k.name, k.sent.Load(), k.failed.Load(), k.dropped.Load())
+			return
+		case sig := <-k.queue:
+			doc, keep, err := k.Transform(sig)
+			if err != nil {
+				k.failed.Add(1)
+				logger.Warn("Sink %s: signal %s: %v", k.name, sig.ID, err)
+				continue
+			}
+			if !keep {
+				continue
+			}
+			if err := s.sendToSink(ctx, k, doc); err != nil {
+				k.failed.Add(1)
+				logger.Warn("Sink %s: failed to send signal %s: %v", k.name, sig.ID, err)
+				continue
+			}
+			k.sent.Add(1)
+		}
+	}
+}
+
+// sendToSink posts a transformed signal to a sink
+func (s *Shipper) sendToSink(ctx context.Context, k *Sink, doc map[string]any) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", k.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	for name, value := range k.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
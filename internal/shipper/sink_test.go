@@ -0,0 +1,150 @@
+package shipper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+func testSinkSignal(severity string) *state.Signal {
+	return &state.Signal{
+		ID:       "sig-" + severity,
+		TS:       time.Now(),
+		HostID:   "host-1",
+		RuleID:   "SM-001",
+		Severity: severity,
+		Title:    "Test",
+		Context: map[string]any{
+			"args": []string{"curl", "-H", "Authorization: secret"},
+			"user": "alice",
+		},
+	}
+}
+
+func TestSinkTransform(t *testing.T) {
+	sink, err := NewSink(config.SinkConfig{
+		Name: "chatops",
+		URL:  "https://hooks.example.com",
+		Transforms: []config.TransformConfig{
+			{Filter: `signal.severity in ["high", "critical"]`},
+			{Drop: []string{"context.args", "tags", "context.missing.deep"}},
+			{Rename: map[string]string{"context.user": "user", "title": "text"}},
+			{Labels: map[string]string{"channel": "#alerts", "meta.source": "santamon"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	doc, keep, err := sink.Transform(testSinkSignal("high"))
+	if err != nil || !keep {
+		t.Fatalf("Transform() = %v, %v; want kept", keep, err)
+	}
+	if _, ok := doc["context"].(map[string]any)["args"]; ok {
+		t.Error("context.args not dropped")
+	}
+	if _, ok := doc["tags"]; ok {
+		t.Error("tags not dropped")
+	}
+	if doc["user"] != "alice" || doc["text"] != "Test" {
+		t.Errorf("Fields not renamed: %v", doc)
+	}
+	if _, ok := doc["title"]; ok {
+		t.Error("title still present after rename")
+	}
+	if doc["channel"] != "#alerts" || doc["meta"].(map[string]any)["source"] != "santamon" {
+		t.Errorf("Labels not set: %v", doc)
+	}
+
+	if _, keep, err := sink.Transform(testSinkSignal("low")); err != nil || keep {
+		t.Errorf("Transform(low) = %v, %v; want filtered out", keep, err)
+	}
+}
+
+func TestNewSinkInvalidFilter(t *testing.T) {
+	tests := []struct {
+		filter  string
+		wantErr string
+	}{
+		{filter: `signal.severity ==`, wantErr: "filter"},
+		{filter: `signal.severity`, wantErr: "must return boolean"},
+	}
+	for _, tt := range tests {
+		_, err := NewSink(config.SinkConfig{
+			Name:       "chat",
+			URL:        "https://hooks.example.com",
+			Transforms: []config.TransformConfig{{Filter: tt.filter}},
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.filter, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSinkDelivery(t *testing.T) {
+	received := make(chan map[string]any, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Error("Missing sink header")
+		}
+		var doc map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			t.Errorf("Invalid JSON body: %v", err)
+		}
+		received <- doc
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	s := NewShipper(testConfig("https://backend.example.com"), db, "test-agent", "1.0.0")
+	sink, err := NewSink(config.SinkConfig{
+		Name:       "chatops",
+		URL:        server.URL,
+		Headers:    map[string]string{"Authorization": "Bearer token"},
+		Transforms: []config.TransformConfig{{Filter: `signal.severity == "high"`}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	s.SetSinks([]*Sink{sink})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.StartSinks(ctx) }()
+
+	for _, sig := range []*state.Signal{testSinkSignal("low"), testSinkSignal("high")} {
+		if err := s.EnqueueSignal(sig); err != nil {
+			t.Fatalf("Failed to enqueue signal: %v", err)
+		}
+	}
+
+	select {
+	case doc := <-received:
+		if doc["signal_id"] != "sig-high" {
+			t.Errorf("Unexpected signal delivered: %v", doc["signal_id"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for sink delivery")
+	}
+	cancel()
+	<-done
+
+	// The signal still goes to the backend queue
+	if pending, err := db.DequeueSignals(10); err != nil || len(pending) != 2 {
+		t.Errorf("Expected 2 pending signals, got %d (%v)", len(pending), err)
+	}
+	select {
+	case doc := <-received:
+		t.Errorf("Filtered signal delivered: %v", doc["signal_id"])
+	default:
+	}
+}
@@ -0,0 +1,153 @@
+package shipper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// metaTriageCursor holds the cursor of the last triage page applied, so a
+// restart resumes where it left off
+const metaTriageCursor = "triage_cursor"
+
+// TriageUpdate is a status change of a shipped signal made on the backend
+type TriageUpdate struct {
+	SignalID string    `json:"signal_id"`
+	RuleID   string    `json:"rule_id,omitempty"`
+	Status   string    `json:"status"` // open, acknowledged or closed
+	TS       time.Time `json:"ts"`
+	By       string    `json:"by,omitempty"`
+	Note     string    `json:"note,omitempty"`
+}
+
+// triagePage is the response of GET /signals/triage
+type triagePage struct {
+	Updates []TriageUpdate `json:"updates"`
+	Cursor  string         `json:"cursor"` // Passed back to get the updates after this page
+	More    bool           `json:"more"`   // Another page is ready now
+}
+
+// FetchTriage returns the triage updates of this agent's signals made after
+// cursor (GET /signals/triage), the cursor to resume from, and whether more
+// updates are ready
+func (s *Shipper) FetchTriage(ctx context.Context, cursor string) ([]TriageUpdate, string, bool, error) {
+	cfg := s.conf()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := url.Values{"agent_id": {s.agentID}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	triageURL := strings.TrimSuffix(cfg.Endpoint, "/ingest") + "/signals/triage?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", triageURL, nil)
+	if err != nil {
+		return nil, cursor, false, fmt.Errorf("failed to create triage request: %w", err)
+	}
+	req.Header.Set("X-API-Key", cfg.APIKey)
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, cursor, false, fmt.Errorf("triage request failed: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, cursor, false, fmt.Errorf("triage request failed with status %d", resp.StatusCode)
+	}
+	var page triagePage
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(&page); err != nil {
+		return nil, cursor, false, fmt.Errorf("failed to decode triage response: %w", err)
+	}
+	if page.Cursor == "" {
+		page.Cursor = cursor
+	}
+	return page.Updates, page.Cursor, page.More, nil
+}
+
+// StartTriageSync polls the backend for signals analysts acknowledged or
+// closed and records the changes in the state DB, where dedup and aggregation
+// use them to stop emitting updates for closed signals
+func (s *Shipper) StartTriageSync(ctx context.Context) error {
+	if !s.conf().Triage.Enabled {
+		return nil
+	}
+
+	ticker := time.NewTicker(s.conf().Triage.Interval)
+	defer ticker.Stop()
+	changed := s.configChanged()
+	logger.Verbose("Triage sync enabled: polling every %s", s.conf().Triage.Interval)
+
+	for {
+		if _, err := s.SyncTriage(ctx); err != nil && ctx.Err() == nil {
+			logger.Verbose("Triage sync failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-changed:
+			ticker.Reset(s.conf().Triage.Interval)
+			changed = s.configChanged()
+		}
+	}
+}
+
+// SyncTriage applies all triage updates ready on the backend and returns how
+// many changed a signal's status
+func (s *Shipper) SyncTriage(ctx context.Context) (int, error) {
+	cursor, err := s.db.GetMeta(metaTriageCursor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read triage cursor: %w", err)
+	}
+	applied := 0
+	for {
+		updates, next, more, err := s.FetchTriage(ctx, cursor)
+		if err != nil {
+			return applied, err
+		}
+		for _, u := range updates {
+			if u.SignalID == "" {
+				continue
+			}
+			if u.TS.IsZero() {
+				u.TS = time.Now()
+			}
+			ok, err := s.db.SetTriage(u.SignalID, u.RuleID, state.TriageTransition{Status: u.Status, TS: u.TS, By: u.By, Note: u.Note})
+			if err != nil {
+				logger.Verbose("Ignoring triage update for signal %s: %v", u.SignalID, err)
+				continue
+			}
+			if ok {
+				applied++
+				if u.By != "" {
+					logger.Info("Signal %s %s by %s", u.SignalID, u.Status, u.By)
+				} else {
+					logger.Info("Signal %s %s", u.SignalID, u.Status)
+				}
+			}
+		}
+		if next != cursor {
+			if err := s.db.SetMeta(metaTriageCursor, next); err != nil {
+				return applied, fmt.Errorf("failed to save triage cursor: %w", err)
+			}
+			cursor = next
+		} else {
+			more = false // The backend would return the same page again
+		}
+		if !more {
+			return applied, nil
+		}
+	}
+}
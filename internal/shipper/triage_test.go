@@ -0,0 +1,78 @@
+package shipper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+func TestSyncTriage(t *testing.T) {
+	ts := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	pages := map[string]triagePage{
+		"": {
+			Updates: []TriageUpdate{
+				{SignalID: "sig-1", RuleID: "R1", Status: state.StatusAcknowledged, TS: ts, By: "alice"},
+				{SignalID: "sig-2", RuleID: "R2", Status: "resolved", TS: ts},
+			},
+			Cursor: "c1",
+			More:   true,
+		},
+		"c1": {
+			Updates: []TriageUpdate{{SignalID: "sig-1", RuleID: "R1", Status: state.StatusClosed, TS: ts.Add(time.Minute), By: "alice", Note: "benign"}},
+			Cursor:  "c2",
+		},
+		"c2": {Cursor: "c2"},
+	}
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/signals/triage" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "test-key-1234567890" {
+			t.Error("Missing or incorrect API key")
+		}
+		if r.URL.Query().Get("agent_id") != "test-agent" {
+			t.Errorf("agent_id = %q", r.URL.Query().Get("agent_id"))
+		}
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		_ = json.NewEncoder(w).Encode(pages[cursor])
+	}))
+	defer server.Close()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	s := NewShipper(testConfig(server.URL+"/ingest"), db, "test-agent", "1.0.0")
+	applied, err := s.SyncTriage(context.Background())
+	if err != nil {
+		t.Fatalf("SyncTriage failed: %v", err)
+	}
+	if applied != 2 {
+		t.Errorf("applied = %d, want 2 (the invalid status is skipped)", applied)
+	}
+	rec, err := db.Triage("sig-1")
+	if err != nil || rec == nil {
+		t.Fatalf("Triage(sig-1) = %v, %v", rec, err)
+	}
+	if rec.Status != state.StatusClosed || len(rec.Transitions) != 2 || rec.Transitions[1].Note != "benign" {
+		t.Errorf("Unexpected triage record: %+v", rec)
+	}
+	if status, _ := db.SignalStatus("sig-2"); status != state.StatusOpen {
+		t.Errorf("sig-2 status = %q, want open", status)
+	}
+
+	// The cursor is persisted, so the next sync resumes after the last page
+	cursors = nil
+	if _, err := s.SyncTriage(context.Background()); err != nil {
+		t.Fatalf("SyncTriage failed: %v", err)
+	}
+	if len(cursors) != 1 || cursors[0] != "c2" {
+		t.Errorf("Second sync requested cursors %q, want [c2]", cursors)
+	}
+}
@@ -0,0 +1,194 @@
+package signals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/reputation"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+	"github.com/0x4d31/santamon/internal/yara"
+)
+
+// FollowUps runs slow enrichments, hash reputation lookups and YARA scans of
+// execution targets, off the detection loop. Signals ship as generated; a
+// bounded pool of workers enriches a copy and hands it to emit as a
+// follow-up signal when a lookup flagged the target or a scan matched.
+// Signals submitted while the queue is full are not enriched.
+type FollowUps struct {
+	queue chan followUp
+	emit  func(*state.Signal)
+
+	reputation reputation.Provider // Optional hash reputation lookup
+
+	yara            yara.Provider // Optional YARA scan of execution targets
+	yaraMinSeverity string        // Only signals at least this severe are scanned
+}
+
+type followUp struct {
+	sig *state.Signal
+	msg *santapb.SantaMessage
+}
+
+// NewFollowUps creates a stage that queues up to queueSize signals and passes
+// their follow-ups to emit, which must be safe to call from several workers
+func NewFollowUps(queueSize int, emit func(*state.Signal)) *FollowUps {
+	return &FollowUps{queue: make(chan followUp, queueSize), emit: emit}
+}
+
+// SetReputation enables reputation_* enrichment of execution signals from p
+// (nil disables it)
+func (f *FollowUps) SetReputation(p reputation.Provider) {
+	f.reputation = p
+}
+
+// SetYARA enables yara_matches enrichment of execution signals at least as
+// severe as minSeverity from p (nil disables it)
+func (f *FollowUps) SetYARA(p yara.Provider, minSeverity string) {
+	f.yara = p
+	f.yaraMinSeverity = minSeverity
+}
+
+// Submit queues sig, generated for msg, for enrichment if it needs any. It
+// returns false when the queue is full and the signal is not enriched.
+func (f *FollowUps) Submit(sig *state.Signal, msg *santapb.SantaMessage) bool {
+	if f == nil || !f.wants(sig, msg) {
+		return true
+	}
+	// Snapshot the context: the caller keeps using the signal
+	copied := *sig
+	copied.Context = maps.Clone(sig.Context)
+	select {
+	case f.queue <- followUp{sig: &copied, msg: msg}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run enriches queued signals on workers until ctx is done
+func (f *FollowUps) Run(ctx context.Context, workers int) error {
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-f.queue:
+					f.followUp(ctx, job)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// followUp enriches a copy of a shipped signal and emits it when the
+// enrichment found something
+func (f *FollowUps) followUp(ctx context.Context, job followUp) {
+	sig := job.sig.Derive(job.sig.ID+"|followup", time.Now())
+	sig.Context = job.sig.Context // Already a copy, see Submit
+	if !f.Enrich(ctx, sig, job.msg) {
+		return
+	}
+	sig.Context["follow_up_of"] = job.sig.ID
+	f.emit(sig)
+}
+
+// Enrich adds the enrichments sig needs to its context in place and reports
+// whether they found something worth a follow-up: antivirus detections or
+// YARA matches
+func (f *FollowUps) Enrich(ctx context.Context, sig *state.Signal, msg *santapb.SantaMessage) bool {
+	if f == nil {
+		return false
+	}
+	if sig.Context == nil {
+		sig.Context = make(map[string]any)
+	}
+	flagged := f.appendReputation(ctx, sig.Context, msg)
+	matched := f.appendYARA(ctx, sig.Context, msg, sig.Severity)
+	return flagged || matched
+}
+
+// wants reports whether any enrichment applies to sig
+func (f *FollowUps) wants(sig *state.Signal, msg *santapb.SantaMessage) bool {
+	if _, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); !ok {
+		return false
+	}
+	return f.reputation != nil || f.scansYARA(sig.Severity)
+}
+
+func (f *FollowUps) scansYARA(severity string) bool {
+	return f.yara != nil && rules.SeverityAtLeast(severity, f.yaraMinSeverity)
+}
+
+// appendReputation adds the detection ratio of an execution's target. Hashes
+// the service does not know get reputation_known false; lookups that fail or
+// are rate limited add nothing. It reports whether any engine flagged the
+// target.
+func (f *FollowUps) appendReputation(ctx context.Context, sigCtx map[string]any, msg *santapb.SantaMessage) bool {
+	if f.reputation == nil {
+		return false
+	}
+	if _, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); !ok {
+		return false
+	}
+	hash := events.TargetSHA256(msg)
+	if hash == "" {
+		return false
+	}
+	result, err := f.reputation.Lookup(ctx, hash)
+	if errors.Is(err, reputation.ErrRateLimited) {
+		logger.Verbose("Reputation lookup for %s skipped: %v", hash, err)
+		return false
+	}
+	if err != nil {
+		logger.Warn("Reputation lookup failed for %s: %v", hash, err)
+		return false
+	}
+	sigCtx["reputation_known"] = result != nil
+	if result == nil {
+		return false
+	}
+	sigCtx["reputation_malicious"] = result.Malicious
+	sigCtx["reputation_total"] = result.Total
+	sigCtx["reputation_detections"] = fmt.Sprintf("%d/%d", result.Malicious, result.Total)
+	return result.Malicious > 0
+}
+
+// appendYARA adds the YARA rules matching the executable of a severe enough
+// execution's target; clean files get an empty list. Scans that fail or are
+// skipped for size or concurrency add nothing. It reports whether any rule
+// matched.
+func (f *FollowUps) appendYARA(ctx context.Context, sigCtx map[string]any, msg *santapb.SantaMessage, severity string) bool {
+	if !f.scansYARA(severity) {
+		return false
+	}
+	if _, ok := msg.GetEvent().(*santapb.SantaMessage_Execution); !ok {
+		return false
+	}
+	path := events.TargetPath(msg)
+	if path == "" {
+		return false
+	}
+	matches, err := f.yara.Scan(ctx, path, events.TargetSHA256(msg))
+	if errors.Is(err, yara.ErrBusy) || errors.Is(err, yara.ErrTooLarge) || errors.Is(err, os.ErrNotExist) {
+		logger.Verbose("YARA scan of %s skipped: %v", path, err)
+		return false
+	}
+	if err != nil {
+		logger.Warn("YARA scan failed for %s: %v", path, err)
+		return false
+	}
+	sigCtx["yara_matches"] = matches
+	return len(matches) > 0
+}
@@ -0,0 +1,207 @@
+package signals
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/baseline"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/reputation"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+	"github.com/0x4d31/santamon/internal/yara"
+)
+
+// stubReputation returns fixed results; hashes without one are unknown
+type stubReputation struct {
+	results map[string]*reputation.Result
+	err     error
+	lookups int
+}
+
+func (s *stubReputation) Lookup(_ context.Context, sha256 string) (*reputation.Result, error) {
+	s.lookups++
+	return s.results[sha256], s.err
+}
+
+func TestReputationEnrichment(t *testing.T) {
+	const hash = "6f3c2a5b4e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b"
+	exec := func(sha string) *santapb.SantaMessage {
+		msg := extraContextMessage()
+		msg.GetExecution().Target.Executable.Hash = &santapb.Hash{Hash: proto.String(sha)}
+		return msg
+	}
+
+	rep := &stubReputation{results: map[string]*reputation.Result{
+		hash:                    {Malicious: 3, Total: 64},
+		strings.Repeat("1", 64): {Malicious: 0, Total: 64},
+	}}
+	f := NewFollowUps(1, nil)
+	f.SetReputation(rep)
+	gen := NewGenerator("test-host", nil)
+	ctx := context.Background()
+	enrich := func(msg *santapb.SantaMessage) (*state.Signal, bool) {
+		sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg})
+		flagged := f.Enrich(ctx, sig, msg)
+		return sig, flagged
+	}
+
+	sig, flagged := enrich(exec(hash))
+	if !flagged {
+		t.Error("Enrich() = false for a flagged hash")
+	}
+	want := map[string]any{
+		"reputation_known":      true,
+		"reputation_malicious":  3,
+		"reputation_total":      64,
+		"reputation_detections": "3/64",
+	}
+	for k, v := range want {
+		if sig.Context[k] != v {
+			t.Errorf("context[%q] = %v, want %v", k, sig.Context[k], v)
+		}
+	}
+
+	// Clean and unknown hashes are no reason for a follow-up
+	if sig, flagged := enrich(exec(strings.Repeat("1", 64))); flagged || sig.Context["reputation_detections"] != "0/64" {
+		t.Errorf("Clean hash: %v, %v", flagged, sig.Context)
+	}
+	sig, flagged = enrich(exec(strings.Repeat("0", 64)))
+	if flagged || sig.Context["reputation_known"] != false {
+		t.Errorf("reputation_known = %v, want false", sig.Context["reputation_known"])
+	}
+	if _, ok := sig.Context["reputation_detections"]; ok {
+		t.Error("Detections set for an unknown hash")
+	}
+
+	// Only execution events with a hash are looked up
+	rep.lookups = 0
+	enrich(extraContextMessage())
+	enrich(&santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}}})
+	if rep.lookups != 0 {
+		t.Errorf("Expected no lookups, got %d", rep.lookups)
+	}
+
+	// Failed and rate-limited lookups add nothing
+	for _, err := range []error{reputation.ErrRateLimited, errors.New("timeout")} {
+		rep.err = err
+		if sig, _ := enrich(exec(hash)); sig.Context["reputation_known"] != nil {
+			t.Errorf("%v: reputation_known set", err)
+		}
+	}
+}
+
+// stubYARA returns fixed matches and records the scanned paths
+type stubYARA struct {
+	matches []string
+	err     error
+	scanned []string
+}
+
+func (s *stubYARA) Scan(_ context.Context, path, _ string) ([]string, error) {
+	s.scanned = append(s.scanned, path)
+	return s.matches, s.err
+}
+
+func TestYARAEnrichment(t *testing.T) {
+	scanner := &stubYARA{matches: []string{"Generic_Packer", "Mal_Loader"}}
+	f := NewFollowUps(1, nil)
+	f.SetYARA(scanner, rules.SeverityHigh)
+	gen := NewGenerator("test-host", nil)
+	ctx := context.Background()
+
+	msg := extraContextMessage()
+	sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg, Severity: rules.SeverityCritical})
+	if !f.Enrich(ctx, sig, msg) {
+		t.Error("Enrich() = false for a YARA match")
+	}
+	if got, ok := sig.Context["yara_matches"].([]string); !ok || !slices.Equal(got, scanner.matches) {
+		t.Errorf("yara_matches = %v", sig.Context["yara_matches"])
+	}
+	if len(scanner.scanned) != 1 || scanner.scanned[0] != events.TargetPath(msg) {
+		t.Errorf("Scanned %v, want the target executable", scanner.scanned)
+	}
+	sig = gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: msg, Severity: rules.SeverityHigh})
+	if f.Enrich(ctx, sig, msg); sig.Context["yara_matches"] == nil {
+		t.Error("Baseline signal was not scanned")
+	}
+
+	// Less severe signals and other events are not scanned
+	scanner.scanned = nil
+	fork := &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}}}
+	f.Enrich(ctx, gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg, Severity: rules.SeverityMedium}), msg)
+	f.Enrich(ctx, gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Severity: rules.SeverityHigh, Message: fork}), fork)
+	if len(scanner.scanned) != 0 {
+		t.Errorf("Expected no scans, got %v", scanner.scanned)
+	}
+
+	// Clean files are listed but are no reason for a follow-up
+	scanner.matches = []string{}
+	sig = gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg, Severity: rules.SeverityHigh})
+	if f.Enrich(ctx, sig, msg) || sig.Context["yara_matches"] == nil {
+		t.Errorf("Clean scan: yara_matches = %v", sig.Context["yara_matches"])
+	}
+
+	// Skipped and failed scans add nothing
+	for _, err := range []error{yara.ErrBusy, yara.ErrTooLarge, errors.New("yara failed")} {
+		scanner.err = err
+		sig = gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg, Severity: rules.SeverityHigh})
+		f.Enrich(ctx, sig, msg)
+		if _, ok := sig.Context["yara_matches"]; ok {
+			t.Errorf("%v: yara_matches set", err)
+		}
+	}
+}
+
+func TestFollowUps(t *testing.T) {
+	emitted := make(chan *state.Signal, 1)
+	f := NewFollowUps(1, func(sig *state.Signal) { emitted <- sig })
+	f.SetYARA(&stubYARA{matches: []string{"Mal_Loader"}}, rules.SeverityHigh)
+	gen := NewGenerator("test-host", nil)
+	msg := extraContextMessage()
+
+	// Signals that need no enrichment are not queued
+	if !f.Submit(gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg, Severity: rules.SeverityLow}), msg) {
+		t.Error("Submit() of a low-severity signal failed")
+	}
+	sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: msg, Severity: rules.SeverityHigh})
+	if !f.Submit(sig, msg) {
+		t.Fatal("Submit() failed")
+	}
+	// The queue holds one signal; the rest are not enriched
+	if f.Submit(sig, msg) {
+		t.Error("Submit() to a full queue succeeded")
+	}
+	sig.Context["changed_after_submit"] = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.Run(ctx, 2) }()
+	follow := <-emitted
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+
+	if follow.ID == sig.ID || follow.Context["follow_up_of"] != sig.ID {
+		t.Errorf("Follow-up %s of %v, want a new ID following up %s", follow.ID, follow.Context["follow_up_of"], sig.ID)
+	}
+	if got, _ := follow.Context["yara_matches"].([]string); !slices.Equal(got, []string{"Mal_Loader"}) {
+		t.Errorf("yara_matches = %v", follow.Context["yara_matches"])
+	}
+	if _, ok := follow.Context["changed_after_submit"]; ok {
+		t.Error("Follow-up saw a change made after Submit()")
+	}
+	if _, ok := sig.Context["yara_matches"]; ok {
+		t.Error("Original signal was changed")
+	}
+	if follow.RuleID != sig.RuleID || follow.Severity != sig.Severity || follow.Priority {
+		t.Errorf("Follow-up = %+v", follow)
+	}
+}
@@ -0,0 +1,100 @@
+package signals
+
+import (
+	"fmt"
+	"strings"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+
+	"github.com/0x4d31/santamon/internal/events"
+)
+
+// kindContext adds the details of event kinds the generic message context
+// doesn't cover, so their signals are actionable without include_event.
+// Extractors are keyed by events.Kind.
+var kindContext = map[string]func(ctx map[string]any, msg *santapb.SantaMessage){
+	"launch_item":         launchItemContext,
+	"tcc_modification":    tccContext,
+	"gatekeeper_override": gatekeeperContext,
+	"disk":                diskContext,
+	"authentication":      authenticationContext,
+}
+
+// appendKindContext adds the kind-specific context of msg
+func appendKindContext(ctx map[string]any, msg *santapb.SantaMessage) {
+	if extract := kindContext[events.Kind(msg)]; extract != nil {
+		extract(ctx, msg)
+	}
+}
+
+func launchItemContext(ctx map[string]any, msg *santapb.SantaMessage) {
+	item := msg.GetLaunchItem()
+	putString(ctx, "item_path", item.GetItemPath())
+	putString(ctx, "item_executable", item.GetExecutablePath())
+	putString(ctx, "item_user", item.GetItemUser().GetName())
+	ctx["item_type"] = enumValue(item.GetItemType(), "ITEM_TYPE_")
+	ctx["item_action"] = enumValue(item.GetAction(), "ACTION_")
+	ctx["item_legacy"] = item.GetLegacy()
+	ctx["item_managed"] = item.GetManaged()
+}
+
+func tccContext(ctx map[string]any, msg *santapb.SantaMessage) {
+	tcc := msg.GetTccModification()
+	putString(ctx, "tcc_service", tcc.GetService())
+	putString(ctx, "tcc_identity", tcc.GetIdentity())
+	ctx["tcc_identity_type"] = enumValue(tcc.GetIdentityType(), "IDENTITY_TYPE_")
+	ctx["tcc_event_type"] = enumValue(tcc.GetEventType(), "EVENT_TYPE_")
+	ctx["tcc_right"] = enumValue(tcc.GetAuthorizationRight(), "AUTHORIZATION_RIGHT_")
+	ctx["tcc_reason"] = enumValue(tcc.GetAuthorizationReason(), "AUTHORIZATION_REASON_")
+}
+
+func gatekeeperContext(ctx map[string]any, msg *santapb.SantaMessage) {
+	target := msg.GetGatekeeperOverride().GetTarget()
+	putString(ctx, "override_path", target.GetPath())
+	putString(ctx, "override_sha256", target.GetHash().GetHash())
+}
+
+func diskContext(ctx map[string]any, msg *santapb.SantaMessage) {
+	disk := msg.GetDisk()
+	ctx["disk_action"] = enumValue(disk.GetAction(), "ACTION_")
+	putString(ctx, "mount_point", disk.GetMount())
+	putString(ctx, "volume", disk.GetVolume())
+	putString(ctx, "bsd_name", disk.GetBsdName())
+	putString(ctx, "filesystem", disk.GetFs())
+	putString(ctx, "disk_bus", disk.GetBus())
+	putString(ctx, "disk_model", disk.GetModel())
+	putString(ctx, "disk_serial", disk.GetSerial())
+}
+
+// authenticationContext adds the authentication method; the outcome and
+// account are added with the message context (see events.AuthSuccess)
+func authenticationContext(ctx map[string]any, msg *santapb.SantaMessage) {
+	auth := msg.GetAuthentication()
+	m := auth.ProtoReflect()
+	if fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("event")); fd != nil {
+		ctx["auth_type"] = strings.TrimPrefix(string(fd.Name()), "authentication_")
+	}
+	if od := auth.GetAuthenticationOd(); od != nil {
+		putString(ctx, "auth_record_type", od.GetRecordType())
+		putString(ctx, "auth_node", od.GetNodeName())
+	}
+	if touchID := auth.GetAuthenticationTouchId(); touchID != nil {
+		ctx["auth_mode"] = enumValue(touchID.GetMode(), "MODE_")
+	}
+	if unlock := auth.GetAuthenticationAutoUnlock(); unlock != nil {
+		ctx["auth_mode"] = enumValue(unlock.GetType(), "TYPE_")
+	}
+}
+
+// putString sets ctx[key] to v unless v is empty
+func putString(ctx map[string]any, key, v string) {
+	if v != "" {
+		ctx[key] = v
+	}
+}
+
+// enumValue returns a Santa enum value without its prefix, lowercased, so
+// ITEM_TYPE_AGENT becomes agent
+func enumValue(v fmt.Stringer, prefix string) string {
+	return strings.ToLower(strings.TrimPrefix(v.String(), prefix))
+}
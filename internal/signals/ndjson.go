@@ -0,0 +1,30 @@
+package signals
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// NDJSONWriter writes signals as newline-delimited JSON, one signal per
+// line, in the same shape the shipper sends to the backend
+type NDJSONWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter creates a writer emitting to w
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONWriter{enc: enc}
+}
+
+// Write emits sig as a single JSON line
+func (w *NDJSONWriter) Write(sig *state.Signal) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(sig)
+}
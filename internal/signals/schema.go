@@ -0,0 +1,504 @@
+package signals
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// SchemaOptions selects the optional signal context fields an agent produces
+// with its config and rules
+type SchemaOptions struct {
+	Version        string             // Agent version recorded in the schema description
+	Rules          *rules.RulesConfig // extra_context, include_event, include_process_tree and aggregate of the loaded rules
+	Identity       bool               // A directory identity provider is configured
+	SpoolArchive   bool               // Spool files are archived (santa.archive_dir)
+	Dedup          bool               // Repeated signals are deduplicated (state.dedup.cooldown)
+	FleetFirstSeen bool               // Baseline matches are checked with the collector (state.first_seen.fleet)
+	Intel          bool               // Threat intel feeds are configured (intel.feeds)
+	Reputation     bool               // Execution targets are looked up with a reputation service (reputation.provider)
+	YARA           bool               // Execution targets are scanned with YARA rules (yara.enabled)
+	Learning       bool               // Baseline learning summaries are shipped (state.first_seen.learning.summary)
+	SantaRules     bool               // Santa rules are suggested for execution signals (santa_rules.enabled)
+	Response       bool               // Rules may trigger response actions (response.enabled)
+}
+
+// signalFieldDescriptions documents the top-level signal fields
+var signalFieldDescriptions = map[string]string{
+	"signal_id":        "Deterministic signal ID (hex)",
+	"ts":               "Time of the matching event, or of generation for correlations",
+	"host_id":          "Agent ID of the host",
+	"rule_id":          "ID of the rule that produced the signal",
+	"rule_description": "Rule description",
+	"status":           "open, or acknowledged for dedup summaries and rollups of signals acknowledged on the backend",
+	"severity":         "Rule severity",
+	"title":            "Rule title",
+	"tags":             "Rule tags; correlation and baseline signals add their rule type",
+	"context":          "Event details and enrichments; the fields depend on the rule type",
+	"priority":         "Shipped on the fast lane ahead of bulk signals",
+	"rules_version":    "Rules bundle that produced the signal",
+	"trace_id":         "Trace of the spool file that produced the signal, when traced",
+	"span_id":          "Span that generated the signal",
+	"event_seq":        "Agent-wide sequence number of the event that produced the signal, increasing in processing order",
+}
+
+// Schema returns a JSON Schema (draft 2020-12) of the signal payload this
+// build ships. Top-level fields are read from state.Signal; the context is
+// one of the per-rule-type objects in $defs.
+func Schema(opts SchemaOptions) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	t := reflect.TypeFor[state.Signal]()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, flags, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		prop := typeSchema(f.Type)
+		if desc := signalFieldDescriptions[name]; desc != "" {
+			prop["description"] = desc
+		}
+		properties[name] = prop
+		if !strings.Contains(flags, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	defs := map[string]any{
+		"rule_context":        contextSchema(ruleContext(opts), "kind"),
+		"correlation_context": contextSchema(correlationContext(opts), "event_count", "window_type"),
+		"baseline_context":    contextSchema(baselineContext(opts), "pattern", "in_learning"),
+	}
+	var variants []any
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		variants = append(variants, map[string]any{"$ref": "#/$defs/" + name})
+	}
+	if opts.Learning {
+		defs["learning_summary_context"] = contextSchema(learningSummaryContext(), "learned_patterns", "learned_total")
+		variants = append(variants, map[string]any{"$ref": "#/$defs/learning_summary_context"})
+	}
+	if hasAggregateRules(opts.Rules) {
+		defs["aggregate_context"] = contextSchema(aggregateContext(opts), "aggregate_count", "sample")
+		variants = append(variants, map[string]any{"$ref": "#/$defs/aggregate_context"})
+	}
+	properties["context"] = map[string]any{
+		"type":        "object",
+		"description": signalFieldDescriptions["context"],
+		"anyOf":       variants,
+	}
+
+	version := opts.Version
+	if version == "" {
+		version = "dev"
+	}
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "Santamon signal",
+		"description": "Signal payload shipped by santamon " + version,
+		"type":        "object",
+		"properties":  properties,
+		"required":    required,
+		"$defs":       defs,
+	}
+}
+
+// typeSchema maps a Go field type to its JSON Schema
+func typeSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": []string{"array", "null"}, "items": typeSchema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object"}
+	}
+	return map[string]any{}
+}
+
+// contextSchema builds an open object schema from context fields
+func contextSchema(fields map[string]any, required ...string) map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"properties":           fields,
+		"required":             required,
+		"additionalProperties": true,
+	}
+}
+
+func str(desc string) map[string]any {
+	return map[string]any{"type": "string", "description": desc}
+}
+
+func integer(desc string) map[string]any {
+	return map[string]any{"type": "integer", "description": desc}
+}
+
+func number(desc string) map[string]any {
+	return map[string]any{"type": "number", "description": desc}
+}
+
+func boolean(desc string) map[string]any {
+	return map[string]any{"type": "boolean", "description": desc}
+}
+
+func timestamp(desc string) map[string]any {
+	return map[string]any{"type": "string", "format": "date-time", "description": desc}
+}
+
+func stringList(desc string) map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": desc}
+}
+
+// messageContext returns the fields appendMessageContext adds
+func messageContext() map[string]any {
+	return map[string]any{
+		"actor_path":          str("Path of the instigating process"),
+		"actor_team":          str("Team ID of the instigating process"),
+		"actor_signing_id":    str("Signing ID of the instigating process"),
+		"target_path":         str("Path of the target executable or file"),
+		"target_team":         str("Team ID of the target executable"),
+		"target_sha256":       str("SHA-256 of the target executable"),
+		"decision":            str("Santa decision"),
+		"remote_address":      str("Source address of an open_ssh or screen_sharing event"),
+		"remote_address_type": str("Type of remote_address: ipv4, ipv6 or named_socket"),
+		"auth_success":        boolean("Whether the SSH or screen sharing login, or the authentication, succeeded"),
+		"ssh_result":          str("Outcome of an SSH login, e.g. auth_success or invalid_user"),
+		"auth_user":           str("Account an SSH, screen sharing or authentication event is for"),
+		"kind":                str("Santa event kind, e.g. execution or file_access"),
+
+		// Added per event kind (see kindContext)
+		"item_path":         str("Property list of a launch_item"),
+		"item_executable":   str("Executable a launch_item runs"),
+		"item_user":         str("User a launch_item is registered for"),
+		"item_type":         str("Type of a launch_item, e.g. agent"),
+		"item_action":       str("Whether a launch_item was added or removed"),
+		"item_legacy":       boolean("The launch_item is a legacy launchd plist"),
+		"item_managed":      boolean("The launch_item is managed by MDM"),
+		"tcc_service":       str("TCC service of a tcc_modification, e.g. kTCCServiceScreenCapture"),
+		"tcc_identity":      str("Bundle ID or path the TCC right was changed for"),
+		"tcc_identity_type": str("Type of tcc_identity, e.g. bundle_id"),
+		"tcc_event_type":    str("TCC change, e.g. modify"),
+		"tcc_right":         str("Resulting TCC authorization right, e.g. allowed or denied"),
+		"tcc_reason":        str("Why the TCC right changed, e.g. user_consent or mdm_policy"),
+		"override_path":     str("File a gatekeeper_override was granted for"),
+		"override_sha256":   str("SHA-256 of the overridden file"),
+		"disk_action":       str("Whether a disk appeared or disappeared"),
+		"mount_point":       str("Mount point of a disk"),
+		"volume":            str("Volume name of a disk"),
+		"bsd_name":          str("BSD name of a disk, e.g. disk4s1"),
+		"filesystem":        str("File system of a disk"),
+		"disk_bus":          str("Bus a disk is attached through, e.g. USB"),
+		"disk_model":        str("Model of a disk"),
+		"disk_serial":       str("Serial number of a disk"),
+		"auth_type":         str("Authentication method: od, touch_id, token or auto_unlock"),
+		"auth_record_type":  str("Open Directory record type of an od authentication"),
+		"auth_node":         str("Open Directory node of an od authentication"),
+		"auth_mode":         str("Touch ID mode or auto unlock type of an authentication"),
+	}
+}
+
+// enrichmentContext returns the fields main adds to signals of every rule type
+func enrichmentContext(opts SchemaOptions) map[string]any {
+	fields := map[string]any{
+		"replayed_from": str("Spool file the signal was replayed from (santamon replay only)"),
+	}
+	if opts.SpoolArchive {
+		fields["spool_archive_path"] = str("Archived spool file holding the event")
+	}
+	if opts.Identity {
+		fields["user_email"] = str("Directory email of the acting user")
+		fields["department"] = str("Directory department of the acting user")
+	}
+	if opts.Response {
+		fields["response_actions"] = stringList("Response actions the signal triggered; outcomes are in the response audit log")
+	}
+	return fields
+}
+
+// intelContext returns the fields appendIntel adds
+func intelContext() map[string]any {
+	return map[string]any{
+		"intel_matches":    stringList("Threat intel feeds listing the event's hashes, team IDs or signing IDs"),
+		"intel_indicators": stringList("Hashes, team IDs and signing IDs that matched a feed"),
+	}
+}
+
+// followUpContext returns the fields of follow-up signals (see FollowUps)
+func followUpContext(opts SchemaOptions) map[string]any {
+	if !opts.Reputation && !opts.YARA {
+		return nil
+	}
+	fields := map[string]any{
+		"follow_up_of": str("ID of the signal a follow-up enriches; the follow-up repeats its context"),
+	}
+	// santamon replay adds the enrichments to the signal itself
+	if opts.Reputation {
+		fields["reputation_known"] = boolean("The reputation service knows the target SHA-256")
+		fields["reputation_malicious"] = integer("Antivirus engines that flagged the target")
+		fields["reputation_total"] = integer("Antivirus engines that scanned the target")
+		fields["reputation_detections"] = str("Detection ratio, e.g. 3/64")
+	}
+	if opts.YARA {
+		fields["yara_matches"] = stringList("YARA rules matching the target executable; empty when it was scanned clean")
+	}
+	return fields
+}
+
+// inventoryContext returns the fields appendInventory adds
+func inventoryContext() map[string]any {
+	return map[string]any{
+		"host_os_version": str("macOS version of the host, e.g. 14.2.1"),
+		"host_os_build":   str("macOS build of the host, e.g. 23C71"),
+		"host_model":      str("Hardware model of the host, e.g. Mac14,2"),
+		"host_uuid":       str("Hardware UUID of the host"),
+		"santa_version":   str("Version of the Santa daemon"),
+		"santa_mode":      str("Santa client mode: monitor, lockdown or standalone"),
+	}
+}
+
+// sessionContext returns the fields appendSession adds
+func sessionContext() map[string]any {
+	return map[string]any{
+		"console_user":           str("User at the console when the event happened"),
+		"console_locked":         boolean("The console user's screen was locked"),
+		"effective_user":         str("User the event's process ran as (the target's, for executions)"),
+		"effective_user_differs": boolean("effective_user is not the console user, e.g. after sudo"),
+	}
+}
+
+// santaRuleSchema describes santarule.Rule.Context
+func santaRuleSchema() map[string]any {
+	return map[string]any{
+		"type":        "object",
+		"description": "Suggested Santa rule for an unsigned or newly seen execution target (santa_rules)",
+		"properties": map[string]any{
+			"identifier": str("SHA-256 for BINARY rules, TEAMID:signing_id for SIGNINGID rules"),
+			"policy":     str("BLOCKLIST, or ALLOWLIST when Santa denied the binary by default"),
+			"rule_type":  str("BINARY or SIGNINGID"),
+			"custom_msg": str("Message Santa shows when the rule blocks"),
+		},
+		"required": []string{"identifier", "policy", "rule_type"},
+	}
+}
+
+// metadataContext returns the fields appendRuleMetadata adds
+func metadataContext() map[string]any {
+	return map[string]any{
+		"rule_references":      stringList("Reference URLs of the rule"),
+		"rule_author":          str("Rule author"),
+		"rule_created":         map[string]any{"type": "string", "format": "date", "description": "Date the rule was written"},
+		"rule_modified":        map[string]any{"type": "string", "format": "date", "description": "Date the rule was last changed"},
+		"rule_false_positives": str("Known benign causes of the rule firing"),
+		"rule_pack":            str("Rule pack the rule belongs to"),
+	}
+}
+
+// ruleContext returns the context fields of simple rule signals
+func ruleContext(opts SchemaOptions) map[string]any {
+	fields := messageContext()
+	maps.Copy(fields, enrichmentContext(opts))
+	maps.Copy(fields, metadataContext())
+	maps.Copy(fields, inventoryContext())
+	maps.Copy(fields, sessionContext())
+	fields["first_seen"] = boolean("First time this agent saw the target SHA-256")
+	if opts.Intel {
+		maps.Copy(fields, intelContext())
+	}
+	maps.Copy(fields, followUpContext(opts))
+	if opts.SantaRules {
+		fields["santa_rule"] = santaRuleSchema()
+	}
+
+	if opts.Dedup {
+		fields["dedup_count"] = integer("Dedup summary: occurrences in the cooldown, including the first")
+		fields["dedup_first_seen"] = timestamp("Dedup summary: first occurrence")
+		fields["dedup_last_seen"] = timestamp("Dedup summary: last occurrence")
+		fields["dedup_signal_id"] = str("Dedup summary: ID of the signal already shipped")
+	}
+
+	if opts.Rules == nil {
+		return fields
+	}
+	for _, r := range opts.Rules.Rules {
+		if !r.Enabled {
+			continue
+		}
+		if r.IncludeEvent {
+			fields["event"] = map[string]any{"type": "object", "description": "Full Santa event (include_event)"}
+		}
+		if r.IncludeProcessTree {
+			fields["process_tree"] = processTreeSchema()
+		}
+		if r.IncludeChildren {
+			fields["process_children"] = processChildrenSchema()
+		}
+		if len(r.Entitlements) > 0 {
+			fields["entitlements"] = map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "string"},
+				"description":          "Target entitlements matching include_entitlements, with JSON encoded values",
+			}
+		}
+		if r.IncludeProcessTree || len(r.ExtraContext) > 0 {
+			fields["context_missing"] = stringList("Requested extra_context fields and process_tree the event did not provide")
+		}
+		for _, field := range r.ExtraContext {
+			name := strings.TrimPrefix(field, "event.")
+			if name == "" {
+				continue
+			}
+			if name == "execution.args" {
+				fields[name] = stringList("Execution arguments (extra_context)")
+				continue
+			}
+			fields[name] = str("Event field requested by extra_context")
+		}
+	}
+	return fields
+}
+
+// processTreeSchema describes lineage.Serialize output
+func processTreeSchema() map[string]any {
+	props := processProperties()
+	props["relation"] = map[string]any{"type": "string", "enum": []string{"target", "parent", "ancestor", "responsible"}}
+	props["depth"] = integer("0 for the target")
+	return map[string]any{
+		"type":        "array",
+		"description": "Process lineage of the target, target first (include_process_tree)",
+		"items":       map[string]any{"type": "object", "properties": props},
+	}
+}
+
+// processChildrenSchema describes lineage.SerializeDescendants output
+func processChildrenSchema() map[string]any {
+	props := processProperties()
+	props["relation"] = map[string]any{"type": "string", "enum": []string{"child", "descendant"}}
+	props["depth"] = integer("Generations below the target, 1 for its children")
+	props["parent_pid"] = integer("Process ID of the parent")
+	return map[string]any{
+		"type":        "array",
+		"description": "Processes spawned by the target and their descendants, children first (include_children)",
+		"items":       map[string]any{"type": "object", "properties": props},
+	}
+}
+
+// processProperties describes the process fields of lineage nodes
+func processProperties() map[string]any {
+	return map[string]any{
+		"pid":        integer("Process ID"),
+		"pidversion": integer("Process ID version"),
+		"path":       str("Executable path"),
+		"user":       str("User name"),
+		"uid":        integer("User ID"),
+		"group":      str("Group name"),
+		"gid":        integer("Group ID"),
+		"session_id": integer("Session ID"),
+		"start_time": timestamp("Process start time"),
+		"args":       stringList("Process arguments"),
+
+		"team_id":         str("Team ID of the executable's signature"),
+		"signing_id":      str("Signing ID of the executable's signature"),
+		"platform_binary": boolean("Executable is an Apple platform binary"),
+		"sha256":          str("SHA-256 of the executable"),
+	}
+}
+
+// correlationContext returns the context fields of correlation signals
+func correlationContext(opts SchemaOptions) map[string]any {
+	fields := messageContext()
+	maps.Copy(fields, enrichmentContext(opts))
+	maps.Copy(fields, metadataContext())
+	maps.Copy(fields, inventoryContext())
+	fields["event_count"] = integer("Events in the window when the threshold was reached, or unanswered triggers of an absence correlation")
+	fields["window_type"] = map[string]any{"type": "string", "enum": []string{"correlation", "absence", "rate"}}
+	fields["event_rate"] = number("Estimated events per second of a rate correlation")
+	fields["expected"] = str("Expression of the follow-up event an absence correlation did not see")
+	fields["hosts"] = stringList("Machine IDs of the window's events, with cross-host windows")
+	fields["distinct_field"] = str("count_distinct fields, comma separated")
+	fields["distinct_values"] = stringList("Distinct values of the count_distinct fields, joined by | for several fields")
+	fields["grouped_by"] = map[string]any{
+		"type":                 "object",
+		"description":          "group_by field values",
+		"additionalProperties": map[string]any{"type": "string"},
+	}
+	fields["sample_event"] = map[string]any{"type": "object", "description": "Last event in the window"}
+	return fields
+}
+
+// baselineContext returns the context fields of baseline signals
+func baselineContext(opts SchemaOptions) map[string]any {
+	fields := messageContext()
+	maps.Copy(fields, enrichmentContext(opts))
+	maps.Copy(fields, metadataContext())
+	maps.Copy(fields, inventoryContext())
+	maps.Copy(fields, sessionContext())
+	fields["pattern"] = str("Baseline pattern not seen during learning")
+	fields["in_learning"] = boolean("Always false when shipped")
+	fields["scope"] = str("Scope field values the pattern was first seen for, for rules with scope")
+	fields["last_seen"] = timestamp("When a pattern that returned after forget_after was last seen")
+	fields["occurrences"] = integer("Sightings of the pattern so far, for rules with min_occurrences")
+	fields["rarity"] = number("Share of the rule's sightings that were other patterns, 0 to 1")
+	fields["rule_patterns"] = integer("Distinct patterns the rule tracks")
+	fields["rule_sightings"] = integer("Sightings of all patterns the rule tracks")
+	if opts.Intel {
+		maps.Copy(fields, intelContext())
+	}
+	maps.Copy(fields, followUpContext(opts))
+	if opts.SantaRules {
+		fields["santa_rule"] = santaRuleSchema()
+	}
+	if opts.FleetFirstSeen {
+		fields["fleet_hosts"] = integer("Hosts that had reported the pattern to the collector, including this one")
+		fields["fleet_first_seen"] = timestamp("Earliest report of the pattern across the fleet")
+	}
+	return fields
+}
+
+// learningSummaryContext returns the context fields of baseline learning
+// summaries
+func learningSummaryContext() map[string]any {
+	fields := metadataContext()
+	maps.Copy(fields, inventoryContext())
+	fields["learning_started"] = timestamp("Start of the rule's learning period")
+	fields["learning_ended"] = timestamp("End of the rule's learning period")
+	fields["learned_patterns"] = stringList("Learned patterns, most seen first, at most 100")
+	fields["learned_total"] = integer("Learned patterns, including those not listed")
+	fields["learned_sightings"] = integer("Sightings of all learned patterns")
+	fields["learned_truncated"] = boolean("More patterns were learned than are listed")
+	return fields
+}
+
+// aggregateContext returns the context fields of aggregate rule rollups
+func aggregateContext(opts SchemaOptions) map[string]any {
+	return map[string]any{
+		"aggregate_count":             integer("Matches in the interval"),
+		"aggregate_distinct_targets":  integer("Distinct targets listed in aggregate_targets"),
+		"aggregate_targets":           stringList("Target paths, else hashes, of the matches"),
+		"aggregate_targets_truncated": boolean("More targets matched than are listed"),
+		"aggregate_first_seen":        timestamp("First match"),
+		"aggregate_last_seen":         timestamp("Last match"),
+		"sample":                      contextSchema(ruleContext(opts), "kind"),
+	}
+}
+
+// hasAggregateRules reports whether any enabled rule is marked aggregate
+func hasAggregateRules(rc *rules.RulesConfig) bool {
+	if rc == nil {
+		return false
+	}
+	return slices.ContainsFunc(rc.Rules, func(r *rules.Rule) bool {
+		return r.Enabled && r.Aggregate
+	})
+}
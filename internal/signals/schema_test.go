@@ -0,0 +1,135 @@
+package signals
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/0x4d31/santamon/internal/baseline"
+	"github.com/0x4d31/santamon/internal/correlation"
+	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// schemaDef returns the properties of a context definition
+func schemaDef(t *testing.T, schema map[string]any, name string) map[string]any {
+	t.Helper()
+	def, ok := schema["$defs"].(map[string]any)[name].(map[string]any)
+	if !ok {
+		t.Fatalf("Schema has no %s definition", name)
+	}
+	return def["properties"].(map[string]any)
+}
+
+func TestSchema(t *testing.T) {
+	rule := &rules.Rule{
+		ID:           "SM-001",
+		Enabled:      true,
+		IncludeEvent: true,
+		ExtraContext: []string{"event.execution.args", "event.execution.decision"},
+		Metadata: rules.Metadata{
+			References:     []string{"https://example.com/runbook"},
+			Author:         "Detection Team",
+			Created:        "2026-01-15",
+			Modified:       "2026-09-30",
+			FalsePositives: "Homebrew",
+		},
+	}
+	schema := Schema(SchemaOptions{
+		Version:        "1.2.3",
+		Rules:          &rules.RulesConfig{Rules: []*rules.Rule{rule, {ID: "SM-002", Enabled: true, Aggregate: true}}},
+		Identity:       true,
+		FleetFirstSeen: true,
+		Intel:          true,
+		Reputation:     true,
+		YARA:           true,
+		Learning:       true,
+	})
+
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("Failed to marshal schema: %v", err)
+	}
+	if schema["description"] != "Signal payload shipped by santamon 1.2.3" {
+		t.Errorf("description = %v", schema["description"])
+	}
+
+	required := schema["required"].([]string)
+	for _, name := range []string{"signal_id", "ts", "rule_id", "context"} {
+		if !slices.Contains(required, name) {
+			t.Errorf("%s is not required: %v", name, required)
+		}
+	}
+	if slices.Contains(required, "trace_id") {
+		t.Error("omitempty field trace_id is required")
+	}
+
+	// Every context field a generated signal carries is declared
+	gen := NewGenerator("test-host", nil)
+	gen.SetIdentityProvider(stubIdentities{})
+	gen.SetIntel(stubIntel{"EQHXZ8M8AV": {"partner-feed"}})
+	signal := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: extraContextMessage(), Rule: rule, Severity: rules.SeverityHigh})
+	// Follow-ups add their enrichments
+	followUps := NewFollowUps(1, func(sig *state.Signal) { signal = sig })
+	followUps.SetReputation(&stubReputation{})
+	followUps.SetYARA(&stubYARA{matches: []string{"Mal_Loader"}}, rules.SeverityLow)
+	followUps.followUp(context.Background(), followUp{sig: signal, msg: extraContextMessage()})
+	if _, ok := signal.Context["follow_up_of"]; !ok {
+		t.Fatal("No follow-up was emitted")
+	}
+	ruleFields := schemaDef(t, schema, "rule_context")
+	for k := range signal.Context {
+		if _, ok := ruleFields[k]; !ok {
+			t.Errorf("rule_context does not declare %q", k)
+		}
+	}
+
+	window := gen.FromWindowMatch(&correlation.WindowMatch{
+		RuleID: "CORR-001",
+		Count:  3,
+		Events: []map[string]any{{"execution": map[string]any{"decision": "DECISION_ALLOW"}}},
+		Rule:   &rules.CorrelationRule{GroupBy: []string{"execution.decision"}, CountDistinct: rules.Fields{"execution.decision"}},
+	}, "boot-123")
+	correlationFields := schemaDef(t, schema, "correlation_context")
+	for k := range window.Context {
+		if _, ok := correlationFields[k]; !ok {
+			t.Errorf("correlation_context does not declare %q", k)
+		}
+	}
+
+	// Disabled enrichments are left out
+	if _, ok := ruleFields["dedup_count"]; ok {
+		t.Error("dedup fields declared without dedup")
+	}
+	if _, ok := ruleFields["process_tree"]; ok {
+		t.Error("process_tree declared without include_process_tree")
+	}
+	if _, ok := schemaDef(t, schema, "baseline_context")["fleet_hosts"]; !ok {
+		t.Error("baseline_context does not declare fleet_hosts")
+	}
+	if _, ok := schemaDef(t, Schema(SchemaOptions{}), "baseline_context")["fleet_hosts"]; ok {
+		t.Error("fleet_hosts declared without fleet first-seen")
+	}
+	if _, ok := schemaDef(t, Schema(SchemaOptions{}), "rule_context")["intel_matches"]; ok {
+		t.Error("intel_matches declared without intel feeds")
+	}
+	schemaDef(t, schema, "aggregate_context")
+	if _, ok := Schema(SchemaOptions{})["$defs"].(map[string]any)["aggregate_context"]; ok {
+		t.Error("aggregate_context declared without aggregate rules")
+	}
+
+	summary := gen.FromLearningSummary(&baseline.LearningSummary{
+		Rule:     &rules.BaselineRule{ID: "BL-1", Metadata: rule.Metadata},
+		Patterns: []string{"path=/bin/ls"},
+		Total:    1,
+	})
+	summaryFields := schemaDef(t, schema, "learning_summary_context")
+	for k := range summary.Context {
+		if _, ok := summaryFields[k]; !ok {
+			t.Errorf("learning_summary_context does not declare %q", k)
+		}
+	}
+	if _, ok := Schema(SchemaOptions{})["$defs"].(map[string]any)["learning_summary_context"]; ok {
+		t.Error("learning_summary_context declared without learning summaries")
+	}
+}
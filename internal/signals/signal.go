@@ -1,24 +1,48 @@
 package signals
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
 	"github.com/0x4d31/santamon/internal/baseline"
 	"github.com/0x4d31/santamon/internal/correlation"
 	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/identity"
+	"github.com/0x4d31/santamon/internal/inventory"
 	"github.com/0x4d31/santamon/internal/lineage"
+	"github.com/0x4d31/santamon/internal/logutil"
 	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/session"
 	"github.com/0x4d31/santamon/internal/state"
 )
 
+var logger = logutil.For("signals")
+
+// maxProcessChildren bounds the descendants included with include_children
+const maxProcessChildren = 50
+
 // Generator creates signals from rule matches
 type Generator struct {
-	hostID  string
-	lineage *lineage.Store
+	hostID   string
+	lineage  *lineage.Store
+	identity identity.Provider  // Optional directory identity lookup
+	intel    rules.IntelMatcher // Optional threat intel lookup
+
+	rulesVersion string // Version of the rules bundle that produced the signals
+
+	inventory InventorySource  // Optional machine inventory
+	sessions  *session.Tracker // Optional console user tracking
+
+	mu     sync.Mutex
+	warned map[string]bool // rule ID + context field already reported missing
 }
 
 // NewGenerator creates a new signal generator
@@ -26,6 +50,166 @@ func NewGenerator(hostID string, store *lineage.Store) *Generator {
 	return &Generator{
 		hostID:  hostID,
 		lineage: store,
+		warned:  make(map[string]bool),
+	}
+}
+
+// SetIdentityProvider enables user_email/department enrichment from p (nil disables it)
+func (g *Generator) SetIdentityProvider(p identity.Provider) {
+	g.identity = p
+}
+
+// SetIntel enables intel_matches enrichment from m (nil disables it)
+func (g *Generator) SetIntel(m rules.IntelMatcher) {
+	g.intel = m
+}
+
+// SetRulesVersion stamps subsequent signals with the active rules version
+func (g *Generator) SetRulesVersion(version string) {
+	g.rulesVersion = version
+}
+
+// InventorySource provides the latest machine inventory (see
+// inventory.Collector)
+type InventorySource interface {
+	Current() *inventory.Inventory
+}
+
+// SetInventory enables host_* and santa_* enrichment from the latest
+// inventory of src (nil disables it)
+func (g *Generator) SetInventory(src InventorySource) {
+	g.inventory = src
+}
+
+// appendInventory adds the machine details that attribute a signal in a
+// fleet. Heartbeats carry the full inventory.
+func (g *Generator) appendInventory(ctx map[string]any) {
+	if g.inventory == nil {
+		return
+	}
+	inv := g.inventory.Current()
+	if inv == nil {
+		return
+	}
+	putString(ctx, "host_os_version", inv.OSVersion)
+	putString(ctx, "host_os_build", inv.OSBuild)
+	putString(ctx, "host_model", inv.Model)
+	putString(ctx, "host_uuid", inv.HardwareUUID)
+	putString(ctx, "santa_version", inv.SantaVersion)
+	putString(ctx, "santa_mode", inv.SantaMode)
+}
+
+// SetSessions enables console_user and effective_user enrichment from the
+// console sessions t tracks (nil disables it)
+func (g *Generator) SetSessions(t *session.Tracker) {
+	g.sessions = t
+}
+
+// appendSession adds the user at the console when the event happened and
+// the user the event's process ran as. effective_user_differs marks events
+// run as another account than the console user's, such as sudo to root.
+func (g *Generator) appendSession(ctx map[string]any, msg *santapb.SantaMessage) {
+	if g.sessions == nil {
+		return
+	}
+	console := g.sessions.At(events.EventTime(msg))
+	if console.User != "" {
+		ctx["console_user"] = console.User
+		ctx["console_locked"] = console.Locked
+	}
+	if name := events.EffectiveUser(msg).GetName(); name != "" {
+		ctx["effective_user"] = name
+		if console.User != "" {
+			ctx["effective_user_differs"] = name != console.User
+		}
+	}
+}
+
+// appendIdentity adds the directory identity of the acting user, if known
+func (g *Generator) appendIdentity(ctx map[string]any, user identity.User) {
+	if g.identity == nil || user.Name == "" {
+		return
+	}
+	id, err := g.identity.Lookup(context.Background(), user)
+	if err != nil {
+		logger.Warn("Identity lookup failed for %s: %v", user.Name, err)
+		return
+	}
+	if id == nil {
+		return
+	}
+	ctx["user_email"] = id.Email
+	if id.Department != "" {
+		ctx["department"] = id.Department
+	}
+}
+
+// appendIntel adds the threat intel feeds listing the event's hashes, team
+// IDs or signing IDs (as TEAMID:signing_id, see events.SigningKey), and the
+// indicators they matched
+func (g *Generator) appendIntel(ctx map[string]any, msg *santapb.SantaMessage) {
+	if g.intel == nil {
+		return
+	}
+	var feeds, indicators []string
+	for _, v := range []string{
+		events.TargetSHA256(msg),
+		events.TargetTeam(msg),
+		events.TargetSigningKey(msg),
+		events.ActorTeam(msg),
+		events.ActorSigningKey(msg),
+	} {
+		if v == "" || slices.Contains(indicators, v) {
+			continue
+		}
+		if names := g.intel.Match(v); len(names) > 0 {
+			feeds = append(feeds, names...)
+			indicators = append(indicators, v)
+		}
+	}
+	if len(feeds) == 0 {
+		return
+	}
+	slices.Sort(feeds)
+	ctx["intel_matches"] = slices.Compact(feeds)
+	ctx["intel_indicators"] = indicators
+}
+
+// messageUser returns the acting user of a Santa message
+func messageUser(msg *santapb.SantaMessage) identity.User {
+	u := events.ActorUser(msg)
+	return identity.User{UID: u.GetUid(), Name: u.GetName()}
+}
+
+// mapUser returns the acting user of an event map (correlation window samples)
+func mapUser(evt map[string]any) identity.User {
+	kind := events.KindFromMap(evt)
+	for _, field := range []string{"real_user", "effective_user"} {
+		prefix := kind + ".instigator." + field + "."
+		if name := events.ExtractField(evt, prefix+"name"); name != "" {
+			uid, _ := strconv.Atoi(events.ExtractField(evt, prefix+"uid"))
+			return identity.User{UID: int32(uid), Name: name}
+		}
+	}
+	return identity.User{}
+}
+
+// warnMissing logs the first time a rule's signal lacks each requested
+// context field, so rules that can never fill their context don't go unnoticed
+func (g *Generator) warnMissing(ruleID string, fields []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, field := range fields {
+		key := ruleID + "\x00" + field
+		if g.warned[key] {
+			continue
+		}
+		g.warned[key] = true
+		if field == "process_tree" && g.lineage == nil {
+			logger.Warn("Rule %s requests include_process_tree but process lineage is not tracked; its signals have no process_tree", ruleID)
+			continue
+		}
+		logger.Warn("Rule %s requested %s but the event did not provide it; such signals list it in context_missing", ruleID, field)
 	}
 }
 
@@ -42,24 +226,37 @@ func (g *Generator) FromRuleMatch(match *rules.Match) *state.Signal {
 
 	context := map[string]any{}
 	appendMessageContext(context, match.Message)
+	g.appendIdentity(context, messageUser(match.Message))
+	g.appendIntel(context, match.Message)
+	g.appendSession(context, match.Message)
+	g.appendInventory(context)
 
-	// Build event map if needed for extra context or full event inclusion
+	// The full event map is only built for include_event, or for extra
+	// context fields that cannot be read from the typed message
 	var eventMap map[string]any
-	if match.Rule != nil && (match.Rule.IncludeEvent || len(match.Rule.ExtraContext) > 0) {
-		var err error
-		eventMap, err = events.ToMap(match.Message)
-		if err == nil {
-			events.BuildActivation(match.Message, eventMap)
+	mapBuilt := false
+	getEventMap := func() map[string]any {
+		if !mapBuilt {
+			mapBuilt = true
+			if m, err := match.EventMap(); err == nil {
+				eventMap = m
+			}
 		}
+		return eventMap
 	}
 
 	// Include full event map when requested on the rule
-	if match.Rule != nil && match.Rule.IncludeEvent && eventMap != nil {
-		context["event"] = eventMap
+	if match.Rule != nil && match.Rule.IncludeEvent {
+		if m := getEventMap(); m != nil {
+			context["event"] = m
+		}
 	}
 
+	// Requested context the event did not provide
+	var missing []string
+
 	// Include extra context fields when requested on the rule
-	if match.Rule != nil && len(match.Rule.ExtraContext) > 0 && eventMap != nil {
+	if match.Rule != nil {
 		for _, field := range match.Rule.ExtraContext {
 			if field == "" {
 				continue
@@ -70,42 +267,79 @@ func (g *Generator) FromRuleMatch(match *rules.Match) *state.Signal {
 
 			// Special-case execution.args to preserve the full list
 			if cleanField == "execution.args" {
-				if execRaw, ok := eventMap["execution"].(map[string]any); ok {
-					if args, ok := execRaw["args"]; ok && args != nil {
-						context["execution.args"] = args
-						continue
-					}
+				if _, ok := match.Message.GetEvent().(*santapb.SantaMessage_Execution); ok {
+					context["execution.args"] = events.DecodedArgs(match.Message)
+					continue
 				}
 			}
 
-			if val := events.ExtractField(eventMap, cleanField); val != "" {
+			val, ok := events.LookupField(match.Message, cleanField)
+			if !ok {
+				val = events.ExtractField(getEventMap(), cleanField)
+			}
+			if val != "" {
 				context[cleanField] = val
+			} else {
+				missing = append(missing, cleanField)
 			}
 		}
 	}
 
+	// Include the target's entitlements matching include_entitlements
+	if match.Rule != nil && len(match.Rule.Entitlements) > 0 {
+		if ents := match.Rule.MatchedEntitlements(match.Message); ents != nil {
+			context["entitlements"] = ents
+		}
+	}
+
 	// Include process tree / lineage when requested on the rule
-	if g.lineage != nil && match.Rule != nil && match.Rule.IncludeProcessTree {
-		if ev, ok := match.Message.GetEvent().(*santapb.SantaMessage_Execution); ok {
-			if tgt := ev.Execution.GetTarget(); tgt != nil && tgt.GetId() != nil {
-				key := lineage.FromProcessID(match.Message.GetBootSessionUuid(), tgt.GetId())
-				chain := g.lineage.Lineage(key, 8)
-				if len(chain) > 0 {
-					context["process_tree"] = lineage.Serialize(chain)
+	if match.Rule != nil && match.Rule.IncludeProcessTree {
+		if g.lineage != nil {
+			if ev, ok := match.Message.GetEvent().(*santapb.SantaMessage_Execution); ok {
+				if tgt := ev.Execution.GetTarget(); tgt != nil && tgt.GetId() != nil {
+					key := lineage.FromProcessID(match.Message.GetBootSessionUuid(), tgt.GetId())
+					depth := match.Rule.TreeDepth()
+					var chain []*lineage.Node
+					if match.Rule.ProcessTreeMode == rules.ProcessTreeResponsible {
+						chain = g.lineage.ResponsibleLineage(key, depth)
+					} else {
+						chain = g.lineage.Lineage(key, depth)
+					}
+					if len(chain) > 0 {
+						context["process_tree"] = lineage.Serialize(chain)
+					}
+					// Children spawned before the signal, usually those in the
+					// same spool file
+					if match.Rule.IncludeChildren {
+						if children := g.lineage.Descendants(key, depth, maxProcessChildren); len(children) > 0 {
+							context["process_children"] = lineage.SerializeDescendants(key, children)
+						}
+					}
 				}
 			}
 		}
+		if _, ok := context["process_tree"]; !ok {
+			missing = append(missing, "process_tree")
+		}
+	}
+	if len(missing) > 0 {
+		context["context_missing"] = missing
+		g.warnMissing(match.RuleID, missing)
 	}
 
 	ruleDesc := ""
+	priority := false
 	if match.Rule != nil {
 		ruleDesc = strings.TrimSpace(match.Rule.Description)
+		priority = match.Rule.Priority
+		appendRuleMetadata(context, &match.Rule.Metadata)
 	}
 
 	return &state.Signal{
 		ID:              signalID,
 		TS:              ts,
 		HostID:          g.hostID,
+		RulesVersion:    g.rulesVersion,
 		RuleID:          match.RuleID,
 		RuleDescription: ruleDesc,
 		Status:          "open",
@@ -113,6 +347,7 @@ func (g *Generator) FromRuleMatch(match *rules.Match) *state.Signal {
 		Title:           match.Title,
 		Tags:            match.Tags,
 		Context:         context,
+		Priority:        priority,
 	}
 }
 
@@ -128,13 +363,24 @@ func (g *Generator) FromWindowMatch(match *correlation.WindowMatch, bootUUID str
 		"event_count": match.Count,
 		"window_type": "correlation",
 	}
+	if match.Rule != nil && match.Rule.IsAbsence() {
+		ctx["window_type"] = "absence"
+		ctx["expected"] = match.Rule.Expect
+	}
+	if len(match.Hosts) > 0 {
+		ctx["hosts"] = match.Hosts
+	}
+	if match.Rule != nil && match.Rule.IsRate() {
+		ctx["window_type"] = "rate"
+		ctx["event_rate"] = math.Round(match.Rate*100) / 100
+	}
 
 	// Include distinct values if count_distinct is configured
-	if match.Rule != nil && match.Rule.CountDistinct != "" {
+	if match.Rule != nil && len(match.Rule.CountDistinct) > 0 {
 		distinctValues := g.extractDistinctValues(match.Events, match.Rule.CountDistinct)
 		if len(distinctValues) > 0 {
 			ctx["distinct_values"] = distinctValues
-			ctx["distinct_field"] = match.Rule.CountDistinct
+			ctx["distinct_field"] = strings.Join(match.Rule.CountDistinct, ",")
 		}
 	}
 
@@ -197,8 +443,15 @@ func (g *Generator) FromWindowMatch(match *correlation.WindowMatch, bootUUID str
 		}
 		// kind
 		ctx["kind"] = events.KindFromMap(sample)
+
+		g.appendIdentity(ctx, mapUser(sample))
 	}
 
+	if match.Rule != nil {
+		appendRuleMetadata(ctx, &match.Rule.Metadata)
+	}
+	g.appendInventory(ctx)
+
 	// Use tags from the rule, and add "correlation" tag
 	tags := make([]string, 0, len(match.Tags)+1)
 	tags = append(tags, match.Tags...)
@@ -208,6 +461,7 @@ func (g *Generator) FromWindowMatch(match *correlation.WindowMatch, bootUUID str
 		ID:              signalID,
 		TS:              now,
 		HostID:          g.hostID,
+		RulesVersion:    g.rulesVersion,
 		RuleID:          match.RuleID,
 		RuleDescription: strings.TrimSpace(match.Description),
 		Status:          "open",
@@ -275,8 +529,29 @@ func (g *Generator) FromBaselineMatch(match *baseline.BaselineMatch) *state.Sign
 		"pattern":     match.Pattern,
 		"in_learning": match.InLearning,
 	}
+	if match.Scope != "" {
+		context["scope"] = match.Scope
+	}
+	if !match.LastSeen.IsZero() {
+		context["last_seen"] = match.LastSeen.UTC().Format(time.RFC3339)
+	}
+	if match.Rule != nil && match.Rule.MinOccurrences > 0 {
+		context["occurrences"] = match.Occurrences
+	}
+	if match.Sightings > 0 {
+		context["rarity"] = match.Rarity
+		context["rule_patterns"] = match.Patterns
+		context["rule_sightings"] = match.Sightings
+	}
 
 	appendMessageContext(context, match.Message)
+	g.appendIdentity(context, messageUser(match.Message))
+	g.appendIntel(context, match.Message)
+	g.appendSession(context, match.Message)
+	g.appendInventory(context)
+	if match.Rule != nil {
+		appendRuleMetadata(context, &match.Rule.Metadata)
+	}
 
 	// Add "baseline" tag to differentiate from simple rules
 	tags := make([]string, 0, len(match.Tags)+1)
@@ -287,6 +562,7 @@ func (g *Generator) FromBaselineMatch(match *baseline.BaselineMatch) *state.Sign
 		ID:              signalID,
 		TS:              ts,
 		HostID:          g.hostID,
+		RulesVersion:    g.rulesVersion,
 		RuleID:          match.RuleID,
 		RuleDescription: strings.TrimSpace(match.Description),
 		Status:          "open",
@@ -297,6 +573,84 @@ func (g *Generator) FromBaselineMatch(match *baseline.BaselineMatch) *state.Sign
 	}
 }
 
+// FromLearningSummary creates the info signal listing what a baseline rule
+// learned during its learning period
+func (g *Generator) FromLearningSummary(summary *baseline.LearningSummary) *state.Signal {
+	rule := summary.Rule
+	context := map[string]any{
+		"learning_started":  summary.Start.UTC().Format(time.RFC3339),
+		"learning_ended":    summary.End.UTC().Format(time.RFC3339),
+		"learned_patterns":  summary.Patterns,
+		"learned_total":     summary.Total,
+		"learned_sightings": summary.Sightings,
+		"learned_truncated": len(summary.Patterns) < summary.Total,
+	}
+	appendRuleMetadata(context, &rule.Metadata)
+	g.appendInventory(context)
+
+	tags := make([]string, 0, len(rule.Tags)+2)
+	tags = append(tags, rule.Tags...)
+	tags = append(tags, "baseline", "learning_summary")
+
+	return &state.Signal{
+		ID:              g.generateSignalID(rule.ID, summary.End, g.hostID, "learning_summary"),
+		TS:              summary.End,
+		HostID:          g.hostID,
+		RulesVersion:    g.rulesVersion,
+		RuleID:          rule.ID,
+		RuleDescription: strings.TrimSpace(rule.Description),
+		Status:          "open",
+		Severity:        "info",
+		Title:           "Baseline learning complete: " + rule.Title,
+		Tags:            tags,
+		Context:         context,
+	}
+}
+
+// FromHealth creates an agent health signal, such as the spool disk filling
+// up. Its rule ID is "santamon." + check, which no detection rule uses.
+func (g *Generator) FromHealth(check, severity, title string, ts time.Time, context map[string]any) *state.Signal {
+	ruleID := "santamon." + check
+	if context == nil {
+		context = map[string]any{}
+	}
+	g.appendInventory(context)
+	return &state.Signal{
+		ID:           g.generateSignalID(ruleID, ts, g.hostID, title),
+		TS:           ts,
+		HostID:       g.hostID,
+		RulesVersion: g.rulesVersion,
+		RuleID:       ruleID,
+		Status:       "open",
+		Severity:     severity,
+		Title:        title,
+		Tags:         []string{"santamon", "health"},
+		Context:      context,
+	}
+}
+
+// appendRuleMetadata adds the rule's triage guidance to a signal context
+func appendRuleMetadata(ctx map[string]any, m *rules.Metadata) {
+	if len(m.References) > 0 {
+		ctx["rule_references"] = m.References
+	}
+	if m.Author != "" {
+		ctx["rule_author"] = m.Author
+	}
+	if m.Created != "" {
+		ctx["rule_created"] = m.Created
+	}
+	if m.Modified != "" {
+		ctx["rule_modified"] = m.Modified
+	}
+	if fp := strings.TrimSpace(m.FalsePositives); fp != "" {
+		ctx["rule_false_positives"] = fp
+	}
+	if m.Pack != "" {
+		ctx["rule_pack"] = m.Pack
+	}
+}
+
 // EnrichSignal adds additional context to a signal
 func (g *Generator) EnrichSignal(sig *state.Signal, enrichments map[string]any) {
 	for k, v := range enrichments {
@@ -330,18 +684,32 @@ func appendMessageContext(ctx map[string]any, msg *santapb.SantaMessage) {
 	if v := events.Decision(msg); v != "" {
 		ctx["decision"] = v
 	}
+
+	// Remote access and authentication outcome, so these alerts stand alone
+	if addr, addrType := events.RemoteSource(msg); addr != "" {
+		ctx["remote_address"] = addr
+		ctx["remote_address_type"] = addrType
+	}
+	if success, ok := events.AuthSuccess(msg); ok {
+		ctx["auth_success"] = success
+	}
+	if v := events.SSHResult(msg); v != "" {
+		ctx["ssh_result"] = v
+	}
+	if v := events.AuthUser(msg); v != "" {
+		ctx["auth_user"] = v
+	}
+	appendKindContext(ctx, msg)
 	ctx["kind"] = events.Kind(msg)
 }
 
 // extractDistinctValues extracts all distinct values for the count_distinct field from window events
-func (g *Generator) extractDistinctValues(windowEvents []map[string]any, countDistinctField string) []string {
+func (g *Generator) extractDistinctValues(windowEvents []map[string]any, countDistinct []string) []string {
 	seen := make(map[string]bool)
 	values := make([]string, 0)
 
-	cleanField := strings.TrimPrefix(countDistinctField, "event.")
-
 	for _, evt := range windowEvents {
-		value := events.ExtractField(evt, cleanField)
+		value := correlation.DistinctValue(evt, countDistinct)
 		if value != "" && !seen[value] {
 			seen[value] = true
 			values = append(values, value)
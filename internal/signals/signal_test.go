@@ -1,6 +1,14 @@
 package signals
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -8,11 +16,38 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/baseline"
+	"github.com/0x4d31/santamon/internal/config"
 	"github.com/0x4d31/santamon/internal/correlation"
+	"github.com/0x4d31/santamon/internal/identity"
+	"github.com/0x4d31/santamon/internal/intel"
+	"github.com/0x4d31/santamon/internal/inventory"
+	"github.com/0x4d31/santamon/internal/lineage"
 	"github.com/0x4d31/santamon/internal/rules"
+	"github.com/0x4d31/santamon/internal/session"
 	"github.com/0x4d31/santamon/internal/state"
 )
 
+// extraContextMessage is an execution with the fields read by extra_context
+func extraContextMessage() *santapb.SantaMessage {
+	return &santapb.SantaMessage{
+		MachineId:       proto.String("machine-1"),
+		BootSessionUuid: proto.String("boot-123"),
+		EventTime:       timestamppb.New(time.Unix(1700000000, 0)),
+		Event: &santapb.SantaMessage_Execution{
+			Execution: &santapb.Execution{
+				Decision: santapb.Execution_DECISION_DENY.Enum(),
+				Args:     [][]byte{[]byte("/bin/sh"), []byte("-c"), []byte("id")},
+				Target: &santapb.ProcessInfo{
+					Id:            &santapb.ProcessID{Pid: proto.Int32(4242)},
+					CodeSignature: &santapb.CodeSignature{TeamId: proto.String("EQHXZ8M8AV")},
+					Executable:    &santapb.FileInfo{Path: proto.String("/bin/sh")},
+				},
+			},
+		},
+	}
+}
+
 func TestNewGenerator(t *testing.T) {
 	hostID := "test-host"
 	gen := NewGenerator(hostID, nil)
@@ -27,6 +62,7 @@ func TestNewGenerator(t *testing.T) {
 
 func TestFromRuleMatch(t *testing.T) {
 	gen := NewGenerator("test-host", nil)
+	gen.SetRulesVersion("0a1b2c3d4e5f")
 	ts := time.Now()
 
 	msg := &santapb.SantaMessage{
@@ -91,6 +127,9 @@ func TestFromRuleMatch(t *testing.T) {
 	if len(signal.Tags) != 2 {
 		t.Errorf("Tags length = %v, want 2", len(signal.Tags))
 	}
+	if signal.RulesVersion != "0a1b2c3d4e5f" {
+		t.Errorf("RulesVersion = %v, want 0a1b2c3d4e5f", signal.RulesVersion)
+	}
 
 	// Verify context fields
 	if signal.Context["actor_path"] != "/usr/bin/curl" {
@@ -107,6 +146,618 @@ func TestFromRuleMatch(t *testing.T) {
 	}
 }
 
+// stubIdentities is an in-memory identity provider for tests
+type stubIdentities map[string]*identity.Identity
+
+func (s stubIdentities) Lookup(_ context.Context, user identity.User) (*identity.Identity, error) {
+	if user.Name == "broken" {
+		return nil, errors.New("directory unreachable")
+	}
+	return s[user.Name], nil
+}
+
+func TestFromRuleMatchExtraContext(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	match := &rules.Match{
+		RuleID:  "SM-001",
+		Message: extraContextMessage(),
+		Rule: &rules.Rule{ExtraContext: []string{
+			"event.execution.args",
+			"event.execution.decision",
+			"event.execution.target.id.pid",
+			"execution.target.code_signature.team_id",
+			"event.event_time",                           // Activation field, read from the event map
+			"event.execution.instigator.executable.path", // Unset
+		}},
+	}
+
+	signal := gen.FromRuleMatch(match)
+	want := map[string]any{
+		"execution.decision":                      "DECISION_DENY",
+		"execution.target.id.pid":                 "4242",
+		"execution.target.code_signature.team_id": "EQHXZ8M8AV",
+		"event_time":                              time.Unix(1700000000, 0).UTC().String(),
+	}
+	for k, v := range want {
+		if signal.Context[k] != v {
+			t.Errorf("context[%q] = %v, want %v", k, signal.Context[k], v)
+		}
+	}
+	if args, ok := signal.Context["execution.args"].([]string); !ok || strings.Join(args, " ") != "/bin/sh -c id" {
+		t.Errorf("context[execution.args] = %#v", signal.Context["execution.args"])
+	}
+	if _, ok := signal.Context["execution.instigator.executable.path"]; ok {
+		t.Error("Unset field added to context")
+	}
+	if missing, _ := signal.Context["context_missing"].([]string); len(missing) != 1 || missing[0] != "execution.instigator.executable.path" {
+		t.Errorf("context_missing = %v, want [execution.instigator.executable.path]", signal.Context["context_missing"])
+	}
+	if _, ok := signal.Context["event"]; ok {
+		t.Error("Event map included without include_event")
+	}
+}
+
+func TestRemoteAccessContext(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	result := santapb.OpenSSHLogin_RESULT_AUTH_SUCCESS
+	match := &rules.Match{
+		RuleID: "SM-REMOTE",
+		Message: &santapb.SantaMessage{Event: &santapb.SantaMessage_OpenSsh{OpenSsh: &santapb.OpenSSH{
+			Event: &santapb.OpenSSH_Login{Login: &santapb.OpenSSHLogin{
+				Result: &result,
+				Source: &santapb.SocketAddress{Address: []byte("2001:db8::1"), Type: santapb.SocketAddress_TYPE_IPV6.Enum()},
+				User:   &santapb.UserInfo{Name: proto.String("admin")},
+			}},
+		}}},
+	}
+
+	signal := gen.FromRuleMatch(match)
+	want := map[string]any{
+		"remote_address":      "2001:db8::1",
+		"remote_address_type": "ipv6",
+		"auth_success":        true,
+		"ssh_result":          "auth_success",
+		"auth_user":           "admin",
+		"kind":                "open_ssh",
+	}
+	for k, v := range want {
+		if signal.Context[k] != v {
+			t.Errorf("context[%q] = %v, want %v", k, signal.Context[k], v)
+		}
+	}
+}
+
+func TestKindContext(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	right := santapb.TCCModification_AUTHORIZATION_RIGHT_DENIED
+	appeared := santapb.Disk_ACTION_APPEARED
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want map[string]any
+	}{
+		{
+			name: "launch item",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_LaunchItem{LaunchItem: &santapb.LaunchItem{
+				Action:         santapb.LaunchItem_ACTION_ADD,
+				ItemType:       santapb.LaunchItem_ITEM_TYPE_AGENT,
+				ItemPath:       proto.String("/Users/alice/Library/LaunchAgents/com.example.agent.plist"),
+				ExecutablePath: proto.String("/Users/alice/.local/agent"),
+			}}},
+			want: map[string]any{
+				"item_path":       "/Users/alice/Library/LaunchAgents/com.example.agent.plist",
+				"item_executable": "/Users/alice/.local/agent",
+				"item_type":       "agent",
+				"item_action":     "add",
+				"item_managed":    false,
+			},
+		},
+		{
+			name: "tcc modification",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_TccModification{TccModification: &santapb.TCCModification{
+				Service:            proto.String("kTCCServiceCamera"),
+				Identity:           proto.String("com.example.app"),
+				IdentityType:       santapb.TCCModification_IDENTITY_TYPE_BUNDLE_ID.Enum(),
+				AuthorizationRight: &right,
+			}}},
+			want: map[string]any{
+				"tcc_service":       "kTCCServiceCamera",
+				"tcc_identity":      "com.example.app",
+				"tcc_identity_type": "bundle_id",
+				"tcc_right":         "denied",
+			},
+		},
+		{
+			name: "gatekeeper override",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_GatekeeperOverride{GatekeeperOverride: &santapb.GatekeeperOverride{
+				Target: &santapb.FileInfo{Path: proto.String("/Applications/Tool.app"), Hash: &santapb.Hash{Hash: proto.String("abc123")}},
+			}}},
+			want: map[string]any{"override_path": "/Applications/Tool.app", "override_sha256": "abc123"},
+		},
+		{
+			name: "disk",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_Disk{Disk: &santapb.Disk{
+				Action:  &appeared,
+				Mount:   proto.String("/Volumes/USB"),
+				BsdName: proto.String("disk4s1"),
+				Bus:     proto.String("USB"),
+			}}},
+			want: map[string]any{"disk_action": "appeared", "mount_point": "/Volumes/USB", "bsd_name": "disk4s1", "disk_bus": "USB"},
+		},
+		{
+			name: "authentication",
+			msg: &santapb.SantaMessage{Event: &santapb.SantaMessage_Authentication{Authentication: &santapb.Authentication{
+				Success: proto.Bool(false),
+				Event: &santapb.Authentication_AuthenticationOd{AuthenticationOd: &santapb.AuthenticationOD{
+					RecordType: proto.String("User"),
+					RecordName: proto.String("alice"),
+					NodeName:   proto.String("/Local/Default"),
+				}},
+			}}},
+			want: map[string]any{
+				"auth_type":        "od",
+				"auth_record_type": "User",
+				"auth_node":        "/Local/Default",
+				"auth_user":        "alice",
+				"auth_success":     false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := gen.FromRuleMatch(&rules.Match{RuleID: "SM-KIND", Message: tt.msg})
+			for k, v := range tt.want {
+				if signal.Context[k] != v {
+					t.Errorf("context[%q] = %v, want %v", k, signal.Context[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestEntitlementsContext(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	match := &rules.Match{
+		RuleID: "SM-ENT",
+		Message: &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+			EntitlementInfo: &santapb.EntitlementInfo{Entitlements: []*santapb.Entitlement{
+				{Key: proto.String("com.apple.security.get-task-allow"), Value: proto.String("true")},
+				{Key: proto.String("com.apple.application-identifier"), Value: proto.String(`"ABCDE12345.com.example"`)},
+			}},
+		}}},
+		Rule: &rules.Rule{Entitlements: []string{"com.apple.security.*"}},
+	}
+
+	signal := gen.FromRuleMatch(match)
+	ents, ok := signal.Context["entitlements"].(map[string]string)
+	if !ok || len(ents) != 1 || ents["com.apple.security.get-task-allow"] != "true" {
+		t.Errorf("context[entitlements] = %#v", signal.Context["entitlements"])
+	}
+
+	match.Rule.Entitlements = []string{"com.apple.private.*"}
+	if _, ok := gen.FromRuleMatch(match).Context["entitlements"]; ok {
+		t.Error("Entitlements added to context without a match")
+	}
+}
+
+func TestContextMissingProcessTree(t *testing.T) {
+	rule := &rules.Rule{IncludeProcessTree: true}
+	tests := []struct {
+		name  string
+		store *lineage.Store
+		msg   *santapb.SantaMessage
+	}{
+		{name: "lineage not tracked", msg: extraContextMessage()},
+		{name: "not an execution", store: lineage.NewStore(lineage.Config{}), msg: &santapb.SantaMessage{
+			Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := NewGenerator("test-host", tt.store)
+			for range 2 {
+				signal := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: tt.msg, Rule: rule})
+				if missing, _ := signal.Context["context_missing"].([]string); len(missing) != 1 || missing[0] != "process_tree" {
+					t.Errorf("context_missing = %v, want [process_tree]", signal.Context["context_missing"])
+				}
+			}
+			if !gen.warned["SM-001\x00process_tree"] {
+				t.Error("Missing process_tree not recorded as warned")
+			}
+		})
+	}
+
+	// Complete context is not flagged
+	signal := NewGenerator("test-host", nil).FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: extraContextMessage()})
+	if _, ok := signal.Context["context_missing"]; ok {
+		t.Error("context_missing set for a rule that requests no context")
+	}
+}
+
+func TestProcessTreeDepthAndChildren(t *testing.T) {
+	store := lineage.NewStore(lineage.Config{})
+	exec := func(pid, parent int32, path string) *santapb.SantaMessage {
+		msg := &santapb.SantaMessage{
+			BootSessionUuid: proto.String("boot"),
+			Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+				Target: &santapb.ProcessInfo{
+					Id:         &santapb.ProcessID{Pid: proto.Int32(pid), Pidversion: proto.Int32(1)},
+					ParentId:   &santapb.ProcessID{Pid: proto.Int32(parent), Pidversion: proto.Int32(1)},
+					Executable: &santapb.FileInfo{Path: proto.String(path)},
+				},
+			}},
+		}
+		store.UpsertFromExecution(msg, msg.GetExecution())
+		return msg
+	}
+	exec(10, 1, "/bin/zsh")
+	target := exec(20, 10, "/usr/bin/python3")
+	exec(30, 20, "/usr/bin/curl")
+	exec(40, 30, "/bin/sh")
+
+	rule := &rules.Rule{IncludeProcessTree: true, ProcessTreeDepth: 1, IncludeChildren: true}
+	signal := NewGenerator("test-host", store).FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: target, Rule: rule})
+
+	if tree, _ := signal.Context["process_tree"].([]map[string]any); len(tree) != 1 || tree[0]["path"] != "/usr/bin/python3" {
+		t.Errorf("process_tree = %v, want only the target", signal.Context["process_tree"])
+	}
+	children, _ := signal.Context["process_children"].([]map[string]any)
+	if len(children) != 1 || children[0]["path"] != "/usr/bin/curl" || children[0]["relation"] != "child" {
+		t.Errorf("process_children = %v, want curl only", signal.Context["process_children"])
+	}
+}
+
+func TestRuleMetadata(t *testing.T) {
+	meta := rules.Metadata{
+		References:     []string{"https://attack.mitre.org/techniques/T1059/004/"},
+		Author:         "Detection Team",
+		Created:        "2026-01-15",
+		Modified:       "2026-09-30",
+		FalsePositives: "Homebrew install scripts.\n",
+		Pack:           "community",
+	}
+	gen := NewGenerator("test-host", nil)
+	signals := map[string]*state.Signal{
+		"rule": gen.FromRuleMatch(&rules.Match{RuleID: "R1", Message: extraContextMessage(), Rule: &rules.Rule{ID: "R1", Metadata: meta}}),
+		"correlation": gen.FromWindowMatch(&correlation.WindowMatch{
+			RuleID: "C1",
+			Events: []map[string]any{{"execution": map[string]any{}}},
+			Rule:   &rules.CorrelationRule{ID: "C1", Metadata: meta},
+		}, "boot-123"),
+		"baseline": gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "B1", Message: extraContextMessage(), Rule: &rules.BaselineRule{ID: "B1", Metadata: meta}}),
+	}
+	for name, sig := range signals {
+		ctx := sig.Context
+		if refs, ok := ctx["rule_references"].([]string); !ok || len(refs) != 1 {
+			t.Errorf("%s: rule_references = %v", name, ctx["rule_references"])
+		}
+		if ctx["rule_author"] != "Detection Team" || ctx["rule_created"] != "2026-01-15" || ctx["rule_modified"] != "2026-09-30" {
+			t.Errorf("%s: unexpected metadata context: %v", name, ctx)
+		}
+		if ctx["rule_false_positives"] != "Homebrew install scripts." {
+			t.Errorf("%s: rule_false_positives = %q", name, ctx["rule_false_positives"])
+		}
+		if ctx["rule_pack"] != "community" {
+			t.Errorf("%s: rule_pack = %q", name, ctx["rule_pack"])
+		}
+	}
+
+	// Rules without metadata add nothing
+	sig := gen.FromRuleMatch(&rules.Match{RuleID: "R2", Message: extraContextMessage(), Rule: &rules.Rule{ID: "R2"}})
+	for k := range sig.Context {
+		if strings.HasPrefix(k, "rule_") {
+			t.Errorf("Unexpected %s in context without metadata", k)
+		}
+	}
+}
+
+func TestIdentityEnrichment(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	gen.SetIdentityProvider(stubIdentities{
+		"alice": {Email: "alice@example.com", Department: "Engineering"},
+	})
+
+	execAs := func(name string) *santapb.SantaMessage {
+		return &santapb.SantaMessage{
+			Event: &santapb.SantaMessage_Execution{
+				Execution: &santapb.Execution{
+					Instigator: &santapb.ProcessInfoLight{
+						RealUser: &santapb.UserInfo{Uid: proto.Int32(501), Name: proto.String(name)},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		user      string
+		wantEmail any
+		wantDept  any
+	}{
+		{"alice", "alice@example.com", "Engineering"},
+		{"bob", nil, nil},
+		{"broken", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: execAs(tt.user)})
+			if sig.Context["user_email"] != tt.wantEmail {
+				t.Errorf("user_email = %v, want %v", sig.Context["user_email"], tt.wantEmail)
+			}
+			if sig.Context["department"] != tt.wantDept {
+				t.Errorf("department = %v, want %v", sig.Context["department"], tt.wantDept)
+			}
+		})
+	}
+
+	// Correlation signals resolve the user from the sample event map
+	win := gen.FromWindowMatch(&correlation.WindowMatch{
+		RuleID: "SM-CORR",
+		Count:  1,
+		Events: []map[string]any{{
+			"kind": "execution",
+			"execution": map[string]any{
+				"instigator": map[string]any{
+					"real_user": map[string]any{"uid": 501, "name": "alice"},
+				},
+			},
+		}},
+	}, "boot-123")
+	if win.Context["user_email"] != "alice@example.com" {
+		t.Errorf("Window user_email = %v, want alice@example.com", win.Context["user_email"])
+	}
+}
+
+// stubIntel lists indicators by feed name
+type stubIntel map[string][]string
+
+func (s stubIntel) Match(value string) []string {
+	return s[value]
+}
+
+func TestIntelEnrichment(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	gen.SetIntel(stubIntel{
+		"EQHXZ8M8AV": {"partner-feed", "abuse-feed"},
+		"/bin/sh":    {"paths"}, // Paths are not looked up
+	})
+
+	sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: extraContextMessage()})
+	feeds, _ := sig.Context["intel_matches"].([]string)
+	if strings.Join(feeds, ",") != "abuse-feed,partner-feed" {
+		t.Errorf("intel_matches = %v, want [abuse-feed partner-feed]", sig.Context["intel_matches"])
+	}
+	indicators, _ := sig.Context["intel_indicators"].([]string)
+	if len(indicators) != 1 || indicators[0] != "EQHXZ8M8AV" {
+		t.Errorf("intel_indicators = %v, want [EQHXZ8M8AV]", sig.Context["intel_indicators"])
+	}
+
+	baseline := gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage()})
+	if _, ok := baseline.Context["intel_matches"]; !ok {
+		t.Error("Baseline signal not enriched with intel_matches")
+	}
+
+	gen.SetIntel(stubIntel{})
+	if sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: extraContextMessage()}); sig.Context["intel_matches"] != nil {
+		t.Errorf("Unexpected intel_matches: %v", sig.Context["intel_matches"])
+	}
+}
+
+// Signing ID indicators are TEAMID:signing_id or platform:signing_id, while
+// telemetry carries the bare signing ID
+func TestIntelSigningIDs(t *testing.T) {
+	feed := filepath.Join(t.TempDir(), "signing.csv")
+	if err := os.WriteFile(feed, []byte("EQHXZ8M8AV:com.evil.tool\nplatform:com.apple.curl\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := intel.NewStore(config.IntelConfig{Feeds: []config.FeedConfig{{Name: "signing", Path: feed, Format: "csv"}}})
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Failed to load feed: %v", err)
+	}
+	gen := NewGenerator("test-host", nil)
+	gen.SetIntel(store)
+
+	exec := func(team string, platform bool) *santapb.SantaMessage {
+		return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: &santapb.Execution{
+			Target: &santapb.ProcessInfo{
+				IsPlatformBinary: proto.Bool(platform),
+				CodeSignature:    &santapb.CodeSignature{TeamId: proto.String(team), SigningId: proto.String("com.evil.tool")},
+				Executable:       &santapb.FileInfo{Path: proto.String("/tmp/tool")},
+			},
+		}}}
+	}
+	access := &santapb.SantaMessage{Event: &santapb.SantaMessage_FileAccess{FileAccess: &santapb.FileAccess{
+		Instigator: &santapb.ProcessInfo{
+			IsPlatformBinary: proto.Bool(true),
+			CodeSignature:    &santapb.CodeSignature{SigningId: proto.String("com.apple.curl")},
+		},
+	}}}
+
+	tests := []struct {
+		name string
+		msg  *santapb.SantaMessage
+		want string
+	}{
+		{"team signed target", exec("EQHXZ8M8AV", false), "EQHXZ8M8AV:com.evil.tool"},
+		{"platform actor", access, "platform:com.apple.curl"},
+		{"other team", exec("ABCDE12345", false), ""},
+		{"ad hoc", exec("", false), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := gen.FromRuleMatch(&rules.Match{RuleID: "SM-001", Message: tt.msg})
+			indicators, _ := sig.Context["intel_indicators"].([]string)
+			if got := strings.Join(indicators, ","); got != tt.want {
+				t.Errorf("intel_indicators = %v, want %q", indicators, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromBaselineMatchLastSeen(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+
+	sig := gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage()})
+	if _, ok := sig.Context["last_seen"]; ok {
+		t.Errorf("Unexpected last_seen for a new pattern: %v", sig.Context["last_seen"])
+	}
+
+	lastSeen := time.Date(2026, 6, 1, 8, 30, 0, 0, time.UTC)
+	sig = gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage(), LastSeen: lastSeen})
+	if sig.Context["last_seen"] != "2026-06-01T08:30:00Z" {
+		t.Errorf("last_seen = %v, want 2026-06-01T08:30:00Z", sig.Context["last_seen"])
+	}
+}
+
+func TestFromBaselineMatchOccurrences(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	rule := &rules.BaselineRule{ID: "BL-1", MinOccurrences: 3}
+
+	sig := gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage(), Occurrences: 2, Rule: rule})
+	if sig.Context["occurrences"] != 2 {
+		t.Errorf("occurrences = %v, want 2", sig.Context["occurrences"])
+	}
+	sig = gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage(), Occurrences: 1})
+	if _, ok := sig.Context["occurrences"]; ok {
+		t.Error("Unexpected occurrences without min_occurrences")
+	}
+}
+
+func TestFromBaselineMatchScope(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+
+	sig := gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage(), Scope: "execution.target.effective_user.name=svc"})
+	if sig.Context["scope"] != "execution.target.effective_user.name=svc" {
+		t.Errorf("scope = %v", sig.Context["scope"])
+	}
+	sig = gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage()})
+	if _, ok := sig.Context["scope"]; ok {
+		t.Error("Unexpected scope for an unscoped rule")
+	}
+}
+
+func TestFromLearningSummary(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	end := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	summary := &baseline.LearningSummary{
+		Rule:      &rules.BaselineRule{ID: "BL-1", Title: "New binary", Severity: "high", Tags: []string{"T1204"}},
+		Start:     end.Add(-720 * time.Hour),
+		End:       end,
+		Patterns:  []string{"path=/bin/ls", "path=/bin/cat"},
+		Total:     150,
+		Sightings: 4000,
+	}
+	sig := gen.FromLearningSummary(summary)
+	if sig.Severity != "info" || sig.Title != "Baseline learning complete: New binary" || !sig.TS.Equal(end) {
+		t.Errorf("Unexpected signal: %s %q %v", sig.Severity, sig.Title, sig.TS)
+	}
+	if !slices.Equal(sig.Tags, []string{"T1204", "baseline", "learning_summary"}) {
+		t.Errorf("tags = %v", sig.Tags)
+	}
+	if sig.Context["learning_started"] != "2026-05-02T00:00:00Z" || sig.Context["learned_total"] != 150 || sig.Context["learned_truncated"] != true {
+		t.Errorf("Unexpected context: %v", sig.Context)
+	}
+
+	// The summary of a rule's learning period has a stable ID
+	if again := gen.FromLearningSummary(summary); again.ID != sig.ID {
+		t.Error("Learning summary ID is not deterministic")
+	}
+}
+
+func TestFromHealth(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	ts := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	sig := gen.FromHealth("spool_pressure", "medium", "Spool under pressure", ts, map[string]any{"spool_bytes": int64(42)})
+	if sig.RuleID != "santamon.spool_pressure" || sig.Severity != "medium" || sig.HostID != "test-host" || !sig.TS.Equal(ts) {
+		t.Errorf("Unexpected signal: %+v", sig)
+	}
+	if !slices.Equal(sig.Tags, []string{"santamon", "health"}) || sig.Context["spool_bytes"] != int64(42) {
+		t.Errorf("Unexpected tags or context: %v %v", sig.Tags, sig.Context)
+	}
+}
+
+type fixedInventory struct{ inv *inventory.Inventory }
+
+func (f fixedInventory) Current() *inventory.Inventory { return f.inv }
+
+func TestInventoryContext(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	src := &fixedInventory{}
+	gen.SetInventory(src)
+
+	sig := gen.FromRuleMatch(&rules.Match{RuleID: "R-1", Message: extraContextMessage()})
+	if _, ok := sig.Context["host_os_version"]; ok {
+		t.Error("Unexpected inventory context before the first collection")
+	}
+
+	src.inv = &inventory.Inventory{
+		OSVersion:    "14.2.1",
+		Model:        "Mac14,2",
+		HardwareUUID: "5D3F1C2A-8B7E-4F10-9A2B-3C4D5E6F7081",
+		SantaMode:    "lockdown",
+	}
+	for _, sig := range []*state.Signal{
+		gen.FromRuleMatch(&rules.Match{RuleID: "R-1", Message: extraContextMessage()}),
+		gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage()}),
+		gen.FromHealth("spool_pressure", "medium", "Spool under pressure", time.Now(), nil),
+	} {
+		if sig.Context["host_os_version"] != "14.2.1" || sig.Context["host_model"] != "Mac14,2" ||
+			sig.Context["host_uuid"] != "5D3F1C2A-8B7E-4F10-9A2B-3C4D5E6F7081" || sig.Context["santa_mode"] != "lockdown" {
+			t.Errorf("%s: unexpected inventory context: %v", sig.RuleID, sig.Context)
+		}
+		if _, ok := sig.Context["santa_version"]; ok {
+			t.Errorf("%s: empty inventory fields should be left out", sig.RuleID)
+		}
+	}
+}
+
+func TestSessionContext(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+	tracker := session.NewTracker()
+	gen.SetSessions(tracker)
+
+	sudo := extraContextMessage()
+	sudo.GetExecution().GetTarget().EffectiveUser = &santapb.UserInfo{Name: proto.String("root"), Uid: proto.Int32(0)}
+
+	sig := gen.FromRuleMatch(&rules.Match{RuleID: "R-1", Message: sudo})
+	if sig.Context["effective_user"] != "root" {
+		t.Errorf("effective_user = %v, want root", sig.Context["effective_user"])
+	}
+	for _, key := range []string{"console_user", "effective_user_differs"} {
+		if _, ok := sig.Context[key]; ok {
+			t.Errorf("Unexpected %s without a known console user", key)
+		}
+	}
+
+	tracker.Seed(session.Console{User: "alice", UID: 501})
+	sig = gen.FromRuleMatch(&rules.Match{RuleID: "R-1", Message: sudo})
+	if sig.Context["console_user"] != "alice" || sig.Context["console_locked"] != false || sig.Context["effective_user_differs"] != true {
+		t.Errorf("Unexpected session context: %v", sig.Context)
+	}
+
+	sudo.GetExecution().GetTarget().EffectiveUser.Name = proto.String("alice")
+	sig = gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: sudo})
+	if sig.Context["effective_user_differs"] != false {
+		t.Errorf("effective_user_differs = %v, want false", sig.Context["effective_user_differs"])
+	}
+}
+
+func TestFromBaselineMatchRarity(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+
+	sig := gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage(), Patterns: 40, Sightings: 200, Rarity: 0.995})
+	if sig.Context["rarity"] != 0.995 || sig.Context["rule_patterns"] != 40 || sig.Context["rule_sightings"] != 200 {
+		t.Errorf("Unexpected rarity context: %v %v %v", sig.Context["rarity"], sig.Context["rule_patterns"], sig.Context["rule_sightings"])
+	}
+	sig = gen.FromBaselineMatch(&baseline.BaselineMatch{RuleID: "BL-1", Message: extraContextMessage()})
+	if _, ok := sig.Context["rarity"]; ok {
+		t.Error("Unexpected rarity without statistics")
+	}
+}
+
 func TestFromWindowMatch(t *testing.T) {
 	gen := NewGenerator("test-host", nil)
 
@@ -167,6 +818,78 @@ func TestFromWindowMatch(t *testing.T) {
 	}
 }
 
+func TestFromWindowMatchAbsence(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+
+	wmatch := &correlation.WindowMatch{
+		RuleID:   "SM-ABS-001",
+		Severity: "medium",
+		Title:    "Login without screen lock",
+		GroupKey: "_global",
+		Count:    1,
+		Events:   []map[string]any{{"machine_id": "m1"}},
+		Rule: &rules.CorrelationRule{
+			ID:     "SM-ABS-001",
+			Expr:   "kind == 'login_logout'",
+			Expect: "kind == 'screen_sharing'",
+		},
+	}
+
+	signal := gen.FromWindowMatch(wmatch, "boot-456")
+	if signal.Context["window_type"] != "absence" {
+		t.Errorf("Context window_type = %v, want absence", signal.Context["window_type"])
+	}
+	if signal.Context["expected"] != "kind == 'screen_sharing'" {
+		t.Errorf("Context expected = %v, want the expect expression", signal.Context["expected"])
+	}
+}
+
+func TestFromWindowMatchRate(t *testing.T) {
+	gen := NewGenerator("test-host", nil)
+
+	wmatch := &correlation.WindowMatch{
+		RuleID:   "SM-RATE-001",
+		Severity: "high",
+		Title:    "Fork bomb",
+		GroupKey: "_global",
+		Count:    512,
+		Rate:     51.234,
+		Events:   []map[string]any{{"machine_id": "m1"}},
+		Rule:     &rules.CorrelationRule{ID: "SM-RATE-001", Rate: 50, Window: 10 * time.Second},
+	}
+
+	signal := gen.FromWindowMatch(wmatch, "boot-456")
+	if _, ok := signal.Context["hosts"]; ok {
+		t.Error("Context has hosts without cross-host windows")
+	}
+	if signal.Context["window_type"] != "rate" {
+		t.Errorf("Context window_type = %v, want rate", signal.Context["window_type"])
+	}
+	if signal.Context["event_rate"] != 51.23 {
+		t.Errorf("Context event_rate = %v, want 51.23", signal.Context["event_rate"])
+	}
+}
+
+func TestFromWindowMatchHosts(t *testing.T) {
+	gen := NewGenerator("collector", nil)
+
+	wmatch := &correlation.WindowMatch{
+		RuleID:   "SM-FLEET-001",
+		Severity: "high",
+		Title:    "Hash on many hosts",
+		GroupKey: "execution.target.executable.hash.hash=abc",
+		Count:    2,
+		Events:   []map[string]any{{"machine_id": "m1"}, {"machine_id": "m2"}},
+		Hosts:    []string{"m1", "m2"},
+	}
+
+	signal := gen.FromWindowMatch(wmatch, "")
+	hosts, ok := signal.Context["hosts"].([]string)
+	if !ok || len(hosts) != 2 || hosts[1] != "m2" {
+		t.Errorf("Context hosts = %v, want [m1 m2]", signal.Context["hosts"])
+	}
+}
+
 func TestFromWindowMatchNoEvents(t *testing.T) {
 	gen := NewGenerator("test-host", nil)
 
@@ -408,7 +1131,7 @@ func TestFromWindowMatchWithDistinctValues(t *testing.T) {
 
 	rule := &rules.CorrelationRule{
 		ID:            "SM-COR-001",
-		CountDistinct: "event.file_access.policy_name",
+		CountDistinct: rules.Fields{"event.file_access.policy_name"},
 		GroupBy:       []string{"event.file_access.instigator.executable.path"},
 	}
 
@@ -472,3 +1195,68 @@ func TestFromWindowMatchWithDistinctValues(t *testing.T) {
 			groupedBy["file_access.instigator.executable.path"])
 	}
 }
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONWriter(&buf)
+
+	for _, sig := range []*state.Signal{
+		{ID: "sig-1", RuleID: "R1", Context: map[string]any{"target_path": "/tmp/a&b"}},
+		{ID: "sig-2", RuleID: "R2"},
+	} {
+		if err := w.Write(sig); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	var got state.Signal
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("Failed to parse line: %v", err)
+	}
+	if got.ID != "sig-1" || got.Context["target_path"] != "/tmp/a&b" {
+		t.Errorf("Unexpected signal: %+v", got)
+	}
+	if !strings.Contains(lines[0], "/tmp/a&b") {
+		t.Errorf("HTML characters should not be escaped: %s", lines[0])
+	}
+}
+
+// BenchmarkFromRuleMatchExtraContext measures a matched signal with typical
+// extra_context fields, read from the typed message
+func BenchmarkFromRuleMatchExtraContext(b *testing.B) {
+	gen := NewGenerator("test-host", nil)
+	match := &rules.Match{
+		RuleID:  "SM-001",
+		Message: extraContextMessage(),
+		Rule: &rules.Rule{ExtraContext: []string{
+			"event.execution.args",
+			"event.execution.decision",
+			"event.execution.target.code_signature.team_id",
+		}},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		gen.FromRuleMatch(match)
+	}
+}
+
+// BenchmarkFromRuleMatchIncludeEvent measures a matched signal that needs the
+// full event map, as every extra_context signal did before typed lookups
+func BenchmarkFromRuleMatchIncludeEvent(b *testing.B) {
+	gen := NewGenerator("test-host", nil)
+	match := &rules.Match{
+		RuleID:  "SM-001",
+		Message: extraContextMessage(),
+		Rule:    &rules.Rule{IncludeEvent: true},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		gen.FromRuleMatch(match)
+	}
+}
@@ -0,0 +1,734 @@
+package simulate
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Options configures synthetic event generation
+type Options struct {
+	Kinds    []string      // Event kinds, generated in turn (default: all kinds)
+	Count    int           // Number of events
+	DenyRate float64       // Share of executions and file accesses that are denied (0-1)
+	Start    time.Time     // Time of the first event (default: now)
+	Interval time.Duration // Time between events (default: 100ms)
+	Seed     uint64        // Random seed; the same seed produces the same events
+}
+
+// Generator produces synthetic Santa events that look like a macOS endpoint:
+// signed apps and platform binaries, a few unsigned downloads, one local user
+type Generator struct {
+	opts     Options
+	rng      *rand.Rand
+	builders []func() *santapb.SantaMessage
+
+	machineID string
+	bootUUID  string
+	now       time.Time
+	nextPid   int32
+	generated int
+}
+
+// binary is a program the generator runs or uses as an instigator
+type binary struct {
+	path      string
+	signingID string
+	teamID    string
+	platform  bool
+}
+
+var (
+	launchd = binary{path: "/sbin/launchd", signingID: "com.apple.xpc.launchd", platform: true}
+	shells  = []binary{
+		{path: "/bin/zsh", signingID: "com.apple.zsh", platform: true},
+		{path: "/bin/bash", signingID: "com.apple.bash", platform: true},
+		{path: "/System/Applications/Utilities/Terminal.app/Contents/MacOS/Terminal", signingID: "com.apple.Terminal", platform: true},
+	}
+	// signed are the targets of allowed executions
+	signed = []binary{
+		{path: "/usr/bin/curl", signingID: "com.apple.curl", platform: true},
+		{path: "/usr/bin/git", signingID: "com.apple.git", platform: true},
+		{path: "/usr/bin/osascript", signingID: "com.apple.osascript", platform: true},
+		{path: "/usr/bin/python3", signingID: "com.apple.python3", platform: true},
+		{path: "/usr/bin/ssh", signingID: "com.apple.openssh", platform: true},
+		{path: "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome", signingID: "EQHXZ8M8AV:com.google.Chrome", teamID: "EQHXZ8M8AV"},
+		{path: "/Applications/Slack.app/Contents/MacOS/Slack", signingID: "BQR82RBBHL:com.tinyspeck.slackmacgap", teamID: "BQR82RBBHL"},
+		{path: "/Applications/Visual Studio Code.app/Contents/MacOS/Electron", signingID: "UBF8T346G9:com.microsoft.VSCode", teamID: "UBF8T346G9"},
+		{path: "/Applications/zoom.us.app/Contents/MacOS/zoom.us", signingID: "BJ4HAAB9B3:us.zoom.xos", teamID: "BJ4HAAB9B3"},
+	}
+	// unsigned are the targets of denied executions
+	unsigned = []binary{
+		{path: "/Users/alice/Downloads/Installer"},
+		{path: "/private/tmp/update"},
+		{path: "/Users/alice/Library/Application Support/.helper/agent"},
+		{path: "/opt/homebrew/Cellar/tool/1.0/bin/tool"},
+	}
+	// protectedFiles are watched by file access policies, keyed by path
+	protectedFiles = []struct{ path, policy string }{
+		{path: "/Users/alice/Library/Application Support/Google/Chrome/Default/Cookies", policy: "ChromeCookies"},
+		{path: "/Users/alice/Library/Application Support/Google/Chrome/Default/Login Data", policy: "ChromeLoginData"},
+		{path: "/Users/alice/Library/Keychains/login.keychain-db", policy: "Keychains"},
+		{path: "/Users/alice/.ssh/id_ed25519", policy: "SSHKeys"},
+		{path: "/Users/alice/.aws/credentials", policy: "CloudCredentials"},
+	}
+	userFiles = []string{
+		"/Users/alice/Documents/report.docx",
+		"/Users/alice/Desktop/notes.txt",
+		"/Users/alice/Downloads/archive.zip",
+		"/private/tmp/build.log",
+	}
+	tccServices = []string{
+		"kTCCServiceScreenCapture",
+		"kTCCServiceMicrophone",
+		"kTCCServiceCamera",
+		"kTCCServiceSystemPolicyAllFiles",
+		"kTCCServiceAccessibility",
+	}
+	alice = &santapb.UserInfo{Uid: proto.Int32(501), Name: proto.String("alice")}
+	root  = &santapb.UserInfo{Uid: proto.Int32(0), Name: proto.String("root")}
+	staff = &santapb.GroupInfo{Gid: proto.Int32(20), Name: proto.String("staff")}
+	wheel = &santapb.GroupInfo{Gid: proto.Int32(0), Name: proto.String("wheel")}
+)
+
+// Kinds returns the event kinds the generator supports
+func Kinds() []string {
+	return []string{
+		"execution", "fork", "exit", "close", "rename", "unlink", "link",
+		"exchangedata", "disk", "bundle", "allowlist", "file_access",
+		"codesigning_invalidated", "login_window_session", "login_logout",
+		"screen_sharing", "open_ssh", "authentication", "clone", "copyfile",
+		"gatekeeper_override", "launch_item", "tcc_modification", "xprotect",
+	}
+}
+
+// New creates a Generator
+func New(opts Options) (*Generator, error) {
+	if opts.Count < 0 {
+		return nil, fmt.Errorf("count must be positive")
+	}
+	if opts.DenyRate < 0 || opts.DenyRate > 1 {
+		return nil, fmt.Errorf("deny rate must be between 0 and 1")
+	}
+	if opts.Interval < 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = 100 * time.Millisecond
+	}
+	if opts.Start.IsZero() {
+		opts.Start = time.Now()
+	}
+	if len(opts.Kinds) == 0 {
+		opts.Kinds = Kinds()
+	}
+
+	g := &Generator{
+		opts:    opts,
+		rng:     rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x5a17a)),
+		now:     opts.Start,
+		nextPid: 400,
+	}
+	g.machineID = fmt.Sprintf("%08X-%04X-%04X-%04X-%012X", g.rng.Uint32(), g.rng.Uint32N(1<<16), g.rng.Uint32N(1<<16), g.rng.Uint32N(1<<16), g.rng.Uint64N(1<<48))
+	g.bootUUID = fmt.Sprintf("%08X-%04X-%04X-%04X-%012X", g.rng.Uint32(), g.rng.Uint32N(1<<16), g.rng.Uint32N(1<<16), g.rng.Uint32N(1<<16), g.rng.Uint64N(1<<48))
+
+	builders := map[string]func() *santapb.SantaMessage{
+		"execution":               g.execution,
+		"fork":                    g.fork,
+		"exit":                    g.exit,
+		"close":                   g.close,
+		"rename":                  g.rename,
+		"unlink":                  g.unlink,
+		"link":                    g.link,
+		"exchangedata":            g.exchangedata,
+		"disk":                    g.disk,
+		"bundle":                  g.bundle,
+		"allowlist":               g.allowlist,
+		"file_access":             g.fileAccess,
+		"codesigning_invalidated": g.codesigningInvalidated,
+		"login_window_session":    g.loginWindowSession,
+		"login_logout":            g.loginLogout,
+		"screen_sharing":          g.screenSharing,
+		"open_ssh":                g.openSSH,
+		"authentication":          g.authentication,
+		"clone":                   g.clone,
+		"copyfile":                g.copyfile,
+		"gatekeeper_override":     g.gatekeeperOverride,
+		"launch_item":             g.launchItem,
+		"tcc_modification":        g.tccModification,
+		"xprotect":                g.xprotect,
+	}
+	for _, kind := range opts.Kinds {
+		build, ok := builders[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown event kind %q (supported: %s)", kind, strings.Join(Kinds(), ", "))
+		}
+		g.builders = append(g.builders, build)
+	}
+	return g, nil
+}
+
+// Generate returns opts.Count events, cycling through the kinds
+func (g *Generator) Generate() []*santapb.SantaMessage {
+	msgs := make([]*santapb.SantaMessage, 0, g.opts.Count)
+	for range g.opts.Count {
+		msgs = append(msgs, g.Next())
+	}
+	return msgs
+}
+
+// Next returns the next event
+func (g *Generator) Next() *santapb.SantaMessage {
+	build := g.builders[g.generated%len(g.builders)]
+	g.generated++
+	msg := build()
+	msg.MachineId = proto.String(g.machineID)
+	msg.BootSessionUuid = proto.String(g.bootUUID)
+	msg.EventTime = timestamppb.New(g.now)
+	msg.ProcessedTime = timestamppb.New(g.now.Add(time.Duration(g.rng.IntN(5)+1) * time.Millisecond))
+	g.now = g.now.Add(g.opts.Interval)
+	return msg
+}
+
+// denied reports whether the next decision should be a deny
+func (g *Generator) denied() bool {
+	return g.rng.Float64() < g.opts.DenyRate
+}
+
+func pick[T any](g *Generator, items []T) T {
+	return items[g.rng.IntN(len(items))]
+}
+
+// processID returns a fresh process ID
+func (g *Generator) processID() *santapb.ProcessID {
+	g.nextPid += int32(g.rng.IntN(20) + 1)
+	return &santapb.ProcessID{Pid: proto.Int32(g.nextPid), Pidversion: proto.Int32(int32(g.rng.IntN(100000) + 1000))}
+}
+
+// user returns the acting user and group for b
+func user(b binary) (*santapb.UserInfo, *santapb.GroupInfo) {
+	if b == launchd {
+		return root, wheel
+	}
+	return alice, staff
+}
+
+// light returns a ProcessInfoLight running b under parent
+func (g *Generator) light(b binary, id, parent *santapb.ProcessID) *santapb.ProcessInfoLight {
+	u, grp := user(b)
+	return &santapb.ProcessInfoLight{
+		Id:             id,
+		ParentId:       parent,
+		GroupId:        proto.Int32(id.GetPid()),
+		SessionId:      proto.Int32(1),
+		EffectiveUser:  u,
+		EffectiveGroup: grp,
+		RealUser:       u,
+		RealGroup:      grp,
+		Executable:     &santapb.FileInfoLight{Path: proto.String(b.path)},
+	}
+}
+
+// instigator returns a shell, launchd or, now and then, a browser
+func (g *Generator) instigator() *santapb.ProcessInfoLight {
+	b := launchd
+	switch g.rng.IntN(8) {
+	case 0, 1:
+	case 2:
+		b = signed[5] // Google Chrome
+	default:
+		b = pick(g, shells)
+	}
+	parent := &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)}
+	if b == launchd {
+		return g.light(b, parent, &santapb.ProcessID{Pid: proto.Int32(0), Pidversion: proto.Int32(0)})
+	}
+	return g.light(b, g.processID(), parent)
+}
+
+// process returns a full ProcessInfo running b
+func (g *Generator) process(b binary, id, parent *santapb.ProcessID) *santapb.ProcessInfo {
+	u, grp := user(b)
+	p := &santapb.ProcessInfo{
+		Id:               id,
+		ParentId:         parent,
+		ResponsibleId:    parent,
+		GroupId:          proto.Int32(parent.GetPid()),
+		SessionId:        proto.Int32(1),
+		EffectiveUser:    u,
+		EffectiveGroup:   grp,
+		RealUser:         u,
+		RealGroup:        grp,
+		IsPlatformBinary: proto.Bool(b.platform),
+		IsEsClient:       proto.Bool(false),
+		Executable:       fileInfo(b.path),
+		StartTime:        timestamppb.New(g.now),
+	}
+	if b.signingID != "" {
+		cdhash := sha256.Sum256([]byte("cdhash|" + b.path))
+		p.CodeSignature = &santapb.CodeSignature{Cdhash: cdhash[:20], SigningId: proto.String(b.signingID)}
+		if b.teamID != "" {
+			p.CodeSignature.TeamId = proto.String(b.teamID)
+		}
+		p.CsFlags = proto.Uint32(0x26000001) // CS_VALID | CS_SIGNED | CS_RUNTIME
+	}
+	return p
+}
+
+// fileInfo returns a FileInfo with a SHA-256 derived from path
+func fileInfo(path string) *santapb.FileInfo {
+	algo := santapb.Hash_HASH_ALGO_SHA256
+	sum := sha256.Sum256([]byte(path))
+	return &santapb.FileInfo{
+		Path: proto.String(path),
+		Hash: &santapb.Hash{Type: &algo, Hash: proto.String(fmt.Sprintf("%x", sum))},
+	}
+}
+
+func (g *Generator) execution() *santapb.SantaMessage {
+	parent := g.instigator()
+	exec := &santapb.Execution{
+		Instigator:       parent,
+		WorkingDirectory: &santapb.FileInfo{Path: proto.String("/Users/alice")},
+		Mode:             santapb.Execution_MODE_LOCKDOWN.Enum(),
+	}
+	b := pick(g, signed)
+	if g.denied() {
+		b = pick(g, unsigned)
+		exec.Decision = santapb.Execution_DECISION_DENY.Enum()
+		exec.Reason = santapb.Execution_REASON_BINARY.Enum()
+		exec.Explain = proto.String("unsigned binary blocked by default rule")
+		if strings.Contains(b.path, "/Downloads/") {
+			exec.QuarantineUrl = proto.String("https://example.com/download/Installer")
+		}
+	} else {
+		exec.Decision = santapb.Execution_DECISION_ALLOW.Enum()
+		exec.Reason = santapb.Execution_REASON_CERT.Enum()
+		if b.teamID != "" {
+			exec.Reason = santapb.Execution_REASON_TEAM_ID.Enum()
+		} else if b.platform {
+			exec.Reason = santapb.Execution_REASON_SIGNING_ID.Enum()
+		}
+	}
+	exec.Target = g.process(b, g.processID(), parent.GetId())
+	exec.Args = args(g, b)
+	exec.Envs = [][]byte{[]byte("HOME=/Users/alice"), []byte("SHELL=/bin/zsh"), []byte("PATH=/usr/bin:/bin:/usr/sbin:/sbin")}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Execution{Execution: exec}}
+}
+
+// args returns a plausible command line for b
+func args(g *Generator, b binary) [][]byte {
+	var argv []string
+	switch filepath.Base(b.path) {
+	case "curl":
+		argv = []string{"curl", "-fsSL", pick(g, []string{"https://example.com/install.sh", "https://api.github.com/repos", "http://10.0.0.5:8080/payload"})}
+	case "git":
+		argv = []string{"git", pick(g, []string{"status", "fetch", "pull", "log"})}
+	case "osascript":
+		argv = []string{"osascript", "-e", pick(g, []string{`display notification "Build done"`, `tell application "Finder" to get name of every disk`})}
+	case "python3":
+		argv = []string{"python3", pick(g, []string{"-m", "-c"}), pick(g, []string{"http.server", "import sys; print(sys.version)"})}
+	case "ssh":
+		argv = []string{"ssh", pick(g, []string{"git@github.com", "build@10.0.0.12"})}
+	default:
+		argv = []string{b.path}
+	}
+	out := make([][]byte, len(argv))
+	for i, a := range argv {
+		out[i] = []byte(a)
+	}
+	return out
+}
+
+func (g *Generator) fork() *santapb.SantaMessage {
+	parent := g.instigator()
+	child := g.light(binary{path: parent.GetExecutable().GetPath()}, g.processID(), parent.GetId())
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Fork{Fork: &santapb.Fork{Instigator: parent, Child: child}}}
+}
+
+func (g *Generator) exit() *santapb.SantaMessage {
+	exit := &santapb.Exit{Instigator: g.light(pick(g, signed), g.processID(), g.instigator().GetId())}
+	if g.rng.IntN(10) == 0 {
+		exit.ExitType = &santapb.Exit_Signaled_{Signaled: &santapb.Exit_Signaled{Signal: proto.Int32(9)}}
+	} else {
+		exit.ExitType = &santapb.Exit_Exited_{Exited: &santapb.Exit_Exited{ExitStatus: proto.Int32(int32(g.rng.IntN(2)))}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Exit{Exit: exit}}
+}
+
+func (g *Generator) close() *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Close{Close: &santapb.Close{
+		Instigator: g.instigator(),
+		Target:     fileInfo(pick(g, userFiles)),
+		Modified:   proto.Bool(g.rng.IntN(2) == 0),
+	}}}
+}
+
+func (g *Generator) rename() *santapb.SantaMessage {
+	src := pick(g, userFiles)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Rename{Rename: &santapb.Rename{
+		Instigator:    g.instigator(),
+		Source:        fileInfo(src),
+		Target:        proto.String(src + ".bak"),
+		TargetExisted: proto.Bool(false),
+	}}}
+}
+
+func (g *Generator) unlink() *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Unlink{Unlink: &santapb.Unlink{
+		Instigator: g.instigator(),
+		Target:     fileInfo(pick(g, userFiles)),
+	}}}
+}
+
+func (g *Generator) link() *santapb.SantaMessage {
+	src := pick(g, userFiles)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Link{Link: &santapb.Link{
+		Instigator: g.instigator(),
+		Source:     fileInfo(src),
+		Target:     proto.String("/private/tmp/" + filepath.Base(src)),
+	}}}
+}
+
+func (g *Generator) exchangedata() *santapb.SantaMessage {
+	f := pick(g, userFiles)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Exchangedata{Exchangedata: &santapb.Exchangedata{
+		Instigator: g.instigator(),
+		File1:      fileInfo(f),
+		File2:      fileInfo(f + ".sb-tmp"),
+	}}}
+}
+
+func (g *Generator) disk() *santapb.SantaMessage {
+	action := santapb.Disk_ACTION_APPEARED
+	if g.rng.IntN(2) == 0 {
+		action = santapb.Disk_ACTION_DISAPPEARED
+	}
+	volume := pick(g, []string{"BACKUP", "Untitled", "KINGSTON"})
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Disk{Disk: &santapb.Disk{
+		Action:     &action,
+		Mount:      proto.String("/Volumes/" + volume),
+		Volume:     proto.String(volume),
+		BsdName:    proto.String(fmt.Sprintf("disk%ds1", g.rng.IntN(4)+4)),
+		Fs:         proto.String(pick(g, []string{"msdos", "apfs", "exfat"})),
+		Model:      proto.String("Flash Drive"),
+		Serial:     proto.String(fmt.Sprintf("%016X", g.rng.Uint64())),
+		Bus:        proto.String("USB"),
+		Appearance: timestamppb.New(g.now),
+	}}}
+}
+
+func (g *Generator) bundle() *santapb.SantaMessage {
+	b := pick(g, signed[5:])
+	app := b.path[:strings.Index(b.path, ".app/")+4]
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Bundle{Bundle: &santapb.Bundle{
+		FileHash:   fileInfo(b.path).Hash,
+		BundleHash: fileInfo(app).Hash,
+		BundleName: proto.String(strings.TrimSuffix(filepath.Base(app), ".app")),
+		BundleId:   proto.String(b.signingID[strings.Index(b.signingID, ":")+1:]),
+		BundlePath: proto.String(app),
+		Path:       proto.String(b.path),
+	}}}
+}
+
+func (g *Generator) allowlist() *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Allowlist{Allowlist: &santapb.Allowlist{
+		Instigator: g.light(pick(g, signed[5:]), g.processID(), g.instigator().GetId()),
+		Target:     fileInfo("/Users/alice/Library/Developer/Build/Products/Debug/app"),
+	}}}
+}
+
+func (g *Generator) fileAccess() *santapb.SantaMessage {
+	parent := g.instigator()
+	b := pick(g, signed[5:])
+	decision := santapb.FileAccess_POLICY_DECISION_ALLOWED_AUDIT_ONLY
+	if g.denied() {
+		b = pick(g, slices.Concat(unsigned, signed[:5]))
+		decision = santapb.FileAccess_POLICY_DECISION_DENIED
+	}
+	target := pick(g, protectedFiles)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_FileAccess{FileAccess: &santapb.FileAccess{
+		Instigator:     g.process(b, g.processID(), parent.GetId()),
+		Target:         &santapb.FileInfoLight{Path: proto.String(target.path)},
+		PolicyVersion:  proto.String("v1"),
+		PolicyName:     proto.String(target.policy),
+		AccessType:     santapb.FileAccess_ACCESS_TYPE_OPEN.Enum(),
+		PolicyDecision: &decision,
+		OperationId:    proto.String(fmt.Sprintf("%016x", g.rng.Uint64())),
+	}}}
+}
+
+func (g *Generator) codesigningInvalidated() *santapb.SantaMessage {
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_CodesigningInvalidated{CodesigningInvalidated: &santapb.CodesigningInvalidated{
+		Instigator: g.light(pick(g, signed[5:]), g.processID(), g.instigator().GetId()),
+	}}}
+}
+
+// loginwindow returns the loginwindow process
+func (g *Generator) loginwindow() *santapb.ProcessInfoLight {
+	return g.light(binary{path: "/System/Library/CoreServices/loginwindow.app/Contents/MacOS/loginwindow"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)})
+}
+
+func (g *Generator) loginWindowSession() *santapb.SantaMessage {
+	lw := g.loginwindow()
+	session := &santapb.GraphicalSession{Id: proto.Uint32(uint32(g.rng.IntN(5) + 256))}
+	ev := &santapb.LoginWindowSession{}
+	switch g.rng.IntN(4) {
+	case 0:
+		ev.Event = &santapb.LoginWindowSession_Login{Login: &santapb.LoginWindowSessionLogin{Instigator: lw, User: alice, GraphicalSession: session}}
+	case 1:
+		ev.Event = &santapb.LoginWindowSession_Logout{Logout: &santapb.LoginWindowSessionLogout{Instigator: lw, User: alice, GraphicalSession: session}}
+	case 2:
+		ev.Event = &santapb.LoginWindowSession_Lock{Lock: &santapb.LoginWindowSessionLock{Instigator: lw, User: alice, GraphicalSession: session}}
+	default:
+		ev.Event = &santapb.LoginWindowSession_Unlock{Unlock: &santapb.LoginWindowSessionUnlock{Instigator: lw, User: alice, GraphicalSession: session}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_LoginWindowSession{LoginWindowSession: ev}}
+}
+
+func (g *Generator) loginLogout() *santapb.SantaMessage {
+	proc := g.light(binary{path: "/usr/bin/login"}, g.processID(), g.instigator().GetId())
+	ev := &santapb.LoginLogout{}
+	if g.rng.IntN(2) == 0 {
+		login := &santapb.Login{Instigator: proc, Success: proto.Bool(true), User: alice}
+		if g.denied() {
+			login.Success = proto.Bool(false)
+			login.FailureMessage = []byte("Login incorrect")
+		}
+		ev.Event = &santapb.LoginLogout_Login{Login: login}
+	} else {
+		ev.Event = &santapb.LoginLogout_Logout{Logout: &santapb.Logout{Instigator: proc, User: alice}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_LoginLogout{LoginLogout: ev}}
+}
+
+// remoteAddress returns a private IPv4 peer address
+func (g *Generator) remoteAddress() *santapb.SocketAddress {
+	return &santapb.SocketAddress{
+		Address: fmt.Appendf(nil, "10.0.%d.%d", g.rng.IntN(4), g.rng.IntN(250)+2),
+		Type:    santapb.SocketAddress_TYPE_IPV4.Enum(),
+	}
+}
+
+func (g *Generator) screenSharing() *santapb.SantaMessage {
+	proc := g.light(binary{path: "/System/Library/CoreServices/RemoteManagement/ScreensharingAgent.bundle/Contents/MacOS/ScreensharingAgent"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)})
+	session := &santapb.GraphicalSession{Id: proto.Uint32(257)}
+	ev := &santapb.ScreenSharing{}
+	if g.rng.IntN(2) == 0 {
+		ev.Event = &santapb.ScreenSharing_Attach{Attach: &santapb.ScreenSharingAttach{
+			Instigator:         proc,
+			Success:            proto.Bool(!g.denied()),
+			Source:             g.remoteAddress(),
+			AuthenticationType: []byte("idp"),
+			AuthenticationUser: alice,
+			SessionUser:        alice,
+			ExistingSession:    proto.Bool(true),
+			GraphicalSession:   session,
+		}}
+	} else {
+		ev.Event = &santapb.ScreenSharing_Detach{Detach: &santapb.ScreenSharingDetach{Instigator: proc, Source: g.remoteAddress(), GraphicalSession: session}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_ScreenSharing{ScreenSharing: ev}}
+}
+
+func (g *Generator) openSSH() *santapb.SantaMessage {
+	sshd := g.light(binary{path: "/usr/sbin/sshd"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)})
+	ev := &santapb.OpenSSH{}
+	if g.rng.IntN(2) == 0 {
+		result := santapb.OpenSSHLogin_RESULT_AUTH_SUCCESS
+		if g.denied() {
+			result = pick(g, []santapb.OpenSSHLogin_Result{santapb.OpenSSHLogin_RESULT_AUTH_FAIL_PASSWD, santapb.OpenSSHLogin_RESULT_INVALID_USER})
+		}
+		ev.Event = &santapb.OpenSSH_Login{Login: &santapb.OpenSSHLogin{Instigator: sshd, Result: &result, Source: g.remoteAddress(), User: alice}}
+	} else {
+		ev.Event = &santapb.OpenSSH_Logout{Logout: &santapb.OpenSSHLogout{Instigator: sshd, Source: g.remoteAddress(), User: alice}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_OpenSsh{OpenSsh: ev}}
+}
+
+func (g *Generator) authentication() *santapb.SantaMessage {
+	proc := g.light(binary{path: "/System/Library/Frameworks/LocalAuthentication.framework/Support/coreauthd"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)})
+	trigger := g.light(pick(g, shells), g.processID(), proc.GetParentId())
+	ev := &santapb.Authentication{Success: proto.Bool(!g.denied())}
+	switch g.rng.IntN(3) {
+	case 0:
+		ev.Event = &santapb.Authentication_AuthenticationOd{AuthenticationOd: &santapb.AuthenticationOD{
+			Instigator:     proc,
+			AuthInstigator: &santapb.AuthenticationOD_TriggerProcess{TriggerProcess: trigger},
+			RecordType:     proto.String("User"),
+			RecordName:     proto.String("alice"),
+			NodeName:       proto.String("/Local/Default"),
+			DbPath:         proto.String("/var/db/dslocal/nodes/Default"),
+		}}
+	case 1:
+		ev.Event = &santapb.Authentication_AuthenticationTouchId{AuthenticationTouchId: &santapb.AuthenticationTouchID{
+			Instigator:     proc,
+			AuthInstigator: &santapb.AuthenticationTouchID_TriggerProcess{TriggerProcess: trigger},
+			Mode:           santapb.AuthenticationTouchID_MODE_VERIFICATION.Enum(),
+			User:           alice,
+		}}
+	default:
+		ev.Event = &santapb.Authentication_AuthenticationAutoUnlock{AuthenticationAutoUnlock: &santapb.AuthenticationAutoUnlock{
+			Instigator: proc,
+			UserInfo:   alice,
+			Type:       santapb.AuthenticationAutoUnlock_TYPE_MACHINE_UNLOCK,
+		}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Authentication{Authentication: ev}}
+}
+
+func (g *Generator) clone() *santapb.SantaMessage {
+	src := pick(g, userFiles)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Clone{Clone: &santapb.Clone{
+		Instigator: g.instigator(),
+		Source:     fileInfo(src),
+		Target:     proto.String(strings.TrimSuffix(src, filepath.Ext(src)) + " copy" + filepath.Ext(src)),
+	}}}
+}
+
+func (g *Generator) copyfile() *santapb.SantaMessage {
+	src := pick(g, userFiles)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Copyfile{Copyfile: &santapb.Copyfile{
+		Instigator:    g.instigator(),
+		Source:        fileInfo(src),
+		Target:        proto.String("/Volumes/BACKUP/" + filepath.Base(src)),
+		TargetExisted: proto.Bool(false),
+		Mode:          0o644,
+	}}}
+}
+
+func (g *Generator) gatekeeperOverride() *santapb.SantaMessage {
+	b := pick(g, unsigned)
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_GatekeeperOverride{GatekeeperOverride: &santapb.GatekeeperOverride{
+		Instigator: g.light(binary{path: "/usr/libexec/syspolicyd"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)}),
+		Target:     fileInfo(b.path),
+	}}}
+}
+
+func (g *Generator) launchItem() *santapb.SantaMessage {
+	b := pick(g, slices.Concat(signed[5:], unsigned))
+	action := santapb.LaunchItem_ACTION_ADD
+	if g.rng.IntN(4) == 0 {
+		action = santapb.LaunchItem_ACTION_REMOVE
+	}
+	label := "com.example." + strings.ToLower(strings.ReplaceAll(filepath.Base(b.path), " ", ""))
+	registrant := g.light(b, g.processID(), g.instigator().GetId())
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_LaunchItem{LaunchItem: &santapb.LaunchItem{
+		Instigator:     g.light(binary{path: "/System/Library/PrivateFrameworks/BackgroundTaskManagement.framework/Resources/backgroundtaskmanagementd"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)}),
+		Action:         action,
+		BtmInstigator:  &santapb.LaunchItem_TriggerProcess{TriggerProcess: registrant},
+		App:            &santapb.LaunchItem_RegistrantProcess{RegistrantProcess: registrant},
+		ItemType:       santapb.LaunchItem_ITEM_TYPE_AGENT,
+		Legacy:         proto.Bool(true),
+		Managed:        proto.Bool(false),
+		ItemUser:       alice,
+		ItemPath:       proto.String("/Users/alice/Library/LaunchAgents/" + label + ".plist"),
+		ExecutablePath: proto.String(b.path),
+	}}}
+}
+
+func (g *Generator) tccModification() *santapb.SantaMessage {
+	b := pick(g, signed[5:])
+	right := santapb.TCCModification_AUTHORIZATION_RIGHT_ALLOWED
+	if g.denied() {
+		right = santapb.TCCModification_AUTHORIZATION_RIGHT_DENIED
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_TccModification{TccModification: &santapb.TCCModification{
+		Instigator:          g.light(binary{path: "/System/Library/PrivateFrameworks/TCC.framework/Support/tccd"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)}),
+		Service:             proto.String(pick(g, tccServices)),
+		Identity:            proto.String(b.signingID[strings.Index(b.signingID, ":")+1:]),
+		IdentityType:        santapb.TCCModification_IDENTITY_TYPE_BUNDLE_ID.Enum(),
+		EventType:           santapb.TCCModification_EVENT_TYPE_MODIFY.Enum(),
+		AuthorizationRight:  &right,
+		AuthorizationReason: pick(g, []santapb.TCCModification_AuthorizationReason{santapb.TCCModification_AUTHORIZATION_REASON_USER_CONSENT, santapb.TCCModification_AUTHORIZATION_REASON_USER_CONSENT, santapb.TCCModification_AUTHORIZATION_REASON_MDM_POLICY}).Enum(),
+		TccInstigator:       &santapb.TCCModification_TriggerProcess{TriggerProcess: g.light(b, g.processID(), g.instigator().GetId())},
+	}}}
+}
+
+func (g *Generator) xprotect() *santapb.SantaMessage {
+	proc := g.light(binary{path: "/Library/Apple/System/Library/CoreServices/XProtect.app/Contents/MacOS/XProtect"}, g.processID(), &santapb.ProcessID{Pid: proto.Int32(1), Pidversion: proto.Int32(1)})
+	b := pick(g, unsigned)
+	malware := pick(g, []string{"MACOS.ADLOAD.A", "MACOS.PIRRIT.B", "MACOS.AMOS.C"})
+	incident := fmt.Sprintf("%08X-%04X", g.rng.Uint32(), g.rng.Uint32N(1<<16))
+	ev := &santapb.XProtect{}
+	if g.rng.IntN(2) == 0 {
+		ev.Event = &santapb.XProtect_Detected{Detected: &santapb.XProtectDetected{
+			Instigator:         proc,
+			SignatureVersion:   proto.String("5297"),
+			MalwareIdentifier:  proto.String(malware),
+			IncidentIdentifier: proto.String(incident),
+			DetectedPath:       proto.String(b.path),
+		}}
+	} else {
+		ev.Event = &santapb.XProtect_Remediated{Remediated: &santapb.XProtectRemediated{
+			Instigator:         proc,
+			SignatureVersion:   proto.String("5297"),
+			MalwareIdentifier:  proto.String(malware),
+			IncidentIdentifier: proto.String(incident),
+			ActionType:         proto.String("path_delete"),
+			Success:            proto.Bool(true),
+			RemediatedPath:     proto.String(b.path),
+		}}
+	}
+	return &santapb.SantaMessage{Event: &santapb.SantaMessage_Xprotect{Xprotect: ev}}
+}
+
+// WriteSpool writes msgs to dir as Santa LogBatch spool files of at most
+// perFile events each and returns their paths. Files are written next to dir
+// and renamed into it, so a watcher never sees a partial file.
+func WriteSpool(dir string, msgs []*santapb.SantaMessage, perFile int) ([]string, error) {
+	if perFile <= 0 {
+		return nil, fmt.Errorf("events per file must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	stage := filepath.Dir(filepath.Clean(dir))
+
+	var paths []string
+	for chunk := range slices.Chunk(msgs, perFile) {
+		batch := &santapb.LogBatch{}
+		for _, msg := range chunk {
+			record, err := anypb.New(msg)
+			if err != nil {
+				return paths, fmt.Errorf("failed to wrap event: %w", err)
+			}
+			batch.Records = append(batch.Records, record)
+		}
+		data, err := proto.Marshal(batch)
+		if err != nil {
+			return paths, fmt.Errorf("failed to marshal log batch: %w", err)
+		}
+
+		tmp, err := os.CreateTemp(stage, ".santamon-sim-*")
+		if err != nil {
+			return paths, fmt.Errorf("failed to create spool file: %w", err)
+		}
+		if _, err := tmp.Write(data); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+			return paths, fmt.Errorf("failed to write spool file: %w", err)
+		}
+		if err := tmp.Close(); err != nil {
+			_ = os.Remove(tmp.Name())
+			return paths, fmt.Errorf("failed to write spool file: %w", err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("santamon-sim-%d-%04d", time.Now().UnixNano(), len(paths)))
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			_ = os.Remove(tmp.Name())
+			return paths, fmt.Errorf("failed to move spool file into %s: %w", dir, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
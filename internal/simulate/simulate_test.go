@@ -0,0 +1,140 @@
+package simulate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/0x4d31/santamon/internal/events"
+	"github.com/0x4d31/santamon/internal/spool"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGenerateAllKinds(t *testing.T) {
+	kinds := Kinds()
+	g, err := New(Options{Count: len(kinds) * 3, Seed: 1})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	msgs := g.Generate()
+	if len(msgs) != len(kinds)*3 {
+		t.Fatalf("Expected %d events, got %d", len(kinds)*3, len(msgs))
+	}
+	for i, msg := range msgs {
+		if got, want := events.Kind(msg), kinds[i%len(kinds)]; got != want {
+			t.Errorf("Event %d kind = %s, want %s", i, got, want)
+		}
+		if msg.GetMachineId() == "" || msg.GetBootSessionUuid() == "" || msg.GetEventTime() == nil {
+			t.Errorf("Event %d is missing message metadata", i)
+		}
+		if _, err := events.ToMap(msg); err != nil {
+			t.Errorf("Failed to convert %s event: %v", events.Kind(msg), err)
+		}
+	}
+	if !msgs[1].GetEventTime().AsTime().After(msgs[0].GetEventTime().AsTime()) {
+		t.Error("Event times do not advance")
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	generate := func() []*santapb.SantaMessage {
+		g, err := New(Options{Kinds: []string{"execution", "file_access"}, Count: 20, DenyRate: 0.5, Start: start, Seed: 42})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		return g.Generate()
+	}
+	a, b := generate(), generate()
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			t.Fatalf("Event %d differs between runs with the same seed", i)
+		}
+	}
+}
+
+func TestDenyRate(t *testing.T) {
+	tests := []struct {
+		rate     float64
+		min, max int
+	}{
+		{rate: 0, min: 0, max: 0},
+		{rate: 0.1, min: 60, max: 140},
+		{rate: 1, min: 1000, max: 1000},
+	}
+	for _, tt := range tests {
+		g, err := New(Options{Kinds: []string{"execution"}, Count: 1000, DenyRate: tt.rate, Seed: 7})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		denied := 0
+		for _, msg := range g.Generate() {
+			if events.Decision(msg) == "DECISION_DENY" {
+				denied++
+				if events.TargetTeam(msg) != "" {
+					t.Errorf("Denied execution of signed target %s", events.TargetPath(msg))
+				}
+			}
+		}
+		if denied < tt.min || denied > tt.max {
+			t.Errorf("Deny rate %v: %d of 1000 denied, want %d-%d", tt.rate, denied, tt.min, tt.max)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "unknown kind", opts: Options{Kinds: []string{"network"}}},
+		{name: "deny rate", opts: Options{DenyRate: 1.5}},
+		{name: "count", opts: Options{Count: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestWriteSpool(t *testing.T) {
+	g, err := New(Options{Count: 250, Seed: 3})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	msgs := g.Generate()
+
+	dir := filepath.Join(t.TempDir(), "spool", "new")
+	paths, err := WriteSpool(dir, msgs, 100)
+	if err != nil {
+		t.Fatalf("Failed to write spool: %v", err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("Expected 3 spool files, got %d", len(paths))
+	}
+
+	decoder := spool.NewDecoder()
+	var decoded []*santapb.SantaMessage
+	for _, path := range paths {
+		if filepath.Dir(path) != dir {
+			t.Errorf("Spool file %s not in %s", path, dir)
+		}
+		batch, err := decoder.DecodeEvents(path)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+		decoded = append(decoded, batch...)
+	}
+	if len(decoded) != len(msgs) {
+		t.Fatalf("Decoded %d events, want %d", len(decoded), len(msgs))
+	}
+	for i := range msgs {
+		if !proto.Equal(decoded[i], msgs[i]) {
+			t.Fatalf("Event %d changed in the spool round trip", i)
+		}
+	}
+}
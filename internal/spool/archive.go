@@ -0,0 +1,153 @@
+package spool
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archivePartitionLayout names the daily subdirectories of a partitioned archive
+const archivePartitionLayout = "2006-01-02"
+
+// ArchivePath returns where ArchiveFile puts path, or "" when processed files
+// are deleted. Partitioned archives use the day the file was last written.
+func (w *Watcher) ArchivePath(path string) string {
+	if w.archiveDir == "" {
+		return ""
+	}
+	dir := w.archiveDir
+	if w.archivePartition {
+		day := time.Now()
+		if info, err := os.Stat(path); err == nil {
+			day = info.ModTime()
+		}
+		dir = filepath.Join(dir, day.Format(archivePartitionLayout))
+	}
+	name := filepath.Base(path)
+	if w.archiveCompress && !strings.HasSuffix(name, ".zst") {
+		name += ".zst"
+	}
+	return filepath.Join(dir, name)
+}
+
+// compressFile writes a zstd-compressed copy of src to dst, keeping the
+// modification time so replay still orders archived files by when Santa wrote them
+func compressFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Write under a temporary name so a crash never leaves a truncated archive
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = out.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	enc, err := zstd.NewWriter(out)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(enc, in); err != nil {
+		_ = enc.Close()
+		return err
+	}
+	if err = enc.Close(); err != nil {
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if err = os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// PruneArchive removes archived files older than the archive's max age, then
+// the oldest files until the archive fits its size limit, along with daily
+// partitions left empty. It returns how many files were removed.
+func (w *Watcher) PruneArchive(now time.Time) (int, error) {
+	if w.archiveDir == "" || (w.archiveMaxAge <= 0 && w.archiveMaxBytes <= 0) {
+		return 0, nil
+	}
+
+	type archived struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []archived
+	var total int64
+	err := filepath.WalkDir(w.archiveDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed meanwhile
+		}
+		files = append(files, archived{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan archive: %w", err)
+	}
+
+	// Oldest first
+	slices.SortFunc(files, func(a, b archived) int { return a.modTime.Compare(b.modTime) })
+
+	removed := 0
+	for _, f := range files {
+		expired := w.archiveMaxAge > 0 && now.Sub(f.modTime) > w.archiveMaxAge
+		oversize := w.archiveMaxBytes > 0 && total > w.archiveMaxBytes
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove archived file: %w", err)
+		}
+		total -= f.size
+		removed++
+	}
+
+	if w.archivePartition && removed > 0 {
+		entries, _ := os.ReadDir(w.archiveDir)
+		for _, e := range entries {
+			if _, err := time.Parse(archivePartitionLayout, e.Name()); e.IsDir() && err == nil {
+				// Only succeeds for empty directories
+				_ = os.Remove(filepath.Join(w.archiveDir, e.Name()))
+			}
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,130 @@
+package spool
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestArchiveFileCompressedPartitioned(t *testing.T) {
+	spoolDir := t.TempDir()
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	w, err := NewWatcherWithOptions(spoolDir, 100*time.Millisecond, WatcherOptions{
+		ArchiveDir:       archiveDir,
+		ArchiveCompress:  true,
+		ArchivePartition: true,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	testFile := filepath.Join(spoolDir, "test.pb")
+	content := bytes.Repeat([]byte("spool data "), 100)
+	if err := os.WriteFile(testFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	written := time.Date(2025, 3, 14, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(testFile, written, written); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(archiveDir, "2025-03-14", "test.pb.zst")
+	if got := w.ArchivePath(testFile); got != want {
+		t.Errorf("ArchivePath = %s, want %s", got, want)
+	}
+	if err := w.ArchiveFile(testFile); err != nil {
+		t.Fatalf("ArchiveFile failed: %v", err)
+	}
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Error("Original file should have been removed")
+	}
+
+	// The archive keeps the write time and decompresses to the original
+	info, err := os.Stat(want)
+	if err != nil {
+		t.Fatalf("Archived file missing: %v", err)
+	}
+	if !info.ModTime().Equal(written) {
+		t.Errorf("Archived mod time = %v, want %v", info.ModTime(), written)
+	}
+	f, err := os.Open(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	dec, err := zstd.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	var plain bytes.Buffer
+	if _, err := plain.ReadFrom(dec); err != nil {
+		t.Fatalf("Failed to decompress archive: %v", err)
+	}
+	if !bytes.Equal(plain.Bytes(), content) {
+		t.Error("Decompressed archive does not match the original")
+	}
+}
+
+func TestPruneArchive(t *testing.T) {
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	w, err := NewWatcherWithOptions(t.TempDir(), 100*time.Millisecond, WatcherOptions{
+		ArchiveDir:       archiveDir,
+		ArchivePartition: true,
+		ArchiveMaxAge:    48 * time.Hour,
+		ArchiveMaxBytes:  25,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	now := time.Now()
+	write := func(day, name string, age time.Duration) string {
+		path := filepath.Join(archiveDir, day, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, 10), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	expired := write("2025-01-01", "old.pb", 72*time.Hour)
+	oldest := write("2025-01-02", "a.pb", 3*time.Hour)
+	middle := write("2025-01-02", "b.pb", 2*time.Hour)
+	newest := write("2025-01-03", "c.pb", time.Hour)
+
+	removed, err := w.PruneArchive(now)
+	if err != nil {
+		t.Fatalf("PruneArchive failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+
+	// Past max age, then oldest first until the archive fits
+	for _, path := range []string{expired, oldest} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should have been pruned", path)
+		}
+	}
+	for _, path := range []string{middle, newest} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should have been kept: %v", path, err)
+		}
+	}
+
+	// Emptied partitions are removed
+	if _, err := os.Stat(filepath.Join(archiveDir, "2025-01-01")); !os.IsNotExist(err) {
+		t.Error("Empty partition should have been removed")
+	}
+}
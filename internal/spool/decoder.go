@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strings"
 
@@ -124,23 +123,17 @@ func (d *Decoder) decodeProtobuf(ctx context.Context, data []byte, depth int) ([
 		magic := binary.LittleEndian.Uint32(data[:4])
 		switch {
 		case magic == streamBatcherMagic:
-			if os.Getenv("SANTAMON_DEBUG") == "1" {
-				log.Printf("decoder: detected stream batch")
-			}
+			logger.Debug("decoder: detected stream batch")
 			return parseStreamBatch(ctx, data)
 		case magic == zstdMagic:
-			if os.Getenv("SANTAMON_DEBUG") == "1" {
-				log.Printf("decoder: detected zstd batch (depth %d)", depth)
-			}
+			logger.Debug("decoder: detected zstd batch (depth %d)", depth)
 			plain, err := d.decompressZSTD(data)
 			if err != nil {
 				return nil, err
 			}
 			return d.decodeProtobuf(ctx, plain, depth+1)
 		case magic&0xffff == gzipMagic:
-			if os.Getenv("SANTAMON_DEBUG") == "1" {
-				log.Printf("decoder: detected gzip batch (depth %d)", depth)
-			}
+			logger.Debug("decoder: detected gzip batch (depth %d)", depth)
 			plain, err := d.decompressGZIP(data)
 			if err != nil {
 				return nil, err
@@ -151,9 +144,7 @@ func (d *Decoder) decodeProtobuf(ctx context.Context, data []byte, depth int) ([
 
 	var logBatch santapb.LogBatch
 	if err := proto.Unmarshal(data, &logBatch); err == nil {
-		if os.Getenv("SANTAMON_DEBUG") == "1" {
-			log.Printf("decoder: telemetry.LogBatch parsed, records=%d", len(logBatch.GetRecords()))
-		}
+		logger.Debug("decoder: telemetry.LogBatch parsed, records=%d", len(logBatch.GetRecords()))
 		if len(logBatch.GetRecords()) > 0 {
 			if msgs, err := d.messagesFromLogBatch(&logBatch); err == nil && len(msgs) > 0 {
 				return msgs, nil
@@ -163,9 +154,7 @@ func (d *Decoder) decodeProtobuf(ctx context.Context, data []byte, depth int) ([
 	}
 
 	if msgs, err := parseBinaryLogBatch(data); err == nil {
-		if os.Getenv("SANTAMON_DEBUG") == "1" {
-			log.Printf("decoder: binary LogBatch parsed, messages=%d", len(msgs))
-		}
+		logger.Debug("decoder: binary LogBatch parsed, messages=%d", len(msgs))
 		if len(msgs) > 0 {
 			return msgs, nil
 		}
@@ -173,9 +162,7 @@ func (d *Decoder) decodeProtobuf(ctx context.Context, data []byte, depth int) ([
 
 	var batch santapb.SantaMessageBatch
 	if err := proto.Unmarshal(data, &batch); err == nil {
-		if os.Getenv("SANTAMON_DEBUG") == "1" {
-			log.Printf("decoder: SantaMessageBatch parsed, messages=%d", len(batch.GetMessages()))
-		}
+		logger.Debug("decoder: SantaMessageBatch parsed, messages=%d", len(batch.GetMessages()))
 		if len(batch.GetMessages()) > 0 {
 			return cloneMessages(batch.GetMessages()), nil
 		}
@@ -183,10 +170,7 @@ func (d *Decoder) decodeProtobuf(ctx context.Context, data []byte, depth int) ([
 
 	var single santapb.SantaMessage
 	if err := proto.Unmarshal(data, &single); err == nil {
-		if os.Getenv("SANTAMON_DEBUG") == "1" {
-			hasEvent := single.GetEvent() != nil
-			log.Printf("decoder: SantaMessage parsed, hasEvent=%v", hasEvent)
-		}
+		logger.Debug("decoder: SantaMessage parsed, hasEvent=%v", single.GetEvent() != nil)
 		if single.GetEvent() != nil {
 			return []*santapb.SantaMessage{proto.Clone(&single).(*santapb.SantaMessage)}, nil
 		}
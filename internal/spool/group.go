@@ -0,0 +1,158 @@
+package spool
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Source is a watched spool directory and the label its events are tagged with
+type Source struct {
+	Label   string
+	Watcher *Watcher
+}
+
+// Group watches several spool directories as one pipeline input. Files from
+// all sources arrive on a single channel; per-file operations go to the
+// watcher of the directory the file is in.
+type Group struct {
+	sources  []Source
+	events   chan string
+	inFlight atomic.Int64 // Files taken from a watcher but not yet forwarded
+}
+
+// NewGroup combines the watchers of sources
+func NewGroup(sources ...Source) *Group {
+	return &Group{sources: sources, events: make(chan string)}
+}
+
+// Events returns the channel of file paths ready for processing, from all sources
+func (g *Group) Events() <-chan string {
+	return g.events
+}
+
+// Start runs every watcher and forwards their files until ctx is done or a
+// watcher fails
+func (g *Group) Start(ctx context.Context) error {
+	defer close(g.events)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, src := range g.sources {
+		w := src.Watcher
+		eg.Go(func() error {
+			return w.Start(ctx)
+		})
+		eg.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case path, ok := <-w.Events():
+					if !ok {
+						return nil
+					}
+					g.inFlight.Add(1)
+					select {
+					case g.events <- path:
+						g.inFlight.Add(-1)
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
+		})
+	}
+	return eg.Wait()
+}
+
+// Health reports the first source whose watcher is unhealthy
+func (g *Group) Health() error {
+	for _, src := range g.sources {
+		if err := src.Watcher.Health(); err != nil {
+			if src.Label != "" {
+				return fmt.Errorf("%s: %w", src.Label, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Backlog returns how many files are waiting to be processed across all sources
+func (g *Group) Backlog() int {
+	n := int(g.inFlight.Load())
+	for _, src := range g.sources {
+		n += src.Watcher.Backlog()
+	}
+	return n
+}
+
+// Usage adds up the spools of all sources; FreeBytes is that of the fullest filesystem
+func (g *Group) Usage() (Usage, error) {
+	var total Usage
+	for i, src := range g.sources {
+		u, err := src.Watcher.Usage()
+		if err != nil {
+			return Usage{}, err
+		}
+		total.Files += u.Files
+		total.Bytes += u.Bytes
+		if i == 0 || u.FreeBytes < total.FreeBytes {
+			total.FreeBytes = u.FreeBytes
+		}
+	}
+	return total, nil
+}
+
+// SetNewestFirst sets the dispatch order of every source (see Watcher.SetNewestFirst)
+func (g *Group) SetNewestFirst(newest bool) {
+	for _, src := range g.sources {
+		src.Watcher.SetNewestFirst(newest)
+	}
+}
+
+// Label returns the label of the source path belongs to ("" when unlabeled)
+func (g *Group) Label(path string) string {
+	return g.source(path).Label
+}
+
+// Claim claims path through the watcher of its source (see Watcher.Claim)
+func (g *Group) Claim(path string) (string, error) {
+	return g.source(path).Watcher.Claim(path)
+}
+
+// ArchivePath returns where ArchiveFile puts path (see Watcher.ArchivePath)
+func (g *Group) ArchivePath(path string) string {
+	return g.source(path).Watcher.ArchivePath(path)
+}
+
+// ArchiveFile moves or deletes a processed file through the watcher of its source
+func (g *Group) ArchiveFile(path string) error {
+	return g.source(path).Watcher.ArchiveFile(path)
+}
+
+// Close stops every watcher
+func (g *Group) Close() error {
+	var first error
+	for _, src := range g.sources {
+		if err := src.Watcher.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// source returns the source whose spool or claim directory holds path,
+// falling back to the first one
+func (g *Group) source(path string) Source {
+	dir := filepath.Dir(path)
+	for _, src := range g.sources {
+		if src.Watcher.owns(dir) {
+			return src
+		}
+	}
+	return g.sources[0]
+}
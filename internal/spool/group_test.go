@@ -0,0 +1,86 @@
+package spool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGroupMergesSources(t *testing.T) {
+	santaDir := t.TempDir()
+	forwarderDir := t.TempDir()
+	claimDir := filepath.Join(t.TempDir(), "claimed")
+
+	// Files already waiting in both spools
+	for _, dir := range []string{santaDir, forwarderDir} {
+		if err := os.MkdirAll(filepath.Join(dir, "new"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	santaFile := filepath.Join(santaDir, "new", "a.pb")
+	forwarderFile := filepath.Join(forwarderDir, "new", "b.json")
+	for _, path := range []string{santaFile, forwarderFile} {
+		if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	santa, err := NewWatcher(santaDir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	forwarder, err := NewWatcherWithOptions(forwarderDir, 10*time.Millisecond, WatcherOptions{ClaimDir: claimDir})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	g := NewGroup(Source{Label: "santa", Watcher: santa}, Source{Label: "forwarder", Watcher: forwarder})
+	defer func() { _ = g.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Start(ctx) }()
+
+	got := make(map[string]string)
+	for len(got) < 2 {
+		select {
+		case path := <-g.Events():
+			got[path] = g.Label(path)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for files, got %v", got)
+		}
+	}
+	if got[santaFile] != "santa" || got[forwarderFile] != "forwarder" {
+		t.Errorf("Labels = %v", got)
+	}
+	if err := g.Health(); err != nil {
+		t.Errorf("Expected healthy group, got %v", err)
+	}
+
+	// Claiming goes through the owning watcher; the claimed path keeps its label
+	claimed, err := g.Claim(forwarderFile)
+	if err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+	if filepath.Dir(claimed) != claimDir {
+		t.Errorf("Claimed path = %s, want it in %s", claimed, claimDir)
+	}
+	if g.Label(claimed) != "forwarder" {
+		t.Errorf("Claimed file label = %q, want forwarder", g.Label(claimed))
+	}
+	if path, err := g.Claim(santaFile); err != nil || path != santaFile {
+		t.Errorf("Claim without claim dir = %s, %v", path, err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Group did not stop")
+	}
+	if _, ok := <-g.Events(); ok {
+		t.Error("Expected events channel to be closed")
+	}
+}
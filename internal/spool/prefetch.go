@@ -0,0 +1,78 @@
+package spool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Prefetcher prepares spool files on up to a fixed number of goroutines
+// ahead of a single consumer, delivering the results in the order the files
+// were dispatched
+type Prefetcher[T any] struct {
+	out     chan T
+	pending atomic.Int64 // Files received but not yet delivered
+}
+
+// Prefetch runs prepare for each path received from paths, with at most
+// workers files in flight. The results channel is closed once paths is
+// closed and every prepared file was delivered, or when ctx is done.
+func Prefetch[T any](ctx context.Context, paths <-chan string, workers int, prepare func(path string) T) *Prefetcher[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Prefetcher[T]{out: make(chan T)}
+
+	// Each file gets its own result channel, queued in dispatch order. The
+	// queue plus the file awaiting delivery bound the files in flight.
+	queue := make(chan chan T, workers-1)
+	go func() {
+		defer close(queue)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case path, ok := <-paths:
+				if !ok {
+					return
+				}
+				p.pending.Add(1)
+				result := make(chan T, 1)
+				select {
+				case queue <- result:
+				case <-ctx.Done():
+					return
+				}
+				go func() { result <- prepare(path) }()
+			}
+		}
+	}()
+
+	go func() {
+		defer close(p.out)
+		for result := range queue {
+			var v T
+			select {
+			case v = <-result:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case p.out <- v:
+				p.pending.Add(-1)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Results returns the channel of prepared files, in dispatch order
+func (p *Prefetcher[T]) Results() <-chan T {
+	return p.out
+}
+
+// Pending returns how many files were received but not yet delivered
+func (p *Prefetcher[T]) Pending() int {
+	return int(p.pending.Load())
+}
@@ -0,0 +1,80 @@
+package spool
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrefetchOrder(t *testing.T) {
+	paths := make(chan string)
+	var inFlight, maxInFlight atomic.Int64
+	prepare := func(path string) string {
+		n := inFlight.Add(1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// Later files finish first
+		var i int
+		_, _ = fmt.Sscanf(path, "file-%d", &i)
+		time.Sleep(time.Duration(10-i) * time.Millisecond)
+		inFlight.Add(-1)
+		return path
+	}
+
+	p := Prefetch(context.Background(), paths, 3, prepare)
+	go func() {
+		for i := range 10 {
+			paths <- fmt.Sprintf("file-%d", i)
+		}
+		close(paths)
+	}()
+
+	var got []string
+	for path := range p.Results() {
+		got = append(got, path)
+	}
+	if len(got) != 10 {
+		t.Fatalf("got %d results, want 10", len(got))
+	}
+	for i, path := range got {
+		if want := fmt.Sprintf("file-%d", i); path != want {
+			t.Errorf("result %d = %s, want %s", i, path, want)
+		}
+	}
+	if m := maxInFlight.Load(); m > 3 {
+		t.Errorf("%d files prepared concurrently, want at most 3", m)
+	}
+	if p.Pending() != 0 {
+		t.Errorf("Pending() = %d after all results were delivered", p.Pending())
+	}
+}
+
+func TestPrefetchContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := make(chan string, 1)
+	paths <- "file-0"
+
+	p := Prefetch(ctx, paths, 2, func(path string) string { return path })
+	cancel()
+
+	select {
+	case <-p.Results():
+		// Either the file or the close may win the race with cancel
+	case <-time.After(time.Second):
+		t.Fatal("Results not closed after context cancellation")
+	}
+	select {
+	case _, ok := <-p.Results():
+		if ok {
+			t.Error("Expected results channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Results not closed after context cancellation")
+	}
+}
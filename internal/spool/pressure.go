@@ -0,0 +1,52 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PressureMonitor tracks whether the spool is falling behind (too many bytes
+// waiting) or its filesystem is running out of space
+type PressureMonitor struct {
+	maxBytes int64  // Spool size that counts as falling behind (0 = not checked)
+	minFree  uint64 // Free space below which the disk is under pressure (0 = not checked)
+
+	mu     sync.Mutex
+	reason string
+}
+
+// NewPressureMonitor creates a monitor with the given thresholds in bytes
+func NewPressureMonitor(maxBytes int64, minFree uint64) *PressureMonitor {
+	return &PressureMonitor{maxBytes: maxBytes, minFree: minFree}
+}
+
+// Update evaluates a usage sample. It returns why the spool is under pressure
+// ("" when it is not) and whether that changed since the last sample.
+func (m *PressureMonitor) Update(u Usage) (string, bool) {
+	var causes []string
+	if m.maxBytes > 0 && u.Bytes >= m.maxBytes {
+		causes = append(causes, fmt.Sprintf("%d files (%d MB) waiting in the spool", u.Files, u.Bytes>>20))
+	}
+	if m.minFree > 0 && u.FreeBytes < m.minFree {
+		causes = append(causes, fmt.Sprintf("%d MB free on the spool disk", u.FreeBytes>>20))
+	}
+	reason := strings.Join(causes, ", ")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := reason != m.reason
+	m.reason = reason
+	return reason, changed
+}
+
+// Health reports the spool as degraded while it is under pressure
+func (m *PressureMonitor) Health() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reason != "" {
+		return errors.New("spool under pressure: " + m.reason)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPressureMonitor(t *testing.T) {
+	m := NewPressureMonitor(10<<20, 1<<30)
+
+	steps := []struct {
+		usage   Usage
+		want    string
+		changed bool
+	}{
+		{usage: Usage{Files: 3, Bytes: 1 << 20, FreeBytes: 5 << 30}},
+		{usage: Usage{Files: 40, Bytes: 12 << 20, FreeBytes: 5 << 30}, want: "40 files (12 MB) waiting", changed: true},
+		{usage: Usage{Files: 40, Bytes: 12 << 20, FreeBytes: 512 << 20}, want: "512 MB free", changed: true},
+		{usage: Usage{Files: 40, Bytes: 12 << 20, FreeBytes: 512 << 20}, want: "512 MB free"},
+		{usage: Usage{Files: 1, Bytes: 1 << 10, FreeBytes: 5 << 30}, changed: true},
+	}
+	for i, step := range steps {
+		reason, changed := m.Update(step.usage)
+		if changed != step.changed || (step.want == "") != (reason == "") || !strings.Contains(reason, step.want) {
+			t.Errorf("step %d: Update = %q, %v, want %q, %v", i, reason, changed, step.want, step.changed)
+		}
+		if err := m.Health(); (err != nil) != (reason != "") {
+			t.Errorf("step %d: Health() = %v with reason %q", i, err, reason)
+		}
+	}
+}
+
+func TestWatcherUsage(t *testing.T) {
+	spoolDir := t.TempDir()
+	w, err := NewWatcher(spoolDir, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	for _, name := range []string{"a.pb", "b.pb"} {
+		if err := os.WriteFile(filepath.Join(spoolDir, "new", name), make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, err := w.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if u.Files != 2 || u.Bytes != 200 || u.FreeBytes == 0 {
+		t.Errorf("Usage = %+v, want 2 files, 200 bytes and some free space", u)
+	}
+}
+
+func TestWatcherNewestFirst(t *testing.T) {
+	w, err := NewWatcher(t.TempDir(), time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	now := time.Now()
+	stability := make(map[string]time.Time)
+	var paths []string
+	for i, name := range []string{"old.pb", "mid.pb", "new.pb"} {
+		path := filepath.Join(w.spoolDir, "new", name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		stability[path] = now.Add(time.Duration(i-3) * time.Minute)
+		paths = append(paths, path)
+	}
+
+	if got := w.stableFiles(stability, now); !slices.Equal(got, paths) {
+		t.Errorf("stableFiles = %v, want oldest first %v", got, paths)
+	}
+	w.SetNewestFirst(true)
+	want := []string{paths[2], paths[1], paths[0]}
+	if got := w.stableFiles(stability, now); !slices.Equal(got, want) {
+		t.Errorf("stableFiles = %v, want newest first %v", got, want)
+	}
+}
@@ -0,0 +1,397 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// maxStreamMessages bounds the messages of a single spool file
+const maxStreamMessages = 100000
+
+// maxRecordSize bounds a single stream frame or batch record
+const maxRecordSize = 10 * 1024 * 1024
+
+// DecodeEventsStream decodes a spool file one message at a time, so memory
+// use is bounded by the largest record rather than the file. Stream batches,
+// LogBatch and SantaMessageBatch files, JSON lines and their zstd or gzip
+// compressed forms are streamed; other layouts are decoded in full. An error
+// ends the sequence and may follow messages already yielded.
+func (d *Decoder) DecodeEventsStream(ctx context.Context, path string) iter.Seq2[*santapb.SantaMessage, error] {
+	return func(yield func(*santapb.SantaMessage, error) bool) {
+		f, err := d.openSpool(path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer func() { _ = f.Close() }()
+
+		s := &messageStream{ctx: ctx, yield: yield}
+		file := &countingReader{r: f}
+		r := bufio.NewReader(file)
+
+		// Unwrap compression layers, each with its own size limit
+		var layers []*countingReader
+		for {
+			magic, _ := r.Peek(4)
+			var plain io.Reader
+			switch {
+			case len(magic) == 4 && binary.LittleEndian.Uint32(magic) == zstdMagic:
+				zr, err := zstd.NewReader(r)
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to init zstd reader: %w", err))
+					return
+				}
+				defer zr.Close()
+				plain = zr
+			case len(magic) >= 2 && binary.LittleEndian.Uint16(magic) == gzipMagic:
+				gr, err := gzip.NewReader(r)
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to init gzip reader: %w", err))
+					return
+				}
+				defer func() { _ = gr.Close() }()
+				plain = gr
+			}
+			if plain == nil {
+				break
+			}
+			if len(layers) == 2 {
+				yield(nil, errors.New("maximum decompression depth exceeded"))
+				return
+			}
+			layer := &countingReader{r: io.LimitReader(plain, d.maxDecompressedSize)}
+			layers = append(layers, layer)
+			r = bufio.NewReader(layer)
+		}
+
+		head, _ := r.Peek(4)
+		switch {
+		case len(head) == 4 && binary.LittleEndian.Uint32(head) == streamBatcherMagic:
+			err = s.frames(r)
+		case d.isJSON(head):
+			err = s.jsonLines(d, r)
+		default:
+			err = s.records(d, r)
+		}
+		if err == nil {
+			for _, layer := range layers {
+				if layer.n >= d.maxDecompressedSize {
+					err = fmt.Errorf("decompressed size limit exceeded (max %d bytes)", d.maxDecompressedSize)
+				}
+			}
+		}
+		if err == nil && len(layers) > 0 && file.n > 0 {
+			if rate := layers[len(layers)-1].n / file.n; rate > int64(d.maxDecompressionRate) {
+				err = fmt.Errorf("decompression ratio too high: %d:1 (max %d:1)", rate, d.maxDecompressionRate)
+			}
+		}
+		if err == nil && s.count == 0 && !s.stopped {
+			err = fmt.Errorf("no Santa messages found in %s", path)
+		}
+		if err != nil && !s.stopped {
+			yield(nil, err)
+		}
+	}
+}
+
+// openSpool opens a spool file after checking its size
+func (d *Decoder) openSpool(path string) (*os.File, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file path cannot be empty")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	if info.Size() > d.maxFileSize {
+		return nil, fmt.Errorf("file too large: %d bytes (max %d)", info.Size(), d.maxFileSize)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return f, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// messageStream hands decoded messages to an iterator's consumer
+type messageStream struct {
+	ctx     context.Context
+	yield   func(*santapb.SantaMessage, error) bool
+	count   int
+	stopped bool // The consumer stopped iterating
+}
+
+// errStopped ends decoding once the consumer stops iterating
+var errStopped = errors.New("iteration stopped")
+
+// emit yields msg, returning errStopped when the consumer is done
+func (s *messageStream) emit(msg *santapb.SantaMessage) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.count++
+	if s.count > maxStreamMessages {
+		return fmt.Errorf("too many messages in spool file (max %d)", maxStreamMessages)
+	}
+	if !s.yield(msg, nil) {
+		s.stopped = true
+		return errStopped
+	}
+	return nil
+}
+
+// finish maps the end of the consumer's iteration to a clean stop
+func (s *messageStream) finish(err error) error {
+	if errors.Is(err, errStopped) {
+		return nil
+	}
+	return err
+}
+
+// frames streams a Santa stream batcher file
+func (s *messageStream) frames(r *bufio.Reader) error {
+	for {
+		var magic uint32
+		if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read stream magic: %w", err)
+		}
+		if magic != streamBatcherMagic {
+			return fmt.Errorf("invalid stream magic: 0x%x", magic)
+		}
+
+		var expectedHash uint64
+		if err := binary.Read(r, binary.LittleEndian, &expectedHash); err != nil {
+			return fmt.Errorf("failed to read stream hash: %w", err)
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("failed to read stream length: %w", err)
+		}
+		if length == 0 {
+			return fmt.Errorf("invalid zero-length message in stream")
+		}
+		if length > maxRecordSize {
+			return fmt.Errorf("stream message too large: %d bytes", length)
+		}
+
+		msgBuf := make([]byte, length)
+		if _, err := io.ReadFull(r, msgBuf); err != nil {
+			return fmt.Errorf("failed to read stream message: %w", err)
+		}
+		if expectedHash != 0 {
+			if sum := xxhash.Sum64(msgBuf); sum != expectedHash {
+				return fmt.Errorf("stream hash mismatch: expected %x got %x", expectedHash, sum)
+			}
+		}
+
+		msg := &santapb.SantaMessage{}
+		if err := proto.Unmarshal(msgBuf, msg); err != nil {
+			return fmt.Errorf("failed to unmarshal SantaMessage: %w", err)
+		}
+		if msg.GetEvent() == nil {
+			continue
+		}
+		if err := s.emit(msg); err != nil {
+			return s.finish(err)
+		}
+	}
+}
+
+// jsonLines streams a JSON lines file
+func (s *messageStream) jsonLines(d *Decoder, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 2*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		msg := &santapb.SantaMessage{}
+		if err := d.json.Unmarshal([]byte(text), msg); err != nil {
+			return fmt.Errorf("failed to parse JSON line %d: %w", line, err)
+		}
+		if msg.GetEvent() == nil {
+			continue
+		}
+		if err := s.emit(msg); err != nil {
+			return s.finish(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("JSON line too large (max 2MB)")
+		}
+		return fmt.Errorf("failed to read JSON lines: %w", err)
+	}
+	return nil
+}
+
+// records streams the field 1 records of a LogBatch (Any-wrapped messages)
+// or SantaMessageBatch. Input that doesn't start with such a record is
+// decoded in full instead.
+func (s *messageStream) records(d *Decoder, r *bufio.Reader) error {
+	// Keep what is read until the first record proves the layout
+	head := &recordingReader{r: r, buf: &bytes.Buffer{}}
+	first := true
+	for {
+		msgs, ok, err := readRecord(head)
+		if errors.Is(err, io.EOF) && !first {
+			return nil
+		}
+		if first {
+			if err != nil || !ok {
+				return s.buffered(d, io.MultiReader(head.buf, r))
+			}
+			first = false
+			head.buf = nil
+		} else if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if err := s.emit(msg); err != nil {
+				return s.finish(err)
+			}
+		}
+	}
+}
+
+// buffered decodes the rest of a file in memory, for layouts that can't be
+// streamed such as a lone SantaMessage
+func (s *messageStream) buffered(d *Decoder, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	msgs, err := d.decodeProtobuf(s.ctx, data, 0)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := s.emit(msg); err != nil {
+			return s.finish(err)
+		}
+	}
+	return nil
+}
+
+// readRecord reads the next field of a batch and returns the messages of a
+// field 1 record; ok is false for other fields and undecodable records
+func readRecord(r *recordingReader) ([]*santapb.SantaMessage, bool, error) {
+	tag, err := binary.ReadUvarint(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, false, io.EOF
+		}
+		return nil, false, fmt.Errorf("failed to read record tag: %w", err)
+	}
+	num, typ := protowire.DecodeTag(tag)
+	switch typ {
+	case protowire.VarintType:
+		_, err = binary.ReadUvarint(r)
+		return nil, false, err
+	case protowire.Fixed32Type:
+		_, err = io.CopyN(io.Discard, r, 4)
+		return nil, false, err
+	case protowire.Fixed64Type:
+		_, err = io.CopyN(io.Discard, r, 8)
+		return nil, false, err
+	case protowire.BytesType:
+	default:
+		return nil, false, fmt.Errorf("unsupported wire type %d in batch", typ)
+	}
+
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read record length: %w", err)
+	}
+	if length > maxRecordSize {
+		return nil, false, fmt.Errorf("batch record too large: %d bytes", length)
+	}
+	val := make([]byte, length)
+	if _, err := io.ReadFull(r, val); err != nil {
+		return nil, false, fmt.Errorf("failed to read batch record: %w", err)
+	}
+	if num != 1 {
+		return nil, false, nil
+	}
+	msgs, ok := recordMessages(val)
+	return msgs, ok, nil
+}
+
+// recordMessages decodes a batch record: an Any wrapping a SantaMessage or
+// SantaMessageBatch, or a bare SantaMessage
+func recordMessages(val []byte) ([]*santapb.SantaMessage, bool) {
+	var record anypb.Any
+	if err := proto.Unmarshal(val, &record); err == nil && strings.Contains(record.GetTypeUrl(), "/") {
+		msg := &santapb.SantaMessage{}
+		if err := proto.Unmarshal(record.GetValue(), msg); err == nil {
+			return []*santapb.SantaMessage{msg}, true
+		}
+		var batch santapb.SantaMessageBatch
+		if err := proto.Unmarshal(record.GetValue(), &batch); err == nil {
+			return batch.GetMessages(), true
+		}
+		return nil, false
+	}
+	msg := &santapb.SantaMessage{}
+	if err := proto.Unmarshal(val, msg); err == nil && msg.GetEvent() != nil {
+		return []*santapb.SantaMessage{msg}, true
+	}
+	return nil, false
+}
+
+// recordingReader keeps a copy of the bytes read while buf is set
+type recordingReader struct {
+	r   *bufio.Reader
+	buf *bytes.Buffer
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.buf != nil {
+		r.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+func (r *recordingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil && r.buf != nil {
+		r.buf.WriteByte(b)
+	}
+	return b, err
+}
@@ -0,0 +1,238 @@
+package spool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	santapb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/telemetry"
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// testMessages returns n distinct messages
+func testMessages(t *testing.T, n int) []*santapb.SantaMessage {
+	t.Helper()
+	msgs := make([]*santapb.SantaMessage, n)
+	for i := range msgs {
+		msgs[i] = createTestProtoMessage()
+		msgs[i].MachineId = proto.String(fmt.Sprintf("machine-%d", i))
+	}
+	return msgs
+}
+
+func marshalLogBatch(t *testing.T, msgs []*santapb.SantaMessage) []byte {
+	t.Helper()
+	batch := &santapb.LogBatch{}
+	for _, msg := range msgs {
+		record, err := anypb.New(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch.Records = append(batch.Records, record)
+	}
+	data, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func marshalStream(t *testing.T, msgs []*santapb.SantaMessage) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(streamBatcherMagic))
+		_ = binary.Write(&buf, binary.LittleEndian, xxhash.Sum64(data))
+		buf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func zstdCompress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipCompress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// collect drains a stream, returning the machine IDs decoded and the error
+func collect(d *Decoder, path string) ([]string, error) {
+	var ids []string
+	for msg, err := range d.DecodeEventsStream(context.Background(), path) {
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, msg.GetMachineId())
+	}
+	return ids, nil
+}
+
+func TestDecodeEventsStream(t *testing.T) {
+	msgs := testMessages(t, 3)
+	single, err := proto.Marshal(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageBatch, err := proto.Marshal(&santapb.SantaMessageBatch{Messages: msgs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonLines bytes.Buffer
+	for _, msg := range msgs {
+		line, err := protojson.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonLines.Write(line)
+		jsonLines.WriteString("\n")
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"single message", single, 1},
+		{"message batch", messageBatch, 3},
+		{"log batch", marshalLogBatch(t, msgs), 3},
+		{"zstd log batch", zstdCompress(t, marshalLogBatch(t, msgs)), 3},
+		{"stream batch", marshalStream(t, msgs), 3},
+		{"zstd stream batch", zstdCompress(t, marshalStream(t, msgs)), 3},
+		{"gzip single message", gzipCompress(t, single), 1},
+		{"json lines", jsonLines.Bytes(), 3},
+	}
+
+	d := NewDecoder()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spool.pb")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			ids, err := collect(d, path)
+			if err != nil {
+				t.Fatalf("DecodeEventsStream failed: %v", err)
+			}
+			if len(ids) != tt.want {
+				t.Fatalf("got %d messages, want %d", len(ids), tt.want)
+			}
+			for i, id := range ids {
+				if want := fmt.Sprintf("machine-%d", i); id != want {
+					t.Errorf("message %d: got machine %s, want %s", i, id, want)
+				}
+			}
+
+			// Same messages as decoding the whole file
+			buffered, err := d.DecodeEvents(path)
+			if err != nil {
+				t.Fatalf("DecodeEvents failed: %v", err)
+			}
+			if len(buffered) != len(ids) {
+				t.Errorf("DecodeEvents returned %d messages, stream %d", len(buffered), len(ids))
+			}
+		})
+	}
+}
+
+func TestDecodeEventsStreamErrors(t *testing.T) {
+	msgs := testMessages(t, 1)
+	single, err := proto.Marshal(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tripleCompressed := gzipCompress(t, gzipCompress(t, gzipCompress(t, single)))
+
+	tests := []struct {
+		name string
+		d    *Decoder
+		data []byte
+	}{
+		{"empty file", NewDecoder(), nil},
+		{"garbage", NewDecoder(), []byte("\x00\x01not telemetry")},
+		{"decompression bomb", NewDecoder().WithLimits(10*1024*1024, 1024, 10), gzipCompress(t, make([]byte, 2048))},
+		{"max depth", NewDecoder(), tripleCompressed},
+		{"corrupt stream frame", NewDecoder(), marshalStream(t, msgs)[:20]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spool.pb")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := collect(tt.d, path); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	if _, err := collect(NewDecoder(), filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestDecodeEventsStreamEarlyStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.pb")
+	if err := os.WriteFile(path, marshalStream(t, testMessages(t, 5)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := 0
+	for _, err := range NewDecoder().DecodeEventsStream(context.Background(), path) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		seen++
+		if seen == 2 {
+			break
+		}
+	}
+	if seen != 2 {
+		t.Errorf("got %d messages before stopping, want 2", seen)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range NewDecoder().DecodeEventsStream(ctx, path) {
+		if err == nil {
+			t.Fatal("Expected context error")
+		}
+		break
+	}
+}
@@ -2,29 +2,47 @@ package spool
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/0x4d31/santamon/internal/logutil"
 	"github.com/fsnotify/fsnotify"
 )
 
+var logger = logutil.For("spool")
+
 // Watcher monitors the Santa spool directory for new files
 type Watcher struct {
-	spoolDir        string
-	stabilityWait   time.Duration
-	eventChan       chan string
-	watcher         *fsnotify.Watcher
-	archiveDir      string        // Directory to move processed files (empty = delete)
-	checkInterval   time.Duration // How often to check file stability
-	maxPendingFiles int           // Maximum files in stability map
-	stabMu          sync.Mutex    // Protects fileStability map from concurrent access
+	spoolDir         string
+	stabilityWait    time.Duration
+	eventChan        chan string
+	watcher          *fsnotify.Watcher
+	archiveDir       string        // Directory to move processed files (empty = delete)
+	claimDir         string        // Per-agent work directory files are claimed into (empty = no claiming)
+	checkInterval    time.Duration // How often to check file stability
+	maxPendingFiles  int           // Maximum files in stability map
+	archiveCompress  bool          // zstd-compress archived files
+	archivePartition bool          // Archive into daily subdirectories
+	archiveMaxAge    time.Duration // Prune archived files older than this (0 = keep)
+	archiveMaxBytes  int64         // Prune the oldest archived files beyond this size (0 = unlimited)
+	pruneInterval    time.Duration // How often to enforce archive retention
+	stabMu           sync.Mutex    // Protects fileStability map from concurrent access
+	pending          int           // Files in the stability map, for Backlog (guarded by stabMu)
+
+	// Health state
+	running      atomic.Bool
+	newestFirst  atomic.Bool  // Dispatch the newest stable files first (disk pressure)
+	lastDispatch atomic.Int64 // Unix time a file was last handed to the pipeline
 }
 
 // NewWatcher creates a new spool directory watcher with default settings
@@ -35,9 +53,16 @@ func NewWatcher(spoolDir string, stabilityWait time.Duration) (*Watcher, error)
 // WatcherOptions contains optional configuration for the watcher
 type WatcherOptions struct {
 	ArchiveDir      string        // Directory to move processed files (empty = delete)
+	ClaimDir        string        // Per-agent work directory for claiming files (must share a filesystem with the spool)
 	CheckInterval   time.Duration // How often to check file stability (default: 1s)
 	MaxPendingFiles int           // Maximum files waiting for stability (default: 1000)
 	ChannelBuffer   int           // Size of event channel buffer (default: 100)
+
+	ArchiveCompress      bool          // Compress archived files with zstd (adds a .zst suffix)
+	ArchivePartition     bool          // Archive into daily YYYY-MM-DD subdirectories
+	ArchiveMaxAge        time.Duration // Remove archived files older than this (0 = keep forever)
+	ArchiveMaxBytes      int64         // Remove the oldest archived files beyond this total size (0 = unlimited)
+	ArchivePruneInterval time.Duration // How often archive retention is enforced (default: 5m)
 }
 
 // NewWatcherWithOptions creates a new spool directory watcher with custom options
@@ -69,6 +94,9 @@ func NewWatcherWithOptions(spoolDir string, stabilityWait time.Duration, opts Wa
 	if opts.ChannelBuffer == 0 {
 		opts.ChannelBuffer = 100
 	}
+	if opts.ArchivePruneInterval == 0 {
+		opts.ArchivePruneInterval = 5 * time.Minute
+	}
 
 	// Create archive directory if specified
 	if opts.ArchiveDir != "" {
@@ -78,14 +106,29 @@ func NewWatcherWithOptions(spoolDir string, stabilityWait time.Duration, opts Wa
 		}
 	}
 
+	// Create claim directory if specified
+	if opts.ClaimDir != "" {
+		if err := os.MkdirAll(opts.ClaimDir, 0700); err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("failed to create claim directory: %w", err)
+		}
+	}
+
 	return &Watcher{
 		spoolDir:        spoolDir,
 		stabilityWait:   stabilityWait,
 		eventChan:       make(chan string, opts.ChannelBuffer),
 		watcher:         watcher,
 		archiveDir:      opts.ArchiveDir,
+		claimDir:        opts.ClaimDir,
 		checkInterval:   opts.CheckInterval,
 		maxPendingFiles: opts.MaxPendingFiles,
+
+		archiveCompress:  opts.ArchiveCompress,
+		archivePartition: opts.ArchivePartition,
+		archiveMaxAge:    opts.ArchiveMaxAge,
+		archiveMaxBytes:  opts.ArchiveMaxBytes,
+		pruneInterval:    opts.ArchivePruneInterval,
 	}, nil
 }
 
@@ -96,12 +139,24 @@ func (w *Watcher) Events() <-chan string {
 
 // Start begins watching for new files
 func (w *Watcher) Start(ctx context.Context) error {
+	w.running.Store(true)
+	defer w.running.Store(false)
+
 	// Track file modification times for stability check
 	fileStability := make(map[string]time.Time)
 
+	// Re-dispatch files this agent claimed but never finished (e.g. after a crash)
+	if w.claimDir != "" {
+		if claimed, err := scanDir(w.claimDir); err != nil {
+			logger.Warn("Failed to scan claim directory: %v", err)
+		} else {
+			w.seedExistingFiles(claimed, fileStability)
+		}
+	}
+
 	// First, process any existing files in the spool
 	if existing, err := w.processExistingFiles(); err != nil {
-		logutil.Warn("Failed to process existing files: %v", err)
+		logger.Warn("Failed to process existing files: %v", err)
 	} else {
 		w.seedExistingFiles(existing, fileStability)
 	}
@@ -114,6 +169,15 @@ func (w *Watcher) Start(ctx context.Context) error {
 	cleanupTicker := time.NewTicker(30 * time.Second)
 	defer cleanupTicker.Stop()
 
+	// Keep the archive within its retention limits
+	var pruneTick <-chan time.Time
+	if w.archiveMaxAge > 0 || w.archiveMaxBytes > 0 {
+		w.pruneArchive()
+		pruneTicker := time.NewTicker(w.pruneInterval)
+		defer pruneTicker.Stop()
+		pruneTick = pruneTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -136,36 +200,28 @@ func (w *Watcher) Start(ctx context.Context) error {
 			if !ok {
 				return fmt.Errorf("watcher errors channel closed")
 			}
-			log.Printf("Watcher error: %v", err)
+			logger.Warn("Watcher error: %v", err)
 			if errors.Is(err, fsnotify.ErrEventOverflow) {
 				w.resyncFiles(fileStability)
 			}
 
 		case <-stabilityTicker.C:
-			// Check for stable files
-			now := time.Now()
-			w.stabMu.Lock()
-			for path, lastMod := range fileStability {
-				if now.Sub(lastMod) >= w.stabilityWait {
-					// Verify file still exists before sending
-					if _, err := os.Stat(path); err != nil {
-						delete(fileStability, path)
-						continue
-					}
-
-					// File is stable, send for processing (unlock before blocking send)
+			// Send stable files for processing
+			for _, path := range w.stableFiles(fileStability, time.Now()) {
+				select {
+				case w.eventChan <- path:
+					w.lastDispatch.Store(time.Now().Unix())
+					w.stabMu.Lock()
+					delete(fileStability, path)
+					w.pending = len(fileStability)
 					w.stabMu.Unlock()
-					select {
-					case w.eventChan <- path:
-						w.stabMu.Lock()
-						delete(fileStability, path)
-					case <-ctx.Done():
-						return ctx.Err()
-					}
-					continue
+				case <-ctx.Done():
+					return ctx.Err()
 				}
 			}
-			w.stabMu.Unlock()
+
+		case <-pruneTick:
+			w.pruneArchive()
 
 		case <-cleanupTicker.C:
 			// Remove stale entries (files that have been pending too long)
@@ -174,15 +230,162 @@ func (w *Watcher) Start(ctx context.Context) error {
 			w.stabMu.Lock()
 			for path, lastMod := range fileStability {
 				if now.Sub(lastMod) > maxWait {
-					logutil.Warn("Removing stale pending file: %s (pending for %v)", path, now.Sub(lastMod))
+					logger.Warn("Removing stale pending file: %s (pending for %v)", path, now.Sub(lastMod))
 					delete(fileStability, path)
 				}
 			}
+			w.pending = len(fileStability)
 			w.stabMu.Unlock()
 		}
 	}
 }
 
+// stableFiles returns the files that have not changed for the stability wait,
+// oldest first or, under disk pressure, newest first. Files that disappeared
+// are dropped from the stability map.
+func (w *Watcher) stableFiles(fileStability map[string]time.Time, now time.Time) []string {
+	w.stabMu.Lock()
+	defer w.stabMu.Unlock()
+
+	var stable []string
+	for path, lastMod := range fileStability {
+		if now.Sub(lastMod) < w.stabilityWait {
+			continue
+		}
+		// Verify file still exists before sending
+		if _, err := os.Stat(path); err != nil {
+			delete(fileStability, path)
+			continue
+		}
+		stable = append(stable, path)
+	}
+	w.pending = len(fileStability)
+
+	newest := w.newestFirst.Load()
+	slices.SortFunc(stable, func(a, b string) int {
+		if newest {
+			return fileStability[b].Compare(fileStability[a])
+		}
+		return fileStability[a].Compare(fileStability[b])
+	})
+	return stable
+}
+
+// SetNewestFirst makes the watcher dispatch the most recently written stable
+// files first, so detection stays current while an old backlog waits
+func (w *Watcher) SetNewestFirst(newest bool) {
+	w.newestFirst.Store(newest)
+}
+
+// Usage is the disk footprint of a spool
+type Usage struct {
+	Files     int    // Files waiting in the spool new/ directory
+	Bytes     int64  // Their total size
+	FreeBytes uint64 // Space available on the spool filesystem
+}
+
+// Usage measures the files waiting in the spool and the free space left on
+// its filesystem
+func (w *Watcher) Usage() (Usage, error) {
+	newDir := filepath.Join(w.spoolDir, "new")
+	files, err := scanDir(newDir)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to scan spool: %w", err)
+	}
+	var u Usage
+	for _, f := range files {
+		u.Files++
+		u.Bytes += f.size
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(newDir, &st); err != nil {
+		return Usage{}, fmt.Errorf("failed to stat spool filesystem: %w", err)
+	}
+	u.FreeBytes = uint64(st.Bavail) * uint64(st.Bsize)
+	return u, nil
+}
+
+// Health reports whether the watcher loop is running and the spool directory is reachable
+func (w *Watcher) Health() error {
+	if !w.running.Load() {
+		return fmt.Errorf("spool watcher not running")
+	}
+	if _, err := os.Stat(filepath.Join(w.spoolDir, "new")); err != nil {
+		return fmt.Errorf("spool directory unavailable: %w", err)
+	}
+	return nil
+}
+
+// Backlog returns how many files are waiting to be processed: dispatched
+// but not yet picked up, plus those still waiting to become stable
+func (w *Watcher) Backlog() int {
+	w.stabMu.Lock()
+	pending := w.pending
+	w.stabMu.Unlock()
+	return len(w.eventChan) + pending
+}
+
+// LastDispatch returns when a file was last handed to the pipeline (zero if never)
+func (w *Watcher) LastDispatch() time.Time {
+	ts := w.lastDispatch.Load()
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
+
+// owns reports whether dir is this watcher's spool new/ or claim directory
+func (w *Watcher) owns(dir string) bool {
+	dir = filepath.Clean(dir)
+	if dir == filepath.Join(w.spoolDir, "new") {
+		return true
+	}
+	return w.claimDir != "" && dir == filepath.Clean(w.claimDir)
+}
+
+// ErrAlreadyClaimed is returned by Claim when another agent took the file first
+var ErrAlreadyClaimed = errors.New("spool file already claimed")
+
+// Claim atomically moves a spool file into this agent's claim directory and returns
+// the new path. When several agents watch the same spool, exactly one rename succeeds;
+// the others get ErrAlreadyClaimed and must skip the file. Without a claim directory
+// the original path is returned unchanged.
+func (w *Watcher) Claim(path string) (string, error) {
+	if w.claimDir == "" {
+		return path, nil
+	}
+	if filepath.Dir(path) == filepath.Clean(w.claimDir) {
+		// Recovered from a previous run; already ours
+		return path, nil
+	}
+
+	claimed := filepath.Join(w.claimDir, filepath.Base(path))
+	if err := os.Rename(path, claimed); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrAlreadyClaimed
+		}
+		return "", fmt.Errorf("failed to claim spool file: %w", err)
+	}
+	return claimed, nil
+}
+
+// FileHash returns the hex SHA-256 digest of a spool file, identifying its
+// contents across restarts
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // ArchiveFile moves or deletes a processed file
 func (w *Watcher) ArchiveFile(path string) error {
 	if w.archiveDir == "" {
@@ -193,10 +396,25 @@ func (w *Watcher) ArchiveFile(path string) error {
 		return nil
 	}
 
-	// Move to archive directory
-	filename := filepath.Base(path)
-	archivePath := filepath.Join(w.archiveDir, filename)
+	archivePath := w.ArchivePath(path)
+	if w.archivePartition {
+		if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+			return fmt.Errorf("failed to create archive partition: %w", err)
+		}
+	}
 
+	// Compress into the archive, then drop the original
+	if w.archiveCompress {
+		if err := compressFile(path, archivePath); err != nil {
+			return fmt.Errorf("failed to archive file: %w", err)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove original file: %w", err)
+		}
+		return nil
+	}
+
+	// Move to archive directory
 	if err := os.Rename(path, archivePath); err != nil {
 		// If rename fails (e.g., cross-device), try copy+delete
 		if err := w.copyFile(path, archivePath); err != nil {
@@ -234,12 +452,17 @@ func (w *Watcher) copyFile(src, dst string) error {
 type existingFile struct {
 	path    string
 	modTime time.Time
+	size    int64
 }
 
 // processExistingFiles scans the spool directory for existing files
 func (w *Watcher) processExistingFiles() ([]existingFile, error) {
-	newDir := filepath.Join(w.spoolDir, "new")
-	entries, err := os.ReadDir(newDir)
+	return scanDir(filepath.Join(w.spoolDir, "new"))
+}
+
+// scanDir lists regular files in dir with their modification times
+func scanDir(dir string) ([]existingFile, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -250,21 +473,32 @@ func (w *Watcher) processExistingFiles() ([]existingFile, error) {
 			continue
 		}
 
-		path := filepath.Join(newDir, entry.Name())
+		path := filepath.Join(dir, entry.Name())
 
 		// Check file age to ensure it's stable
 		info, err := entry.Info()
 		if err != nil {
-			logutil.Warn("Failed to stat file %s: %v", path, err)
+			logger.Warn("Failed to stat file %s: %v", path, err)
 			continue
 		}
 
-		existing = append(existing, existingFile{path: path, modTime: info.ModTime()})
+		existing = append(existing, existingFile{path: path, modTime: info.ModTime(), size: info.Size()})
 	}
 
 	return existing, nil
 }
 
+// pruneArchive enforces archive retention, logging what it removed
+func (w *Watcher) pruneArchive() {
+	removed, err := w.PruneArchive(time.Now())
+	if err != nil {
+		logger.Warn("Failed to prune spool archive: %v", err)
+	}
+	if removed > 0 {
+		logger.Info("Pruned %d archived spool files past retention", removed)
+	}
+}
+
 // Close stops the watcher and releases resources
 func (w *Watcher) Close() error {
 	return w.watcher.Close()
@@ -278,6 +512,7 @@ func (w *Watcher) seedExistingFiles(existing []existingFile, fileStability map[s
 		if age >= w.stabilityWait {
 			select {
 			case w.eventChan <- f.path:
+				w.lastDispatch.Store(time.Now().Unix())
 				continue
 			default:
 			}
@@ -290,7 +525,7 @@ func (w *Watcher) seedExistingFiles(existing []existingFile, fileStability map[s
 func (w *Watcher) resyncFiles(fileStability map[string]time.Time) {
 	existing, err := w.processExistingFiles()
 	if err != nil {
-		logutil.Warn("Failed to resync spool directory: %v", err)
+		logger.Warn("Failed to resync spool directory: %v", err)
 		return
 	}
 	w.seedExistingFiles(existing, fileStability)
@@ -303,7 +538,7 @@ func (w *Watcher) trackFile(fileStability map[string]time.Time, path string, mod
 
 	// Check if we're at max capacity
 	if len(fileStability) >= w.maxPendingFiles {
-		log.Printf("Warning: max pending files reached (%d), dropping oldest", w.maxPendingFiles)
+		logger.Warn("Max pending files reached (%d), dropping oldest", w.maxPendingFiles)
 		// Remove oldest entry
 		var oldest string
 		var oldestTime time.Time
@@ -317,4 +552,5 @@ func (w *Watcher) trackFile(fileStability map[string]time.Time, path string, mod
 	}
 	// Mark file as recently modified
 	fileStability[path] = modTime
+	w.pending = len(fileStability)
 }
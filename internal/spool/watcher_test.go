@@ -2,6 +2,7 @@ package spool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -66,6 +67,40 @@ func TestNewWatcherWithOptions(t *testing.T) {
 	}
 }
 
+func TestWatcherHealth(t *testing.T) {
+	spoolDir := t.TempDir()
+	w, err := NewWatcher(spoolDir, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := w.Health(); err == nil {
+		t.Error("Expected error before watcher is started")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = w.Start(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for w.Health() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := w.Health(); err != nil {
+		t.Errorf("Expected healthy running watcher, got %v", err)
+	}
+
+	cancel()
+	<-done
+	if err := w.Health(); err == nil {
+		t.Error("Expected error after watcher stopped")
+	}
+}
+
 func TestWatcherProcessExistingFiles(t *testing.T) {
 	spoolDir := t.TempDir()
 	newDir := filepath.Join(spoolDir, "new")
@@ -107,6 +142,147 @@ func TestWatcherProcessExistingFiles(t *testing.T) {
 	}
 }
 
+func TestWatcherBacklog(t *testing.T) {
+	spoolDir := t.TempDir()
+	newDir := filepath.Join(spoolDir, "new")
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.pb", "b.pb", "c.pb"} {
+		if err := os.WriteFile(filepath.Join(newDir, name), []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewWatcherWithOptions(spoolDir, 10*time.Millisecond, WatcherOptions{
+		CheckInterval: 10 * time.Millisecond,
+		ChannelBuffer: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go func() {
+		_ = w.Start(ctx)
+	}()
+
+	waitForBacklog := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for w.Backlog() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Backlog() = %d, want %d", w.Backlog(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Nothing is consuming events: one file buffered, two waiting
+	waitForBacklog(3)
+	<-w.Events()
+	<-w.Events()
+	waitForBacklog(1)
+}
+
+func TestWatcherClaim(t *testing.T) {
+	spoolDir := t.TempDir()
+	newDir := filepath.Join(spoolDir, "new")
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testFile := filepath.Join(newDir, "test.pb")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewWatcherWithOptions(spoolDir, 10*time.Millisecond, WatcherOptions{ClaimDir: filepath.Join(spoolDir, "claimed", "a")})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	b, err := NewWatcherWithOptions(spoolDir, 10*time.Millisecond, WatcherOptions{ClaimDir: filepath.Join(spoolDir, "claimed", "b")})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer func() { _ = b.Close() }()
+
+	claimed, err := a.Claim(testFile)
+	if err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+	if want := filepath.Join(spoolDir, "claimed", "a", "test.pb"); claimed != want {
+		t.Errorf("Claim() = %s, want %s", claimed, want)
+	}
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Error("Claimed file should no longer be in new/")
+	}
+
+	// The second agent loses the race
+	if _, err := b.Claim(testFile); !errors.Is(err, ErrAlreadyClaimed) {
+		t.Errorf("Expected ErrAlreadyClaimed, got %v", err)
+	}
+
+	// Claiming an already-claimed path is a no-op
+	again, err := a.Claim(claimed)
+	if err != nil || again != claimed {
+		t.Errorf("Claim(claimed) = %s, %v; want %s, nil", again, err, claimed)
+	}
+}
+
+func TestWatcherClaimDisabled(t *testing.T) {
+	spoolDir := t.TempDir()
+	w, err := NewWatcher(spoolDir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	path := filepath.Join(spoolDir, "new", "test.pb")
+	got, err := w.Claim(path)
+	if err != nil || got != path {
+		t.Errorf("Claim() = %s, %v; want %s, nil", got, err, path)
+	}
+}
+
+func TestWatcherRecoversClaimedFiles(t *testing.T) {
+	spoolDir := t.TempDir()
+	claimDir := filepath.Join(spoolDir, "claimed", "agent")
+	if err := os.MkdirAll(claimDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a file left behind by a crash mid-processing
+	leftover := filepath.Join(claimDir, "leftover.pb")
+	if err := os.WriteFile(leftover, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	w, err := NewWatcherWithOptions(spoolDir, 10*time.Millisecond, WatcherOptions{ClaimDir: claimDir})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go func() {
+		_ = w.Start(ctx)
+	}()
+
+	select {
+	case path := <-w.Events():
+		if path != leftover {
+			t.Errorf("Expected path %s, got %s", leftover, path)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Timeout waiting for leftover claimed file")
+	}
+}
+
 func TestWatcherNewFile(t *testing.T) {
 	spoolDir := t.TempDir()
 	w, err := NewWatcher(spoolDir, 100*time.Millisecond)
@@ -193,6 +369,32 @@ func TestWatcherFileStability(t *testing.T) {
 	}
 }
 
+func TestFileHash(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.pb")
+	b := filepath.Join(dir, "b.pb")
+	if err := os.WriteFile(a, []byte("events"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(b, []byte("events!"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	hashA, err := FileHash(a)
+	if err != nil {
+		t.Fatalf("FileHash failed: %v", err)
+	}
+	if again, _ := FileHash(a); again != hashA {
+		t.Errorf("FileHash not stable: %s != %s", again, hashA)
+	}
+	if hashB, _ := FileHash(b); hashB == hashA {
+		t.Error("Different contents hashed the same")
+	}
+	if _, err := FileHash(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
 func TestArchiveFileDelete(t *testing.T) {
 	spoolDir := t.TempDir()
 	// Create watcher without archive directory (should delete files)
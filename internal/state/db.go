@@ -1,8 +1,14 @@
 package state
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -10,18 +16,29 @@ import (
 
 var (
 	// Bucket names
-	bucketSignals   = []byte("signals")
-	bucketShipped   = []byte("shipped")
-	bucketFirstSeen = []byte("first_seen")
-	bucketWindows   = []byte("windows")
-	bucketJournal   = []byte("journal")
-	bucketMeta      = []byte("meta")
+	bucketSignals     = []byte("signals")
+	bucketPriority    = []byte("priority_signals")
+	bucketShipped     = []byte("shipped")
+	bucketFirstSeen   = []byte("first_seen")
+	bucketWindows     = []byte("windows")
+	bucketWindowFired = []byte("window_fired")
+	bucketRates       = []byte("rates")
+	bucketJournal     = []byte("journal")
+	bucketMeta        = []byte("meta")
+	bucketHistory     = []byte("history")
+	bucketDedup       = []byte("dedup")
+	bucketAggregate   = []byte("aggregates")
+	bucketRuleFires   = []byte("rule_fires")
+	bucketLearning    = []byte("learning")
+	bucketLineage     = []byte("lineage")
+	bucketTriage      = []byte("triage")
 )
 
 // DB wraps BoltDB with santamon-specific operations
 type DB struct {
 	*bolt.DB
 	maxFirstSeen int
+	syncWrites   bool
 }
 
 // Signal represents a detection signal
@@ -36,6 +53,45 @@ type Signal struct {
 	Title           string         `json:"title"`
 	Tags            []string       `json:"tags"`
 	Context         map[string]any `json:"context"`
+	Priority        bool           `json:"priority,omitempty"`      // Shipped on the fast lane ahead of bulk signals
+	RulesVersion    string         `json:"rules_version,omitempty"` // Rules bundle that produced the signal
+	TraceID         string         `json:"trace_id,omitempty"`      // Trace of the spool file that produced the signal, when traced
+	SpanID          string         `json:"span_id,omitempty"`       // Span that generated the signal; shipping continues it
+	EventSeq        uint64         `json:"event_seq,omitempty"`     // Agent-wide sequence number of the event that produced the signal
+}
+
+// Derive returns a copy of s to report on it later, such as a summary of its
+// repeats. The copy gets an ID hashed from seed, so it does not collide with
+// s, is dated ts, ships on the bulk lane and carries no trace or context.
+func (s *Signal) Derive(seed string, ts time.Time) *Signal {
+	sum := sha256.Sum256([]byte(seed))
+	sig := *s
+	sig.ID = fmt.Sprintf("%x", sum[:16])
+	sig.TS = ts
+	sig.Priority = false
+	sig.TraceID, sig.SpanID = "", ""
+	sig.Context = nil
+	return &sig
+}
+
+// HistoryEntry is a compact record of an emitted signal kept for local tuning
+type HistoryEntry struct {
+	SignalID string            `json:"signal_id"`
+	TS       time.Time         `json:"ts"`
+	RuleID   string            `json:"rule_id"`
+	Fields   map[string]string `json:"fields,omitempty"` // Selected string context fields
+}
+
+// historyFields are the signal context keys retained in history
+var historyFields = []string{
+	"kind",
+	"actor_path",
+	"actor_team",
+	"actor_signing_id",
+	"target_path",
+	"target_team",
+	"target_sha256",
+	"decision",
 }
 
 // FirstSeenEntry tracks when an artifact was first observed
@@ -45,22 +101,99 @@ type FirstSeenEntry struct {
 	Last  time.Time `json:"last"`
 }
 
+// DedupEntry tracks repeats of a signal within its cooldown window
+type DedupEntry struct {
+	Key    string    `json:"key"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+	Count  int       `json:"count"`  // Occurrences in the window, including the first
+	Signal *Signal   `json:"signal"` // Signal emitted for the first occurrence
+}
+
+// AggregateEntry accumulates the matches of an aggregated rule until its
+// rollup signal is emitted
+type AggregateEntry struct {
+	RuleID    string    `json:"rule_id"`
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+	Count     int       `json:"count"`
+	Targets   []string  `json:"targets,omitempty"`   // Distinct targets, up to the limit given to AddAggregate
+	Truncated bool      `json:"truncated,omitempty"` // More distinct targets were seen than kept
+	Signal    *Signal   `json:"signal"`              // Signal of the first match, the rollup template
+}
+
+// Signal statuses. Signals are shipped open; the backend acknowledges or
+// closes them (see SetTriage).
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusClosed       = "closed"
+)
+
+// maxTriageTransitions bounds the status changes kept per signal
+const maxTriageTransitions = 20
+
+// TriageTransition is one status change of a signal
+type TriageTransition struct {
+	Status string    `json:"status"`
+	TS     time.Time `json:"ts"`
+	By     string    `json:"by,omitempty"`   // Analyst or system that made the change
+	Note   string    `json:"note,omitempty"` // Reason given for the change
+}
+
+// TriageRecord is the triage state of a shipped signal
+type TriageRecord struct {
+	SignalID    string             `json:"signal_id"`
+	RuleID      string             `json:"rule_id,omitempty"`
+	Status      string             `json:"status"`
+	Updated     time.Time          `json:"updated"`
+	Transitions []TriageTransition `json:"transitions"` // Oldest first, up to the last 20
+}
+
+// RuleFires is the cumulative fire history of a rule, kept across restarts
+type RuleFires struct {
+	RuleID string    `json:"rule_id"`
+	Count  int64     `json:"count"`
+	First  time.Time `json:"first,omitzero"` // Earliest fire recorded
+	Last   time.Time `json:"last,omitzero"`  // Latest fire recorded
+}
+
 // JournalEntry tracks spool file processing progress
 type JournalEntry struct {
-	Offset      int64     `json:"offset"`
-	ProcessedTS time.Time `json:"processed_ts"`
+	Offset      int64       `json:"offset"`
+	ProcessedTS time.Time   `json:"processed_ts"`
+	Checkpoint  *Checkpoint `json:"checkpoint,omitempty"` // Set while the file is partially processed
 }
 
-// Open opens or creates the BoltDB database
-func Open(path string, maxFirstSeen int, syncWrites bool) (*DB, error) {
+// Checkpoint is the progress of a spool file whose processing has not
+// finished, so a restart resumes at the first event not yet processed
+type Checkpoint struct {
+	Hash     string `json:"hash"`                // Digest of the file contents
+	Priority int    `json:"priority"`            // Events evaluated by priority rules
+	Next     int    `json:"next"`                // Events fully processed
+	FirstSeq uint64 `json:"first_seq,omitempty"` // Sequence number of the file's first event
+	Signals  bool   `json:"signals,omitempty"`   // Signals were generated from the file
+}
+
+// checkOpenArgs validates the arguments shared by the Open functions of
+// every backend
+func checkOpenArgs(path string, maxFirstSeen int) error {
 	if path == "" {
-		return nil, fmt.Errorf("database path cannot be empty")
+		return fmt.Errorf("database path cannot be empty")
 	}
 	if maxFirstSeen <= 0 {
-		return nil, fmt.Errorf("maxFirstSeen must be positive, got %d", maxFirstSeen)
+		return fmt.Errorf("maxFirstSeen must be positive, got %d", maxFirstSeen)
 	}
 	if maxFirstSeen > 10000000 {
-		return nil, fmt.Errorf("maxFirstSeen too large (max 10000000), got %d", maxFirstSeen)
+		return fmt.Errorf("maxFirstSeen too large (max 10000000), got %d", maxFirstSeen)
+	}
+	return nil
+}
+
+// Open opens or creates the BoltDB database
+func Open(path string, maxFirstSeen int, syncWrites bool) (*DB, error) {
+	if err := checkOpenArgs(path, maxFirstSeen); err != nil {
+		return nil, err
 	}
 
 	db, err := bolt.Open(path, 0600, &bolt.Options{
@@ -76,11 +209,21 @@ func Open(path string, maxFirstSeen int, syncWrites bool) (*DB, error) {
 	err = db.Update(func(tx *bolt.Tx) error {
 		buckets := [][]byte{
 			bucketSignals,
+			bucketPriority,
 			bucketShipped,
 			bucketFirstSeen,
 			bucketWindows,
+			bucketWindowFired,
+			bucketRates,
 			bucketJournal,
 			bucketMeta,
+			bucketHistory,
+			bucketDedup,
+			bucketAggregate,
+			bucketRuleFires,
+			bucketLearning,
+			bucketLineage,
+			bucketTriage,
 		}
 		for _, b := range buckets {
 			_, err := tx.CreateBucketIfNotExists(b)
@@ -101,29 +244,27 @@ func Open(path string, maxFirstSeen int, syncWrites bool) (*DB, error) {
 	return &DB{
 		DB:           db,
 		maxFirstSeen: maxFirstSeen,
+		syncWrites:   syncWrites,
 	}, nil
 }
 
 // EnqueueSignal adds a signal to the outbox queue
 func (db *DB) EnqueueSignal(sig *Signal) error {
-	if sig == nil {
-		return fmt.Errorf("signal cannot be nil")
-	}
-	if sig.ID == "" {
-		return fmt.Errorf("signal ID cannot be empty")
-	}
-	if sig.RuleID == "" {
-		return fmt.Errorf("signal RuleID cannot be empty")
+	if err := checkSignal(sig); err != nil {
+		return err
 	}
 
 	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketSignals)
-		key := []byte(fmt.Sprintf("%d_%s", time.Now().UnixNano(), sig.ID))
+		b := tx.Bucket(queueBucket(sig))
+		key := queueKey(sig.ID)
 		val, err := json.Marshal(sig)
 		if err != nil {
 			return fmt.Errorf("failed to marshal signal: %w", err)
 		}
-		return b.Put(key, val)
+		if err := b.Put(key, val); err != nil {
+			return err
+		}
+		return putHistory(tx, sig)
 	})
 }
 
@@ -132,14 +273,8 @@ func (db *DB) EnqueueSignal(sig *Signal) error {
 // This prevents the race condition where two goroutines could both enqueue
 // the same signal by doing the check and enqueue in a single transaction.
 func (db *DB) EnqueueSignalIfNotShipped(sig *Signal) (bool, error) {
-	if sig == nil {
-		return false, fmt.Errorf("signal cannot be nil")
-	}
-	if sig.ID == "" {
-		return false, fmt.Errorf("signal ID cannot be empty")
-	}
-	if sig.RuleID == "" {
-		return false, fmt.Errorf("signal RuleID cannot be empty")
+	if err := checkSignal(sig); err != nil {
+		return false, err
 	}
 
 	var enqueued bool
@@ -152,8 +287,8 @@ func (db *DB) EnqueueSignalIfNotShipped(sig *Signal) (bool, error) {
 		}
 
 		// Not shipped, so enqueue it
-		signalsBucket := tx.Bucket(bucketSignals)
-		key := []byte(fmt.Sprintf("%d_%s", time.Now().UnixNano(), sig.ID))
+		signalsBucket := tx.Bucket(queueBucket(sig))
+		key := queueKey(sig.ID)
 		val, err := json.Marshal(sig)
 		if err != nil {
 			return fmt.Errorf("failed to marshal signal: %w", err)
@@ -161,6 +296,9 @@ func (db *DB) EnqueueSignalIfNotShipped(sig *Signal) (bool, error) {
 		if err := signalsBucket.Put(key, val); err != nil {
 			return err
 		}
+		if err := putHistory(tx, sig); err != nil {
+			return err
+		}
 
 		enqueued = true
 		return nil
@@ -169,31 +307,306 @@ func (db *DB) EnqueueSignalIfNotShipped(sig *Signal) (bool, error) {
 	return enqueued, err
 }
 
+// checkSignal rejects signals that cannot be queued
+func checkSignal(sig *Signal) error {
+	if sig == nil {
+		return fmt.Errorf("signal cannot be nil")
+	}
+	if sig.ID == "" {
+		return fmt.Errorf("signal ID cannot be empty")
+	}
+	if sig.RuleID == "" {
+		return fmt.Errorf("signal RuleID cannot be empty")
+	}
+	return nil
+}
+
 // DequeueSignals retrieves and removes up to limit signals from the queue
 func (db *DB) DequeueSignals(limit int) ([]*Signal, error) {
 	var signals []*Signal
 
+	// Priority signals always drain first
 	err := db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketSignals)
-		c := b.Cursor()
+		var err error
+		signals, err = dequeueFrom(tx.Bucket(bucketPriority), limit, signals)
+		if err != nil {
+			return err
+		}
+		signals, err = dequeueFrom(tx.Bucket(bucketSignals), limit-len(signals), signals)
+		return err
+	})
 
-		count := 0
-		for k, v := c.First(); k != nil && count < limit; k, v = c.Next() {
-			var sig Signal
-			if err := json.Unmarshal(v, &sig); err != nil {
-				// Log error but continue
-				continue
+	return signals, err
+}
+
+// DequeuePrioritySignals retrieves and removes only priority signals from the outbox
+func (db *DB) DequeuePrioritySignals(limit int) ([]*Signal, error) {
+	var signals []*Signal
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		signals, err = dequeueFrom(tx.Bucket(bucketPriority), limit, signals)
+		return err
+	})
+
+	return signals, err
+}
+
+// dequeueFrom removes up to limit signals from b and appends them to signals
+func dequeueFrom(b *bolt.Bucket, limit int, signals []*Signal) ([]*Signal, error) {
+	c := b.Cursor()
+
+	count := 0
+	for k, v := c.First(); k != nil && count < limit; k, v = c.Next() {
+		var sig Signal
+		if err := json.Unmarshal(v, &sig); err != nil {
+			// Log error but continue
+			continue
+		}
+		signals = append(signals, &sig)
+		if err := c.Delete(); err != nil {
+			return signals, err
+		}
+		count++
+	}
+	return signals, nil
+}
+
+// QueuedSignal is a signal waiting in the outbox
+type QueuedSignal struct {
+	*Signal
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// ListQueue returns up to limit queued signals in shipping order (priority
+// first) without removing them. A limit <= 0 returns the whole queue.
+func (db *DB) ListQueue(limit int) ([]*QueuedSignal, error) {
+	var queued []*QueuedSignal
+	err := db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketPriority, bucketSignals} {
+			c := tx.Bucket(name).Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if limit > 0 && len(queued) >= limit {
+					return nil
+				}
+				var sig Signal
+				if err := json.Unmarshal(v, &sig); err != nil {
+					continue
+				}
+				queued = append(queued, &QueuedSignal{Signal: &sig, QueuedAt: queueKeyTime(k)})
+			}
+		}
+		return nil
+	})
+	return queued, err
+}
+
+// DropQueued removes every queued copy of the signal with the given ID.
+// Returns the number of entries removed.
+func (db *DB) DropQueued(signalID string) (int, error) {
+	dropped := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketPriority, bucketSignals} {
+			b := tx.Bucket(name)
+			// Collect first: deleting while iterating makes the cursor skip keys
+			var keys [][]byte
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				if _, id, ok := bytes.Cut(k, []byte("_")); ok && string(id) == signalID {
+					keys = append(keys, append([]byte(nil), k...))
+				}
+			}
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+				dropped++
 			}
-			signals = append(signals, &sig)
-			if err := c.Delete(); err != nil {
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+// PruneQueue removes signals queued before before from both lanes and
+// returns how many were removed
+func (db *DB) PruneQueue(before time.Time) (int, error) {
+	return db.pruneBuckets(queuedBefore(before), bucketPriority, bucketSignals)
+}
+
+// queuedBefore reports outbox entries queued before before
+func queuedBefore(before time.Time) func(k, v []byte) bool {
+	return func(k, _ []byte) bool {
+		ts := queueKeyTime(k)
+		return !ts.IsZero() && ts.Before(before)
+	}
+}
+
+// pruneBatch is how many keys a prune deletes per transaction, so sweeping a
+// large bucket never holds the write lock for long and writes on the event
+// path can interleave with it
+const pruneBatch = 1000
+
+// pruneBuckets deletes the keys of the named buckets that stale reports, in
+// transactions of at most pruneBatch deletions, and returns how many were
+// deleted
+func (db *DB) pruneBuckets(stale func(k, v []byte) bool, names ...[]byte) (int, error) {
+	removed := 0
+	for _, name := range names {
+		var from []byte
+		for {
+			var n int
+			err := db.Update(func(tx *bolt.Tx) error {
+				var err error
+				n, from, err = pruneBucket(tx.Bucket(name), from, stale)
 				return err
+			})
+			if err != nil {
+				return removed, err
+			}
+			removed += n
+			if from == nil {
+				break
 			}
-			count++
+		}
+	}
+	return removed, nil
+}
+
+// pruneBucket deletes up to pruneBatch keys of b, starting at from, that
+// stale reports. It returns how many were deleted and the key to resume at,
+// nil once the whole bucket was swept.
+func pruneBucket(b *bolt.Bucket, from []byte, stale func(k, v []byte) bool) (int, []byte, error) {
+	// Collect first: deleting while iterating makes the cursor skip keys
+	var keys [][]byte
+	var next []byte
+	c := b.Cursor()
+	k, v := c.First()
+	if from != nil {
+		k, v = c.Seek(from)
+	}
+	for ; k != nil; k, v = c.Next() {
+		// Stop before asking stale again about a key the next batch sees
+		if len(keys) == pruneBatch {
+			next = append([]byte(nil), k...)
+			break
+		}
+		if v == nil || !stale(k, v) {
+			continue
+		}
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return 0, nil, err
+		}
+	}
+	return len(keys), next, nil
+}
+
+// queueKey keys an outbox entry by enqueue time, so the queue ships in order
+func queueKey(signalID string) []byte {
+	return []byte(fmt.Sprintf("%d_%s", time.Now().UnixNano(), signalID))
+}
+
+// queueKeyTime extracts the enqueue time from an outbox key
+func queueKeyTime(key []byte) time.Time {
+	prefix, _, ok := bytes.Cut(key, []byte("_"))
+	if !ok {
+		return time.Time{}
+	}
+	nanos, err := strconv.ParseInt(string(prefix), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// queueBucket returns the outbox bucket a signal belongs in
+func queueBucket(sig *Signal) []byte {
+	if sig.Priority {
+		return bucketPriority
+	}
+	return bucketSignals
+}
+
+// putHistory records a compact copy of sig in the history bucket
+func putHistory(tx *bolt.Tx, sig *Signal) error {
+	key, val, err := historyRecord(sig)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketHistory).Put(key, val)
+}
+
+// historyRecord returns the history key and entry of sig
+func historyRecord(sig *Signal) ([]byte, []byte, error) {
+	ts := sig.TS
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	entry := HistoryEntry{SignalID: sig.ID, TS: ts, RuleID: sig.RuleID}
+	for _, field := range historyFields {
+		if v, ok := sig.Context[field].(string); ok && v != "" {
+			if entry.Fields == nil {
+				entry.Fields = make(map[string]string)
+			}
+			entry.Fields[field] = v
+		}
+	}
+	val, err := json.Marshal(entry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	return historyKey(ts, sig.ID), val, nil
+}
+
+// historyKey orders history entries by signal time
+func historyKey(ts time.Time, id string) []byte {
+	nanos := ts.UnixNano()
+	if ts.Before(time.Unix(0, 0)) {
+		nanos = 0
+	}
+	return []byte(fmt.Sprintf("%020d_%s", nanos, id))
+}
+
+// History returns signal history entries at or after since, oldest first
+func (db *DB) History(since time.Time) ([]*HistoryEntry, error) {
+	var entries []*HistoryEntry
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketHistory).Cursor()
+		for k, v := c.Seek(historyKey(since, "")); k != nil; k, v = c.Next() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			entries = append(entries, &entry)
 		}
 		return nil
 	})
+	return entries, err
+}
 
-	return signals, err
+// PruneHistory removes history entries older than before and returns how many were removed
+func (db *DB) PruneHistory(before time.Time) (int, error) {
+	removed := 0
+	limit := historyKey(before, "")
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketHistory)
+		var stale [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = c.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
 }
 
 // MarkShipped records that a signal was successfully shipped
@@ -218,19 +631,333 @@ func (db *DB) IsShipped(signalID string) (bool, error) {
 	return shipped, err
 }
 
+// SetTriage records a status change of a signal and reports whether its
+// status changed. Changes older than the latest one recorded arrived out of
+// order and are ignored, as are repeats of the current status.
+func (db *DB) SetTriage(signalID, ruleID string, t TriageTransition) (bool, error) {
+	if err := checkStatus(t.Status); err != nil {
+		return false, err
+	}
+	changed := false
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketTriage)
+		rec := TriageRecord{SignalID: signalID, Status: StatusOpen}
+		if existing := b.Get([]byte(signalID)); existing != nil {
+			if err := json.Unmarshal(existing, &rec); err != nil {
+				return fmt.Errorf("failed to unmarshal triage record: %w", err)
+			}
+		}
+		if changed = rec.apply(ruleID, t); !changed {
+			return nil
+		}
+		val, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to marshal triage record: %w", err)
+		}
+		return b.Put([]byte(signalID), val)
+	})
+	return changed, err
+}
+
+// checkStatus rejects unknown signal statuses
+func checkStatus(status string) error {
+	switch status {
+	case StatusOpen, StatusAcknowledged, StatusClosed:
+		return nil
+	}
+	return fmt.Errorf("invalid signal status %q", status)
+}
+
+// apply records the status change t of the record's signal, attributed to
+// ruleID when set, and reports whether the status changed
+func (rec *TriageRecord) apply(ruleID string, t TriageTransition) bool {
+	if t.Status == rec.Status || t.TS.Before(rec.Updated) {
+		return false
+	}
+	if ruleID != "" {
+		rec.RuleID = ruleID
+	}
+	rec.Status = t.Status
+	rec.Updated = t.TS
+	rec.Transitions = append(rec.Transitions, t)
+	if len(rec.Transitions) > maxTriageTransitions {
+		rec.Transitions = rec.Transitions[len(rec.Transitions)-maxTriageTransitions:]
+	}
+	return true
+}
+
+// Triage returns the triage record of a signal, or nil if it was never
+// triaged
+func (db *DB) Triage(signalID string) (*TriageRecord, error) {
+	var rec *TriageRecord
+	err := db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(bucketTriage).Get([]byte(signalID))
+		if val == nil {
+			return nil
+		}
+		rec = &TriageRecord{}
+		return json.Unmarshal(val, rec)
+	})
+	return rec, err
+}
+
+// SignalStatus returns the triage status of a signal, open if it was never
+// triaged
+func (db *DB) SignalStatus(signalID string) (string, error) {
+	rec, err := db.Triage(signalID)
+	if err != nil || rec == nil {
+		return StatusOpen, err
+	}
+	return rec.Status, nil
+}
+
+// ForEachTriage calls fn for each triage record, in signal ID order
+func (db *DB) ForEachTriage(fn func(rec *TriageRecord) error) error {
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketTriage).ForEach(func(_, v []byte) error {
+			var rec TriageRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return nil
+			}
+			return fn(&rec)
+		})
+	})
+}
+
+// PruneTriage removes the triage records last updated before before and
+// returns how many were removed
+func (db *DB) PruneTriage(before time.Time) (int, error) {
+	return db.pruneBuckets(triagedBefore(before), bucketTriage)
+}
+
+// triagedBefore reports triage records last updated before before
+func triagedBefore(before time.Time) func(k, v []byte) bool {
+	return func(_, v []byte) bool {
+		var rec TriageRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return false
+		}
+		return rec.Updated.Before(before)
+	}
+}
+
 // IsFirstSeen checks if an artifact is being seen for the first time
 // Returns true if first seen, false if already tracked
 func (db *DB) IsFirstSeen(kind, id string) (bool, error) {
-	var isFirst bool
+	count, _, err := db.RecordSeen(kind, id, time.Now(), 0)
+	return count == 1, err
+}
+
+// RecordSeen records a sighting of an artifact at now and returns how many
+// times it has been seen, including this one. An artifact last seen
+// forgetAfter or longer before now is tracked anew: its count starts over
+// and the previous sighting is returned. Zero forgetAfter never forgets.
+func (db *DB) RecordSeen(kind, id string, now time.Time, forgetAfter time.Duration) (int, time.Time, error) {
+	var count int
+	var lastSeen time.Time
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketFirstSeen)
-		key := []byte(fmt.Sprintf("%s:%s", kind, id))
+		key := firstSeenKey(kind, id)
 
 		existing := b.Get(key)
 		if existing == nil {
-			isFirst = true
+			count = 1
+
+			// LRU eviction at max entries
+			if b.Stats().KeyN >= db.maxFirstSeen {
+				c := b.Cursor()
+				if k, _ := c.First(); k != nil {
+					_ = b.Delete(k)
+				}
+			}
+
+			entry := FirstSeenEntry{
+				First: now,
+				Count: 1,
+				Last:  now,
+			}
+			val, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			return b.Put(key, val)
+		} else {
+			// Update existing entry
+			var entry FirstSeenEntry
+			if err := json.Unmarshal(existing, &entry); err == nil {
+				lastSeen = entry.sight(now, forgetAfter)
+				count = entry.Count
+				val, err := json.Marshal(entry)
+				if err != nil {
+					return err
+				}
+				return b.Put(key, val)
+			}
+		}
+		return nil
+	})
+
+	return count, lastSeen, err
+}
+
+// firstSeenKey keys a tracked artifact by kind, so the artifacts of a kind
+// are contiguous
+func firstSeenKey(kind, id string) []byte {
+	return []byte(fmt.Sprintf("%s:%s", kind, id))
+}
+
+// sight records a sighting of the artifact at now. An artifact last seen
+// forgetAfter or longer before now starts over, and its previous sighting is
+// returned.
+func (e *FirstSeenEntry) sight(now time.Time, forgetAfter time.Duration) time.Time {
+	var lastSeen time.Time
+	if forgetAfter > 0 && now.Sub(e.Last) >= forgetAfter {
+		// Forgotten: start over
+		lastSeen = e.Last
+		*e = FirstSeenEntry{First: now}
+	}
+	e.Count++
+	// Events out of order don't move the last sighting back
+	if now.After(e.Last) {
+		e.Last = now
+	}
+	return lastSeen
+}
+
+// merge folds a sighting history learned elsewhere into the entry, keeping
+// the earliest first and latest last sighting of both
+func (e *FirstSeenEntry) merge(seed FirstSeenEntry) {
+	if seed.First.Before(e.First) {
+		e.First = seed.First
+	}
+	if seed.Last.After(e.Last) {
+		e.Last = seed.Last
+	}
+	e.Count = max(e.Count, seed.Count)
+}
+
+// FirstSeenEntries returns the tracked artifacts of kind by ID
+func (db *DB) FirstSeenEntries(kind string) (map[string]FirstSeenEntry, error) {
+	entries := make(map[string]FirstSeenEntry)
+	err := db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(kind + ":")
+		c := tx.Bucket(bucketFirstSeen).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var entry FirstSeenEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			entries[string(k[len(prefix):])] = entry
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// ForEachFirstSeen calls fn for every tracked artifact, in key order
+func (db *DB) ForEachFirstSeen(fn func(kind, id string, entry FirstSeenEntry) error) error {
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketFirstSeen).ForEach(func(k, v []byte) error {
+			kind, id, ok := bytes.Cut(k, []byte(":"))
+			if !ok {
+				return nil
+			}
+			var entry FirstSeenEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return nil
+			}
+			return fn(string(kind), string(id), entry)
+		})
+	})
+}
+
+// PruneFirstSeen removes tracked artifacts last seen before before and
+// returns how many were removed
+func (db *DB) PruneFirstSeen(before time.Time) (int, error) {
+	return db.pruneBuckets(seenBefore(before), bucketFirstSeen)
+}
+
+// seenBefore reports tracked artifacts last seen before before
+func seenBefore(before time.Time) func(k, v []byte) bool {
+	return func(_, v []byte) bool {
+		var entry FirstSeenEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return false
+		}
+		last := entry.Last
+		if last.IsZero() {
+			last = entry.First
+		}
+		return last.Before(before)
+	}
+}
+
+// FirstSeenStats returns how many artifacts of kind are tracked and their
+// total sightings
+func (db *DB) FirstSeenStats(kind string) (int, int, error) {
+	var tracked, sightings int
+	err := db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(kind + ":")
+		c := tx.Bucket(bucketFirstSeen).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var entry FirstSeenEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			tracked++
+			sightings += entry.Count
+		}
+		return nil
+	})
+	return tracked, sightings, err
+}
+
+// learningEntry records when a baseline rule started learning
+type learningEntry struct {
+	Hash  string    `json:"hash"`
+	Start time.Time `json:"start"`
+}
+
+// LearningStart returns when the baseline rule ruleID started learning. A
+// rule not seen before, or whose content hash changed, starts at now.
+func (db *DB) LearningStart(ruleID, hash string, now time.Time) (time.Time, error) {
+	start := now
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLearning)
+		if v := b.Get([]byte(ruleID)); v != nil {
+			var entry learningEntry
+			if err := json.Unmarshal(v, &entry); err == nil && entry.Hash == hash {
+				start = entry.Start
+				return nil
+			}
+		}
+		val, err := json.Marshal(learningEntry{Hash: hash, Start: now})
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(ruleID), val)
+	})
+	return start, err
+}
 
+// SeedFirstSeen merges an artifact learned elsewhere into the first-seen
+// store, keeping the earliest first and latest last sighting of both. It
+// reports whether the artifact was new.
+func (db *DB) SeedFirstSeen(kind, id string, seed FirstSeenEntry) (bool, error) {
+	var added bool
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketFirstSeen)
+		key := firstSeenKey(kind, id)
+
+		entry := seed
+		if existing := b.Get(key); existing != nil {
+			if err := json.Unmarshal(existing, &entry); err != nil {
+				return err
+			}
+			entry.merge(seed)
+		} else {
+			added = true
 			// LRU eviction at max entries
 			if b.Stats().KeyN >= db.maxFirstSeen {
 				c := b.Cursor()
@@ -238,34 +965,243 @@ func (db *DB) IsFirstSeen(kind, id string) (bool, error) {
 					_ = b.Delete(k)
 				}
 			}
+		}
+
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, val)
+	})
+	return added, err
+}
+
+// Dedup records an occurrence of sig under key at now. It returns true when
+// the occurrence opens a new window and sig should be emitted, and false when
+// it repeats a signal emitted less than cooldown before. When the occurrence
+// replaces a window that has ended, the old entry is returned as well.
+func (db *DB) Dedup(key string, sig *Signal, now time.Time, cooldown time.Duration) (bool, *DedupEntry, error) {
+	emit := false
+	var expired *DedupEntry
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDedup)
+		var val []byte
+		var err error
+		val, emit, expired, err = dedupOccurrence(b.Get([]byte(key)), key, sig, now, cooldown)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), val)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return emit, expired, nil
+}
+
+// dedupOccurrence applies an occurrence of sig at now to the dedup entry
+// existing, nil if there is none, and returns the updated entry, whether sig
+// should be emitted and the window it replaced, if that ended
+func dedupOccurrence(existing []byte, key string, sig *Signal, now time.Time, cooldown time.Duration) ([]byte, bool, *DedupEntry, error) {
+	emit := false
+	var expired *DedupEntry
+	var entry DedupEntry
+	if existing != nil {
+		if err := json.Unmarshal(existing, &entry); err != nil {
+			return nil, false, nil, fmt.Errorf("failed to unmarshal dedup entry: %w", err)
+		}
+		if now.Sub(entry.First) >= cooldown {
+			old := entry
+			expired = &old
+			entry = DedupEntry{}
+		}
+	}
+
+	if entry.Signal == nil {
+		emit = true
+		entry = DedupEntry{Key: key, First: now, Last: now, Count: 1, Signal: sig}
+	} else {
+		entry.Count++
+		if now.After(entry.Last) {
+			entry.Last = now
+		}
+	}
+
+	val, err := json.Marshal(entry)
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("failed to marshal dedup entry: %w", err)
+	}
+	return val, emit, expired, nil
+}
+
+// ExpireDedup removes the dedup windows that opened before before and returns them
+func (db *DB) ExpireDedup(before time.Time) ([]*DedupEntry, error) {
+	return expireEntries(db.pruneBuckets, bucketDedup, before, func(e *DedupEntry) time.Time { return e.First })
+}
+
+// AddAggregate counts a match of an aggregated rule at now. sig is kept as the
+// template of the rollup when it is the first match since the last rollup.
+// Up to maxTargets distinct targets are recorded.
+func (db *DB) AddAggregate(ruleID, target string, sig *Signal, now time.Time, maxTargets int) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAggregate)
+		entry := AggregateEntry{RuleID: ruleID, First: now, Last: now, Signal: sig}
+		if existing := b.Get([]byte(ruleID)); existing != nil {
+			if err := json.Unmarshal(existing, &entry); err != nil {
+				return fmt.Errorf("failed to unmarshal aggregate entry: %w", err)
+			}
+		}
+
+		entry.add(target, now, maxTargets)
+
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal aggregate entry: %w", err)
+		}
+		return b.Put([]byte(ruleID), val)
+	})
+}
+
+// add counts a match on target at now, recording up to maxTargets distinct
+// targets
+func (e *AggregateEntry) add(target string, now time.Time, maxTargets int) {
+	e.Count++
+	if now.Before(e.First) {
+		e.First = now
+	}
+	if now.After(e.Last) {
+		e.Last = now
+	}
+	if target != "" && !slices.Contains(e.Targets, target) {
+		if len(e.Targets) < maxTargets {
+			e.Targets = append(e.Targets, target)
+		} else {
+			e.Truncated = true
+		}
+	}
+}
+
+// ExpireAggregates removes the aggregates whose first match was before
+// before and returns them
+func (db *DB) ExpireAggregates(before time.Time) ([]*AggregateEntry, error) {
+	return expireEntries(db.pruneBuckets, bucketAggregate, before, func(e *AggregateEntry) time.Time { return e.First })
+}
+
+// expireEntries removes the entries of bucket whose first time is before
+// before and returns them. Entries that cannot be decoded are removed too.
+func expireEntries[T any](prune func(stale func(k, v []byte) bool, names ...[]byte) (int, error), bucket []byte, before time.Time, first func(*T) time.Time) ([]*T, error) {
+	var expired []*T
+	_, err := prune(func(_, v []byte) bool {
+		entry := new(T)
+		if err := json.Unmarshal(v, entry); err != nil {
+			return true
+		}
+		if !first(entry).Before(before) {
+			return false
+		}
+		expired = append(expired, entry)
+		return true
+	}, bucket)
+	return expired, err
+}
+
+// AddRuleFires adds a batch of fires, keyed by rule ID, to the fire history
+// in one transaction
+func (db *DB) AddRuleFires(fires map[string]*RuleFires) error {
+	if len(fires) == 0 {
+		return nil
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRuleFires)
+		for ruleID, add := range fires {
+			entry := RuleFires{RuleID: ruleID, First: add.First, Last: add.Last}
+			if existing := b.Get([]byte(ruleID)); existing != nil {
+				if err := json.Unmarshal(existing, &entry); err != nil {
+					return fmt.Errorf("failed to unmarshal rule fires: %w", err)
+				}
+			}
+			entry.merge(add)
 
-			entry := FirstSeenEntry{
-				First: time.Now(),
-				Count: 1,
-				Last:  time.Now(),
-			}
 			val, err := json.Marshal(entry)
 			if err != nil {
+				return fmt.Errorf("failed to marshal rule fires: %w", err)
+			}
+			if err := b.Put([]byte(ruleID), val); err != nil {
 				return err
 			}
-			return b.Put(key, val)
-		} else {
-			// Update existing entry
-			var entry FirstSeenEntry
-			if err := json.Unmarshal(existing, &entry); err == nil {
-				entry.Count++
-				entry.Last = time.Now()
-				val, err := json.Marshal(entry)
-				if err != nil {
-					return err
-				}
-				return b.Put(key, val)
+		}
+		return nil
+	})
+}
+
+// merge adds the fires of add to the history
+func (f *RuleFires) merge(add *RuleFires) {
+	if add.First.Before(f.First) {
+		f.First = add.First
+	}
+	if add.Last.After(f.Last) {
+		f.Last = add.Last
+	}
+	f.Count += add.Count
+}
+
+// RuleFireHistory returns the fire history of every rule that has fired,
+// sorted by rule ID
+func (db *DB) RuleFireHistory() ([]*RuleFires, error) {
+	var fires []*RuleFires
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRuleFires).ForEach(func(k, v []byte) error {
+			var entry RuleFires
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("failed to unmarshal rule fires for %s: %w", k, err)
+			}
+			fires = append(fires, &entry)
+			return nil
+		})
+	})
+	return fires, err
+}
+
+// SaveLineage replaces the persisted process lineage with snapshots, keyed
+// by boot session UUID. Sessions missing from snapshots are removed.
+func (db *DB) SaveLineage(snapshots map[string][]byte) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLineage)
+		var stale [][]byte
+		err := b.ForEach(func(k, _ []byte) error {
+			if _, ok := snapshots[string(k)]; !ok {
+				stale = append(stale, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		for boot, data := range snapshots {
+			if err := b.Put([]byte(boot), data); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
+}
 
-	return isFirst, err
+// LoadLineage returns the persisted process lineage, keyed by boot session UUID
+func (db *DB) LoadLineage() (map[string][]byte, error) {
+	snapshots := make(map[string][]byte)
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketLineage).ForEach(func(k, v []byte) error {
+			snapshots[string(k)] = bytes.Clone(v)
+			return nil
+		})
+	})
+	return snapshots, err
 }
 
 // UpdateJournal records progress processing a spool file
@@ -284,6 +1220,29 @@ func (db *DB) UpdateJournal(filename string, offset int64) error {
 	})
 }
 
+// SaveCheckpoint records partial progress processing a spool file; the
+// journal entry stays incomplete until UpdateJournal marks it processed
+func (db *DB) SaveCheckpoint(filename string, cp Checkpoint) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		entry := JournalEntry{
+			ProcessedTS: time.Now(),
+			Checkpoint:  &cp,
+		}
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketJournal).Put([]byte(filename), val)
+	})
+}
+
+// Writes returns the number of page writes committed since the database was
+// opened, to tell whether some work changed any state
+func (db *DB) Writes() int64 {
+	stats := db.DB.Stats()
+	return stats.TxStats.GetWrite()
+}
+
 // GetJournalEntry retrieves the processing progress for a spool file
 func (db *DB) GetJournalEntry(filename string) (*JournalEntry, error) {
 	var entry *JournalEntry
@@ -302,6 +1261,58 @@ func (db *DB) GetJournalEntry(filename string) (*JournalEntry, error) {
 	return entry, err
 }
 
+// PruneJournal removes the markers of spool files processed before before,
+// keeping checkpoints of files still being processed, and returns how many
+// were removed
+func (db *DB) PruneJournal(before time.Time) (int, error) {
+	return db.pruneBuckets(processedBefore(before), bucketJournal)
+}
+
+// processedBefore reports the journal markers of spool files processed
+// before before
+func processedBefore(before time.Time) func(k, v []byte) bool {
+	return func(_, v []byte) bool {
+		var entry JournalEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return false
+		}
+		return entry.Checkpoint == nil && entry.ProcessedTS.Before(before)
+	}
+}
+
+// metaEventSeq is the meta key of the last reserved event sequence number
+const metaEventSeq = "event_seq"
+
+// ReserveEventSeq reserves n consecutive event sequence numbers and returns
+// the first. Numbers start at 1, are never handed out twice (also across
+// restarts and concurrent callers), and events that are processed again get
+// new numbers.
+func (db *DB) ReserveEventSeq(n int) (uint64, error) {
+	var first uint64
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketMeta)
+		var last uint64
+		if val := b.Get([]byte(metaEventSeq)); val != nil {
+			var err error
+			if last, err = strconv.ParseUint(string(val), 10, 64); err != nil {
+				return fmt.Errorf("invalid %s: %w", metaEventSeq, err)
+			}
+		}
+		first = last + 1
+		return b.Put([]byte(metaEventSeq), []byte(strconv.FormatUint(last+uint64(n), 10)))
+	})
+	return first, err
+}
+
+// EventSeq returns the last reserved event sequence number, 0 if none
+func (db *DB) EventSeq() (uint64, error) {
+	val, err := db.GetMeta(metaEventSeq)
+	if err != nil || val == "" {
+		return 0, err
+	}
+	return strconv.ParseUint(val, 10, 64)
+}
+
 // SetMeta stores a metadata key-value pair
 func (db *DB) SetMeta(key, value string) error {
 	return db.Update(func(tx *bolt.Tx) error {
@@ -378,6 +1389,270 @@ func (db *DB) GetWindowEvents(ruleID, groupKey string) ([]map[string]any, error)
 	return events, err
 }
 
+// WindowGroups returns the group keys with stored events for a correlation rule
+func (db *DB) WindowGroups(ruleID string) ([]string, error) {
+	var keys []string
+	err := db.View(func(tx *bolt.Tx) error {
+		ruleBucket := tx.Bucket(bucketWindows).Bucket([]byte(ruleID))
+		if ruleBucket == nil {
+			return nil
+		}
+		return ruleBucket.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// WindowRules returns the IDs of correlation rules with stored windows, fire
+// times or rate counters
+func (db *DB) WindowRules() ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketWindows).ForEach(func(k, _ []byte) error {
+			add(string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{bucketWindowFired, bucketRates} {
+			if err := tx.Bucket(name).ForEach(func(k, _ []byte) error {
+				if id, _, ok := bytes.Cut(k, []byte{0}); ok {
+					add(string(id))
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// PruneWindowEvents removes the events of a correlation rule's windows that
+// keep rejects and deletes windows left empty. It returns the number of
+// events and windows removed.
+func (db *DB) PruneWindowEvents(ruleID string, keep func(event map[string]any) bool) (int, int, error) {
+	var removedEvents, removedWindows int
+	err := db.Update(func(tx *bolt.Tx) error {
+		ruleBucket := tx.Bucket(bucketWindows).Bucket([]byte(ruleID))
+		if ruleBucket == nil {
+			return nil
+		}
+
+		// Buckets can't be modified while iterating; collect the changes first
+		updates := make(map[string][]byte)
+		err := ruleBucket.ForEach(func(k, v []byte) error {
+			val, removed, err := filterWindow(v, keep)
+			if err != nil || removed == 0 {
+				return err
+			}
+			removedEvents += removed
+			if val == nil {
+				removedWindows++
+			}
+			updates[string(k)] = val
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for k, val := range updates {
+			if val == nil {
+				err = ruleBucket.Delete([]byte(k))
+			} else {
+				err = ruleBucket.Put([]byte(k), val)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removedEvents, removedWindows, err
+}
+
+// filterWindow drops the events of the stored window v that keep rejects.
+// It returns the window left, nil if it is empty, and how many events were
+// dropped.
+func filterWindow(v []byte, keep func(event map[string]any) bool) ([]byte, int, error) {
+	var events []map[string]any
+	if err := json.Unmarshal(v, &events); err != nil {
+		return nil, 0, err
+	}
+	kept := make([]map[string]any, 0, len(events))
+	for _, event := range events {
+		if keep(event) {
+			kept = append(kept, event)
+		}
+	}
+	removed := len(events) - len(kept)
+	if removed == 0 || len(kept) == 0 {
+		return nil, removed, nil
+	}
+	val, err := json.Marshal(kept)
+	return val, removed, err
+}
+
+// PruneWindowFired removes the fire times of a correlation rule's groups that
+// keep rejects and returns how many were removed
+func (db *DB) PruneWindowFired(ruleID string, keep func(fired time.Time) bool) (int, error) {
+	removed := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		return pruneRuleKeys(tx.Bucket(bucketWindowFired), ruleID, func(v []byte) (bool, error) {
+			var fired time.Time
+			if err := fired.UnmarshalBinary(v); err != nil {
+				return false, err
+			}
+			if keep(fired) {
+				return false, nil
+			}
+			removed++
+			return true, nil
+		})
+	})
+	return removed, err
+}
+
+// PruneRates removes the rate counters of a correlation rule's groups that
+// keep rejects and returns how many were removed. keep receives the counter
+// value as of its last update.
+func (db *DB) PruneRates(ruleID string, keep func(value float64, updated time.Time) bool) (int, error) {
+	removed := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		return pruneRuleKeys(tx.Bucket(bucketRates), ruleID, func(v []byte) (bool, error) {
+			var c rateCounter
+			if err := json.Unmarshal(v, &c); err != nil {
+				return false, err
+			}
+			if keep(c.Value, c.Updated) {
+				return false, nil
+			}
+			removed++
+			return true, nil
+		})
+	})
+	return removed, err
+}
+
+// DropWindowRule removes all state of a correlation rule: its windows, fire
+// times and rate counters. It returns the number of groups removed.
+func (db *DB) DropWindowRule(ruleID string) (int, error) {
+	removed := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		windows := tx.Bucket(bucketWindows)
+		if ruleBucket := windows.Bucket([]byte(ruleID)); ruleBucket != nil {
+			removed += ruleBucket.Stats().KeyN
+			if err := windows.DeleteBucket([]byte(ruleID)); err != nil {
+				return err
+			}
+		}
+		if err := pruneRuleKeys(tx.Bucket(bucketWindowFired), ruleID, func([]byte) (bool, error) {
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		return pruneRuleKeys(tx.Bucket(bucketRates), ruleID, func([]byte) (bool, error) {
+			removed++
+			return true, nil
+		})
+	})
+	return removed, err
+}
+
+// DropWindowGroup removes the window, fire time and rate counter of one
+// correlation group
+func (db *DB) DropWindowGroup(ruleID, groupKey string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if ruleBucket := tx.Bucket(bucketWindows).Bucket([]byte(ruleID)); ruleBucket != nil {
+			if err := ruleBucket.Delete([]byte(groupKey)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(bucketWindowFired).Delete(windowKey(ruleID, groupKey)); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketRates).Delete(windowKey(ruleID, groupKey))
+	})
+}
+
+// WindowUsage calls fn with the number of stored events of every correlation
+// group. A rate counter counts as one event.
+func (db *DB) WindowUsage(fn func(ruleID, groupKey string, events int)) error {
+	return db.View(func(tx *bolt.Tx) error {
+		windows := tx.Bucket(bucketWindows)
+		err := windows.ForEach(func(ruleID, _ []byte) error {
+			ruleBucket := windows.Bucket(ruleID)
+			if ruleBucket == nil {
+				return nil
+			}
+			return ruleBucket.ForEach(func(k, v []byte) error {
+				var events []json.RawMessage
+				if err := json.Unmarshal(v, &events); err != nil {
+					return err
+				}
+				fn(string(ruleID), string(k), len(events))
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketRates).ForEach(func(k, _ []byte) error {
+			if ruleID, groupKey, ok := bytes.Cut(k, []byte{0}); ok {
+				fn(string(ruleID), string(groupKey), 1)
+			}
+			return nil
+		})
+	})
+}
+
+// WindowBytes returns the bytes in use by correlation windows, fire times
+// and rate counters
+func (db *DB) WindowBytes() (int64, error) {
+	var size int64
+	err := db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketWindows, bucketWindowFired, bucketRates} {
+			s := tx.Bucket(name).Stats()
+			size += int64(s.BranchInuse + s.LeafInuse)
+		}
+		return nil
+	})
+	return size, err
+}
+
+// pruneRuleKeys deletes the windowKey entries of a rule that remove reports
+func pruneRuleKeys(b *bolt.Bucket, ruleID string, remove func(v []byte) (bool, error)) error {
+	prefix := windowKey(ruleID, "")
+	var stale [][]byte
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		ok, err := remove(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			stale = append(stale, slices.Clone(k))
+		}
+	}
+	for _, k := range stale {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CleanWindowEvents removes old events from correlation windows
 func (db *DB) CleanWindowEvents(ruleID, groupKey string, keepCount int) error {
 	return db.Update(func(tx *bolt.Tx) error {
@@ -434,15 +1709,114 @@ func (db *DB) ReplaceWindowEvents(ruleID, groupKey string, events []map[string]a
 	})
 }
 
+// WindowFired returns when a correlation group last matched, or the zero time
+func (db *DB) WindowFired(ruleID, groupKey string) (time.Time, error) {
+	var fired time.Time
+	err := db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(bucketWindowFired).Get(windowKey(ruleID, groupKey))
+		if val == nil {
+			return nil
+		}
+		return fired.UnmarshalBinary(val)
+	})
+	return fired, err
+}
+
+// SetWindowFired records when a correlation group matched
+func (db *DB) SetWindowFired(ruleID, groupKey string, ts time.Time) error {
+	val, err := ts.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWindowFired).Put(windowKey(ruleID, groupKey), val)
+	})
+}
+
+// windowKey keys per-group correlation state outside the windows bucket
+func windowKey(ruleID, groupKey string) []byte {
+	return []byte(ruleID + "\x00" + groupKey)
+}
+
+// rateCounter is an exponentially decaying event counter
+type rateCounter struct {
+	Value   float64   `json:"value"`
+	Updated time.Time `json:"updated"`
+}
+
+// IncrementRate decays a correlation group's event counter with time
+// constant tau, adds one event at now and returns the new value. At a steady
+// rate r the value approaches r*tau.
+func (db *DB) IncrementRate(ruleID, groupKey string, now time.Time, tau time.Duration) (float64, error) {
+	var value float64
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRates)
+		key := windowKey(ruleID, groupKey)
+		val, v, err := incrementRate(b.Get(key), now, tau)
+		if err != nil {
+			return err
+		}
+		value = v
+		return b.Put(key, val)
+	})
+	return value, err
+}
+
+// incrementRate decays the stored counter val, nil if there is none, and
+// adds one event at now. It returns the updated counter and its value.
+func incrementRate(val []byte, now time.Time, tau time.Duration) ([]byte, float64, error) {
+	var c rateCounter
+	if val != nil {
+		if err := json.Unmarshal(val, &c); err != nil {
+			return nil, 0, err
+		}
+		// Events out of order don't decay the counter
+		if elapsed := now.Sub(c.Updated); elapsed > 0 {
+			c.Value *= math.Exp(-elapsed.Seconds() / tau.Seconds())
+		}
+	}
+	c.Value++
+	if now.After(c.Updated) {
+		c.Updated = now
+	}
+	val, err := json.Marshal(c)
+	return val, c.Value, err
+}
+
+// ResetRate clears a correlation group's event counter
+func (db *DB) ResetRate(ruleID, groupKey string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRates).Delete(windowKey(ruleID, groupKey))
+	})
+}
+
+// Ping verifies the database is open and readable
+func (db *DB) Ping() error {
+	return db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketMeta) == nil {
+			return fmt.Errorf("meta bucket missing")
+		}
+		return nil
+	})
+}
+
 // Stats returns database statistics
 func (db *DB) Stats() (map[string]any, error) {
 	stats := make(map[string]any)
 
 	err := db.View(func(tx *bolt.Tx) error {
 		stats["signals"] = tx.Bucket(bucketSignals).Stats().KeyN
+		stats["priority_signals"] = tx.Bucket(bucketPriority).Stats().KeyN
 		stats["shipped"] = tx.Bucket(bucketShipped).Stats().KeyN
 		stats["first_seen"] = tx.Bucket(bucketFirstSeen).Stats().KeyN
 		stats["journal"] = tx.Bucket(bucketJournal).Stats().KeyN
+		stats["history"] = tx.Bucket(bucketHistory).Stats().KeyN
+		stats["dedup"] = tx.Bucket(bucketDedup).Stats().KeyN
+		stats["aggregates"] = tx.Bucket(bucketAggregate).Stats().KeyN
+		stats["rule_fires"] = tx.Bucket(bucketRuleFires).Stats().KeyN
+		stats["window_fired"] = tx.Bucket(bucketWindowFired).Stats().KeyN
+		stats["rates"] = tx.Bucket(bucketRates).Stats().KeyN
+		stats["triage"] = tx.Bucket(bucketTriage).Stats().KeyN
 
 		// Count window events
 		windowCount := 0
@@ -458,6 +1832,16 @@ func (db *DB) Stats() (map[string]any, error) {
 		})
 		stats["windows"] = windowCount
 
+		// Bytes in use per bucket, nested window buckets included
+		bucketBytes := make(map[string]int)
+		_ = tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			s := b.Stats()
+			bucketBytes[string(name)] = s.BranchInuse + s.LeafInuse + s.InlineBucketInuse
+			return nil
+		})
+		stats["bucket_bytes"] = bucketBytes
+		stats["file_size"] = tx.Size()
+
 		dbStats := tx.DB().Stats()
 		stats["tx_count"] = dbStats.TxN
 		stats["page_count"] = dbStats.TxStats.PageCount
@@ -469,10 +1853,66 @@ func (db *DB) Stats() (map[string]any, error) {
 	return stats, err
 }
 
-// Compact performs database compaction
+// metaCompactPending marks a requested compaction in the meta bucket
+const metaCompactPending = "compact_pending"
+
+// RequestCompaction marks the database for compaction by CompactIfRequested
+func (db *DB) RequestCompaction() error {
+	return db.SetMeta(metaCompactPending, "true")
+}
+
+// CompactIfRequested compacts the database when RequestCompaction was called
+// since the last compaction, and reports whether it did
+func (db *DB) CompactIfRequested() (bool, error) {
+	pending, err := db.GetMeta(metaCompactPending)
+	if err != nil || pending == "" {
+		return false, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Delete([]byte(metaCompactPending))
+	}); err != nil {
+		return false, err
+	}
+	return true, db.Compact()
+}
+
+// Compact rewrites the database into a new file without the free pages left
+// by deleted entries and reopens it. The database must not be used by other
+// goroutines meanwhile.
 func (db *DB) Compact() error {
-	// BoltDB doesn't have a direct compact method, but we can copy to a new file
-	// This would be implemented in a separate function if needed
-	// For now, just return nil as BoltDB handles space efficiently
-	return nil
+	path := db.Path()
+	opts := &bolt.Options{
+		Timeout: 1 * time.Second,
+		NoSync:  !db.syncWrites,
+	}
+	tmp := path + ".compact"
+	_ = os.Remove(tmp)
+
+	dst, err := bolt.Open(tmp, 0600, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted database: %w", err)
+	}
+	if err := bolt.Compact(dst, db.DB, 64*1024*1024); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to copy database: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to close compacted database: %w", err)
+	}
+
+	if err := db.DB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		err = fmt.Errorf("failed to replace database: %w", err)
+	}
+	reopened, openErr := bolt.Open(path, 0600, opts)
+	if openErr != nil {
+		return fmt.Errorf("failed to reopen database: %w", openErr)
+	}
+	db.DB = reopened
+	return err
 }
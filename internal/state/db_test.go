@@ -1,9 +1,15 @@
 package state
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 // setupTestDB creates a temporary database for testing
@@ -154,6 +160,326 @@ func TestEnqueueDequeueSignals(t *testing.T) {
 	}
 }
 
+// TestPrioritySignalsDequeueFirst tests that priority signals drain ahead of bulk signals
+func TestPrioritySignalsDequeueFirst(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	bulk := &Signal{ID: "bulk-1", RuleID: "RULE-001", Severity: "low"}
+	priority := &Signal{ID: "prio-1", RuleID: "RULE-002", Severity: "critical", Priority: true}
+	if err := db.EnqueueSignal(bulk); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+	if _, err := db.EnqueueSignalIfNotShipped(priority); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+
+	// Priority-only dequeue must not touch bulk signals
+	prio, err := db.DequeuePrioritySignals(10)
+	if err != nil {
+		t.Fatalf("Failed to dequeue priority signals: %v", err)
+	}
+	if len(prio) != 1 || prio[0].ID != "prio-1" || !prio[0].Priority {
+		t.Fatalf("Unexpected priority signals: %+v", prio)
+	}
+
+	// Requeue and verify the general dequeue returns priority first
+	if err := db.EnqueueSignal(prio[0]); err != nil {
+		t.Fatalf("Failed to re-queue signal: %v", err)
+	}
+	all, err := db.DequeueSignals(10)
+	if err != nil {
+		t.Fatalf("Failed to dequeue signals: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 signals, got %d", len(all))
+	}
+	if all[0].ID != "prio-1" || all[1].ID != "bulk-1" {
+		t.Errorf("Expected priority signal first, got %s, %s", all[0].ID, all[1].ID)
+	}
+
+	// Limit applies across both queues
+	if err := db.EnqueueSignal(bulk); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+	if err := db.EnqueueSignal(priority); err != nil {
+		t.Fatalf("Failed to enqueue signal: %v", err)
+	}
+	limited, err := db.DequeueSignals(1)
+	if err != nil {
+		t.Fatalf("Failed to dequeue signals: %v", err)
+	}
+	if len(limited) != 1 || limited[0].ID != "prio-1" {
+		t.Errorf("Expected only the priority signal, got %+v", limited)
+	}
+}
+
+func TestSignalHistory(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		sig := &Signal{
+			ID:     fmt.Sprintf("sig-%d", i),
+			TS:     base.Add(time.Duration(i) * time.Hour),
+			RuleID: "R1",
+			Context: map[string]any{
+				"actor_signing_id": "com.jetbrains.goland",
+				"event_count":      3, // Non-string fields are not kept
+			},
+		}
+		if err := db.EnqueueSignal(sig); err != nil {
+			t.Fatalf("Failed to enqueue signal: %v", err)
+		}
+	}
+
+	// History survives the outbox being drained
+	if _, err := db.DequeueSignals(10); err != nil {
+		t.Fatalf("Failed to dequeue signals: %v", err)
+	}
+
+	entries, err := db.History(base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].SignalID != "sig-1" || entries[0].Fields["actor_signing_id"] != "com.jetbrains.goland" {
+		t.Errorf("Unexpected entry: %+v", entries[0])
+	}
+	if _, ok := entries[0].Fields["event_count"]; ok {
+		t.Error("Non-string context field should not be kept")
+	}
+
+	removed, err := db.PruneHistory(base.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to prune history: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 entries pruned, got %d", removed)
+	}
+	entries, _ = db.History(time.Time{})
+	if len(entries) != 1 || entries[0].SignalID != "sig-2" {
+		t.Errorf("Unexpected history after prune: %+v", entries)
+	}
+}
+
+func TestListAndDropQueue(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	for _, sig := range []*Signal{
+		{ID: "bulk-1", RuleID: "R1"},
+		{ID: "bulk-2", RuleID: "R2"},
+		{ID: "urgent", RuleID: "R3", Priority: true},
+	} {
+		if err := db.EnqueueSignal(sig); err != nil {
+			t.Fatalf("Failed to enqueue signal: %v", err)
+		}
+	}
+
+	queued, err := db.ListQueue(0)
+	if err != nil {
+		t.Fatalf("Failed to list queue: %v", err)
+	}
+	if len(queued) != 3 || queued[0].ID != "urgent" || queued[1].ID != "bulk-1" {
+		t.Fatalf("Unexpected queue order: %+v", queued)
+	}
+	if queued[0].QueuedAt.IsZero() {
+		t.Error("QueuedAt not parsed from key")
+	}
+	if limited, _ := db.ListQueue(2); len(limited) != 2 {
+		t.Errorf("ListQueue(2) returned %d signals", len(limited))
+	}
+
+	dropped, err := db.DropQueued("bulk-1")
+	if err != nil || dropped != 1 {
+		t.Fatalf("DropQueued() = %d, %v; want 1", dropped, err)
+	}
+	if dropped, _ := db.DropQueued("bulk-"); dropped != 0 {
+		t.Errorf("DropQueued() matched an ID prefix: dropped %d", dropped)
+	}
+
+	remaining, _ := db.DequeueSignals(10)
+	if len(remaining) != 2 || remaining[1].ID != "bulk-2" {
+		t.Errorf("Unexpected queue after drop: %+v", remaining)
+	}
+}
+
+// TestRetentionPrune tests pruning first-seen entries, journal markers and
+// queued signals by age
+func TestRetentionPrune(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	if _, _, err := db.RecordSeen("BL-1", "old", now.Add(-48*time.Hour), 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+	if _, _, err := db.RecordSeen("BL-1", "new", now, 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+	removed, err := db.PruneFirstSeen(now.Add(-24 * time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("PruneFirstSeen() = %d, %v; want 1", removed, err)
+	}
+	if entries, _ := db.FirstSeenEntries("BL-1"); len(entries) != 1 || entries["new"].Count != 1 {
+		t.Errorf("Unexpected first-seen entries after prune: %+v", entries)
+	}
+
+	if err := db.UpdateJournal("/spool/a", 0); err != nil {
+		t.Fatalf("Failed to update journal: %v", err)
+	}
+	if removed, _ := db.PruneJournal(now.Add(-time.Hour)); removed != 0 {
+		t.Errorf("PruneJournal() removed %d fresh entries", removed)
+	}
+	if removed, err := db.PruneJournal(time.Now().Add(time.Second)); err != nil || removed != 1 {
+		t.Errorf("PruneJournal() = %d, %v; want 1", removed, err)
+	}
+	if entry, _ := db.GetJournalEntry("/spool/a"); entry != nil {
+		t.Errorf("Journal entry not pruned: %+v", entry)
+	}
+
+	for _, sig := range []*Signal{
+		{ID: "bulk", RuleID: "R1"},
+		{ID: "urgent", RuleID: "R2", Priority: true},
+	} {
+		if err := db.EnqueueSignal(sig); err != nil {
+			t.Fatalf("Failed to enqueue signal: %v", err)
+		}
+	}
+	if removed, _ := db.PruneQueue(now.Add(-time.Hour)); removed != 0 {
+		t.Errorf("PruneQueue() removed %d fresh signals", removed)
+	}
+	if removed, err := db.PruneQueue(time.Now().Add(time.Second)); err != nil || removed != 2 {
+		t.Errorf("PruneQueue() = %d, %v; want 2", removed, err)
+	}
+	if queued, _ := db.ListQueue(0); len(queued) != 0 {
+		t.Errorf("Signals left in queue: %+v", queued)
+	}
+}
+
+// TestPruneBatches tests that prunes larger than a batch sweep the whole
+// bucket over several transactions
+func TestPruneBatches(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	old := time.Now().Add(-48 * time.Hour)
+	total := 2*pruneBatch + 10
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketFirstSeen)
+		for i := range total {
+			// Interleave fresh entries, which must survive
+			seen := old
+			if i%2 == 0 {
+				seen = time.Now()
+			}
+			data, err := json.Marshal(FirstSeenEntry{First: seen})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(fmt.Appendf(nil, "BL-1:%05d", i), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to record sightings: %v", err)
+	}
+
+	removed, err := db.PruneFirstSeen(time.Now().Add(-24 * time.Hour))
+	if err != nil || removed != total/2 {
+		t.Fatalf("PruneFirstSeen() = %d, %v; want %d", removed, err, total/2)
+	}
+	if entries, _ := db.FirstSeenEntries("BL-1"); len(entries) != total/2 {
+		t.Errorf("Expected %d fresh entries after prune, got %d", total/2, len(entries))
+	}
+}
+
+// TestExpireDedupBatches tests that expiries larger than a batch return each
+// entry once and drop undecodable ones
+func TestExpireDedupBatches(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	total := pruneBatch + 5
+	for i := range total {
+		sig := &Signal{ID: fmt.Sprintf("sig-%05d", i)}
+		if _, _, err := db.Dedup(fmt.Sprintf("R1|%05d", i), sig, now.Add(-time.Hour), time.Hour); err != nil {
+			t.Fatalf("Dedup() failed: %v", err)
+		}
+	}
+	if _, _, err := db.Dedup("R1|fresh", &Signal{ID: "fresh"}, now, time.Hour); err != nil {
+		t.Fatalf("Dedup() failed: %v", err)
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDedup).Put([]byte("R1|corrupt"), []byte("{"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := db.ExpireDedup(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ExpireDedup() failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, entry := range expired {
+		seen[entry.Key] = true
+	}
+	if len(expired) != total || len(seen) != total {
+		t.Errorf("ExpireDedup() = %d entries (%d distinct), want %d", len(expired), len(seen), total)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket(bucketDedup).Stats().KeyN; n != 1 {
+			t.Errorf("Expected only the fresh window left, got %d entries", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSaveCheckpoint tests recording partial spool file progress
+func TestSaveCheckpoint(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	cp := Checkpoint{Hash: "abc", Priority: 10, Next: 4, FirstSeq: 7, Signals: true}
+	writes := db.Writes()
+	if err := db.SaveCheckpoint("/spool/a", cp); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	if db.Writes() <= writes {
+		t.Errorf("Writes() = %d after saving a checkpoint, want more than %d", db.Writes(), writes)
+	}
+	entry, err := db.GetJournalEntry("/spool/a")
+	if err != nil {
+		t.Fatalf("Failed to get journal entry: %v", err)
+	}
+	if entry == nil || entry.Checkpoint == nil || *entry.Checkpoint != cp {
+		t.Fatalf("GetJournalEntry() = %+v, want checkpoint %+v", entry, cp)
+	}
+
+	// Checkpoints of files still being processed are never pruned
+	if removed, _ := db.PruneJournal(time.Now().Add(time.Hour)); removed != 0 {
+		t.Errorf("PruneJournal() removed %d checkpoints", removed)
+	}
+
+	if err := db.UpdateJournal("/spool/a", 0); err != nil {
+		t.Fatalf("Failed to update journal: %v", err)
+	}
+	if entry, _ := db.GetJournalEntry("/spool/a"); entry == nil || entry.Checkpoint != nil {
+		t.Errorf("Processed file still has a checkpoint: %+v", entry)
+	}
+}
+
 // TestEnqueueSignalIfNotShipped tests atomic check-and-enqueue
 func TestEnqueueSignalIfNotShipped(t *testing.T) {
 	db, _ := setupTestDB(t)
@@ -230,6 +556,165 @@ func TestIsFirstSeen(t *testing.T) {
 	}
 }
 
+// TestSeedFirstSeen tests merging seeded first-seen entries
+func TestSeedFirstSeen(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if _, _, err := db.RecordSeen("BL-1", "/bin/local", now, 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+
+	seeded := FirstSeenEntry{First: now.Add(-48 * time.Hour), Last: now.Add(-time.Hour), Count: 5}
+	added, err := db.SeedFirstSeen("BL-1", "/bin/local", seeded)
+	if err != nil {
+		t.Fatalf("Failed to seed entry: %v", err)
+	}
+	if added {
+		t.Error("Expected a known pattern not to be new")
+	}
+	added, err = db.SeedFirstSeen("BL-1", "/bin/seeded", seeded)
+	if err != nil {
+		t.Fatalf("Failed to seed entry: %v", err)
+	}
+	if !added {
+		t.Error("Expected an unknown pattern to be new")
+	}
+
+	entries, err := db.FirstSeenEntries("BL-1")
+	if err != nil {
+		t.Fatalf("Failed to read entries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	local := entries["/bin/local"]
+	if !local.First.Equal(seeded.First) || !local.Last.Equal(now) || local.Count != 5 {
+		t.Errorf("Unexpected merged entry: %+v", local)
+	}
+	if got := entries["/bin/seeded"]; !got.First.Equal(seeded.First) || !got.Last.Equal(seeded.Last) || got.Count != 5 {
+		t.Errorf("Unexpected seeded entry: %+v", got)
+	}
+
+	// A seeded pattern is no longer first seen
+	first, err := db.IsFirstSeen("BL-1", "/bin/seeded")
+	if err != nil {
+		t.Fatalf("Failed to check first seen: %v", err)
+	}
+	if first {
+		t.Error("Expected seeded pattern not to be first seen")
+	}
+}
+
+// TestFirstSeenStats tests per-kind first-seen statistics
+func TestFirstSeenStats(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	for _, id := range []string{"a", "a", "a", "b"} {
+		if _, _, err := db.RecordSeen("BL-1", id, now, 0); err != nil {
+			t.Fatalf("Failed to record sighting: %v", err)
+		}
+	}
+	if _, _, err := db.RecordSeen("BL-10", "c", now, 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+
+	tracked, sightings, err := db.FirstSeenStats("BL-1")
+	if err != nil {
+		t.Fatalf("Failed to read stats: %v", err)
+	}
+	if tracked != 2 || sightings != 4 {
+		t.Errorf("FirstSeenStats = %d, %d, want 2, 4", tracked, sightings)
+	}
+}
+
+// TestLearningStart tests persisted baseline learning starts
+func TestLearningStart(t *testing.T) {
+	db, dbPath := setupTestDB(t)
+
+	first := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	start, err := db.LearningStart("BL-1", "abc", first)
+	if err != nil {
+		t.Fatalf("Failed to get learning start: %v", err)
+	}
+	if !start.Equal(first) {
+		t.Errorf("New rule start = %v, want %v", start, first)
+	}
+	_ = db.Close()
+
+	// The start survives a restart while the rule is unchanged
+	db, err = Open(dbPath, 1000, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	if start, err = db.LearningStart("BL-1", "abc", now); err != nil {
+		t.Fatalf("Failed to get learning start: %v", err)
+	}
+	if !start.Equal(first) {
+		t.Errorf("Restarted rule start = %v, want %v", start, first)
+	}
+
+	// A changed rule learns again
+	if start, err = db.LearningStart("BL-1", "def", now); err != nil {
+		t.Fatalf("Failed to get learning start: %v", err)
+	}
+	if !start.Equal(now) {
+		t.Errorf("Changed rule start = %v, want %v", start, now)
+	}
+}
+
+// TestForEachFirstSeen tests listing first-seen entries across kinds
+func TestForEachFirstSeen(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	for _, key := range [][2]string{{"BL-1", "a"}, {"BL-1", "b:c"}, {"BL-2", "a"}, {"BL-1", "a"}} {
+		if _, _, err := db.RecordSeen(key[0], key[1], now, 0); err != nil {
+			t.Fatalf("Failed to record sighting: %v", err)
+		}
+	}
+
+	var got []string
+	err := db.ForEachFirstSeen(func(kind, id string, entry FirstSeenEntry) error {
+		got = append(got, fmt.Sprintf("%s/%s/%d", kind, id, entry.Count))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachFirstSeen failed: %v", err)
+	}
+	if want := []string{"BL-1/a/2", "BL-1/b:c/1", "BL-2/a/1"}; !slices.Equal(got, want) {
+		t.Errorf("ForEachFirstSeen = %v, want %v", got, want)
+	}
+}
+
+// TestStatsSizes tests the size statistics
+func TestStatsSizes(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if err := db.SetMeta("key", "value"); err != nil {
+		t.Fatalf("Failed to set meta: %v", err)
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if size, _ := stats["file_size"].(int64); size <= 0 {
+		t.Errorf("file_size = %v, want positive", stats["file_size"])
+	}
+	bucketBytes, _ := stats["bucket_bytes"].(map[string]int)
+	if bucketBytes["meta"] <= 0 {
+		t.Errorf("bucket_bytes[meta] = %d, want positive", bucketBytes["meta"])
+	}
+}
+
 // TestFirstSeenLRUEviction tests LRU eviction
 func TestFirstSeenLRUEviction(t *testing.T) {
 	// Create DB with small max size for testing
@@ -301,6 +786,193 @@ func TestStoreWindowEvent(t *testing.T) {
 	if len(events) != 2 {
 		t.Fatalf("Expected 2 events, got %d", len(events))
 	}
+
+	if err := db.StoreWindowEvent(ruleID, "user:bob", event1); err != nil {
+		t.Fatalf("Failed to store event 3: %v", err)
+	}
+	groups, err := db.WindowGroups(ruleID)
+	if err != nil {
+		t.Fatalf("Failed to list window groups: %v", err)
+	}
+	if len(groups) != 2 || groups[0] != "user:alice" || groups[1] != "user:bob" {
+		t.Errorf("WindowGroups() = %v, want [user:alice user:bob]", groups)
+	}
+	if groups, err := db.WindowGroups("CORR-UNKNOWN"); err != nil || len(groups) != 0 {
+		t.Errorf("WindowGroups(unknown) = %v, %v; want none", groups, err)
+	}
+}
+
+func TestPruneWindowState(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	for _, ev := range []map[string]any{{"n": 1.0}, {"n": 2.0}, {"n": 3.0}} {
+		if err := db.StoreWindowEvent("CORR-001", "user:alice", ev); err != nil {
+			t.Fatalf("Failed to store event: %v", err)
+		}
+	}
+	if err := db.StoreWindowEvent("CORR-001", "user:bob", map[string]any{"n": 1.0}); err != nil {
+		t.Fatalf("Failed to store event: %v", err)
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.SetWindowFired("CORR-001", "user:alice", now); err != nil {
+		t.Fatalf("Failed to set window fire time: %v", err)
+	}
+	if err := db.SetWindowFired("CORR-001", "user:bob", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to set window fire time: %v", err)
+	}
+	if _, err := db.IncrementRate("CORR-002", "_global", now, time.Minute); err != nil {
+		t.Fatalf("Failed to increment rate: %v", err)
+	}
+
+	if ids, err := db.WindowRules(); err != nil || !slices.Equal(ids, []string{"CORR-001", "CORR-002"}) {
+		t.Errorf("WindowRules() = %v, %v; want [CORR-001 CORR-002]", ids, err)
+	}
+
+	events, windows, err := db.PruneWindowEvents("CORR-001", func(ev map[string]any) bool {
+		return ev["n"].(float64) > 1
+	})
+	if err != nil {
+		t.Fatalf("Failed to prune window events: %v", err)
+	}
+	if events != 2 || windows != 1 {
+		t.Errorf("PruneWindowEvents() = %d events, %d windows; want 2, 1", events, windows)
+	}
+	if groups, _ := db.WindowGroups("CORR-001"); !slices.Equal(groups, []string{"user:alice"}) {
+		t.Errorf("WindowGroups() = %v, want [user:alice]", groups)
+	}
+
+	fired, err := db.PruneWindowFired("CORR-001", func(ts time.Time) bool {
+		return now.Sub(ts) < 10*time.Minute
+	})
+	if err != nil || fired != 1 {
+		t.Errorf("PruneWindowFired() = %d, %v; want 1", fired, err)
+	}
+	if ts, _ := db.WindowFired("CORR-001", "user:alice"); !ts.Equal(now) {
+		t.Errorf("WindowFired(alice) = %v, want %v", ts, now)
+	}
+
+	rates, err := db.PruneRates("CORR-002", func(value float64, updated time.Time) bool {
+		return value > 1
+	})
+	if err != nil || rates != 1 {
+		t.Errorf("PruneRates() = %d, %v; want 1", rates, err)
+	}
+
+	dropped, err := db.DropWindowRule("CORR-001")
+	if err != nil || dropped != 1 {
+		t.Errorf("DropWindowRule() = %d, %v; want 1", dropped, err)
+	}
+	if ids, err := db.WindowRules(); err != nil || len(ids) != 0 {
+		t.Errorf("WindowRules() = %v, %v; want none", ids, err)
+	}
+}
+
+func TestWindowUsageAndCompaction(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	for range 3 {
+		if err := db.StoreWindowEvent("CORR-001", "user:alice", map[string]any{"n": 1}); err != nil {
+			t.Fatalf("Failed to store event: %v", err)
+		}
+	}
+	if _, err := db.IncrementRate("CORR-002", "user:bob", time.Now(), time.Minute); err != nil {
+		t.Fatalf("Failed to increment rate: %v", err)
+	}
+
+	usage := make(map[string]int)
+	if err := db.WindowUsage(func(ruleID, groupKey string, events int) {
+		usage[ruleID+"/"+groupKey] = events
+	}); err != nil {
+		t.Fatalf("Failed to read window usage: %v", err)
+	}
+	if len(usage) != 2 || usage["CORR-001/user:alice"] != 3 || usage["CORR-002/user:bob"] != 1 {
+		t.Errorf("WindowUsage() = %v, want alice=3 bob=1", usage)
+	}
+	if size, err := db.WindowBytes(); err != nil || size == 0 {
+		t.Errorf("WindowBytes() = %d, %v; want > 0", size, err)
+	}
+
+	if err := db.DropWindowGroup("CORR-002", "user:bob"); err != nil {
+		t.Fatalf("Failed to drop window group: %v", err)
+	}
+	if ids, _ := db.WindowRules(); !slices.Equal(ids, []string{"CORR-001"}) {
+		t.Errorf("WindowRules() = %v, want [CORR-001]", ids)
+	}
+
+	// Compaction only runs once requested and keeps the data
+	if compacted, err := db.CompactIfRequested(); err != nil || compacted {
+		t.Errorf("CompactIfRequested() = %v, %v; want false", compacted, err)
+	}
+	if err := db.RequestCompaction(); err != nil {
+		t.Fatalf("Failed to request compaction: %v", err)
+	}
+	if compacted, err := db.CompactIfRequested(); err != nil || !compacted {
+		t.Fatalf("CompactIfRequested() = %v, %v; want true", compacted, err)
+	}
+	if events, err := db.GetWindowEvents("CORR-001", "user:alice"); err != nil || len(events) != 3 {
+		t.Errorf("GetWindowEvents() after compaction = %d events, %v; want 3", len(events), err)
+	}
+	if compacted, _ := db.CompactIfRequested(); compacted {
+		t.Error("CompactIfRequested() compacted twice")
+	}
+}
+
+func TestIncrementRate(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tau := 10 * time.Second
+	steps := []struct {
+		at   time.Time
+		want float64
+	}{
+		{t0, 1},
+		{t0, 2},
+		{t0.Add(tau), 2*math.Exp(-1) + 1},
+		// Out of order: no decay, and the update time stays
+		{t0.Add(tau / 2), 2*math.Exp(-1) + 2},
+	}
+	for i, step := range steps {
+		got, err := db.IncrementRate("CORR-001", "user:alice", step.at, tau)
+		if err != nil {
+			t.Fatalf("Failed to increment rate %d: %v", i, err)
+		}
+		if math.Abs(got-step.want) > 1e-9 {
+			t.Errorf("step %d: IncrementRate() = %v, want %v", i, got, step.want)
+		}
+	}
+
+	if got, _ := db.IncrementRate("CORR-001", "user:bob", t0, tau); got != 1 {
+		t.Errorf("IncrementRate(other group) = %v, want 1", got)
+	}
+	if err := db.ResetRate("CORR-001", "user:alice"); err != nil {
+		t.Fatalf("Failed to reset rate: %v", err)
+	}
+	if got, _ := db.IncrementRate("CORR-001", "user:alice", t0.Add(tau), tau); got != 1 {
+		t.Errorf("IncrementRate() after reset = %v, want 1", got)
+	}
+}
+
+func TestWindowFired(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if fired, err := db.WindowFired("CORR-001", "user:alice"); err != nil || !fired.IsZero() {
+		t.Errorf("WindowFired() = %v, %v; want zero time", fired, err)
+	}
+	ts := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.SetWindowFired("CORR-001", "user:alice", ts); err != nil {
+		t.Fatalf("Failed to set window fire time: %v", err)
+	}
+	if fired, err := db.WindowFired("CORR-001", "user:alice"); err != nil || !fired.Equal(ts) {
+		t.Errorf("WindowFired() = %v, %v; want %v", fired, err, ts)
+	}
+	if fired, _ := db.WindowFired("CORR-001", "user:bob"); !fired.IsZero() {
+		t.Errorf("WindowFired(other group) = %v, want zero time", fired)
+	}
 }
 
 // TestDatabaseRecovery tests database recovery after close
@@ -356,6 +1028,154 @@ func TestDatabaseRecovery(t *testing.T) {
 	}
 }
 
+func TestRuleFires(t *testing.T) {
+	db, dbPath := setupTestDB(t)
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := db.AddRuleFires(map[string]*RuleFires{
+		"R2": {Count: 3, First: t0, Last: t0.Add(time.Minute)},
+		"R1": {Count: 1, First: t0, Last: t0},
+	}); err != nil {
+		t.Fatalf("Failed to add rule fires: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	// History survives a restart and later batches are merged into it
+	db, err := Open(dbPath, 1000, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if err := db.AddRuleFires(map[string]*RuleFires{
+		"R2": {Count: 2, First: t0.Add(-time.Hour), Last: t0.Add(time.Hour)},
+	}); err != nil {
+		t.Fatalf("Failed to add rule fires: %v", err)
+	}
+
+	fires, err := db.RuleFireHistory()
+	if err != nil {
+		t.Fatalf("Failed to read rule fire history: %v", err)
+	}
+	if len(fires) != 2 || fires[0].RuleID != "R1" || fires[1].RuleID != "R2" {
+		t.Fatalf("Unexpected rule fire history: %+v", fires)
+	}
+	r2 := fires[1]
+	if r2.Count != 5 || !r2.First.Equal(t0.Add(-time.Hour)) || !r2.Last.Equal(t0.Add(time.Hour)) {
+		t.Errorf("R2 fires = %+v, want 5 from t0-1h to t0+1h", r2)
+	}
+}
+
+func TestTriage(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	if status, err := db.SignalStatus("sig-1"); err != nil || status != StatusOpen {
+		t.Fatalf("Untriaged signal status = %q, %v; want open", status, err)
+	}
+
+	steps := []struct {
+		t           TriageTransition
+		wantChanged bool
+	}{
+		{TriageTransition{Status: StatusAcknowledged, TS: t0, By: "alice"}, true},
+		{TriageTransition{Status: StatusAcknowledged, TS: t0.Add(time.Minute)}, false}, // Same status
+		{TriageTransition{Status: StatusClosed, TS: t0.Add(2 * time.Minute), Note: "benign"}, true},
+		{TriageTransition{Status: StatusOpen, TS: t0.Add(time.Minute)}, false},     // Out of order
+		{TriageTransition{Status: "resolved", TS: t0.Add(3 * time.Minute)}, false}, // Invalid
+	}
+	for i, step := range steps {
+		changed, err := db.SetTriage("sig-1", "R1", step.t)
+		if step.t.Status == "resolved" {
+			if err == nil {
+				t.Errorf("Step %d: expected an error for an invalid status", i)
+			}
+			continue
+		}
+		if err != nil || changed != step.wantChanged {
+			t.Errorf("Step %d: SetTriage() = %v, %v; want %v", i, changed, err, step.wantChanged)
+		}
+	}
+
+	rec, err := db.Triage("sig-1")
+	if err != nil || rec == nil {
+		t.Fatalf("Triage() = %v, %v", rec, err)
+	}
+	if rec.Status != StatusClosed || rec.RuleID != "R1" || len(rec.Transitions) != 2 || rec.Transitions[1].Note != "benign" {
+		t.Errorf("Unexpected triage record: %+v", rec)
+	}
+
+	if _, err := db.SetTriage("sig-2", "R2", TriageTransition{Status: StatusClosed, TS: t0.Add(-48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := db.PruneTriage(t0.Add(-24 * time.Hour))
+	if err != nil || removed != 1 {
+		t.Errorf("PruneTriage() = %d, %v; want 1", removed, err)
+	}
+	var ids []string
+	_ = db.ForEachTriage(func(rec *TriageRecord) error {
+		ids = append(ids, rec.SignalID)
+		return nil
+	})
+	if len(ids) != 1 || ids[0] != "sig-1" {
+		t.Errorf("Triage records after pruning = %v, want [sig-1]", ids)
+	}
+}
+
+func TestReserveEventSeq(t *testing.T) {
+	db, dbPath := setupTestDB(t)
+
+	first, err := db.ReserveEventSeq(3)
+	if err != nil {
+		t.Fatalf("Failed to reserve sequence numbers: %v", err)
+	}
+	if first != 1 {
+		t.Errorf("First sequence number = %d, want 1", first)
+	}
+	if first, _ = db.ReserveEventSeq(2); first != 4 {
+		t.Errorf("Second reservation starts at %d, want 4", first)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	// Numbers continue after a restart
+	db, err = Open(dbPath, 1000, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// Concurrent reservations get disjoint ranges
+	const workers = 8
+	starts := make(chan uint64, workers)
+	for range workers {
+		go func() {
+			first, err := db.ReserveEventSeq(10)
+			if err != nil {
+				t.Errorf("Failed to reserve sequence numbers: %v", err)
+			}
+			starts <- first
+		}()
+	}
+	seen := make(map[uint64]bool)
+	for range workers {
+		first := <-starts
+		if first < 6 || (first-6)%10 != 0 || seen[first] {
+			t.Errorf("Unexpected or duplicate range start %d", first)
+		}
+		seen[first] = true
+	}
+	if next, _ := db.ReserveEventSeq(1); next != 6+workers*10 {
+		t.Errorf("Next sequence number = %d, want %d", next, 6+workers*10)
+	}
+	if last, err := db.EventSeq(); err != nil || last != 6+workers*10 {
+		t.Errorf("EventSeq() = %d, %v; want %d", last, err, 6+workers*10)
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
@@ -365,3 +1185,31 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestLineageSnapshots(t *testing.T) {
+	db, dbPath := setupTestDB(t)
+
+	if err := db.SaveLineage(map[string][]byte{"boot-1": []byte(`[1]`), "boot-2": []byte(`[2]`)}); err != nil {
+		t.Fatalf("Failed to save lineage: %v", err)
+	}
+	// Saving again replaces the previous snapshot, dropping sessions not included
+	if err := db.SaveLineage(map[string][]byte{"boot-2": []byte(`[3]`)}); err != nil {
+		t.Fatalf("Failed to save lineage: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	db, err := Open(dbPath, 1000, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	snapshots, err := db.LoadLineage()
+	if err != nil {
+		t.Fatalf("Failed to load lineage: %v", err)
+	}
+	if len(snapshots) != 1 || string(snapshots["boot-2"]) != `[3]` {
+		t.Errorf("Loaded lineage = %q, want only boot-2 = [3]", snapshots)
+	}
+}
@@ -0,0 +1,1452 @@
+//go:build sqlite
+
+package state
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteTables are the tables of the SQLite backend, one per BoltDB bucket
+// holding the same keys and values. Correlation windows are keyed by
+// windowKey instead of living in a nested bucket per rule.
+var sqliteTables = [][]byte{
+	bucketSignals,
+	bucketPriority,
+	bucketShipped,
+	bucketFirstSeen,
+	bucketWindows,
+	bucketWindowFired,
+	bucketRates,
+	bucketJournal,
+	bucketMeta,
+	bucketHistory,
+	bucketDedup,
+	bucketAggregate,
+	bucketRuleFires,
+	bucketLearning,
+	bucketLineage,
+	bucketTriage,
+}
+
+// sqliteViews decode the entries most often inspected by hand, such as
+// signals per rule or the age of baseline patterns
+const sqliteViews = `
+CREATE VIEW IF NOT EXISTS signal_history AS
+	SELECT json_extract(CAST(value AS TEXT), '$.signal_id') AS signal_id,
+		json_extract(CAST(value AS TEXT), '$.rule_id') AS rule_id,
+		json_extract(CAST(value AS TEXT), '$.ts') AS ts,
+		json_extract(CAST(value AS TEXT), '$.fields') AS fields
+	FROM history;
+CREATE VIEW IF NOT EXISTS rule_fire_counts AS
+	SELECT CAST(key AS TEXT) AS rule_id,
+		json_extract(CAST(value AS TEXT), '$.count') AS count,
+		json_extract(CAST(value AS TEXT), '$.first') AS first,
+		json_extract(CAST(value AS TEXT), '$.last') AS last
+	FROM rule_fires;
+CREATE VIEW IF NOT EXISTS first_seen_patterns AS
+	SELECT substr(CAST(key AS TEXT), 1, instr(CAST(key AS TEXT), ':') - 1) AS kind,
+		substr(CAST(key AS TEXT), instr(CAST(key AS TEXT), ':') + 1) AS pattern,
+		json_extract(CAST(value AS TEXT), '$.first') AS first_seen,
+		json_extract(CAST(value AS TEXT), '$.last') AS last_seen,
+		json_extract(CAST(value AS TEXT), '$.count') AS count,
+		julianday('now') - julianday(json_extract(CAST(value AS TEXT), '$.first')) AS age_days
+	FROM first_seen;
+CREATE VIEW IF NOT EXISTS triage_status AS
+	SELECT CAST(key AS TEXT) AS signal_id,
+		json_extract(CAST(value AS TEXT), '$.rule_id') AS rule_id,
+		json_extract(CAST(value AS TEXT), '$.status') AS status,
+		json_extract(CAST(value AS TEXT), '$.updated') AS updated
+	FROM triage;
+`
+
+// SQLite stores santamon state in a SQLite database. It keeps the entries of
+// DB in tables that can be queried with standard tooling, also while the
+// agent runs.
+type SQLite struct {
+	db           *sql.DB
+	maxFirstSeen int
+	writes       atomic.Int64
+}
+
+var _ Store = (*SQLite)(nil)
+
+// openSQLite opens the SQLite store for OpenStore
+func openSQLite(path string, maxFirstSeen int, syncWrites bool) (Store, error) {
+	db, err := OpenSQLite(path, maxFirstSeen, syncWrites)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenSQLite opens or creates the SQLite database
+func OpenSQLite(path string, maxFirstSeen int, syncWrites bool) (*SQLite, error) {
+	if err := checkOpenArgs(path, maxFirstSeen); err != nil {
+		return nil, err
+	}
+
+	// SQLite creates files world-readable; create it private like BoltDB
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	_ = f.Close()
+
+	synchronous := "OFF"
+	if syncWrites {
+		synchronous = "FULL"
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=1000&_journal_mode=WAL&_synchronous="+synchronous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// One connection serializes transactions like BoltDB's single writer
+	db.SetMaxOpenConns(1)
+
+	var schema bytes.Buffer
+	for _, table := range sqliteTables {
+		fmt.Fprintf(&schema, "CREATE TABLE IF NOT EXISTS %q (key BLOB PRIMARY KEY, value BLOB NOT NULL) WITHOUT ROWID;\n", table)
+	}
+	schema.WriteString(sqliteViews)
+	if _, err := db.Exec(schema.String()); err != nil {
+		// Ensure database is closed on error
+		if closeErr := db.Close(); closeErr != nil {
+			return nil, fmt.Errorf("failed to initialize tables: %w (also failed to close db: %v)", err, closeErr)
+		}
+		return nil, fmt.Errorf("failed to initialize tables: %w", err)
+	}
+
+	return &SQLite{db: db, maxFirstSeen: maxFirstSeen}, nil
+}
+
+// Close closes the database
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// sqliteTx is a transaction with the bucket operations of BoltDB
+type sqliteTx struct {
+	tx    *sql.Tx
+	wrote bool
+}
+
+// sqliteEntry is a key and value read from a table
+type sqliteEntry struct {
+	k, v []byte
+}
+
+// update runs fn in a read-write transaction, committed if fn returns nil
+func (s *SQLite) update(fn func(tx *sqliteTx) error) error {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	tx := &sqliteTx{tx: sqlTx}
+	if err := fn(tx); err != nil {
+		_ = sqlTx.Rollback()
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return err
+	}
+	if tx.wrote {
+		s.writes.Add(1)
+	}
+	return nil
+}
+
+// view runs fn in a transaction that is rolled back afterwards
+func (s *SQLite) view(fn func(tx *sqliteTx) error) error {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sqlTx.Rollback() }()
+	return fn(&sqliteTx{tx: sqlTx})
+}
+
+// get returns the value of key in table, nil if there is none
+func (tx *sqliteTx) get(table, key []byte) ([]byte, error) {
+	var val []byte
+	err := tx.tx.QueryRow(fmt.Sprintf("SELECT value FROM %q WHERE key = ?", table), key).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return val, err
+}
+
+// put sets the value of key in table
+func (tx *sqliteTx) put(table, key, val []byte) error {
+	tx.wrote = true
+	_, err := tx.tx.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %q (key, value) VALUES (?, ?)", table), key, val)
+	return err
+}
+
+// delete removes key from table
+func (tx *sqliteTx) delete(table, key []byte) error {
+	res, err := tx.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE key = ?", table), key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		tx.wrote = true
+	}
+	return nil
+}
+
+// deletePrefix removes the keys of table starting with prefix and returns
+// how many were removed
+func (tx *sqliteTx) deletePrefix(table, prefix []byte) (int, error) {
+	res, err := tx.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE substr(key, 1, ?) = ?", table), len(prefix), prefix)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if n > 0 {
+		tx.wrote = true
+	}
+	return int(n), err
+}
+
+// entries returns up to limit entries of table in key order, starting at
+// from and restricted to keys starting with prefix. A limit <= 0 returns
+// them all. The rows are read before returning, so the caller may write to
+// the table while going through them.
+func (tx *sqliteTx) entries(table, from, prefix []byte, limit int) ([]sqliteEntry, error) {
+	// A nil blob binds as NULL, which compares to nothing
+	if from == nil {
+		from = []byte{}
+	}
+	if prefix == nil {
+		prefix = []byte{}
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := tx.tx.Query(fmt.Sprintf("SELECT key, value FROM %q WHERE key >= ? AND substr(key, 1, ?) = ? ORDER BY key LIMIT ?", table),
+		from, len(prefix), prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []sqliteEntry
+	for rows.Next() {
+		var e sqliteEntry
+		if err := rows.Scan(&e.k, &e.v); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// count returns the number of entries in table
+func (tx *sqliteTx) count(table []byte) (int, error) {
+	var n int
+	err := tx.tx.QueryRow(fmt.Sprintf("SELECT count(*) FROM %q", table)).Scan(&n)
+	return n, err
+}
+
+// size returns the bytes of the keys and values in table
+func (tx *sqliteTx) size(table []byte) (int64, error) {
+	var n int64
+	err := tx.tx.QueryRow(fmt.Sprintf("SELECT coalesce(sum(length(key) + length(value)), 0) FROM %q", table)).Scan(&n)
+	return n, err
+}
+
+// EnqueueSignal adds a signal to the outbox queue
+func (s *SQLite) EnqueueSignal(sig *Signal) error {
+	if err := checkSignal(sig); err != nil {
+		return err
+	}
+	return s.update(func(tx *sqliteTx) error {
+		return tx.enqueue(sig)
+	})
+}
+
+// EnqueueSignalIfNotShipped enqueues a signal unless it was already shipped,
+// in one transaction. Returns true if the signal was enqueued.
+func (s *SQLite) EnqueueSignalIfNotShipped(sig *Signal) (bool, error) {
+	if err := checkSignal(sig); err != nil {
+		return false, err
+	}
+	var enqueued bool
+	err := s.update(func(tx *sqliteTx) error {
+		shipped, err := tx.get(bucketShipped, []byte(sig.ID))
+		if err != nil || shipped != nil {
+			return err
+		}
+		enqueued = true
+		return tx.enqueue(sig)
+	})
+	return enqueued, err
+}
+
+// enqueue adds sig to its outbox lane and to the history
+func (tx *sqliteTx) enqueue(sig *Signal) error {
+	val, err := json.Marshal(sig)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signal: %w", err)
+	}
+	if err := tx.put(queueBucket(sig), queueKey(sig.ID), val); err != nil {
+		return err
+	}
+	key, val, err := historyRecord(sig)
+	if err != nil {
+		return err
+	}
+	return tx.put(bucketHistory, key, val)
+}
+
+// DequeueSignals retrieves and removes up to limit signals from the queue
+func (s *SQLite) DequeueSignals(limit int) ([]*Signal, error) {
+	var signals []*Signal
+	// Priority signals always drain first
+	err := s.update(func(tx *sqliteTx) error {
+		var err error
+		signals, err = tx.dequeue(bucketPriority, limit, signals)
+		if err != nil {
+			return err
+		}
+		signals, err = tx.dequeue(bucketSignals, limit-len(signals), signals)
+		return err
+	})
+	return signals, err
+}
+
+// DequeuePrioritySignals retrieves and removes only priority signals from the outbox
+func (s *SQLite) DequeuePrioritySignals(limit int) ([]*Signal, error) {
+	var signals []*Signal
+	err := s.update(func(tx *sqliteTx) error {
+		var err error
+		signals, err = tx.dequeue(bucketPriority, limit, signals)
+		return err
+	})
+	return signals, err
+}
+
+// dequeue removes up to limit signals from table and appends them to signals
+func (tx *sqliteTx) dequeue(table []byte, limit int, signals []*Signal) ([]*Signal, error) {
+	if limit <= 0 {
+		return signals, nil
+	}
+	entries, err := tx.entries(table, nil, nil, limit)
+	if err != nil {
+		return signals, err
+	}
+	for _, e := range entries {
+		if err := tx.delete(table, e.k); err != nil {
+			return signals, err
+		}
+		var sig Signal
+		if err := json.Unmarshal(e.v, &sig); err != nil {
+			continue
+		}
+		signals = append(signals, &sig)
+	}
+	return signals, nil
+}
+
+// ListQueue returns up to limit queued signals in shipping order (priority
+// first) without removing them. A limit <= 0 returns the whole queue.
+func (s *SQLite) ListQueue(limit int) ([]*QueuedSignal, error) {
+	var queued []*QueuedSignal
+	err := s.view(func(tx *sqliteTx) error {
+		for _, table := range [][]byte{bucketPriority, bucketSignals} {
+			if limit > 0 && len(queued) >= limit {
+				return nil
+			}
+			entries, err := tx.entries(table, nil, nil, limit-len(queued))
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				var sig Signal
+				if err := json.Unmarshal(e.v, &sig); err != nil {
+					continue
+				}
+				queued = append(queued, &QueuedSignal{Signal: &sig, QueuedAt: queueKeyTime(e.k)})
+			}
+		}
+		return nil
+	})
+	return queued, err
+}
+
+// DropQueued removes every queued copy of the signal with the given ID.
+// Returns the number of entries removed.
+func (s *SQLite) DropQueued(signalID string) (int, error) {
+	dropped := 0
+	err := s.update(func(tx *sqliteTx) error {
+		for _, table := range [][]byte{bucketPriority, bucketSignals} {
+			entries, err := tx.entries(table, nil, nil, 0)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if _, id, ok := bytes.Cut(e.k, []byte("_")); ok && string(id) == signalID {
+					if err := tx.delete(table, e.k); err != nil {
+						return err
+					}
+					dropped++
+				}
+			}
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+// PruneQueue removes signals queued before before from both lanes and
+// returns how many were removed
+func (s *SQLite) PruneQueue(before time.Time) (int, error) {
+	return s.pruneTables(queuedBefore(before), bucketPriority, bucketSignals)
+}
+
+// pruneTables deletes the entries of the named tables that stale reports,
+// going through at most pruneBatch entries per transaction, and returns how
+// many were deleted
+func (s *SQLite) pruneTables(stale func(k, v []byte) bool, tables ...[]byte) (int, error) {
+	removed := 0
+	for _, table := range tables {
+		var from []byte
+		for {
+			var n int
+			err := s.update(func(tx *sqliteTx) error {
+				entries, err := tx.entries(table, from, nil, pruneBatch+1)
+				if err != nil {
+					return err
+				}
+				from = nil
+				if len(entries) > pruneBatch {
+					from = entries[pruneBatch].k
+					entries = entries[:pruneBatch]
+				}
+				n = 0
+				for _, e := range entries {
+					if !stale(e.k, e.v) {
+						continue
+					}
+					if err := tx.delete(table, e.k); err != nil {
+						return err
+					}
+					n++
+				}
+				return nil
+			})
+			if err != nil {
+				return removed, err
+			}
+			removed += n
+			if from == nil {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
+
+// History returns signal history entries at or after since, oldest first
+func (s *SQLite) History(since time.Time) ([]*HistoryEntry, error) {
+	var history []*HistoryEntry
+	err := s.view(func(tx *sqliteTx) error {
+		entries, err := tx.entries(bucketHistory, historyKey(since, ""), nil, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			var entry HistoryEntry
+			if err := json.Unmarshal(e.v, &entry); err != nil {
+				continue
+			}
+			history = append(history, &entry)
+		}
+		return nil
+	})
+	return history, err
+}
+
+// PruneHistory removes history entries older than before and returns how many were removed
+func (s *SQLite) PruneHistory(before time.Time) (int, error) {
+	removed := 0
+	err := s.update(func(tx *sqliteTx) error {
+		res, err := tx.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE key < ?", bucketHistory), historyKey(before, ""))
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		removed = int(n)
+		tx.wrote = n > 0
+		return err
+	})
+	return removed, err
+}
+
+// MarkShipped records that a signal was successfully shipped
+func (s *SQLite) MarkShipped(signalID string) error {
+	return s.update(func(tx *sqliteTx) error {
+		return tx.put(bucketShipped, []byte(signalID), []byte(time.Now().Format(time.RFC3339)))
+	})
+}
+
+// IsShipped checks if a signal has already been shipped
+func (s *SQLite) IsShipped(signalID string) (bool, error) {
+	var shipped bool
+	err := s.view(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketShipped, []byte(signalID))
+		shipped = val != nil
+		return err
+	})
+	return shipped, err
+}
+
+// SetTriage records a status change of a signal and reports whether its
+// status changed. Changes older than the latest one recorded arrived out of
+// order and are ignored, as are repeats of the current status.
+func (s *SQLite) SetTriage(signalID, ruleID string, t TriageTransition) (bool, error) {
+	if err := checkStatus(t.Status); err != nil {
+		return false, err
+	}
+	changed := false
+	err := s.update(func(tx *sqliteTx) error {
+		rec := TriageRecord{SignalID: signalID, Status: StatusOpen}
+		existing, err := tx.get(bucketTriage, []byte(signalID))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if err := json.Unmarshal(existing, &rec); err != nil {
+				return fmt.Errorf("failed to unmarshal triage record: %w", err)
+			}
+		}
+		if changed = rec.apply(ruleID, t); !changed {
+			return nil
+		}
+		val, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to marshal triage record: %w", err)
+		}
+		return tx.put(bucketTriage, []byte(signalID), val)
+	})
+	return changed, err
+}
+
+// Triage returns the triage record of a signal, or nil if it was never
+// triaged
+func (s *SQLite) Triage(signalID string) (*TriageRecord, error) {
+	var rec *TriageRecord
+	err := s.view(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketTriage, []byte(signalID))
+		if err != nil || val == nil {
+			return err
+		}
+		rec = &TriageRecord{}
+		return json.Unmarshal(val, rec)
+	})
+	return rec, err
+}
+
+// SignalStatus returns the triage status of a signal, open if it was never
+// triaged
+func (s *SQLite) SignalStatus(signalID string) (string, error) {
+	rec, err := s.Triage(signalID)
+	if err != nil || rec == nil {
+		return StatusOpen, err
+	}
+	return rec.Status, nil
+}
+
+// ForEachTriage calls fn for each triage record, in signal ID order
+func (s *SQLite) ForEachTriage(fn func(rec *TriageRecord) error) error {
+	entries, err := s.all(bucketTriage)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var rec TriageRecord
+		if err := json.Unmarshal(e.v, &rec); err != nil {
+			continue
+		}
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// all returns every entry of table in key order. Callbacks run on them
+// after the read, so they may use the store.
+func (s *SQLite) all(table []byte) ([]sqliteEntry, error) {
+	var entries []sqliteEntry
+	err := s.view(func(tx *sqliteTx) error {
+		var err error
+		entries, err = tx.entries(table, nil, nil, 0)
+		return err
+	})
+	return entries, err
+}
+
+// PruneTriage removes the triage records last updated before before and
+// returns how many were removed
+func (s *SQLite) PruneTriage(before time.Time) (int, error) {
+	return s.pruneTables(triagedBefore(before), bucketTriage)
+}
+
+// IsFirstSeen checks if an artifact is being seen for the first time
+// Returns true if first seen, false if already tracked
+func (s *SQLite) IsFirstSeen(kind, id string) (bool, error) {
+	count, _, err := s.RecordSeen(kind, id, time.Now(), 0)
+	return count == 1, err
+}
+
+// RecordSeen records a sighting of an artifact at now and returns how many
+// times it has been seen, including this one. An artifact last seen
+// forgetAfter or longer before now is tracked anew: its count starts over
+// and the previous sighting is returned. Zero forgetAfter never forgets.
+func (s *SQLite) RecordSeen(kind, id string, now time.Time, forgetAfter time.Duration) (int, time.Time, error) {
+	var count int
+	var lastSeen time.Time
+
+	err := s.update(func(tx *sqliteTx) error {
+		key := firstSeenKey(kind, id)
+		existing, err := tx.get(bucketFirstSeen, key)
+		if err != nil {
+			return err
+		}
+		entry := FirstSeenEntry{First: now, Count: 1, Last: now}
+		if existing == nil {
+			if err := tx.evictFirstSeen(s.maxFirstSeen); err != nil {
+				return err
+			}
+		} else {
+			if err := json.Unmarshal(existing, &entry); err != nil {
+				return nil
+			}
+			lastSeen = entry.sight(now, forgetAfter)
+		}
+		count = entry.Count
+
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketFirstSeen, key, val)
+	})
+
+	return count, lastSeen, err
+}
+
+// evictFirstSeen makes room for a new artifact when limit are tracked
+func (tx *sqliteTx) evictFirstSeen(limit int) error {
+	n, err := tx.count(bucketFirstSeen)
+	if err != nil || n < limit {
+		return err
+	}
+	_, err = tx.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE key = (SELECT min(key) FROM %q)", bucketFirstSeen, bucketFirstSeen))
+	return err
+}
+
+// FirstSeenEntries returns the tracked artifacts of kind by ID
+func (s *SQLite) FirstSeenEntries(kind string) (map[string]FirstSeenEntry, error) {
+	tracked := make(map[string]FirstSeenEntry)
+	prefix := []byte(kind + ":")
+	err := s.view(func(tx *sqliteTx) error {
+		entries, err := tx.entries(bucketFirstSeen, nil, prefix, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			var entry FirstSeenEntry
+			if err := json.Unmarshal(e.v, &entry); err != nil {
+				continue
+			}
+			tracked[string(e.k[len(prefix):])] = entry
+		}
+		return nil
+	})
+	return tracked, err
+}
+
+// ForEachFirstSeen calls fn for every tracked artifact, in key order
+func (s *SQLite) ForEachFirstSeen(fn func(kind, id string, entry FirstSeenEntry) error) error {
+	entries, err := s.all(bucketFirstSeen)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		kind, id, ok := bytes.Cut(e.k, []byte(":"))
+		if !ok {
+			continue
+		}
+		var entry FirstSeenEntry
+		if err := json.Unmarshal(e.v, &entry); err != nil {
+			continue
+		}
+		if err := fn(string(kind), string(id), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneFirstSeen removes tracked artifacts last seen before before and
+// returns how many were removed
+func (s *SQLite) PruneFirstSeen(before time.Time) (int, error) {
+	return s.pruneTables(seenBefore(before), bucketFirstSeen)
+}
+
+// FirstSeenStats returns how many artifacts of kind are tracked and their
+// total sightings
+func (s *SQLite) FirstSeenStats(kind string) (int, int, error) {
+	tracked, err := s.FirstSeenEntries(kind)
+	if err != nil {
+		return 0, 0, err
+	}
+	sightings := 0
+	for _, entry := range tracked {
+		sightings += entry.Count
+	}
+	return len(tracked), sightings, nil
+}
+
+// LearningStart returns when the baseline rule ruleID started learning. A
+// rule not seen before, or whose content hash changed, starts at now.
+func (s *SQLite) LearningStart(ruleID, hash string, now time.Time) (time.Time, error) {
+	start := now
+	err := s.update(func(tx *sqliteTx) error {
+		v, err := tx.get(bucketLearning, []byte(ruleID))
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			var entry learningEntry
+			if err := json.Unmarshal(v, &entry); err == nil && entry.Hash == hash {
+				start = entry.Start
+				return nil
+			}
+		}
+		val, err := json.Marshal(learningEntry{Hash: hash, Start: now})
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketLearning, []byte(ruleID), val)
+	})
+	return start, err
+}
+
+// SeedFirstSeen merges an artifact learned elsewhere into the first-seen
+// store, keeping the earliest first and latest last sighting of both. It
+// reports whether the artifact was new.
+func (s *SQLite) SeedFirstSeen(kind, id string, seed FirstSeenEntry) (bool, error) {
+	var added bool
+	err := s.update(func(tx *sqliteTx) error {
+		key := firstSeenKey(kind, id)
+		existing, err := tx.get(bucketFirstSeen, key)
+		if err != nil {
+			return err
+		}
+
+		entry := seed
+		if existing != nil {
+			if err := json.Unmarshal(existing, &entry); err != nil {
+				return err
+			}
+			entry.merge(seed)
+		} else {
+			added = true
+			if err := tx.evictFirstSeen(s.maxFirstSeen); err != nil {
+				return err
+			}
+		}
+
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketFirstSeen, key, val)
+	})
+	return added, err
+}
+
+// Dedup records an occurrence of sig under key at now. It returns true when
+// the occurrence opens a new window and sig should be emitted, and false when
+// it repeats a signal emitted less than cooldown before. When the occurrence
+// replaces a window that has ended, the old entry is returned as well.
+func (s *SQLite) Dedup(key string, sig *Signal, now time.Time, cooldown time.Duration) (bool, *DedupEntry, error) {
+	emit := false
+	var expired *DedupEntry
+
+	err := s.update(func(tx *sqliteTx) error {
+		existing, err := tx.get(bucketDedup, []byte(key))
+		if err != nil {
+			return err
+		}
+		var val []byte
+		val, emit, expired, err = dedupOccurrence(existing, key, sig, now, cooldown)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketDedup, []byte(key), val)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return emit, expired, nil
+}
+
+// ExpireDedup removes the dedup windows that opened before before and returns them
+func (s *SQLite) ExpireDedup(before time.Time) ([]*DedupEntry, error) {
+	return expireEntries(s.pruneTables, bucketDedup, before, func(e *DedupEntry) time.Time { return e.First })
+}
+
+// AddAggregate counts a match of an aggregated rule at now. sig is kept as the
+// template of the rollup when it is the first match since the last rollup.
+// Up to maxTargets distinct targets are recorded.
+func (s *SQLite) AddAggregate(ruleID, target string, sig *Signal, now time.Time, maxTargets int) error {
+	return s.update(func(tx *sqliteTx) error {
+		entry := AggregateEntry{RuleID: ruleID, First: now, Last: now, Signal: sig}
+		existing, err := tx.get(bucketAggregate, []byte(ruleID))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if err := json.Unmarshal(existing, &entry); err != nil {
+				return fmt.Errorf("failed to unmarshal aggregate entry: %w", err)
+			}
+		}
+
+		entry.add(target, now, maxTargets)
+
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal aggregate entry: %w", err)
+		}
+		return tx.put(bucketAggregate, []byte(ruleID), val)
+	})
+}
+
+// ExpireAggregates removes the aggregates whose first match was before
+// before and returns them
+func (s *SQLite) ExpireAggregates(before time.Time) ([]*AggregateEntry, error) {
+	return expireEntries(s.pruneTables, bucketAggregate, before, func(e *AggregateEntry) time.Time { return e.First })
+}
+
+// AddRuleFires adds a batch of fires, keyed by rule ID, to the fire history
+// in one transaction
+func (s *SQLite) AddRuleFires(fires map[string]*RuleFires) error {
+	if len(fires) == 0 {
+		return nil
+	}
+	return s.update(func(tx *sqliteTx) error {
+		for ruleID, add := range fires {
+			entry := RuleFires{RuleID: ruleID, First: add.First, Last: add.Last}
+			existing, err := tx.get(bucketRuleFires, []byte(ruleID))
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if err := json.Unmarshal(existing, &entry); err != nil {
+					return fmt.Errorf("failed to unmarshal rule fires: %w", err)
+				}
+			}
+			entry.merge(add)
+
+			val, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("failed to marshal rule fires: %w", err)
+			}
+			if err := tx.put(bucketRuleFires, []byte(ruleID), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RuleFireHistory returns the fire history of every rule that has fired,
+// sorted by rule ID
+func (s *SQLite) RuleFireHistory() ([]*RuleFires, error) {
+	entries, err := s.all(bucketRuleFires)
+	if err != nil {
+		return nil, err
+	}
+	var fires []*RuleFires
+	for _, e := range entries {
+		var entry RuleFires
+		if err := json.Unmarshal(e.v, &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rule fires for %s: %w", e.k, err)
+		}
+		fires = append(fires, &entry)
+	}
+	return fires, nil
+}
+
+// SaveLineage replaces the persisted process lineage with snapshots, keyed
+// by boot session UUID. Sessions missing from snapshots are removed.
+func (s *SQLite) SaveLineage(snapshots map[string][]byte) error {
+	return s.update(func(tx *sqliteTx) error {
+		entries, err := tx.entries(bucketLineage, nil, nil, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if _, ok := snapshots[string(e.k)]; !ok {
+				if err := tx.delete(bucketLineage, e.k); err != nil {
+					return err
+				}
+			}
+		}
+		for boot, data := range snapshots {
+			if err := tx.put(bucketLineage, []byte(boot), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadLineage returns the persisted process lineage, keyed by boot session UUID
+func (s *SQLite) LoadLineage() (map[string][]byte, error) {
+	entries, err := s.all(bucketLineage)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		snapshots[string(e.k)] = e.v
+	}
+	return snapshots, nil
+}
+
+// UpdateJournal records progress processing a spool file
+func (s *SQLite) UpdateJournal(filename string, offset int64) error {
+	return s.putJournal(filename, JournalEntry{Offset: offset, ProcessedTS: time.Now()})
+}
+
+// SaveCheckpoint records partial progress processing a spool file; the
+// journal entry stays incomplete until UpdateJournal marks it processed
+func (s *SQLite) SaveCheckpoint(filename string, cp Checkpoint) error {
+	return s.putJournal(filename, JournalEntry{ProcessedTS: time.Now(), Checkpoint: &cp})
+}
+
+// putJournal stores the journal entry of a spool file
+func (s *SQLite) putJournal(filename string, entry JournalEntry) error {
+	val, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *sqliteTx) error {
+		return tx.put(bucketJournal, []byte(filename), val)
+	})
+}
+
+// Writes returns the number of committed transactions that changed state
+// since the database was opened
+func (s *SQLite) Writes() int64 {
+	return s.writes.Load()
+}
+
+// GetJournalEntry retrieves the processing progress for a spool file
+func (s *SQLite) GetJournalEntry(filename string) (*JournalEntry, error) {
+	var entry *JournalEntry
+	err := s.view(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketJournal, []byte(filename))
+		if err != nil || val == nil {
+			return err
+		}
+		entry = &JournalEntry{}
+		return json.Unmarshal(val, entry)
+	})
+	return entry, err
+}
+
+// PruneJournal removes the markers of spool files processed before before,
+// keeping checkpoints of files still being processed, and returns how many
+// were removed
+func (s *SQLite) PruneJournal(before time.Time) (int, error) {
+	return s.pruneTables(processedBefore(before), bucketJournal)
+}
+
+// ReserveEventSeq reserves n consecutive event sequence numbers and returns
+// the first, like DB.ReserveEventSeq
+func (s *SQLite) ReserveEventSeq(n int) (uint64, error) {
+	var first uint64
+	err := s.update(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketMeta, []byte(metaEventSeq))
+		if err != nil {
+			return err
+		}
+		var last uint64
+		if val != nil {
+			if last, err = strconv.ParseUint(string(val), 10, 64); err != nil {
+				return fmt.Errorf("invalid %s: %w", metaEventSeq, err)
+			}
+		}
+		first = last + 1
+		return tx.put(bucketMeta, []byte(metaEventSeq), []byte(strconv.FormatUint(last+uint64(n), 10)))
+	})
+	return first, err
+}
+
+// EventSeq returns the last reserved event sequence number, 0 if none
+func (s *SQLite) EventSeq() (uint64, error) {
+	val, err := s.GetMeta(metaEventSeq)
+	if err != nil || val == "" {
+		return 0, err
+	}
+	return strconv.ParseUint(val, 10, 64)
+}
+
+// SetMeta stores a metadata key-value pair
+func (s *SQLite) SetMeta(key, value string) error {
+	return s.update(func(tx *sqliteTx) error {
+		return tx.put(bucketMeta, []byte(key), []byte(value))
+	})
+}
+
+// GetMeta retrieves a metadata value
+func (s *SQLite) GetMeta(key string) (string, error) {
+	var value string
+	err := s.view(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketMeta, []byte(key))
+		value = string(val)
+		return err
+	})
+	return value, err
+}
+
+// StoreWindowEvent stores an event for correlation window processing
+func (s *SQLite) StoreWindowEvent(ruleID, groupKey string, event map[string]any) error {
+	return s.update(func(tx *sqliteTx) error {
+		key := windowKey(ruleID, groupKey)
+		var events []map[string]any
+		existing, err := tx.get(bucketWindows, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if err := json.Unmarshal(existing, &events); err != nil {
+				return err
+			}
+		}
+		events = append(events, event)
+
+		val, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketWindows, key, val)
+	})
+}
+
+// GetWindowEvents retrieves events for a correlation window
+func (s *SQLite) GetWindowEvents(ruleID, groupKey string) ([]map[string]any, error) {
+	var events []map[string]any
+	err := s.view(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketWindows, windowKey(ruleID, groupKey))
+		if err != nil || val == nil {
+			return err
+		}
+		return json.Unmarshal(val, &events)
+	})
+	return events, err
+}
+
+// WindowGroups returns the group keys with stored events for a correlation rule
+func (s *SQLite) WindowGroups(ruleID string) ([]string, error) {
+	var keys []string
+	prefix := windowKey(ruleID, "")
+	err := s.view(func(tx *sqliteTx) error {
+		entries, err := tx.entries(bucketWindows, nil, prefix, 0)
+		for _, e := range entries {
+			keys = append(keys, string(e.k[len(prefix):]))
+		}
+		return err
+	})
+	return keys, err
+}
+
+// WindowRules returns the IDs of correlation rules with stored windows, fire
+// times or rate counters
+func (s *SQLite) WindowRules() ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	err := s.view(func(tx *sqliteTx) error {
+		for _, table := range [][]byte{bucketWindows, bucketWindowFired, bucketRates} {
+			rows, err := tx.tx.Query(fmt.Sprintf("SELECT DISTINCT substr(key, 1, instr(key, x'00') - 1) FROM %q WHERE instr(key, x'00') > 0 ORDER BY 1", table))
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var id []byte
+				if err := rows.Scan(&id); err != nil {
+					_ = rows.Close()
+					return err
+				}
+				if !seen[string(id)] {
+					seen[string(id)] = true
+					ids = append(ids, string(id))
+				}
+			}
+			if err := rows.Close(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// PruneWindowEvents removes the events of a correlation rule's windows that
+// keep rejects and deletes windows left empty. It returns the number of
+// events and windows removed.
+func (s *SQLite) PruneWindowEvents(ruleID string, keep func(event map[string]any) bool) (int, int, error) {
+	var removedEvents, removedWindows int
+	err := s.update(func(tx *sqliteTx) error {
+		entries, err := tx.entries(bucketWindows, nil, windowKey(ruleID, ""), 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			val, removed, err := filterWindow(e.v, keep)
+			if err != nil {
+				return err
+			}
+			if removed == 0 {
+				continue
+			}
+			removedEvents += removed
+			if val == nil {
+				removedWindows++
+				err = tx.delete(bucketWindows, e.k)
+			} else {
+				err = tx.put(bucketWindows, e.k, val)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removedEvents, removedWindows, err
+}
+
+// PruneWindowFired removes the fire times of a correlation rule's groups that
+// keep rejects and returns how many were removed
+func (s *SQLite) PruneWindowFired(ruleID string, keep func(fired time.Time) bool) (int, error) {
+	return s.pruneRuleKeys(bucketWindowFired, ruleID, func(v []byte) (bool, error) {
+		var fired time.Time
+		if err := fired.UnmarshalBinary(v); err != nil {
+			return false, err
+		}
+		return !keep(fired), nil
+	})
+}
+
+// PruneRates removes the rate counters of a correlation rule's groups that
+// keep rejects and returns how many were removed. keep receives the counter
+// value as of its last update.
+func (s *SQLite) PruneRates(ruleID string, keep func(value float64, updated time.Time) bool) (int, error) {
+	return s.pruneRuleKeys(bucketRates, ruleID, func(v []byte) (bool, error) {
+		var c rateCounter
+		if err := json.Unmarshal(v, &c); err != nil {
+			return false, err
+		}
+		return !keep(c.Value, c.Updated), nil
+	})
+}
+
+// pruneRuleKeys deletes the windowKey entries of a rule that remove reports
+// and returns how many were deleted
+func (s *SQLite) pruneRuleKeys(table []byte, ruleID string, remove func(v []byte) (bool, error)) (int, error) {
+	removed := 0
+	err := s.update(func(tx *sqliteTx) error {
+		entries, err := tx.entries(table, nil, windowKey(ruleID, ""), 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			ok, err := remove(e.v)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err := tx.delete(table, e.k); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// DropWindowRule removes all state of a correlation rule: its windows, fire
+// times and rate counters. It returns the number of groups removed.
+func (s *SQLite) DropWindowRule(ruleID string) (int, error) {
+	removed := 0
+	prefix := windowKey(ruleID, "")
+	err := s.update(func(tx *sqliteTx) error {
+		windows, err := tx.deletePrefix(bucketWindows, prefix)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.deletePrefix(bucketWindowFired, prefix); err != nil {
+			return err
+		}
+		rates, err := tx.deletePrefix(bucketRates, prefix)
+		removed = windows + rates
+		return err
+	})
+	return removed, err
+}
+
+// DropWindowGroup removes the window, fire time and rate counter of one
+// correlation group
+func (s *SQLite) DropWindowGroup(ruleID, groupKey string) error {
+	key := windowKey(ruleID, groupKey)
+	return s.update(func(tx *sqliteTx) error {
+		for _, table := range [][]byte{bucketWindows, bucketWindowFired, bucketRates} {
+			if err := tx.delete(table, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WindowUsage calls fn with the number of stored events of every correlation
+// group. A rate counter counts as one event.
+func (s *SQLite) WindowUsage(fn func(ruleID, groupKey string, events int)) error {
+	windows, err := s.all(bucketWindows)
+	if err != nil {
+		return err
+	}
+	for _, e := range windows {
+		ruleID, groupKey, ok := bytes.Cut(e.k, []byte{0})
+		if !ok {
+			continue
+		}
+		var events []json.RawMessage
+		if err := json.Unmarshal(e.v, &events); err != nil {
+			return err
+		}
+		fn(string(ruleID), string(groupKey), len(events))
+	}
+	rates, err := s.all(bucketRates)
+	if err != nil {
+		return err
+	}
+	for _, e := range rates {
+		if ruleID, groupKey, ok := bytes.Cut(e.k, []byte{0}); ok {
+			fn(string(ruleID), string(groupKey), 1)
+		}
+	}
+	return nil
+}
+
+// WindowBytes returns the bytes in use by correlation windows, fire times
+// and rate counters
+func (s *SQLite) WindowBytes() (int64, error) {
+	var total int64
+	err := s.view(func(tx *sqliteTx) error {
+		for _, table := range [][]byte{bucketWindows, bucketWindowFired, bucketRates} {
+			n, err := tx.size(table)
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	return total, err
+}
+
+// CleanWindowEvents removes old events from correlation windows
+func (s *SQLite) CleanWindowEvents(ruleID, groupKey string, keepCount int) error {
+	return s.update(func(tx *sqliteTx) error {
+		key := windowKey(ruleID, groupKey)
+		val, err := tx.get(bucketWindows, key)
+		if err != nil || val == nil {
+			return err
+		}
+
+		var events []map[string]any
+		if err := json.Unmarshal(val, &events); err != nil {
+			return err
+		}
+
+		// Keep only recent events
+		if len(events) > keepCount {
+			events = events[len(events)-keepCount:]
+		}
+
+		newVal, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketWindows, key, newVal)
+	})
+}
+
+// ReplaceWindowEvents overwrites a correlation window with the provided events.
+// If events is empty or nil, the entry is removed.
+func (s *SQLite) ReplaceWindowEvents(ruleID, groupKey string, events []map[string]any) error {
+	return s.update(func(tx *sqliteTx) error {
+		key := windowKey(ruleID, groupKey)
+		if len(events) == 0 {
+			return tx.delete(bucketWindows, key)
+		}
+
+		val, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucketWindows, key, val)
+	})
+}
+
+// WindowFired returns when a correlation group last matched, or the zero time
+func (s *SQLite) WindowFired(ruleID, groupKey string) (time.Time, error) {
+	var fired time.Time
+	err := s.view(func(tx *sqliteTx) error {
+		val, err := tx.get(bucketWindowFired, windowKey(ruleID, groupKey))
+		if err != nil || val == nil {
+			return err
+		}
+		return fired.UnmarshalBinary(val)
+	})
+	return fired, err
+}
+
+// SetWindowFired records when a correlation group matched
+func (s *SQLite) SetWindowFired(ruleID, groupKey string, ts time.Time) error {
+	val, err := ts.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *sqliteTx) error {
+		return tx.put(bucketWindowFired, windowKey(ruleID, groupKey), val)
+	})
+}
+
+// IncrementRate decays a correlation group's event counter with time
+// constant tau, adds one event at now and returns the new value. At a steady
+// rate r the value approaches r*tau.
+func (s *SQLite) IncrementRate(ruleID, groupKey string, now time.Time, tau time.Duration) (float64, error) {
+	var value float64
+	err := s.update(func(tx *sqliteTx) error {
+		key := windowKey(ruleID, groupKey)
+		existing, err := tx.get(bucketRates, key)
+		if err != nil {
+			return err
+		}
+		val, v, err := incrementRate(existing, now, tau)
+		if err != nil {
+			return err
+		}
+		value = v
+		return tx.put(bucketRates, key, val)
+	})
+	return value, err
+}
+
+// ResetRate clears a correlation group's event counter
+func (s *SQLite) ResetRate(ruleID, groupKey string) error {
+	return s.update(func(tx *sqliteTx) error {
+		return tx.delete(bucketRates, windowKey(ruleID, groupKey))
+	})
+}
+
+// Ping verifies the database is open and readable
+func (s *SQLite) Ping() error {
+	return s.view(func(tx *sqliteTx) error {
+		_, err := tx.count(bucketMeta)
+		return err
+	})
+}
+
+// Stats returns database statistics under the keys of DB.Stats, except for
+// the BoltDB transaction and page counters
+func (s *SQLite) Stats() (map[string]any, error) {
+	stats := make(map[string]any)
+
+	err := s.view(func(tx *sqliteTx) error {
+		bucketBytes := make(map[string]int)
+		for _, table := range sqliteTables {
+			n, err := tx.count(table)
+			if err != nil {
+				return err
+			}
+			stats[string(table)] = n
+			size, err := tx.size(table)
+			if err != nil {
+				return err
+			}
+			bucketBytes[string(table)] = int(size)
+		}
+		delete(stats, string(bucketLearning))
+		delete(stats, string(bucketLineage))
+		delete(stats, string(bucketMeta))
+		stats["bucket_bytes"] = bucketBytes
+
+		var pageCount, pageSize, freePages int64
+		if err := tx.tx.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+			return err
+		}
+		if err := tx.tx.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+			return err
+		}
+		if err := tx.tx.QueryRow("PRAGMA freelist_count").Scan(&freePages); err != nil {
+			return err
+		}
+		stats["file_size"] = pageCount * pageSize
+		stats["page_count"] = pageCount
+		stats["free_pages"] = freePages
+		return nil
+	})
+
+	return stats, err
+}
+
+// RequestCompaction marks the database for compaction by CompactIfRequested
+func (s *SQLite) RequestCompaction() error {
+	return s.SetMeta(metaCompactPending, "true")
+}
+
+// CompactIfRequested compacts the database when RequestCompaction was called
+// since the last compaction, and reports whether it did
+func (s *SQLite) CompactIfRequested() (bool, error) {
+	pending, err := s.GetMeta(metaCompactPending)
+	if err != nil || pending == "" {
+		return false, err
+	}
+	if err := s.update(func(tx *sqliteTx) error {
+		return tx.delete(bucketMeta, []byte(metaCompactPending))
+	}); err != nil {
+		return false, err
+	}
+	return true, s.Compact()
+}
+
+// Compact rebuilds the database without the free pages left by deleted
+// entries
+func (s *SQLite) Compact() error {
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to compact database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !sqlite
+
+package state
+
+import "fmt"
+
+// openSQLite fails in builds without the sqlite tag, which leaves out the
+// cgo SQLite driver
+func openSQLite(string, int, bool) (Store, error) {
+	return nil, fmt.Errorf("state backend %q is not available in this build (build with -tags sqlite)", BackendSQLite)
+}
@@ -0,0 +1,347 @@
+//go:build sqlite
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// setupTestSQLite creates a temporary SQLite database for testing
+func setupTestSQLite(t *testing.T, maxFirstSeen int) (*SQLite, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.sqlite")
+	db, err := OpenSQLite(dbPath, maxFirstSeen, true)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, dbPath
+}
+
+func TestOpenStoreSQLite(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenStore(BackendSQLite, dbPath, 1000, false)
+	if err != nil {
+		t.Fatalf("OpenStore(sqlite) error = %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if _, ok := store.(*SQLite); !ok {
+		t.Errorf("OpenStore(sqlite) = %T, want *SQLite", store)
+	}
+	if err := store.Ping(); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	if info, err := os.Stat(dbPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("database mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	if _, err := OpenSQLite("", 1000, false); err == nil {
+		t.Error("OpenSQLite(\"\") succeeded, want error")
+	}
+}
+
+func TestSQLiteQueue(t *testing.T) {
+	db, dbPath := setupTestSQLite(t, 1000)
+
+	now := time.Now()
+	signals := []*Signal{
+		{ID: "bulk-1", TS: now, RuleID: "RULE-001", Context: map[string]any{"actor_path": "/bin/sh"}},
+		{ID: "prio-1", TS: now, RuleID: "RULE-002", Priority: true},
+		{ID: "bulk-2", TS: now, RuleID: "RULE-001"},
+	}
+	for _, sig := range signals {
+		if err := db.EnqueueSignal(sig); err != nil {
+			t.Fatalf("Failed to enqueue signal: %v", err)
+		}
+	}
+	if err := db.EnqueueSignal(&Signal{ID: "no-rule"}); err == nil {
+		t.Error("EnqueueSignal() without rule succeeded, want error")
+	}
+
+	queued, err := db.ListQueue(0)
+	if err != nil {
+		t.Fatalf("Failed to list queue: %v", err)
+	}
+	var ids []string
+	for _, q := range queued {
+		ids = append(ids, q.ID)
+	}
+	if !slices.Equal(ids, []string{"prio-1", "bulk-1", "bulk-2"}) {
+		t.Errorf("ListQueue() = %v, want [prio-1 bulk-1 bulk-2]", ids)
+	}
+	if dropped, err := db.DropQueued("bulk-2"); err != nil || dropped != 1 {
+		t.Errorf("DropQueued() = %d, %v; want 1", dropped, err)
+	}
+
+	dequeued, err := db.DequeueSignals(10)
+	if err != nil || len(dequeued) != 2 || dequeued[0].ID != "prio-1" || dequeued[1].ID != "bulk-1" {
+		t.Fatalf("DequeueSignals() = %v, %v; want prio-1, bulk-1", dequeued, err)
+	}
+	if err := db.MarkShipped("bulk-1"); err != nil {
+		t.Fatalf("Failed to mark shipped: %v", err)
+	}
+	if shipped, _ := db.IsShipped("bulk-1"); !shipped {
+		t.Error("IsShipped() = false after MarkShipped")
+	}
+	if enqueued, err := db.EnqueueSignalIfNotShipped(signals[0]); err != nil || enqueued {
+		t.Errorf("EnqueueSignalIfNotShipped(shipped) = %v, %v; want false", enqueued, err)
+	}
+
+	history, err := db.History(now.Add(-time.Minute))
+	if err != nil || len(history) != 3 {
+		t.Fatalf("History() = %d entries, %v; want 3", len(history), err)
+	}
+
+	// Signals per rule are a query away
+	var count int
+	query := "SELECT count(*) FROM signal_history WHERE rule_id = 'RULE-001'"
+	if err := db.db.QueryRow(query).Scan(&count); err != nil || count != 2 {
+		t.Errorf("signal_history RULE-001 = %d, %v; want 2", count, err)
+	}
+
+	if removed, err := db.PruneHistory(now.Add(time.Minute)); err != nil || removed != 3 {
+		t.Errorf("PruneHistory() = %d, %v; want 3", removed, err)
+	}
+
+	// Entries survive reopening
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+	reopened, err := OpenSQLite(dbPath, 1000, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if shipped, _ := reopened.IsShipped("bulk-1"); !shipped {
+		t.Error("IsShipped() = false after reopening")
+	}
+}
+
+func TestSQLitePrune(t *testing.T) {
+	db, _ := setupTestSQLite(t, 10000)
+
+	// More entries than one prune batch holds
+	old := time.Now().Add(-48 * time.Hour)
+	for i := range pruneBatch + 5 {
+		if _, _, err := db.RecordSeen("RULE-001", strconv.Itoa(i), old, 0); err != nil {
+			t.Fatalf("Failed to record sighting: %v", err)
+		}
+	}
+	if _, _, err := db.RecordSeen("RULE-001", "fresh", time.Now(), 0); err != nil {
+		t.Fatalf("Failed to record sighting: %v", err)
+	}
+	removed, err := db.PruneFirstSeen(time.Now().Add(-24 * time.Hour))
+	if err != nil || removed != pruneBatch+5 {
+		t.Errorf("PruneFirstSeen() = %d, %v; want %d", removed, err, pruneBatch+5)
+	}
+	if tracked, sightings, _ := db.FirstSeenStats("RULE-001"); tracked != 1 || sightings != 1 {
+		t.Errorf("FirstSeenStats() = %d, %d; want 1, 1", tracked, sightings)
+	}
+
+	if _, _, err := db.Dedup("key", &Signal{ID: "s", RuleID: "RULE-001"}, old, time.Hour); err != nil {
+		t.Fatalf("Failed to dedup: %v", err)
+	}
+	expired, err := db.ExpireDedup(time.Now())
+	if err != nil || len(expired) != 1 || expired[0].Key != "key" {
+		t.Errorf("ExpireDedup() = %v, %v; want the key entry", expired, err)
+	}
+}
+
+func TestSQLiteFirstSeen(t *testing.T) {
+	db, _ := setupTestSQLite(t, 3)
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c", "d"} {
+		count, _, err := db.RecordSeen("RULE-001", id, t0.Add(time.Duration(i)*time.Minute), 0)
+		if err != nil || count != 1 {
+			t.Fatalf("RecordSeen(%s) = %d, %v; want 1", id, count, err)
+		}
+	}
+	// The smallest key made room for d
+	entries, err := db.FirstSeenEntries("RULE-001")
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("FirstSeenEntries() = %v, %v; want 3 entries", entries, err)
+	}
+	if _, ok := entries["a"]; ok {
+		t.Error("FirstSeenEntries() kept a past max entries")
+	}
+
+	count, last, err := db.RecordSeen("RULE-001", "b", t0.Add(time.Hour), 30*time.Minute)
+	if err != nil || count != 1 || !last.Equal(t0.Add(time.Minute)) {
+		t.Errorf("RecordSeen(forgotten b) = %d, %v, %v; want 1 and its last sighting", count, last, err)
+	}
+	if added, err := db.SeedFirstSeen("RULE-001", "c", FirstSeenEntry{First: t0.Add(-time.Hour), Last: t0, Count: 5}); err != nil || added {
+		t.Errorf("SeedFirstSeen(known) = %v, %v; want false", added, err)
+	}
+	if entries, _ := db.FirstSeenEntries("RULE-001"); !entries["c"].First.Equal(t0.Add(-time.Hour)) || entries["c"].Count != 5 {
+		t.Errorf("seeded entry = %+v, want first moved back and count 5", entries["c"])
+	}
+
+	// Pattern age is a query away
+	var kind, pattern string
+	var age float64
+	query := "SELECT kind, pattern, age_days FROM first_seen_patterns WHERE pattern = 'c'"
+	if err := db.db.QueryRow(query).Scan(&kind, &pattern, &age); err != nil {
+		t.Fatalf("Failed to query first_seen_patterns: %v", err)
+	}
+	if kind != "RULE-001" || age <= 0 {
+		t.Errorf("first_seen_patterns = %s, %s, %v; want RULE-001 and a positive age", kind, pattern, age)
+	}
+
+	start, err := db.LearningStart("RULE-001", "hash", t0)
+	if err != nil || !start.Equal(t0) {
+		t.Errorf("LearningStart() = %v, %v; want %v", start, err, t0)
+	}
+	if start, _ := db.LearningStart("RULE-001", "hash", t0.Add(time.Hour)); !start.Equal(t0) {
+		t.Errorf("LearningStart(same hash) = %v, want %v", start, t0)
+	}
+}
+
+func TestSQLiteWindows(t *testing.T) {
+	db, _ := setupTestSQLite(t, 1000)
+
+	for _, ev := range []map[string]any{{"n": 1.0}, {"n": 2.0}, {"n": 3.0}} {
+		if err := db.StoreWindowEvent("CORR-001", "user:alice", ev); err != nil {
+			t.Fatalf("Failed to store event: %v", err)
+		}
+	}
+	if err := db.StoreWindowEvent("CORR-001", "user:bob", map[string]any{"n": 1.0}); err != nil {
+		t.Fatalf("Failed to store event: %v", err)
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.SetWindowFired("CORR-001", "user:bob", now); err != nil {
+		t.Fatalf("Failed to set window fire time: %v", err)
+	}
+	if _, err := db.IncrementRate("CORR-002", "_global", now, time.Minute); err != nil {
+		t.Fatalf("Failed to increment rate: %v", err)
+	}
+
+	if groups, err := db.WindowGroups("CORR-001"); err != nil || !slices.Equal(groups, []string{"user:alice", "user:bob"}) {
+		t.Errorf("WindowGroups() = %v, %v; want [user:alice user:bob]", groups, err)
+	}
+	if ids, err := db.WindowRules(); err != nil || !slices.Equal(ids, []string{"CORR-001", "CORR-002"}) {
+		t.Errorf("WindowRules() = %v, %v; want [CORR-001 CORR-002]", ids, err)
+	}
+	usage := make(map[string]int)
+	if err := db.WindowUsage(func(ruleID, groupKey string, events int) {
+		usage[ruleID+"/"+groupKey] = events
+	}); err != nil || usage["CORR-001/user:alice"] != 3 || usage["CORR-002/_global"] != 1 {
+		t.Errorf("WindowUsage() = %v, %v; want alice=3 _global=1", usage, err)
+	}
+
+	events, windows, err := db.PruneWindowEvents("CORR-001", func(ev map[string]any) bool {
+		return ev["n"].(float64) > 1
+	})
+	if err != nil || events != 2 || windows != 1 {
+		t.Errorf("PruneWindowEvents() = %d, %d, %v; want 2, 1", events, windows, err)
+	}
+	if err := db.CleanWindowEvents("CORR-001", "user:alice", 1); err != nil {
+		t.Fatalf("Failed to clean window: %v", err)
+	}
+	if events, _ := db.GetWindowEvents("CORR-001", "user:alice"); len(events) != 1 || events[0]["n"] != 3.0 {
+		t.Errorf("GetWindowEvents() = %v, want the latest event", events)
+	}
+	if fired, err := db.PruneWindowFired("CORR-001", func(time.Time) bool { return false }); err != nil || fired != 1 {
+		t.Errorf("PruneWindowFired() = %d, %v; want 1", fired, err)
+	}
+
+	before := db.Writes()
+	if dropped, err := db.DropWindowRule("CORR-002"); err != nil || dropped != 1 {
+		t.Errorf("DropWindowRule() = %d, %v; want 1", dropped, err)
+	}
+	if db.Writes() == before {
+		t.Error("Writes() did not grow after DropWindowRule")
+	}
+	before = db.Writes()
+	if err := db.ResetRate("CORR-002", "_global"); err != nil {
+		t.Fatalf("Failed to reset rate: %v", err)
+	}
+	if db.Writes() != before {
+		t.Error("Writes() grew after deleting nothing")
+	}
+	if ids, _ := db.WindowRules(); !slices.Equal(ids, []string{"CORR-001"}) {
+		t.Errorf("WindowRules() = %v, want [CORR-001]", ids)
+	}
+}
+
+func TestSQLiteTriageAndFires(t *testing.T) {
+	db, _ := setupTestSQLite(t, 1000)
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if changed, err := db.SetTriage("sig-1", "RULE-001", TriageTransition{Status: StatusAcknowledged, TS: t0}); err != nil || !changed {
+		t.Fatalf("SetTriage() = %v, %v; want true", changed, err)
+	}
+	if changed, _ := db.SetTriage("sig-1", "", TriageTransition{Status: StatusClosed, TS: t0.Add(-time.Minute)}); changed {
+		t.Error("SetTriage(out of order) changed the status")
+	}
+	if status, _ := db.SignalStatus("sig-1"); status != StatusAcknowledged {
+		t.Errorf("SignalStatus() = %q, want acknowledged", status)
+	}
+	if _, err := db.SetTriage("sig-1", "", TriageTransition{Status: "bogus", TS: t0}); err == nil {
+		t.Error("SetTriage(bogus) succeeded, want error")
+	}
+
+	add := map[string]*RuleFires{
+		"RULE-001": {Count: 2, First: t0, Last: t0.Add(time.Minute)},
+		"RULE-002": {Count: 1, First: t0, Last: t0},
+	}
+	for range 2 {
+		if err := db.AddRuleFires(add); err != nil {
+			t.Fatalf("Failed to add rule fires: %v", err)
+		}
+	}
+	fires, err := db.RuleFireHistory()
+	if err != nil || len(fires) != 2 || fires[0].RuleID != "RULE-001" || fires[0].Count != 4 {
+		t.Errorf("RuleFireHistory() = %+v, %v; want RULE-001 fired 4 times first", fires, err)
+	}
+	var count int
+	if err := db.db.QueryRow("SELECT count FROM rule_fire_counts WHERE rule_id = 'RULE-002'").Scan(&count); err != nil || count != 2 {
+		t.Errorf("rule_fire_counts RULE-002 = %d, %v; want 2", count, err)
+	}
+
+	if first, err := db.ReserveEventSeq(5); err != nil || first != 1 {
+		t.Errorf("ReserveEventSeq(5) = %d, %v; want 1", first, err)
+	}
+	if first, _ := db.ReserveEventSeq(1); first != 6 {
+		t.Errorf("ReserveEventSeq(1) = %d, want 6", first)
+	}
+
+	if err := db.SaveLineage(map[string][]byte{"boot-1": {1}, "boot-2": {2}}); err != nil {
+		t.Fatalf("Failed to save lineage: %v", err)
+	}
+	if err := db.SaveLineage(map[string][]byte{"boot-2": {3}}); err != nil {
+		t.Fatalf("Failed to save lineage: %v", err)
+	}
+	if lineage, err := db.LoadLineage(); err != nil || len(lineage) != 1 || lineage["boot-2"][0] != 3 {
+		t.Errorf("LoadLineage() = %v, %v; want boot-2 only", lineage, err)
+	}
+
+	if err := db.SaveCheckpoint("spool-1", Checkpoint{Next: 3}); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	if entry, err := db.GetJournalEntry("spool-1"); err != nil || entry.Checkpoint == nil || entry.Checkpoint.Next != 3 {
+		t.Errorf("GetJournalEntry() = %+v, %v; want checkpoint at 3", entry, err)
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats["triage"] != 1 || stats["rule_fires"] != 2 {
+		t.Errorf("Stats() = %v, want triage=1 rule_fires=2", stats)
+	}
+	if size, _ := stats["file_size"].(int64); size <= 0 {
+		t.Errorf("file_size = %v, want positive", stats["file_size"])
+	}
+	if err := db.RequestCompaction(); err != nil {
+		t.Fatalf("Failed to request compaction: %v", err)
+	}
+	if compacted, err := db.CompactIfRequested(); err != nil || !compacted {
+		t.Errorf("CompactIfRequested() = %v, %v; want true", compacted, err)
+	}
+}
@@ -0,0 +1,109 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// Backends of the state store, selected by state.backend
+const (
+	BackendBolt   = "bolt"
+	BackendSQLite = "sqlite"
+)
+
+// Store is the santamon state: the signal outbox and history, baseline and
+// correlation state, and spool processing progress. DB (BoltDB) and SQLite
+// implement it.
+type Store interface {
+	EnqueueSignal(sig *Signal) error
+	EnqueueSignalIfNotShipped(sig *Signal) (bool, error)
+	DequeueSignals(limit int) ([]*Signal, error)
+	DequeuePrioritySignals(limit int) ([]*Signal, error)
+	ListQueue(limit int) ([]*QueuedSignal, error)
+	DropQueued(signalID string) (int, error)
+	PruneQueue(before time.Time) (int, error)
+	History(since time.Time) ([]*HistoryEntry, error)
+	PruneHistory(before time.Time) (int, error)
+	MarkShipped(signalID string) error
+	IsShipped(signalID string) (bool, error)
+
+	SetTriage(signalID, ruleID string, t TriageTransition) (bool, error)
+	Triage(signalID string) (*TriageRecord, error)
+	SignalStatus(signalID string) (string, error)
+	ForEachTriage(fn func(rec *TriageRecord) error) error
+	PruneTriage(before time.Time) (int, error)
+
+	IsFirstSeen(kind, id string) (bool, error)
+	RecordSeen(kind, id string, now time.Time, forgetAfter time.Duration) (int, time.Time, error)
+	FirstSeenEntries(kind string) (map[string]FirstSeenEntry, error)
+	ForEachFirstSeen(fn func(kind, id string, entry FirstSeenEntry) error) error
+	PruneFirstSeen(before time.Time) (int, error)
+	FirstSeenStats(kind string) (int, int, error)
+	LearningStart(ruleID, hash string, now time.Time) (time.Time, error)
+	SeedFirstSeen(kind, id string, seed FirstSeenEntry) (bool, error)
+
+	Dedup(key string, sig *Signal, now time.Time, cooldown time.Duration) (bool, *DedupEntry, error)
+	ExpireDedup(before time.Time) ([]*DedupEntry, error)
+	AddAggregate(ruleID, target string, sig *Signal, now time.Time, maxTargets int) error
+	ExpireAggregates(before time.Time) ([]*AggregateEntry, error)
+	AddRuleFires(fires map[string]*RuleFires) error
+	RuleFireHistory() ([]*RuleFires, error)
+	SaveLineage(snapshots map[string][]byte) error
+	LoadLineage() (map[string][]byte, error)
+
+	UpdateJournal(filename string, offset int64) error
+	SaveCheckpoint(filename string, cp Checkpoint) error
+	GetJournalEntry(filename string) (*JournalEntry, error)
+	PruneJournal(before time.Time) (int, error)
+	ReserveEventSeq(n int) (uint64, error)
+	EventSeq() (uint64, error)
+	SetMeta(key, value string) error
+	GetMeta(key string) (string, error)
+
+	StoreWindowEvent(ruleID, groupKey string, event map[string]any) error
+	GetWindowEvents(ruleID, groupKey string) ([]map[string]any, error)
+	WindowGroups(ruleID string) ([]string, error)
+	WindowRules() ([]string, error)
+	PruneWindowEvents(ruleID string, keep func(event map[string]any) bool) (int, int, error)
+	PruneWindowFired(ruleID string, keep func(fired time.Time) bool) (int, error)
+	PruneRates(ruleID string, keep func(value float64, updated time.Time) bool) (int, error)
+	DropWindowRule(ruleID string) (int, error)
+	DropWindowGroup(ruleID, groupKey string) error
+	WindowUsage(fn func(ruleID, groupKey string, events int)) error
+	WindowBytes() (int64, error)
+	CleanWindowEvents(ruleID, groupKey string, keepCount int) error
+	ReplaceWindowEvents(ruleID, groupKey string, events []map[string]any) error
+	WindowFired(ruleID, groupKey string) (time.Time, error)
+	SetWindowFired(ruleID, groupKey string, ts time.Time) error
+	IncrementRate(ruleID, groupKey string, now time.Time, tau time.Duration) (float64, error)
+	ResetRate(ruleID, groupKey string) error
+
+	// Writes grows with every change of state; comparing two readings tells
+	// whether some work changed any state
+	Writes() int64
+	Ping() error
+	Stats() (map[string]any, error)
+	RequestCompaction() error
+	CompactIfRequested() (bool, error)
+	Compact() error
+	Close() error
+}
+
+var _ Store = (*DB)(nil)
+
+// OpenStore opens or creates the state store of the given backend at path.
+// An empty backend is bolt.
+func OpenStore(backend, path string, maxFirstSeen int, syncWrites bool) (Store, error) {
+	switch backend {
+	case "", BackendBolt:
+		db, err := Open(path, maxFirstSeen, syncWrites)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case BackendSQLite:
+		return openSQLite(path, maxFirstSeen, syncWrites)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", backend)
+	}
+}
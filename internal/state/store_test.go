@@ -0,0 +1,22 @@
+package state
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenStore(t *testing.T) {
+	store, err := OpenStore(BackendBolt, filepath.Join(t.TempDir(), "state.db"), 1000, false)
+	if err != nil {
+		t.Fatalf("OpenStore(bolt) error = %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if _, ok := store.(*DB); !ok {
+		t.Errorf("OpenStore(bolt) = %T, want *DB", store)
+	}
+
+	if _, err := OpenStore("leveldb", filepath.Join(t.TempDir(), "state.db"), 1000, false); err == nil || !strings.Contains(err.Error(), "unknown state backend") {
+		t.Errorf("OpenStore(leveldb) error = %v, want unknown state backend", err)
+	}
+}
@@ -0,0 +1,266 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/logutil"
+)
+
+var logger = logutil.For("tracing")
+
+const (
+	queueSize     = 2048            // Finished spans buffered for export
+	batchSize     = 512             // Spans per export request
+	flushInterval = 5 * time.Second // Maximum time a span waits for export
+)
+
+// Attr is a span attribute
+type Attr struct {
+	Key   string
+	Value any // string, bool, int, int64 or float64
+}
+
+// String returns a string attribute
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int returns an integer attribute
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+// Int64 returns an integer attribute
+func Int64(key string, value int64) Attr { return Attr{Key: key, Value: value} }
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// Tracer creates spans and exports them in the background to an
+// OpenTelemetry collector over OTLP/HTTP. A nil *Tracer (tracing disabled)
+// returns nil spans, and all *Span methods are no-ops on nil.
+type Tracer struct {
+	exporter   *exporter
+	sampleRate float64
+	queue      chan *Span
+	dropped    atomic.Int64
+}
+
+// New creates a tracer exporting to cfg.Endpoint. resource describes this
+// agent (e.g. host.name) and is attached to every exported span.
+func New(cfg config.TracingConfig, resource ...Attr) *Tracer {
+	resource = append([]Attr{String("service.name", cfg.ServiceName)}, resource...)
+	return &Tracer{
+		exporter:   newExporter(cfg, resource),
+		sampleRate: cfg.SampleRate,
+		queue:      make(chan *Span, queueSize),
+	}
+}
+
+// Start exports finished spans until ctx is cancelled, then flushes what is left
+func (t *Tracer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	export := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.export(ctx, batch); err != nil {
+			logger.Warn("Failed to export %d spans: %v", len(batch), err)
+		}
+		if n := t.dropped.Swap(0); n > 0 {
+			logger.Warn("Dropped %d spans (export queue full)", n)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Drain what is already queued with a short deadline
+			flushCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			for {
+				select {
+				case span := <-t.queue:
+					batch = append(batch, span)
+					if len(batch) == batchSize {
+						export(flushCtx)
+					}
+				default:
+					export(flushCtx)
+					return ctx.Err()
+				}
+			}
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) == batchSize {
+				export(ctx)
+			}
+		case <-ticker.C:
+			export(ctx)
+		}
+	}
+}
+
+// StartSpan starts a span as a child of the span in ctx, or as the root of a
+// new trace. Root spans are sampled at the configured rate; children follow
+// their parent's decision.
+func (t *Tracer) StartSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, name: name, start: time.Now(), attrs: attrs}
+	if parent := spanFromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = parent.sampled
+	} else if remote, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+		span.traceID = remote.traceID
+		span.parentID = remote.spanID
+		span.sampled = true
+	} else {
+		span.traceID = randomID(16)
+		span.sampled = t.sampleRate >= 1 || mathrand.Float64() < t.sampleRate
+	}
+	span.spanID = randomID(8)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// enqueue hands a finished span to the exporter without blocking
+func (t *Tracer) enqueue(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Span is a timed unit of work within a trace
+type Span struct {
+	tracer   *Tracer
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+	sampled  bool
+	start    time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []Attr
+	errMsg string
+	ended  bool
+}
+
+// SetAttr adds attributes to the span
+func (s *Span) SetAttr(attrs ...Attr) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || !s.sampled || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// Record adds an already finished span that started at start and took d as
+// a child of the span in ctx, and returns a context whose spans are its
+// children. It is used for work timed before it is known to be worth a span,
+// such as a rule evaluation that turned out to match. Nothing is recorded
+// unless the span in ctx is sampled.
+func (t *Tracer) Record(ctx context.Context, name string, start time.Time, d time.Duration, attrs ...Attr) context.Context {
+	parent := spanFromContext(ctx)
+	if t == nil || !parent.Sampled() {
+		return ctx
+	}
+	span := &Span{
+		tracer:   t,
+		name:     name,
+		traceID:  parent.traceID,
+		spanID:   randomID(8),
+		parentID: parent.spanID,
+		sampled:  true,
+		start:    start,
+		end:      start.Add(d),
+		attrs:    attrs,
+		ended:    true,
+	}
+	t.enqueue(span)
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Sampled reports whether the span will be exported
+func (s *Span) Sampled() bool {
+	return s != nil && s.sampled
+}
+
+// IDs returns the hex trace and span IDs of a sampled span, or empty strings
+func (s *Span) IDs() (traceID, spanID string) {
+	if !s.Sampled() {
+		return "", ""
+	}
+	return s.traceID, s.spanID
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+type remoteParent struct {
+	traceID string
+	spanID  string
+}
+
+func spanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// WithParent returns a context whose next span continues the trace of a span
+// recorded earlier, e.g. shipping a signal generated before a restart.
+// Empty IDs leave ctx unchanged.
+func WithParent(ctx context.Context, traceID, spanID string) context.Context {
+	if traceID == "" || spanID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, remoteParent{traceID: traceID, spanID: spanID})
+}
+
+// randomID returns n random bytes, hex encoded
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // Never fails since Go 1.24
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+// collector records the spans of every OTLP export request
+type collector struct {
+	mu     sync.Mutex
+	spans  []otlpSpan
+	header http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header = r.Header
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *collector) byName() map[string]otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]otlpSpan)
+	for _, s := range c.spans {
+		out[s.Name] = s
+	}
+	return out
+}
+
+func TestExport(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	tracer := New(config.TracingConfig{
+		Endpoint:    srv.URL,
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		ServiceName: "santamon",
+		SampleRate:  1,
+		Timeout:     time.Second,
+	}, String("host.name", "test-host"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = tracer.Start(ctx)
+		close(done)
+	}()
+
+	fileCtx, file := tracer.StartSpan(context.Background(), "spool.file", String("spool.file", "a.pb"))
+	_, decode := tracer.StartSpan(fileCtx, "spool.decode")
+	decode.RecordError(errors.New("truncated"))
+	decode.End()
+	evalStart := time.Now().Add(-time.Second)
+	evalCtx := tracer.Record(fileCtx, "rule.evaluate", evalStart, 5*time.Millisecond, String("rule.id", "R1"), Int("rule.matches", 2))
+	_, generate := tracer.StartSpan(evalCtx, "signal.generate")
+	generate.End()
+	file.End()
+
+	// Shipping later continues the trace from the stored IDs
+	traceID, spanID := file.IDs()
+	_, ship := tracer.StartSpan(WithParent(context.Background(), traceID, spanID), "signal.ship")
+	ship.End()
+
+	cancel()
+	<-done
+
+	spans := c.byName()
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 exported spans, got %d", len(spans))
+	}
+	root := spans["spool.file"]
+	if root.ParentSpanID != "" || len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Errorf("Unexpected root span: %+v", root)
+	}
+	for _, name := range []string{"spool.decode", "rule.evaluate", "signal.ship"} {
+		if s := spans[name]; s.TraceID != root.TraceID || s.ParentSpanID != root.SpanID {
+			t.Errorf("%s is not a child of spool.file: %+v", name, s)
+		}
+	}
+	if s := spans["spool.decode"]; s.Status == nil || s.Status.Code != statusCodeError || s.Status.Message != "truncated" {
+		t.Errorf("Expected error status on spool.decode, got %+v", s.Status)
+	}
+	eval := spans["rule.evaluate"]
+	if len(eval.Attributes) != 2 || *eval.Attributes[1].Value.IntValue != "2" {
+		t.Errorf("Unexpected rule.evaluate attributes: %+v", eval.Attributes)
+	}
+	if want := strconv.FormatInt(evalStart.UnixNano(), 10); eval.StartTimeUnixNano != want {
+		t.Errorf("rule.evaluate start = %s, want %s", eval.StartTimeUnixNano, want)
+	}
+	if s := spans["signal.generate"]; s.ParentSpanID != eval.SpanID {
+		t.Errorf("signal.generate is not a child of rule.evaluate: %+v", s)
+	}
+	if got := c.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization header = %q", got)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	tracer := New(config.TracingConfig{SampleRate: 0.0000001})
+	ctx, root := tracer.StartSpan(context.Background(), "spool.file")
+	_, child := tracer.StartSpan(ctx, "spool.decode")
+	if root.Sampled() || child.Sampled() {
+		t.Fatal("Expected unsampled root and child")
+	}
+	if traceID, _ := child.IDs(); traceID != "" {
+		t.Errorf("Expected no IDs for unsampled span, got %q", traceID)
+	}
+	if got := tracer.Record(ctx, "rule.evaluate", time.Now(), time.Second); got != ctx {
+		t.Error("Recorded a child of an unsampled span")
+	}
+	root.End()
+	if len(tracer.queue) != 0 {
+		t.Errorf("Unsampled span was queued for export")
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.StartSpan(context.Background(), "spool.file")
+	if span != nil || ctx == nil {
+		t.Fatalf("Expected nil span from nil tracer")
+	}
+	span.SetAttr(String("k", "v"))
+	span.RecordError(errors.New("boom"))
+	if got := tracer.Record(ctx, "child", time.Now(), time.Second); got != ctx {
+		t.Error("Nil tracer recorded a span")
+	}
+	span.End()
+	if span.Sampled() {
+		t.Error("Nil span reported as sampled")
+	}
+}
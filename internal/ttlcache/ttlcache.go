@@ -0,0 +1,57 @@
+package ttlcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache maps keys to values that expire ttl after they were stored. It holds
+// at most max entries: when full, expired entries are dropped first, and if
+// none had expired the cache starts over. Callers pass the current time, so
+// they keep control of the clock.
+type Cache[V any] struct {
+	ttl time.Duration
+	max int
+
+	mu      sync.Mutex
+	entries map[string]entry[V]
+}
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// New creates a cache of up to max entries that expire after ttl
+func New[V any](ttl time.Duration, max int) *Cache[V] {
+	return &Cache[V]{ttl: ttl, max: max, entries: make(map[string]entry[V])}
+}
+
+// Get returns the value stored under key, unless it expired before now
+func (c *Cache[V]) Get(key string, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.After(e.expires) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Put stores value under key at now
+func (c *Cache[V]) Put(key string, value V, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.max {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.max {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = entry[V]{value: value, expires: now.Add(c.ttl)}
+}
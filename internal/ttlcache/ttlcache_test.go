@@ -0,0 +1,42 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := New[int](time.Hour, 2)
+
+	if _, ok := c.Get("a", now); ok {
+		t.Error("Get() of a missing key succeeded")
+	}
+	c.Put("a", 1, now)
+	if v, ok := c.Get("a", now.Add(time.Hour)); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v; want 1", v, ok)
+	}
+	if _, ok := c.Get("a", now.Add(time.Hour+time.Second)); ok {
+		t.Error("Get() returned an expired entry")
+	}
+
+	// A full cache drops expired entries first
+	c.Put("b", 2, now.Add(30*time.Minute))
+	c.Put("c", 3, now.Add(90*time.Minute))
+	later := now.Add(90 * time.Minute)
+	if _, ok := c.Get("b", later); !ok {
+		t.Error("Unexpired entry b was dropped")
+	}
+	if len(c.entries) != 2 {
+		t.Errorf("Expected 2 entries, got %d", len(c.entries))
+	}
+
+	// And starts over when none expired
+	c.Put("d", 4, later)
+	if _, ok := c.Get("b", later); ok {
+		t.Error("Full cache kept entry b")
+	}
+	if v, ok := c.Get("d", later); !ok || v != 4 {
+		t.Errorf("Get(d) = %d, %v; want 4", v, ok)
+	}
+}
@@ -0,0 +1,294 @@
+package tune
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// Options controls which patterns are worth suggesting
+type Options struct {
+	MinSignals int     // Rules with fewer signals in history are skipped
+	MinShare   float64 // Fraction of a rule's signals a pattern must cover (0-1]
+}
+
+// DefaultOptions returns the thresholds used by santamon tune
+func DefaultOptions() Options {
+	return Options{MinSignals: 10, MinShare: 0.8}
+}
+
+// Suggestion is a candidate exception for a noisy rule
+type Suggestion struct {
+	RuleID string
+	Field  string // Signal context field the pattern was found in
+	Value  string // Exact value, or prefix when Prefix is set
+	Prefix bool
+	Kind   string // Event kind of the covered signals
+	Count  int    // Signals covered by the pattern
+	Total  int    // Signals for the rule in the analyzed history
+	Expr   string // CEL exception expression
+}
+
+// Share returns the fraction of the rule's signals covered by the suggestion
+func (s Suggestion) Share() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Count) / float64(s.Total)
+}
+
+// Pattern returns the value as displayed to users, with a trailing * for prefixes
+func (s Suggestion) Pattern() string {
+	if s.Prefix {
+		return s.Value + "*"
+	}
+	return s.Value
+}
+
+// YAML returns an exceptions block ready to paste under the rule
+func (s Suggestion) YAML() (string, error) {
+	out, err := yaml.Marshal(map[string][]string{"exceptions": {s.Expr}})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal exception: %w", err)
+	}
+	header := fmt.Sprintf("# %s: %.0f%% of signals (%d/%d) have %s %s\n",
+		s.RuleID, s.Share()*100, s.Count, s.Total, s.Field, s.Pattern())
+	return header + string(out), nil
+}
+
+// minPathDepth is the minimum directory depth of a suggested path prefix
+const minPathDepth = 3
+
+// celField locates a signal context field in the event for one event kind
+type celField struct {
+	path  string // CEL path of the field
+	guard string // Optional has() check for the enclosing message
+	team  string // CEL path of the team ID, for signing IDs keyed as TEAMID:signing_id
+}
+
+// fieldPaths maps tunable signal context fields to CEL paths by event kind.
+// Fields are listed in order of preference: signing identities are more stable
+// across updates than paths or hashes.
+var fieldPaths = []struct {
+	field string
+	kinds map[string]celField
+}{
+	{"actor_signing_id", map[string]celField{
+		"file_access": {path: "event.file_access.instigator.code_signature.signing_id", guard: "event.file_access.instigator.code_signature", team: "event.file_access.instigator.code_signature.team_id"},
+	}},
+	{"actor_team", map[string]celField{
+		"file_access": {path: "event.file_access.instigator.code_signature.team_id", guard: "event.file_access.instigator.code_signature"},
+	}},
+	{"target_team", map[string]celField{
+		"execution": {path: "event.execution.target.code_signature.team_id", guard: "event.execution.target.code_signature"},
+	}},
+	{"actor_path", map[string]celField{
+		"execution":   {path: "event.execution.instigator.executable.path"},
+		"file_access": {path: "event.file_access.instigator.executable.path"},
+	}},
+	{"target_path", map[string]celField{
+		"execution":   {path: "event.execution.target.executable.path"},
+		"file_access": {path: "event.file_access.target.path"},
+		"xprotect":    {path: "event.xprotect.detected.detected_path"},
+	}},
+	{"target_sha256", map[string]celField{
+		"execution": {path: "event.execution.target.executable.hash.hash"},
+	}},
+}
+
+// Analyze groups history by rule and suggests at most one exception per rule
+// whose signals are dominated by a single value or prefix. Suggestions are
+// ordered by the number of signals they would suppress.
+func Analyze(entries []*state.HistoryEntry, opts Options) []Suggestion {
+	if opts.MinShare <= 0 || opts.MinShare > 1 {
+		opts.MinShare = DefaultOptions().MinShare
+	}
+
+	byRule := make(map[string][]*state.HistoryEntry)
+	for _, e := range entries {
+		byRule[e.RuleID] = append(byRule[e.RuleID], e)
+	}
+
+	var suggestions []Suggestion
+	for ruleID, ruleEntries := range byRule {
+		if len(ruleEntries) < opts.MinSignals {
+			continue
+		}
+		if s, ok := bestSuggestion(ruleID, ruleEntries, opts.MinShare); ok {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		return suggestions[i].RuleID < suggestions[j].RuleID
+	})
+	return suggestions
+}
+
+// bestSuggestion returns the highest-coverage pattern for one rule
+func bestSuggestion(ruleID string, entries []*state.HistoryEntry, minShare float64) (Suggestion, bool) {
+	var best Suggestion
+	found := false
+	for _, fp := range fieldPaths {
+		s, ok := fieldSuggestion(fp.field, fp.kinds, entries, minShare)
+		if !ok {
+			continue
+		}
+		// Earlier fields win ties; an exact value beats a prefix with the same
+		// coverage, except a signing ID prefix, which is narrower than its team
+		if !found || s.Count > best.Count || (s.Count == best.Count && best.Prefix && !s.Prefix && best.Field != "actor_signing_id") {
+			best = s
+			found = true
+		}
+	}
+	best.RuleID = ruleID
+	return best, found
+}
+
+// fieldSuggestion looks for a dominant exact value, then a dominant prefix, in one field
+func fieldSuggestion(field string, kinds map[string]celField, entries []*state.HistoryEntry, minShare float64) (Suggestion, bool) {
+	total := len(entries)
+	need := int(minShare*float64(total) + 0.999999)
+
+	exact := make(map[string]int)
+	prefixes := make(map[string]int)
+	for _, e := range entries {
+		v := fieldValue(e, field)
+		if v == "" {
+			continue
+		}
+		exact[v]++
+		if p := prefixOf(field, v); p != "" {
+			prefixes[p]++
+		}
+	}
+
+	candidates := []struct {
+		counts map[string]int
+		prefix bool
+	}{{exact, false}, {prefixes, true}}
+
+	for _, c := range candidates {
+		value, count := top(c.counts)
+		if count < need {
+			continue
+		}
+		kind, ok := coveredKind(entries, field, value, c.prefix)
+		if !ok {
+			continue
+		}
+		cf, ok := kinds[kind]
+		if !ok {
+			continue
+		}
+		return Suggestion{
+			Field:  field,
+			Value:  value,
+			Prefix: c.prefix,
+			Kind:   kind,
+			Count:  count,
+			Total:  total,
+			Expr:   exceptionExpr(cf, value, c.prefix),
+		}, true
+	}
+	return Suggestion{}, false
+}
+
+// top returns the most frequent key, breaking ties alphabetically
+func top(counts map[string]int) (string, int) {
+	best, bestCount := "", 0
+	for k, n := range counts {
+		if n > bestCount || (n == bestCount && k < best) {
+			best, bestCount = k, n
+		}
+	}
+	return best, bestCount
+}
+
+// coveredKind returns the event kind shared by all signals matching the pattern
+func coveredKind(entries []*state.HistoryEntry, field, value string, prefix bool) (string, bool) {
+	kind := ""
+	for _, e := range entries {
+		v := fieldValue(e, field)
+		if v == "" || (prefix && !strings.HasPrefix(v, value)) || (!prefix && v != value) {
+			continue
+		}
+		k := e.Fields["kind"]
+		if kind != "" && k != kind {
+			return "", false
+		}
+		kind = k
+	}
+	return kind, kind != ""
+}
+
+// fieldValue returns the value of a tunable field for an entry. Signals carry
+// the bare signing ID, which any ad hoc signed binary can claim, so signing
+// IDs are keyed as TEAMID:signing_id like Santa rules, allowlists and intel
+// feeds name them; signing IDs without a team ID are not suggested.
+func fieldValue(e *state.HistoryEntry, field string) string {
+	v := e.Fields[field]
+	if field == "actor_signing_id" {
+		team := e.Fields["actor_team"]
+		if v == "" || team == "" {
+			return ""
+		}
+		return team + ":" + v
+	}
+	return v
+}
+
+// prefixOf returns the grouping prefix for a value: the vendor part of a
+// reverse-DNS signing ID, or the app bundle / parent directory of a path
+func prefixOf(field, value string) string {
+	switch field {
+	case "actor_signing_id":
+		// Keyed as TEAMID:com.vendor.product (see fieldValue)
+		team, id, _ := strings.Cut(value, ":")
+		parts := strings.Split(id, ".")
+		if len(parts) < 3 {
+			return ""
+		}
+		return team + ":" + parts[0] + "." + parts[1] + "."
+	case "actor_path", "target_path":
+		if i := strings.Index(value, ".app/"); i >= 0 {
+			return value[:i+len(".app/")]
+		}
+		// Shallow directories such as /tmp or /usr/bin are too broad to except
+		dir := path.Dir(value)
+		if strings.Count(dir, "/") < minPathDepth {
+			return ""
+		}
+		return dir + "/"
+	}
+	return ""
+}
+
+// exceptionExpr renders the CEL expression for a pattern
+func exceptionExpr(cf celField, value string, prefix bool) string {
+	team := ""
+	if cf.team != "" {
+		team, value, _ = strings.Cut(value, ":")
+	}
+	quoted := strconv.Quote(value)
+	expr := cf.path + " == " + quoted
+	if prefix {
+		expr = cf.path + ".startsWith(" + quoted + ")"
+	}
+	if team != "" {
+		expr = cf.team + " == " + strconv.Quote(team) + " && " + expr
+	}
+	if cf.guard != "" {
+		expr = "has(" + cf.guard + ") && " + expr
+	}
+	return expr
+}
@@ -0,0 +1,162 @@
+package tune
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/0x4d31/santamon/internal/state"
+)
+
+// history builds n entries for a rule with the given context fields
+func history(ruleID string, n int, fields func(i int) map[string]string) []*state.HistoryEntry {
+	entries := make([]*state.HistoryEntry, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, &state.HistoryEntry{
+			SignalID: fmt.Sprintf("%s-%d", ruleID, i),
+			RuleID:   ruleID,
+			Fields:   fields(i),
+		})
+	}
+	return entries
+}
+
+func TestAnalyzeSigningIDPrefix(t *testing.T) {
+	products := []string{"goland", "pycharm", "idea"}
+	entries := history("R-014", 20, func(i int) map[string]string {
+		// Signals carry the bare signing ID and the team ID separately
+		team, sid := "2ZEFAR8TH3", "com.jetbrains."+products[i%len(products)]
+		if i >= 18 {
+			team, sid = "", "com.apple.ssh"
+		}
+		return map[string]string{
+			"kind":             "file_access",
+			"actor_team":       team,
+			"actor_signing_id": sid,
+			"actor_path":       fmt.Sprintf("/tmp/bin%d", i),
+		}
+	})
+
+	got := Analyze(entries, DefaultOptions())
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 suggestion, got %+v", got)
+	}
+	s := got[0]
+	if s.Field != "actor_signing_id" || !s.Prefix || s.Value != "2ZEFAR8TH3:com.jetbrains." {
+		t.Errorf("Unexpected suggestion: %+v", s)
+	}
+	if s.Count != 18 || s.Total != 20 {
+		t.Errorf("Count = %d/%d, want 18/20", s.Count, s.Total)
+	}
+	want := `has(event.file_access.instigator.code_signature) && ` +
+		`event.file_access.instigator.code_signature.team_id == "2ZEFAR8TH3" && ` +
+		`event.file_access.instigator.code_signature.signing_id.startsWith("com.jetbrains.")`
+	if s.Expr != want {
+		t.Errorf("Expr = %s\nwant %s", s.Expr, want)
+	}
+}
+
+// Bare signing IDs, which ad hoc signed binaries can claim, are not suggested
+func TestAnalyzeSigningIDWithoutTeam(t *testing.T) {
+	entries := history("R-015", 10, func(i int) map[string]string {
+		return map[string]string{"kind": "file_access", "actor_signing_id": "com.example.tool"}
+	})
+	if got := Analyze(entries, DefaultOptions()); len(got) != 0 {
+		t.Errorf("Expected no suggestions, got %+v", got)
+	}
+}
+
+func TestAnalyzeExactValueAndPaths(t *testing.T) {
+	entries := history("SM-010", 10, func(i int) map[string]string {
+		return map[string]string{
+			"kind":        "execution",
+			"target_path": "/Applications/Docker.app/Contents/MacOS/com.docker.backend",
+		}
+	})
+	entries = append(entries, history("SM-011", 10, func(i int) map[string]string {
+		return map[string]string{
+			"kind":        "execution",
+			"target_path": fmt.Sprintf("/Applications/Zoom.app/Contents/Frameworks/helper%d", i),
+		}
+	})...)
+
+	got := Analyze(entries, DefaultOptions())
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 suggestions, got %+v", got)
+	}
+	for _, s := range got {
+		switch s.RuleID {
+		case "SM-010":
+			if s.Prefix || s.Expr != `event.execution.target.executable.path == "/Applications/Docker.app/Contents/MacOS/com.docker.backend"` {
+				t.Errorf("Unexpected SM-010 suggestion: %+v", s)
+			}
+		case "SM-011":
+			if !s.Prefix || s.Value != "/Applications/Zoom.app/" {
+				t.Errorf("Unexpected SM-011 suggestion: %+v", s)
+			}
+		default:
+			t.Errorf("Unexpected rule %s", s.RuleID)
+		}
+	}
+}
+
+func TestAnalyzeThresholds(t *testing.T) {
+	// Too few signals
+	few := history("R1", 5, func(i int) map[string]string {
+		return map[string]string{"kind": "execution", "target_path": "/usr/local/bin/tool"}
+	})
+	if got := Analyze(few, DefaultOptions()); len(got) != 0 {
+		t.Errorf("Expected no suggestions below MinSignals, got %+v", got)
+	}
+
+	// No dominant pattern
+	diverse := history("R2", 20, func(i int) map[string]string {
+		return map[string]string{"kind": "execution", "target_path": fmt.Sprintf("/opt/app%d/bin/tool", i)}
+	})
+	if got := Analyze(diverse, DefaultOptions()); len(got) != 0 {
+		t.Errorf("Expected no suggestions without a dominant pattern, got %+v", got)
+	}
+
+	// Dominant pattern spans event kinds, so no single CEL path applies
+	mixed := history("R3", 20, func(i int) map[string]string {
+		kind := "execution"
+		if i%2 == 0 {
+			kind = "file_access"
+		}
+		return map[string]string{"kind": kind, "actor_path": "/usr/bin/python3"}
+	})
+	if got := Analyze(mixed, DefaultOptions()); len(got) != 0 {
+		t.Errorf("Expected no suggestions across kinds, got %+v", got)
+	}
+}
+
+func TestSuggestionYAML(t *testing.T) {
+	s := Suggestion{
+		RuleID: "R-014",
+		Field:  "actor_signing_id",
+		Value:  "com.jetbrains.",
+		Prefix: true,
+		Count:  9,
+		Total:  10,
+		Expr:   `event.file_access.instigator.code_signature.signing_id.startsWith("com.jetbrains.")`,
+	}
+	out, err := s.YAML()
+	if err != nil {
+		t.Fatalf("Failed to render YAML: %v", err)
+	}
+	if !strings.HasPrefix(out, "# R-014: 90% of signals (9/10) have actor_signing_id com.jetbrains.*\n") {
+		t.Errorf("Unexpected header: %q", out)
+	}
+
+	var parsed struct {
+		Exceptions []string `yaml:"exceptions"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+	if len(parsed.Exceptions) != 1 || parsed.Exceptions[0] != s.Expr {
+		t.Errorf("Round-tripped exceptions = %v", parsed.Exceptions)
+	}
+}
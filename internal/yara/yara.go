@@ -0,0 +1,164 @@
+package yara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+	"github.com/0x4d31/santamon/internal/ttlcache"
+)
+
+var (
+	// ErrBusy is returned when max_concurrent scans are already running
+	ErrBusy = errors.New("yara scan skipped: too many scans running")
+
+	// ErrTooLarge is returned for files over max_file_size_mb
+	ErrTooLarge = errors.New("yara scan skipped: file too large")
+)
+
+// maxCacheEntries bounds the result cache; expired entries are dropped first
+const maxCacheEntries = 10000
+
+// Provider scans a file with YARA rules and returns the names of the rules
+// that matched. sha256 is the file's hash, if known, and keys the cache.
+type Provider interface {
+	Scan(ctx context.Context, path, sha256 string) ([]string, error)
+}
+
+// Scanner runs the yara command-line scanner on executables, a bounded
+// number at a time, caching results by SHA-256
+type Scanner struct {
+	binary   string
+	rules    string
+	compiled bool
+	maxSize  int64
+	timeout  time.Duration
+	sem      chan struct{}
+
+	now   func() time.Time
+	cache *ttlcache.Cache[[]string]
+}
+
+// New creates a scanner for the configured rules. It fails if the rules
+// file or the yara binary cannot be found.
+func New(cfg config.YARAConfig) (*Scanner, error) {
+	if _, err := os.Stat(cfg.Rules); err != nil {
+		return nil, fmt.Errorf("yara rules: %w", err)
+	}
+	binary := cfg.Binary
+	if binary == "" {
+		binary = "yara"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("yara scanner: %w", err)
+	}
+	concurrent := cfg.MaxConcurrent
+	if concurrent <= 0 {
+		concurrent = 1
+	}
+	return &Scanner{
+		binary:   path,
+		rules:    cfg.Rules,
+		compiled: cfg.Compiled,
+		maxSize:  int64(cfg.MaxFileSizeMB) << 20,
+		timeout:  cfg.Timeout,
+		sem:      make(chan struct{}, concurrent),
+		now:      time.Now,
+		cache:    ttlcache.New[[]string](cfg.CacheTTL, maxCacheEntries),
+	}, nil
+}
+
+// Scan returns the cached matches for sha256 or scans path. Scans never
+// wait for a free slot: when max_concurrent scans are running, Scan returns
+// ErrBusy.
+func (s *Scanner) Scan(ctx context.Context, path, sha256 string) ([]string, error) {
+	sha256 = strings.ToLower(sha256)
+	if matches, ok := s.cached(sha256); ok {
+		return matches, nil
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("yara scan skipped: %q is not an absolute path", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("yara scan skipped: %s is not a regular file", path)
+	}
+	if s.maxSize > 0 && info.Size() > s.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	default:
+		return nil, ErrBusy
+	}
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	matches, err := s.run(ctx, path)
+	if err != nil {
+		// Errors are not cached so a transient failure is retried on the next signal
+		return nil, err
+	}
+	if sha256 != "" {
+		s.store(sha256, matches)
+	}
+	return matches, nil
+}
+
+// run scans path with yara and parses the matched rule names from its
+// output, one "<rule> <path>" line per match
+func (s *Scanner) run(ctx context.Context, path string) ([]string, error) {
+	args := []string{"-w"}
+	if s.compiled {
+		args = append(args, "-C")
+	}
+	args = append(args, s.rules, path)
+	cmd := exec.CommandContext(ctx, s.binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("yara failed: %w (%s)", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	seen := make(map[string]bool)
+	matches := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		rule, _, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		matches = append(matches, rule)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func (s *Scanner) cached(sha256 string) ([]string, bool) {
+	if sha256 == "" {
+		return nil, false
+	}
+	return s.cache.Get(sha256, s.now())
+}
+
+func (s *Scanner) store(sha256 string, matches []string) {
+	s.cache.Put(sha256, matches, s.now())
+}
@@ -0,0 +1,149 @@
+package yara
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x4d31/santamon/internal/config"
+)
+
+func writeFile(t *testing.T, path, data string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeYARA writes a stand-in for the yara scanner that reports two rules for
+// files containing EVIL and logs its arguments to calls
+func fakeYARA(t *testing.T, dir string) (binary, rules, calls string) {
+	t.Helper()
+	binary = filepath.Join(dir, "yara")
+	rules = filepath.Join(dir, "rules.yar")
+	calls = filepath.Join(dir, "calls")
+	writeFile(t, binary, `#!/bin/sh
+echo "$@" >> "`+calls+`"
+for f; do :; done
+if grep -q EVIL "$f"; then
+  echo "Mal_Loader $f"
+  echo "Generic_Packer $f"
+  echo "Mal_Loader $f"
+fi
+`, 0755)
+	writeFile(t, rules, "rule Mal_Loader { condition: true }\n", 0644)
+	return binary, rules, calls
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	binary, rules, calls := fakeYARA(t, dir)
+	evil := filepath.Join(dir, "evil")
+	clean := filepath.Join(dir, "clean")
+	writeFile(t, evil, "EVIL", 0755)
+	writeFile(t, clean, "hello", 0755)
+
+	s, err := New(config.YARAConfig{Binary: binary, Rules: rules, Compiled: true, MaxFileSizeMB: 1, MaxConcurrent: 1, Timeout: 5 * time.Second, CacheTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	ctx := context.Background()
+
+	matches, err := s.Scan(ctx, evil, "AA")
+	if err != nil {
+		t.Fatalf("Scan() failed: %v", err)
+	}
+	if !slices.Equal(matches, []string{"Generic_Packer", "Mal_Loader"}) {
+		t.Errorf("Scan() = %v", matches)
+	}
+	if matches, err := s.Scan(ctx, clean, "bb"); err != nil || matches == nil || len(matches) != 0 {
+		t.Errorf("Scan(clean) = %#v, %v; want no matches", matches, err)
+	}
+
+	// Results are cached by hash
+	_, _ = s.Scan(ctx, evil, "aa")
+	_, _ = s.Scan(ctx, clean, "BB")
+	data, _ := os.ReadFile(calls)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 scans, got %q", data)
+	}
+	if lines[0] != "-w -C "+rules+" "+evil {
+		t.Errorf("yara arguments = %q", lines[0])
+	}
+
+	// Until the TTL expires
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, _ = s.Scan(ctx, evil, "aa")
+	if data, _ := os.ReadFile(calls); strings.Count(string(data), "\n") != 3 {
+		t.Errorf("Expected a new scan after the TTL, got %q", data)
+	}
+}
+
+func TestScanLimits(t *testing.T) {
+	dir := t.TempDir()
+	binary, rules, _ := fakeYARA(t, dir)
+	s, err := New(config.YARAConfig{Binary: binary, Rules: rules, MaxFileSizeMB: 1, MaxConcurrent: 1})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	ctx := context.Background()
+
+	large := filepath.Join(dir, "large")
+	writeFile(t, large, strings.Repeat("x", 2<<20), 0755)
+	if _, err := s.Scan(ctx, large, ""); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Scan(large) = %v, want ErrTooLarge", err)
+	}
+	if _, err := s.Scan(ctx, dir, ""); err == nil {
+		t.Error("Scan() of a directory succeeded")
+	}
+	if _, err := s.Scan(ctx, "relative", ""); err == nil {
+		t.Error("Scan() of a relative path succeeded")
+	}
+	if _, err := s.Scan(ctx, filepath.Join(dir, "missing"), ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Scan(missing) = %v", err)
+	}
+
+	// Scans do not queue behind running ones
+	small := filepath.Join(dir, "small")
+	writeFile(t, small, "EVIL", 0755)
+	s.sem <- struct{}{}
+	if _, err := s.Scan(ctx, small, ""); !errors.Is(err, ErrBusy) {
+		t.Errorf("Scan() with every slot taken = %v, want ErrBusy", err)
+	}
+	<-s.sem
+	if _, err := s.Scan(ctx, small, ""); err != nil {
+		t.Errorf("Scan() after a slot freed up = %v", err)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.yar")
+	writeFile(t, rules, "", 0644)
+	failing := filepath.Join(dir, "yara")
+	writeFile(t, failing, "#!/bin/sh\necho 'syntax error in rules' >&2\nexit 1\n", 0755)
+
+	if _, err := New(config.YARAConfig{Binary: failing, Rules: filepath.Join(dir, "missing.yar")}); err == nil {
+		t.Error("New() accepted a missing rules file")
+	}
+	if _, err := New(config.YARAConfig{Binary: filepath.Join(dir, "missing"), Rules: rules}); err == nil {
+		t.Error("New() accepted a missing binary")
+	}
+
+	s, err := New(config.YARAConfig{Binary: failing, Rules: rules})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if _, err := s.Scan(context.Background(), rules, "cc"); err == nil || !strings.Contains(err.Error(), "syntax error in rules") {
+		t.Errorf("Expected scanner failure with stderr, got %v", err)
+	}
+	if _, ok := s.cached("cc"); ok {
+		t.Error("Failed scan was cached")
+	}
+}